@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Queries the audit log of the operations changing the repository",
+	Long: `Queries the audit log of the operations changing the repository.
+
+Every operation changing the kopia repository, like snap or purge-file,
+stores an audit record in the repository with the user and host running it,
+the time, the command and the snapshot manifests it affected. The records
+are never changed or deleted by gasset.`,
+	Args: cobra.NoArgs,
+	RunE: AuditRun,
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().String("user", "", "Only shows the operations of the given user")
+	auditCmd.Flags().String("command", "", "Only shows the operations of the given command, e.g. purge-file")
+	auditCmd.Flags().Duration("since", 0, "Only shows the operations within the given duration, e.g. 720h")
+	auditCmd.Flags().Bool("json", false, "Prints the records as JSON lines")
+}
+
+func AuditRun(cmd *cobra.Command, _ []string) error {
+	log.Println("audit called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	filter := util.AuditFilter{}
+	if filter.User, err = cmd.Flags().GetString("user"); err != nil {
+		return err
+	}
+	if filter.Command, err = cmd.Flags().GetString("command"); err != nil {
+		return err
+	}
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		return err
+	}
+	if since > 0 {
+		filter.Since = time.Now().Add(-since)
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	return showAudit(context.Background(), options, filter, asJson, cmd.OutOrStdout())
+}
+
+func showAudit(ctx context.Context, op *util.Options, filter util.AuditFilter, asJson bool, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	records, err := util.ListAuditRecords(ctx, rep, filter)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if asJson {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s@%s\t%s", util.FormatTime(record.Time, op.LocalTime), record.User, record.Host, record.Command)
+		if len(record.Args) > 0 {
+			fmt.Fprintf(w, " %s", strings.Join(record.Args, " "))
+		}
+		for _, id := range record.Manifests {
+			fmt.Fprintf(w, "\n\t%s", id)
+		}
+		if record.Error != "" {
+			fmt.Fprintf(w, "\n\tfailed: %s", record.Error)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
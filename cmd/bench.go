@@ -0,0 +1,368 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// benchCmd represents the bench command
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measures the restore speed from the Kopia repository",
+	Long: `Measures the restore speed from the Kopia repository.
+
+Synthetic objects are written to the repository and read back
+sequentially and in parallel to measure the download throughput and
+the effect of the local cache. The hashing speed of the repository
+format is measured as well. The recommended parallelism and throttling
+limits for this machine and link are printed at the end.
+
+The synthetic objects are not part of any snapshot and are removed by
+the next full maintenance.`,
+	Args: cobra.NoArgs,
+	RunE: BenchRun,
+}
+
+// benchCompressionCmd represents the bench compression command
+var benchCompressionCmd = &cobra.Command{
+	Use:   "compression <dir>",
+	Short: "Compares the compressors on the files of an asset directory",
+	Long: `Compares the compressors on the files of an asset directory.
+
+Files are sampled evenly from the asset directory and compressed in
+memory with every compressor kopia offers, the level being part of its
+name like zstd-fastest or zstd-best-compression. The share of the bytes
+saved and the compression speed of every compressor are printed with the
+recommended compression of the .gasset file, which is the one saving the
+most among the reasonably fast ones, or none if the assets are
+compressed already. The repository is not read.`,
+	Args: cobra.ExactArgs(1),
+	RunE: BenchCompressionRun,
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.AddCommand(benchCompressionCmd)
+
+	benchCompressionCmd.Flags().Int("files", 32, "Number of files sampled")
+	benchCompressionCmd.Flags().Int64("sample-size", 4<<20, "Bytes read from the start of every sampled file")
+	benchCompressionCmd.Flags().Bool("json", false, "Prints the results as JSON")
+
+	benchCmd.Flags().Int("objects", 8, "Number of synthetic objects read in every pass")
+	benchCmd.Flags().Int("object-size", 4<<20, "Size in bytes of every synthetic object")
+	benchCmd.Flags().Int("max-parallelism", 8, "Highest number of parallel reads measured")
+	addTimeoutFlag(benchCmd)
+}
+
+func BenchRun(cmd *cobra.Command, _ []string) error {
+	log.Println("bench called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	objects, err := cmd.Flags().GetInt("objects")
+	if err != nil {
+		return err
+	}
+
+	objectSize, err := cmd.Flags().GetInt("object-size")
+	if err != nil {
+		return err
+	}
+
+	maxParallelism, err := cmd.Flags().GetInt("max-parallelism")
+	if err != nil {
+		return err
+	}
+
+	if objects < 1 || objectSize < 1 || maxParallelism < 1 {
+		return errors.New("objects, object size and max parallelism must be positive")
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return bench(ctx, options, objects, objectSize, maxParallelism, cmd.OutOrStdout())
+}
+
+func bench(ctx context.Context, op *util.Options, objects int, objectSize int, maxParallelism int, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	var parallelisms []int
+	for parallelism := 2; parallelism <= maxParallelism; parallelism *= 2 {
+		parallelisms = append(parallelisms, parallelism)
+	}
+
+	// Every pass reads its own objects so that the cache of a previous pass does not skew the result
+	objectSets, err := writeBenchObjects(ctx, op, rep, 1+len(parallelisms), objects, objectSize)
+	if err != nil {
+		return err
+	}
+
+	cold, err := readBenchObjects(ctx, rep, objectSets[0], 1)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Sequential read:      %s\n", util.FormatThroughput(cold.Throughput()))
+
+	warm, err := readBenchObjects(ctx, rep, objectSets[0], 1)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Sequential re-read:   %s (local cache effect)\n", util.FormatThroughput(warm.Throughput()))
+
+	results := []util.BenchResult{cold}
+	for i, parallelism := range parallelisms {
+		result, err := readBenchObjects(ctx, rep, objectSets[i+1], parallelism)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Parallel read (%d):    %s\n", parallelism, util.FormatThroughput(result.Throughput()))
+		results = append(results, result)
+	}
+
+	if directRep, ok := rep.(repo.DirectRepository); ok {
+		hashResult, err := benchHashing(directRep.FormatManager(), objects*objectSize)
+		if err != nil {
+			fmt.Fprintf(w, "Hashing:              not measured, %v\n", err)
+		} else {
+			fmt.Fprintf(w, "Hashing (%s): %s\n", directRep.FormatManager().GetHashFunction(), util.FormatThroughput(hashResult.Throughput()))
+		}
+	}
+
+	recommended := util.RecommendParallelism(results)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Recommended parallelism:          %d\n", recommended.Parallelism)
+	fmt.Fprintf(w, "Recommended throttlingLimits:\n")
+	fmt.Fprintf(w, "  concurrentReads:                %d\n", recommended.Parallelism)
+	fmt.Fprintf(w, "  maxDownloadSpeedBytesPerSecond: %.0f (%s, leaves part of the link free)\n",
+		util.RecommendDownloadLimit(recommended), util.FormatThroughput(util.RecommendDownloadLimit(recommended)))
+
+	return nil
+}
+
+// writeBenchObjects writes sets of objects with random content so that nothing is deduplicated
+func writeBenchObjects(ctx context.Context, op *util.Options, rep repo.Repository, sets int, objects int, objectSize int) ([][]object.ID, error) {
+	objectSets := make([][]object.ID, sets)
+
+	err := op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Benchmark restore speed"),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		data := make([]byte, objectSize)
+		for set := range objectSets {
+			for i := 0; i < objects; i++ {
+				if _, err := rand.Read(data); err != nil {
+					return err
+				}
+
+				objectWriter := writer.NewObjectWriter(ctx, object.WriterOptions{Description: "gasset bench"})
+				if _, err := objectWriter.Write(data); err != nil {
+					objectWriter.Close()
+					return err
+				}
+				oid, err := objectWriter.Result()
+				objectWriter.Close()
+				if err != nil {
+					return err
+				}
+				objectSets[set] = append(objectSets[set], oid)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objectSets, nil
+}
+
+func readBenchObjects(ctx context.Context, rep repo.Repository, oids []object.ID, parallelism int) (util.BenchResult, error) {
+	result := util.BenchResult{Parallelism: parallelism}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	queue := make(chan object.ID)
+
+	start := time.Now()
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for oid := range queue {
+				n, err := readBenchObject(ctx, rep, oid)
+
+				mu.Lock()
+				result.Bytes += n
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, oid := range oids {
+		queue <- oid
+	}
+	close(queue)
+	wg.Wait()
+	result.Duration = time.Since(start)
+
+	return result, errors.Join(errs...)
+}
+
+func readBenchObject(ctx context.Context, rep repo.Repository, oid object.ID) (int64, error) {
+	reader, err := rep.OpenObject(ctx, oid)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	return io.Copy(io.Discard, reader)
+}
+
+func benchHashing(fmgr *format.Manager, size int) (util.BenchResult, error) {
+	h, err := util.NewBenchHash(fmgr.GetHashFunction(), fmgr.GetHmacSecret())
+	if err != nil {
+		return util.BenchResult{}, err
+	}
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return util.BenchResult{}, err
+	}
+
+	start := time.Now()
+	h.Write(data)
+	h.Sum(nil)
+	return util.BenchResult{Parallelism: 1, Bytes: int64(size), Duration: time.Since(start)}, nil
+}
+
+func BenchCompressionRun(cmd *cobra.Command, args []string) error {
+	log.Println("bench compression called")
+
+	// The files are compressed in memory so the storage secrets are not needed
+	options := newOptions()
+	if err := options.InitWorkingDirectory(); err != nil {
+		return err
+	}
+	config, err := util.GetConfig(options.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	options.Config = config
+
+	files, err := cmd.Flags().GetInt("files")
+	if err != nil {
+		return err
+	}
+	sampleSize, err := cmd.Flags().GetInt64("sample-size")
+	if err != nil {
+		return err
+	}
+	if files < 1 || sampleSize < 1 {
+		return errors.New("files and sample size must be positive")
+	}
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	return benchCompression(&options, args[0], files, sampleSize, asJson, cmd.OutOrStdout())
+}
+
+// compressionBench is the JSON output of bench compression
+type compressionBench struct {
+	Dir         string                   `json:"dir"`
+	Samples     int                      `json:"samples"`
+	Results     []util.CompressionResult `json:"results"`
+	Recommended compression.Name         `json:"recommended"`
+}
+
+func benchCompression(op *util.Options, dir string, files int, sampleSize int64, asJson bool, w io.Writer) error {
+	index := slices.IndexFunc(op.Config.Dirs, func(configured string) bool {
+		return filepath.Clean(configured) == filepath.Clean(dir)
+	})
+	if index < 0 {
+		return fmt.Errorf("%s is not an asset directory of the .gasset file", dir)
+	}
+	dir = op.Config.Dirs[index]
+
+	samples, err := util.SampleFiles(filepath.Join(op.WorkingDirectory, dir), files, sampleSize)
+	if err != nil {
+		return err
+	}
+	results, err := util.BenchCompressors(samples)
+	if err != nil {
+		return err
+	}
+	recommended, err := util.RecommendCompression(results)
+	if err != nil {
+		return err
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(compressionBench{Dir: dir, Samples: len(samples), Results: results, Recommended: recommended})
+	}
+
+	fmt.Fprintf(w, "%-28s %8s %14s\n", "Compressor", "Saved", "Speed")
+	for _, result := range results {
+		fmt.Fprintf(w, "%-28s %7.1f%% %14s\n", result.Compressor, result.Savings()*100, util.FormatThroughput(result.Throughput()))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, util.T("Sampled %d files of %s", len(samples), dir))
+	if op.Config.Compression != "" {
+		fmt.Fprintf(w, "Current compression:     %s\n", op.Config.Compression)
+	}
+	fmt.Fprintf(w, "Recommended compression: %s, set \"compression\": %q in the .gasset file\n", recommended, recommended)
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type BenchSuite struct {
+	repoSuite
+}
+
+func TestBenchSuite(t *testing.T) {
+	suite.Run(t, new(BenchSuite))
+}
+
+func (suite *BenchSuite) Test_benchCompression() {
+	if err := os.WriteFile(filepath.Join(suite.options.WorkingDirectory, "assets", "level.json"), bytes.Repeat([]byte(`{"tile": 1}`), 2048), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+
+	tests := []struct {
+		name        string
+		dir         string
+		wantErr     assert.ErrorAssertionFunc
+		wantSamples int
+	}{
+		{
+			name:        "Recommend a compression for the samples of an asset directory",
+			dir:         "assets",
+			wantErr:     assert.NoError,
+			wantSamples: 2,
+		},
+		{
+			name:    "Fail on a directory which is not an asset directory",
+			dir:     "./sounds",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			var output bytes.Buffer
+			if !tt.wantErr(suite.T(), benchCompression(suite.options, tt.dir, 8, 1<<20, true, &output)) || tt.wantSamples == 0 {
+				return
+			}
+			var bench compressionBench
+			if !assert.NoError(suite.T(), json.Unmarshal(output.Bytes(), &bench)) {
+				return
+			}
+			assert.Equal(suite.T(), tt.wantSamples, bench.Samples)
+			assert.NotEmpty(suite.T(), bench.Results)
+			assert.NotEqual(suite.T(), "none", string(bench.Recommended))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manages the local Kopia cache",
+}
+
+// cacheVerifyCmd represents the cache verify command
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies the integrity of the local Kopia cache",
+	Long: `Verifies the integrity of the local Kopia cache.
+
+Every cached item is checked against its HMAC and corrupted items are
+evicted so that they are fetched again from the storage. With --interval
+the verification is repeated periodically until interrupted.`,
+	RunE: CacheVerifyRun,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheVerifyCmd)
+
+	cacheVerifyCmd.Flags().Bool("no-evict", false, "Only reports the corrupted items without evicting them")
+	cacheVerifyCmd.Flags().Duration("interval", 0, "Repeats the verification at the given interval, 0 runs it once")
+}
+
+func CacheVerifyRun(cmd *cobra.Command, _ []string) error {
+	log.Println("cache verify called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	noEvict, err := cmd.Flags().GetBool("no-evict")
+	if err != nil {
+		return err
+	}
+
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for {
+		if err := verifyCache(ctx, options, !noEvict, cmd.OutOrStdout()); err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func verifyCache(ctx context.Context, op *util.Options, evict bool, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	localConfig, err := repo.LoadConfigFromFile(kopiaUserConfigPath)
+	if err != nil {
+		return err
+	}
+	if localConfig.Caching == nil || localConfig.Caching.CacheDirectory == "" {
+		fmt.Fprintln(w, util.T("Local cache is disabled, nothing to verify"))
+		return nil
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	directRep, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return errors.New("local cache is not available for this connection")
+	}
+
+	hmacSecret, err := util.CacheHMACSecret(directRep.FormatManager())
+	if err != nil {
+		return err
+	}
+
+	result, err := util.VerifyCache(localConfig.Caching.CacheDirectory, hmacSecret, evict)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range result.Corrupted {
+		fmt.Fprintf(w, "corrupted: %s\n", path)
+	}
+	fmt.Fprintln(w, util.T("Checked %d cache items, %d corrupted, %d evicted", result.Checked, len(result.Corrupted), result.Evicted))
+	summary.Add("checked", result.Checked)
+	summary.Add("corrupted", len(result.Corrupted))
+	summary.Add("evicted", result.Evicted)
+
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// catCmd represents the cat command
+var catCmd = &cobra.Command{
+	Use:   "cat <path>",
+	Short: "Prints the content of an asset in a snapshot",
+	Long: `Prints the content of an asset in a snapshot.
+
+The asset is read from the latest snapshot of its directory, or from the
+given snapshot, so it does not need to exist on the local disk.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSnapshotPaths,
+	RunE:              CatRun,
+}
+
+func init() {
+	rootCmd.AddCommand(catCmd)
+
+	catCmd.Flags().String("snapshot", "", "Id of the snapshot to read instead of the latest one")
+}
+
+func CatRun(cmd *cobra.Command, args []string) error {
+	log.Println("cat called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	snapshotId, err := cmd.Flags().GetString("snapshot")
+	if err != nil {
+		return err
+	}
+
+	return catAsset(context.Background(), options, args[0], snapshotId, cmd.OutOrStdout())
+}
+
+func catAsset(ctx context.Context, op *util.Options, assetPath string, snapshotId string, w io.Writer) error {
+	dir, relativePath, err := op.AssetDir(assetPath)
+	if err != nil {
+		return err
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	var man *snapshot.Manifest
+	if snapshotId != "" {
+		man, err = snapshot.LoadSnapshot(ctx, rep, manifest.ID(snapshotId))
+		if err != nil {
+			return err
+		}
+	} else {
+		manifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return err
+		}
+		man = latestCompleteSnapshot(manifests)
+		if man == nil {
+			return fmt.Errorf("%s has no snapshots", dir)
+		}
+	}
+
+	entry, err := findSnapshotEntry(ctx, rep, man, relativePath)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("%s is not part of snapshot %s", assetPath, man.ID)
+	}
+
+	file, ok := snapshotfs.EntryFromDirEntry(rep, entry).(fs.File)
+	if !ok {
+		return fmt.Errorf("%s is not a file", assetPath)
+	}
+
+	reader, err := file.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(w, reader)
+	return err
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check [dir...]",
+	Short: "Verifies the working files against the hashes recorded on snap",
+	Long: `Verifies the working files against the hashes recorded on snap.
+
+With workingHashes in the .gasset file, snap records an xxhash or BLAKE3
+hash of every file of the snapshotted directories. check hashes the
+working files again and lists the ones which were added, removed,
+modified or moved since, without reading the repository, which is much
+faster than comparing them with the snapshots.
+
+The hashes are recorded locally and, with inLockFile, in the .gasset.lock
+file, which is used when nothing was recorded locally, e.g. in a fresh
+clone. With lockFilePerDir every asset directory has its own .gasset.lock
+file and all of them are read. It checks all the asset directories unless
+some are given.`,
+	RunE: CheckRun,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+func CheckRun(cmd *cobra.Command, args []string) error {
+	log.Println("check called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if options.Config.WorkingHashes == nil {
+		return errors.New("set workingHashes in the .gasset file and snap to record the hashes to check")
+	}
+
+	dirs := args
+	if len(dirs) == 0 {
+		dirs = options.Config.Dirs
+	}
+	return checkWorkingHashes(options, dirs, cmd.OutOrStdout())
+}
+
+// checkWorkingHashes prints the files of the directories which changed since their hashes were recorded
+func checkWorkingHashes(op *util.Options, dirs []string, w io.Writer) error {
+	recorded, err := op.LoadWorkingHashes()
+	if err != nil {
+		return err
+	}
+	lock, err := util.LoadLockFiles(op.WorkingDirectory, op.Config.Dirs)
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, dirPath := range dirs {
+		changes, ok, err := workingHashChanges(op, recorded, lock, dirPath)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Printf("Warning: no hashes are recorded for %s, snap it first", dirPath)
+			continue
+		}
+
+		for _, change := range changes {
+			changed++
+			if change.Kind == util.ChangeMoved {
+				fmt.Fprintf(w, "%s\t%s -> %s\n", change.Kind, change.From, change.Path)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\n", change.Kind, change.Path)
+			}
+		}
+	}
+
+	summary.Add("changed", changed)
+	if changed > 0 {
+		return fmt.Errorf("%d files changed since their hashes were recorded", changed)
+	}
+	fmt.Fprintln(w, util.T("All files match their recorded hashes"))
+	return nil
+}
+
+// workingHashChanges hashes the working files of a directory and compares them with the recorded hashes,
+// falling back to the ones in the lock file. It returns false if no hashes are recorded for the directory.
+func workingHashChanges(op *util.Options, recorded *util.WorkingHashes, lock *util.LockFile, dirPath string) ([]util.Change, bool, error) {
+	before := recorded.DirHashes(dirPath)
+	if len(before) == 0 && lock.WorkingHashes != nil && lock.WorkingHashes.Algorithm == recorded.Algorithm {
+		before = lock.WorkingHashes.DirHashes(dirPath)
+	}
+	if len(before) == 0 {
+		return nil, false, nil
+	}
+
+	current, err := util.HashWorkingFiles(op.WorkingDirectory, dirPath, recorded.Algorithm, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	after := make(map[string]string, len(current))
+	for filePath, fileHash := range current {
+		after[filePath] = fileHash.Hash
+	}
+	summary.Add("checked", len(after))
+
+	return util.DiffFiles(before, after), true, nil
+}
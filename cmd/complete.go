@@ -0,0 +1,137 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"log"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// completeSnapshotPaths completes the paths in the latest snapshots, including files missing on the local disk
+func completeSnapshotPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// Fall back to the completion of local files if the snapshots can't be listed
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	listing, err := snapshotListing(context.Background(), options)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	// Directories end with a slash so that the completion can continue inside them
+	return listing.Complete(toComplete), cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// snapshotListing returns the cached listing of the latest snapshots, refreshing it once it is stale.
+// Snapshots are immutable so only the directories with a new snapshot are listed again.
+func snapshotListing(ctx context.Context, op *util.Options) (*util.SnapshotListing, error) {
+	listing, err := op.LoadListingCache()
+	if err != nil {
+		return nil, err
+	}
+	if listing.IsFresh(time.Now()) {
+		return listing, nil
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Close(ctx)
+
+	dirs := make(map[string]util.DirListing, len(op.Config.Dirs))
+	for _, dir := range op.Config.Dirs {
+		manifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return nil, err
+		}
+		latest := latestCompleteSnapshot(manifests)
+		if latest == nil {
+			continue
+		}
+		if cached, ok := listing.Dirs[dir]; ok && cached.Snapshot == latest.ID {
+			dirs[dir] = cached
+			continue
+		}
+
+		root, err := snapshotfs.SnapshotRoot(rep, latest)
+		if err != nil {
+			return nil, err
+		}
+		rootDir, ok := root.(fs.Directory)
+		if !ok {
+			continue
+		}
+
+		dirPath := path.Clean(filepath.ToSlash(dir)) + "/"
+		paths, err := listSnapshotPaths(ctx, rootDir, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		dirs[dir] = util.DirListing{Snapshot: latest.ID, Paths: append([]string{dirPath}, paths...)}
+	}
+
+	listing.Dirs = dirs
+	listing.UpdatedAt = time.Now().UTC()
+	if err := op.SaveListingCache(listing); err != nil {
+		log.Printf("Could not cache the snapshot listing: %v", err)
+	}
+	return listing, nil
+}
+
+// listSnapshotPaths returns the paths of all the entries in a snapshot directory, directories end with a slash
+func listSnapshotPaths(ctx context.Context, dir fs.Directory, dirPath string) ([]string, error) {
+	var paths []string
+
+	err := fs.IterateEntries(ctx, dir, func(ctx context.Context, entry fs.Entry) error {
+		entryPath := dirPath + entry.Name()
+		childDir, ok := entry.(fs.Directory)
+		if !ok {
+			paths = append(paths, entryPath)
+			return nil
+		}
+
+		paths = append(paths, entryPath+"/")
+		childPaths, err := listSnapshotPaths(ctx, childDir, entryPath+"/")
+		if err != nil {
+			return err
+		}
+		paths = append(paths, childPaths...)
+		return nil
+	})
+
+	return paths, err
+}
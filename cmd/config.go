@@ -0,0 +1,169 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Works with the .gasset config file",
+}
+
+// configSchemaCmd represents the config schema command
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the JSON Schema of the .gasset file",
+	Long: `Prints the JSON Schema of the .gasset file.
+
+The schema includes the subset of the kopia config supported by gasset
+and can be used by editors to validate and complete the file.`,
+	Args: cobra.NoArgs,
+	RunE: ConfigSchemaRun,
+}
+
+// configLintCmd represents the config lint command
+var configLintCmd = &cobra.Command{
+	Use:   "lint [file]",
+	Short: "Validates a .gasset file against its schema",
+	Long: `Validates a .gasset file against its schema.
+
+Every problem is reported with its line, column and field. If no file is
+given, the .gasset file of the current git repository is validated.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: ConfigLintRun,
+}
+
+// configDriftCmd represents the config drift command
+var configDriftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Checks if the connected Kopia config differs from the .gasset file",
+	Long: `Checks if the connected Kopia config differs from the .gasset file.
+
+The kopia section of the .gasset file is compared with the config the
+repository was connected with, ignoring credentials and caching. If the
+.gasset file changed upstream, e.g. a new endpoint, the differences are
+reported and init has to be run again to reconnect. Nothing is written.`,
+	Args: cobra.NoArgs,
+	RunE: ConfigDriftRun,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	configCmd.AddCommand(configLintCmd)
+	configCmd.AddCommand(configDriftCmd)
+}
+
+func ConfigSchemaRun(cmd *cobra.Command, _ []string) error {
+	schemaBytes, err := json.MarshalIndent(util.ConfigSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(schemaBytes))
+	return err
+}
+
+func ConfigLintRun(cmd *cobra.Command, args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		options := newOptions()
+		if err := options.InitWorkingDirectory(); err != nil {
+			return err
+		}
+		var err error
+		if path, err = util.FindConfigFile(options.WorkingDirectory); err != nil {
+			return err
+		}
+	}
+
+	return lintConfig(path, cmd.OutOrStdout())
+}
+
+func lintConfig(path string, w io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lintErrors, err := util.LintConfig(data, util.DetectConfigFormat(path, data))
+	if err != nil {
+		return err
+	}
+
+	for _, lintError := range lintErrors {
+		fmt.Fprintf(w, "%s:%s\n", path, lintError.Error())
+	}
+	if len(lintErrors) > 0 {
+		return fmt.Errorf("found %d problems in %s", len(lintErrors), path)
+	}
+
+	fmt.Fprintln(w, util.T("%s is valid", path))
+	return nil
+}
+
+func ConfigDriftRun(cmd *cobra.Command, _ []string) error {
+	log.Println("config drift called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	differences, err := options.ConfigDrift()
+	if err != nil {
+		return err
+	}
+
+	for _, difference := range differences {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: .gasset has %v, connected with %v\n", difference.Field, difference.Committed, difference.Connected)
+	}
+	if len(differences) > 0 {
+		return errors.New("the connected kopia config differs from the .gasset file, run init to reconnect")
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), util.T("The connected kopia config matches the .gasset file"))
+	return nil
+}
+
+// warnConfigDrift logs the differences between the connected kopia config and the .gasset file
+func warnConfigDrift(op *util.Options) {
+	differences, err := op.ConfigDrift()
+	if err != nil {
+		log.Printf("Could not compare the connected kopia config with the .gasset file: %v", err)
+		return
+	}
+
+	for _, difference := range differences {
+		log.Printf("Warning: %s in .gasset is %v but the repository is connected with %v", difference.Field, difference.Committed, difference.Connected)
+	}
+	if len(differences) > 0 {
+		log.Println("Warning: run init to reconnect with the current .gasset file")
+	}
+}
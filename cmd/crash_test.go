@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type CrashSuite struct {
+	repoSuite
+}
+
+func TestCrashSuite(t *testing.T) {
+	suite.Run(t, new(CrashSuite))
+}
+
+func (suite *CrashSuite) Test_recordCrash() {
+	var submitted util.CrashDump
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&submitted); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	dump := util.NewCrashDump("snap", nil, "boom", []byte("goroutine 1 [running]:"), suite.options.Config, time.Now())
+	recordCrash(context.Background(), suite.options, dump, server.URL)
+	assert.Equal(suite.T(), "boom", submitted.Panic)
+
+	crashDir, err := suite.options.GetCrashDir()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	entries, err := os.ReadDir(crashDir)
+	if assert.NoError(suite.T(), err) {
+		assert.Len(suite.T(), entries, 1)
+	}
+}
+
+func (suite *CrashSuite) Test_recordCrash_configHome() {
+	tests := []struct {
+		name  string
+		env   bool
+		local bool
+	}{
+		{
+			name: "Write the dump under the config home of the environment variable",
+			env:  true,
+		},
+		{
+			name:  "Write the dump under the config home of the .gasset.local file",
+			local: true,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			workingDirectory := suite.T().TempDir()
+			if err := os.Mkdir(filepath.Join(workingDirectory, ".git"), 0o755); err != nil {
+				suite.T().FailNow()
+			}
+			home := filepath.Join(workingDirectory, ".gasset-home")
+			suite.T().Setenv(util.EnvConfigHome, "")
+			if tt.env {
+				suite.T().Setenv(util.EnvConfigHome, home)
+			}
+			if tt.local {
+				if err := os.WriteFile(filepath.Join(workingDirectory, util.LocalConfigFileName), []byte(`{"configHome": ".gasset-home"}`), 0o600); err != nil {
+					suite.T().FailNow()
+				}
+			}
+
+			op := newOptions()
+			op.OsGetwd = func() (string, error) {
+				return workingDirectory, nil
+			}
+			osConfigDir := suite.T().TempDir()
+			op.OsUserConfigDir = func() (string, error) {
+				return osConfigDir, nil
+			}
+			config := loadCrashOptions(&op)
+			recordCrash(context.Background(), &op, util.NewCrashDump("snap", nil, "boom", []byte("goroutine 1 [running]:"), config, time.Now()), "")
+
+			entries, err := os.ReadDir(filepath.Join(home, "git-gasset", "crashes"))
+			if assert.NoError(suite.T(), err) {
+				assert.Len(suite.T(), entries, 1)
+			}
+			_, err = os.Stat(filepath.Join(osConfigDir, "git-gasset", "crashes"))
+			assert.True(suite.T(), os.IsNotExist(err), "the user config directory of the os is left alone")
+		})
+	}
+}
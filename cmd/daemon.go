@@ -0,0 +1,39 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"git-gasset/util"
+	"log"
+	"net/http"
+)
+
+// runSupervised runs the worker of a long-running mode under a supervisor which restarts it when it crashes.
+// With listen set, the health of the worker is served on that address next to the other endpoints of the mode.
+func runSupervised(ctx context.Context, listen string, name string, endpoints map[string]http.Handler, worker func(ctx context.Context) error) error {
+	supervisor := util.NewSupervisor()
+	if listen != "" {
+		go func() {
+			if err := util.ServeHealth(ctx, listen, supervisor, endpoints); err != nil {
+				log.Printf("Warning: could not serve on %s: %v", listen, err)
+			}
+		}()
+	}
+
+	return supervisor.Run(ctx, name, worker)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type DaemonSuite struct {
+	suite.Suite
+}
+
+func TestDaemonSuite(t *testing.T) {
+	suite.Run(t, new(DaemonSuite))
+}
+
+func (suite *DaemonSuite) Test_runSupervised() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		suite.T().FailNow()
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	crashed := false
+	done := make(chan error, 1)
+	go func() {
+		endpoints := map[string]http.Handler{"/metrics": http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			io.WriteString(w, "metrics")
+		})}
+		done <- runSupervised(ctx, addr, "worker", endpoints, func(ctx context.Context) error {
+			if !crashed {
+				crashed = true
+				panic("crashed")
+			}
+			<-ctx.Done()
+			return nil
+		})
+	}()
+
+	// The worker is reported healthy again once it is restarted after the panic
+	assert.Eventually(suite.T(), func() bool {
+		resp, err := http.Get("http://" + addr + util.HealthPath)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+
+		var health struct {
+			Workers []util.WorkerHealth `json:"workers"`
+		}
+		if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&health) != nil {
+			return false
+		}
+		return len(health.Workers) == 1 && health.Workers[0].Running && health.Workers[0].Restarts == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if assert.NoError(suite.T(), err) {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "metrics", string(body))
+	}
+
+	cancel()
+	assert.NoError(suite.T(), <-done)
+}
@@ -0,0 +1,214 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+)
+
+// changedExitCode is the exit code of diff --exit-code when files changed
+const changedExitCode = 1
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <snapshot-id> [snapshot-id]",
+	Short: "Shows the files changed between two snapshots or a snapshot and the working files",
+	Long: `Shows the files changed between two snapshots or a snapshot and the working files.
+
+The files are listed as added, removed, modified or moved with the change
+of their size. Moves are only detected between snapshots, where the
+content of the files is known. Without a second snapshot the working files
+of the asset directory of the snapshot are compared with it like status
+compares them, without uploading anything. With --json the changes are
+printed for pipelines, and --exit-code exits with 1 if any file changed.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: DiffRun,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().Bool("json", false, "Prints the changes as JSON")
+	diffCmd.Flags().Bool("exit-code", false, "Exits with 1 if any file changed")
+	addTimeoutFlag(diffCmd)
+}
+
+func DiffRun(cmd *cobra.Command, args []string) error {
+	log.Println("diff called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	exitCode, err := cmd.Flags().GetBool("exit-code")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var toId string
+	if len(args) > 1 {
+		toId = args[1]
+	}
+	diff, err := diffSnapshots(ctx, options, args[0], toId)
+	if err != nil {
+		return err
+	}
+	if err := printSnapshotDiff(diff, asJson, cmd.OutOrStdout()); err != nil {
+		return err
+	}
+
+	if exitCode && len(diff.Changes) > 0 {
+		return newExitCodeError(cmd, changedExitCode, fmt.Errorf("%d files changed", len(diff.Changes)))
+	}
+	return nil
+}
+
+// snapshotDiff is the files changed from a snapshot to another one or to the working files
+type snapshotDiff struct {
+	From manifest.ID `json:"from"`
+	// To is empty when the snapshot is compared with the working files
+	To      manifest.ID        `json:"to,omitempty"`
+	Changes []util.SizedChange `json:"changes"`
+}
+
+func diffSnapshots(ctx context.Context, op *util.Options, fromId string, toId string) (*snapshotDiff, error) {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Close(ctx)
+
+	from, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(fromId))
+	if err != nil {
+		return nil, err
+	}
+	diff := &snapshotDiff{From: from.ID}
+
+	if toId == "" {
+		dir, err := snapshotAssetDir(op, from)
+		if err != nil {
+			return nil, err
+		}
+		var recorded *util.WorkingHashes
+		if op.Config.WorkingHashes != nil {
+			if recorded, err = op.LoadWorkingHashes(); err != nil {
+				return nil, err
+			}
+		}
+		changes, snapshotFiles, workingFiles, err := workingChanges(ctx, op, rep, recorded, dir, from)
+		if err != nil {
+			return nil, err
+		}
+		diff.Changes = util.AddSizes(changes, snapshotFiles, workingFiles)
+		return diff, nil
+	}
+
+	to, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(toId))
+	if err != nil {
+		return nil, err
+	}
+	diff.To = to.ID
+
+	beforeIds, before, err := snapshotFileStates(ctx, op, rep, from)
+	if err != nil {
+		return nil, err
+	}
+	afterIds, after, err := snapshotFileStates(ctx, op, rep, to)
+	if err != nil {
+		return nil, err
+	}
+	diff.Changes = util.AddSizes(util.DiffFiles(beforeIds, afterIds), before, after)
+	return diff, nil
+}
+
+// snapshotFileStates returns the object ids and states of the files of a snapshot by their path relative to the
+// working directory, or to the snapshot root if it is not of an asset directory
+func snapshotFileStates(ctx context.Context, op *util.Options, rep repo.Repository, man *snapshot.Manifest) (map[string]string, map[string]util.FileState, error) {
+	var dirPath string
+	if dir, err := snapshotAssetDir(op, man); err == nil {
+		dirPath = path.Clean(filepath.ToSlash(dir)) + "/"
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	if err != nil {
+		return nil, nil, err
+	}
+	rootDir, ok := root.(fs.Directory)
+	if !ok {
+		return nil, nil, fmt.Errorf("snapshot %s is not of a directory", man.ID)
+	}
+	return util.SnapshotFileStates(ctx, rootDir, dirPath)
+}
+
+func printSnapshotDiff(diff *snapshotDiff, asJson bool, w io.Writer) error {
+	summary.Add("changed", len(diff.Changes))
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+
+	var total int64
+	for _, change := range diff.Changes {
+		if change.Kind == util.ChangeMoved {
+			fmt.Fprintf(w, "%s\t%s -> %s\t%s\n", change.Kind, change.From, change.Path, formatSizeDelta(change.SizeDelta))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", change.Kind, change.Path, formatSizeDelta(change.SizeDelta))
+		}
+		total += change.SizeDelta
+	}
+	fmt.Fprintln(w, util.T("%d files changed, %s in size", len(diff.Changes), formatSizeDelta(total)))
+	return nil
+}
+
+// formatSizeDelta formats a change of size with its sign, e.g. +1.5 KiB
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + util.FormatBytes(-delta)
+	}
+	return "+" + util.FormatBytes(delta)
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type DiffSuite struct {
+	repoSuite
+}
+
+func TestDiffSuite(t *testing.T) {
+	suite.Run(t, new(DiffSuite))
+}
+
+func (suite *DiffSuite) Test_diffSnapshots() {
+	ctx := context.Background()
+	assetsDir := filepath.Join(suite.options.WorkingDirectory, "assets")
+	snap := func() string {
+		record := suite.options.NewAuditRecord("snap", nil)
+		if _, err := createSnapshot(ctx, suite.options, record, snapSettings{}); err != nil || len(record.Manifests) != 1 {
+			suite.T().FailNow()
+		}
+		return string(record.Manifests[0])
+	}
+	from := snap()
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		removed     []string
+		snap        bool
+		wantChanges []util.SizedChange
+		wantPrinted string
+	}{
+		{
+			name:  "Diff two snapshots",
+			files: map[string]string{"a.txt": "aaa", "b.txt": "bb"},
+			snap:  true,
+			wantChanges: []util.SizedChange{
+				{Change: util.Change{Kind: util.ChangeModified, Path: "assets/a.txt"}, SizeBefore: 1, SizeAfter: 3, SizeDelta: 2},
+				{Change: util.Change{Kind: util.ChangeAdded, Path: "assets/b.txt"}, SizeAfter: 2, SizeDelta: 2},
+			},
+			wantPrinted: "modified\tassets/a.txt\t+2.0 B\nadded\tassets/b.txt\t+2.0 B\n2 files changed, +4.0 B in size\n",
+		},
+		{
+			name:    "Diff a snapshot and the working files",
+			removed: []string{"a.txt"},
+			wantChanges: []util.SizedChange{
+				{Change: util.Change{Kind: util.ChangeRemoved, Path: "assets/a.txt"}, SizeBefore: 3, SizeDelta: -3},
+			},
+			wantPrinted: "removed\tassets/a.txt\t-3.0 B\n1 files changed, -3.0 B in size\n",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(assetsDir, name), []byte(content), 0o644); err != nil {
+					suite.T().FailNow()
+				}
+			}
+			for _, name := range tt.removed {
+				if err := os.Remove(filepath.Join(assetsDir, name)); err != nil {
+					suite.T().FailNow()
+				}
+			}
+			to := ""
+			if tt.snap {
+				to = snap()
+			}
+
+			diff, err := diffSnapshots(ctx, suite.options, from, to)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+			assert.Equal(suite.T(), to, string(diff.To))
+			assert.Equal(suite.T(), tt.wantChanges, diff.Changes)
+
+			w := &bytes.Buffer{}
+			assert.NoError(suite.T(), printSnapshotDiff(diff, false, w))
+			assert.Equal(suite.T(), tt.wantPrinted, w.String())
+			if to != "" {
+				from = to
+			}
+		})
+	}
+}
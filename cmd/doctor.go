@@ -0,0 +1,142 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"slices"
+	"strings"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks that the credentials permit the gasset operations",
+	Long: `Checks that the credentials permit the gasset operations.
+
+The credentials of the storage are often limited to what a user needs,
+e.g. keys which can't delete. doctor probes which operations on the
+blobs they actually permit, listing, reading, writing and deleting a
+probe blob, and prints them as a matrix against the permissions the gasset
+operations need. It fails if one of the operations can't succeed, before
+it is run and fails halfway, e.g. a prune whose keys can't delete.
+
+All the operations are checked unless some are given with --operations.`,
+	Args: cobra.NoArgs,
+	RunE: DoctorRun,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	addTimeoutFlag(doctorCmd)
+
+	doctorCmd.Flags().StringSlice("operations", nil, "The operations to check ("+strings.Join(util.OperationNames(), ", ")+")")
+}
+
+func DoctorRun(cmd *cobra.Command, _ []string) error {
+	log.Println("doctor called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	operations, err := cmd.Flags().GetStringSlice("operations")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return checkCredentials(ctx, options, operations, cmd.OutOrStdout())
+}
+
+// checkCredentials prints the permissions of the credentials and which of the operations they are enough for
+func checkCredentials(ctx context.Context, op *util.Options, operations []string, w io.Writer) error {
+	if len(operations) == 0 {
+		operations = util.OperationNames()
+	}
+	for _, operation := range operations {
+		if _, ok := util.OperationPermissions[operation]; !ok {
+			return fmt.Errorf("unknown operation %s, expected one of %s", operation, strings.Join(util.OperationNames(), ", "))
+		}
+	}
+
+	st, err := openStorage(ctx, op, false)
+	if err != nil {
+		return err
+	}
+	defer st.Close(ctx)
+
+	probed := util.ProbeStoragePermissions(ctx, st, util.GenerateRandomString(op.GassetIdLength, op.RandIntn))
+	for _, permission := range util.StoragePermissions {
+		if err := probed[permission]; err != nil {
+			fmt.Fprintf(w, "%s\tdenied\t%v\n", permission, err)
+		} else {
+			fmt.Fprintf(w, "%s\tallowed\n", permission)
+		}
+	}
+	fmt.Fprintln(w)
+
+	header := []string{"operation"}
+	for _, permission := range util.StoragePermissions {
+		header = append(header, string(permission))
+	}
+	fmt.Fprintln(w, strings.Join(append(header, "status"), "\t"))
+
+	var failing []string
+	for _, operation := range operations {
+		row := []string{operation}
+		for _, permission := range util.StoragePermissions {
+			switch {
+			case !slices.Contains(util.OperationPermissions[operation], permission):
+				row = append(row, "-")
+			case probed[permission] != nil:
+				row = append(row, "no")
+			default:
+				row = append(row, "yes")
+			}
+		}
+
+		status := "ok"
+		if missing := util.MissingPermissions(operation, probed); len(missing) > 0 {
+			failing = append(failing, operation)
+			names := make([]string, 0, len(missing))
+			for _, permission := range missing {
+				names = append(names, string(permission))
+			}
+			status = "missing " + strings.Join(names, ", ")
+		}
+		fmt.Fprintln(w, strings.Join(append(row, status), "\t"))
+	}
+	summary.Add("failing operations", len(failing))
+
+	if len(failing) > 0 {
+		return fmt.Errorf("the credentials do not permit %s", strings.Join(failing, ", "))
+	}
+	fmt.Fprintln(w, util.T("The credentials permit every checked operation"))
+	return nil
+}
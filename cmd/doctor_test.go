@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type DoctorSuite struct {
+	repoSuite
+}
+
+func TestDoctorSuite(t *testing.T) {
+	suite.Run(t, new(DoctorSuite))
+}
+
+func (suite *DoctorSuite) Test_checkCredentials() {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		operations []string
+		wantErr    assert.ErrorAssertionFunc
+		wantLines  []string
+	}{
+		{
+			name:       "Check the permissions of the operations",
+			operations: []string{"snap", "prune"},
+			wantErr:    assert.NoError,
+			wantLines:  []string{"delete\tallowed\n", "snap\tyes\tyes\tyes\t-\tok\n", "prune\tyes\tyes\tyes\tyes\tok\n"},
+		},
+		{
+			name:       "Fail on an unknown operation",
+			operations: []string{"backup"},
+			wantErr:    assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			w := &bytes.Buffer{}
+			tt.wantErr(suite.T(), checkCredentials(ctx, suite.options, tt.operations, w))
+			for _, line := range tt.wantLines {
+				assert.Contains(suite.T(), w.String(), line)
+			}
+		})
+	}
+}
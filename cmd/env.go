@@ -0,0 +1,140 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Prints the environment variables required by the .gasset config",
+	Long: `Prints the environment variables required by the .gasset config.
+
+The variables depend on the storage configured in the .gasset file. With
+--write a template .env file is created in the root of the git repository
+and with --check the command fails if any of the variables is neither set
+in the environment nor in the .env file. The password stored in the keyring
+with password store counts as set.`,
+	Args: cobra.NoArgs,
+	RunE: EnvRun,
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+
+	envCmd.Flags().Bool("write", false, "Writes a template .env file if one does not exist")
+	envCmd.Flags().Bool("check", false, "Fails if any of the required variables is missing")
+	envCmd.MarkFlagsMutuallyExclusive("write", "check")
+}
+
+func EnvRun(cmd *cobra.Command, _ []string) error {
+	log.Println("env called")
+
+	write, err := cmd.Flags().GetBool("write")
+	if err != nil {
+		return err
+	}
+
+	check, err := cmd.Flags().GetBool("check")
+	if err != nil {
+		return err
+	}
+
+	// The .env file may not exist yet so the secrets are not loaded here
+	options := newOptions()
+	if err := options.InitWorkingDirectory(); err != nil {
+		return err
+	}
+
+	config, err := util.GetConfig(options.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	envVars := util.RequiredEnvVars(config)
+
+	switch {
+	case write:
+		return writeEnvTemplate(options.WorkingDirectory, envVars, cmd.OutOrStdout())
+	case check:
+		options.Config = config
+		return checkEnv(options.WorkingDirectory, withoutKeyringPassword(&options, envVars), cmd.OutOrStdout())
+	default:
+		for _, envVar := range envVars {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", envVar.Name, envVar.Description)
+		}
+		return nil
+	}
+}
+
+func writeEnvTemplate(path string, envVars []util.EnvVar, w io.Writer) error {
+	envPath := filepath.Join(path, ".env")
+
+	file, err := os.OpenFile(envPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s already exists", envPath)
+		}
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.WriteString(file, util.EnvTemplate(envVars)); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, util.T("Wrote %s, fill in the values before connecting", envPath))
+	return nil
+}
+
+// withoutKeyringPassword leaves the password out of the variables if it is stored in the keyring, which the
+// commands and the non-interactive mode read it from instead of the .env file
+func withoutKeyringPassword(op *util.Options, envVars []util.EnvVar) []util.EnvVar {
+	if op.KeyringPassword() == "" {
+		return envVars
+	}
+	return slices.DeleteFunc(slices.Clone(envVars), func(envVar util.EnvVar) bool {
+		return envVar.Name == util.EnvPassword
+	})
+}
+
+func checkEnv(path string, envVars []util.EnvVar, w io.Writer) error {
+	missing, err := util.MissingEnvVars(path, envVars)
+	if err != nil {
+		return err
+	}
+
+	for _, envVar := range missing {
+		fmt.Fprintf(w, "missing: %s\t%s\n", envVar.Name, envVar.Description)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d required environment variables are missing", len(missing))
+	}
+
+	fmt.Fprintln(w, util.T("All required environment variables are set"))
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"testing"
+)
+
+type EnvSuite struct {
+	repoSuite
+}
+
+func TestEnvSuite(t *testing.T) {
+	suite.Run(t, new(EnvSuite))
+}
+
+func (suite *EnvSuite) Test_checkEnv_keyring() {
+	tests := []struct {
+		name       string
+		keyringGet func(service string, user string) (string, error)
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Accept the password stored in the keyring",
+			keyringGet: func(service string, user string) (string, error) {
+				return "password", nil
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Fail without the password in the keyring or the .env file",
+			keyringGet: func(service string, user string) (string, error) {
+				return "", errors.New("the secret service is not running")
+			},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.T().Setenv(util.EnvPassword, "")
+			op := suite.options.Clone()
+			op.KeyringGet = tt.keyringGet
+			envVars := []util.EnvVar{{Name: util.EnvPassword, Description: "Password of the kopia repository"}}
+			tt.wantErr(suite.T(), checkEnv(suite.T().TempDir(), withoutKeyringPassword(op, envVars), io.Discard))
+		})
+	}
+}
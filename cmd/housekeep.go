@@ -0,0 +1,165 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// housekeepCmd represents the housekeep command
+var housekeepCmd = &cobra.Command{
+	Use:   "housekeep",
+	Short: "Finds the kopia configs and caches of gasset ids no local checkout uses anymore",
+	Long: `Finds the kopia configs and caches of gasset ids no local checkout uses anymore.
+
+Every command registers the checkout it runs in with its gasset id in the user
+config directory. The configs, caches and local state of the gasset ids whose
+registered checkouts were all deleted or switched to another gasset id are
+listed with their size, and deleted after a confirmation with --delete. Gasset
+ids which were never registered, e.g. because they were last used by an older
+version of gasset, are only listed and deleted with --include-unknown. Only the
+local data is deleted, the repositories in the storage are left untouched.`,
+	Args: cobra.NoArgs,
+	RunE: HousekeepRun,
+}
+
+func init() {
+	rootCmd.AddCommand(housekeepCmd)
+
+	housekeepCmd.Flags().Bool("delete", false, "Deletes the orphaned configs and caches after a confirmation")
+	housekeepCmd.Flags().Bool("include-unknown", false, "Includes the gasset ids which were never registered by a checkout")
+	housekeepCmd.Flags().Bool("yes", false, "Skips the confirmation of --delete")
+	housekeepCmd.Flags().Bool("json", false, "Prints the orphaned items as JSON")
+	housekeepCmd.MarkFlagsMutuallyExclusive("delete", "json")
+}
+
+func HousekeepRun(cmd *cobra.Command, _ []string) error {
+	log.Println("housekeep called")
+
+	deleteItems, err := cmd.Flags().GetBool("delete")
+	if err != nil {
+		return err
+	}
+	includeUnknown, err := cmd.Flags().GetBool("include-unknown")
+	if err != nil {
+		return err
+	}
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	options, err := localStateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	items, err := orphanedItems(options, includeUnknown)
+	if err != nil {
+		return err
+	}
+	if asJson {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+	}
+	printOrphanedItems(items, cmd.OutOrStdout())
+
+	if !deleteItems || len(items) == 0 {
+		return nil
+	}
+	question := util.T("Delete %d orphaned items, %s in total?", len(items), util.FormatBytes(orphanedSize(items)))
+	if !yes {
+		if err := checkInteractive(cmd, "the confirmation", "pass --yes"); err != nil {
+			return err
+		}
+	}
+	if err := util.ConfirmLocal(cmd.InOrStdin(), cmd.ErrOrStderr(), question, yes); err != nil {
+		return err
+	}
+	if err := options.RemoveOrphanedItems(items); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), util.T("Deleted %d orphaned items, reclaimed %s", len(items), util.FormatBytes(orphanedSize(items))))
+	summary.Add("deleted", len(items))
+	return nil
+}
+
+// localStateOptions creates the options of the commands managing the local state of all the gasset ids.
+// They work outside of a checkout as well, only the config home of the checkout they run in is applied.
+func localStateOptions(cmd *cobra.Command) (*util.Options, error) {
+	options := newOptions()
+	if err := options.InitWorkingDirectory(); err != nil {
+		log.Printf("Not in a git repository, only %s can move the config home: %v", util.EnvConfigHome, err)
+		if err := options.ApplyConfigHome(); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	options.LocalTime, err = cmd.Flags().GetBool("local-time")
+	if err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// orphanedItems returns the orphaned items, without the ones of unregistered gasset ids unless includeUnknown is set
+func orphanedItems(op *util.Options, includeUnknown bool) ([]util.OrphanedItem, error) {
+	items, err := op.FindOrphanedItems()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []util.OrphanedItem
+	unknown := 0
+	for _, item := range items {
+		if item.Unknown && !includeUnknown {
+			unknown++
+			continue
+		}
+		result = append(result, item)
+	}
+	if unknown > 0 {
+		log.Printf("Skipped %d items of gasset ids never registered by a checkout, pass --include-unknown to include them", unknown)
+	}
+	return result, nil
+}
+
+func printOrphanedItems(items []util.OrphanedItem, w io.Writer) {
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.GassetId, util.FormatBytes(item.Size), item.Path)
+	}
+	fmt.Fprintln(w, util.T("%d orphaned items, %s in total", len(items), util.FormatBytes(orphanedSize(items))))
+}
+
+func orphanedSize(items []util.OrphanedItem) int64 {
+	var size int64
+	for _, item := range items {
+		size += item.Size
+	}
+	return size
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Shows information about the connected Kopia repository",
+	Long: `Shows information about the connected Kopia repository.
+
+Prints the gasset id, the storage and client details and the format
+of the repository including the error correction settings and their
+storage overhead. The fingerprint is the one recorded by init on the
+first connect and checked on the later ones. The retention tells whether
+the repository is archival, keeping every snapshot.`,
+	RunE: InfoRun,
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
+
+func InfoRun(cmd *cobra.Command, _ []string) error {
+	log.Println("info called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	return showInfo(context.Background(), options, cmd.OutOrStdout())
+}
+
+func showInfo(ctx context.Context, op *util.Options, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	directRep, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return errors.New("repository format is not available for this connection")
+	}
+
+	fmgr := directRep.FormatManager()
+	clientOptions := rep.ClientOptions()
+
+	fmt.Fprintf(w, "Gasset ID:    %s\n", op.Config.GassetId)
+	fmt.Fprintf(w, "Config file:  %s\n", kopiaUserConfigPath)
+	fmt.Fprintf(w, "Storage type: %s\n", op.Config.Kopia.Storage.Type)
+	fmt.Fprintf(w, "Hostname:     %s\n", clientOptions.Hostname)
+	fmt.Fprintf(w, "Username:     %s\n", clientOptions.Username)
+	if op.MachineIdentity != "" {
+		fmt.Fprintf(w, "Machine ID:   %s\n", op.MachineIdentity)
+	}
+	fmt.Fprintf(w, "Unique ID:    %x\n", directRep.UniqueID())
+	fmt.Fprintf(w, "Fingerprint:  %s\n", util.RepositoryFingerprint(directRep))
+	fmt.Fprintf(w, "Hash:         %s\n", fmgr.GetHashFunction())
+	fmt.Fprintf(w, "Encryption:   %s\n", fmgr.GetEncryptionAlgorithm())
+	fmt.Fprintf(w, "Splitter:     %s\n", fmgr.ObjectFormat().Splitter)
+	fmt.Fprintf(w, "ECC:          %s\n", eccDescription(fmgr.GetECCAlgorithm(), fmgr.GetECCOverheadPercent()))
+	fmt.Fprintf(w, "Retention:    %s\n", retentionDescription(op.Config))
+
+	return nil
+}
+
+// retentionDescription returns when the retention policy is applied to the snapshots
+func retentionDescription(config *util.Config) string {
+	switch {
+	case config.Archival:
+		return "archival, every snapshot is kept"
+	case config.DeferRetention:
+		return "applied by prune"
+	default:
+		return "applied by snap and prune"
+	}
+}
+
+// eccDescription returns a human-readable summary of the error correction settings
+func eccDescription(algorithm string, overheadPercent int) string {
+	if algorithm == "" || overheadPercent == 0 {
+		return "disabled"
+	}
+	return fmt.Sprintf("%s with %d%% storage overhead", algorithm, overheadPercent)
+}
@@ -23,13 +23,15 @@ import (
 	"git-gasset/util"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
-	"github.com/kopia/kopia/repo/blob/s3"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/spf13/cobra"
 	"log"
-	"math/rand"
-	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 )
 
 // initCmd represents the init command
@@ -39,7 +41,33 @@ var initCmd = &cobra.Command{
 	Long: `Creates or connects to the Kopia repository
 
 Checks the existence of the Kopia config file and if exists uses
-it to connect and if not, creates the repository.`,
+it to connect and if not, creates the repository.
+
+With --shared the Kopia repository can be used by several projects.
+The snapshots of every project are kept under its own namespace so
+that shared assets are deduplicated instead of stored once per project.
+Run it with --create for the first project and without it to register
+further projects in the existing repository.
+
+With --kopia-config, the kopiaConfig key of the .gasset file or
+KOPIA_CONFIG_PATH an existing kopia config is used instead. It has to
+be connected to the repository of the .gasset file and is never written
+to, init only generates the gasset id if there is none.
+
+With the apiServer section of the kopia block instead of a storage, init
+connects through a kopia repository server as the user of the hostname
+and username, with KOPIA_PASSWORD as the password of that user on the
+server. The repository is created on the server, not with --create.
+The server has to present the certificate of serverCertFingerprint,
+which snap checks as well before connecting.
+
+With --template the recommended asset directories, ignore rules and
+compression of a kind of project are added to the .gasset file, the
+directories are created and the assets are added to the .gitignore file.
+Settings already in the .gasset file are kept.
+
+A filesystem storage, e.g. on a mounted network drive, needs an absolute
+path. Its directory has to exist unless --create is passed, which creates it.`,
 	RunE: InitRun,
 }
 
@@ -55,52 +83,165 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	initCmd.Flags().BoolP("create", "c", false, "Creates the repository if not exists")
+	initCmd.Flags().String("ecc", ecc.DefaultAlgorithm, "Error correction algorithm used when creating the repository ("+strings.Join(ecc.SupportedAlgorithms(), ", ")+")")
+	initCmd.Flags().Int("ecc-overhead-percent", 0, "Space overhead in percent used for error correction when creating the repository, 0 disables it")
+	initCmd.Flags().Bool("shared", false, "Namespaces the snapshots of this project so that the repository can be shared with other projects")
+	initCmd.Flags().String("template", "", "Scaffolds the asset directories, ignore rules and compression of a kind of project ("+strings.Join(util.ProjectTemplateNames(), ", ")+")")
 }
 
 func InitRun(cmd *cobra.Command, _ []string) error {
 	log.Println("init called")
 
-	options := util.Options{
-		GassetIdLength:   8,
-		OsGetwd:          os.Getwd,
-		OsTempDir:        os.TempDir,
-		OsUserConfigDir:  os.UserConfigDir,
-		RandIntn:         rand.Intn,
-		S3New:            s3.New,
-		RepoConnect:      repo.Connect,
-		RepoInitialize:   repo.Initialize,
-		RepoOpen:         repo.Open,
-		RepoWriteSession: repo.WriteSession,
-		PolicySetPolicy:  policy.SetPolicy,
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
 	}
 
-	if err := options.InitWorkingDirectory(); err != nil {
+	doCreate, err := cmd.Flags().GetBool("create")
+	if err != nil {
 		return err
 	}
 
-	if err := options.ReloadKopiaConfig(); err != nil {
+	shared, err := cmd.Flags().GetBool("shared")
+	if err != nil {
 		return err
 	}
 
-	doCreate, err := cmd.Flags().GetBool("create")
+	eccAlgorithm, err := cmd.Flags().GetString("ecc")
+	if err != nil {
+		return err
+	}
+
+	eccOverheadPercent, err := cmd.Flags().GetInt("ecc-overhead-percent")
+	if err != nil {
+		return err
+	}
+
+	newRepoOptions, err := newRepositoryOptions(eccAlgorithm, eccOverheadPercent)
+	if err != nil {
+		return err
+	}
+
+	templateName, err := cmd.Flags().GetString("template")
+	if err != nil {
+		return err
+	}
+	if templateName != "" {
+		if err := scaffoldTemplate(options, templateName); err != nil {
+			return err
+		}
+	}
+
+	if !options.UsesExternalKopiaConfig() {
+		if err := checkInsecure(cmd, publicBucket(context.Background(), options)); err != nil {
+			return err
+		}
+	}
+
+	if err := connect(options, doCreate, shared, newRepoOptions); err != nil {
+		return err
+	}
+	status := "connected"
+	if doCreate {
+		status = "created"
+	}
+	events.Emit(util.Event{Operation: "init", Status: status, GassetId: options.Config.GassetId})
+	return nil
+}
+
+// publicBucket returns a problem if anyone can read the repository, e.g. through the policy of an s3 bucket.
+// Only the storages of the providers implementing util.PublicReadChecker are checked.
+func publicBucket(ctx context.Context, op *util.Options) []string {
+	if op.Config.Kopia.Storage == nil {
+		return nil
+	}
+	provider, err := util.GetStorageProvider(op.Config.Kopia.Storage.Type)
+	if err != nil {
+		return nil
+	}
+	checker, ok := provider.(util.PublicReadChecker)
+	if !ok {
+		return nil
+	}
+	return checker.PublicRead(ctx, op, op.Config.Kopia.Storage.Config)
+}
+
+// scaffoldTemplate adds a project template to the .gasset file and the .gitignore file and creates its asset directories
+func scaffoldTemplate(op *util.Options, templateName string) error {
+	template, err := util.GetProjectTemplate(templateName)
 	if err != nil {
 		return err
 	}
 
-	return connect(&options, doCreate)
+	// The .gasset file is updated from disk so that the secrets loaded into the options are not written to it
+	if err := util.ApplyProjectTemplate(op.WorkingDirectory, template); err != nil {
+		return err
+	}
+	op.Config.ApplyTemplate(template)
+
+	for _, dir := range template.Dirs {
+		if err := op.Config.Permissions.MkdirAll(filepath.Join(op.WorkingDirectory, dir)); err != nil {
+			return err
+		}
+	}
+
+	added, err := util.AppendGitIgnore(op.WorkingDirectory, template.GitIgnore, op.Config.Permissions)
+	if err != nil {
+		return err
+	}
+	log.Printf("Applied the %s template, added %d .gitignore entries", templateName, len(added))
+	return nil
 }
 
-func connect(op *util.Options, create bool) error {
+// newRepositoryOptions returns the options used to initialize a new repository after validating the ECC settings
+func newRepositoryOptions(eccAlgorithm string, eccOverheadPercent int) (*repo.NewRepositoryOptions, error) {
+	if eccOverheadPercent < 0 || eccOverheadPercent > 100 {
+		return nil, fmt.Errorf("ecc overhead percent must be between 0 and 100, got %d", eccOverheadPercent)
+	}
+
+	if eccOverheadPercent > 0 && !slices.Contains(ecc.SupportedAlgorithms(), eccAlgorithm) {
+		return nil, fmt.Errorf("unsupported ecc algorithm %q", eccAlgorithm)
+	}
+
+	return &repo.NewRepositoryOptions{
+		BlockFormat: format.ContentFormat{
+			ECC:                eccAlgorithm,
+			ECCOverheadPercent: eccOverheadPercent,
+		},
+	}, nil
+}
+
+func connect(op *util.Options, create bool, shared bool, newRepoOptions *repo.NewRepositoryOptions) error {
 	ctx := context.Background()
 
-	storage, err := op.S3New(ctx, op.Config.Kopia.Storage.Config.(*s3.Options), false)
+	// An existing kopia config was checked to be connected to the repository while loading the options
+	if op.UsesExternalKopiaConfig() {
+		if create {
+			return fmt.Errorf("the repository of the kopia config %s exists already, run init without --create", op.KopiaConfigPath)
+		}
+		return adoptKopiaConfig(op, shared)
+	}
+
+	// The repository behind a kopia API server is created and connected to its storage by the server
+	if op.ConnectsToServer() {
+		if create {
+			return fmt.Errorf("the repository of the kopia API server %s is created on the server, run init without --create", op.Config.Kopia.APIServer.BaseURL)
+		}
+		return connectServer(ctx, op, shared)
+	}
+
+	storage, err := openStorage(ctx, op, create)
 	if err != nil {
 		return err
 	}
 	op.Storage = storage
 
 	if create {
-		if err := createRepo(ctx, op); err != nil {
+		if err := createRepo(ctx, op, shared, newRepoOptions); err != nil {
+			return err
+		}
+	} else if shared && op.Config.GassetId == "" {
+		if err := joinRepo(ctx, op); err != nil {
 			return err
 		}
 	}
@@ -111,23 +252,124 @@ func connect(op *util.Options, create bool) error {
 	return nil
 }
 
+// openStorage opens the storage of the kopia config of the .gasset file with the provider of its type.
+// Only the directory of a filesystem storage is created with --create, the buckets must exist already.
+func openStorage(ctx context.Context, op *util.Options, create bool) (blob.Storage, error) {
+	storage := op.Config.Kopia.Storage
+	if storage == nil {
+		return nil, errors.New("the .gasset file has no storage")
+	}
+	provider, err := util.GetStorageProvider(storage.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := provider.ValidateCredentials(storage.Config); err != nil {
+		return nil, err
+	}
+	if create {
+		return provider.Create(ctx, op, storage.Config)
+	}
+	return provider.Connect(ctx, op, storage.Config)
+}
+
 func connectRepo(ctx context.Context, op *util.Options) error {
 	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
 	if err != nil {
 		return err
 	}
-	return op.RepoConnect(ctx, kopiaUserConfigPath, op.Storage, op.Password, &repo.ConnectOptions{
-		ClientOptions:  op.Config.Kopia.ClientOptions,
-		CachingOptions: content.CachingOptions{},
-	})
+	if err := op.RepoConnect(ctx, kopiaUserConfigPath, op.Storage, op.Password, &repo.ConnectOptions{
+		ClientOptions:  op.ClientOptions(),
+		CachingOptions: cachingOptions(op),
+	}); err != nil {
+		return err
+	}
+	return verifyFingerprint(ctx, op, kopiaUserConfigPath)
+}
+
+// connectServer connects to the repository of the kopia API server of the .gasset file, as the user of the client
+// options with the password. The first project connecting to it gets a new gasset id like with an external kopia config.
+func connectServer(ctx context.Context, op *util.Options, shared bool) error {
+	server := op.Config.Kopia.APIServer
+	if err := util.CheckServerCertificate(ctx, server); err != nil {
+		return err
+	}
+
+	// The gasset id names the kopia config so it is generated before connecting and saved once connected
+	assigned := op.Config.GassetId == ""
+	if assigned {
+		op.Config.GassetId = util.GenerateRandomString(op.GassetIdLength, op.RandIntn)
+	}
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := op.RepoConnectAPIServer(ctx, kopiaUserConfigPath, server, op.Password, &repo.ConnectOptions{
+		ClientOptions:  op.ClientOptions(),
+		CachingOptions: cachingOptions(op),
+	}); err != nil {
+		return err
+	}
+	log.Printf("Connected to the kopia API server %s", server.BaseURL)
+
+	if !assigned {
+		return nil
+	}
+	return saveGassetId(op, shared)
+}
+
+// cachingOptions returns the caching options of the .gasset file, caching stays disabled unless it configures it
+func cachingOptions(op *util.Options) content.CachingOptions {
+	caching := content.CachingOptions{}
+	if op.Config.Kopia.Caching != nil {
+		caching = *op.Config.Kopia.Caching
+	}
+	if caching.CacheDirectory == "" {
+		caching.CacheDirectory = op.DefaultCacheDirectory()
+	}
+	return caching
+}
+
+// verifyFingerprint records the fingerprint of the repository on the first connect of the gasset id and
+// checks it on the later ones, disconnecting from a repository whose bucket contents were replaced
+func verifyFingerprint(ctx context.Context, op *util.Options, kopiaUserConfigPath string) error {
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	directRep, ok := rep.(repo.DirectRepository)
+	if !ok {
+		// The repository behind a kopia API server is verified by the server
+		if rep != nil {
+			rep.Close(ctx)
+		}
+		return nil
+	}
+	fingerprint := util.RepositoryFingerprint(directRep)
+	rep.Close(ctx)
+
+	recorded, err := op.VerifyFingerprint(fingerprint)
+	if err != nil {
+		if errors.Is(err, util.ErrFingerprintMismatch) {
+			if err := repo.Disconnect(ctx, kopiaUserConfigPath); err != nil {
+				log.Printf("Could not disconnect from the repository: %v", err)
+			}
+		}
+		return err
+	}
+	if recorded {
+		log.Printf("Recorded the repository fingerprint %s", fingerprint)
+	} else {
+		log.Printf("Repository fingerprint %s matches the recorded one", fingerprint)
+	}
+	return nil
 }
 
-func createRepo(ctx context.Context, op *util.Options) error {
+func createRepo(ctx context.Context, op *util.Options, shared bool, newRepoOptions *repo.NewRepositoryOptions) error {
 	if err := ensureEmpty(ctx, op.Storage); err != nil {
 		return err
 	}
 
-	if err := op.RepoInitialize(ctx, op.Storage, nil, op.Password); err != nil {
+	if err := op.RepoInitialize(ctx, op.Storage, newRepoOptions, op.Password); err != nil {
 		return err
 	}
 
@@ -142,9 +384,55 @@ func createRepo(ctx context.Context, op *util.Options) error {
 		return err
 	}
 
+	if shared {
+		return updateSharedIdentity(op)
+	}
 	return util.UpdateGassetId(op.WorkingDirectory, op.Config.GassetId)
 }
 
+// joinRepo registers the project in a repository created by another project with its own gasset id and namespace
+func joinRepo(ctx context.Context, op *util.Options) error {
+	op.Config.GassetId = util.GenerateRandomString(op.GassetIdLength, op.RandIntn)
+
+	if err := connectRepo(ctx, op); err != nil {
+		return err
+	}
+
+	return updateSharedIdentity(op)
+}
+
+// adoptKopiaConfig registers the project in the repository of an existing kopia config without writing to the config
+func adoptKopiaConfig(op *util.Options, shared bool) error {
+	if op.Config.GassetId != "" {
+		log.Printf("Using the kopia config %s", op.KopiaConfigPath)
+		return nil
+	}
+
+	op.Config.GassetId = util.GenerateRandomString(op.GassetIdLength, op.RandIntn)
+	log.Printf("Using the kopia config %s with the new gasset id %s", op.KopiaConfigPath, op.Config.GassetId)
+	return saveGassetId(op, shared)
+}
+
+// saveGassetId saves the new gasset id of a repository which gasset did not create, as the namespace as well if shared
+func saveGassetId(op *util.Options, shared bool) error {
+	if shared {
+		return updateSharedIdentity(op)
+	}
+	return util.UpdateGassetId(op.WorkingDirectory, op.Config.GassetId)
+}
+
+// updateSharedIdentity saves the gasset id and uses it as the namespace unless one is configured already
+func updateSharedIdentity(op *util.Options) error {
+	if op.Config.Namespace == "" {
+		op.Config.Namespace = op.Config.GassetId
+	}
+
+	if err := util.UpdateGassetId(op.WorkingDirectory, op.Config.GassetId); err != nil {
+		return err
+	}
+	return util.UpdateNamespace(op.WorkingDirectory, op.Config.Namespace)
+}
+
 // mostly from github.com/kopia/kopia/cli.commandRepositoryCreate.ensureEmpty
 func ensureEmpty(ctx context.Context, storage blob.Storage) error {
 	hasDataError := errors.New("has data")
@@ -176,7 +464,7 @@ func initPolicy(ctx context.Context, op *util.Options) error {
 		defer rep.Close(ctx)
 	}
 	return op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
-		Purpose: "Initialize repository with default policy",
+		Purpose: op.SessionPurpose("Initialize repository with default policy"),
 	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
 		// Not needed once https://github.com/kopia/kopia/issues/3556 is closed and released
 		newOptionalInt := func(b policy.OptionalInt) *policy.OptionalInt {
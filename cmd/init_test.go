@@ -18,11 +18,22 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"git-gasset/gassettest"
 	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -42,10 +53,25 @@ func (suite *InitSuite) SetupSuite() {
 	}
 }
 
+// workingCopy clones the options into a temp working directory holding a copy of their .gasset file
+func (suite *InitSuite) workingCopy(options *util.Options) *util.Options {
+	config, err := util.GetConfig(options.WorkingDirectory)
+	if err != nil {
+		suite.T().FailNow()
+	}
+	clone := options.Clone()
+	clone.WorkingDirectory = suite.T().TempDir()
+	if err := util.UpdateConfig(filepath.Join(clone.WorkingDirectory, ".gasset"), config); err != nil {
+		suite.T().FailNow()
+	}
+	return clone
+}
+
 func (suite *InitSuite) Test_initOptions_connect() {
 	type args struct {
 		options *util.Options
 		create  bool
+		shared  bool
 	}
 	tests := []struct {
 		name    string
@@ -67,13 +93,83 @@ func (suite *InitSuite) Test_initOptions_connect() {
 			args:    args{options: suite.OptionsWithGassetId, create: true},
 			wantErr: assert.NoError,
 		},
+		{
+			name:    "Join a shared S3 repository with no gasset id registered",
+			args:    args{options: suite.OptionsWithNoGassetId, create: false, shared: true},
+			wantErr: assert.NoError,
+		},
 	}
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
-			err := connect(tt.args.options, tt.args.create)
-			if !tt.wantErr(suite.T(), err, fmt.Sprintf("connect(%v)", tt.args.create)) {
+			options := tt.args.options.Clone()
+			if tt.args.create || tt.args.shared {
+				// Creating and joining rewrite the .gasset file so they must not touch the shared mocks
+				options = suite.workingCopy(tt.args.options)
+			}
+			err := connect(options, tt.args.create, tt.args.shared, &repo.NewRepositoryOptions{})
+			if !tt.wantErr(suite.T(), err, fmt.Sprintf("connect(%v, %v)", tt.args.create, tt.args.shared)) || err != nil {
 				return
 			}
+			if tt.args.shared {
+				assert.Equalf(suite.T(), options.Config.GassetId, options.Config.Namespace, "connect(%v, %v)", tt.args.create, tt.args.shared)
+			}
+		})
+	}
+}
+
+func (suite *InitSuite) Test_initOptions_openStorage() {
+	dir := suite.T().TempDir()
+	tests := []struct {
+		name    string
+		storage *blob.ConnectionInfo
+		create  bool
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Open an S3 storage",
+			storage: &blob.ConnectionInfo{Type: "s3", Config: &s3.Options{BucketName: "bucket"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Open an existing directory",
+			storage: &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{Path: dir}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Fail to open a missing directory without create",
+			storage: &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{Path: filepath.Join(dir, "nas")}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Create a missing directory",
+			storage: &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{Path: filepath.Join(dir, "nas", "assets")}},
+			create:  true,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Fail on a config of another storage type",
+			storage: &blob.ConnectionInfo{Type: "filesystem", Config: &s3.Options{BucketName: "bucket"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Fail on an unsupported storage",
+			storage: &blob.ConnectionInfo{Type: "gcs"},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			options := suite.OptionsWithGassetId.Clone()
+			options.FilesystemNew = filesystem.New
+			options.Config.Kopia.Storage = tt.storage
+			st, err := openStorage(context.Background(), options, tt.create)
+			if !tt.wantErr(suite.T(), err, fmt.Sprintf("openStorage(%v)", tt.storage.Type)) || err != nil {
+				return
+			}
+			assert.NotNil(suite.T(), st)
+			if opt, ok := tt.storage.Config.(*filesystem.Options); ok {
+				assert.DirExists(suite.T(), opt.Path)
+			}
 		})
 	}
 }
@@ -134,7 +230,7 @@ func (suite *InitSuite) Test_initOptions_createRepo() {
 	}
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
-			err := createRepo(tt.args.ctx, tt.args.options)
+			err := createRepo(tt.args.ctx, suite.workingCopy(tt.args.options), false, &repo.NewRepositoryOptions{})
 			if !tt.wantErr(suite.T(), err, fmt.Sprintf("createRepo(%v)", tt.args.ctx)) {
 				return
 			}
@@ -142,6 +238,48 @@ func (suite *InitSuite) Test_initOptions_createRepo() {
 	}
 }
 
+func (suite *InitSuite) Test_initOptions_newRepositoryOptions() {
+	type args struct {
+		eccAlgorithm       string
+		eccOverheadPercent int
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "ECC disabled",
+			args:    args{eccAlgorithm: "", eccOverheadPercent: 0},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "ECC enabled with a supported algorithm",
+			args:    args{eccAlgorithm: "REED-SOLOMON-CRC32", eccOverheadPercent: 2},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "ECC enabled with an unsupported algorithm",
+			args:    args{eccAlgorithm: "UNKNOWN", eccOverheadPercent: 2},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "ECC overhead out of range",
+			args:    args{eccAlgorithm: "REED-SOLOMON-CRC32", eccOverheadPercent: 101},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			got, err := newRepositoryOptions(tt.args.eccAlgorithm, tt.args.eccOverheadPercent)
+			if !tt.wantErr(suite.T(), err, fmt.Sprintf("newRepositoryOptions(%v, %v)", tt.args.eccAlgorithm, tt.args.eccOverheadPercent)) || err != nil {
+				return
+			}
+			assert.Equalf(suite.T(), tt.args.eccOverheadPercent, got.BlockFormat.ECCOverheadPercent, "newRepositoryOptions(%v, %v)", tt.args.eccAlgorithm, tt.args.eccOverheadPercent)
+		})
+	}
+}
+
 func (suite *InitSuite) Test_initOptions_ensureEmpty() {
 	type args struct {
 		ctx     context.Context
@@ -199,3 +337,61 @@ func (suite *InitSuite) Test_initOptions_initPolicy() {
 		})
 	}
 }
+
+func (suite *InitSuite) Test_initOptions_connectServer() {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	sum := sha256.Sum256(server.Certificate().Raw)
+	apiServer := &repo.APIServerInfo{BaseURL: server.URL, TrustedServerCertificateFingerprint: hex.EncodeToString(sum[:])}
+
+	workingDirectory := suite.T().TempDir()
+	options, doubles := gassettest.NewOptions(suite.T(), workingDirectory)
+	options.Config.Kopia = &repo.LocalConfig{APIServer: apiServer, ClientOptions: options.Config.Kopia.ClientOptions}
+	options.Config.GassetId = ""
+	if err := util.SaveConfig(workingDirectory, options.Config); err != nil {
+		suite.T().FailNow()
+	}
+
+	assert.Error(suite.T(), connect(options, true, false, &repo.NewRepositoryOptions{}), "connect() creating the repository of a server")
+
+	doubles.Repo.EXPECT().ConnectAPIServer(mock.Anything, mock.Anything, apiServer, "password", mock.Anything).Return(nil).Once()
+	if !assert.NoError(suite.T(), connect(options, false, false, &repo.NewRepositoryOptions{})) {
+		return
+	}
+	config, err := util.GetConfig(workingDirectory)
+	if assert.NoError(suite.T(), err) {
+		assert.NotEmpty(suite.T(), config.GassetId, "the first connect to the server saves a gasset id")
+	}
+
+	// A rotated certificate is refused before connecting
+	options.Config.Kopia.APIServer = &repo.APIServerInfo{BaseURL: server.URL, TrustedServerCertificateFingerprint: strings.Repeat("0", 64)}
+	assert.ErrorIs(suite.T(), connect(options, false, false, &repo.NewRepositoryOptions{}), util.ErrServerCertificateMismatch)
+}
+
+type ConnectRepoSuite struct {
+	repoSuite
+}
+
+func TestConnectRepoSuite(t *testing.T) {
+	suite.Run(t, new(ConnectRepoSuite))
+}
+
+func (suite *ConnectRepoSuite) Test_connectRepo_fingerprint() {
+	ctx := context.Background()
+	assert.NoError(suite.T(), connectRepo(ctx, suite.options), "connectRepo() on the first connect")
+	assert.NoError(suite.T(), connectRepo(ctx, suite.options), "connectRepo() to the same repository")
+
+	// Another repository in place of the bucket contents
+	replaced := util.NewMemoryStorage(suite.T().TempDir())
+	if err := repo.Initialize(ctx, replaced, &repo.NewRepositoryOptions{}, suite.options.Password); err != nil {
+		suite.T().FailNow()
+	}
+	suite.options.Storage = replaced
+	assert.ErrorIs(suite.T(), connectRepo(ctx, suite.options), util.ErrFingerprintMismatch)
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	assert.NoFileExists(suite.T(), kopiaUserConfigPath, "kopia config after a fingerprint mismatch")
+}
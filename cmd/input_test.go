@@ -0,0 +1,174 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"strings"
+	"testing"
+)
+
+type InputSuite struct {
+	repoSuite
+}
+
+func TestInputSuite(t *testing.T) {
+	suite.Run(t, new(InputSuite))
+}
+
+func (suite *InputSuite) newCmd(args ...string) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.PersistentFlags().String("storage", "", "")
+	addInputFlags(cmd)
+	addConfirmFlags(cmd)
+	if err := cmd.ParseFlags(args); err != nil {
+		suite.T().FailNow()
+	}
+	return cmd
+}
+
+func (suite *InputSuite) Test_loadInputFlags() {
+	tests := []struct {
+		name               string
+		args               []string
+		stdin              string
+		wantErr            assert.ErrorAssertionFunc
+		wantStorageURL     string
+		wantPassword       string
+		wantNonInteractive bool
+	}{
+		{
+			name:               "Load the bucket and the password of stdin",
+			args:               []string{"--bucket", "assets", "--endpoint", "localhost:9000", "--prefix", "game/", "--password-stdin", "--non-interactive"},
+			stdin:              "secret\n",
+			wantErr:            assert.NoError,
+			wantStorageURL:     "s3://assets/game/?endpoint=localhost%3A9000",
+			wantPassword:       "secret",
+			wantNonInteractive: true,
+		},
+		{
+			name:    "Fail on an empty password",
+			args:    []string{"--password-stdin"},
+			stdin:   "\n",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Fail on a prefix without a bucket",
+			args:    []string{"--prefix", "game/"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Fail on both a storage and a bucket",
+			args:    []string{"--storage", "b2://assets/", "--bucket", "assets"},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cmd := suite.newCmd(tt.args...)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			op := &util.Options{}
+			if !tt.wantErr(suite.T(), loadInputFlags(cmd, op)) || tt.wantStorageURL == "" {
+				return
+			}
+			assert.Equal(suite.T(), tt.wantStorageURL, op.StorageURL)
+			assert.Equal(suite.T(), tt.wantPassword, op.GivenPassword)
+			assert.Equal(suite.T(), tt.wantNonInteractive, op.NonInteractive)
+		})
+	}
+}
+
+func (suite *InputSuite) Test_readPassword() {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Read the first line",
+			input: "secret\r\nignored\n",
+			want:  "secret",
+		},
+		{
+			name:  "Read a password without a newline",
+			input: "no newline",
+			want:  "no newline",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			password, err := readPassword(strings.NewReader(tt.input), io.Discard)
+			if assert.NoError(suite.T(), err) {
+				assert.Equal(suite.T(), tt.want, password)
+			}
+		})
+	}
+}
+
+func (suite *InputSuite) Test_confirmDestructive() {
+	tests := []struct {
+		name        string
+		args        []string
+		env         string
+		stdin       string
+		wantErrText string
+	}{
+		{
+			name:        "Point to --yes in the non-interactive mode",
+			args:        []string{"--non-interactive"},
+			wantErrText: "--yes",
+		},
+		{
+			name:        "Require the environment variable with --yes",
+			args:        []string{"--yes"},
+			wantErrText: util.EnvAllowDestructive,
+		},
+		{
+			name: "Skip the confirmation with --yes and the environment variable",
+			args: []string{"--yes"},
+			env:  "1",
+		},
+		{
+			name:  "Confirm with the gasset id typed",
+			stdin: suite.OptionsWithGassetId.Config.GassetId + "\n",
+		},
+		{
+			name:        "Abort on another gasset id typed",
+			stdin:       "other\n",
+			wantErrText: "does not match",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.T().Setenv(util.EnvAllowDestructive, tt.env)
+			cmd := suite.newCmd(tt.args...)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetErr(io.Discard)
+
+			err := confirmDestructive(cmd, suite.options, "delete the snapshots")
+			if tt.wantErrText == "" {
+				assert.NoError(suite.T(), err)
+			} else {
+				assert.ErrorContains(suite.T(), err, tt.wantErrText)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"log"
+	"path/filepath"
+)
+
+// licenseCmd represents the license command
+var licenseCmd = &cobra.Command{
+	Use:   "license",
+	Short: "Manages the license metadata of the assets",
+}
+
+// licenseSetCmd represents the license set command
+var licenseSetCmd = &cobra.Command{
+	Use:   "set <path>",
+	Short: "Attaches license metadata to an asset directory or file",
+	Long: `Attaches license metadata to an asset directory or file.
+
+The metadata is stored in a ` + util.LicenseFileName + ` sidecar manifest in the
+directory, so it is snapshotted together with the assets. The license of a
+directory applies to all its files and subdirectories unless they have their
+own. Use report licenses to review the licenses of a snapshot.`,
+	Args: cobra.ExactArgs(1),
+	RunE: LicenseSetRun,
+}
+
+func init() {
+	rootCmd.AddCommand(licenseCmd)
+	licenseCmd.AddCommand(licenseSetCmd)
+
+	licenseSetCmd.Flags().String("license", "", "License of the asset, preferably an SPDX identifier like CC-BY-4.0")
+	licenseSetCmd.Flags().String("source", "", "Where the asset was obtained from")
+	licenseSetCmd.Flags().String("author", "", "Author or vendor of the asset")
+}
+
+func LicenseSetRun(cmd *cobra.Command, args []string) error {
+	log.Println("license set called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	info := util.LicenseInfo{}
+	if info.License, err = cmd.Flags().GetString("license"); err != nil {
+		return err
+	}
+	if info.Source, err = cmd.Flags().GetString("source"); err != nil {
+		return err
+	}
+	if info.Author, err = cmd.Flags().GetString("author"); err != nil {
+		return err
+	}
+	if info == (util.LicenseInfo{}) {
+		return errors.New("at least one of --license, --source and --author is required")
+	}
+
+	// Only assets inside the asset directories are snapshotted with their metadata
+	if _, _, err := options.AssetDir(args[0]); err != nil {
+		return err
+	}
+
+	assetPath := args[0]
+	if !filepath.IsAbs(assetPath) {
+		assetPath = filepath.Join(options.WorkingDirectory, assetPath)
+	}
+	return util.SetLicense(assetPath, info, options.Config.Permissions)
+}
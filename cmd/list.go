@@ -0,0 +1,201 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the snapshots",
+	Long: `Lists the snapshots of the asset directories, oldest first.
+
+By default the snapshots of all the asset directories taken by any user
+are listed. The ones taken with a --machine-identity, e.g. by CI agents,
+are only listed with --machine or a --host or --user filter naming them.
+The filters are applied to the metadata of the snapshot manifests, so
+that only the matching snapshots are loaded even in large shared
+repositories. --path-prefix matches the source paths, which are relative
+to the working directory unless they start with a slash.
+
+Every snapshot is printed with its id, start time, root object id, size,
+file count and source, or as JSON with --json for scripts.`,
+	Args: cobra.NoArgs,
+	RunE: ListRun,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().String("host", "", "Only lists the snapshots taken on the given host or machine identity")
+	listCmd.Flags().String("user", "", "Only lists the snapshots taken by the given user")
+	listCmd.Flags().StringSlice("path-prefix", nil, "Only lists the snapshots of the sources under the given paths")
+	listCmd.Flags().Duration("since", 0, "Only lists the snapshots started within the given duration, e.g. 720h")
+	listCmd.Flags().Duration("until", 0, "Only lists the snapshots started before the given duration ago, e.g. 24h")
+	listCmd.Flags().String("changeset", "", "Only lists the snapshots of the changeset with the given id or name")
+	listCmd.Flags().Bool("machine", false, "Also lists the snapshots taken with a machine identity, e.g. by CI agents")
+	listCmd.Flags().Bool("json", false, "Prints the snapshots as JSON")
+}
+
+func ListRun(cmd *cobra.Command, _ []string) error {
+	log.Println("list called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	filter := util.SnapshotFilter{}
+	if filter.Host, err = cmd.Flags().GetString("host"); err != nil {
+		return err
+	}
+	if filter.User, err = cmd.Flags().GetString("user"); err != nil {
+		return err
+	}
+	pathPrefixes, err := cmd.Flags().GetStringSlice("path-prefix")
+	if err != nil {
+		return err
+	}
+	filter.PathPrefixes = listPathPrefixes(options, pathPrefixes)
+
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		return err
+	}
+	if since > 0 {
+		filter.Since = time.Now().Add(-since)
+	}
+	until, err := cmd.Flags().GetDuration("until")
+	if err != nil {
+		return err
+	}
+	if until > 0 {
+		filter.Until = time.Now().Add(-until)
+	}
+	changeset, err := cmd.Flags().GetString("changeset")
+	if err != nil {
+		return err
+	}
+	if filter.Changeset, err = changesetID(options, changeset); err != nil {
+		return err
+	}
+	if filter.Machine, err = cmd.Flags().GetBool("machine"); err != nil {
+		return err
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	return listSnapshots(context.Background(), options, filter, asJson, cmd.OutOrStdout())
+}
+
+// changesetID returns the id of the changeset recorded in the lock files with the given id or name.
+// Ids of changesets missing in the lock files, e.g. the ones of another branch, are used as they are.
+func changesetID(op *util.Options, idOrName string) (string, error) {
+	if idOrName == "" {
+		return "", nil
+	}
+	lock, err := util.LoadLockFiles(op.WorkingDirectory, op.Config.Dirs)
+	if err != nil {
+		return "", err
+	}
+	if changeset, ok := lock.FindChangeset(idOrName); ok {
+		return changeset.ID, nil
+	}
+	return idOrName, nil
+}
+
+// listPathPrefixes returns the source paths to list, the ones of the asset directories if no prefix is given
+func listPathPrefixes(op *util.Options, pathPrefixes []string) []string {
+	if len(pathPrefixes) == 0 {
+		for _, dir := range op.Config.Dirs {
+			pathPrefixes = append(pathPrefixes, dir)
+			// The snapshots taken before the directory was moved are part of its history
+			pathPrefixes = append(pathPrefixes, op.Config.PreviousDirs(dir)...)
+		}
+	}
+
+	sourcePaths := make([]string, 0, len(pathPrefixes))
+	for _, pathPrefix := range pathPrefixes {
+		if path.IsAbs(pathPrefix) || filepath.IsAbs(pathPrefix) {
+			sourcePaths = append(sourcePaths, pathPrefix)
+		} else {
+			sourcePaths = append(sourcePaths, op.SourcePath(pathPrefix))
+		}
+	}
+	return sourcePaths
+}
+
+func listSnapshots(ctx context.Context, op *util.Options, filter util.SnapshotFilter, asJson bool, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	manifests, err := util.FindSnapshots(ctx, rep, filter)
+	if err != nil {
+		return err
+	}
+
+	summary.Add("snapshots", len(manifests))
+	details := make([]util.SnapshotDetails, 0, len(manifests))
+	for _, man := range manifests {
+		details = append(details, util.NewSnapshotDetails(man))
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(details)
+	}
+
+	for _, snapshotDetails := range details {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d files\t%s@%s:%s",
+			snapshotDetails.ID,
+			util.FormatTime(snapshotDetails.StartTime, op.LocalTime),
+			snapshotDetails.RootObjectID,
+			util.FormatBytes(snapshotDetails.Bytes),
+			snapshotDetails.Files,
+			snapshotDetails.User, snapshotDetails.Host, snapshotDetails.Path,
+		)
+		if snapshotDetails.IncompleteReason != "" {
+			fmt.Fprintf(w, "\tincomplete: %s", snapshotDetails.IncompleteReason)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
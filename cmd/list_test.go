@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type ListSuite struct {
+	repoSuite
+}
+
+func TestListSuite(t *testing.T) {
+	suite.Run(t, new(ListSuite))
+}
+
+func (suite *ListSuite) Test_listSnapshots() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	filter := util.SnapshotFilter{PathPrefixes: listPathPrefixes(suite.options, nil)}
+
+	w := &bytes.Buffer{}
+	if !assert.NoError(suite.T(), listSnapshots(ctx, suite.options, filter, true, w)) {
+		return
+	}
+	var details []util.SnapshotDetails
+	if err := json.Unmarshal(w.Bytes(), &details); err != nil {
+		suite.T().FailNow()
+	}
+	if !assert.Len(suite.T(), details, 1) {
+		return
+	}
+	assert.Equal(suite.T(), int64(1), details[0].Files)
+	assert.Equal(suite.T(), int64(1), details[0].Bytes)
+	assert.NotEmpty(suite.T(), details[0].RootObjectID)
+
+	w.Reset()
+	assert.NoError(suite.T(), listSnapshots(ctx, suite.options, filter, false, w))
+	assert.Equal(suite.T(), strings.Join([]string{
+		details[0].ID,
+		util.FormatTime(details[0].StartTime, false),
+		details[0].RootObjectID,
+		"1.0 B",
+		"1 files",
+		details[0].User + "@" + details[0].Host + ":" + details[0].Path,
+	}, "\t")+"\n", w.String())
+}
+
+func (suite *ListSuite) Test_listSnapshots_machine() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	filter := util.SnapshotFilter{PathPrefixes: listPathPrefixes(suite.options, nil)}
+
+	machine := suite.options.Clone()
+	if err := machine.SetMachineIdentity("ci-agent"); err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(machine.WorkingDirectory, "assets", "b.txt"), []byte("b"), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+	if _, err := createSnapshot(ctx, machine, machine.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	tests := []struct {
+		name      string
+		filter    util.SnapshotFilter
+		wantCount int
+	}{
+		{name: "Leave out the machine snapshots", filter: filter, wantCount: 1},
+		{name: "List the machine snapshots with --machine", filter: util.SnapshotFilter{PathPrefixes: filter.PathPrefixes, Machine: true}, wantCount: 2},
+		{name: "List the machine snapshots of a host filter", filter: util.SnapshotFilter{PathPrefixes: filter.PathPrefixes, Host: "ci-agent"}, wantCount: 1},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			w := &bytes.Buffer{}
+			if !assert.NoError(suite.T(), listSnapshots(ctx, suite.options, tt.filter, true, w)) {
+				return
+			}
+			var listed []util.SnapshotDetails
+			if err := json.Unmarshal(w.Bytes(), &listed); err != nil {
+				suite.T().FailNow()
+			}
+			assert.Len(suite.T(), listed, tt.wantCount)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// mvCmd represents the mv command
+var mvCmd = &cobra.Command{
+	Use:   "mv <old-dir> <new-dir>",
+	Short: "Moves an asset directory",
+	Long: `Moves an asset directory.
+
+The directory is renamed on disk and in the .gasset file, including the
+derived directories, restore hooks and profiles referring to it. The
+hashes of its files are moved in the lock files and the local state, as
+are its local thumbnails. The rename is recorded in the .gasset file so
+that the snapshots taken at the old path, and their thumbnails, stay part
+of the history of the directory, and a snapshot is taken at the new path
+tagged with the old one.`,
+	Args: cobra.ExactArgs(2),
+	RunE: MvRun,
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+
+	addTimeoutFlag(mvCmd)
+}
+
+func MvRun(cmd *cobra.Command, args []string) error {
+	log.Println("mv called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return moveDir(ctx, options, args[0], args[1], newAuditRecord(cmd, options, args), cmd.OutOrStdout())
+}
+
+func moveDir(ctx context.Context, op *util.Options, oldDir string, newDir string, record *util.AuditRecord, w io.Writer) error {
+	index := slices.IndexFunc(op.Config.Dirs, func(configured string) bool {
+		return filepath.Clean(configured) == filepath.Clean(oldDir)
+	})
+	if index < 0 {
+		return fmt.Errorf("%s is not an asset directory of the .gasset file", oldDir)
+	}
+	oldDir = op.Config.Dirs[index]
+	if filepath.IsAbs(newDir) {
+		rel, err := filepath.Rel(op.WorkingDirectory, newDir)
+		if err != nil {
+			return err
+		}
+		newDir = rel
+	}
+	newDir = configDirPath(oldDir, newDir)
+
+	oldPath := filepath.Join(op.WorkingDirectory, oldDir)
+	newPath := filepath.Join(op.WorkingDirectory, newDir)
+	if rel, err := filepath.Rel(op.WorkingDirectory, newPath); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is not inside the git repository", newDir)
+	}
+	if _, err := os.Lstat(newPath); !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s exists already", newDir)
+	}
+
+	// The config is checked before anything is moved on disk
+	if err := op.Clone().Config.RenameDir(oldDir, newDir); err != nil {
+		return err
+	}
+
+	if err := op.Config.Permissions.MkdirAll(filepath.Dir(newPath)); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if err := util.RenameDirInConfig(op.WorkingDirectory, oldDir, newDir); err != nil {
+		if rollbackErr := os.Rename(newPath, oldPath); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("could not move %s back to %s: %w", newDir, oldDir, rollbackErr))
+		}
+		return err
+	}
+	if err := op.Config.RenameDir(oldDir, newDir); err != nil {
+		return err
+	}
+
+	if err := renameRecordedHashes(op, oldDir, newDir); err != nil {
+		log.Printf("Warning: could not move the recorded hashes of %s, run snap to record them again: %v", oldDir, err)
+	}
+	if err := renameThumbnails(op, oldDir, newDir); err != nil {
+		log.Printf("Warning: could not move the local thumbnails of %s, the next snap generates them again: %v", oldDir, err)
+	}
+	summary.Add("moved", 1)
+
+	snapshotId, err := snapshotMovedDir(ctx, op, oldDir, newDir, record)
+	if err != nil {
+		return fmt.Errorf("%s was moved to %s but could not be snapshotted, run snap to take the snapshot: %w", oldDir, newDir, err)
+	}
+	fmt.Fprintln(w, util.T("Moved %s to %s as snapshot %s", oldDir, newDir, snapshotId))
+	return nil
+}
+
+// configDirPath returns the path of a directory written like the configured one, i.e. with a leading ./ if it has one
+func configDirPath(configured string, dir string) string {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if strings.HasPrefix(configured, "./") {
+		return "./" + dir
+	}
+	return dir
+}
+
+// renameRecordedHashes moves the hashes of the files of a moved directory in the lock files and the local state
+func renameRecordedHashes(op *util.Options, oldDir string, newDir string) error {
+	lockDirs := []string{op.WorkingDirectory}
+	if op.Config.LockFilePerDir {
+		lockDirs = append(lockDirs, filepath.Join(op.WorkingDirectory, newDir))
+	}
+	for _, lockDir := range lockDirs {
+		lock, err := util.LoadLockFile(lockDir)
+		if err != nil {
+			return err
+		}
+		if lock.WorkingHashes == nil {
+			continue
+		}
+		lock.WorkingHashes.RenameDir(oldDir, newDir)
+		if err := util.SaveLockFile(lockDir, lock, op.Config.Permissions); err != nil {
+			return err
+		}
+	}
+
+	if op.Config.WorkingHashes == nil {
+		return nil
+	}
+	hashes, err := op.LoadWorkingHashes()
+	if err != nil {
+		return err
+	}
+	hashes.RenameDir(oldDir, newDir)
+	return op.SaveWorkingHashes(hashes)
+}
+
+// renameThumbnails moves the local thumbnails of a moved directory so that the unchanged images are not generated again
+func renameThumbnails(op *util.Options, oldDir string, newDir string) error {
+	oldPath, err := op.GetThumbnailDirectory(oldDir)
+	if err != nil {
+		return err
+	}
+	newPath, err := op.GetThumbnailDirectory(newDir)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(oldPath); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o700); err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// snapshotMovedDir takes the first snapshot of a directory at its new path, tagged with the old one
+func snapshotMovedDir(ctx context.Context, op *util.Options, oldDir string, newDir string, record *util.AuditRecord) (string, error) {
+	moveOptions := op.Clone()
+	moveOptions.Config.Dirs = []string{newDir}
+
+	skipIdentical := false
+	settings := defaultSnapSettings(op)
+	settings.skipIdentical = &skipIdentical
+	settings.tags = map[string]string{util.TagMovedFrom: oldDir}
+
+	if _, err := snapshotReconnecting(ctx, moveOptions, record, settings); err != nil {
+		return "", err
+	}
+	if len(record.Manifests) == 0 {
+		return "", errors.New("the snapshot was not saved")
+	}
+	return string(record.Manifests[len(record.Manifests)-1]), nil
+}
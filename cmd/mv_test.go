@@ -0,0 +1,136 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type MvSuite struct {
+	repoSuite
+}
+
+func TestMvSuite(t *testing.T) {
+	suite.Run(t, new(MvSuite))
+}
+
+func (suite *MvSuite) Test_moveDir() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	if err := util.UpdateConfig(filepath.Join(suite.options.WorkingDirectory, ".gasset"), suite.options.Config); err != nil {
+		suite.T().FailNow()
+	}
+
+	tests := []struct {
+		name        string
+		from        string
+		to          string
+		wantErr     assert.ErrorAssertionFunc
+		wantFile    string
+		wantDirs    []string
+		wantRenames []util.DirRename
+	}{
+		{
+			name:     "Fail on a directory which is not an asset directory",
+			from:     "./sounds",
+			to:       "./music",
+			wantErr:  assert.Error,
+			wantFile: "assets/a.txt",
+			wantDirs: []string{"./assets"},
+		},
+		{
+			name:        "Move an asset directory",
+			from:        "assets",
+			to:          "art/assets",
+			wantErr:     assert.NoError,
+			wantFile:    "art/assets/a.txt",
+			wantDirs:    []string{"./art/assets"},
+			wantRenames: []util.DirRename{{From: "./assets", To: "./art/assets"}},
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			var output bytes.Buffer
+			tt.wantErr(suite.T(), moveDir(ctx, suite.options, tt.from, tt.to, suite.options.NewAuditRecord("mv", nil), &output))
+			assert.FileExists(suite.T(), filepath.Join(suite.options.WorkingDirectory, filepath.FromSlash(tt.wantFile)))
+
+			config, err := util.GetConfig(suite.options.WorkingDirectory)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+			assert.Equal(suite.T(), tt.wantDirs, config.Dirs)
+			assert.Equal(suite.T(), tt.wantRenames, config.Renames)
+		})
+	}
+	assert.NoDirExists(suite.T(), filepath.Join(suite.options.WorkingDirectory, "assets"))
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+
+	// The snapshot at the old path stays part of the history
+	manifests, err := listDirSnapshots(ctx, suite.options, rep, "./art/assets")
+	if !assert.NoError(suite.T(), err) || !assert.Len(suite.T(), manifests, 2) {
+		return
+	}
+	for _, man := range manifests {
+		if man.Source.Path == suite.options.SourcePath("./art/assets") {
+			assert.Equal(suite.T(), "./assets", man.Tags[util.TagMovedFrom])
+		} else {
+			assert.Equal(suite.T(), suite.options.SourcePath("./assets"), man.Source.Path)
+		}
+	}
+}
+
+func (suite *MvSuite) Test_renameThumbnails() {
+	oldDir, err := suite.options.GetThumbnailDirectory("./assets")
+	if err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.MkdirAll(oldDir, 0o700); err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(oldDir, "wall.png"+util.ThumbnailExtension), []byte("thumbnail"), 0o600); err != nil {
+		suite.T().FailNow()
+	}
+
+	if assert.NoError(suite.T(), renameThumbnails(suite.options, "./assets", "./art/assets")) {
+		newDir, err := suite.options.GetThumbnailDirectory("./art/assets")
+		assert.NoError(suite.T(), err)
+		assert.FileExists(suite.T(), filepath.Join(newDir, "wall.png"+util.ThumbnailExtension))
+		assert.NoDirExists(suite.T(), oldDir)
+	}
+
+	// A directory without thumbnails has nothing to move
+	assert.NoError(suite.T(), renameThumbnails(suite.options, "./sounds", "./music"))
+}
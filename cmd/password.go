@@ -0,0 +1,147 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// passwordCmd represents the password command
+var passwordCmd = &cobra.Command{
+	Use:   "password",
+	Short: "Keeps the password of the repository in the keyring of the os",
+	Long: `Keeps the password of the repository in the keyring of the os.
+
+The password stored in the keyring, i.e. the keychain of macOS, the
+credential manager of windows or the secret service of linux, is used
+instead of the KOPIA_PASSWORD of the .env file. It is stored by the gasset
+id of the .gasset file so every repository has its own. Without a
+keyring, e.g. on a CI runner, the password is read from the .env file.`,
+}
+
+// passwordStoreCmd represents the password store command
+var passwordStoreCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Stores the password of the repository in the keyring",
+	Long: `Stores the password of the repository in the keyring.
+
+The password is prompted for on a terminal and read from the first line of
+the standard input otherwise. Once stored, KOPIA_PASSWORD can be removed
+from the .env file.`,
+	Args: cobra.NoArgs,
+	RunE: PasswordStoreRun,
+}
+
+// passwordForgetCmd represents the password forget command
+var passwordForgetCmd = &cobra.Command{
+	Use:   "forget",
+	Short: "Removes the password of the repository from the keyring",
+	Args:  cobra.NoArgs,
+	RunE:  PasswordForgetRun,
+}
+
+func init() {
+	rootCmd.AddCommand(passwordCmd)
+	passwordCmd.AddCommand(passwordStoreCmd)
+	passwordCmd.AddCommand(passwordForgetCmd)
+}
+
+func PasswordStoreRun(cmd *cobra.Command, _ []string) error {
+	log.Println("password store called")
+
+	options, err := keyringOptions()
+	if err != nil {
+		return err
+	}
+
+	if isTerminal(cmd.InOrStdin()) {
+		if err := checkInteractive(cmd, "the password", "pipe it into stdin"); err != nil {
+			return err
+		}
+	}
+	password, err := readPassword(cmd.InOrStdin(), cmd.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+	if err := options.StorePassword(password); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), util.T("Stored the password of the repository %s in the keyring", options.Config.GassetId))
+	return nil
+}
+
+func PasswordForgetRun(cmd *cobra.Command, _ []string) error {
+	log.Println("password forget called")
+
+	options, err := keyringOptions()
+	if err != nil {
+		return err
+	}
+	if err := options.ForgetPassword(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), util.T("Removed the password of the repository %s from the keyring", options.Config.GassetId))
+	return nil
+}
+
+// keyringOptions returns the options with the config of the .gasset file. The secrets are not loaded, the
+// password may not be anywhere else yet.
+func keyringOptions() (*util.Options, error) {
+	options := newOptions()
+	if err := options.InitWorkingDirectory(); err != nil {
+		return nil, err
+	}
+	config, err := util.GetConfig(options.WorkingDirectory)
+	if err != nil {
+		return nil, err
+	}
+	options.Config = config
+	return &options, nil
+}
+
+// readPassword prompts for the password without echoing it if in is a terminal, otherwise it reads the first line of in
+func readPassword(in io.Reader, prompt io.Writer) (string, error) {
+	if isTerminal(in) {
+		fmt.Fprint(prompt, util.T("Password: "))
+		password, err := term.ReadPassword(int(in.(*os.File).Fd()))
+		fmt.Fprintln(prompt)
+		return string(password), err
+	}
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// isTerminal returns whether the stream is a terminal, which the inputs are prompted on and the progress is drawn on
+func isTerminal(stream any) bool {
+	file, ok := stream.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
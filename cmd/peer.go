@@ -0,0 +1,120 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+)
+
+// peerCmd represents the peer command
+var peerCmd = &cobra.Command{
+	Use:   "peer",
+	Short: "Serves the local pack blobs to teammates on the same network",
+	Long: `Serves the local pack blobs to teammates on the same network.
+
+With the peers section of the .gasset file, restore fetches the pack
+blobs of the repository from the peers before the storage, which saves
+the egress of the storage and is faster on the network of an office. The
+pack blobs restore fetches from the storage are kept in a local cache of
+the size of peers.maxCacheBytes, and peer serves that cache over HTTP
+until interrupted. It also answers the UDP discoveries of peers.discover,
+so that the teammates don't have to list each other.
+
+With --discover, peer lists the peers answering the discovery on the
+network and exits instead of serving, e.g. to check a firewall.
+
+The pack blobs are encrypted, peer never reads the repository and needs
+no credentials.`,
+	Args: cobra.NoArgs,
+	RunE: PeerRun,
+}
+
+func init() {
+	rootCmd.AddCommand(peerCmd)
+
+	peerCmd.Flags().Int("port", 0, "Serves on the given port instead of the one of the .gasset file")
+	peerCmd.Flags().Bool("discover", false, "Lists the peers serving the repository on the network instead of serving")
+}
+
+func PeerRun(cmd *cobra.Command, _ []string) error {
+	log.Println("peer called")
+
+	// Only the cached blobs are served so the storage secrets are not needed
+	options := newOptions()
+	if err := options.InitWorkingDirectory(); err != nil {
+		return err
+	}
+	config, err := util.GetConfig(options.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	options.Config = config
+	if config.Peers == nil {
+		return errors.New("the .gasset file has no peers section")
+	}
+
+	port, err := cmd.Flags().GetInt("port")
+	if err != nil {
+		return err
+	}
+	if port <= 0 {
+		port = config.Peers.ServePort()
+	}
+
+	discover, err := cmd.Flags().GetBool("discover")
+	if err != nil {
+		return err
+	}
+	if discover {
+		config.Peers.Port = port
+		return discoverPeers(context.Background(), &options, cmd.OutOrStdout())
+	}
+
+	cache, err := options.OpenPeerCache()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintln(cmd.OutOrStdout(), util.T("Serving the pack blobs of %s to the peers on port %d", config.GassetId, port))
+	return util.ServePeers(ctx, cache, config.GassetId, port)
+}
+
+// discoverPeers prints the addresses of the peers answering the discovery of the repository, one per line
+func discoverPeers(ctx context.Context, op *util.Options, w io.Writer) error {
+	peers, err := op.DiscoverPeers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(peers) == 0 {
+		log.Println("No peer answered the discovery")
+	}
+	for _, peer := range peers {
+		fmt.Fprintln(w, peer)
+	}
+	return nil
+}
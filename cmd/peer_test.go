@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type PeerSuite struct {
+	repoSuite
+}
+
+func TestPeerSuite(t *testing.T) {
+	suite.Run(t, new(PeerSuite))
+}
+
+func (suite *PeerSuite) Test_discoverPeers() {
+	userDir, err := suite.options.OsUserConfigDir()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	suite.T().Cleanup(func() {
+		os.RemoveAll(filepath.Join(userDir, "git-gasset", "peers-"+suite.options.Config.GassetId))
+	})
+
+	listener, err := net.Listen("tcp4", ":0")
+	if err != nil {
+		suite.T().FailNow()
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cache, err := suite.options.OpenPeerCache()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go util.ServePeers(ctx, cache, suite.options.Config.GassetId, port)
+
+	suite.options.Config.Peers = &util.PeerOptions{Port: port}
+	assert.Eventually(suite.T(), func() bool {
+		var output bytes.Buffer
+		if err := discoverPeers(ctx, suite.options, &output); err != nil {
+			return false
+		}
+		return strings.HasSuffix(strings.TrimSpace(output.String()), ":"+strconv.Itoa(port))
+	}, 5*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// policyCmd represents the policy command
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Manages the policy of the repository",
+}
+
+// policySetCmd represents the policy set command
+var policySetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Changes the retention of the snapshots",
+	Long: `Changes the retention of the snapshots.
+
+Only the given --keep-* flags are changed in the global policy, the others
+keep their value. Before the policy is changed, the snapshots of all the
+asset directories which would become eligible for deletion by the next
+prune are listed and the change has to be confirmed like a deletion, as
+a retention mistake cannot be undone once prune ran. With --dry-run the
+snapshots are listed without changing the policy.`,
+	Args: cobra.NoArgs,
+	RunE: PolicySetRun,
+}
+
+// retentionFlags are the flags of policy set and the retention field each of them changes
+var retentionFlags = []struct {
+	name  string
+	usage string
+	field func(r *policy.RetentionPolicy) **policy.OptionalInt
+}{
+	{"keep-latest", "Number of latest snapshots to keep", func(r *policy.RetentionPolicy) **policy.OptionalInt { return &r.KeepLatest }},
+	{"keep-hourly", "Number of most recent hourly snapshots to keep", func(r *policy.RetentionPolicy) **policy.OptionalInt { return &r.KeepHourly }},
+	{"keep-daily", "Number of most recent daily snapshots to keep", func(r *policy.RetentionPolicy) **policy.OptionalInt { return &r.KeepDaily }},
+	{"keep-weekly", "Number of most recent weekly snapshots to keep", func(r *policy.RetentionPolicy) **policy.OptionalInt { return &r.KeepWeekly }},
+	{"keep-monthly", "Number of most recent monthly snapshots to keep", func(r *policy.RetentionPolicy) **policy.OptionalInt { return &r.KeepMonthly }},
+	{"keep-annual", "Number of most recent annual snapshots to keep", func(r *policy.RetentionPolicy) **policy.OptionalInt { return &r.KeepAnnual }},
+}
+
+// errPolicyNotChanged discards the write session of policy set without changing the policy
+var errPolicyNotChanged = errors.New("policy not changed")
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policySetCmd)
+
+	for _, flag := range retentionFlags {
+		policySetCmd.Flags().Int(flag.name, 0, flag.usage)
+	}
+	policySetCmd.Flags().Bool("dry-run", false, "Lists the snapshots which would become eligible for deletion without changing the policy")
+	addConfirmFlags(policySetCmd)
+	addTimeoutFlag(policySetCmd)
+}
+
+func PolicySetRun(cmd *cobra.Command, _ []string) error {
+	log.Println("policy set called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	var retention policy.RetentionPolicy
+	for _, flag := range retentionFlags {
+		if !cmd.Flags().Changed(flag.name) {
+			continue
+		}
+		n, err := cmd.Flags().GetInt(flag.name)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("--%s cannot be negative", flag.name)
+		}
+		value := policy.OptionalInt(n)
+		*flag.field(&retention) = &value
+	}
+	if retention == (policy.RetentionPolicy{}) {
+		return errors.New("at least one of the --keep-* flags is required")
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	confirm := func(eligible int) error {
+		return confirmDestructive(cmd, options, fmt.Sprintf("make %d snapshots eligible for deletion by the next prune", eligible))
+	}
+	return setRetention(ctx, options, retention, dryRun, confirm, cmd.OutOrStdout(), newAuditRecord(cmd, options, nil))
+}
+
+// setRetention changes the given fields of the retention in the global policy.
+// The change is only saved if no snapshot becomes eligible for deletion or confirm accepts the ones that do.
+func setRetention(ctx context.Context, op *util.Options, retention policy.RetentionPolicy, dryRun bool, confirm func(eligible int) error, w io.Writer, record *util.AuditRecord) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	var eligible int
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Set retention policy"),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		expired, err := expiredSnapshots(ctx, op, writer)
+		if err != nil {
+			return err
+		}
+		expiredBefore := map[manifest.ID]bool{}
+		for _, man := range expired {
+			expiredBefore[man.ID] = true
+		}
+
+		global, err := policy.GetDefinedPolicy(ctx, writer, policy.GlobalPolicySourceInfo)
+		if errors.Is(err, policy.ErrPolicyNotFound) {
+			global = &policy.Policy{}
+		} else if err != nil {
+			return err
+		}
+		for _, flag := range retentionFlags {
+			if value := *flag.field(&retention); value != nil {
+				*flag.field(&global.RetentionPolicy) = value
+			}
+		}
+		if err := op.PolicySetPolicy(ctx, writer, policy.GlobalPolicySourceInfo, global); err != nil {
+			return err
+		}
+
+		// The writer reads its own pending policy so the retention is evaluated as prune would after the change
+		expiredAfter, err := expiredSnapshots(ctx, op, writer)
+		if err != nil {
+			return err
+		}
+		for _, man := range expiredAfter {
+			if expiredBefore[man.ID] {
+				continue
+			}
+			eligible++
+			fmt.Fprintf(w, "%s\t%s\t%s\n", man.ID, man.Source, util.FormatTime(man.StartTime.ToTime(), op.LocalTime))
+		}
+
+		if dryRun {
+			return errPolicyNotChanged
+		}
+		if eligible > 0 {
+			if err := confirm(eligible); err != nil {
+				return err
+			}
+		}
+		return util.WriteAuditRecord(ctx, writer, record)
+	})
+	if errors.Is(err, errPolicyNotChanged) {
+		fmt.Fprintln(w, util.T("%d snapshots would become eligible for deletion, the policy was not changed", eligible))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	summary.Add("eligible", eligible)
+	fmt.Fprintln(w, util.T("Changed the retention, %d snapshots become eligible for deletion by the next prune", eligible))
+	return nil
+}
+
+// expiredSnapshots returns the snapshots of all the asset directories which prune would delete, oldest first per source
+func expiredSnapshots(ctx context.Context, op *util.Options, rep repo.Repository) ([]*snapshot.Manifest, error) {
+	var expired []*snapshot.Manifest
+	for _, dir := range op.Config.Dirs {
+		sources, err := listDirSources(ctx, op, rep, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range sources {
+			manifests, err := snapshot.ListSnapshots(ctx, rep, source)
+			if err != nil {
+				return nil, err
+			}
+			effective, _, _, err := policy.GetEffectivePolicy(ctx, rep, source)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+			effective.RetentionPolicy.ComputeRetentionReasons(manifests)
+			for _, man := range snapshot.SortByTime(manifests, false) {
+				if len(man.RetentionReasons) == 0 && len(man.Pins) == 0 {
+					expired = append(expired, man)
+				}
+			}
+		}
+	}
+	return expired, nil
+}
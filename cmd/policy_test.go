@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"git-gasset/util"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"testing"
+)
+
+type PolicySuite struct {
+	repoSuite
+}
+
+func TestPolicySuite(t *testing.T) {
+	suite.Run(t, new(PolicySuite))
+}
+
+func (suite *PolicySuite) Test_setRetention() {
+	ctx := context.Background()
+	skipIdentical := false
+	settings := snapSettings{skipIdentical: &skipIdentical}
+	for i := 0; i < 3; i++ {
+		if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings); err != nil {
+			suite.T().FailNow()
+		}
+	}
+
+	keepOne := policy.OptionalInt(1)
+	none := policy.OptionalInt(0)
+	retention := policy.RetentionPolicy{KeepLatest: &keepOne, KeepHourly: &none, KeepDaily: &none, KeepWeekly: &none, KeepMonthly: &none, KeepAnnual: &none}
+	var confirmed []int
+	confirm := func(eligible int) error {
+		confirmed = append(confirmed, eligible)
+		return errors.New("declined")
+	}
+
+	var out bytes.Buffer
+	assert.NoError(suite.T(), setRetention(ctx, suite.options, retention, true, confirm, &out, suite.options.NewAuditRecord("policy set", nil)))
+	assert.Contains(suite.T(), out.String(), "2 snapshots would become eligible for deletion")
+	assert.Empty(suite.T(), confirmed, "confirm() on a dry run")
+
+	assert.Error(suite.T(), setRetention(ctx, suite.options, retention, false, confirm, io.Discard, suite.options.NewAuditRecord("policy set", nil)))
+	assert.Equal(suite.T(), []int{2}, confirmed)
+	if !assert.NoError(suite.T(), prune(ctx, suite.options, false, util.MaintenanceLockOptions{}, io.Discard, suite.options.NewAuditRecord("prune", nil))) {
+		return
+	}
+	assert.Equal(suite.T(), 3, suite.snapshotCount(ctx), "snapshots after a declined change")
+
+	confirm = func(eligible int) error {
+		return nil
+	}
+	assert.NoError(suite.T(), setRetention(ctx, suite.options, retention, false, confirm, io.Discard, suite.options.NewAuditRecord("policy set", nil)))
+	if !assert.NoError(suite.T(), prune(ctx, suite.options, false, util.MaintenanceLockOptions{}, io.Discard, suite.options.NewAuditRecord("prune", nil))) {
+		return
+	}
+	assert.Equal(suite.T(), 1, suite.snapshotCount(ctx), "snapshots after a confirmed change")
+}
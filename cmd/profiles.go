@@ -0,0 +1,155 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+)
+
+// profilesCmd represents the profiles command
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manages the restore profiles",
+}
+
+// profilesShowCmd represents the profiles show command
+var profilesShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Prints the profiles with the size they restore",
+	Long: `Prints the profiles with the size they restore.
+
+Profiles are named sets of asset directories and globs in the profiles
+key of the .gasset file. The size of a profile is the total size of the
+files it selects in the latest snapshot of every asset directory, so that
+the smallest profile fitting the disk can be picked.`,
+	Args: cobra.NoArgs,
+	RunE: ProfilesShowRun,
+}
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+	profilesCmd.AddCommand(profilesShowCmd)
+
+	profilesShowCmd.Flags().Bool("json", false, "Prints the profiles as JSON")
+}
+
+func ProfilesShowRun(cmd *cobra.Command, _ []string) error {
+	log.Println("profiles show called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	return showProfiles(context.Background(), options, asJson, cmd.OutOrStdout())
+}
+
+func showProfiles(ctx context.Context, op *util.Options, asJson bool, w io.Writer) error {
+	sizes, err := profileSizes(ctx, op)
+	if err != nil {
+		return err
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sizes)
+	}
+
+	if len(sizes) == 0 {
+		fmt.Fprintln(w, util.T("No profiles are configured in the .gasset file"))
+		return nil
+	}
+	for _, size := range sizes {
+		fmt.Fprintf(w, "%s\t%s\t%d files\n", size.Name, util.FormatBytes(size.Bytes), size.Files)
+		for _, pattern := range size.Patterns {
+			fmt.Fprintf(w, "  %s\n", pattern)
+		}
+	}
+	return nil
+}
+
+// profileSizes walks the latest snapshot of every asset directory once and adds its files to the profiles selecting them
+func profileSizes(ctx context.Context, op *util.Options) ([]*util.ProfileSize, error) {
+	sizes := make([]*util.ProfileSize, 0, len(op.Config.Profiles))
+	for _, name := range op.Config.ProfileNames() {
+		sizes = append(sizes, &util.ProfileSize{Name: name, Patterns: op.Config.Profiles[name]})
+	}
+	if len(sizes) == 0 {
+		return sizes, nil
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Close(ctx)
+
+	for _, dir := range op.Config.Dirs {
+		manifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return nil, err
+		}
+		latest := latestCompleteSnapshot(manifests)
+		if latest == nil {
+			log.Printf("%s has no snapshot, it is not part of the sizes", dir)
+			continue
+		}
+
+		root, err := snapshotfs.SnapshotRoot(rep, latest)
+		if err != nil {
+			return nil, err
+		}
+		rootDir, ok := root.(fs.Directory)
+		if !ok {
+			continue
+		}
+
+		dirPath := path.Clean(filepath.ToSlash(dir)) + "/"
+		err = util.WalkSnapshotFiles(ctx, rootDir, dirPath, func(filePath string, entry fs.Entry) error {
+			for _, size := range sizes {
+				size.AddFile(filePath, entry.Size())
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sizes, nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Applies the retention policy to the snapshots",
+	Long: `Applies the retention policy to the snapshots.
+
+The snapshots of all the asset directories, taken by any user or machine,
+which are no longer retained by the retention policy are deleted. Meant to
+be scheduled when snap defers the retention with --defer-retention or the
+deferRetention key of the .gasset file, so that deletions happen in a
+controlled window. Only one user can prune or purge at a time, the
+maintenance lock of a command which died expires after --lock-ttl or can be
+removed with --steal-lock. The content of the deleted snapshots is dropped from the
+storage by the next full maintenance. Archival repositories refuse to prune
+unless --override-archival is passed.`,
+	Args: cobra.NoArgs,
+	RunE: PruneRun,
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().Bool("dry-run", false, "Lists the snapshots which would be deleted without deleting them")
+	addOverrideArchivalFlag(pruneCmd)
+	addMaintenanceLockFlags(pruneCmd)
+	addConfirmFlags(pruneCmd)
+	addTimeoutFlag(pruneCmd)
+}
+
+func PruneRun(cmd *cobra.Command, _ []string) error {
+	log.Println("prune called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	if !dryRun {
+		if err := checkArchival(cmd, options); err != nil {
+			return err
+		}
+		if err := confirmDestructive(cmd, options, "delete the snapshots no longer retained by the retention policy"); err != nil {
+			return err
+		}
+	}
+
+	lockOptions, err := maintenanceLockOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return prune(ctx, options, dryRun, lockOptions, cmd.OutOrStdout(), newAuditRecord(cmd, options, nil))
+}
+
+func prune(ctx context.Context, op *util.Options, dryRun bool, lockOptions util.MaintenanceLockOptions, w io.Writer, record *util.AuditRecord) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	if !dryRun {
+		release, err := acquireMaintenanceLock(ctx, op, rep, record.Command, lockOptions)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	var expired []manifest.ID
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Apply retention policy"),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		for _, dir := range op.Config.Dirs {
+			sources, err := listDirSources(ctx, op, writer, dir)
+			if err != nil {
+				return err
+			}
+			for _, source := range sources {
+				sourceExpired, err := policy.ApplyRetentionPolicy(ctx, writer, source, !dryRun)
+				if err != nil {
+					return fmt.Errorf("%s: %w", source, err)
+				}
+				for _, id := range sourceExpired {
+					fmt.Fprintf(w, "%s\t%s\n", id, source)
+				}
+				expired = append(expired, sourceExpired...)
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+		record.Manifests = expired
+		return util.WriteAuditRecord(ctx, writer, record)
+	})
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		summary.Add("expired", len(expired))
+		fmt.Fprintln(w, util.T("%d snapshots would be deleted, nothing was deleted", len(expired)))
+	} else {
+		summary.Add("deleted", len(expired))
+		fmt.Fprintln(w, util.T("Deleted %d snapshots, their content is dropped from the storage by the next full maintenance", len(expired)))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+type PruneSuite struct {
+	repoSuite
+}
+
+func TestPruneSuite(t *testing.T) {
+	suite.Run(t, new(PruneSuite))
+}
+
+func (suite *PruneSuite) Test_prune() {
+	ctx := context.Background()
+	suite.keepLatest(ctx, 1)
+	skipIdentical := false
+	settings := snapSettings{deferRetention: true, skipIdentical: &skipIdentical}
+	for i := 0; i < 3; i++ {
+		if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings); err != nil {
+			suite.T().FailNow()
+		}
+	}
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		wantExpired int
+		wantLast    string
+		wantCount   int
+	}{
+		{
+			name:        "List the snapshots no longer retained on a dry run",
+			dryRun:      true,
+			wantExpired: 2,
+			wantLast:    "2 snapshots would be deleted, nothing was deleted",
+			wantCount:   3,
+		},
+		{
+			name:        "Delete the snapshots no longer retained",
+			wantExpired: 2,
+			wantLast:    "Deleted 2 snapshots, their content is dropped from the storage by the next full maintenance",
+			wantCount:   1,
+		},
+		{
+			name:      "Delete nothing once the retention is applied",
+			wantLast:  "Deleted 0 snapshots, their content is dropped from the storage by the next full maintenance",
+			wantCount: 1,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			w := &bytes.Buffer{}
+			if !assert.NoError(suite.T(), prune(ctx, suite.options, tt.dryRun, util.MaintenanceLockOptions{}, w, suite.options.NewAuditRecord("prune", nil))) {
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(w.String()), "\n")
+			assert.Equal(suite.T(), tt.wantLast, lines[len(lines)-1])
+			assert.Len(suite.T(), lines, tt.wantExpired+1, "a line per expired snapshot and the summary")
+			assert.Equal(suite.T(), tt.wantCount, suite.snapshotCount(ctx))
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"slices"
+)
+
+// publishCmd represents the publish command
+var publishCmd = &cobra.Command{
+	Use:   "publish --channel <name> [snapshot-id...]",
+	Short: "Publishes snapshots to a channel",
+	Long: `Publishes snapshots to a channel.
+
+A channel, e.g. stable or beta, is a curated stream of asset drops for
+consumers who don't want every intermediate snapshot. The latest complete
+snapshot of every asset directory is published, or only the given
+snapshots while the other directories keep the ones of the previous
+release of the channel. The release is recorded in the repository and
+restore --channel restores it.
+
+The published snapshots are pinned with channel-<name> so that the
+retention policy never deletes a release.`,
+	RunE: PublishRun,
+}
+
+func init() {
+	rootCmd.AddCommand(publishCmd)
+
+	publishCmd.Flags().String("channel", "", "The channel to publish to, e.g. stable")
+	addTimeoutFlag(publishCmd)
+}
+
+func PublishRun(cmd *cobra.Command, args []string) error {
+	log.Println("publish called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	if options.Sandbox != "" {
+		return fmt.Errorf("the snapshots of the sandbox %s can't be published to a channel of the team", options.Sandbox)
+	}
+
+	channel, err := cmd.Flags().GetString("channel")
+	if err != nil {
+		return err
+	}
+	if channel == "" {
+		return errors.New("the channel to publish to is missing, e.g. --channel stable")
+	}
+	if err := util.CheckChannelName(channel); err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return publish(ctx, options, channel, args, newAuditRecord(cmd, options, args), cmd.OutOrStdout())
+}
+
+func publish(ctx context.Context, op *util.Options, channel string, snapshotIds []string, record *util.AuditRecord, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	release := op.NewChannelRelease(channel)
+	return op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Publish to the " + channel + " channel"),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		targets, err := publishTargets(ctx, op, writer, channel, snapshotIds)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return errors.New("there is no snapshot to publish, run snap first")
+		}
+
+		pin := util.ChannelPin(channel)
+		for _, target := range targets {
+			if !slices.Contains(target.manifest.Pins, pin) {
+				// Updating the pins saves the snapshot under a new id
+				target.manifest.Pins = append(target.manifest.Pins, pin)
+				if err := snapshot.UpdateSnapshot(ctx, writer, target.manifest); err != nil {
+					return fmt.Errorf("could not pin snapshot %s of %s: %w", target.manifest.ID, target.dir, err)
+				}
+			}
+			release.Snapshots[target.dir] = target.manifest.ID
+			record.Manifests = append(record.Manifests, target.manifest.ID)
+		}
+
+		if err := util.WriteChannelRelease(ctx, writer, release); err != nil {
+			return err
+		}
+		record.Manifests = append(record.Manifests, release.ID)
+		if err := util.WriteAuditRecord(ctx, writer, record); err != nil {
+			return err
+		}
+
+		for _, target := range targets {
+			fmt.Fprintln(w, util.T("Published %s to the %s channel as snapshot %s", target.dir, channel, target.manifest.ID))
+		}
+		summary.Add("published", len(targets))
+		return nil
+	})
+}
+
+// publishTargets returns the given snapshots on top of the previous release of the channel,
+// or the latest snapshot of every asset directory without any
+func publishTargets(ctx context.Context, op *util.Options, rep repo.Repository, channel string, snapshotIds []string) ([]restoreTarget, error) {
+	if len(snapshotIds) == 0 {
+		return restoreTargets(ctx, op, rep, "", "")
+	}
+
+	published := map[string]*snapshot.Manifest{}
+	releases, err := util.ListChannelReleases(ctx, rep, channel)
+	if err != nil {
+		return nil, err
+	}
+	if len(releases) > 0 {
+		targets, err := releaseTargets(ctx, op, rep, releases[len(releases)-1])
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range targets {
+			published[target.dir] = target.manifest
+		}
+	}
+	for _, snapshotId := range snapshotIds {
+		man, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(snapshotId))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", snapshotId, err)
+		}
+		if man.IncompleteReason != "" {
+			return nil, fmt.Errorf("snapshot %s is incomplete: %s", snapshotId, man.IncompleteReason)
+		}
+		dir, err := snapshotAssetDir(op, man)
+		if err != nil {
+			return nil, err
+		}
+		published[dir] = man
+	}
+
+	var targets []restoreTarget
+	for _, dir := range op.Config.Dirs {
+		if man, ok := published[dir]; ok {
+			targets = append(targets, restoreTarget{dir: dir, manifest: man})
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type PublishSuite struct {
+	repoSuite
+}
+
+func TestPublishSuite(t *testing.T) {
+	suite.Run(t, new(PublishSuite))
+}
+
+func (suite *PublishSuite) Test_publish() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+
+	publishes := []struct {
+		name       string
+		channel    string
+		change     string
+		wantOutput string
+	}{
+		{
+			name:       "Publish the latest snapshots",
+			channel:    "stable",
+			wantOutput: "to the stable channel",
+		},
+		{
+			name:       "Publish a newer snapshot to another channel",
+			channel:    "beta",
+			change:     "changed",
+			wantOutput: "to the beta channel",
+		},
+	}
+	for _, tt := range publishes {
+		suite.Run(tt.name, func() {
+			var snapshotIds []string
+			if tt.change != "" {
+				if err := os.WriteFile(filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt"), []byte(tt.change), 0o644); err != nil {
+					suite.T().FailNow()
+				}
+				record := suite.options.NewAuditRecord("snap", nil)
+				if _, err := createSnapshot(ctx, suite.options, record, snapSettings{}); err != nil || len(record.Manifests) == 0 {
+					suite.T().FailNow()
+				}
+				snapshotIds = []string{string(record.Manifests[len(record.Manifests)-1])}
+			}
+
+			var output bytes.Buffer
+			if assert.NoError(suite.T(), publish(ctx, suite.options, tt.channel, snapshotIds, suite.options.NewAuditRecord("publish", nil), &output)) {
+				assert.Contains(suite.T(), output.String(), tt.wantOutput)
+			}
+		})
+	}
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+
+	tests := []struct {
+		name    string
+		channel string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Restore the stable channel",
+			channel: "stable",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Restore the beta channel",
+			channel: "beta",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Fail on a channel never published to",
+			channel: "nightly",
+			wantErr: assert.Error,
+		},
+	}
+	roots := map[string]object.ID{}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			targets, err := restoreTargets(ctx, suite.options, rep, "", tt.channel)
+			if !tt.wantErr(suite.T(), err) || err != nil || !assert.Len(suite.T(), targets, 1) {
+				return
+			}
+			// Both drops are kept from the retention policy
+			assert.Equal(suite.T(), []string{util.ChannelPin(tt.channel)}, targets[0].manifest.Pins)
+			roots[tt.channel] = targets[0].manifest.RootObjectID()
+		})
+	}
+	assert.NotEqual(suite.T(), roots["stable"], roots["beta"], "the beta drop is newer than the stable one")
+}
@@ -0,0 +1,319 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"slices"
+	"strings"
+)
+
+// purgeFileCmd represents the purge-file command
+var purgeFileCmd = &cobra.Command{
+	Use:   "purge-file <path>",
+	Short: "Removes a file from all the snapshots",
+	Long: `Removes a file from all the snapshots.
+
+Meant for licensed or confidential material snapshotted by mistake. The
+content of the file at the given path is looked up in every snapshot of
+its asset directory and every entry with that content is removed, so
+copies of the file under other names are removed as well, and so is the
+thumbnail of an image. The snapshot manifests are rewritten and the
+content itself is dropped from the storage by the next full maintenance. Only one user can prune or purge at
+a time, the maintenance lock of a command which died expires after
+--lock-ttl or can be removed with --steal-lock. Archival repositories refuse to
+purge unless --override-archival is passed.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSnapshotPaths,
+	RunE:              PurgeFileRun,
+}
+
+func init() {
+	rootCmd.AddCommand(purgeFileCmd)
+
+	purgeFileCmd.Flags().Bool("dry-run", false, "Only reports the snapshots containing the file without rewriting them")
+	addOverrideArchivalFlag(purgeFileCmd)
+	addMaintenanceLockFlags(purgeFileCmd)
+	addConfirmFlags(purgeFileCmd)
+}
+
+func PurgeFileRun(cmd *cobra.Command, args []string) error {
+	log.Println("purge-file called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	if !dryRun {
+		if err := checkArchival(cmd, options); err != nil {
+			return err
+		}
+		if err := confirmDestructive(cmd, options, "remove "+args[0]+" from all the snapshots"); err != nil {
+			return err
+		}
+	}
+
+	lockOptions, err := maintenanceLockOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return purgeFile(ctx, options, args[0], dryRun, lockOptions, cmd.OutOrStdout(), newAuditRecord(cmd, options, args))
+}
+
+func purgeFile(ctx context.Context, op *util.Options, assetPath string, dryRun bool, lockOptions util.MaintenanceLockOptions, w io.Writer, record *util.AuditRecord) error {
+	dir, relativePath, err := op.AssetDir(assetPath)
+	if err != nil {
+		return err
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	manifests, err := listDirSnapshots(ctx, op, rep, dir)
+	if err != nil {
+		return err
+	}
+
+	purged := map[object.ID]bool{}
+	var affected []*snapshot.Manifest
+	for _, manifest := range manifests {
+		entry, err := findSnapshotEntry(ctx, rep, manifest, relativePath)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+		purged[entry.ObjectID] = true
+		affected = append(affected, manifest)
+	}
+
+	if len(affected) == 0 {
+		fmt.Fprintln(w, util.T("%s is not part of any snapshot", assetPath))
+		return nil
+	}
+
+	// The thumbnail of an image previews it, so it is purged from the snapshots of the thumbnails as well
+	var thumbnailManifests []*snapshot.Manifest
+	if util.IsThumbnailImage(relativePath) {
+		if thumbnailManifests, err = listDirThumbnailSnapshots(ctx, op, rep, dir); err != nil {
+			return err
+		}
+		for _, manifest := range thumbnailManifests {
+			entry, err := findSnapshotEntry(ctx, rep, manifest, relativePath+util.ThumbnailExtension)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				purged[entry.ObjectID] = true
+			}
+		}
+	}
+
+	if dryRun {
+		for _, manifest := range affected {
+			fmt.Fprintf(w, "%s %s\n", manifest.ID, util.FormatTime(manifest.StartTime.ToTime(), op.LocalTime))
+		}
+		fmt.Fprintln(w, util.T("%s is part of %d of %d snapshots, nothing was rewritten", assetPath, len(affected), len(manifests)))
+		return nil
+	}
+
+	release, err := acquireMaintenanceLock(ctx, op, rep, record.Command, lockOptions)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	rewritten := 0
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Purge file " + assetPath),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		rewriter, err := snapshotfs.NewDirRewriter(ctx, writer, snapshotfs.DirRewriterOptions{
+			RewriteEntry: func(ctx context.Context, parentPath string, input *snapshot.DirEntry) (*snapshot.DirEntry, error) {
+				if purged[input.ObjectID] {
+					return nil, nil
+				}
+				return input, nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+		defer rewriter.Close(ctx)
+
+		rewrite := func(manifest *snapshot.Manifest) (bool, error) {
+			changed, err := rewriter.RewriteSnapshotManifest(ctx, manifest)
+			if err != nil || !changed {
+				return false, err
+			}
+			// Both the replaced and the replacing manifest are recorded
+			record.Manifests = append(record.Manifests, manifest.ID)
+			if err := snapshot.UpdateSnapshot(ctx, writer, manifest); err != nil {
+				return false, err
+			}
+			record.Manifests = append(record.Manifests, manifest.ID)
+			return true, nil
+		}
+
+		// Every snapshot of the directory is rewritten since the content may be there under another name
+		for _, manifest := range manifests {
+			changed, err := rewrite(manifest)
+			if err != nil {
+				return err
+			}
+			if changed {
+				rewritten++
+			}
+		}
+		for _, manifest := range thumbnailManifests {
+			if _, err := rewrite(manifest); err != nil {
+				return err
+			}
+		}
+		return util.WriteAuditRecord(ctx, writer, record)
+	})
+	if err != nil {
+		return err
+	}
+
+	summary.Add("rewritten", rewritten)
+	fmt.Fprintln(w, util.T("Removed %s from %d snapshots, its content is dropped from the storage by the next full maintenance", assetPath, rewritten))
+	return nil
+}
+
+// listDirSources returns the snapshot sources of an asset directory of any user or machine
+func listDirSources(ctx context.Context, op *util.Options, rep repo.Repository, dir string) ([]snapshot.SourceInfo, error) {
+	sources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	sourcePaths := dirSourcePaths(op, dir)
+	var dirSources []snapshot.SourceInfo
+	for _, source := range sources {
+		if slices.Contains(sourcePaths, source.Path) {
+			dirSources = append(dirSources, source)
+		}
+	}
+	return dirSources, nil
+}
+
+// dirSourcePaths returns the source paths of an asset directory.
+// The snapshots taken before the directory was moved are part of its history.
+func dirSourcePaths(op *util.Options, dir string) []string {
+	sourcePaths := []string{op.SourcePath(dir)}
+	for _, previous := range op.Config.PreviousDirs(dir) {
+		sourcePaths = append(sourcePaths, op.SourcePath(previous))
+	}
+	return sourcePaths
+}
+
+// listDirSnapshots returns the snapshots of an asset directory taken by any user or machine
+func listDirSnapshots(ctx context.Context, op *util.Options, rep repo.Repository, dir string) ([]*snapshot.Manifest, error) {
+	sources, err := listDirSources(ctx, op, rep, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*snapshot.Manifest
+	for _, source := range sources {
+		sourceManifests, err := snapshot.ListSnapshots(ctx, rep, source)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, sourceManifests...)
+	}
+	return manifests, nil
+}
+
+// listDirThumbnailSnapshots returns the snapshots of the thumbnails of an asset directory taken by any user or machine
+func listDirThumbnailSnapshots(ctx context.Context, op *util.Options, rep repo.Repository, dir string) ([]*snapshot.Manifest, error) {
+	sources, err := listDirSources(ctx, op, rep, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*snapshot.Manifest
+	for _, source := range sources {
+		sourceManifests, err := snapshot.ListSnapshots(ctx, rep, util.ThumbnailSource(source))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, sourceManifests...)
+	}
+	return manifests, nil
+}
+
+// findSnapshotEntry returns the entry at the slash separated path in the snapshot or nil if it does not exist
+func findSnapshotEntry(ctx context.Context, rep repo.Repository, manifest *snapshot.Manifest, relativePath string) (*snapshot.DirEntry, error) {
+	current, err := snapshotfs.SnapshotRoot(rep, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(relativePath, "/") {
+		dir, ok := current.(fs.Directory)
+		if !ok {
+			return nil, nil
+		}
+		current, err = dir.Child(ctx, name)
+		if errors.Is(err, fs.ErrEntryNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entry, ok := current.(snapshot.HasDirEntry)
+	if !ok {
+		return nil, nil
+	}
+	return entry.DirEntry(), nil
+}
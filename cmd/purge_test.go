@@ -0,0 +1,108 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type PurgeSuite struct {
+	repoSuite
+}
+
+func TestPurgeSuite(t *testing.T) {
+	suite.Run(t, new(PurgeSuite))
+}
+
+func (suite *PurgeSuite) Test_purgeFile() {
+	ctx := context.Background()
+	imageBytes := &bytes.Buffer{}
+	if err := png.Encode(imageBytes, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(suite.options.WorkingDirectory, "assets", "wall.png"), imageBytes.Bytes(), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+	suite.options.Config.Thumbnails = &util.ThumbnailOptions{}
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+
+	tests := []struct {
+		name          string
+		dryRun        bool
+		wantImage     bool
+		wantThumbnail bool
+	}{
+		{
+			name:          "Keep the image and its thumbnail in a dry run",
+			dryRun:        true,
+			wantImage:     true,
+			wantThumbnail: true,
+		},
+		{
+			name:          "Purge the image and its thumbnail",
+			wantImage:     false,
+			wantThumbnail: false,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			record := suite.options.NewAuditRecord("purge-file", nil)
+			if !assert.NoError(suite.T(), purgeFile(ctx, suite.options, "assets/wall.png", tt.dryRun, util.MaintenanceLockOptions{}, io.Discard, record)) {
+				return
+			}
+
+			kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+			if err != nil {
+				suite.T().FailNow()
+			}
+			rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+			if err != nil {
+				suite.T().FailNow()
+			}
+			defer rep.Close(ctx)
+
+			manifests, err := listDirSnapshots(ctx, suite.options, rep, "./assets")
+			if !assert.NoError(suite.T(), err) || !assert.Len(suite.T(), manifests, 1) {
+				return
+			}
+			entry, err := findSnapshotEntry(ctx, rep, manifests[0], "wall.png")
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), tt.wantImage, entry != nil)
+
+			thumbnailManifests, err := listDirThumbnailSnapshots(ctx, suite.options, rep, "./assets")
+			if !assert.NoError(suite.T(), err) || !assert.Len(suite.T(), thumbnailManifests, 1) {
+				return
+			}
+			entry, err = findSnapshotEntry(ctx, rep, thumbnailManifests[0], "wall.png"+util.ThumbnailExtension)
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), tt.wantThumbnail, entry != nil)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+)
+
+// quickstartCmd represents the quickstart command
+var quickstartCmd = &cobra.Command{
+	Use:   "quickstart [dir]",
+	Short: "Walks through a first snapshot and restore in a tutorial project",
+	Long: `Walks through a first snapshot and restore in a tutorial project.
+
+Creates a git repository with sample assets in the given directory, or in
+a new one in the temp directory, and runs what init --create, snap and
+restore do against a repository on the local filesystem next to it. A
+sample asset is deleted after the snapshot and restored from it.
+
+The kopia config of the project is kept in the directory too instead of
+the user config directory, so that nothing outside of it is touched. It
+is kept to be explored, delete it when done.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: QuickstartRun,
+}
+
+func init() {
+	rootCmd.AddCommand(quickstartCmd)
+
+	addTimeoutFlag(quickstartCmd)
+}
+
+func QuickstartRun(cmd *cobra.Command, args []string) error {
+	log.Println("quickstart called")
+
+	options := newOptions()
+	tempDirectory, err := cmd.Flags().GetString("temp-dir")
+	if err != nil {
+		return err
+	}
+	options.TempDirectory = tempDirectory
+
+	root := ""
+	if len(args) > 0 {
+		root = args[0]
+	} else if root, err = os.MkdirTemp(options.TempDir(), "gasset-quickstart-"); err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	password := util.GenerateRandomString(24, options.RandIntn)
+	project, err := util.CreateQuickstartProject(root, password)
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	fmt.Fprintln(w, util.T("Created the tutorial project %s with the asset directory %s", project.Path, util.QuickstartDir))
+
+	options.Command = commandName(cmd)
+	if err := runQuickstart(ctx, &options, project, w); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, util.T("The tutorial project is kept in %s, delete it when done", root))
+	return nil
+}
+
+// runQuickstart creates the repository of the quickstart project, snapshots its assets, deletes one and restores it
+func runQuickstart(ctx context.Context, op *util.Options, project *util.QuickstartProject, w io.Writer) error {
+	op.OsGetwd = func() (string, error) {
+		return project.Path, nil
+	}
+	op.OsUserConfigDir = func() (string, error) {
+		return project.ConfigPath, nil
+	}
+	if err := op.InitWorkingDirectory(); err != nil {
+		return err
+	}
+	if err := op.ReloadKopiaConfig(); err != nil {
+		return err
+	}
+
+	newRepoOptions, err := newRepositoryOptions(ecc.DefaultAlgorithm, 0)
+	if err != nil {
+		return err
+	}
+	if err := connect(op, true, false, newRepoOptions); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, util.T("Created the repository in %s like init --create does", project.StoragePath))
+
+	if _, err := createSnapshot(ctx, op, op.NewAuditRecord(op.Command, nil), defaultSnapSettings(op)); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, util.T("Snapshotted %s like snap does", util.QuickstartDir))
+
+	lost := project.AssetPaths()[0]
+	if err := os.Remove(project.AssetPath(lost)); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, util.T("Deleted %s as if it was lost", lost))
+
+	if err := restoreSnapshots(ctx, op, "", restoreSettings{}, w, w); err != nil {
+		return err
+	}
+	if err := project.CheckAssets(); err != nil {
+		return fmt.Errorf("the restored assets differ from the snapshot: %w", err)
+	}
+	fmt.Fprintln(w, util.T("Restored %s like restore does", lost))
+	return nil
+}
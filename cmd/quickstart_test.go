@@ -0,0 +1,54 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"path/filepath"
+	"testing"
+)
+
+type QuickstartSuite struct {
+	repoSuite
+}
+
+func TestQuickstartSuite(t *testing.T) {
+	suite.Run(t, new(QuickstartSuite))
+}
+
+func (suite *QuickstartSuite) Test_runQuickstart() {
+	// The .env file of the project does not replace the password of the environment, which is restored after the test
+	suite.T().Setenv(util.EnvPassword, "password")
+	project, err := util.CreateQuickstartProject(filepath.Join(suite.T().TempDir(), "quickstart"), "password")
+	if err != nil {
+		suite.T().FailNow()
+	}
+	options := newOptions()
+	options.TempDirectory = suite.T().TempDir()
+
+	var output bytes.Buffer
+	if !assert.NoError(suite.T(), runQuickstart(context.Background(), &options, project, &output)) {
+		return
+	}
+	assert.NoError(suite.T(), project.CheckAssets())
+	assert.Contains(suite.T(), output.String(), "Restored "+project.AssetPaths()[0])
+	assert.NotEmpty(suite.T(), options.Config.GassetId, "the repository got a gasset id")
+}
@@ -0,0 +1,228 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// recoverConfigCmd represents the recover-config command
+var recoverConfigCmd = &cobra.Command{
+	Use:   "recover-config",
+	Short: "Recovers the .gasset file and the lock files from the repository",
+	Long: `Recovers the .gasset file and the lock files from the repository.
+
+Every snap stores a copy of the .gasset file and the lock files in the
+repository when they changed since the last copy. recover-config writes
+the latest copy back into the git repository, e.g. when its history was
+lost but the bucket and the credentials are still there.
+
+Without a .gasset file the storage is given with --storage and the
+credentials in the .env file or the environment as usual. When the
+repository is shared by several projects, the project is picked with
+--gasset-id, --list shows the copies of every project. Existing files are
+only overwritten with --force.`,
+	Args: cobra.NoArgs,
+	RunE: RecoverConfigRun,
+}
+
+func init() {
+	rootCmd.AddCommand(recoverConfigCmd)
+
+	recoverConfigCmd.Flags().String("gasset-id", "", "Recovers the files of the project with the gasset id (default is the one of the .gasset file)")
+	recoverConfigCmd.Flags().Bool("list", false, "Lists the copies stored in the repository without recovering them")
+	recoverConfigCmd.Flags().Bool("force", false, "Overwrites the existing files")
+	addTimeoutFlag(recoverConfigCmd)
+}
+
+func RecoverConfigRun(cmd *cobra.Command, _ []string) error {
+	log.Println("recover-config called")
+
+	options, err := loadRecoveryOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	gassetId, err := cmd.Flags().GetString("gasset-id")
+	if err != nil {
+		return err
+	}
+	list, err := cmd.Flags().GetBool("list")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return recoverConfig(ctx, options, gassetId, list, force, cmd.OutOrStdout())
+}
+
+// loadRecoveryOptions loads the options like loadOptions but without requiring the .gasset file,
+// whose storage is given with --storage when it is lost
+func loadRecoveryOptions(cmd *cobra.Command) (*util.Options, error) {
+	options := newOptions()
+
+	machineIdentity, err := cmd.Flags().GetString("machine-identity")
+	if err != nil {
+		return nil, err
+	}
+	if err := options.SetMachineIdentity(machineIdentity); err != nil {
+		return nil, err
+	}
+	if options.TempDirectory, err = cmd.Flags().GetString("temp-dir"); err != nil {
+		return nil, err
+	}
+	if err := loadInputFlags(cmd, &options); err != nil {
+		return nil, err
+	}
+	options.Command = commandName(cmd)
+	if options.LocalTime, err = cmd.Flags().GetBool("local-time"); err != nil {
+		return nil, err
+	}
+
+	if err := options.InitWorkingDirectory(); err != nil {
+		return nil, err
+	}
+
+	config, err := util.GetConfig(options.WorkingDirectory)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Warning: could not read the .gasset file, it is left out: %v", err)
+		}
+		config = &util.Config{}
+	}
+	if (config.Kopia == nil || config.Kopia.Storage == nil) && config.Storage == "" && options.StorageURL == "" {
+		return nil, errors.New("the storage of the repository is unknown, give it with --storage, e.g. s3://bucket/prefix/, or --bucket")
+	}
+	if err := options.LoadKopiaConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := checkInsecure(cmd, util.InsecureTransport(options.Config)); err != nil {
+		return nil, err
+	}
+	if err := enableKopiaDebug(cmd, &options); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// recoverConfig writes the latest backup of the gasset id, or of the .gasset file, into the working directory
+func recoverConfig(ctx context.Context, op *util.Options, gassetId string, list bool, force bool, w io.Writer) error {
+	if gassetId == "" {
+		gassetId = op.Config.GassetId
+	}
+
+	rep, disconnect, err := connectRecovery(ctx, op)
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+
+	backups, err := util.ListConfigBackups(ctx, rep, gassetId)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		if gassetId != "" {
+			return fmt.Errorf("the repository has no backup of the .gasset file of %s", gassetId)
+		}
+		return errors.New("the repository has no backup of the .gasset file")
+	}
+
+	if list {
+		for _, backup := range backups {
+			names := make([]string, 0, len(backup.Files))
+			for name := range backup.Files {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			fmt.Fprintf(w, "%s\t%s\t%s@%s\t%s\n", util.FormatTime(backup.Time, op.LocalTime), backup.GassetId, backup.User, backup.Host, strings.Join(names, ", "))
+		}
+		return nil
+	}
+
+	var gassetIds []string
+	for _, backup := range backups {
+		if !slices.Contains(gassetIds, backup.GassetId) {
+			gassetIds = append(gassetIds, backup.GassetId)
+		}
+	}
+	if len(gassetIds) > 1 {
+		return fmt.Errorf("the repository has the backups of the projects %s, pick one with --gasset-id", strings.Join(gassetIds, ", "))
+	}
+
+	latest := backups[len(backups)-1]
+	names, err := util.RecoverConfigBackup(op.WorkingDirectory, latest, force, op.Config.Permissions)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w, pass --force to overwrite it", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, util.T("Recovered %s from the backup taken by %s at %s", strings.Join(names, ", "), latest.User+"@"+latest.Host, util.FormatTime(latest.Time, op.LocalTime)))
+	summary.Add("recovered files", len(names))
+	return nil
+}
+
+// connectRecovery connects to the repository of the storage with a temporary kopia config, as the kopia
+// config of the gasset id may be lost as well. The returned function disconnects from it.
+func connectRecovery(ctx context.Context, op *util.Options) (repo.Repository, func(), error) {
+	storage, err := openStorage(ctx, op, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configFile := filepath.Join(op.TempDir(), "gasset-recover-"+util.GenerateRandomString(8, op.RandIntn)+".config")
+	if err := op.RepoConnect(ctx, configFile, storage, op.Password, &repo.ConnectOptions{ClientOptions: op.ClientOptions()}); err != nil {
+		return nil, nil, err
+	}
+	disconnect := func() {
+		if err := repo.Disconnect(context.WithoutCancel(ctx), configFile); err != nil {
+			log.Printf("Warning: could not remove the temporary kopia config %s: %v", configFile, err)
+		}
+	}
+
+	rep, err := op.RepoOpen(ctx, configFile, op.Password, &repo.Options{})
+	if err != nil {
+		disconnect()
+		return nil, nil, err
+	}
+	return rep, func() {
+		rep.Close(ctx)
+		disconnect()
+	}, nil
+}
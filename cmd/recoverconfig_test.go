@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type RecoverConfigSuite struct {
+	repoSuite
+}
+
+func TestRecoverConfigSuite(t *testing.T) {
+	suite.Run(t, new(RecoverConfigSuite))
+}
+
+func (suite *RecoverConfigSuite) Test_recoverConfig() {
+	ctx := context.Background()
+	gassetPath := filepath.Join(suite.options.WorkingDirectory, ".gasset")
+	if err := os.WriteFile(gassetPath, []byte(`{"gassetId": "0000000000", "dirs": ["./assets"]}`), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+			suite.T().FailNow()
+		}
+	}
+
+	tests := []struct {
+		name         string
+		removeConfig bool
+		gassetId     string
+		list         bool
+		force        bool
+		wantErr      assert.ErrorAssertionFunc
+		wantOutput   string
+		wantLines    int
+	}{
+		{
+			name:       "List the backups, an unchanged one is stored once",
+			list:       true,
+			wantErr:    assert.NoError,
+			wantOutput: "0000000000",
+			wantLines:  1,
+		},
+		{
+			name: "Keep an existing .gasset file",
+			wantErr: func(t assert.TestingT, err error, msgAndArgs ...interface{}) bool {
+				return assert.ErrorIs(t, err, fs.ErrExist, msgAndArgs...)
+			},
+		},
+		{
+			name:         "Recover a lost .gasset file",
+			removeConfig: true,
+			wantErr:      assert.NoError,
+			wantOutput:   "Recovered .gasset from the backup",
+			wantLines:    1,
+		},
+		{
+			name:     "Fail on the backups of another gasset id",
+			gassetId: "other",
+			force:    true,
+			wantErr:  assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			if tt.removeConfig {
+				if err := os.Remove(gassetPath); err != nil {
+					suite.T().FailNow()
+				}
+			}
+
+			var output bytes.Buffer
+			if !tt.wantErr(suite.T(), recoverConfig(ctx, suite.options, tt.gassetId, tt.list, tt.force, &output)) || tt.wantLines == 0 {
+				return
+			}
+			assert.Equal(suite.T(), tt.wantLines, strings.Count(output.String(), "\n"))
+			assert.Contains(suite.T(), output.String(), tt.wantOutput)
+		})
+	}
+	content, err := os.ReadFile(gassetPath)
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(content), "0000000000")
+}
@@ -0,0 +1,432 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generates reports about the snapshots",
+}
+
+// reportLicensesCmd represents the report licenses command
+var reportLicensesCmd = &cobra.Command{
+	Use:   "licenses",
+	Short: "Aggregates the licenses of the assets in the snapshots",
+	Long: `Aggregates the licenses of the assets in the snapshots.
+
+The license sidecar manifests written by license set are read from the
+latest snapshot of every asset directory, or from the given snapshot, and
+the assets are listed grouped by license for a legal review before
+shipping. Assets without a license are grouped as ` + util.UnknownLicense + `.`,
+	Args: cobra.NoArgs,
+	RunE: ReportLicensesRun,
+}
+
+// reportColdAssetsCmd represents the report cold-assets command
+var reportColdAssetsCmd = &cobra.Command{
+	Use:   "cold-assets",
+	Short: "Lists the assets which are never restored",
+	Long: `Lists the assets which are never restored.
+
+The files of the latest snapshot of every asset directory are compared to
+the restores counted on this machine, which are only recorded with
+trackRestores in the .gasset file. The files which were never restored, or
+not within --since, are listed with the largest first as candidates to
+archive with untrack.`,
+	Args: cobra.NoArgs,
+	RunE: ReportColdAssetsRun,
+}
+
+// reportMetricsCmd represents the report metrics command
+var reportMetricsCmd = &cobra.Command{
+	Use:   "metrics [dir...]",
+	Short: "Lists the metrics of the asset directories over their snapshots",
+	Long: `Lists the metrics of the asset directories over their snapshots.
+
+Every snapshot records the number of files and directories and the total
+size of its asset directory, together with the metrics of the analyzers
+configured under metrics in the .gasset file, e.g. the total triangles of
+the meshes. The snapshots of the given directories, or of all of them, are
+listed with the oldest first to follow the growth of the assets.`,
+	RunE: ReportMetricsRun,
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportLicensesCmd)
+	reportCmd.AddCommand(reportColdAssetsCmd)
+	reportCmd.AddCommand(reportMetricsCmd)
+
+	reportLicensesCmd.Flags().String("snapshot", "", "Id of the snapshot to report instead of the latest ones")
+
+	reportColdAssetsCmd.Flags().Duration("since", 0, "Lists the assets not restored within the given duration instead of never")
+	reportColdAssetsCmd.Flags().Bool("json", false, "Prints the assets as JSON")
+	addTimeoutFlag(reportColdAssetsCmd)
+
+	reportMetricsCmd.Flags().Bool("json", false, "Prints the metrics as JSON")
+	addTimeoutFlag(reportMetricsCmd)
+}
+
+func ReportLicensesRun(cmd *cobra.Command, _ []string) error {
+	log.Println("report licenses called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	snapshotId, err := cmd.Flags().GetString("snapshot")
+	if err != nil {
+		return err
+	}
+
+	return reportLicenses(context.Background(), options, snapshotId, cmd.OutOrStdout())
+}
+
+func reportLicenses(ctx context.Context, op *util.Options, snapshotId string, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	var manifests []*snapshot.Manifest
+	if snapshotId != "" {
+		man, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(snapshotId))
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, man)
+	} else {
+		for _, dir := range op.Config.Dirs {
+			dirManifests, err := listDirSnapshots(ctx, op, rep, dir)
+			if err != nil {
+				return err
+			}
+			if latest := latestCompleteSnapshot(dirManifests); latest != nil {
+				manifests = append(manifests, latest)
+			}
+		}
+	}
+
+	var entries []util.LicenseEntry
+	for _, man := range manifests {
+		fmt.Fprintln(w, util.T("Snapshot %s of %s taken at %s", man.ID, man.Source.Path, util.FormatTime(man.StartTime.ToTime(), op.LocalTime)))
+
+		root, err := snapshotfs.SnapshotRoot(rep, man)
+		if err != nil {
+			return err
+		}
+		dir, ok := root.(fs.Directory)
+		if !ok {
+			continue
+		}
+
+		snapshotEntries, err := collectLicenses(ctx, dir, path.Base(man.Source.Path))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, snapshotEntries...)
+	}
+
+	licenses, groups := util.GroupLicenses(entries)
+	for _, license := range licenses {
+		fmt.Fprintf(w, "\n%s\n", license)
+		for _, entry := range groups[license] {
+			fmt.Fprintf(w, "  %s", entry.Path)
+			if entry.Author != "" {
+				fmt.Fprintf(w, " by %s", entry.Author)
+			}
+			if entry.Source != "" {
+				fmt.Fprintf(w, " from %s", entry.Source)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	return nil
+}
+
+// latestCompleteSnapshot returns the most recent snapshot which is not a checkpoint
+func latestCompleteSnapshot(manifests []*snapshot.Manifest) *snapshot.Manifest {
+	var latest *snapshot.Manifest
+	for _, man := range manifests {
+		if man.IncompleteReason == "" && (latest == nil || util.SnapshotAfter(man, latest)) {
+			latest = man
+		}
+	}
+	return latest
+}
+
+// collectLicenses walks a snapshot directory and returns the entries of all the license sidecar manifests
+func collectLicenses(ctx context.Context, dir fs.Directory, dirPath string) ([]util.LicenseEntry, error) {
+	var entries []util.LicenseEntry
+
+	err := fs.IterateEntries(ctx, dir, func(ctx context.Context, entry fs.Entry) error {
+		switch typedEntry := entry.(type) {
+		case fs.Directory:
+			childEntries, err := collectLicenses(ctx, typedEntry, path.Join(dirPath, entry.Name()))
+			if err != nil {
+				return err
+			}
+			entries = append(entries, childEntries...)
+		case fs.File:
+			if entry.Name() != util.LicenseFileName {
+				return nil
+			}
+			reader, err := typedEntry.Open(ctx)
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
+
+			licenseManifest, err := util.ReadLicenseManifest(reader)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path.Join(dirPath, entry.Name()), err)
+			}
+			entries = append(entries, licenseManifest.LicenseEntries(dirPath)...)
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+func ReportColdAssetsRun(cmd *cobra.Command, _ []string) error {
+	log.Println("report cold-assets called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		return err
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var sinceTime time.Time
+	if since > 0 {
+		sinceTime = time.Now().Add(-since)
+	}
+	return reportColdAssets(ctx, options, sinceTime, asJson, cmd.OutOrStdout())
+}
+
+func reportColdAssets(ctx context.Context, op *util.Options, since time.Time, asJson bool, w io.Writer) error {
+	if !op.Config.TrackRestores {
+		log.Printf("Warning: trackRestores is not enabled in the .gasset file, restores are not counted")
+	}
+
+	usage, err := op.LoadRestoreUsage()
+	if err != nil {
+		return err
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	files := map[string]int64{}
+	for _, dir := range op.Config.Dirs {
+		manifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return err
+		}
+		latest := latestCompleteSnapshot(manifests)
+		if latest == nil {
+			continue
+		}
+
+		root, err := snapshotfs.SnapshotRoot(rep, latest)
+		if err != nil {
+			return err
+		}
+		rootDir, ok := root.(fs.Directory)
+		if !ok {
+			continue
+		}
+
+		dirPath := path.Clean(filepath.ToSlash(dir)) + "/"
+		err = util.WalkSnapshotFiles(ctx, rootDir, dirPath, func(filePath string, entry fs.Entry) error {
+			files[filePath] = entry.Size()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cold := usage.ColdAssets(files, since)
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(cold)
+	}
+
+	var total int64
+	for _, asset := range cold {
+		lastRestored := "never"
+		if !asset.LastRestored.IsZero() {
+			lastRestored = util.FormatTime(asset.LastRestored, op.LocalTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", util.FormatBytes(asset.Size), lastRestored, asset.Path)
+		total += asset.Size
+	}
+	fmt.Fprintln(w, util.T("%d of %d assets are cold, %s in total", len(cold), len(files), util.FormatBytes(total)))
+	return nil
+}
+
+func ReportMetricsRun(cmd *cobra.Command, args []string) error {
+	log.Println("report metrics called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return reportMetrics(ctx, options, args, asJson, cmd.OutOrStdout())
+}
+
+// snapshotMetrics is the metrics of an asset directory recorded in a snapshot
+type snapshotMetrics struct {
+	Dir      string             `json:"dir"`
+	Snapshot manifest.ID        `json:"snapshot"`
+	Time     time.Time          `json:"time"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+func reportMetrics(ctx context.Context, op *util.Options, dirs []string, asJson bool, w io.Writer) error {
+	if len(dirs) == 0 {
+		dirs = op.Config.Dirs
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	var manifests []*snapshot.Manifest
+	dirOf := map[*snapshot.Manifest]string{}
+	for _, dir := range dirs {
+		dirManifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return err
+		}
+		for _, man := range dirManifests {
+			if man.IncompleteReason == "" {
+				manifests = append(manifests, man)
+				dirOf[man] = dir
+			}
+		}
+	}
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return util.SnapshotAfter(manifests[j], manifests[i])
+	})
+
+	history := make([]snapshotMetrics, 0, len(manifests))
+	for _, man := range manifests {
+		history = append(history, snapshotMetrics{
+			Dir:      dirOf[man],
+			Snapshot: man.ID,
+			Time:     man.StartTime.ToTime(),
+			Metrics:  util.SnapshotMetrics(man),
+		})
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(history)
+	}
+
+	names := util.MetricNames(manifests)
+	fmt.Fprintf(w, "time\tsnapshot\tdir\t%s\n", strings.Join(names, "\t"))
+	for _, entry := range history {
+		fmt.Fprintf(w, "%s\t%s\t%s", util.FormatTime(entry.Time, op.LocalTime), entry.Snapshot, entry.Dir)
+		for _, name := range names {
+			value, ok := entry.Metrics[name]
+			if !ok {
+				fmt.Fprint(w, "\t-")
+				continue
+			}
+			fmt.Fprintf(w, "\t%s", strconv.FormatFloat(value, 'f', -1, 64))
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
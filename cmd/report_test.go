@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type ReportSuite struct {
+	repoSuite
+}
+
+func TestReportSuite(t *testing.T) {
+	suite.Run(t, new(ReportSuite))
+}
+
+func (suite *ReportSuite) Test_reportColdAssets() {
+	ctx := context.Background()
+	if err := os.WriteFile(filepath.Join(suite.options.WorkingDirectory, "assets", "b.txt"), []byte("bb"), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+
+	usage, err := suite.options.LoadRestoreUsage()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	usage.Record("assets/a.txt", time.Now())
+	if err := suite.options.SaveRestoreUsage(usage); err != nil {
+		suite.T().FailNow()
+	}
+	usagePath, err := suite.options.GetRestoreUsagePath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	suite.T().Cleanup(func() {
+		os.Remove(usagePath)
+	})
+
+	w := &bytes.Buffer{}
+	assert.NoError(suite.T(), reportColdAssets(ctx, suite.options, time.Time{}, false, w))
+	assert.Equal(suite.T(), "2.0 B\tnever\tassets/b.txt\n1 of 2 assets are cold, 2.0 B in total\n", w.String())
+}
+
+func (suite *ReportSuite) Test_reportMetrics() {
+	if _, err := exec.LookPath("sh"); err != nil {
+		suite.T().Skip("sh is not available")
+	}
+	ctx := context.Background()
+	suite.options.Config.Metrics = []util.MetricAnalyzer{
+		{Name: "meshes", Command: []string{"sh", "-c", `printf '{"triangles": 1200}'`}},
+	}
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+
+	w := &bytes.Buffer{}
+	if !assert.NoError(suite.T(), reportMetrics(ctx, suite.options, nil, true, w)) {
+		return
+	}
+	var history []snapshotMetrics
+	if err := json.Unmarshal(w.Bytes(), &history); err != nil {
+		suite.T().FailNow()
+	}
+	if !assert.Len(suite.T(), history, 1) {
+		return
+	}
+	assert.Equal(suite.T(), suite.options.Config.Dirs[0], history[0].Dir)
+	assert.Equal(suite.T(), map[string]float64{
+		util.MetricFiles:   1,
+		util.MetricDirs:    1,
+		util.MetricBytes:   1,
+		"meshes.triangles": 1200,
+	}, history[0].Metrics)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"time"
+)
+
+// reposCmd represents the repos command
+var reposCmd = &cobra.Command{
+	Use:   "repos",
+	Short: "Manages the registry of the local checkouts per gasset id",
+	Long: `Manages the registry of the local checkouts per gasset id.
+
+Every command registers the checkout it runs in with its gasset id in the user
+config directory. The checkouts of a gasset id share its kopia config and
+cache, so a second clone of the same git repository reuses what the first one
+already downloaded. housekeep relies on the registry to find the configs and
+caches no checkout uses anymore.`,
+}
+
+// reposListCmd represents the repos list command
+var reposListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the registered checkouts per gasset id",
+	Long: `Lists the registered checkouts per gasset id.
+
+Every checkout is shown with the time it was last used and whether it is
+active, missing because it was deleted or moved, switched to another gasset
+id, or has a .gasset file which cannot be read.`,
+	Args: cobra.NoArgs,
+	RunE: ReposListRun,
+}
+
+// reposForgetCmd represents the repos forget command
+var reposForgetCmd = &cobra.Command{
+	Use:   "forget",
+	Short: "Removes the missing and switched checkouts from the registry",
+	Long: `Removes the missing and switched checkouts from the registry.
+
+The gasset ids stay registered, so that housekeep still finds their configs
+and caches once none of their checkouts are left.`,
+	Args: cobra.NoArgs,
+	RunE: ReposForgetRun,
+}
+
+func init() {
+	rootCmd.AddCommand(reposCmd)
+	reposCmd.AddCommand(reposListCmd)
+	reposCmd.AddCommand(reposForgetCmd)
+
+	reposListCmd.Flags().Bool("json", false, "Prints the checkouts as JSON")
+}
+
+// registeredCheckout is a checkout of the registry with its status
+type registeredCheckout struct {
+	GassetId string              `json:"gassetId"`
+	Path     string              `json:"path"`
+	LastUsed time.Time           `json:"lastUsed"`
+	Status   util.CheckoutStatus `json:"status"`
+}
+
+func ReposListRun(cmd *cobra.Command, _ []string) error {
+	log.Println("repos list called")
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	options, err := localStateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	return listCheckouts(options, asJson, cmd.OutOrStdout())
+}
+
+func listCheckouts(op *util.Options, asJson bool, w io.Writer) error {
+	registry, err := op.LoadCheckoutRegistry()
+	if err != nil {
+		return err
+	}
+
+	checkouts := []registeredCheckout{}
+	for _, gassetId := range registry.SortedGassetIds() {
+		for _, checkout := range registry.GassetIds[gassetId] {
+			checkouts = append(checkouts, registeredCheckout{
+				GassetId: gassetId,
+				Path:     checkout.Path,
+				LastUsed: checkout.LastUsed,
+				Status:   checkout.Status(gassetId),
+			})
+		}
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(checkouts)
+	}
+	for _, checkout := range checkouts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", checkout.GassetId, checkout.Status, util.FormatTime(checkout.LastUsed, op.LocalTime), checkout.Path)
+	}
+	return nil
+}
+
+func ReposForgetRun(cmd *cobra.Command, _ []string) error {
+	log.Println("repos forget called")
+
+	options, err := localStateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	return forgetStaleCheckouts(options, cmd.OutOrStdout())
+}
+
+func forgetStaleCheckouts(op *util.Options, w io.Writer) error {
+	registry, err := op.LoadCheckoutRegistry()
+	if err != nil {
+		return err
+	}
+
+	forgotten := registry.ForgetStale()
+	count := 0
+	for _, gassetId := range registry.SortedGassetIds() {
+		for _, checkout := range forgotten[gassetId] {
+			fmt.Fprintf(w, "%s\t%s\n", gassetId, checkout.Path)
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	if err := op.SaveCheckoutRegistry(registry); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, util.T("Forgot %d checkouts", count))
+	summary.Add("forgotten", count)
+	return nil
+}
@@ -0,0 +1,397 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [snapshot-id]",
+	Short: "Restores the asset directories from their snapshots",
+	Long: `Restores the asset directories from their snapshots.
+
+Every asset directory of the .gasset file is restored from its latest
+snapshot taken by any user or machine, or only the directory of the given
+snapshot. With --channel the directories are restored from the latest
+release published to the channel instead, see publish. Files which
+already match the snapshot are left alone. Files with local changes fail
+the restore unless --overwrite replaces them or --skip-existing keeps
+them.
+
+Files are staged and renamed into place once they are complete, so that
+engines watching the asset directories never open half-written files.
+They are staged inside .git, or in --temp-dir which must then be on the
+same filesystem as the assets. Content restored before is reused with
+reflinks, or hard links with --link, instead of copying it again.
+
+The files left to download are recorded in the user config directory as
+the restore goes, an interrupted restore of the same snapshot continues
+with the remaining files and within a file at its last synced byte.
+
+The restoreHooks of the .gasset file run for the directories with
+written files, with the changed files described in their environment.
+
+The throttling.restore section of the .gasset file replaces the throttling
+limits of the kopia config while restore runs, an empty section lifts them
+so that restores are as fast as possible, and the throttling flags replace
+single limits on top of it.
+
+With the peers section of the .gasset file the pack blobs are fetched
+from the peers on the network before the storage, see peer, unless
+--no-peers is set.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: RestoreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().Bool("overwrite", false, "Replaces the files with local changes")
+	restoreCmd.Flags().Bool("skip-existing", false, "Keeps every file which already exists, even with local changes")
+	restoreCmd.MarkFlagsMutuallyExclusive("overwrite", "skip-existing")
+	restoreCmd.Flags().Bool("link", false, "Hard links the content restored before instead of cloning it, the files then share their attributes")
+	restoreCmd.Flags().String("channel", "", "Restores the latest release of the channel, e.g. stable, instead of the latest snapshots")
+	restoreCmd.Flags().Bool("no-peers", false, "Fetches everything from the storage even with the peers section of the .gasset file")
+	restoreCmd.Flags().String("report", "", "Writes every restored file with its action, size, duration and error as JSON lines to the given file")
+	addTimeoutFlag(restoreCmd)
+	addThrottlingFlags(restoreCmd)
+}
+
+// restoreSettings holds the options of a restore shared by all the asset directories
+type restoreSettings struct {
+	overwrite    bool
+	skipExisting bool
+	hardLinks    bool
+	// channel restores the latest release of the channel instead of the latest snapshots if it is not empty
+	channel string
+	// report records every restored file if it is not nil
+	report *util.Report
+}
+
+func RestoreRun(cmd *cobra.Command, args []string) (err error) {
+	log.Println("restore called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if err := applyThrottling(cmd, options, options.Config.Throttling.RestoreLimits()); err != nil {
+		return err
+	}
+
+	settings := restoreSettings{}
+	if settings.overwrite, err = cmd.Flags().GetBool("overwrite"); err != nil {
+		return err
+	}
+	if settings.skipExisting, err = cmd.Flags().GetBool("skip-existing"); err != nil {
+		return err
+	}
+	if settings.hardLinks, err = cmd.Flags().GetBool("link"); err != nil {
+		return err
+	}
+
+	if settings.channel, err = cmd.Flags().GetString("channel"); err != nil {
+		return err
+	}
+	if settings.channel != "" && len(args) > 0 {
+		return errors.New("restore takes either a snapshot or a channel")
+	}
+
+	reportPath, err := cmd.Flags().GetString("report")
+	if err != nil {
+		return err
+	}
+	if reportPath != "" {
+		if settings.report, err = util.CreateReport(reportPath); err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := settings.report.Close(); closeErr != nil {
+				err = errors.Join(err, fmt.Errorf("could not write the report: %w", closeErr))
+			}
+		}()
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	noPeers, err := cmd.Flags().GetBool("no-peers")
+	if err != nil {
+		return err
+	}
+	if options.Config.Peers != nil && !noPeers {
+		if err := util.EnablePeers(ctx, options); err != nil {
+			return err
+		}
+		defer func() {
+			fromCache, fromPeers := util.PeerFetches()
+			summary.Add("peer cache hits", int(fromCache))
+			summary.Add("peer fetches", int(fromPeers))
+		}()
+	}
+
+	var snapshotId string
+	if len(args) > 0 {
+		snapshotId = args[0]
+	}
+	return restoreSnapshots(ctx, options, snapshotId, settings, cmd.OutOrStdout(), cmd.ErrOrStderr())
+}
+
+// restoreTarget is an asset directory with the snapshot it is restored from
+type restoreTarget struct {
+	dir      string
+	manifest *snapshot.Manifest
+}
+
+func restoreSnapshots(ctx context.Context, op *util.Options, snapshotId string, settings restoreSettings, stdout io.Writer, stderr io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	targets, err := restoreTargets(ctx, op, rep, snapshotId, settings.channel)
+	if err != nil {
+		return err
+	}
+
+	content, err := op.LoadRestoredContent()
+	if err != nil {
+		return err
+	}
+	var usage *util.RestoreUsage
+	if op.Config.TrackRestores {
+		if usage, err = op.LoadRestoreUsage(); err != nil {
+			return err
+		}
+	}
+
+	var changes []util.RestoreChange
+	var restoreErr error
+	for _, target := range targets {
+		change, err := restoreDir(ctx, op, rep, target, settings, content, usage, stdout)
+		if err != nil {
+			restoreErr = fmt.Errorf("%s: %w", target.dir, err)
+			break
+		}
+		changes = append(changes, change)
+	}
+
+	// The files restored before a failure are still on the disk
+	if err := op.SaveRestoredContent(content); err != nil {
+		log.Printf("Warning: could not save the restored content, it is downloaded again next time: %v", err)
+	}
+	if usage != nil {
+		if err := op.SaveRestoreUsage(usage); err != nil {
+			log.Printf("Warning: could not save the restore usage: %v", err)
+		}
+	}
+	if restoreErr != nil {
+		return restoreErr
+	}
+
+	return op.RunRestoreHooks(ctx, changes, stdout, stderr)
+}
+
+// restoreTargets returns the snapshot with the given id, the latest release of the channel
+// or the latest snapshot of every asset directory
+func restoreTargets(ctx context.Context, op *util.Options, rep repo.Repository, snapshotId string, channel string) ([]restoreTarget, error) {
+	if channel != "" {
+		release, err := util.LatestChannelRelease(ctx, rep, channel)
+		if err != nil {
+			return nil, err
+		}
+		return releaseTargets(ctx, op, rep, release)
+	}
+	if snapshotId != "" {
+		man, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(snapshotId))
+		if err != nil {
+			return nil, err
+		}
+		dir, err := snapshotAssetDir(op, man)
+		if err != nil {
+			return nil, err
+		}
+		return []restoreTarget{{dir: dir, manifest: man}}, nil
+	}
+
+	var targets []restoreTarget
+	for _, dir := range op.Config.Dirs {
+		manifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return nil, err
+		}
+		latest := latestCompleteSnapshot(manifests)
+		if latest == nil {
+			log.Printf("Warning: %s has no snapshot to restore", dir)
+			continue
+		}
+		targets = append(targets, restoreTarget{dir: dir, manifest: latest})
+	}
+	return targets, nil
+}
+
+// releaseTargets returns the snapshots of a channel release in the order of the asset directories
+func releaseTargets(ctx context.Context, op *util.Options, rep repo.Repository, release *util.ChannelRelease) ([]restoreTarget, error) {
+	published := map[string]*snapshot.Manifest{}
+	for _, id := range release.Snapshots {
+		man, err := snapshot.LoadSnapshot(ctx, rep, id)
+		if err != nil {
+			return nil, err
+		}
+		// The directory is looked up by the snapshot as it may have been moved since the release
+		dir, err := snapshotAssetDir(op, man)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		published[dir] = man
+	}
+
+	var targets []restoreTarget
+	for _, dir := range op.Config.Dirs {
+		man, ok := published[dir]
+		if !ok {
+			log.Printf("Warning: %s is not part of the %s channel", dir, release.Channel)
+			continue
+		}
+		targets = append(targets, restoreTarget{dir: dir, manifest: man})
+	}
+	return targets, nil
+}
+
+// snapshotAssetDir returns the asset directory a snapshot was taken of, also if it was moved since
+func snapshotAssetDir(op *util.Options, man *snapshot.Manifest) (string, error) {
+	for _, dir := range op.Config.Dirs {
+		if slices.Contains(dirSourcePaths(op, dir), man.Source.Path) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("snapshot %s of %s is not of an asset directory of the .gasset file", man.ID, man.Source.Path)
+}
+
+func restoreDir(ctx context.Context, op *util.Options, rep repo.Repository, target restoreTarget, settings restoreSettings, content *util.RestoredContent, usage *util.RestoreUsage, w io.Writer) (util.RestoreChange, error) {
+	root, err := snapshotfs.SnapshotRoot(rep, target.manifest)
+	if err != nil {
+		return util.RestoreChange{}, err
+	}
+
+	targetPath := filepath.Join(op.WorkingDirectory, target.dir)
+	fsOutput := &restore.FilesystemOutput{
+		TargetPath:             targetPath,
+		OverwriteDirectories:   true,
+		OverwriteFiles:         settings.overwrite,
+		OverwriteSymlinks:      settings.overwrite,
+		IgnorePermissionErrors: true,
+		SkipOwners:             true,
+	}
+	staged, err := util.NewStagedOutput(ctx, fsOutput, op.StagingRoot())
+	if err != nil {
+		return util.RestoreChange{}, err
+	}
+
+	// The queue of an interrupted restore of the same snapshot is continued instead of starting over
+	queue, err := op.LoadDownloadQueue(string(target.manifest.ID))
+	if err != nil {
+		staged.Close(ctx)
+		return util.RestoreChange{}, err
+	}
+	if files, bytes := queue.Left(); files > 0 {
+		log.Printf("Resuming the restore of %s, %d files and %s left", target.dir, files, util.FormatBytes(bytes))
+	}
+	if dir, ok := root.(fs.Directory); ok {
+		if err := queue.Enqueue(ctx, dir); err != nil {
+			staged.Close(ctx)
+			return util.RestoreChange{}, err
+		}
+	}
+
+	var output restore.Output = util.NewQueuedOutput(staged, fsOutput, queue, op.SaveDownloadQueue)
+	if settings.skipExisting {
+		output = &skipExistingOutput{Output: output, targetPath: targetPath}
+	}
+	output = util.NewLinkOutput(output, fsOutput, content, settings.hardLinks)
+	output = util.NewPermissionOutput(output, targetPath, op.Config.Permissions)
+	changes := util.NewChangeOutput(output, target.dir)
+	output = changes
+	if usage != nil {
+		output = util.NewUsageOutput(output, target.dir, usage, time.Now)
+	}
+	if settings.report != nil {
+		output = util.NewReportOutput(output, settings.report, target.dir)
+	}
+
+	// Incremental leaves the files matching the snapshot alone instead of failing on them. The subdirectories
+	// are restored at any depth, kopia otherwise only writes placeholders below the given one.
+	stats, err := restore.Entry(ctx, rep, output, root, restore.Options{Incremental: true, RestoreDirEntryAtDepth: math.MaxInt32})
+	if err != nil {
+		// The output is only closed by a successful restore, which removes the staging directory
+		staged.Close(ctx)
+		return util.RestoreChange{}, err
+	}
+
+	if err := op.ClearDownloadQueue(); err != nil {
+		log.Printf("Warning: could not remove the download queue: %v", err)
+	}
+
+	summary.Add("restored", int(stats.RestoredFileCount))
+	summary.Add("skipped", int(stats.SkippedCount))
+	fmt.Fprintln(w, util.T("Restored %s from snapshot %s, %d files written and %d unchanged", target.dir, target.manifest.ID, stats.RestoredFileCount, stats.SkippedCount))
+	return util.RestoreChange{Dir: target.dir, Snapshot: string(target.manifest.ID), Changed: changes.Changed()}, nil
+}
+
+// skipExistingOutput reports every existing file as restored so that local changes are kept
+type skipExistingOutput struct {
+	restore.Output
+
+	targetPath string
+}
+
+// FileExists implements restore.Output
+func (o *skipExistingOutput) FileExists(ctx context.Context, relativePath string, f fs.File) bool {
+	if _, err := os.Lstat(filepath.Join(o.targetPath, filepath.FromSlash(relativePath))); err == nil {
+		return true
+	}
+	return o.Output.FileExists(ctx, relativePath, f)
+}
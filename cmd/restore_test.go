@@ -0,0 +1,337 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"git-gasset/util"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type RestoreSuite struct {
+	repoSuite
+}
+
+func TestRestoreSuite(t *testing.T) {
+	suite.Run(t, new(RestoreSuite))
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	assetPath := filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt")
+
+	tests := []struct {
+		name        string
+		local       string
+		settings    restoreSettings
+		wantErr     assert.ErrorAssertionFunc
+		wantContent string
+	}{
+		{
+			name:        "Restore a deleted file",
+			wantErr:     assert.NoError,
+			wantContent: "a",
+		},
+		{
+			name:        "Fail on a file with local changes",
+			local:       "changed",
+			wantErr:     assert.Error,
+			wantContent: "changed",
+		},
+		{
+			name:        "Keep a file with local changes",
+			local:       "changed",
+			settings:    restoreSettings{skipExisting: true},
+			wantErr:     assert.NoError,
+			wantContent: "changed",
+		},
+		{
+			name:        "Overwrite a file with local changes",
+			local:       "changed",
+			settings:    restoreSettings{overwrite: true},
+			wantErr:     assert.NoError,
+			wantContent: "a",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			os.Remove(assetPath)
+			if tt.local != "" {
+				if err := os.WriteFile(assetPath, []byte(tt.local), 0o644); err != nil {
+					suite.T().FailNow()
+				}
+			}
+
+			tt.wantErr(suite.T(), restoreSnapshots(ctx, suite.options, "", tt.settings, io.Discard, io.Discard))
+			content, err := os.ReadFile(assetPath)
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), tt.wantContent, string(content))
+
+			// Nothing is left behind in the staging directory, also by a failed restore
+			staged, _ := os.ReadDir(suite.options.StagingRoot())
+			assert.Empty(suite.T(), staged)
+		})
+	}
+}
+
+func (suite *RestoreSuite) Test_snapshotAssetDir() {
+	tests := []struct {
+		name       string
+		sourcePath string
+		want       string
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{
+			name:       "Find the asset directory of a snapshot",
+			sourcePath: suite.options.SourcePath("./assets"),
+			want:       "./assets",
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "Fail on a snapshot of another directory",
+			sourcePath: "/elsewhere",
+			wantErr:    assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			got, err := snapshotAssetDir(suite.options, &snapshot.Manifest{ID: "k1", Source: snapshot.SourceInfo{Path: tt.sourcePath}})
+			tt.wantErr(suite.T(), err)
+			assert.Equal(suite.T(), tt.want, got)
+		})
+	}
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots_hooks() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	assetPath := filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt")
+	refreshedPath := filepath.Join(suite.options.WorkingDirectory, "refreshed")
+	suite.options.Config.RestoreHooks = []util.RestoreHook{
+		{Dir: "./assets", Command: []string{"sh", "-c", `cp "$GASSET_HOOK_CHANGED_FILES" refreshed`}},
+	}
+
+	os.Remove(assetPath)
+	assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{}, io.Discard, io.Discard))
+	changed, err := os.ReadFile(refreshedPath)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "assets/a.txt\n", string(changed))
+
+	// The hooks only run for the directories with written files
+	os.Remove(refreshedPath)
+	assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{}, io.Discard, io.Discard))
+	assert.NoFileExists(suite.T(), refreshedPath)
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots_report() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	reportPath := filepath.Join(suite.T().TempDir(), "report.jsonl")
+	report, err := util.CreateReport(reportPath)
+	if err != nil {
+		suite.T().FailNow()
+	}
+
+	os.Remove(filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt"))
+	assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{report: report}, io.Discard, io.Discard))
+	assert.NoError(suite.T(), report.Close())
+
+	data, err := os.ReadFile(reportPath)
+	assert.NoError(suite.T(), err)
+	var entry util.ReportEntry
+	if assert.NoError(suite.T(), json.Unmarshal(data, &entry)) {
+		assert.Equal(suite.T(), "restore", entry.Operation)
+		assert.Equal(suite.T(), "assets/a.txt", entry.Path)
+		assert.Equal(suite.T(), util.ReportActionRestored, entry.Action)
+		assert.Equal(suite.T(), int64(1), entry.Bytes)
+	}
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots_links() {
+	ctx := context.Background()
+	otherPath := filepath.Join(suite.options.WorkingDirectory, "other")
+	if err := os.MkdirAll(otherPath, 0o755); err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(otherPath, "a.txt"), []byte("a"), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+	suite.options.Config.Dirs = append(suite.options.Config.Dirs, "./other")
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+
+	tests := []struct {
+		name      string
+		hardLinks bool
+		wantSame  bool
+	}{
+		{
+			name:     "Copy or clone the content restored before",
+			wantSame: false,
+		},
+		{
+			name:      "Hard link the content restored before",
+			hardLinks: true,
+			wantSame:  true,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			assetPath := filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt")
+			otherAssetPath := filepath.Join(otherPath, "a.txt")
+			os.Remove(assetPath)
+			os.Remove(otherAssetPath)
+
+			assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{hardLinks: tt.hardLinks}, io.Discard, io.Discard))
+			asset, err := os.Stat(assetPath)
+			assert.NoError(suite.T(), err)
+			other, err := os.Stat(otherAssetPath)
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), tt.wantSame, os.SameFile(asset, other))
+		})
+	}
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots_permissions() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	assetPath := filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt")
+	suite.options.Config.Permissions = &util.PermissionOptions{FileMode: "0640", IgnoreUmask: true}
+
+	os.Remove(assetPath)
+	assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{}, io.Discard, io.Discard))
+	info, err := os.Stat(assetPath)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), os.FileMode(0o640), info.Mode().Perm())
+	}
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots_usage() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	suite.options.Config.TrackRestores = true
+
+	// The file is counted when it is written and when it is already present
+	os.Remove(filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt"))
+	for i := 0; i < 2; i++ {
+		assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{}, io.Discard, io.Discard))
+	}
+	usage, err := suite.options.LoadRestoreUsage()
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), 2, usage.Paths["assets/a.txt"].Hits)
+	}
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots_resume() {
+	ctx := context.Background()
+	assetsPath := filepath.Join(suite.options.WorkingDirectory, "assets")
+	if err := os.WriteFile(filepath.Join(assetsPath, "b.txt"), []byte("b"), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+	record := suite.options.NewAuditRecord("snap", nil)
+	if _, err := createSnapshot(ctx, suite.options, record, snapSettings{}); err != nil || len(record.Manifests) == 0 {
+		suite.T().FailNow()
+	}
+
+	// A directory in the place of the partial file interrupts the download of b.txt
+	for _, name := range []string{"a.txt", "b.txt"} {
+		os.Remove(filepath.Join(assetsPath, name))
+	}
+	partialPath := filepath.Join(assetsPath, ".gasset-partial-b.txt")
+	if err := os.Mkdir(partialPath, 0o755); err != nil {
+		suite.T().FailNow()
+	}
+	assert.Error(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{}, io.Discard, io.Discard))
+	assert.NoFileExists(suite.T(), filepath.Join(assetsPath, "b.txt"))
+
+	queue, err := suite.options.LoadDownloadQueue(string(record.Manifests[0]))
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	assert.Contains(suite.T(), queue.Remaining, "b.txt", "the interrupted file is still queued")
+
+	if err := os.Remove(partialPath); err != nil {
+		suite.T().FailNow()
+	}
+	if !assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{}, io.Discard, io.Discard)) {
+		return
+	}
+	for name, want := range map[string]string{"a.txt": "a", "b.txt": "b"} {
+		content, err := os.ReadFile(filepath.Join(assetsPath, name))
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), want, string(content))
+	}
+
+	queuePath, err := suite.options.GetDownloadQueuePath()
+	if assert.NoError(suite.T(), err) {
+		assert.NoFileExists(suite.T(), queuePath, "a complete restore clears the queue")
+	}
+}
+
+func (suite *RestoreSuite) Test_restoreSnapshots_peers() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	contentPath, err := suite.options.GetRestoredContentPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	userDir, err := suite.options.OsUserConfigDir()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	cacheDir := filepath.Join(userDir, "git-gasset", "peers-"+suite.options.Config.GassetId)
+	suite.T().Cleanup(func() {
+		os.Remove(contentPath)
+		os.RemoveAll(cacheDir)
+		util.DisablePeers()
+	})
+
+	// Without peers the pack blobs are fetched from the storage and kept in the peer cache
+	suite.options.Config.Peers = &util.PeerOptions{}
+	if err := util.EnablePeers(ctx, suite.options); err != nil {
+		suite.T().FailNow()
+	}
+
+	assetPath := filepath.Join(suite.options.WorkingDirectory, "assets", "a.txt")
+	os.Remove(assetPath)
+	assert.NoError(suite.T(), restoreSnapshots(ctx, suite.options, "", restoreSettings{}, io.Discard, io.Discard))
+	content, err := os.ReadFile(assetPath)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "a", string(content))
+	assert.DirExists(suite.T(), cacheDir)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"slices"
+	"strings"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resumes the last interrupted operation",
+	Long: `Resumes the last interrupted operation.
+
+When a snap is interrupted or fails, the directories that were not
+snapshotted and the checkpoints saved for them are recorded. Resume
+runs the operation again for the remaining directories only, continuing
+from the checkpoints, with the machine identity it was started with.`,
+	Args: cobra.NoArgs,
+	RunE: ResumeRun,
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+
+	addTimeoutFlag(resumeCmd)
+}
+
+func ResumeRun(cmd *cobra.Command, _ []string) error {
+	log.Println("resume called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return resume(ctx, options, cmd.OutOrStdout())
+}
+
+func resume(ctx context.Context, op *util.Options, w io.Writer) error {
+	state, err := op.LoadResumeState()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		fmt.Fprintln(w, util.T("Nothing to resume"))
+		return nil
+	}
+
+	fmt.Fprintln(w, util.T("Resuming %s started at %s, remaining: %s",
+		state.Operation, util.FormatTime(state.StartedAt, op.LocalTime), strings.Join(state.RemainingDirs, ", ")))
+
+	if op.MachineIdentity == "" && state.MachineIdentity != "" {
+		if err := op.SetMachineIdentity(state.MachineIdentity); err != nil {
+			return err
+		}
+	}
+
+	switch state.Operation {
+	case "snap":
+		// Directories removed from the .gasset file since the interruption are not snapshotted anymore
+		op.Config.Dirs = slices.DeleteFunc(state.RemainingDirs, func(dir string) bool {
+			return !slices.Contains(op.Config.Dirs, dir)
+		})
+		_, err := snapshotReconnecting(ctx, op, op.NewAuditRecord("resume", nil), defaultSnapSettings(op))
+		return err
+	default:
+		return fmt.Errorf("cannot resume unknown operation %q", state.Operation)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// reviewCmd represents the review command
+var reviewCmd = &cobra.Command{
+	Use:   "review <base-ref> [head-ref]",
+	Short: "Summarizes the asset changes between git refs for a pull request",
+	Long: `Summarizes the asset changes between git refs for a pull request.
+
+The hashes of the working files recorded in the lock files, with
+workingHashes.inLockFile in the .gasset file, are compared between the
+base ref and the head ref, or the lock files of the working tree without
+a head ref. The files added, removed, modified and moved are printed as
+markdown to be posted to the pull request by CI, with their sizes when
+the lock files record them, or as JSON with --json. The repository is not
+read.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: ReviewRun,
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+
+	reviewCmd.Flags().Bool("json", false, "Prints the summary as JSON")
+}
+
+func ReviewRun(cmd *cobra.Command, args []string) error {
+	log.Println("review called")
+
+	// The review only reads the lock files so the storage secrets are not needed
+	options := newOptions()
+	if err := options.InitWorkingDirectory(); err != nil {
+		return err
+	}
+	config, err := util.GetConfig(options.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	options.Config = config
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	head := ""
+	if len(args) == 2 {
+		head = args[1]
+	}
+	return review(&options, args[0], head, asJson, cmd.OutOrStdout())
+}
+
+// review compares the lock files of base with the ones of head, or of the working tree if head is empty
+func review(op *util.Options, base string, head string, asJson bool, w io.Writer) error {
+	before, err := util.GitLockFiles(op.WorkingDirectory, base, op.Config.Dirs)
+	if err != nil {
+		return err
+	}
+
+	var after *util.LockFile
+	headName := head
+	if head == "" {
+		headName = "the working tree"
+		after, err = util.LoadLockFiles(op.WorkingDirectory, op.Config.Dirs)
+	} else {
+		after, err = util.GitLockFiles(op.WorkingDirectory, head, op.Config.Dirs)
+	}
+	if err != nil {
+		return err
+	}
+
+	assetReview, err := util.ReviewLockFiles(base, before, headName, after)
+	if err != nil {
+		return err
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(assetReview)
+	}
+	return assetReview.WriteMarkdown(w)
+}
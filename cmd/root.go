@@ -17,9 +17,22 @@ limitations under the License.
 package cmd
 
 import (
-	"os"
-
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -30,29 +43,593 @@ var rootCmd = &cobra.Command{
 
 It uses the kopia library and a S3 compatible storage solution to backup assets 
 incrementally. This can be used alongside git commits to track changes to assets 
-while Git only tracks changes to the code.`,
+while Git only tracks changes to the code.
+
+The kopia configs, caches and local state are kept in the user config directory
+of the os. Set GASSET_CONFIG_HOME, or "configHome" in an untracked .gasset.local
+file in the root of the git repository, to keep them somewhere else.
+
+A crash writes an anonymous crash dump, without the strings of the .gasset
+file, the home and working directories or the names of the user and the
+host, into the crashes directory next to them. It is only sent anywhere
+with --crash-report-url.
+
+With --sandbox the snapshots are taken into sources of their own, which
+are neither listed nor restored without --sandbox and the same name, e.g.
+to try out a pipeline against the production repository without the team
+seeing its snapshots.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: startSummary,
+}
+
+// summary collects the outcome of the running command, it is nil when the commands are not run through Execute
+var summary *util.Summary
+
+// events writes the events of the --json mode, it is nil in the text mode
+var events *util.EventWriter
+
+// startSummary starts the summary of the commands doing actual work, not of help or shell completion
+func startSummary(cmd *cobra.Command, _ []string) {
+	if cmd.Hidden || cmd.Name() == "help" || (cmd.HasParent() && cmd.Parent().Name() == "completion") {
+		return
+	}
+	summary = util.NewSummary(commandName(cmd), time.Now())
+
+	if asJson, err := cmd.Flags().GetBool("json"); err == nil && asJson {
+		startJsonMode(cmd)
+		return
+	}
+	log.SetOutput(summary.LogWriter(os.Stderr))
+}
+
+// startJsonMode writes the events of the command and its result as JSON lines on stdout and the logs as
+// JSON lines on stderr. The commands with a --json flag of their own print a JSON document on stdout,
+// their result goes to stderr so that the document stays parseable.
+func startJsonMode(cmd *cobra.Command) {
+	var output io.Writer = os.Stdout
+	if cmd.LocalFlags().Lookup("json") != nil {
+		output = os.Stderr
+	}
+	events = util.NewEventWriter(output)
+
+	log.SetFlags(0)
+	log.SetOutput(summary.LogWriter(util.NewEventWriter(os.Stderr).LogWriter()))
+	// The error is part of the result event
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	defer handleCrash()
 	err := rootCmd.Execute()
+	kopiaDebug.LogMetrics()
+	if summary != nil {
+		log.SetOutput(os.Stderr)
+		if events != nil {
+			events.Emit(summary.Event(err, time.Now()))
+		} else {
+			log.Println(summary.Line(err, time.Now()))
+		}
+		// Commands going on after some items failed still have to fail the process
+		if err == nil && summary.HasFailures() {
+			os.Exit(1)
+		}
+	}
 	if err != nil {
+		var exitCodeErr *exitCodeError
+		if errors.As(err, &exitCodeErr) {
+			os.Exit(exitCodeErr.code)
+		}
 		os.Exit(1)
 	}
 }
 
+// handleCrash writes a crash dump of a panic of the command, and submits it with --crash-report-url, before exiting
+// like the panic would have. The panics of the goroutines started by kopia still crash without a dump.
+func handleCrash() {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	stack := debug.Stack()
+	log.SetOutput(os.Stderr)
+	fmt.Fprintf(os.Stderr, "panic: %v\n\n%s\n", recovered, stack)
+
+	command := rootCmd
+	if found, _, err := rootCmd.Find(os.Args[1:]); err == nil {
+		command = found
+	}
+	var flags []string
+	command.Flags().Visit(func(flag *pflag.Flag) {
+		flags = append(flags, flag.Name)
+	})
+	reportURL, _ := rootCmd.PersistentFlags().GetString("crash-report-url")
+
+	options := newOptions()
+	config := loadCrashOptions(&options)
+	recordCrash(context.Background(), &options, util.NewCrashDump(commandName(command), flags, recovered, stack, config, time.Now()), reportURL)
+	os.Exit(2)
+}
+
+// loadCrashOptions loads the working directory and applies the config home like loadOptions does, so that the
+// crash dump lands next to the other local state. It returns the .gasset file for the dump, nil if it can't be read.
+func loadCrashOptions(op *util.Options) *util.Config {
+	if err := op.InitWorkingDirectory(); err != nil {
+		// GASSET_CONFIG_HOME still applies outside of a git repository
+		if op.WorkingDirectory == "" {
+			_ = op.ApplyConfigHome()
+		}
+		return nil
+	}
+	config, err := util.GetConfig(op.WorkingDirectory)
+	if err != nil {
+		return nil
+	}
+	return config
+}
+
+// recordCrash writes the crash dump into the crash dumps directory and submits it if a report URL is given
+func recordCrash(ctx context.Context, op *util.Options, dump *util.CrashDump, reportURL string) {
+	dir, err := op.GetCrashDir()
+	if err == nil {
+		var path string
+		if path, err = dump.Write(dir); err == nil {
+			log.Printf("The crash dump is written to %s, attach it when reporting the crash", path)
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: could not write the crash dump: %v", err)
+	}
+
+	if reportURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := util.SendWebhook(ctx, &http.Client{}, reportURL, dump); err != nil {
+		log.Printf("Warning: could not submit the crash dump: %v", err)
+		return
+	}
+	log.Printf("Submitted the crash dump to %s", reportURL)
+}
+
+// exitCodeError makes the process exit with a specific status to report an outcome that is not a failure
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// newExitCodeError returns an exitCodeError, cobra doesn't print it since the outcome was already reported
+func newExitCodeError(cmd *cobra.Command, code int, err error) error {
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &exitCodeError{code: code, err: err}
+}
+
 func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.git-gasset.yaml)")
+	rootCmd.PersistentFlags().String("machine-identity", "", "Uses a deterministic machine identity instead of the hostname and username, e.g. for CI agents")
+	rootCmd.PersistentFlags().String("storage", os.Getenv("GASSET_STORAGE"), "Storage as a URL overriding the one of the .gasset file, e.g. s3://bucket/prefix/?endpoint=nyc3.digitaloceanspaces.com b2://bucket/prefix/ or file:///mnt/assets (default is $GASSET_STORAGE)")
+	addInputFlags(rootCmd)
+	rootCmd.PersistentFlags().String("sandbox", os.Getenv("GASSET_SANDBOX"), "Snapshots into, lists and restores from the sources of the named sandbox instead of the ones of the asset directories (default is $GASSET_SANDBOX)")
+	rootCmd.PersistentFlags().String("temp-dir", os.Getenv("GASSET_TEMP_DIR"), "Temp directory, also used to stage restored files which requires it to be on the same filesystem as the assets (default is $GASSET_TEMP_DIR)")
+	rootCmd.PersistentFlags().String("chaos", "", "Injects storage failures for developing retry and resume, e.g. error=0.1,partial=0.05,latency=200ms,seed=42, requires "+util.EnvAllowChaos+"=1")
+	rootCmd.PersistentFlags().MarkHidden("chaos")
+	rootCmd.PersistentFlags().Bool("local-time", false, "Shows times in the local time zone instead of UTC")
+	rootCmd.PersistentFlags().Bool("allow-insecure", false, "Uses a storage reached without TLS or without verifying its certificate, or a publicly readable bucket")
+	rootCmd.PersistentFlags().Bool("kopia-debug", false, "Passes the internal logs of kopia, every storage access and the internal metrics of the repository into the log, e.g. to diagnose slow uploads")
+	rootCmd.PersistentFlags().Bool("json", false, "Prints the results as JSON lines on stdout and the logs as JSON lines on stderr, for CI tooling")
+	rootCmd.PersistentFlags().String("crash-report-url", os.Getenv(util.EnvCrashReportURL), "Submits the anonymous crash dump of a crash to the URL, it is only written to the user config directory without it (default is $"+util.EnvCrashReportURL+")")
+	rootCmd.PersistentFlags().String("kopia-config", os.Getenv(util.EnvKopiaConfigPath), "Uses an existing kopia config connected to the repository of the .gasset file instead of the one managed by gasset (default is $"+util.EnvKopiaConfigPath+")")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	//rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+// newOptions returns the options backed by the real os, kopia and rand implementations
+func newOptions() util.Options {
+	options := util.Options{
+		GassetIdLength:  8,
+		Reconnect:       util.DefaultReconnectBackoff,
+		OsGetwd:         os.Getwd,
+		OsTempDir:       os.TempDir,
+		OsUserConfigDir: os.UserConfigDir,
+		RandIntn:        rand.Intn,
+	}
+	options.SetStorageFactory(util.KopiaStorageFactory{})
+	options.SetRepoController(util.KopiaRepoController{})
+	options.SetPolicyManager(util.KopiaPolicyManager{})
+	options.SetKeyring(util.OSKeyring{})
+	return options
+}
+
+// loadOptions creates the options and loads the working directory and the config from the .gasset file
+func loadOptions(cmd *cobra.Command) (*util.Options, error) {
+	options := newOptions()
+
+	machineIdentity, err := cmd.Flags().GetString("machine-identity")
+	if err != nil {
+		return nil, err
+	}
+	if err := options.SetMachineIdentity(machineIdentity); err != nil {
+		return nil, err
+	}
+
+	options.TempDirectory, err = cmd.Flags().GetString("temp-dir")
+	if err != nil {
+		return nil, err
+	}
+	options.KopiaConfigPath, err = cmd.Flags().GetString("kopia-config")
+	if err != nil {
+		return nil, err
+	}
+	if err := loadInputFlags(cmd, &options); err != nil {
+		return nil, err
+	}
+	options.Command = commandName(cmd)
+	options.LocalTime, err = cmd.Flags().GetBool("local-time")
+	if err != nil {
+		return nil, err
+	}
+	if err := loadSandbox(cmd, &options); err != nil {
+		return nil, err
+	}
+
+	if err := options.InitWorkingDirectory(); err != nil {
+		return nil, err
+	}
+
+	if err := options.ReloadKopiaConfig(); err != nil {
+		return nil, err
+	}
+	if err := options.RegisterCheckout(time.Now()); err != nil {
+		log.Printf("Warning: could not register the checkout for housekeeping: %v", err)
+	}
+
+	if err := checkInsecure(cmd, util.InsecureTransport(options.Config)); err != nil {
+		return nil, err
+	}
+
+	if err := enableChaos(cmd, &options); err != nil {
+		return nil, err
+	}
+	if err := enableKopiaDebug(cmd, &options); err != nil {
+		return nil, err
+	}
+
+	return &options, nil
+}
+
+// loadSandbox sets the sandbox of --sandbox, whose snapshots are kept apart from the ones of the team
+func loadSandbox(cmd *cobra.Command, op *util.Options) error {
+	sandbox, err := cmd.Flags().GetString("sandbox")
+	if err != nil || sandbox == "" {
+		return err
+	}
+	if err := util.CheckSandboxName(sandbox); err != nil {
+		return err
+	}
+	op.Sandbox = sandbox
+	log.Printf("Using the sandbox %s, its snapshots are only listed and restored with --sandbox %s", sandbox, sandbox)
+	return nil
+}
+
+// addInputFlags adds the global flags replacing the inputs of the .gasset file, the .env file and the prompts
+func addInputFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("bucket", "", "S3 bucket overriding the storage of the .gasset file, like --storage s3://bucket/")
+	cmd.PersistentFlags().String("endpoint", "", "Endpoint of the --bucket (default is "+util.DefaultS3Endpoint+")")
+	cmd.PersistentFlags().String("prefix", "", "Prefix of the files in the --bucket")
+	cmd.PersistentFlags().Bool("password-stdin", false, "Reads the password of the repository from the first line of stdin, it is preferred to the keyring and the .env file")
+	cmd.PersistentFlags().Bool("non-interactive", false, "Fails on a missing input instead of prompting for it, the secrets are read from the environment without a .env file, e.g. in CI")
+}
+
+// loadInputFlags sets the storage, the password and the non-interactive mode of the flags which replace the .gasset
+// file and the .env file, e.g. in CI
+func loadInputFlags(cmd *cobra.Command, op *util.Options) error {
+	var err error
+	if op.NonInteractive, err = cmd.Flags().GetBool("non-interactive"); err != nil {
+		return err
+	}
+	if op.StorageURL, err = storageFlag(cmd); err != nil {
+		return err
+	}
+
+	passwordStdin, err := cmd.Flags().GetBool("password-stdin")
+	if err != nil || !passwordStdin {
+		return err
+	}
+	if isTerminal(cmd.InOrStdin()) {
+		if err := checkInteractive(cmd, "the password", "pipe it into stdin"); err != nil {
+			return err
+		}
+	}
+	if op.GivenPassword, err = readPassword(cmd.InOrStdin(), cmd.ErrOrStderr()); err != nil {
+		return err
+	}
+	if op.GivenPassword == "" {
+		return errors.New("--password-stdin read an empty password")
+	}
+	return nil
+}
+
+// storageFlag returns the storage URL of --storage, or the one of the s3 bucket of --bucket, --endpoint and --prefix
+func storageFlag(cmd *cobra.Command) (string, error) {
+	spec, err := cmd.Flags().GetString("storage")
+	if err != nil {
+		return "", err
+	}
+	bucket, err := cmd.Flags().GetString("bucket")
+	if err != nil {
+		return "", err
+	}
+	endpoint, err := cmd.Flags().GetString("endpoint")
+	if err != nil {
+		return "", err
+	}
+	prefix, err := cmd.Flags().GetString("prefix")
+	if err != nil {
+		return "", err
+	}
+
+	if bucket == "" {
+		if endpoint != "" || prefix != "" {
+			return "", errors.New("--endpoint and --prefix require --bucket")
+		}
+		return spec, nil
+	}
+	if cmd.Flags().Changed("storage") {
+		return "", errors.New("--bucket and --storage both give the storage, use one of them")
+	}
+	return util.S3StorageURL(bucket, prefix, endpoint), nil
+}
+
+// checkInteractive fails in the non-interactive mode, which can't prompt for the input, with what to do instead
+func checkInteractive(cmd *cobra.Command, input string, instead string) error {
+	nonInteractive, err := cmd.Flags().GetBool("non-interactive")
+	if err != nil || !nonInteractive {
+		return err
+	}
+	return fmt.Errorf("the non-interactive mode can't prompt for %s, %s", input, instead)
+}
+
+// kopiaDebug passes the internal logs of kopia into the log with --kopia-debug, it is nil without it
+var kopiaDebug *util.KopiaDebug
+
+// enableKopiaDebug passes the internal logs and metrics of kopia into the log with --kopia-debug
+func enableKopiaDebug(cmd *cobra.Command, op *util.Options) error {
+	enabled, err := cmd.Flags().GetBool("kopia-debug")
+	if err != nil || !enabled {
+		return err
+	}
+	kopiaDebug = util.EnableKopiaDebug(op, log.Writer())
+	return nil
+}
+
+// enableChaos injects the storage failures of the hidden --chaos flag, which is only accepted with GASSET_ALLOW_CHAOS=1
+func enableChaos(cmd *cobra.Command, op *util.Options) error {
+	spec, err := cmd.Flags().GetString("chaos")
+	if err != nil || spec == "" {
+		return err
+	}
+	if os.Getenv(util.EnvAllowChaos) != "1" {
+		return fmt.Errorf("--chaos is a developer mode, set %s=1 to use it", util.EnvAllowChaos)
+	}
+
+	chaos, err := util.ParseChaosOptions(spec)
+	if err != nil {
+		return err
+	}
+	util.EnableChaos(op, chaos)
+	log.Printf("Warning: chaos mode injects storage failures (%s, seed=%d)", spec, chaos.Seed)
+	return nil
+}
+
+// checkInsecure fails on the problems exposing the assets unless --allow-insecure acknowledges them
+func checkInsecure(cmd *cobra.Command, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	allowInsecure, err := cmd.Flags().GetBool("allow-insecure")
+	if err != nil {
+		return err
+	}
+	if !allowInsecure {
+		return fmt.Errorf("%s, pass --allow-insecure to use it anyway", strings.Join(problems, ", "))
+	}
+	for _, problem := range problems {
+		log.Printf("Warning: %s", problem)
+	}
+	return nil
+}
+
+// addConfirmFlags adds the --yes flag to commands deleting remote data
+func addConfirmFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("yes", false, "Skips the confirmation, requires "+util.EnvAllowDestructive+"=1 to be set as well")
+}
+
+// confirmDestructive asks for the gasset id to be typed unless --yes is set and allowed by the environment
+func confirmDestructive(cmd *cobra.Command, op *util.Options, action string) error {
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+	if !yes {
+		if err := checkInteractive(cmd, "the confirmation", "pass --yes with "+util.EnvAllowDestructive+"=1"); err != nil {
+			return err
+		}
+	}
+	return util.ConfirmDestructive(cmd.InOrStdin(), cmd.ErrOrStderr(), op.Config.GassetId, action, yes)
+}
+
+// addOverrideArchivalFlag adds the --override-archival flag to commands deleting snapshots
+func addOverrideArchivalFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("override-archival", false, "Deletes snapshots even though the .gasset file marks the repository as archival")
+}
+
+// checkArchival refuses to delete snapshots of an archival repository unless --override-archival is set
+func checkArchival(cmd *cobra.Command, op *util.Options) error {
+	override, err := cmd.Flags().GetBool("override-archival")
+	if err != nil {
+		return err
+	}
+	return op.Config.CheckDeletion(override)
+}
+
+// addMaintenanceLockFlags adds the flags of the commands taking the maintenance lock of the repository
+func addMaintenanceLockFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("steal-lock", false, "Removes the maintenance lock of another user even though it did not expire")
+	cmd.Flags().Duration("lock-ttl", util.DefaultMaintenanceLockTTL, "Expires the maintenance lock after the given duration if the command dies without releasing it")
+}
+
+func maintenanceLockOptions(cmd *cobra.Command) (util.MaintenanceLockOptions, error) {
+	steal, err := cmd.Flags().GetBool("steal-lock")
+	if err != nil {
+		return util.MaintenanceLockOptions{}, err
+	}
+	ttl, err := cmd.Flags().GetDuration("lock-ttl")
+	if err != nil {
+		return util.MaintenanceLockOptions{}, err
+	}
+	return util.MaintenanceLockOptions{TTL: ttl, Steal: steal}, nil
+}
+
+// acquireMaintenanceLock takes the maintenance lock of the repository, the returned function releases it
+func acquireMaintenanceLock(ctx context.Context, op *util.Options, rep repo.Repository, command string, lockOptions util.MaintenanceLockOptions) (func(), error) {
+	lock, err := op.AcquireMaintenanceLock(ctx, rep, command, lockOptions, time.Now())
+	if errors.Is(err, util.ErrMaintenanceLocked) {
+		return nil, fmt.Errorf("%w, wait for it to finish or pass --steal-lock if it died", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		// The lock is released even when the command timed out, otherwise it is held until it expires
+		if err := op.ReleaseMaintenanceLock(context.WithoutCancel(ctx), rep, lock); err != nil {
+			log.Printf("Warning: could not release the maintenance lock, it expires at %s: %v", lock.ExpiresAt.Format(time.RFC3339), err)
+		}
+	}, nil
+}
+
+// commandName returns the path of a command without the root command, e.g. "config drift"
+func commandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// newAuditRecord returns the audit record of a mutating command
+func newAuditRecord(cmd *cobra.Command, op *util.Options, args []string) *util.AuditRecord {
+	return op.NewAuditRecord(commandName(cmd), args)
+}
+
+// addTimeoutFlag adds the --timeout flag to commands that can be bounded by a deadline
+func addTimeoutFlag(cmd *cobra.Command) {
+	cmd.Flags().Duration("timeout", 0, "Aborts the operation if it does not finish within the given duration, 0 disables it")
+}
+
+// commandContext returns the context of a command, bounded by the --timeout flag if the command has one
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc, error) {
+	ctx := context.Background()
+	if cmd.Flags().Lookup("timeout") == nil {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
+}
+
+// withGracePeriod returns a context which is canceled gracePeriod after ctx is done
+func withGracePeriod(ctx context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(gracePeriod, cancel)
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// addThrottlingFlags adds the flags replacing single throttling limits of the storage while the command runs
+func addThrottlingFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("max-upload-speed", 0, "Limits the upload speed in bytes per second, 0 lifts the limit")
+	cmd.Flags().Float64("max-download-speed", 0, "Limits the download speed in bytes per second, 0 lifts the limit")
+	cmd.Flags().Int("concurrent-writes", 0, "Limits the number of concurrent writes to the storage, 0 lifts the limit")
+	cmd.Flags().Int("concurrent-reads", 0, "Limits the number of concurrent reads from the storage, 0 lifts the limit")
+}
+
+// applyThrottling replaces the throttling limits of the kopia config with the section of the .gasset file for the
+// command, if it has one, and then with the throttling flags which are set
+func applyThrottling(cmd *cobra.Command, op *util.Options, section *throttling.Limits) error {
+	var adjustments []func(limits *throttling.Limits)
+	for _, name := range []string{"max-upload-speed", "max-download-speed"} {
+		if !cmd.Flags().Changed(name) {
+			continue
+		}
+		value, err := cmd.Flags().GetFloat64(name)
+		if err != nil {
+			return err
+		}
+		if value < 0 {
+			return fmt.Errorf("--%s must not be negative", name)
+		}
+		if name == "max-upload-speed" {
+			adjustments = append(adjustments, func(limits *throttling.Limits) { limits.UploadBytesPerSecond = value })
+		} else {
+			adjustments = append(adjustments, func(limits *throttling.Limits) { limits.DownloadBytesPerSecond = value })
+		}
+	}
+	for _, name := range []string{"concurrent-writes", "concurrent-reads"} {
+		if !cmd.Flags().Changed(name) {
+			continue
+		}
+		value, err := cmd.Flags().GetInt(name)
+		if err != nil {
+			return err
+		}
+		if value < 0 {
+			return fmt.Errorf("--%s must not be negative", name)
+		}
+		if name == "concurrent-writes" {
+			adjustments = append(adjustments, func(limits *throttling.Limits) { limits.ConcurrentWrites = value })
+		} else {
+			adjustments = append(adjustments, func(limits *throttling.Limits) { limits.ConcurrentReads = value })
+		}
+	}
+
+	if section == nil && len(adjustments) == 0 {
+		return nil
+	}
+	op.OverrideThrottling(section, func(limits *throttling.Limits) {
+		for _, adjust := range adjustments {
+			adjust(limits)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type RootSuite struct {
+	repoSuite
+}
+
+func TestRootSuite(t *testing.T) {
+	suite.Run(t, new(RootSuite))
+}
+
+func (suite *RootSuite) Test_applyThrottling() {
+	ctx := context.Background()
+	tests := []struct {
+		name            string
+		concurrentReads string
+		wantSetErr      assert.ErrorAssertionFunc
+		wantErr         assert.ErrorAssertionFunc
+	}{
+		{
+			name:            "Fail on a flag which is not a number",
+			concurrentReads: "x",
+			wantSetErr:      assert.Error,
+		},
+		{
+			name:            "Fail on a negative number of concurrent reads",
+			concurrentReads: "-1",
+			wantSetErr:      assert.NoError,
+			wantErr:         assert.Error,
+		},
+		{
+			name:            "Override the limits of the config",
+			concurrentReads: "2",
+			wantSetErr:      assert.NoError,
+			wantErr:         assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cmd := &cobra.Command{}
+			addThrottlingFlags(cmd)
+			if !tt.wantSetErr(suite.T(), cmd.Flags().Set("concurrent-reads", tt.concurrentReads)) || tt.wantErr == nil {
+				return
+			}
+			tt.wantErr(suite.T(), applyThrottling(cmd, suite.options, &throttling.Limits{UploadBytesPerSecond: 1 << 30}))
+		})
+	}
+
+	// The repository opens from the copy of the kopia config with the overridden limits
+	_, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{})
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *RootSuite) Test_checkArchival() {
+	tests := []struct {
+		name     string
+		archival bool
+		wantErr  error
+	}{
+		{
+			name:     "Refuse to delete the snapshots of an archival repository",
+			archival: true,
+			wantErr:  util.ErrArchival,
+		},
+		{
+			name:     "Delete the snapshots of a repository which is not archival",
+			archival: false,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.options.Config.Archival = tt.archival
+			assert.ErrorIs(suite.T(), checkArchival(pruneCmd, suite.options), tt.wantErr)
+		})
+	}
+	suite.options.Config.Archival = true
+	assert.Equal(suite.T(), "archival, every snapshot is kept", retentionDescription(suite.options.Config))
+}
+
+func (suite *RootSuite) Test_commandContext() {
+	tests := []struct {
+		name         string
+		timeoutFlag  bool
+		args         []string
+		wantDeadline bool
+	}{
+		{
+			name: "Run without a deadline without the timeout flag",
+		},
+		{
+			name:        "Run without a deadline by default",
+			timeoutFlag: true,
+		},
+		{
+			name:        "Run without a deadline with a timeout of 0",
+			timeoutFlag: true,
+			args:        []string{"--timeout", "0"},
+		},
+		{
+			name:         "Bound the command by the timeout",
+			timeoutFlag:  true,
+			args:         []string{"--timeout", "1h"},
+			wantDeadline: true,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cmd := &cobra.Command{}
+			if tt.timeoutFlag {
+				addTimeoutFlag(cmd)
+			}
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				suite.T().FailNow()
+			}
+			ctx, cancel, err := commandContext(cmd)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+			deadline, ok := ctx.Deadline()
+			assert.Equal(suite.T(), tt.wantDeadline, ok)
+			if tt.wantDeadline {
+				assert.WithinDuration(suite.T(), time.Now().Add(time.Hour), deadline, time.Minute)
+			}
+			cancel()
+			assert.Error(suite.T(), ctx.Err(), "the context is canceled by its cancel function")
+		})
+	}
+}
+
+func (suite *RootSuite) Test_withGracePeriod() {
+	ctx, cancel := context.WithCancel(context.Background())
+	graceCtx, cancelGrace := withGracePeriod(ctx, 50*time.Millisecond)
+	defer cancelGrace()
+
+	cancel()
+	assert.NoError(suite.T(), graceCtx.Err(), "the context is done before the grace period is over")
+	assert.Eventually(suite.T(), func() bool {
+		return graceCtx.Err() != nil
+	}, time.Second, 10*time.Millisecond, "the context is not done after the grace period")
+
+	// Its cancel function ends it right away
+	graceCtx, cancelGrace = withGracePeriod(context.Background(), time.Hour)
+	cancelGrace()
+	assert.Error(suite.T(), graceCtx.Err())
+}
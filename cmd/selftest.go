@@ -0,0 +1,193 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+// selftestCmd represents the selftest command
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Snapshots and restores a throwaway source to prove the repository works",
+	Long: `Snapshots and restores a throwaway source to prove the repository works.
+
+A few small files of random bytes are written to a temporary directory,
+snapshotted into the repository with the credentials, the cache and the
+policies of the .gasset file like snap does, restored into another
+temporary directory and compared byte by byte with the originals. It is
+meant to be run before a team relies on the repository for a big drop.
+
+The snapshot is a source of its own which is never listed or restored
+with the asset directories, it is deleted at the end together with the
+temporary directories. Its contents are removed by the next full
+maintenance.`,
+	Args: cobra.NoArgs,
+	RunE: SelftestRun,
+}
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+	addTimeoutFlag(selftestCmd)
+}
+
+func SelftestRun(cmd *cobra.Command, _ []string) error {
+	log.Println("selftest called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return selftest(ctx, options, cmd.OutOrStdout())
+}
+
+// selftest snapshots a throwaway source, restores it into a temporary directory and verifies its bytes,
+// printing every step as it succeeds
+func selftest(ctx context.Context, op *util.Options, w io.Writer) error {
+	start := time.Now()
+
+	sourcePath, err := os.MkdirTemp(op.TempDir(), "gasset-selftest-source-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(sourcePath)
+	source, err := util.NewSelftestSource(sourcePath)
+	if err != nil {
+		return fmt.Errorf("could not write the source: %w", err)
+	}
+	fmt.Fprintf(w, "write\tok\t%d bytes in %s\n", source.Bytes(), sourcePath)
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return fmt.Errorf("could not open the repository: %w", err)
+	}
+	defer rep.Close(context.WithoutCancel(ctx))
+	fmt.Fprintln(w, "connect\tok")
+
+	id, err := snapshotSelftestSource(ctx, op, rep, source)
+	if err != nil {
+		return fmt.Errorf("could not snapshot the source: %w", err)
+	}
+	defer func() {
+		// The snapshot is deleted even if the restore failed or the command timed out
+		cleanupCtx := context.WithoutCancel(ctx)
+		err := op.RepoWriteSession(cleanupCtx, rep, repo.WriteSessionOptions{
+			Purpose: op.SessionPurpose("Delete self test snapshot"),
+		}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+			return writer.DeleteManifest(ctx, id)
+		})
+		if err != nil {
+			log.Printf("Warning: could not delete the self test snapshot %s, delete it with kopia snapshot delete: %v", id, err)
+			return
+		}
+		fmt.Fprintln(w, "cleanup\tok")
+	}()
+	fmt.Fprintf(w, "snapshot\tok\t%s\n", id)
+
+	targetPath, err := os.MkdirTemp(op.TempDir(), "gasset-selftest-target-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(targetPath)
+	if err := restoreSelftestSnapshot(ctx, rep, id, targetPath); err != nil {
+		return fmt.Errorf("could not restore the snapshot: %w", err)
+	}
+	fmt.Fprintln(w, "restore\tok")
+
+	if err := source.Verify(targetPath); err != nil {
+		return fmt.Errorf("the restored files differ from the source: %w", err)
+	}
+	fmt.Fprintln(w, "verify\tok")
+
+	log.Printf("Self test passed in %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// snapshotSelftestSource snapshots the source with the policies of the .gasset file as a source of its own
+func snapshotSelftestSource(ctx context.Context, op *util.Options, rep repo.Repository, source *util.SelftestSource) (manifest.ID, error) {
+	fsEntry, err := localfs.NewEntry(source.Path)
+	if err != nil {
+		return "", err
+	}
+	// The name of the source is unique so that no previous snapshot is reused
+	info := op.SourceInfo(rep.ClientOptions(), ".gasset-selftest-"+util.GenerateRandomString(op.GassetIdLength, op.RandIntn))
+
+	var id manifest.ID
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Create self test snapshot"),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		skipIdentical := false
+		id, err = snapshotSingleSource(ctx, fsEntry, writer, snapshotfs.NewUploader(writer), info, sourceSnapshotOptions{
+			policyOverride: op.Config.SnapshotPolicy(nil),
+			skipIdentical:  &skipIdentical,
+		})
+		return err
+	})
+	return id, err
+}
+
+// restoreSelftestSnapshot restores the snapshot into the empty directory at targetPath
+func restoreSelftestSnapshot(ctx context.Context, rep repo.Repository, id manifest.ID, targetPath string) error {
+	if err := rep.Refresh(ctx); err != nil {
+		return err
+	}
+	man, err := snapshot.LoadSnapshot(ctx, rep, id)
+	if err != nil {
+		return err
+	}
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	if err != nil {
+		return err
+	}
+
+	output := &restore.FilesystemOutput{
+		TargetPath:             targetPath,
+		OverwriteDirectories:   true,
+		IgnorePermissionErrors: true,
+		SkipOwners:             true,
+	}
+	if err := output.Init(ctx); err != nil {
+		return err
+	}
+	_, err = restore.Entry(ctx, rep, output, root, restore.Options{RestoreDirEntryAtDepth: math.MaxInt32})
+	return err
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+)
+
+type SelftestSuite struct {
+	repoSuite
+}
+
+func TestSelftestSuite(t *testing.T) {
+	suite.Run(t, new(SelftestSuite))
+}
+
+func (suite *SelftestSuite) Test_selftest() {
+	ctx := context.Background()
+	suite.options.TempDirectory = suite.T().TempDir()
+
+	var output bytes.Buffer
+	if !assert.NoError(suite.T(), selftest(ctx, suite.options, &output)) {
+		return
+	}
+	for _, step := range []string{"write", "connect", "snapshot", "restore", "verify", "cleanup"} {
+		assert.Contains(suite.T(), output.String(), step+"\tok")
+	}
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+	sources, err := snapshot.ListSources(ctx, rep)
+	if assert.NoError(suite.T(), err) {
+		assert.Empty(suite.T(), sources, "the self test snapshot is deleted")
+	}
+	entries, err := os.ReadDir(suite.options.TempDirectory)
+	if assert.NoError(suite.T(), err) {
+		assert.Empty(suite.T(), entries, "the temporary directories are removed")
+	}
+}
@@ -18,29 +18,42 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"git-gasset/util"
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/localfs"
 	"github.com/kopia/kopia/repo"
-	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 	"github.com/spf13/cobra"
+	"io"
 	"log"
-	"math/rand"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// checkpointGracePeriod is the time given to save a checkpoint of an upload canceled by a timeout
+const checkpointGracePeriod = time.Minute
+
 // snapCmd represents the snap command
 var snapCmd = &cobra.Command{
 	Use:   "snap",
 	Short: "Takes a snapshot of the assets",
 	Long: `Takes a snapshot of the assets.
 
-It uses the locations key in the .gasset.yaml file to determine the 
-assets to be snapshotted.`,
+It snapshots the asset directories listed by the dirs key of the .gasset
+file, leaving out the files matched by the .gassetignore files.`,
 	RunE: SnapRun,
 }
 
@@ -56,116 +69,897 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// snapCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	addTimeoutFlag(snapCmd)
+	addThrottlingFlags(snapCmd)
+	snapCmd.Flags().String("report", "", "Writes every processed file with its action, size, duration and error as JSON lines to the given file")
+	snapCmd.Flags().Bool("defer-retention", false, "Leaves applying the retention policy to prune, defaults to deferRetention of the .gasset file")
+	snapCmd.Flags().Bool("force", false, "Saves the snapshots even if they are identical to the previous ones, e.g. to mark build points")
+	snapCmd.Flags().String("files-from", "", "Snapshots only the files listed in the given file, separated by newlines or NUL characters, - reads the list from stdin")
+	snapCmd.Flags().String("changeset", "", "Groups the snapshots of this run into a changeset with the given name, recorded in "+util.LockFileName)
+	snapCmd.Flags().Bool("if-changed", false, "Exits without connecting to the repository if no file of the asset directories changed since the last snap on this machine, defaults to ifChanged of the .gasset file")
+	snapCmd.Flags().Bool("no-progress", false, "Doesn't draw the progress of the uploads, which is only drawn on a terminal, e.g. for CI logs")
+	snapCmd.Flags().Bool("exit-code", false, "Exits with "+strconv.Itoa(unchangedExitCode)+" if a directory was not saved because it is identical to its previous snapshot")
+}
+
+// unchangedExitCode is the exit status of snap --exit-code when a directory was identical to its previous snapshot
+const unchangedExitCode = 2
+
+// snapSettings are the settings of a snapshot run
+type snapSettings struct {
+	// report records every processed file if it is not nil
+	report *util.Report
+	// progress draws the progress of the uploads if it is not nil
+	progress *util.UploadProgressBar
+	// deferRetention leaves applying the retention policy to prune
+	deferRetention bool
+	// skipIdentical decides if snapshots identical to the previous ones are skipped, the policy decides if it is nil
+	skipIdentical *bool
+	// tags are added to the tags recording the command and the git HEAD
+	tags map[string]string
+	// pins keep the snapshots from being deleted by the retention policy
+	pins []string
+	// changeset collects the saved snapshots if they are grouped into one
+	changeset *util.Changeset
+}
+
+// snapshotTags returns the tags recording the command and the git HEAD together with the tags of the settings
+func (s snapSettings) snapshotTags(op *util.Options) map[string]string {
+	tags := op.SnapshotTags()
+	if len(s.tags) > 0 {
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		maps.Copy(tags, s.tags)
+	}
+	if s.changeset != nil {
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		tags[util.TagChangeset] = s.changeset.ID
+	}
+	return tags
+}
+
+// wrapProgress returns the progress of the upload of the directory, drawn by the progress bar if there is one
+func (s snapSettings) wrapProgress(dirPath string, progress snapshotfs.UploadProgress) snapshotfs.UploadProgress {
+	if s.progress == nil {
+		return progress
+	}
+	return s.progress.Wrap(dirPath, progress)
+}
+
+// onUpload passes the bytes written to the storage by the write session on to the progress bar if there is one
+func (s snapSettings) onUpload(numBytes int64) {
+	if s.progress != nil {
+		s.progress.UploadedBytes(numBytes)
+	}
+}
+
+// uploadProgressBar returns the progress bar of the uploads on stderr, nil with --no-progress, in the --json mode
+// or if stderr is not a terminal, e.g. in CI logs
+func uploadProgressBar(cmd *cobra.Command) (*util.UploadProgressBar, error) {
+	noProgress, err := cmd.Flags().GetBool("no-progress")
+	if err != nil || noProgress || events != nil || !isTerminal(cmd.ErrOrStderr()) {
+		return nil, err
+	}
+	return util.NewUploadProgressBar(cmd.ErrOrStderr(), time.Now), nil
+}
+
+// defaultSnapSettings returns the settings configured in the .gasset file
+func defaultSnapSettings(op *util.Options) snapSettings {
+	return snapSettings{deferRetention: op.Config.DeferRetention, skipIdentical: op.Config.SkipIdenticalSnapshots}
+}
+
+// snapFlagSettings returns the settings configured in the .gasset file overridden by the flags which are set
+func snapFlagSettings(cmd *cobra.Command, op *util.Options) (snapSettings, error) {
+	settings := defaultSnapSettings(op)
+	var err error
+	if cmd.Flags().Changed("defer-retention") {
+		if settings.deferRetention, err = cmd.Flags().GetBool("defer-retention"); err != nil {
+			return settings, err
+		}
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return settings, err
+	}
+	if force {
+		skipIdentical := false
+		settings.skipIdentical = &skipIdentical
+	}
+	return settings, nil
 }
 
-func SnapRun(cmd *cobra.Command, args []string) error {
+func SnapRun(cmd *cobra.Command, _ []string) error {
 	log.Println("snap called")
 
-	options := util.Options{
-		GassetIdLength:   8,
-		OsGetwd:          os.Getwd,
-		OsTempDir:        os.TempDir,
-		OsUserConfigDir:  os.UserConfigDir,
-		RandIntn:         rand.Intn,
-		S3New:            s3.New,
-		RepoConnect:      repo.Connect,
-		RepoInitialize:   repo.Initialize,
-		RepoOpen:         repo.Open,
-		RepoWriteSession: repo.WriteSession,
-		PolicySetPolicy:  policy.SetPolicy,
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+	warnConfigDrift(options)
+	if err := applyThrottling(cmd, options, options.Config.Throttling.SnapLimits()); err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := options.CheckServerConnection(ctx); err != nil {
+		return err
+	}
+
+	settings, err := snapFlagSettings(cmd, options)
+	if err != nil {
+		return err
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	exitCode, err := cmd.Flags().GetBool("exit-code")
+	if err != nil {
+		return err
+	}
+
+	reportPath, err := cmd.Flags().GetString("report")
+	if err != nil {
+		return err
+	}
+	if reportPath != "" {
+		if settings.report, err = util.CreateReport(reportPath); err != nil {
+			return err
+		}
+	}
+
+	if settings.progress, err = uploadProgressBar(cmd); err != nil {
+		return err
+	}
+
+	filesFrom, err := cmd.Flags().GetString("files-from")
+	if err != nil {
+		return err
 	}
 
-	if err := options.InitWorkingDirectory(); err != nil {
+	changesetName, err := cmd.Flags().GetString("changeset")
+	if err != nil {
 		return err
 	}
+	if cmd.Flags().Changed("changeset") {
+		if changesetName == "" {
+			return errors.New("the changeset needs a name")
+		}
+		if options.Sandbox != "" {
+			return fmt.Errorf("the changesets are recorded in %s which is shared with the team, they can't be used in the sandbox %s", util.LockFileName, options.Sandbox)
+		}
+		settings.changeset = options.NewChangeset(changesetName, time.Now())
+	}
 
-	if err := options.ReloadKopiaConfig(); err != nil {
+	ifChanged, err := snapIfChanged(cmd, options)
+	if err != nil {
 		return err
 	}
 
-	return createSnapshot(&options)
+	var unchanged []string
+	var snapState *util.SnapState
+	if filesFrom != "" {
+		unchanged, err = snapshotFilesFrom(ctx, cmd, options, filesFrom, settings)
+	} else {
+		snapState = scanSnapState(ctx, options)
+		if ifChanged && !force && settings.changeset == nil && unchangedSinceLastSnap(options, snapState) {
+			log.Println("No file changed since the last snap, use --force to snapshot anyway")
+			for _, dirPath := range options.Config.Dirs {
+				summary.Add("unchanged", 1)
+				events.Emit(util.Event{Operation: "snapshot", Dir: dirPath, Status: "unchanged"})
+			}
+			if exitCode && len(options.Config.Dirs) > 0 {
+				return newExitCodeError(cmd, unchangedExitCode, fmt.Errorf("%d directories are unchanged", len(options.Config.Dirs)))
+			}
+			return nil
+		}
+		unchanged, err = snapshotReconnecting(ctx, options, newAuditRecord(cmd, options, nil), settings)
+	}
+	if err == nil && snapState != nil {
+		if err := options.SaveSnapState(snapState); err != nil {
+			log.Printf("Warning: could not record the state of the files, the next snap reads them again: %v", err)
+		}
+	}
+	if settings.report != nil {
+		if closeErr := settings.report.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("could not write the report: %w", closeErr))
+		}
+	}
+	if err == nil && exitCode && len(unchanged) > 0 {
+		return newExitCodeError(cmd, unchangedExitCode, fmt.Errorf("%d directories are unchanged", len(unchanged)))
+	}
+	return err
+}
+
+// snapIfChanged tells whether snap exits if no file changed, ifChanged of the .gasset file unless --if-changed is given
+func snapIfChanged(cmd *cobra.Command, op *util.Options) (bool, error) {
+	if !cmd.Flags().Changed("if-changed") {
+		return op.Config.IfChanged, nil
+	}
+	return cmd.Flags().GetBool("if-changed")
 }
 
-func createSnapshot(op *util.Options) error {
-	ctx := context.Background()
+// scanSnapState returns the state of the files before they are snapshotted, so that a change made during the
+// snapshot is seen by the next snap. It returns nil if the files could not be scanned.
+func scanSnapState(ctx context.Context, op *util.Options) *util.SnapState {
+	state, err := op.ScanSnapState(ctx)
+	if err != nil {
+		log.Printf("Could not scan the asset directories for changes: %v", err)
+		return nil
+	}
+	return state
+}
 
-	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+// unchangedSinceLastSnap tells whether the files are in the state recorded by the last snap of all the asset directories
+func unchangedSinceLastSnap(op *util.Options, state *util.SnapState) bool {
+	recorded, err := op.LoadSnapState()
 	if err != nil {
+		log.Printf("Warning: could not read the state of the last snap: %v", err)
+		return false
+	}
+	return state.Matches(recorded)
+}
+
+// snapshotReconnecting snapshots all the asset directories. When the kopia API server goes away during the
+// snapshot, it reconnects with a backoff and resumes from the directories which were not snapshotted yet.
+func snapshotReconnecting(ctx context.Context, op *util.Options, record *util.AuditRecord, settings snapSettings) ([]string, error) {
+	var unchanged []string
+	reconnected := false
+	err := op.RetryServer(ctx, func(ctx context.Context) error {
+		// The remaining directories are snapshotted with a copy of the options, the config stays the one of the .gasset file
+		attemptOptions := op
+		if reconnected {
+			state, err := op.LoadResumeState()
+			if err != nil {
+				return err
+			}
+			if state != nil {
+				attemptOptions = op.Clone()
+				attemptOptions.Config.Dirs = state.RemainingDirs
+				log.Printf("Resuming the snapshot of %s", strings.Join(state.RemainingDirs, ", "))
+			}
+		}
+		reconnected = true
+
+		dirs, err := createSnapshot(ctx, attemptOptions, record, settings)
+		unchanged = append(unchanged, dirs...)
 		return err
+	})
+	return unchanged, err
+}
+
+// snapshotFilesFrom snapshots the files listed in filesFrom, or in stdin if it is -
+func snapshotFilesFrom(ctx context.Context, cmd *cobra.Command, op *util.Options, filesFrom string, settings snapSettings) ([]string, error) {
+	var list io.Reader = cmd.InOrStdin()
+	if passwordStdin, err := cmd.Flags().GetBool("password-stdin"); err == nil && passwordStdin && filesFrom == "-" {
+		return nil, errors.New("--files-from - and --password-stdin both read stdin, give the files in a file instead")
+	}
+	if filesFrom != "-" {
+		file, err := os.Open(filesFrom)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		list = file
+	}
+
+	paths, err := util.ReadFileList(list)
+	if err != nil {
+		return nil, err
+	}
+	return createFileListSnapshot(ctx, op, paths, newAuditRecord(cmd, op, []string{"--files-from", filesFrom}), settings)
+}
+
+// createFileListSnapshot snapshots only the listed paths as a virtual source rooted at the working directory.
+// It returns the source if it was not saved because it is identical to its previous snapshot.
+func createFileListSnapshot(ctx context.Context, op *util.Options, paths []string, record *util.AuditRecord, settings snapSettings) ([]string, error) {
+	tree, err := util.FileListTree(op.WorkingDirectory, paths)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Snapshotting %d listed paths", len(paths))
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
 	}
 
 	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
 	if err != nil {
+		return nil, err
+	}
+	defer rep.Close(context.WithoutCancel(ctx))
+
+	var unchanged []string
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose:  op.SessionPurpose("Create snapshot of a file list"),
+		OnUpload: settings.onUpload,
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		uploader := snapshotfs.NewUploader(writer)
+		if settings.report != nil {
+			uploader.Progress = util.NewUploadReport(settings.report, util.FilesFromSource)
+		}
+		// The listed paths are relative to the root of the git repository in the snapshot
+		listed := util.NewPathCollector(uploader.Progress, "")
+		uploader.Progress = settings.wrapProgress(util.FilesFromSource, listed)
+		defer func() {
+			warnNonPortablePaths(listed.Paths())
+		}()
+
+		// The listed paths are relative to the root of the git repository like the patterns of its .gassetignore file
+		ignored, err := op.GassetIgnorePolicy(".", nil)
+		if err != nil {
+			return err
+		}
+		id, err := snapshotSingleSource(ctx, tree, writer, uploader, op.SourceInfo(rep.ClientOptions(), util.FilesFromSource), sourceSnapshotOptions{
+			policyOverride: op.Config.SnapshotPolicy(ignored),
+			tags:           settings.snapshotTags(op),
+			pins:           settings.pins,
+			applyRetention: !settings.deferRetention && !op.Config.Archival,
+			skipIdentical:  settings.skipIdentical,
+		})
+		if id != "" {
+			summary.Add("snapshots", 1)
+			events.Emit(util.Event{Operation: "snapshot", Dir: util.FilesFromSource, Status: "saved", SnapshotId: string(id)})
+			record.Manifests = append(record.Manifests, id)
+			if settings.changeset != nil {
+				settings.changeset.Snapshots[util.FilesFromSource] = id
+			}
+		} else if err == nil {
+			summary.Add("unchanged", 1)
+			events.Emit(util.Event{Operation: "snapshot", Dir: util.FilesFromSource, Status: "unchanged"})
+			unchanged = append(unchanged, util.FilesFromSource)
+		} else {
+			events.Emit(util.Event{Operation: "snapshot", Dir: util.FilesFromSource, Status: "failed", Error: err.Error()})
+		}
+		record.SetError(err)
+		if auditErr := util.WriteAuditRecord(ctx, writer, record); auditErr != nil {
+			err = errors.Join(err, fmt.Errorf("could not write the audit record: %w", auditErr))
+		}
 		return err
+	})
+	if ctx.Err() == nil {
+		backupConfig(ctx, op, rep)
+	}
+	if err == nil {
+		recordChangeset(op, settings.changeset)
+		if op.Config.Quota != nil {
+			warnStorageQuota(ctx, op, rep)
+		}
+	}
+	return unchanged, err
+}
+
+// snapshotThumbnails updates the thumbnails of the images of a snapshotted directory and snapshots them as a source
+// of their own, tagged with the snapshot they preview, so that the images can be previewed without reading them
+func snapshotThumbnails(ctx context.Context, op *util.Options, rep repo.Repository, writer repo.RepositoryWriter, dirPath string, snapshotId manifest.ID, paths []string, settings snapSettings) error {
+	update, err := op.UpdateThumbnails(ctx, dirPath, paths)
+	if err != nil {
+		// The thumbnails of the images which failed are missing, the others are snapshotted anyway
+		log.Printf("Warning: could not generate all the thumbnails of %s: %v", dirPath, err)
+	}
+	if update.Generated > 0 || update.Removed > 0 {
+		log.Printf("Generated %d and removed %d thumbnails of %s", update.Generated, update.Removed, dirPath)
+	}
+
+	thumbnailDir, err := op.GetThumbnailDirectory(dirPath)
+	if err != nil {
+		return err
+	}
+	fsEntry, err := localfs.NewEntry(thumbnailDir)
+	if err != nil {
+		return err
+	}
+
+	// Every snapshot of the directory gets its thumbnails even if they did not change
+	skipIdentical := false
+	_, err = snapshotSingleSource(ctx, fsEntry, writer, snapshotfs.NewUploader(writer), util.ThumbnailSource(op.SourceInfo(rep.ClientOptions(), dirPath)), sourceSnapshotOptions{
+		tags:           map[string]string{util.TagThumbnailsOf: string(snapshotId)},
+		applyRetention: !settings.deferRetention && !op.Config.Archival,
+		skipIdentical:  &skipIdentical,
+	})
+	return err
+}
+
+// maxPathWarnings bounds the warnings about non-portable paths of a snap, the others are only counted
+const maxPathWarnings = 20
+
+// warnNonPortablePaths warns about the snapshotted paths which can't be restored on Windows or only differ in case,
+// so that the uploader renames them before they break the checkouts of the teammates on other platforms
+func warnNonPortablePaths(paths []string) {
+	problems := util.PortablePathProblems(paths)
+	if len(problems) == 0 {
+		return
+	}
+	for i, problem := range problems {
+		if i == maxPathWarnings {
+			log.Printf("Warning: %d more paths can't be restored on every platform", len(problems)-i)
+			break
+		}
+		log.Printf("Warning: %s", problem)
+	}
+	summary.Add("non-portable paths", len(problems))
+}
+
+// recordChangeset records the changeset of the snapshots in the lock file. The snapshots are saved already
+// and tagged with it, so not being able to record it does not fail the snapshot.
+func recordChangeset(op *util.Options, changeset *util.Changeset) {
+	if changeset == nil {
+		return
 	}
-	defer rep.Close(ctx)
+	if err := op.RecordChangeset(changeset); err != nil {
+		log.Printf("Warning: could not record the changeset %s in %s: %v", changeset.ID, util.LockFileName, err)
+		return
+	}
+	if len(changeset.Snapshots) > 0 {
+		log.Printf("Recorded %d snapshots as changeset %s (%s)", len(changeset.Snapshots), changeset.ID, changeset.Name)
+	}
+}
 
-	return op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
-		Purpose: "Create snapshot",
+// backupConfig stores a copy of the .gasset file and the lock files in the repository for recover-config.
+// Not being able to store it does not fail the snapshot.
+func backupConfig(ctx context.Context, op *util.Options, rep repo.Repository) {
+	backup, err := op.ReadConfigBackup(time.Now())
+	if err != nil {
+		log.Printf("Warning: could not back up the .gasset file: %v", err)
+		return
+	}
+
+	var stored bool
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Back up the .gasset file"),
 	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		stored, err = util.WriteConfigBackup(ctx, writer, backup)
+		return err
+	})
+	if err != nil {
+		log.Printf("Warning: could not back up the .gasset file: %v", err)
+		return
+	}
+	if stored {
+		log.Printf("Backed up %d files of the configuration", len(backup.Files))
+	}
+}
+
+// warnStorageQuota warns when the repository gets close to the quota of the .gasset file.
+// Not being able to check the quota does not fail the snapshot.
+func warnStorageQuota(ctx context.Context, op *util.Options, rep repo.Repository) {
+	usage, err := util.StorageUsage(ctx, rep)
+	if err != nil {
+		log.Printf("Could not check the storage quota: %v", err)
+		return
+	}
+
+	for _, warning := range op.Config.Quota.Warnings(usage) {
+		log.Printf("Warning: %s", warning)
+	}
+}
+
+// createSnapshot snapshots all the asset directories.
+// It returns the directories which were not saved because they are identical to their previous snapshot.
+func createSnapshot(ctx context.Context, op *util.Options, record *util.AuditRecord, settings snapSettings) ([]string, error) {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Close(context.WithoutCancel(ctx))
+
+	// The session outlives the deadline by a grace period so that the canceled upload can be saved as a checkpoint
+	sessionCtx, cancelSession := withGracePeriod(ctx, checkpointGracePeriod)
+	defer cancelSession()
+
+	var unchanged, done []string
+	var state *util.ResumeState
+	var snapErr error
+	var uploaded atomic.Int64
+	err = op.RepoWriteSession(sessionCtx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Create snapshot"),
+		// Keep the snapshots of the directories that succeeded and the checkpoints of the ones that did not
+		FlushOnFailure: true,
+		OnUpload: func(numBytes int64) {
+			uploaded.Add(numBytes)
+			settings.onUpload(numBytes)
+		},
+	}, func(sessionCtx context.Context, writer repo.RepositoryWriter) error {
+		// The state is recorded before anything is uploaded so that even a killed process can be resumed
+		state = &util.ResumeState{
+			Operation:       "snap",
+			RemainingDirs:   append([]string(nil), op.Config.Dirs...),
+			MachineIdentity: op.MachineIdentity,
+			StartedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+		}
+		if err := op.SaveResumeState(state); err != nil {
+			return err
+		}
+
+		// The policies are loaded once for all the directories instead of once per directory
+		policies, err := op.LoadPolicyCache(sessionCtx, rep)
+		if err != nil {
+			log.Printf("Could not cache the policies, loading them per directory: %v", err)
+		}
+
 		uploader := snapshotfs.NewUploader(writer)
 		uploader.MaxUploadBytes = 0 << 20 // 2^20 or 1 MiB
+		stats := op.NewSnapStats(state.StartedAt)
+
+		stopCancel := context.AfterFunc(ctx, uploader.Cancel)
+		defer stopCancel()
 
+		// A failing directory doesn't stop the others from being snapshotted
+		var errs []error
+		var saved []manifest.ID
+		statuses := make([]string, 0, len(op.Config.Dirs))
 		for _, dirPath := range op.Config.Dirs {
-			fsEntry, err := localfs.NewEntry(dirPath)
+			if ctx.Err() != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", dirPath, ctx.Err()))
+				statuses = append(statuses, fmt.Sprintf("%s: skipped", dirPath))
+				events.Emit(util.Event{Operation: "snapshot", Dir: dirPath, Status: "skipped", Error: ctx.Err().Error()})
+				continue
+			}
+
+			var progress snapshotfs.UploadProgress = &snapshotfs.NullUploadProgress{}
+			if settings.report != nil {
+				progress = util.NewUploadReport(settings.report, dirPath)
+			}
+			paths := util.NewPathCollector(progress, filepath.ToSlash(dirPath))
+			uploader.Progress = settings.wrapProgress(dirPath, paths)
+			id, err := snapshotDir(sessionCtx, op, rep, writer, uploader, policies, dirPath, settings)
+			warnNonPortablePaths(paths.Paths())
 			if err != nil {
-				return err
+				summary.AddFailures(1)
+				errs = append(errs, fmt.Errorf("%s: %w", dirPath, err))
+				statuses = append(statuses, fmt.Sprintf("%s: failed", dirPath))
+				events.Emit(util.Event{Operation: "snapshot", Dir: dirPath, Status: "failed", Error: err.Error()})
+
+				var checkpointErr *checkpointError
+				if errors.As(err, &checkpointErr) {
+					saved = append(saved, checkpointErr.checkpoint)
+					state.Checkpoints = append(state.Checkpoints, string(checkpointErr.checkpoint))
+					if err := op.SaveResumeState(state); err != nil {
+						log.Printf("Could not record the checkpoint of %s: %v", dirPath, err)
+					}
+				}
+				continue
 			}
-			info := snapshot.SourceInfo{
-				Host:     rep.ClientOptions().Hostname,
-				UserName: rep.ClientOptions().Username,
-				Path:     filepath.Join(op.WorkingDirectory, dirPath),
+			if id != "" {
+				summary.Add("snapshots", 1)
+				statuses = append(statuses, fmt.Sprintf("%s: ok", dirPath))
+				saved = append(saved, id)
+				man, err := snapshot.LoadSnapshot(sessionCtx, writer, id)
+				if err == nil {
+					stats.AddSnapshot(man)
+				} else {
+					log.Printf("Warning: could not load the statistics of the snapshot of %s: %v", dirPath, err)
+				}
+				events.Emit(snapshotEvent(dirPath, id, man))
+				if settings.changeset != nil {
+					settings.changeset.Snapshots[dirPath] = id
+				}
+				if op.Config.Thumbnails != nil {
+					if err := snapshotThumbnails(sessionCtx, op, rep, writer, dirPath, id, paths.Paths(), settings); err != nil {
+						log.Printf("Warning: could not snapshot the thumbnails of %s: %v", dirPath, err)
+					}
+				}
+			} else {
+				summary.Add("unchanged", 1)
+				statuses = append(statuses, fmt.Sprintf("%s: unchanged, not saved", dirPath))
+				events.Emit(util.Event{Operation: "snapshot", Dir: dirPath, Status: "unchanged"})
+				unchanged = append(unchanged, dirPath)
 			}
 
-			if err := snapshotSingleSource(ctx, fsEntry, writer, uploader, info); err != nil {
-				return err
+			if op.Config.WorkingHashes != nil {
+				if err := recordWorkingHashes(op, dirPath); err != nil {
+					log.Printf("Warning: could not record the hashes of the files in %s: %v", dirPath, err)
+				}
 			}
+
+			// The directory only leaves the state once the session is flushed
+			done = append(done, dirPath)
 		}
-		return nil
+
+		log.Printf("Snapshotted %d of %d directories", len(op.Config.Dirs)-len(errs), len(op.Config.Dirs))
+		for _, status := range statuses {
+			log.Println(status)
+		}
+
+		record.Manifests = append(record.Manifests, saved...)
+		record.SetError(errors.Join(errs...))
+		if err := util.WriteAuditRecord(sessionCtx, writer, record); err != nil {
+			errs = append(errs, fmt.Errorf("could not write the audit record: %w", err))
+		}
+
+		stats.Dirs = len(op.Config.Dirs)
+		stats.FailedDirs = len(op.Config.Dirs) - len(done)
+		stats.UploadedBytes = uploaded.Load()
+		stats.Duration = time.Since(state.StartedAt)
+		if err := util.WriteSnapStats(sessionCtx, writer, stats); err != nil {
+			log.Printf("Warning: could not write the snapshot statistics: %v", err)
+		}
+
+		warnClockSkew(sessionCtx, writer)
+
+		if len(errs) > 0 {
+			log.Println("Run resume to snapshot the remaining directories")
+			snapErr = errors.Join(errs...)
+		}
+		return snapErr
+	})
+	if ctx.Err() == nil {
+		backupConfig(ctx, op, rep)
+	}
+	if err != nil && (snapErr == nil || !errors.Is(err, snapErr)) {
+		// The session could not be flushed, so none of the directories is saved and all of them remain to resume
+		return unchanged, err
+	}
+	recordChangeset(op, settings.changeset)
+
+	if snapErr == nil {
+		if op.Config.Quota != nil {
+			warnStorageQuota(ctx, op, rep)
+		}
+		return unchanged, op.ClearResumeState()
+	}
+	state.RemainingDirs = slices.DeleteFunc(state.RemainingDirs, func(remaining string) bool {
+		return slices.Contains(done, remaining)
 	})
+	state.UpdatedAt = time.Now().UTC()
+	if err := op.SaveResumeState(state); err != nil {
+		log.Printf("Could not record the progress of the snapshot: %v", err)
+	}
+	return unchanged, snapErr
 }
 
-// mostly from github.com/kopia/kopia/cli.commandSnapshotCreate.snapshotSingleSource
-func snapshotSingleSource(ctx context.Context, fsEntry fs.Entry, rep repo.RepositoryWriter, uploader *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo) error {
-	previousManifests, err := findPreviousSnapshotManifest(ctx, rep, sourceInfo)
+// recordWorkingHashes records the hashes of the files of a snapshotted directory for check,
+// in the local state and, if the .gasset file asks for it and outside of a sandbox, in the lock file
+func recordWorkingHashes(op *util.Options, dirPath string) error {
+	recorded, err := op.LoadWorkingHashes()
 	if err != nil {
 		return err
 	}
 
-	policyTree, err := policy.TreeForSource(ctx, rep, sourceInfo)
+	hashes, err := util.HashWorkingFiles(op.WorkingDirectory, dirPath, recorded.Algorithm, recorded)
 	if err != nil {
 		return err
 	}
+	recorded.RecordDir(dirPath, hashes)
+	if err := op.SaveWorkingHashes(recorded); err != nil {
+		return err
+	}
 
-	manifest, err := uploader.Upload(ctx, fsEntry, policyTree, sourceInfo, previousManifests...)
+	// The lock file is shared with the team through git
+	if !op.Config.InLockFile(dirPath) || op.Sandbox != "" {
+		return nil
+	}
+	lockDir := op.Config.LockFileDir(op.WorkingDirectory, dirPath)
+	lock, err := util.LoadLockFile(lockDir)
+	if err != nil {
+		return err
+	}
+	lock.RecordDir(recorded.Algorithm, dirPath, hashes)
+	return util.SaveLockFile(lockDir, lock, op.Config.Permissions)
+}
+
+// warnClockSkew warns when the local clock and the one of the storage disagree, as the snapshots are ordered by the local clock.
+// Not being able to check it does not fail the snapshot.
+func warnClockSkew(ctx context.Context, writer repo.RepositoryWriter) {
+	skew, err := util.StorageClockSkew(ctx, writer)
+	if err != nil {
+		log.Printf("Could not check the clock of the storage: %v", err)
+		return
+	}
+	if skew > util.ClockSkewThreshold || skew < -util.ClockSkewThreshold {
+		log.Printf("Warning: the local clock is %s off the clock of the storage, snapshots may be ordered wrongly until it is fixed", skew.Round(time.Second))
+	}
+}
+
+// checkpointError is returned when an incomplete snapshot was saved as a checkpoint
+type checkpointError struct {
+	path       string
+	reason     string
+	checkpoint manifest.ID
+}
+
+func (e *checkpointError) Error() string {
+	return fmt.Sprintf("snapshot of %s is incomplete: %s", e.path, e.reason)
+}
+
+// snapshotDir returns the id of the saved snapshot, which is empty if an identical snapshot already exists
+func snapshotDir(ctx context.Context, op *util.Options, rep repo.Repository, writer repo.RepositoryWriter, uploader *snapshotfs.Uploader, policies *util.PolicyCache, dirPath string, settings snapSettings) (manifest.ID, error) {
+	fsEntry, err := localfs.NewEntry(filepath.Join(op.WorkingDirectory, dirPath))
+	if err != nil {
+		return "", err
+	}
+	info := op.SourceInfo(rep.ClientOptions(), dirPath)
+
+	gitTracked, err := gitTrackedPolicy(op, dirPath)
 	if err != nil {
+		return "", err
+	}
+	ignored, err := op.GassetIgnorePolicy(dirPath, gitTracked)
+	if err != nil {
+		return "", err
+	}
+	policyOverride := op.Config.SnapshotPolicy(ignored)
+
+	tags := settings.snapshotTags(op)
+	if op.Config.IsDerived(dirPath) {
+		if err := setDerivedRetention(ctx, op, writer, info); err != nil {
+			return "", err
+		}
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		tags[util.TagDerived] = "true"
+	}
+	if len(op.Config.Metrics) > 0 {
+		metrics, err := op.AnalyzeDir(ctx, dirPath)
+		if err != nil {
+			log.Printf("Warning: could not compute all the metrics of %s: %v", dirPath, err)
+		}
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		maps.Copy(tags, util.MetricTags(metrics))
+	}
+
+	return snapshotSingleSource(ctx, fsEntry, writer, uploader, info, sourceSnapshotOptions{
+		policyOverride: policyOverride,
+		policies:       policies,
+		tags:           tags,
+		pins:           settings.pins,
+		applyRetention: !settings.deferRetention && !op.Config.Archival,
+		skipIdentical:  settings.skipIdentical,
+	})
+}
+
+// setDerivedRetention gives the source of a derived directory the retention of the .gasset file, which prune applies as well
+func setDerivedRetention(ctx context.Context, op *util.Options, writer repo.RepositoryWriter, sourceInfo snapshot.SourceInfo) error {
+	defined, err := policy.GetDefinedPolicy(ctx, writer, sourceInfo)
+	if errors.Is(err, policy.ErrPolicyNotFound) {
+		defined = &policy.Policy{}
+	} else if err != nil {
 		return err
 	}
 
+	retention := op.Config.Derived.RetentionPolicy()
+	retention.IgnoreIdenticalSnapshots = defined.RetentionPolicy.IgnoreIdenticalSnapshots
+	if reflect.DeepEqual(defined.RetentionPolicy, retention) {
+		return nil
+	}
+	defined.RetentionPolicy = retention
+	return op.PolicySetPolicy(ctx, writer, sourceInfo, defined)
+}
+
+// gitTrackedPolicy resolves the files of an asset directory which are tracked by git as well.
+// It returns a policy excluding them from the snapshot unless the config includes them or refuses to snapshot them.
+func gitTrackedPolicy(op *util.Options, dirPath string) (*policy.Policy, error) {
+	tracked, err := util.GitTrackedFiles(op.WorkingDirectory, dirPath)
+	if err != nil {
+		log.Printf("Could not check the git tracked files in %s: %v", dirPath, err)
+		return nil, nil
+	}
+	if len(tracked) == 0 {
+		return nil, nil
+	}
+
+	mode := op.Config.GitTrackedMode()
+	for _, trackedPath := range tracked {
+		log.Printf("%s is tracked by git and gasset", path.Join(filepath.ToSlash(dirPath), trackedPath))
+	}
+
+	switch mode {
+	case util.GitTrackedError:
+		return nil, fmt.Errorf("%d files are tracked by git, remove them from git or set gitTracked in the .gasset file", len(tracked))
+	case util.GitTrackedInclude:
+		log.Printf("Snapshotting %d git tracked files in %s", len(tracked), dirPath)
+		return nil, nil
+	default:
+		log.Printf("Excluding %d git tracked files in %s from the snapshot", len(tracked), dirPath)
+		return &policy.Policy{
+			FilesPolicy: policy.FilesPolicy{IgnoreRules: util.GitIgnoreRules(tracked)},
+		}, nil
+	}
+}
+
+// sourceSnapshotOptions are the options of snapshotSingleSource
+type sourceSnapshotOptions struct {
+	// policyOverride is merged into the policy of the source if it is not nil
+	policyOverride *policy.Policy
+	// policies builds the policy tree without looking up the policies in the repository if it is not nil
+	policies       *util.PolicyCache
+	tags           map[string]string
+	pins           []string
+	applyRetention bool
+	skipIdentical  *bool
+}
+
+// mostly from github.com/kopia/kopia/cli.commandSnapshotCreate.snapshotSingleSource
+func snapshotSingleSource(ctx context.Context, fsEntry fs.Entry, rep repo.RepositoryWriter, uploader *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo, sourceOptions sourceSnapshotOptions) (manifest.ID, error) {
+	previousManifests, err := findPreviousSnapshotManifest(ctx, rep, sourceInfo)
+	if err != nil {
+		return "", err
+	}
+
+	var policyTree *policy.Tree
+	if sourceOptions.policies != nil {
+		policyTree = sourceOptions.policies.Tree(sourceInfo, sourceOptions.policyOverride)
+	} else if policyTree, err = policy.TreeForSourceWithOverride(ctx, rep, sourceInfo, sourceOptions.policyOverride); err != nil {
+		return "", err
+	}
+
+	manifest, err := uploader.Upload(ctx, fsEntry, policyTree, sourceInfo, previousManifests...)
+	if err != nil {
+		return "", err
+	}
+
+	// An incomplete snapshot is saved as a checkpoint for the next run to continue from
+	if manifest.IncompleteReason != "" {
+		checkpoint, err := snapshot.SaveSnapshot(ctx, rep, manifest)
+		if err != nil {
+			return "", err
+		}
+		return "", &checkpointError{path: sourceInfo.Path, reason: manifest.IncompleteReason, checkpoint: checkpoint}
+	}
+
 	//Todo: Add a description to the manifest
 	manifest.Description = ""
-	manifest.Tags = nil
+	manifest.Tags = sourceOptions.tags
+	manifest.Pins = sourceOptions.pins
 
-	// Update pinning not required
 	// startTimeOverride and endTimeOverride not required
 
 	ignoreIdenticalSnapshot := policyTree.EffectivePolicy().RetentionPolicy.IgnoreIdenticalSnapshots.OrDefault(false)
+	if sourceOptions.skipIdentical != nil {
+		ignoreIdenticalSnapshot = *sourceOptions.skipIdentical
+	}
 	if ignoreIdenticalSnapshot && len(previousManifests) > 0 {
 		if previousManifests[0].RootObjectID() == manifest.RootObjectID() {
-			log.Println("Not saving snapshot because no files have been changed since previous snapshot")
-			return nil
+			log.Printf("Not saving snapshot of %s because it is identical to snapshot %s, use --force to save it", sourceInfo.Path, previousManifests[0].ID)
+			return "", nil
 		}
 	}
 
-	if _, err = snapshot.SaveSnapshot(ctx, rep, manifest); err != nil {
-		return err
+	id, err := snapshot.SaveSnapshot(ctx, rep, manifest)
+	if err != nil {
+		return "", err
 	}
+	summary.AddBytes(manifest.Stats.TotalFileSize)
 
-	if _, err = policy.ApplyRetentionPolicy(ctx, rep, sourceInfo, false); err != nil {
-		return err
+	// Deferred retention is applied by prune in a controlled window instead
+	if sourceOptions.applyRetention {
+		if _, err = policy.ApplyRetentionPolicy(ctx, rep, sourceInfo, true); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	return id, nil
+}
+
+// snapshotEvent returns the event of a saved snapshot of a directory, with its statistics unless they could not be loaded
+func snapshotEvent(dirPath string, id manifest.ID, man *snapshot.Manifest) util.Event {
+	event := util.Event{Operation: "snapshot", Dir: dirPath, Status: "saved", SnapshotId: string(id)}
+	if man != nil {
+		event.Files = int64(man.Stats.TotalFileCount)
+		event.Bytes = man.Stats.TotalFileSize
+		event.Duration = man.EndTime.Sub(man.StartTime)
+	}
+	return event
 }
 
 // mostly from github.com/kopia/kopia/cli.findPreviousSnapshotManifest
@@ -182,13 +976,17 @@ func findPreviousSnapshotManifest(ctx context.Context, rep repo.Repository, sour
 	var result []*snapshot.Manifest
 
 	for _, manifest := range manifests {
-		if manifest.IncompleteReason == "" && (previousComplete == nil || manifest.StartTime.After(previousComplete.StartTime)) {
+		if manifest.IncompleteReason == "" && (previousComplete == nil || util.SnapshotAfter(manifest, previousComplete)) {
 			previousComplete = manifest
 			previousCompleteStartTime = manifest.StartTime
 		}
 	}
 
 	if previousComplete != nil {
+		if util.IsFromFuture(previousComplete, time.Now()) {
+			log.Printf("Warning: the previous snapshot %s of %s started at %s, which is in the future, the clock of this machine or the one that took it is wrong",
+				previousComplete.ID, sourceInfo.Path, util.FormatTime(previousComplete.StartTime.ToTime(), false))
+		}
 		result = append(result, previousComplete)
 	}
 
@@ -0,0 +1,608 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"git-gasset/util"
+	kopiafs "github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type SnapSuite struct {
+	repoSuite
+}
+
+func TestSnapSuite(t *testing.T) {
+	suite.Run(t, new(SnapSuite))
+}
+
+// newSnapCmd returns a command with the flags of snap which decide its settings
+func (suite *SnapSuite) newSnapCmd() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("defer-retention", false, "")
+	cmd.Flags().Bool("force", false, "")
+	return cmd
+}
+
+func (suite *SnapSuite) Test_createSnapshot() {
+	ctx := context.Background()
+	skipIdentical := true
+	settings := snapSettings{skipIdentical: &skipIdentical}
+
+	unchanged, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), unchanged)
+	assert.Equal(suite.T(), 1, suite.snapshotCount(ctx))
+
+	unchanged, err = createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"./assets"}, unchanged)
+	assert.Equal(suite.T(), 1, suite.snapshotCount(ctx))
+
+	skipIdentical = false
+	unchanged, err = createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), unchanged)
+	assert.Equal(suite.T(), 2, suite.snapshotCount(ctx))
+}
+
+func (suite *SnapSuite) Test_createSnapshot_nonPortablePaths() {
+	for _, name := range []string{"NUL.txt", "A.txt"} {
+		if err := os.WriteFile(filepath.Join(suite.options.WorkingDirectory, "assets", name), []byte(name), 0o644); err != nil {
+			suite.T().FailNow()
+		}
+	}
+
+	previous := summary
+	summary = util.NewSummary("snap", time.Now())
+	defer func() {
+		summary = previous
+	}()
+
+	_, err := createSnapshot(context.Background(), suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{})
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), summary.Line(nil, time.Now()), "2 non-portable paths")
+}
+
+func (suite *SnapSuite) Test_createSnapshot_gassetIgnore() {
+	ctx := context.Background()
+	files := map[string]string{
+		util.GassetIgnoreFileName:                      "*.tmp\n/assets/build/\n",
+		"assets/a.tmp":                                 "a",
+		"assets/build/a.bin":                           "a",
+		"assets/textures/wall.png":                     "wall",
+		"assets/textures/" + util.GassetIgnoreFileName: "cache/\n",
+		"assets/textures/cache/wall.bin":               "wall",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(suite.options.WorkingDirectory, filepath.FromSlash(name))
+		if os.MkdirAll(filepath.Dir(filePath), 0o755) != nil || os.WriteFile(filePath, []byte(content), 0o644) != nil {
+			suite.T().FailNow()
+		}
+	}
+
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); !assert.NoError(suite.T(), err) {
+		return
+	}
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+	manifests, err := listDirSnapshots(ctx, suite.options, rep, "./assets")
+	if err != nil || len(manifests) == 0 {
+		suite.T().FailNow()
+	}
+	root, err := snapshotfs.SnapshotRoot(rep, manifests[0])
+	if err != nil {
+		suite.T().FailNow()
+	}
+	states, err := util.DirFileStates(ctx, root.(kopiafs.Directory), "")
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	var got []string
+	for filePath := range states {
+		got = append(got, filePath)
+	}
+	assert.ElementsMatch(suite.T(), []string{"a.txt", "textures/wall.png", "textures/" + util.GassetIgnoreFileName}, got)
+}
+
+func (suite *SnapSuite) Test_createSnapshot_events() {
+	var output bytes.Buffer
+	previous := events
+	events = util.NewEventWriter(&output)
+	defer func() {
+		events = previous
+	}()
+
+	record := suite.options.NewAuditRecord("snap", nil)
+	if _, err := createSnapshot(context.Background(), suite.options, record, snapSettings{}); err != nil || len(record.Manifests) == 0 {
+		suite.T().FailNow()
+	}
+	var event util.Event
+	if !assert.NoError(suite.T(), json.Unmarshal(output.Bytes(), &event)) {
+		return
+	}
+	assert.Equal(suite.T(), "snapshot", event.Operation)
+	assert.Equal(suite.T(), "saved", event.Status)
+	assert.Equal(suite.T(), "./assets", event.Dir)
+	assert.Equal(suite.T(), string(record.Manifests[0]), event.SnapshotId)
+	assert.Equal(suite.T(), int64(1), event.Files)
+	assert.NotZero(suite.T(), event.Bytes)
+}
+
+func (suite *SnapSuite) Test_createSnapshot_chaos() {
+	ctx := context.Background()
+	skipIdentical := true
+	settings := snapSettings{skipIdentical: &skipIdentical}
+	defer util.DisableChaos()
+
+	// Every write is cut short so the snapshot fails and remains to be resumed
+	util.EnableChaos(suite.options, &util.ChaosOptions{PartialWriteRate: 1, Seed: 1})
+	_, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings)
+	assert.ErrorIs(suite.T(), err, util.ErrChaos)
+
+	state, err := suite.options.LoadResumeState()
+	if !assert.NoError(suite.T(), err) || !assert.NotNil(suite.T(), state) {
+		return
+	}
+	assert.Equal(suite.T(), []string{"./assets"}, state.RemainingDirs)
+
+	util.DisableChaos()
+	_, err = createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, suite.snapshotCount(ctx))
+
+	state, err = suite.options.LoadResumeState()
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), state)
+}
+
+func (suite *SnapSuite) Test_createSnapshot_derived() {
+	ctx := context.Background()
+	skipIdentical := false
+	settings := snapSettings{deferRetention: true, skipIdentical: &skipIdentical}
+	suite.options.Config.Derived = &util.DerivedOptions{Dirs: []string{"assets"}, KeepLatest: 2}
+
+	for i := 0; i < 3; i++ {
+		_, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings)
+		if !assert.NoError(suite.T(), err) {
+			return
+		}
+	}
+	assert.Equal(suite.T(), 3, suite.snapshotCount(ctx))
+
+	// The retention of the derived directory is applied by prune
+	if !assert.NoError(suite.T(), prune(ctx, suite.options, false, util.MaintenanceLockOptions{}, io.Discard, suite.options.NewAuditRecord("prune", nil))) {
+		return
+	}
+	assert.Equal(suite.T(), 2, suite.snapshotCount(ctx))
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+
+	manifests, err := listDirSnapshots(ctx, suite.options, rep, "./assets")
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	for _, man := range manifests {
+		assert.Equal(suite.T(), "true", man.Tags[util.TagDerived])
+	}
+}
+
+func (suite *SnapSuite) Test_createFileListSnapshot() {
+	ctx := context.Background()
+	record := suite.options.NewAuditRecord("snap", nil)
+
+	unchanged, err := createFileListSnapshot(ctx, suite.options, []string{"assets/a.txt"}, record, defaultSnapSettings(suite.options))
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), unchanged)
+	assert.Len(suite.T(), record.Manifests, 1)
+	assert.Equal(suite.T(), 0, suite.snapshotCount(ctx))
+
+	_, err = createFileListSnapshot(ctx, suite.options, []string{"assets/missing.txt"}, record, defaultSnapSettings(suite.options))
+	assert.Error(suite.T(), err)
+}
+
+func (suite *SnapSuite) Test_createSnapshot_changeset() {
+	ctx := context.Background()
+	settings := snapSettings{changeset: suite.options.NewChangeset("drop", time.Now())}
+
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings); err != nil {
+		suite.T().FailNow()
+	}
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+
+	id, err := changesetID(suite.options, "drop")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), settings.changeset.ID, id)
+
+	w := &bytes.Buffer{}
+	assert.NoError(suite.T(), listSnapshots(ctx, suite.options, util.SnapshotFilter{Changeset: id}, false, w))
+	assert.Contains(suite.T(), w.String(), string(settings.changeset.Snapshots["./assets"]))
+	assert.Equal(suite.T(), 1, bytes.Count(w.Bytes(), []byte("\n")))
+}
+
+func (suite *SnapSuite) Test_createSnapshot_sandbox() {
+	ctx := context.Background()
+	sandboxed := suite.options.Clone()
+	sandboxed.Sandbox = "pipeline-test"
+	record := sandboxed.NewAuditRecord("snap", nil)
+	if _, err := createSnapshot(ctx, sandboxed, record, snapSettings{}); err != nil || len(record.Manifests) == 0 {
+		suite.T().FailNow()
+	}
+
+	// The snapshots of the sandbox are neither listed nor restored without it
+	w := &bytes.Buffer{}
+	assert.NoError(suite.T(), listSnapshots(ctx, suite.options, util.SnapshotFilter{PathPrefixes: listPathPrefixes(suite.options, nil)}, false, w))
+	assert.Empty(suite.T(), w.String())
+	w.Reset()
+	assert.NoError(suite.T(), listSnapshots(ctx, sandboxed, util.SnapshotFilter{PathPrefixes: listPathPrefixes(sandboxed, nil)}, false, w))
+	assert.Contains(suite.T(), w.String(), string(record.Manifests[0]))
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+
+	targets, err := restoreTargets(ctx, suite.options, rep, "", "")
+	if assert.NoError(suite.T(), err) {
+		assert.Empty(suite.T(), targets)
+	}
+	targets, err = restoreTargets(ctx, sandboxed, rep, "", "")
+	if assert.NoError(suite.T(), err) && assert.Len(suite.T(), targets, 1) {
+		assert.Equal(suite.T(), record.Manifests[0], targets[0].manifest.ID)
+	}
+	_, err = restoreTargets(ctx, suite.options, rep, string(record.Manifests[0]), "")
+	assert.Error(suite.T(), err, "restoreTargets() of a snapshot of the sandbox without it")
+}
+
+func (suite *SnapSuite) Test_createSnapshot_progress() {
+	ctx := context.Background()
+	w := &bytes.Buffer{}
+	settings := snapSettings{progress: util.NewUploadProgressBar(w, time.Now)}
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings); err != nil {
+		suite.T().FailNow()
+	}
+	assert.Contains(suite.T(), w.String(), "./assets: 1 files hashed (1.0 B), 0 cached (0.0 B)")
+	assert.True(suite.T(), strings.HasSuffix(w.String(), "\n"))
+}
+
+func (suite *SnapSuite) Test_snapIfChanged() {
+	tests := []struct {
+		name            string
+		configIfChanged bool
+		args            []string
+		want            bool
+	}{
+		{
+			name: "Snapshot always by default",
+			want: false,
+		},
+		{
+			name:            "Default to ifChanged of the .gasset file",
+			configIfChanged: true,
+			want:            true,
+		},
+		{
+			name: "Exit if unchanged with --if-changed",
+			args: []string{"--if-changed"},
+			want: true,
+		},
+		{
+			name:            "Override ifChanged of the .gasset file with --if-changed=false",
+			configIfChanged: true,
+			args:            []string{"--if-changed=false"},
+			want:            false,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cmd := &cobra.Command{}
+			cmd.Flags().Bool("if-changed", false, "")
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				suite.T().FailNow()
+			}
+			op := &util.Options{Config: &util.Config{IfChanged: tt.configIfChanged}}
+			got, err := snapIfChanged(cmd, op)
+			if assert.NoError(suite.T(), err) {
+				assert.Equal(suite.T(), tt.want, got)
+			}
+		})
+	}
+}
+
+func (suite *SnapSuite) Test_createSnapshot_canceled() {
+	if _, err := exec.LookPath("sh"); err != nil {
+		suite.T().Skip("sh is not available")
+	}
+	// The analyzer holds the snapshot of the directory until the context is canceled, like a timeout during the upload
+	started := filepath.Join(suite.options.WorkingDirectory, "started")
+	canceled := filepath.Join(suite.options.WorkingDirectory, "canceled")
+	suite.options.Config.Metrics = []util.MetricAnalyzer{
+		{Name: "wait", Command: []string{"sh", "-c", `touch started; while [ ! -e canceled ]; do sleep 0.01; done; printf '{}'`}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			if _, err := os.Stat(started); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		_ = os.WriteFile(canceled, nil, 0o644)
+	}()
+
+	_, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{})
+	var checkpointErr *checkpointError
+	if !assert.ErrorAs(suite.T(), err, &checkpointErr) {
+		return
+	}
+	assert.Equal(suite.T(), filepath.Join(suite.options.WorkingDirectory, "assets"), checkpointErr.path)
+
+	// The canceled upload is saved as a checkpoint within the grace period and recorded for resume
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(context.Background(), kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(context.Background())
+	man, err := snapshot.LoadSnapshot(context.Background(), rep, checkpointErr.checkpoint)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), snapshotfs.IncompleteReasonCanceled, man.IncompleteReason)
+	}
+	state, err := suite.options.LoadResumeState()
+	if assert.NoError(suite.T(), err) && assert.NotNil(suite.T(), state) {
+		assert.Equal(suite.T(), []string{string(checkpointErr.checkpoint)}, state.Checkpoints)
+		assert.Equal(suite.T(), []string{"./assets"}, state.RemainingDirs)
+	}
+}
+
+func (suite *SnapSuite) Test_createSnapshot_failingDirs() {
+	ctx := context.Background()
+	suite.options.Config.Dirs = []string{"./missing", "./assets", "./gone"}
+
+	_, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{})
+	if !assert.Error(suite.T(), err) {
+		return
+	}
+	// Every failing directory is reported and the others are still snapshotted
+	assert.Contains(suite.T(), err.Error(), "./missing: ")
+	assert.Contains(suite.T(), err.Error(), "./gone: ")
+	assert.Equal(suite.T(), 1, suite.snapshotCount(ctx))
+
+	state, err := suite.options.LoadResumeState()
+	if assert.NoError(suite.T(), err) && assert.NotNil(suite.T(), state) {
+		assert.Equal(suite.T(), []string{"./missing", "./gone"}, state.RemainingDirs)
+	}
+}
+
+func (suite *SnapSuite) Test_snapFlagSettings_deferRetention() {
+	ctx := context.Background()
+	suite.keepLatest(ctx, 1)
+
+	tests := []struct {
+		name                 string
+		configDeferRetention bool
+		args                 []string
+		wantDeferred         bool
+	}{
+		{
+			name:                 "Defer the retention with deferRetention of the .gasset file",
+			configDeferRetention: true,
+			wantDeferred:         true,
+		},
+		{
+			name:         "Defer the retention with --defer-retention",
+			args:         []string{"--defer-retention"},
+			wantDeferred: true,
+		},
+		{
+			name:                 "Apply the retention with --defer-retention=false over the .gasset file",
+			configDeferRetention: true,
+			args:                 []string{"--defer-retention=false"},
+			wantDeferred:         false,
+		},
+		{
+			name:         "Apply the retention on snap by default",
+			wantDeferred: false,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cmd := suite.newSnapCmd()
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				suite.T().FailNow()
+			}
+			suite.options.Config.DeferRetention = tt.configDeferRetention
+			settings, err := snapFlagSettings(cmd, suite.options)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+			assert.Equal(suite.T(), tt.wantDeferred, settings.deferRetention)
+
+			skipIdentical := false
+			settings.skipIdentical = &skipIdentical
+			before := suite.snapshotCount(ctx)
+			for i := 0; i < 2; i++ {
+				if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings); err != nil {
+					suite.T().FailNow()
+				}
+			}
+			if tt.wantDeferred {
+				assert.Equal(suite.T(), before+2, suite.snapshotCount(ctx), "the snapshots no longer retained are kept until prune")
+			} else {
+				assert.Equal(suite.T(), 1, suite.snapshotCount(ctx))
+			}
+		})
+	}
+}
+
+func (suite *SnapSuite) Test_snapFlagSettings_force() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+	previous := events
+	defer func() {
+		events = previous
+		log.SetOutput(os.Stderr)
+	}()
+
+	skip := true
+	keep := false
+	tests := []struct {
+		name          string
+		skipIdentical *bool
+		args          []string
+		wantStatus    string
+		wantLog       string
+	}{
+		{
+			name:          "Skip and report the identical snapshot with skipIdenticalSnapshots of the .gasset file",
+			skipIdentical: &skip,
+			wantStatus:    "unchanged",
+			wantLog:       "because it is identical to snapshot",
+		},
+		{
+			name:          "Save the identical snapshot with --force",
+			skipIdentical: &skip,
+			args:          []string{"--force"},
+			wantStatus:    "saved",
+		},
+		{
+			name:          "Save the identical snapshot without skipIdenticalSnapshots in the .gasset file",
+			skipIdentical: &keep,
+			wantStatus:    "saved",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cmd := suite.newSnapCmd()
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				suite.T().FailNow()
+			}
+			suite.options.Config.SkipIdenticalSnapshots = tt.skipIdentical
+			settings, err := snapFlagSettings(cmd, suite.options)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+
+			var output, logs bytes.Buffer
+			events = util.NewEventWriter(&output)
+			log.SetOutput(&logs)
+			before := suite.snapshotCount(ctx)
+			unchanged, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), settings)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+
+			var event util.Event
+			if !assert.NoError(suite.T(), json.Unmarshal(output.Bytes(), &event)) {
+				return
+			}
+			assert.Equal(suite.T(), tt.wantStatus, event.Status)
+			if tt.wantStatus == "unchanged" {
+				assert.Equal(suite.T(), []string{"./assets"}, unchanged)
+				assert.Equal(suite.T(), before, suite.snapshotCount(ctx))
+				assert.Contains(suite.T(), logs.String(), tt.wantLog)
+				assert.Contains(suite.T(), logs.String(), "./assets: unchanged, not saved")
+			} else {
+				assert.Empty(suite.T(), unchanged)
+				assert.Equal(suite.T(), before+1, suite.snapshotCount(ctx))
+			}
+		})
+	}
+}
+
+func (suite *SnapSuite) Test_snapshotReconnecting() {
+	ctx := context.Background()
+	if err := os.MkdirAll(filepath.Join(suite.options.WorkingDirectory, "textures"), 0o755); err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(suite.options.WorkingDirectory, "textures", "wall.png"), []byte("png"), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+	suite.options.Config.Dirs = []string{"./assets", "./textures"}
+	suite.options.Config.Kopia.APIServer = &repo.APIServerInfo{BaseURL: "https://localhost:51515"}
+	suite.options.Reconnect = util.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 1}
+
+	// The server goes away after ./assets was snapshotted by the first attempt
+	if err := suite.options.SaveResumeState(&util.ResumeState{Operation: "snap", RemainingDirs: []string{"./textures"}}); err != nil {
+		suite.T().FailNow()
+	}
+	repoOpen := suite.options.RepoOpen
+	attempts := 0
+	suite.options.RepoOpen = func(ctx context.Context, configFile string, password string, options *repo.Options) (repo.Repository, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, syscall.ECONNREFUSED
+		}
+		return repoOpen(ctx, configFile, password, options)
+	}
+
+	_, err := snapshotReconnecting(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{})
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	assert.Equal(suite.T(), 2, attempts)
+	assert.Equal(suite.T(), []string{"./assets", "./textures"}, suite.options.Config.Dirs, "the config is not changed by the reconnection")
+	assert.Zero(suite.T(), suite.snapshotCount(ctx), "the directory snapshotted before the reconnection is not snapshotted again")
+}
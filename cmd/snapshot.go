@@ -0,0 +1,137 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Inspects the snapshots",
+}
+
+// snapshotShowCmd represents the snapshot show command
+var snapshotShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Prints the details of a snapshot",
+	Long: `Prints the details of a snapshot.
+
+The details include the source, description, tags and pins, the stats of
+the files and directories, the reason if the snapshot is incomplete, the
+git branch and commit it was taken at and the object id of its root.`,
+	Args: cobra.ExactArgs(1),
+	RunE: SnapshotShowRun,
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotShowCmd)
+
+	snapshotShowCmd.Flags().Bool("json", false, "Prints the details as JSON")
+}
+
+func SnapshotShowRun(cmd *cobra.Command, args []string) error {
+	log.Println("snapshot show called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	return showSnapshot(context.Background(), options, args[0], asJson, cmd.OutOrStdout())
+}
+
+func showSnapshot(ctx context.Context, op *util.Options, snapshotId string, asJson bool, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	man, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(snapshotId))
+	if err != nil {
+		return err
+	}
+	details := util.NewSnapshotDetails(man)
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(details)
+	}
+
+	printField := func(name string, format string, args ...any) {
+		fmt.Fprintf(w, "%-14s"+format+"\n", append([]any{name + ":"}, args...)...)
+	}
+
+	printField("Snapshot", "%s", details.ID)
+	printField("Source", "%s@%s:%s", details.User, details.Host, details.Path)
+	if details.Description != "" {
+		printField("Description", "%s", details.Description)
+	}
+	printField("Started", "%s", util.FormatTime(details.StartTime, op.LocalTime))
+	printField("Finished", "%s (%s)", util.FormatTime(details.EndTime, op.LocalTime), details.EndTime.Sub(details.StartTime).Round(time.Millisecond))
+	if details.GitBranch != "" {
+		printField("Git", "%s at %s", details.GitBranch, details.GitCommit)
+	} else if details.GitCommit != "" {
+		printField("Git", "%s", details.GitCommit)
+	}
+	printField("Files", "%d", details.Files)
+	printField("Directories", "%d", details.Dirs)
+	printField("Size", "%s (%d bytes)", util.FormatBytes(details.Bytes), details.Bytes)
+	printField("Errors", "%d (%d ignored)", details.Errors, details.IgnoredErrors)
+	if details.IncompleteReason != "" {
+		printField("Incomplete", "%s", details.IncompleteReason)
+	}
+	if len(details.Pins) > 0 {
+		printField("Pins", "%s", strings.Join(details.Pins, ", "))
+	}
+	if len(details.Tags) > 0 {
+		tags := make([]string, 0, len(details.Tags))
+		for key, value := range details.Tags {
+			tags = append(tags, strings.TrimPrefix(key, "tag:")+"="+value)
+		}
+		sort.Strings(tags)
+		printField("Tags", "%s", strings.Join(tags, ", "))
+	}
+	printField("Root object", "%s", details.RootObjectID)
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"time"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Shows the statistics of the snap runs",
+	Long: `Shows the statistics of the snap runs.
+
+Every snap stores the statistics of the run in the repository: the duration,
+the size of the saved snapshots, the bytes which had to be uploaded after
+deduplication and compression, and the directories and files which failed.
+Without flags the latest run is shown.
+
+With --history the latest runs are listed oldest first, followed by the trend
+of their durations and how the latest run compares to the median, so that
+snapshots which get slower are noticed early. --json prints the records for
+dashboards.`,
+	Args: cobra.NoArgs,
+	RunE: StatsRun,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().Bool("history", false, "Lists the latest runs and the trend of their durations")
+	statsCmd.Flags().Int("limit", 20, "The number of runs to list with --history, 0 lists all of them")
+	statsCmd.Flags().Bool("json", false, "Prints the records as JSON")
+}
+
+func StatsRun(cmd *cobra.Command, _ []string) error {
+	log.Println("stats called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	history, err := cmd.Flags().GetBool("history")
+	if err != nil {
+		return err
+	}
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		return err
+	}
+	if limit < 0 {
+		return fmt.Errorf("--limit must not be negative, got %d", limit)
+	}
+	if !history {
+		limit = 1
+	}
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	return showStats(context.Background(), options, limit, history, asJson, cmd.OutOrStdout())
+}
+
+func showStats(ctx context.Context, op *util.Options, limit int, history bool, asJson bool, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	runs, err := util.ListSnapStats(ctx, rep, limit)
+	if err != nil {
+		return err
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(runs)
+	}
+
+	if len(runs) == 0 {
+		fmt.Fprintln(w, util.T("No snap statistics recorded yet"))
+		return nil
+	}
+	for _, stats := range runs {
+		printSnapStats(w, op, stats)
+	}
+	if history {
+		printSnapTrend(w, runs)
+	}
+	return nil
+}
+
+func printSnapStats(w io.Writer, op *util.Options, stats *util.SnapStats) {
+	fmt.Fprintf(w, "%s\t%s@%s\t%s\t%s\t%s uploaded\t%.0f%% dedup",
+		util.FormatTime(stats.StartTime, op.LocalTime), stats.User, stats.Host, stats.Duration.Round(time.Second),
+		util.FormatBytes(stats.Bytes), util.FormatBytes(stats.UploadedBytes), stats.DedupRatio()*100)
+	if stats.FailedDirs > 0 || stats.FileErrors > 0 {
+		fmt.Fprintf(w, "\t%d failed dirs, %d file errors", stats.FailedDirs, stats.FileErrors)
+	}
+	fmt.Fprintln(w)
+}
+
+// printSnapTrend prints the durations of the runs as a sparkline and compares the latest run to the median
+func printSnapTrend(w io.Writer, runs []*util.SnapStats) {
+	durations := make([]float64, 0, len(runs))
+	for _, stats := range runs {
+		durations = append(durations, stats.Duration.Seconds())
+	}
+
+	median := time.Duration(util.Median(durations) * float64(time.Second))
+	latest := runs[len(runs)-1].Duration
+	fmt.Fprintf(w, "\n%s %s\n", util.T("Duration trend:"), util.Sparkline(durations))
+	fmt.Fprintf(w, util.T("Latest run took %s, the median is %s")+"\n", latest.Round(time.Second), median.Round(time.Second))
+	if median > 0 && latest > 2*median {
+		log.Printf("Warning: the latest snap took more than twice as long as the median")
+	}
+}
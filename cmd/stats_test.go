@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type StatsSuite struct {
+	repoSuite
+}
+
+func TestStatsSuite(t *testing.T) {
+	suite.Run(t, new(StatsSuite))
+}
+
+func (suite *StatsSuite) Test_showStats() {
+	ctx := context.Background()
+
+	w := &bytes.Buffer{}
+	assert.NoError(suite.T(), showStats(ctx, suite.options, 1, false, false, w))
+	assert.Equal(suite.T(), "No snap statistics recorded yet\n", w.String())
+
+	for i := 0; i < 2; i++ {
+		if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+			suite.T().FailNow()
+		}
+	}
+
+	w.Reset()
+	assert.NoError(suite.T(), showStats(ctx, suite.options, 0, true, true, w))
+	var runs []*util.SnapStats
+	assert.NoError(suite.T(), json.Unmarshal(w.Bytes(), &runs))
+	if !assert.Len(suite.T(), runs, 2) {
+		return
+	}
+	assert.Equal(suite.T(), 1, runs[0].Snapshots)
+	assert.Positive(suite.T(), runs[0].Bytes)
+	assert.Positive(suite.T(), runs[0].UploadedBytes)
+	assert.Equal(suite.T(), len(suite.options.Config.Dirs), runs[0].Dirs)
+	// The files of the second run are already in the repository
+	assert.Less(suite.T(), runs[1].UploadedBytes, runs[0].UploadedBytes)
+
+	w.Reset()
+	assert.NoError(suite.T(), showStats(ctx, suite.options, 0, true, false, w))
+	assert.Contains(suite.T(), w.String(), "Duration trend:")
+	assert.Equal(suite.T(), 5, bytes.Count(w.Bytes(), []byte("\n")))
+}
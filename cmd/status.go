@@ -0,0 +1,223 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status [dir...]",
+	Short: "Shows the working files changed since the latest snapshot",
+	Long: `Shows the working files changed since the latest snapshot.
+
+The working files of the asset directories, or of the given ones, are
+compared with the latest snapshot of their directory taken by any user or
+machine and listed as added, modified or removed, similar to git status.
+Nothing is uploaded and only the metadata of the snapshot is read: files
+with the size and modification time of the snapshot are unchanged, as snap
+considers them. With workingHashes in the .gasset file, files which were
+only touched since the snapshot are hashed to tell if they really changed.
+The ignore rules of snap apply.`,
+	RunE: StatusRun,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().Bool("json", false, "Prints the changes as JSON")
+	addTimeoutFlag(statusCmd)
+}
+
+func StatusRun(cmd *cobra.Command, args []string) error {
+	log.Println("status called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	dirs := args
+	if len(dirs) == 0 {
+		dirs = options.Config.Dirs
+	}
+	return workingTreeStatus(ctx, options, dirs, asJson, cmd.OutOrStdout())
+}
+
+// dirStatus is the working files of an asset directory changed since its latest snapshot
+type dirStatus struct {
+	Dir string `json:"dir"`
+	// Snapshot is empty if the directory was never snapshotted
+	Snapshot manifest.ID   `json:"snapshot,omitempty"`
+	Changes  []util.Change `json:"changes"`
+}
+
+func workingTreeStatus(ctx context.Context, op *util.Options, dirs []string, asJson bool, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	var recorded *util.WorkingHashes
+	if op.Config.WorkingHashes != nil {
+		if recorded, err = op.LoadWorkingHashes(); err != nil {
+			return err
+		}
+	}
+
+	statuses := make([]dirStatus, 0, len(dirs))
+	changed := 0
+	for _, dir := range dirs {
+		status, err := dirWorkingStatus(ctx, op, rep, recorded, dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		statuses = append(statuses, status)
+		changed += len(status.Changes)
+	}
+	summary.Add("changed", changed)
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+	}
+
+	for _, status := range statuses {
+		switch {
+		case status.Snapshot == "":
+			fmt.Fprintln(w, util.T("%s has no snapshot yet", status.Dir))
+		case len(status.Changes) == 0:
+			fmt.Fprintln(w, util.T("%s matches snapshot %s", status.Dir, status.Snapshot))
+			continue
+		default:
+			fmt.Fprintln(w, util.T("%s changed since snapshot %s", status.Dir, status.Snapshot))
+		}
+		for _, change := range status.Changes {
+			fmt.Fprintf(w, "\t%s\t%s\n", change.Kind, change.Path)
+		}
+	}
+	return nil
+}
+
+// dirWorkingStatus compares the working files of an asset directory with its latest snapshot
+func dirWorkingStatus(ctx context.Context, op *util.Options, rep repo.Repository, recorded *util.WorkingHashes, dir string) (dirStatus, error) {
+	status := dirStatus{Dir: dir}
+
+	manifests, err := listDirSnapshots(ctx, op, rep, dir)
+	if err != nil {
+		return status, err
+	}
+	latest := latestCompleteSnapshot(manifests)
+	if latest != nil {
+		status.Snapshot = latest.ID
+	}
+
+	status.Changes, _, _, err = workingChanges(ctx, op, rep, recorded, dir, latest)
+	return status, err
+}
+
+// workingChanges compares the working files of an asset directory with a snapshot of it, all of them are added
+// if the snapshot is nil. It returns the states of the files of both as well, by their path relative to the
+// working directory.
+func workingChanges(ctx context.Context, op *util.Options, rep repo.Repository, recorded *util.WorkingHashes, dir string, man *snapshot.Manifest) ([]util.Change, map[string]util.FileState, map[string]util.FileState, error) {
+	dirPath := path.Clean(filepath.ToSlash(dir)) + "/"
+
+	snapshotFiles := map[string]util.FileState{}
+	if man != nil {
+		root, err := snapshotfs.SnapshotRoot(rep, man)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if rootDir, ok := root.(fs.Directory); ok {
+			if snapshotFiles, err = util.DirFileStates(ctx, rootDir, dirPath); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+
+	localEntry, err := localfs.NewEntry(filepath.Join(op.WorkingDirectory, dir))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	localDir, ok := localEntry.(fs.Directory)
+	if !ok {
+		return nil, nil, nil, errors.New("not a directory")
+	}
+
+	// The working files are filtered like snap filters them so that excluded files don't show up as added
+	gitTracked, err := gitTrackedPolicy(op, dir)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	ignored, err := op.GassetIgnorePolicy(dir, gitTracked)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	policyTree, err := policy.TreeForSourceWithOverride(ctx, rep, op.SourceInfo(rep.ClientOptions(), dir), op.Config.SnapshotPolicy(ignored))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	workingFiles, err := util.DirFileStates(ctx, ignorefs.New(localDir, policyTree), dirPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var known map[string]util.WorkingHash
+	if recorded != nil {
+		known = recorded.Files
+	}
+	changes, err := util.CompareWorkingFiles(snapshotFiles, workingFiles, known, func(filePath string) (string, error) {
+		return util.HashFile(recorded.Algorithm, filepath.Join(op.WorkingDirectory, filepath.FromSlash(filePath)))
+	})
+	return changes, snapshotFiles, workingFiles, err
+}
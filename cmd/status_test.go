@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type StatusSuite struct {
+	repoSuite
+}
+
+func TestStatusSuite(t *testing.T) {
+	suite.Run(t, new(StatusSuite))
+}
+
+func (suite *StatusSuite) Test_workingTreeStatus() {
+	ctx := context.Background()
+	assetsDir := filepath.Join(suite.options.WorkingDirectory, "assets")
+
+	tests := []struct {
+		name        string
+		snap        bool
+		files       map[string]string
+		json        bool
+		want        string
+		wantChanges []util.Change
+	}{
+		{
+			name: "Report an asset directory without a snapshot",
+			want: "./assets has no snapshot yet\n\tadded\tassets/a.txt\n",
+		},
+		{
+			name: "Report an asset directory matching its snapshot",
+			snap: true,
+			want: "./assets matches snapshot %s\n",
+		},
+		{
+			name:  "List the changes since the snapshot",
+			files: map[string]string{"a.txt": "changed", "b.txt": "b"},
+			want:  "./assets changed since snapshot %s\n\tmodified\tassets/a.txt\n\tadded\tassets/b.txt\n",
+		},
+		{
+			name: "List the changes since the snapshot as JSON",
+			json: true,
+			wantChanges: []util.Change{
+				{Kind: util.ChangeModified, Path: "assets/a.txt"},
+				{Kind: util.ChangeAdded, Path: "assets/b.txt"},
+			},
+		},
+	}
+	var snapshotID string
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			if tt.snap {
+				record := suite.options.NewAuditRecord("snap", nil)
+				if _, err := createSnapshot(ctx, suite.options, record, snapSettings{}); err != nil || len(record.Manifests) != 1 {
+					suite.T().FailNow()
+				}
+				snapshotID = string(record.Manifests[0])
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(assetsDir, name), []byte(content), 0o644); err != nil {
+					suite.T().FailNow()
+				}
+			}
+
+			w := &bytes.Buffer{}
+			if !assert.NoError(suite.T(), workingTreeStatus(ctx, suite.options, suite.options.Config.Dirs, tt.json, w)) {
+				return
+			}
+			if !tt.json {
+				want := tt.want
+				if snapshotID != "" {
+					want = fmt.Sprintf(tt.want, snapshotID)
+				}
+				assert.Equal(suite.T(), want, w.String())
+				return
+			}
+			var statuses []dirStatus
+			if err := json.Unmarshal(w.Bytes(), &statuses); err != nil {
+				suite.T().FailNow()
+			}
+			if assert.Len(suite.T(), statuses, 1) {
+				assert.Equal(suite.T(), snapshotID, string(statuses[0].Snapshot))
+				assert.Equal(suite.T(), tt.wantChanges, statuses[0].Changes)
+			}
+		})
+	}
+}
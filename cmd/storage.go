@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+// storageCmd represents the storage command
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Inspects the storage of the repository",
+}
+
+// storageAdviseCmd represents the storage advise command
+var storageAdviseCmd = &cobra.Command{
+	Use:   "advise",
+	Short: "Checks the bucket settings for conflicts with the kopia repository",
+	Long: `Checks the bucket settings for conflicts with the kopia repository.
+
+The versioning and the lifecycle rules of the S3 bucket are read through
+the provider API and checked against the prefix of the repository. Rules
+expiring the blobs or moving them to an archive storage class corrupt
+the repository or make it unreadable and fail the command. Settings
+which only cost more than needed are reported as warnings.
+
+The credentials need the permission to read the bucket versioning and
+lifecycle configuration.`,
+	Args: cobra.NoArgs,
+	RunE: StorageAdviseRun,
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageAdviseCmd)
+	addTimeoutFlag(storageAdviseCmd)
+}
+
+func StorageAdviseRun(cmd *cobra.Command, _ []string) error {
+	log.Println("storage advise called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return adviseStorage(ctx, options, cmd.OutOrStdout())
+}
+
+func adviseStorage(ctx context.Context, op *util.Options, w io.Writer) error {
+	if op.Config.Kopia == nil || op.Config.Kopia.Storage == nil {
+		return errors.New("the .gasset file has no storage to inspect")
+	}
+	opt, ok := op.Config.Kopia.Storage.Config.(*s3.Options)
+	if !ok {
+		return fmt.Errorf("the %s storage can't be inspected, only s3 is supported", op.Config.Kopia.Storage.Type)
+	}
+
+	settings, err := op.S3BucketSettings(ctx, opt)
+	if err != nil {
+		return err
+	}
+
+	dangers := 0
+	for _, advice := range util.AdviseBucket(settings, opt.Prefix) {
+		if advice.Level == util.AdviceDanger {
+			dangers++
+		}
+		fmt.Fprintf(w, "%s\t%s\n", advice.Level, advice.Message)
+	}
+	summary.Add("dangerous settings", dangers)
+
+	if dangers > 0 {
+		return fmt.Errorf("the settings of the bucket %s endanger the repository", opt.BucketName)
+	}
+	fmt.Fprintln(w, util.T("No setting of the bucket %s endangers the repository", opt.BucketName))
+	return nil
+}
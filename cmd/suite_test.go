@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// repoSuite sets up a fake repository for every test of the commands working on the snapshots
+type repoSuite struct {
+	suite.Suite
+	*util.OptionsForTest
+	options *util.Options
+}
+
+func (suite *repoSuite) SetupSuite() {
+	suite.OptionsForTest = &util.OptionsForTest{}
+	if err := util.SetupTestOptions(suite.OptionsForTest); err != nil {
+		suite.T().FailNow()
+	}
+}
+
+func (suite *repoSuite) SetupTest() {
+	workingDirectory := suite.T().TempDir()
+	if err := os.MkdirAll(filepath.Join(workingDirectory, "assets"), 0o755); err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(workingDirectory, "assets", "a.txt"), []byte("a"), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+
+	suite.options = suite.OptionsWithGassetId.Clone()
+	suite.options.WorkingDirectory = workingDirectory
+	if _, err := util.SetupFakeRepository(context.Background(), suite.options, suite.T().TempDir()); err != nil {
+		suite.T().FailNow()
+	}
+}
+
+func (suite *repoSuite) snapshotCount(ctx context.Context) int {
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+
+	manifests, err := listDirSnapshots(ctx, suite.options, rep, "./assets")
+	if err != nil {
+		suite.T().FailNow()
+	}
+	return len(manifests)
+}
+
+// keepLatest sets the retention policy to keep only the given number of latest snapshots
+func (suite *repoSuite) keepLatest(ctx context.Context, latest int) {
+	keep := policy.OptionalInt(latest)
+	none := policy.OptionalInt(0)
+	retention := policy.RetentionPolicy{KeepLatest: &keep, KeepHourly: &none, KeepDaily: &none, KeepWeekly: &none, KeepMonthly: &none, KeepAnnual: &none}
+	confirm := func(int) error {
+		return nil
+	}
+	if err := setRetention(ctx, suite.options, retention, false, confirm, io.Discard, suite.options.NewAuditRecord("policy set", nil)); err != nil {
+		suite.T().FailNow()
+	}
+}
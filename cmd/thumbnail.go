@@ -0,0 +1,166 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+)
+
+// thumbnailCmd represents the thumbnail command
+var thumbnailCmd = &cobra.Command{
+	Use:   "thumbnail <path>",
+	Short: "Prints the thumbnail of an image in a snapshot",
+	Long: `Prints the thumbnail of an image in a snapshot as a PNG.
+
+With thumbnails in the .gasset file, snap generates small thumbnails of
+the PNG, JPEG and GIF images of the asset directories and stores them in
+the repository next to the snapshots, as a source of their own. Only the
+thumbnails of the images which changed are generated again, by as many
+workers as configured, and images above the maximum size are skipped.
+
+The thumbnail is read from the latest snapshot of the directory of the
+image, or from the given snapshot, without reading the image itself.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSnapshotPaths,
+	RunE:              ThumbnailRun,
+}
+
+func init() {
+	rootCmd.AddCommand(thumbnailCmd)
+
+	thumbnailCmd.Flags().String("snapshot", "", "Id of the snapshot to read instead of the latest one")
+	thumbnailCmd.Flags().StringP("output", "o", "", "Writes the thumbnail to the file instead of stdout")
+}
+
+func ThumbnailRun(cmd *cobra.Command, args []string) error {
+	log.Println("thumbnail called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	snapshotId, err := cmd.Flags().GetString("snapshot")
+	if err != nil {
+		return err
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	return printThumbnail(context.Background(), options, args[0], snapshotId, w)
+}
+
+func printThumbnail(ctx context.Context, op *util.Options, assetPath string, snapshotId string, w io.Writer) error {
+	dir, relativePath, err := op.AssetDir(assetPath)
+	if err != nil {
+		return err
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	var man *snapshot.Manifest
+	if snapshotId != "" {
+		man, err = snapshot.LoadSnapshot(ctx, rep, manifest.ID(snapshotId))
+		if err != nil {
+			return err
+		}
+	} else {
+		manifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return err
+		}
+		man = latestCompleteSnapshot(manifests)
+		if man == nil {
+			return fmt.Errorf("%s has no snapshots", dir)
+		}
+	}
+
+	thumbnails, err := findThumbnailSnapshot(ctx, rep, man)
+	if err != nil {
+		return err
+	}
+	if thumbnails == nil {
+		return fmt.Errorf("snapshot %s has no thumbnails, set thumbnails in the .gasset file and snap", man.ID)
+	}
+
+	entry, err := findSnapshotEntry(ctx, rep, thumbnails, relativePath+util.ThumbnailExtension)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("%s has no thumbnail in snapshot %s", assetPath, man.ID)
+	}
+
+	file, ok := snapshotfs.EntryFromDirEntry(rep, entry).(fs.File)
+	if !ok {
+		return fmt.Errorf("the thumbnail of %s is not a file", assetPath)
+	}
+
+	reader, err := file.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// findThumbnailSnapshot returns the snapshot of the thumbnails of the images of a snapshot or nil if there is none
+func findThumbnailSnapshot(ctx context.Context, rep repo.Repository, man *snapshot.Manifest) (*snapshot.Manifest, error) {
+	manifests, err := snapshot.ListSnapshots(ctx, rep, util.ThumbnailSource(man.Source))
+	if err != nil {
+		return nil, err
+	}
+	for _, thumbnails := range manifests {
+		if thumbnails.Tags[util.TagThumbnailsOf] == string(man.ID) {
+			return thumbnails, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type ThumbnailSuite struct {
+	repoSuite
+}
+
+func TestThumbnailSuite(t *testing.T) {
+	suite.Run(t, new(ThumbnailSuite))
+}
+
+func (suite *ThumbnailSuite) Test_printThumbnail() {
+	ctx := context.Background()
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	imageBytes := &bytes.Buffer{}
+	if err := png.Encode(imageBytes, img); err != nil {
+		suite.T().FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(suite.options.WorkingDirectory, "assets", "wall.png"), imageBytes.Bytes(), 0o644); err != nil {
+		suite.T().FailNow()
+	}
+
+	tests := []struct {
+		name       string
+		thumbnails *util.ThumbnailOptions
+		path       string
+		wantErr    assert.ErrorAssertionFunc
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:    "Fail on a snapshot taken without thumbnails",
+			path:    "assets/wall.png",
+			wantErr: assert.Error,
+		},
+		{
+			name:       "Print the thumbnail of an image in the size of the config",
+			thumbnails: &util.ThumbnailOptions{Size: 100},
+			path:       "assets/wall.png",
+			wantErr:    assert.NoError,
+			wantWidth:  100,
+			wantHeight: 50,
+		},
+		{
+			name:       "Fail on a file which is not an image",
+			thumbnails: &util.ThumbnailOptions{Size: 100},
+			path:       "assets/a.txt",
+			wantErr:    assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.options.Config.Thumbnails = tt.thumbnails
+			if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+				suite.T().FailNow()
+			}
+
+			w := &bytes.Buffer{}
+			if !tt.wantErr(suite.T(), printThumbnail(ctx, suite.options, tt.path, "", w)) || tt.wantWidth == 0 {
+				return
+			}
+			config, err := png.DecodeConfig(w)
+			if assert.NoError(suite.T(), err) {
+				assert.Equal(suite.T(), tt.wantWidth, config.Width)
+				assert.Equal(suite.T(), tt.wantHeight, config.Height)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"git-gasset/util"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/spf13/cobra"
+	"log"
+	"sort"
+	"strings"
+)
+
+// uiHistoryLength is the number of snapshots shown in the history of a directory
+const uiHistoryLength = 15
+
+// uiCmd represents the ui command
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Shows the asset directories in an interactive terminal UI",
+	Long: `Shows the asset directories in an interactive terminal UI.
+
+Every asset directory of the .gasset file is listed with its latest
+snapshot and, with workingHashes in the .gasset file, whether its files
+still match the recorded hashes. Enter shows the snapshot history of
+the selected directory and s snapshots it. The log output is printed
+when the UI is closed.`,
+	Args: cobra.NoArgs,
+	RunE: UiRun,
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
+
+func UiRun(cmd *cobra.Command, _ []string) error {
+	log.Println("ui called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	// The log output would break the screen, so it is kept until the UI is closed
+	var logs bytes.Buffer
+	logWriter := log.Writer()
+	log.SetOutput(&logs)
+	defer func() {
+		log.SetOutput(logWriter)
+		logWriter.Write(logs.Bytes())
+	}()
+
+	program := tea.NewProgram(newUiModel(cmd.Context(), options), tea.WithInput(cmd.InOrStdin()), tea.WithOutput(cmd.OutOrStdout()), tea.WithAltScreen())
+	final, err := program.Run()
+	if err != nil {
+		return err
+	}
+	return final.(uiModel).err
+}
+
+// uiDir is an asset directory with its snapshots, the newest first
+type uiDir struct {
+	path      string
+	snapshots []*snapshot.Manifest
+	status    string
+}
+
+type uiModel struct {
+	ctx     context.Context
+	op      *util.Options
+	dirs    []uiDir
+	cursor  int
+	history bool
+	// busy describes the running action, no other action is started until it finishes
+	busy    string
+	message string
+	err     error
+}
+
+type uiLoadedMsg struct {
+	dirs []uiDir
+	err  error
+}
+
+type uiSnappedMsg struct {
+	dir       string
+	unchanged bool
+	err       error
+}
+
+func newUiModel(ctx context.Context, op *util.Options) uiModel {
+	return uiModel{ctx: ctx, op: op, busy: "Loading the snapshots"}
+}
+
+func (m uiModel) Init() tea.Cmd {
+	return m.load
+}
+
+func (m uiModel) load() tea.Msg {
+	dirs, err := loadUiDirs(m.ctx, m.op)
+	return uiLoadedMsg{dirs: dirs, err: err}
+}
+
+func (m uiModel) snap(dir string) tea.Cmd {
+	return func() tea.Msg {
+		snapOptions := m.op.Clone()
+		snapOptions.Config.Dirs = []string{dir}
+		unchanged, err := createSnapshot(m.ctx, snapOptions, m.op.NewAuditRecord("ui snap", []string{dir}), defaultSnapSettings(m.op))
+		return uiSnappedMsg{dir: dir, unchanged: len(unchanged) > 0, err: err}
+	}
+}
+
+func (m uiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case uiLoadedMsg:
+		m.busy = ""
+		if msg.err != nil {
+			m.err = msg.err
+			return m, tea.Quit
+		}
+		m.dirs = msg.dirs
+		m.cursor = min(m.cursor, max(len(m.dirs)-1, 0))
+	case uiSnappedMsg:
+		switch {
+		case msg.err != nil:
+			m.message = fmt.Sprintf("Could not snapshot %s: %v", msg.dir, msg.err)
+		case msg.unchanged:
+			m.message = fmt.Sprintf("%s is unchanged, not saved", msg.dir)
+		default:
+			m.message = fmt.Sprintf("Snapshotted %s", msg.dir)
+		}
+		m.busy = "Loading the snapshots"
+		return m, m.load
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "esc":
+			m.history = false
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.dirs)-1 {
+				m.cursor++
+			}
+		case "enter":
+			m.history = !m.history
+		case "s":
+			if m.busy == "" && len(m.dirs) > 0 {
+				dir := m.dirs[m.cursor].path
+				m.busy = "Snapshotting " + dir
+				m.message = ""
+				return m, m.snap(dir)
+			}
+		case "r":
+			if m.busy == "" {
+				m.busy = "Loading the snapshots"
+				return m, m.load
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m uiModel) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "gasset %s\n\n", m.op.Config.GassetId)
+
+	for i, dir := range m.dirs {
+		cursor := " "
+		if i == m.cursor {
+			cursor = ">"
+		}
+		latest := "never snapshotted"
+		if len(dir.snapshots) > 0 {
+			latest = "latest " + util.FormatTime(dir.snapshots[0].StartTime.ToTime(), m.op.LocalTime)
+		}
+		fmt.Fprintf(&b, "%s %-30s %-14s %-30s %d snapshots\n", cursor, dir.path, dir.status, latest, len(dir.snapshots))
+	}
+
+	if m.history && len(m.dirs) > 0 {
+		dir := m.dirs[m.cursor]
+		fmt.Fprintf(&b, "\nHistory of %s\n", dir.path)
+		for _, man := range dir.snapshots[:min(len(dir.snapshots), uiHistoryLength)] {
+			commit := man.Tags[util.TagGitCommit]
+			fmt.Fprintf(&b, "  %s  %s  %10s  %s\n", man.ID, util.FormatTime(man.StartTime.ToTime(), m.op.LocalTime), util.FormatBytes(man.Stats.TotalFileSize), commit[:min(len(commit), 12)])
+		}
+	}
+
+	b.WriteString("\n")
+	if m.busy != "" {
+		b.WriteString(m.busy + "...\n")
+	} else if m.message != "" {
+		b.WriteString(m.message + "\n")
+	}
+	b.WriteString("↑/↓ select  enter history  s snap  r refresh  q quit\n")
+	return b.String()
+}
+
+// loadUiDirs lists the snapshots of the asset directories and checks their files against the recorded hashes
+func loadUiDirs(ctx context.Context, op *util.Options) ([]uiDir, error) {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Close(ctx)
+
+	var recorded *util.WorkingHashes
+	var lock *util.LockFile
+	if op.Config.WorkingHashes != nil {
+		if recorded, err = op.LoadWorkingHashes(); err != nil {
+			return nil, err
+		}
+		if lock, err = util.LoadLockFiles(op.WorkingDirectory, op.Config.Dirs); err != nil {
+			return nil, err
+		}
+	}
+
+	dirs := make([]uiDir, 0, len(op.Config.Dirs))
+	for _, dirPath := range op.Config.Dirs {
+		manifests, err := listDirSnapshots(ctx, op, rep, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(manifests, func(i, j int) bool {
+			return util.SnapshotAfter(manifests[i], manifests[j])
+		})
+
+		status := "-"
+		if recorded != nil {
+			changes, ok, err := workingHashChanges(op, recorded, lock, dirPath)
+			switch {
+			case err != nil:
+				status = "unreadable"
+			case !ok:
+				status = "no hashes"
+			case len(changes) == 0:
+				status = "clean"
+			default:
+				status = fmt.Sprintf("%d changed", len(changes))
+			}
+		}
+		dirs = append(dirs, uiDir{path: dirPath, snapshots: manifests, status: status})
+	}
+	return dirs, nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type UISuite struct {
+	repoSuite
+}
+
+func TestUISuite(t *testing.T) {
+	suite.Run(t, new(UISuite))
+}
+
+func (suite *UISuite) Test_uiModel() {
+	ctx := context.Background()
+	var model tea.Model = newUiModel(ctx, suite.options)
+
+	model, _ = model.Update(model.(uiModel).load())
+	assert.Len(suite.T(), model.(uiModel).dirs, 1)
+	assert.Empty(suite.T(), model.(uiModel).dirs[0].snapshots)
+
+	model, snap := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	assert.Equal(suite.T(), "Snapshotting ./assets", model.(uiModel).busy)
+	model, load := model.Update(snap())
+	assert.Equal(suite.T(), "Snapshotted ./assets", model.(uiModel).message)
+	model, _ = model.Update(load())
+	assert.Len(suite.T(), model.(uiModel).dirs[0].snapshots, 1)
+	assert.Contains(suite.T(), model.View(), "1 snapshots")
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// archivedPin keeps the archive snapshots from being deleted by the retention policy
+const archivedPin = "archived"
+
+// untrackCmd represents the untrack command
+var untrackCmd = &cobra.Command{
+	Use:   "untrack <dir>",
+	Short: "Removes an asset directory from the .gasset file",
+	Long: `Removes an asset directory from the .gasset file.
+
+The snapshots of the directory and of its thumbnails stay in the
+repository, the local thumbnails kept for the next snap are removed. With
+--archive a
+final snapshot of the directory is taken first, tagged and pinned as
+archived so that the retention policy never deletes it, and recorded in
+the .gasset.lock file as the way to recover the retired directory.`,
+	Args: cobra.ExactArgs(1),
+	RunE: UntrackRun,
+}
+
+func init() {
+	rootCmd.AddCommand(untrackCmd)
+
+	untrackCmd.Flags().Bool("archive", false, "Takes a final snapshot of the directory and records it in the lock file")
+	addTimeoutFlag(untrackCmd)
+}
+
+func UntrackRun(cmd *cobra.Command, args []string) error {
+	log.Println("untrack called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	archive, err := cmd.Flags().GetBool("archive")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return untrackDir(ctx, options, args[0], archive, newAuditRecord(cmd, options, args), cmd.OutOrStdout())
+}
+
+func untrackDir(ctx context.Context, op *util.Options, dir string, archive bool, record *util.AuditRecord, w io.Writer) error {
+	index := slices.IndexFunc(op.Config.Dirs, func(configured string) bool {
+		return filepath.Clean(configured) == filepath.Clean(dir)
+	})
+	if index < 0 {
+		return fmt.Errorf("%s is not an asset directory of the .gasset file", dir)
+	}
+	dir = op.Config.Dirs[index]
+
+	if archive {
+		snapshotId, err := archiveDir(ctx, op, dir, record)
+		if err != nil {
+			return err
+		}
+		summary.Add("archived", 1)
+		fmt.Fprintln(w, util.T("Archived %s as snapshot %s", dir, snapshotId))
+	}
+
+	if err := util.RemoveDir(op.WorkingDirectory, dir); err != nil {
+		return err
+	}
+	if err := removeThumbnails(op, dir); err != nil {
+		log.Printf("Warning: could not remove the local thumbnails of %s: %v", dir, err)
+	}
+	summary.Add("untracked", 1)
+	fmt.Fprintln(w, util.T("Removed %s from the .gasset file", dir))
+	return nil
+}
+
+// archiveDir takes the final snapshot of a directory, even if it is identical to the previous one, and records it in the lock file
+func archiveDir(ctx context.Context, op *util.Options, dir string, record *util.AuditRecord) (string, error) {
+	archiveOptions := op.Clone()
+	archiveOptions.Config.Dirs = []string{dir}
+
+	skipIdentical := false
+	settings := defaultSnapSettings(op)
+	settings.skipIdentical = &skipIdentical
+	settings.tags = map[string]string{util.TagArchived: "true"}
+	settings.pins = []string{archivedPin}
+
+	if _, err := snapshotReconnecting(ctx, archiveOptions, record, settings); err != nil {
+		return "", err
+	}
+	if len(record.Manifests) == 0 {
+		return "", errors.New("the archive snapshot was not saved")
+	}
+	snapshotId := record.Manifests[len(record.Manifests)-1]
+
+	lock, err := util.LoadLockFile(op.WorkingDirectory)
+	if err != nil {
+		return "", err
+	}
+	lock.Archives = append(lock.Archives, util.ArchiveEntry{
+		Dir:        dir,
+		Source:     archiveOptions.SourceInfo(op.ClientOptions(), dir).String(),
+		Snapshot:   snapshotId,
+		ArchivedAt: time.Now().UTC(),
+	})
+	if err := util.SaveLockFile(op.WorkingDirectory, lock, op.Config.Permissions); err != nil {
+		return "", fmt.Errorf("the archive snapshot %s was saved but could not be recorded: %w", snapshotId, err)
+	}
+	return string(snapshotId), nil
+}
+
+// removeThumbnails removes the local thumbnails of an asset directory, the snapshots of them are kept
+func removeThumbnails(op *util.Options, dir string) error {
+	thumbnailDir, err := op.GetThumbnailDirectory(dir)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(thumbnailDir)
+}
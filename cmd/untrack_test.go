@@ -0,0 +1,100 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"path/filepath"
+	"testing"
+)
+
+type UntrackSuite struct {
+	repoSuite
+}
+
+func TestUntrackSuite(t *testing.T) {
+	suite.Run(t, new(UntrackSuite))
+}
+
+func (suite *UntrackSuite) Test_untrackDir() {
+	ctx := context.Background()
+	if err := util.UpdateConfig(filepath.Join(suite.options.WorkingDirectory, ".gasset"), suite.options.Config); err != nil {
+		suite.T().FailNow()
+	}
+
+	tests := []struct {
+		name     string
+		dir      string
+		archive  bool
+		wantErr  assert.ErrorAssertionFunc
+		wantDirs []string
+	}{
+		{
+			name:     "Fail on a directory which is not an asset directory",
+			dir:      "./sounds",
+			wantErr:  assert.Error,
+			wantDirs: []string{"./assets"},
+		},
+		{
+			name:     "Archive and untrack an asset directory",
+			dir:      "assets",
+			archive:  true,
+			wantErr:  assert.NoError,
+			wantDirs: []string{},
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			var output bytes.Buffer
+			tt.wantErr(suite.T(), untrackDir(ctx, suite.options, tt.dir, tt.archive, suite.options.NewAuditRecord("untrack", nil), &output))
+
+			config, err := util.GetConfig(suite.options.WorkingDirectory)
+			if assert.NoError(suite.T(), err) {
+				assert.Equal(suite.T(), tt.wantDirs, config.Dirs)
+			}
+		})
+	}
+
+	lock, err := util.LoadLockFile(suite.options.WorkingDirectory)
+	if !assert.NoError(suite.T(), err) || !assert.Len(suite.T(), lock.Archives, 1) {
+		return
+	}
+	assert.Equal(suite.T(), "./assets", lock.Archives[0].Dir)
+
+	kopiaUserConfigPath, err := suite.options.GetKopiaUserConfigPath()
+	if err != nil {
+		suite.T().FailNow()
+	}
+	rep, err := suite.options.RepoOpen(ctx, kopiaUserConfigPath, suite.options.Password, &repo.Options{})
+	if err != nil {
+		suite.T().FailNow()
+	}
+	defer rep.Close(ctx)
+
+	archived, err := snapshot.LoadSnapshot(ctx, rep, lock.Archives[0].Snapshot)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	assert.Equal(suite.T(), "true", archived.Tags[util.TagArchived])
+	assert.Equal(suite.T(), []string{archivedPin}, archived.Pins)
+}
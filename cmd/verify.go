@@ -0,0 +1,255 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"time"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies the content of the snapshots in the repository",
+	Long: `Verifies the content of the snapshots in the repository.
+
+A sample of the files of the latest snapshot of every asset directory is
+read from the repository, which checks that their content exists, is
+decrypted and matches its hash. The directories of the snapshots are
+always read. The corrupted files are printed and fail the command.
+
+With --daemon the verification is repeated at the --interval until
+interrupted, e.g. on a server or a NAS, and restarted with a backoff
+when it can't run. --listen serves the health of the daemon and its
+Prometheus metrics on /metrics, including the time since the last
+verification without corrupted files. --webhook posts a JSON alert to
+the URL when corrupted files are found, which chat services read as a
+message. The URL usually holds a secret, so it is not part of the
+.gasset file.`,
+	Args: cobra.NoArgs,
+	RunE: VerifyRun,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().Float64("percent", 10, "Percentage of the files read")
+	verifyCmd.Flags().Bool("daemon", false, "Repeats the verification at the interval until interrupted")
+	verifyCmd.Flags().Duration("interval", 24*time.Hour, "Time between the verifications of --daemon")
+	verifyCmd.Flags().String("webhook", "", "Posts an alert to the URL when corrupted files are found")
+	verifyCmd.Flags().String("listen", "", "Serves the health and the metrics of --daemon on the address, e.g. :9090")
+}
+
+func VerifyRun(cmd *cobra.Command, _ []string) error {
+	log.Println("verify called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	percent, err := cmd.Flags().GetFloat64("percent")
+	if err != nil {
+		return err
+	}
+	if percent < 0 || percent > 100 {
+		return errors.New("the percentage of the files read must be between 0 and 100")
+	}
+	daemon, err := cmd.Flags().GetBool("daemon")
+	if err != nil {
+		return err
+	}
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return errors.New("the interval must be positive")
+	}
+	webhook, err := cmd.Flags().GetString("webhook")
+	if err != nil {
+		return err
+	}
+	listen, err := cmd.Flags().GetString("listen")
+	if err != nil {
+		return err
+	}
+	if listen != "" && !daemon {
+		return errors.New("--listen is only served with --daemon")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	alerts := verifyAlerts{op: options, webhook: webhook, client: &http.Client{Timeout: 30 * time.Second}}
+	if daemon {
+		return verifyDaemon(ctx, options, percent, interval, listen, alerts, cmd.OutOrStdout())
+	}
+
+	result, err := verifySnapshots(ctx, options, percent, cmd.OutOrStdout())
+	if err != nil {
+		return err
+	}
+	alerts.send(ctx, result)
+	if len(result.Corrupted) > 0 {
+		return fmt.Errorf("%d corrupted files were found", len(result.Corrupted))
+	}
+	return nil
+}
+
+// verifyAlerts sends the webhook alerts of the verifications which found corrupted files
+type verifyAlerts struct {
+	op      *util.Options
+	webhook string
+	client  *http.Client
+}
+
+func (a verifyAlerts) send(ctx context.Context, result *util.VerifyResult) {
+	if a.webhook == "" || len(result.Corrupted) == 0 {
+		return
+	}
+	if err := util.SendWebhook(ctx, a.client, a.webhook, a.op.NewVerifyAlert(result, time.Now())); err != nil {
+		log.Printf("Warning: could not send the alert: %v", err)
+	}
+}
+
+// verifyDaemon verifies the snapshots at the interval until ctx is done
+func verifyDaemon(ctx context.Context, op *util.Options, percent float64, interval time.Duration, listen string, alerts verifyAlerts, w io.Writer) error {
+	status := util.NewVerifyStatus(time.Now)
+	return runSupervised(ctx, listen, "verify", map[string]http.Handler{util.MetricsPath: status}, func(ctx context.Context) error {
+		for {
+			result, err := verifySnapshots(ctx, op, percent, w)
+			if err != nil {
+				status.RecordFailure()
+				return err
+			}
+			status.Record(result)
+			alerts.send(ctx, result)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+		}
+	})
+}
+
+// verifySnapshots reads the percentage of the files of the latest snapshot of every asset directory
+func verifySnapshots(ctx context.Context, op *util.Options, percent float64, w io.Writer) (*util.VerifyResult, error) {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Close(ctx)
+
+	start := time.Now()
+	result := &util.VerifyResult{}
+	for _, dir := range op.Config.Dirs {
+		manifests, err := listDirSnapshots(ctx, op, rep, dir)
+		if err != nil {
+			return nil, err
+		}
+		latest := latestCompleteSnapshot(manifests)
+		if latest == nil {
+			continue
+		}
+		corruption := func(entryPath string, err error) {
+			result.Corrupted = append(result.Corrupted, fmt.Sprintf("%s (snapshot %s): %v", path.Join(dir, entryPath), latest.ID, err))
+		}
+		root, err := snapshotfs.SnapshotRoot(rep, latest)
+		if err != nil {
+			corruption("", err)
+			continue
+		}
+		result.Snapshots++
+		rootDir, ok := root.(fs.Directory)
+		if !ok {
+			continue
+		}
+		if err := verifyDir(ctx, op, rootDir, "", percent, result, corruption); err != nil {
+			return nil, err
+		}
+	}
+	result.Duration = time.Since(start)
+
+	for _, corrupted := range result.Corrupted {
+		fmt.Fprintf(w, "corrupted: %s\n", corrupted)
+	}
+	fmt.Fprintln(w, util.T("Verified %d files of %d snapshots, %d corrupted", result.Files, result.Snapshots, len(result.Corrupted)))
+	summary.Add("verified", result.Files)
+	summary.Add("corrupted", len(result.Corrupted))
+	return result, nil
+}
+
+// verifyDir reads the sampled files of the directory and its subdirectories. Unreadable entries are reported
+// to corruption, only the cancellation of ctx is returned.
+func verifyDir(ctx context.Context, op *util.Options, dir fs.Directory, dirPath string, percent float64, result *util.VerifyResult, corruption func(entryPath string, err error)) error {
+	err := fs.IterateEntries(ctx, dir, func(ctx context.Context, entry fs.Entry) error {
+		entryPath := path.Join(dirPath, entry.Name())
+		switch typedEntry := entry.(type) {
+		case fs.Directory:
+			return verifyDir(ctx, op, typedEntry, entryPath, percent, result, corruption)
+		case fs.File:
+			if float64(op.RandIntn(10000)) >= percent*100 {
+				return nil
+			}
+			n, err := readSnapshotFile(ctx, typedEntry)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Files++
+			result.Bytes += n
+			if err != nil {
+				corruption(entryPath, err)
+			}
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		corruption(dirPath, err)
+		return nil
+	}
+	return ctx.Err()
+}
+
+func readSnapshotFile(ctx context.Context, file fs.File) (int64, error) {
+	reader, err := file.Open(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	return io.Copy(io.Discard, reader)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type VerifySuite struct {
+	repoSuite
+}
+
+func TestVerifySuite(t *testing.T) {
+	suite.Run(t, new(VerifySuite))
+}
+
+func (suite *VerifySuite) Test_verifySnapshots() {
+	ctx := context.Background()
+	if _, err := createSnapshot(ctx, suite.options, suite.options.NewAuditRecord("snap", nil), snapSettings{}); err != nil {
+		suite.T().FailNow()
+	}
+
+	tests := []struct {
+		name       string
+		percent    float64
+		wantFiles  int
+		wantOutput string
+	}{
+		{
+			name:       "Verify every file",
+			percent:    100,
+			wantFiles:  1,
+			wantOutput: "Verified 1 files of 1 snapshots, 0 corrupted",
+		},
+		{
+			name:       "Read no file with a percentage of 0",
+			percent:    0,
+			wantFiles:  0,
+			wantOutput: "Verified 0 files of 1 snapshots, 0 corrupted",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			var output bytes.Buffer
+			result, err := verifySnapshots(ctx, suite.options, tt.percent, &output)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+			assert.Equal(suite.T(), 1, result.Snapshots)
+			assert.Equal(suite.T(), tt.wantFiles, result.Files)
+			assert.Empty(suite.T(), result.Corrupted)
+			assert.Contains(suite.T(), output.String(), tt.wantOutput)
+		})
+	}
+}
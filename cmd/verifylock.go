@@ -0,0 +1,182 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/spf13/cobra"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// verifyLockCmd represents the verify-lock command
+var verifyLockCmd = &cobra.Command{
+	Use:   "verify-lock",
+	Short: "Checks the lock files after a git merge",
+	Long: `Checks the lock files after a git merge.
+
+Every archive entry and changeset of the lock files must refer to an
+existing snapshot of the repository, and no entry may be recorded twice.
+The problems which are trivial to resolve are fixed in the lock files:
+
+  - the git conflict markers are removed by merging both sides
+  - archive entries recorded twice are removed
+  - changesets recorded twice are merged into one
+  - hashes of files outside the asset directories are removed
+
+The other problems, e.g. a missing snapshot or a changeset recording two
+snapshots of a directory, are reported and fail the command. With
+--dry-run nothing is written.`,
+	Args: cobra.NoArgs,
+	RunE: VerifyLockRun,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyLockCmd)
+
+	verifyLockCmd.Flags().Bool("dry-run", false, "Reports the problems without fixing any")
+	addTimeoutFlag(verifyLockCmd)
+}
+
+func VerifyLockRun(cmd *cobra.Command, args []string) error {
+	log.Println("verify-lock called")
+
+	options, err := loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := commandContext(cmd)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return verifyLock(ctx, options, dryRun, cmd.OutOrStdout())
+}
+
+func verifyLock(ctx context.Context, op *util.Options, dryRun bool, w io.Writer) error {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		return err
+	}
+	defer rep.Close(ctx)
+
+	lockDirs := map[string][]string{".": op.Config.Dirs}
+	order := []string{"."}
+	if op.Config.LockFilePerDir {
+		for _, dir := range op.Config.Dirs {
+			lockDirs[dir] = []string{dir}
+			order = append(order, dir)
+		}
+	}
+
+	fixed, unresolved := 0, 0
+	for _, lockDir := range order {
+		name := filepath.Join(lockDir, util.LockFileName)
+		issues, err := verifyLockFile(ctx, op, rep, lockDir, lockDirs[lockDir], dryRun)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, issue := range issues {
+			switch {
+			case !issue.Fixed:
+				unresolved++
+				fmt.Fprintf(w, "%s: %s\n", name, issue.Problem)
+			case dryRun:
+				fixed++
+				fmt.Fprintf(w, "%s: %s (can be fixed)\n", name, issue.Problem)
+			default:
+				fixed++
+				fmt.Fprintf(w, "%s: %s (fixed)\n", name, issue.Problem)
+			}
+		}
+	}
+
+	if !dryRun {
+		summary.Add("fixed", fixed)
+	}
+	if unresolved > 0 {
+		return fmt.Errorf("%d problems of the lock files have to be resolved by hand", unresolved)
+	}
+	if fixed == 0 {
+		fmt.Fprintln(w, util.T("The lock files are consistent"))
+	}
+	return nil
+}
+
+// verifyLockFile checks the lock file in the directory, relative to the working directory, and saves it
+// if any issue was fixed. The hashes are kept for the files of the given asset directories.
+func verifyLockFile(ctx context.Context, op *util.Options, rep repo.Repository, lockDir string, dirs []string, dryRun bool) ([]util.LockIssue, error) {
+	lockPath := filepath.Join(op.WorkingDirectory, lockDir)
+	lockBytes, err := os.ReadFile(filepath.Join(lockPath, util.LockFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lock, issues, err := util.ReadMergedLockFile(lockBytes)
+	if err != nil {
+		return []util.LockIssue{{Problem: err.Error()}}, nil
+	}
+	issues = append(issues, lock.Dedupe()...)
+	issues = append(issues, lock.DropStrayHashes(dirs)...)
+
+	for _, ref := range lock.SnapshotRefs() {
+		man, err := snapshot.LoadSnapshot(ctx, rep, ref.Snapshot)
+		if errors.Is(err, snapshot.ErrSnapshotNotFound) {
+			issues = append(issues, util.LockIssue{Problem: fmt.Sprintf("%s refers to snapshot %s which does not exist", ref.Entry, ref.Snapshot)})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ref.Source != "" && man.Source.String() != ref.Source {
+			issues = append(issues, util.LockIssue{Problem: fmt.Sprintf("%s refers to snapshot %s of %s instead of %s", ref.Entry, ref.Snapshot, man.Source, ref.Source)})
+		}
+	}
+
+	if dryRun {
+		return issues, nil
+	}
+	for _, issue := range issues {
+		if issue.Fixed {
+			return issues, util.SaveLockFile(lockPath, lock, op.Config.Permissions)
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type VerifyLockSuite struct {
+	repoSuite
+}
+
+func TestVerifyLockSuite(t *testing.T) {
+	suite.Run(t, new(VerifyLockSuite))
+}
+
+func (suite *VerifyLockSuite) Test_verifyLock() {
+	ctx := context.Background()
+	record := suite.options.NewAuditRecord("snap", nil)
+	if _, err := createSnapshot(ctx, suite.options, record, snapSettings{}); err != nil || len(record.Manifests) == 0 {
+		suite.T().FailNow()
+	}
+	entry := util.ArchiveEntry{Dir: "./assets", Snapshot: record.Manifests[0], ArchivedAt: time.Now().UTC()}
+
+	tests := []struct {
+		name         string
+		lock         *util.LockFile
+		dryRun       bool
+		wantErr      assert.ErrorAssertionFunc
+		wantOutput   string
+		wantArchives int
+	}{
+		{
+			name:       "Report a consistent lock file",
+			wantErr:    assert.NoError,
+			wantOutput: "consistent",
+		},
+		{
+			name:         "Report an archive recorded twice without fixing it on a dry run",
+			lock:         &util.LockFile{Archives: []util.ArchiveEntry{entry, entry}},
+			dryRun:       true,
+			wantErr:      assert.NoError,
+			wantOutput:   "recorded twice (can be fixed)",
+			wantArchives: 2,
+		},
+		{
+			name:         "Fix an archive recorded twice",
+			wantErr:      assert.NoError,
+			wantOutput:   "recorded twice (fixed)",
+			wantArchives: 1,
+		},
+		{
+			name: "Fail on a changeset of a snapshot which does not exist",
+			lock: &util.LockFile{
+				Archives:   []util.ArchiveEntry{entry},
+				Changesets: []util.Changeset{{ID: "c", Snapshots: map[string]manifest.ID{"./assets": "k0123456789abcdef"}}},
+			},
+			wantErr:      assert.Error,
+			wantOutput:   "the changeset c of ./assets refers to snapshot k0123456789abcdef which does not exist",
+			wantArchives: 1,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			if tt.lock != nil {
+				if err := util.SaveLockFile(suite.options.WorkingDirectory, tt.lock, suite.options.Config.Permissions); err != nil {
+					suite.T().FailNow()
+				}
+			}
+
+			var output bytes.Buffer
+			tt.wantErr(suite.T(), verifyLock(ctx, suite.options, tt.dryRun, &output))
+			assert.Contains(suite.T(), output.String(), tt.wantOutput)
+			lock, err := util.LoadLockFile(suite.options.WorkingDirectory)
+			if assert.NoError(suite.T(), err) {
+				assert.Len(suite.T(), lock.Archives, tt.wantArchives)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"git-gasset/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"sort"
+)
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Prints the version of git-gasset",
+	Long: `Prints the version of git-gasset.
+
+Besides the version and commit of the build it prints the version of kopia
+it was built with and the platform dependent capabilities of the binary.
+With --json the same is printed as JSON for tooling to detect what the
+installed binary supports.`,
+	Args: cobra.NoArgs,
+	RunE: VersionRun,
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().Bool("json", false, "Prints the build info as JSON")
+}
+
+func VersionRun(cmd *cobra.Command, _ []string) error {
+	log.Println("version called")
+
+	asJson, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	return printVersion(util.GetBuildInfo(), asJson, cmd.OutOrStdout())
+}
+
+func printVersion(info util.BuildInfo, asJson bool, w io.Writer) error {
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	fmt.Fprintf(w, "git-gasset %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Fprintf(w, "Commit:       %s\n", info.Commit)
+	}
+	if info.Date != "" {
+		fmt.Fprintf(w, "Built:        %s\n", info.Date)
+	}
+	if info.KopiaVersion != "" {
+		fmt.Fprintf(w, "Kopia:        %s\n", info.KopiaVersion)
+	}
+	fmt.Fprintf(w, "Go:           %s %s\n", info.GoVersion, info.Platform)
+
+	capabilities := make([]string, 0, len(info.Capabilities))
+	for capability := range info.Capabilities {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Strings(capabilities)
+	for _, capability := range capabilities {
+		fmt.Fprintf(w, "Capability:   %s=%t\n", capability, info.Capabilities[capability])
+	}
+	return nil
+}
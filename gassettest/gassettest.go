@@ -0,0 +1,22 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gassettest provides test doubles of the functions injected into util.Options, for the tests of
+// new commands. The mocks are generated from the interfaces of the util package, run go generate ./...
+// after changing one of them.
+package gassettest
+
+//go:generate go run github.com/vektra/mockery/v2@v2.53.5 --srcpkg git-gasset/util --name StorageFactory|RepoController|PolicyManager|Keyring --output . --outpkg gassettest --case underscore --with-expecter --disable-version-string
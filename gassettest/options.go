@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gassettest
+
+import (
+	"context"
+	"git-gasset/util"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"testing"
+)
+
+// GassetId is the gasset id of the options returned by NewOptions
+const GassetId = "0000000000"
+
+// Doubles are the mocks injected into the options returned by NewOptions
+type Doubles struct {
+	Storage *StorageFactory
+	Repo    *RepoController
+	Policy  *PolicyManager
+	Keyring *Keyring
+}
+
+// NewOptions returns the options of a repository of an s3 storage with the asset directory ./assets in the
+// working directory. The storages, the kopia repository, the policies and the keyring are the mocks of the returned
+// doubles, their expectations are asserted when the test ends. The local state is kept in temp directories
+// of the test.
+func NewOptions(t testing.TB, workingDirectory string) (*util.Options, *Doubles) {
+	doubles := &Doubles{
+		Storage: NewStorageFactory(t),
+		Repo:    NewRepoController(t),
+		Policy:  NewPolicyManager(t),
+		Keyring: NewKeyring(t),
+	}
+
+	tempDirectory := t.TempDir()
+	userConfigDirectory := t.TempDir()
+	op := &util.Options{
+		WorkingDirectory: workingDirectory,
+		Config: &util.Config{
+			Kopia: &repo.LocalConfig{
+				Storage: &blob.ConnectionInfo{
+					Type: "s3",
+					Config: &s3.Options{
+						BucketName:      "bucket-name",
+						Prefix:          "prefix/",
+						Endpoint:        util.DefaultS3Endpoint,
+						AccessKeyID:     "accessid",
+						SecretAccessKey: "secret",
+					},
+				},
+				ClientOptions: repo.ClientOptions{
+					Hostname: "host-pc",
+					Username: "user",
+				},
+			},
+			GassetId: GassetId,
+			Dirs:     []string{"./assets"},
+		},
+		Password:       "password",
+		Storage:        util.StubStorage{},
+		GassetIdLength: len(GassetId),
+		Reconnect:      util.DefaultReconnectBackoff,
+		OsGetwd: func() (string, error) {
+			return workingDirectory, nil
+		},
+		OsTempDir: func() string {
+			return tempDirectory
+		},
+		OsUserConfigDir: func() (string, error) {
+			return userConfigDirectory, nil
+		},
+		RandIntn: func(n int) int {
+			return 0
+		},
+	}
+	op.SetStorageFactory(doubles.Storage)
+	op.SetRepoController(doubles.Repo)
+	op.SetPolicyManager(doubles.Policy)
+	op.SetKeyring(doubles.Keyring)
+	return op, doubles
+}
+
+// NewFakeRepository initializes a kopia repository in memory and connects the options to it, replacing their
+// kopia functions with the real ones. Commands can then be tested end to end without a network.
+func NewFakeRepository(t testing.TB, op *util.Options) *util.MemoryStorage {
+	st, err := util.SetupFakeRepository(context.Background(), op, t.TempDir())
+	if err != nil {
+		t.Fatalf("could not set up the fake repository: %v", err)
+	}
+	return st
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gassettest
+
+import (
+	"context"
+	"errors"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestNewOptions(t *testing.T) {
+	ctx := context.Background()
+	op, doubles := NewOptions(t, t.TempDir())
+
+	doubles.Storage.EXPECT().BucketPolicy(mock.Anything, mock.AnythingOfType("*s3.Options")).Return(`{"Statement": []}`, nil).Once()
+	doubles.Repo.EXPECT().Open(mock.Anything, "kopia.config", "password", mock.Anything).Return(nil, errors.New("offline")).Once()
+
+	bucketPolicy, err := op.S3BucketPolicy(ctx, op.Config.Kopia.Storage.Config.(*s3.Options))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Statement": []}`, bucketPolicy)
+
+	_, err = op.RepoOpen(ctx, "kopia.config", op.Password, &repo.Options{})
+	assert.EqualError(t, err, "offline")
+}
+
+func TestNewFakeRepository(t *testing.T) {
+	ctx := context.Background()
+	op, _ := NewOptions(t, t.TempDir())
+	NewFakeRepository(t, op)
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, rep.Close(ctx))
+}
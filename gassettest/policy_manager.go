@@ -0,0 +1,90 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package gassettest
+
+import (
+	context "context"
+
+	policy "github.com/kopia/kopia/snapshot/policy"
+	mock "github.com/stretchr/testify/mock"
+
+	repo "github.com/kopia/kopia/repo"
+
+	snapshot "github.com/kopia/kopia/snapshot"
+)
+
+// PolicyManager is an autogenerated mock type for the PolicyManager type
+type PolicyManager struct {
+	mock.Mock
+}
+
+type PolicyManager_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *PolicyManager) EXPECT() *PolicyManager_Expecter {
+	return &PolicyManager_Expecter{mock: &_m.Mock}
+}
+
+// SetPolicy provides a mock function with given fields: ctx, r, si, pol
+func (_m *PolicyManager) SetPolicy(ctx context.Context, r repo.RepositoryWriter, si snapshot.SourceInfo, pol *policy.Policy) error {
+	ret := _m.Called(ctx, r, si, pol)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPolicy")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repo.RepositoryWriter, snapshot.SourceInfo, *policy.Policy) error); ok {
+		r0 = rf(ctx, r, si, pol)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PolicyManager_SetPolicy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetPolicy'
+type PolicyManager_SetPolicy_Call struct {
+	*mock.Call
+}
+
+// SetPolicy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - r repo.RepositoryWriter
+//   - si snapshot.SourceInfo
+//   - pol *policy.Policy
+func (_e *PolicyManager_Expecter) SetPolicy(ctx interface{}, r interface{}, si interface{}, pol interface{}) *PolicyManager_SetPolicy_Call {
+	return &PolicyManager_SetPolicy_Call{Call: _e.mock.On("SetPolicy", ctx, r, si, pol)}
+}
+
+func (_c *PolicyManager_SetPolicy_Call) Run(run func(ctx context.Context, r repo.RepositoryWriter, si snapshot.SourceInfo, pol *policy.Policy)) *PolicyManager_SetPolicy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(repo.RepositoryWriter), args[2].(snapshot.SourceInfo), args[3].(*policy.Policy))
+	})
+	return _c
+}
+
+func (_c *PolicyManager_SetPolicy_Call) Return(_a0 error) *PolicyManager_SetPolicy_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *PolicyManager_SetPolicy_Call) RunAndReturn(run func(context.Context, repo.RepositoryWriter, snapshot.SourceInfo, *policy.Policy) error) *PolicyManager_SetPolicy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewPolicyManager creates a new instance of PolicyManager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPolicyManager(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PolicyManager {
+	mock := &PolicyManager{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
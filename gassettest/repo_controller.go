@@ -0,0 +1,299 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package gassettest
+
+import (
+	context "context"
+
+	blob "github.com/kopia/kopia/repo/blob"
+
+	mock "github.com/stretchr/testify/mock"
+
+	repo "github.com/kopia/kopia/repo"
+)
+
+// RepoController is an autogenerated mock type for the RepoController type
+type RepoController struct {
+	mock.Mock
+}
+
+type RepoController_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *RepoController) EXPECT() *RepoController_Expecter {
+	return &RepoController_Expecter{mock: &_m.Mock}
+}
+
+// Connect provides a mock function with given fields: ctx, configFile, st, password, options
+func (_m *RepoController) Connect(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions) error {
+	ret := _m.Called(ctx, configFile, st, password, options)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Connect")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, blob.Storage, string, *repo.ConnectOptions) error); ok {
+		r0 = rf(ctx, configFile, st, password, options)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RepoController_Connect_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Connect'
+type RepoController_Connect_Call struct {
+	*mock.Call
+}
+
+// Connect is a helper method to define mock.On call
+//   - ctx context.Context
+//   - configFile string
+//   - st blob.Storage
+//   - password string
+//   - options *repo.ConnectOptions
+func (_e *RepoController_Expecter) Connect(ctx interface{}, configFile interface{}, st interface{}, password interface{}, options interface{}) *RepoController_Connect_Call {
+	return &RepoController_Connect_Call{Call: _e.mock.On("Connect", ctx, configFile, st, password, options)}
+}
+
+func (_c *RepoController_Connect_Call) Run(run func(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions)) *RepoController_Connect_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(blob.Storage), args[3].(string), args[4].(*repo.ConnectOptions))
+	})
+	return _c
+}
+
+func (_c *RepoController_Connect_Call) Return(_a0 error) *RepoController_Connect_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *RepoController_Connect_Call) RunAndReturn(run func(context.Context, string, blob.Storage, string, *repo.ConnectOptions) error) *RepoController_Connect_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ConnectAPIServer provides a mock function with given fields: ctx, configFile, si, password, options
+func (_m *RepoController) ConnectAPIServer(ctx context.Context, configFile string, si *repo.APIServerInfo, password string, options *repo.ConnectOptions) error {
+	ret := _m.Called(ctx, configFile, si, password, options)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConnectAPIServer")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *repo.APIServerInfo, string, *repo.ConnectOptions) error); ok {
+		r0 = rf(ctx, configFile, si, password, options)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RepoController_ConnectAPIServer_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ConnectAPIServer'
+type RepoController_ConnectAPIServer_Call struct {
+	*mock.Call
+}
+
+// ConnectAPIServer is a helper method to define mock.On call
+//   - ctx context.Context
+//   - configFile string
+//   - si *repo.APIServerInfo
+//   - password string
+//   - options *repo.ConnectOptions
+func (_e *RepoController_Expecter) ConnectAPIServer(ctx interface{}, configFile interface{}, si interface{}, password interface{}, options interface{}) *RepoController_ConnectAPIServer_Call {
+	return &RepoController_ConnectAPIServer_Call{Call: _e.mock.On("ConnectAPIServer", ctx, configFile, si, password, options)}
+}
+
+func (_c *RepoController_ConnectAPIServer_Call) Run(run func(ctx context.Context, configFile string, si *repo.APIServerInfo, password string, options *repo.ConnectOptions)) *RepoController_ConnectAPIServer_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(*repo.APIServerInfo), args[3].(string), args[4].(*repo.ConnectOptions))
+	})
+	return _c
+}
+
+func (_c *RepoController_ConnectAPIServer_Call) Return(_a0 error) *RepoController_ConnectAPIServer_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *RepoController_ConnectAPIServer_Call) RunAndReturn(run func(context.Context, string, *repo.APIServerInfo, string, *repo.ConnectOptions) error) *RepoController_ConnectAPIServer_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Initialize provides a mock function with given fields: ctx, st, opt, password
+func (_m *RepoController) Initialize(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string) error {
+	ret := _m.Called(ctx, st, opt, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Initialize")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, blob.Storage, *repo.NewRepositoryOptions, string) error); ok {
+		r0 = rf(ctx, st, opt, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RepoController_Initialize_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Initialize'
+type RepoController_Initialize_Call struct {
+	*mock.Call
+}
+
+// Initialize is a helper method to define mock.On call
+//   - ctx context.Context
+//   - st blob.Storage
+//   - opt *repo.NewRepositoryOptions
+//   - password string
+func (_e *RepoController_Expecter) Initialize(ctx interface{}, st interface{}, opt interface{}, password interface{}) *RepoController_Initialize_Call {
+	return &RepoController_Initialize_Call{Call: _e.mock.On("Initialize", ctx, st, opt, password)}
+}
+
+func (_c *RepoController_Initialize_Call) Run(run func(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string)) *RepoController_Initialize_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(blob.Storage), args[2].(*repo.NewRepositoryOptions), args[3].(string))
+	})
+	return _c
+}
+
+func (_c *RepoController_Initialize_Call) Return(_a0 error) *RepoController_Initialize_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *RepoController_Initialize_Call) RunAndReturn(run func(context.Context, blob.Storage, *repo.NewRepositoryOptions, string) error) *RepoController_Initialize_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Open provides a mock function with given fields: ctx, configFile, password, options
+func (_m *RepoController) Open(ctx context.Context, configFile string, password string, options *repo.Options) (repo.Repository, error) {
+	ret := _m.Called(ctx, configFile, password, options)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Open")
+	}
+
+	var r0 repo.Repository
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *repo.Options) (repo.Repository, error)); ok {
+		return rf(ctx, configFile, password, options)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *repo.Options) repo.Repository); ok {
+		r0 = rf(ctx, configFile, password, options)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(repo.Repository)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, *repo.Options) error); ok {
+		r1 = rf(ctx, configFile, password, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RepoController_Open_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Open'
+type RepoController_Open_Call struct {
+	*mock.Call
+}
+
+// Open is a helper method to define mock.On call
+//   - ctx context.Context
+//   - configFile string
+//   - password string
+//   - options *repo.Options
+func (_e *RepoController_Expecter) Open(ctx interface{}, configFile interface{}, password interface{}, options interface{}) *RepoController_Open_Call {
+	return &RepoController_Open_Call{Call: _e.mock.On("Open", ctx, configFile, password, options)}
+}
+
+func (_c *RepoController_Open_Call) Run(run func(ctx context.Context, configFile string, password string, options *repo.Options)) *RepoController_Open_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].(*repo.Options))
+	})
+	return _c
+}
+
+func (_c *RepoController_Open_Call) Return(_a0 repo.Repository, _a1 error) *RepoController_Open_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *RepoController_Open_Call) RunAndReturn(run func(context.Context, string, string, *repo.Options) (repo.Repository, error)) *RepoController_Open_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteSession provides a mock function with given fields: ctx, r, opt, cb
+func (_m *RepoController) WriteSession(ctx context.Context, r repo.Repository, opt repo.WriteSessionOptions, cb func(context.Context, repo.RepositoryWriter) error) error {
+	ret := _m.Called(ctx, r, opt, cb)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteSession")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repo.Repository, repo.WriteSessionOptions, func(context.Context, repo.RepositoryWriter) error) error); ok {
+		r0 = rf(ctx, r, opt, cb)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RepoController_WriteSession_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteSession'
+type RepoController_WriteSession_Call struct {
+	*mock.Call
+}
+
+// WriteSession is a helper method to define mock.On call
+//   - ctx context.Context
+//   - r repo.Repository
+//   - opt repo.WriteSessionOptions
+//   - cb func(context.Context , repo.RepositoryWriter) error
+func (_e *RepoController_Expecter) WriteSession(ctx interface{}, r interface{}, opt interface{}, cb interface{}) *RepoController_WriteSession_Call {
+	return &RepoController_WriteSession_Call{Call: _e.mock.On("WriteSession", ctx, r, opt, cb)}
+}
+
+func (_c *RepoController_WriteSession_Call) Run(run func(ctx context.Context, r repo.Repository, opt repo.WriteSessionOptions, cb func(context.Context, repo.RepositoryWriter) error)) *RepoController_WriteSession_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(repo.Repository), args[2].(repo.WriteSessionOptions), args[3].(func(context.Context, repo.RepositoryWriter) error))
+	})
+	return _c
+}
+
+func (_c *RepoController_WriteSession_Call) Return(_a0 error) *RepoController_WriteSession_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *RepoController_WriteSession_Call) RunAndReturn(run func(context.Context, repo.Repository, repo.WriteSessionOptions, func(context.Context, repo.RepositoryWriter) error) error) *RepoController_WriteSession_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewRepoController creates a new instance of RepoController. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRepoController(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *RepoController {
+	mock := &RepoController{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,389 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package gassettest
+
+import (
+	blob "github.com/kopia/kopia/repo/blob"
+	b2 "github.com/kopia/kopia/repo/blob/b2"
+
+	context "context"
+
+	filesystem "github.com/kopia/kopia/repo/blob/filesystem"
+
+	mock "github.com/stretchr/testify/mock"
+
+	s3 "github.com/kopia/kopia/repo/blob/s3"
+
+	util "git-gasset/util"
+)
+
+// StorageFactory is an autogenerated mock type for the StorageFactory type
+type StorageFactory struct {
+	mock.Mock
+}
+
+type StorageFactory_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *StorageFactory) EXPECT() *StorageFactory_Expecter {
+	return &StorageFactory_Expecter{mock: &_m.Mock}
+}
+
+// BucketPolicy provides a mock function with given fields: ctx, opt
+func (_m *StorageFactory) BucketPolicy(ctx context.Context, opt *s3.Options) (string, error) {
+	ret := _m.Called(ctx, opt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BucketPolicy")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *s3.Options) (string, error)); ok {
+		return rf(ctx, opt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *s3.Options) string); ok {
+		r0 = rf(ctx, opt)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *s3.Options) error); ok {
+		r1 = rf(ctx, opt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StorageFactory_BucketPolicy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BucketPolicy'
+type StorageFactory_BucketPolicy_Call struct {
+	*mock.Call
+}
+
+// BucketPolicy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opt *s3.Options
+func (_e *StorageFactory_Expecter) BucketPolicy(ctx interface{}, opt interface{}) *StorageFactory_BucketPolicy_Call {
+	return &StorageFactory_BucketPolicy_Call{Call: _e.mock.On("BucketPolicy", ctx, opt)}
+}
+
+func (_c *StorageFactory_BucketPolicy_Call) Run(run func(ctx context.Context, opt *s3.Options)) *StorageFactory_BucketPolicy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*s3.Options))
+	})
+	return _c
+}
+
+func (_c *StorageFactory_BucketPolicy_Call) Return(_a0 string, _a1 error) *StorageFactory_BucketPolicy_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *StorageFactory_BucketPolicy_Call) RunAndReturn(run func(context.Context, *s3.Options) (string, error)) *StorageFactory_BucketPolicy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// BucketSettings provides a mock function with given fields: ctx, opt
+func (_m *StorageFactory) BucketSettings(ctx context.Context, opt *s3.Options) (*util.BucketSettings, error) {
+	ret := _m.Called(ctx, opt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BucketSettings")
+	}
+
+	var r0 *util.BucketSettings
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *s3.Options) (*util.BucketSettings, error)); ok {
+		return rf(ctx, opt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *s3.Options) *util.BucketSettings); ok {
+		r0 = rf(ctx, opt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*util.BucketSettings)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *s3.Options) error); ok {
+		r1 = rf(ctx, opt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StorageFactory_BucketSettings_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BucketSettings'
+type StorageFactory_BucketSettings_Call struct {
+	*mock.Call
+}
+
+// BucketSettings is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opt *s3.Options
+func (_e *StorageFactory_Expecter) BucketSettings(ctx interface{}, opt interface{}) *StorageFactory_BucketSettings_Call {
+	return &StorageFactory_BucketSettings_Call{Call: _e.mock.On("BucketSettings", ctx, opt)}
+}
+
+func (_c *StorageFactory_BucketSettings_Call) Run(run func(ctx context.Context, opt *s3.Options)) *StorageFactory_BucketSettings_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*s3.Options))
+	})
+	return _c
+}
+
+func (_c *StorageFactory_BucketSettings_Call) Return(_a0 *util.BucketSettings, _a1 error) *StorageFactory_BucketSettings_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *StorageFactory_BucketSettings_Call) RunAndReturn(run func(context.Context, *s3.Options) (*util.BucketSettings, error)) *StorageFactory_BucketSettings_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CheckEncryption provides a mock function with given fields: ctx, opt, encryption
+func (_m *StorageFactory) CheckEncryption(ctx context.Context, opt *s3.Options, encryption *util.EncryptionOptions) error {
+	ret := _m.Called(ctx, opt, encryption)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CheckEncryption")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *s3.Options, *util.EncryptionOptions) error); ok {
+		r0 = rf(ctx, opt, encryption)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StorageFactory_CheckEncryption_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CheckEncryption'
+type StorageFactory_CheckEncryption_Call struct {
+	*mock.Call
+}
+
+// CheckEncryption is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opt *s3.Options
+//   - encryption *util.EncryptionOptions
+func (_e *StorageFactory_Expecter) CheckEncryption(ctx interface{}, opt interface{}, encryption interface{}) *StorageFactory_CheckEncryption_Call {
+	return &StorageFactory_CheckEncryption_Call{Call: _e.mock.On("CheckEncryption", ctx, opt, encryption)}
+}
+
+func (_c *StorageFactory_CheckEncryption_Call) Run(run func(ctx context.Context, opt *s3.Options, encryption *util.EncryptionOptions)) *StorageFactory_CheckEncryption_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*s3.Options), args[2].(*util.EncryptionOptions))
+	})
+	return _c
+}
+
+func (_c *StorageFactory_CheckEncryption_Call) Return(_a0 error) *StorageFactory_CheckEncryption_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *StorageFactory_CheckEncryption_Call) RunAndReturn(run func(context.Context, *s3.Options, *util.EncryptionOptions) error) *StorageFactory_CheckEncryption_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewB2 provides a mock function with given fields: ctx, opt, createIfNotExist
+func (_m *StorageFactory) NewB2(ctx context.Context, opt *b2.Options, createIfNotExist bool) (blob.Storage, error) {
+	ret := _m.Called(ctx, opt, createIfNotExist)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewB2")
+	}
+
+	var r0 blob.Storage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *b2.Options, bool) (blob.Storage, error)); ok {
+		return rf(ctx, opt, createIfNotExist)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *b2.Options, bool) blob.Storage); ok {
+		r0 = rf(ctx, opt, createIfNotExist)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(blob.Storage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *b2.Options, bool) error); ok {
+		r1 = rf(ctx, opt, createIfNotExist)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StorageFactory_NewB2_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'NewB2'
+type StorageFactory_NewB2_Call struct {
+	*mock.Call
+}
+
+// NewB2 is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opt *b2.Options
+//   - createIfNotExist bool
+func (_e *StorageFactory_Expecter) NewB2(ctx interface{}, opt interface{}, createIfNotExist interface{}) *StorageFactory_NewB2_Call {
+	return &StorageFactory_NewB2_Call{Call: _e.mock.On("NewB2", ctx, opt, createIfNotExist)}
+}
+
+func (_c *StorageFactory_NewB2_Call) Run(run func(ctx context.Context, opt *b2.Options, createIfNotExist bool)) *StorageFactory_NewB2_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*b2.Options), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *StorageFactory_NewB2_Call) Return(_a0 blob.Storage, _a1 error) *StorageFactory_NewB2_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *StorageFactory_NewB2_Call) RunAndReturn(run func(context.Context, *b2.Options, bool) (blob.Storage, error)) *StorageFactory_NewB2_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewFilesystem provides a mock function with given fields: ctx, opt, createIfNotExist
+func (_m *StorageFactory) NewFilesystem(ctx context.Context, opt *filesystem.Options, createIfNotExist bool) (blob.Storage, error) {
+	ret := _m.Called(ctx, opt, createIfNotExist)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewFilesystem")
+	}
+
+	var r0 blob.Storage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *filesystem.Options, bool) (blob.Storage, error)); ok {
+		return rf(ctx, opt, createIfNotExist)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *filesystem.Options, bool) blob.Storage); ok {
+		r0 = rf(ctx, opt, createIfNotExist)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(blob.Storage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *filesystem.Options, bool) error); ok {
+		r1 = rf(ctx, opt, createIfNotExist)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StorageFactory_NewFilesystem_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'NewFilesystem'
+type StorageFactory_NewFilesystem_Call struct {
+	*mock.Call
+}
+
+// NewFilesystem is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opt *filesystem.Options
+//   - createIfNotExist bool
+func (_e *StorageFactory_Expecter) NewFilesystem(ctx interface{}, opt interface{}, createIfNotExist interface{}) *StorageFactory_NewFilesystem_Call {
+	return &StorageFactory_NewFilesystem_Call{Call: _e.mock.On("NewFilesystem", ctx, opt, createIfNotExist)}
+}
+
+func (_c *StorageFactory_NewFilesystem_Call) Run(run func(ctx context.Context, opt *filesystem.Options, createIfNotExist bool)) *StorageFactory_NewFilesystem_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*filesystem.Options), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *StorageFactory_NewFilesystem_Call) Return(_a0 blob.Storage, _a1 error) *StorageFactory_NewFilesystem_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *StorageFactory_NewFilesystem_Call) RunAndReturn(run func(context.Context, *filesystem.Options, bool) (blob.Storage, error)) *StorageFactory_NewFilesystem_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewS3 provides a mock function with given fields: ctx, opt, createIfNotExist
+func (_m *StorageFactory) NewS3(ctx context.Context, opt *s3.Options, createIfNotExist bool) (blob.Storage, error) {
+	ret := _m.Called(ctx, opt, createIfNotExist)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewS3")
+	}
+
+	var r0 blob.Storage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *s3.Options, bool) (blob.Storage, error)); ok {
+		return rf(ctx, opt, createIfNotExist)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *s3.Options, bool) blob.Storage); ok {
+		r0 = rf(ctx, opt, createIfNotExist)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(blob.Storage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *s3.Options, bool) error); ok {
+		r1 = rf(ctx, opt, createIfNotExist)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StorageFactory_NewS3_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'NewS3'
+type StorageFactory_NewS3_Call struct {
+	*mock.Call
+}
+
+// NewS3 is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opt *s3.Options
+//   - createIfNotExist bool
+func (_e *StorageFactory_Expecter) NewS3(ctx interface{}, opt interface{}, createIfNotExist interface{}) *StorageFactory_NewS3_Call {
+	return &StorageFactory_NewS3_Call{Call: _e.mock.On("NewS3", ctx, opt, createIfNotExist)}
+}
+
+func (_c *StorageFactory_NewS3_Call) Run(run func(ctx context.Context, opt *s3.Options, createIfNotExist bool)) *StorageFactory_NewS3_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*s3.Options), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *StorageFactory_NewS3_Call) Return(_a0 blob.Storage, _a1 error) *StorageFactory_NewS3_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *StorageFactory_NewS3_Call) RunAndReturn(run func(context.Context, *s3.Options, bool) (blob.Storage, error)) *StorageFactory_NewS3_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewStorageFactory creates a new instance of StorageFactory. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewStorageFactory(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *StorageFactory {
+	mock := &StorageFactory{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
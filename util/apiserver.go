@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"io/fs"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrServerCertificateMismatch is returned when the kopia API server presents another certificate than the trusted one
+var ErrServerCertificateMismatch = errors.New("the certificate of the kopia API server does not match the trusted fingerprint")
+
+// serverFingerprintPattern is a SHA256 fingerprint like kopia compares it, in hex without colons
+var serverFingerprintPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// CheckServerCertificate checks that the kopia API server presents the certificate of the fingerprint of the
+// .gasset file, so that a wrong or rotated certificate fails with the fingerprints instead of a gRPC error.
+// Without a fingerprint the certificate is verified by kopia against the roots of the os.
+func CheckServerCertificate(ctx context.Context, server *repo.APIServerInfo) error {
+	serverURL, err := url.Parse(server.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL of the kopia API server: %w", err)
+	}
+	fingerprint := server.TrustedServerCertificateFingerprint
+	if fingerprint == "" || serverURL.Scheme != "https" {
+		return nil
+	}
+	if !serverFingerprintPattern.MatchString(fingerprint) {
+		return fmt.Errorf("the server certificate fingerprint %s is not a SHA256 fingerprint in hex, e.g. the one printed by kopia server start, without colons", fingerprint)
+	}
+
+	address := serverURL.Host
+	if serverURL.Port() == "" {
+		address = net.JoinHostPort(serverURL.Hostname(), "443")
+	}
+	// The certificate is self-signed in general, it is trusted by its fingerprint like kopia does
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("could not reach the kopia API server %s: %w", server.BaseURL, err)
+	}
+	defer conn.Close()
+
+	var presented []string
+	for _, cert := range conn.(*tls.Conn).ConnectionState().PeerCertificates {
+		sum := sha256.Sum256(cert.Raw)
+		if hex.EncodeToString(sum[:]) == strings.ToLower(fingerprint) {
+			return nil
+		}
+		presented = append(presented, hex.EncodeToString(sum[:]))
+	}
+	return fmt.Errorf("%w: %s presents %s, the .gasset file trusts %s", ErrServerCertificateMismatch, server.BaseURL, strings.Join(presented, ", "), fingerprint)
+}
+
+// CheckServerConnection checks that the kopia config is connected to the kopia API server of the .gasset file with
+// the trusted certificate, and that the server still presents it. It does nothing without an API server.
+func (op *Options) CheckServerConnection(ctx context.Context) error {
+	if !op.ConnectsToServer() {
+		return nil
+	}
+	server := op.Config.Kopia.APIServer
+
+	// An external kopia config was checked while loading the options
+	if !op.UsesExternalKopiaConfig() {
+		kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+		if err != nil {
+			return err
+		}
+		connected, err := repo.LoadConfigFromFile(kopiaUserConfigPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("not connected to the kopia API server %s, run init", server.BaseURL)
+		}
+		if err != nil {
+			return err
+		}
+		if connected.APIServer == nil || connected.APIServer.BaseURL != server.BaseURL ||
+			!strings.EqualFold(connected.APIServer.TrustedServerCertificateFingerprint, server.TrustedServerCertificateFingerprint) {
+			return fmt.Errorf("the kopia config %s is not connected to the kopia API server %s with the certificate of the .gasset file, run init again", kopiaUserConfigPath, server.BaseURL)
+		}
+	}
+
+	return CheckServerCertificate(ctx, server)
+}
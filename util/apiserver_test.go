@@ -0,0 +1,90 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckServerCertificate(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	sum := sha256.Sum256(server.Certificate().Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	assert.NoError(t, CheckServerCertificate(ctx, &repo.APIServerInfo{BaseURL: server.URL, TrustedServerCertificateFingerprint: strings.ToUpper(fingerprint)}))
+	assert.NoError(t, CheckServerCertificate(ctx, &repo.APIServerInfo{BaseURL: server.URL}), "CheckServerCertificate() without a fingerprint")
+	assert.ErrorIs(t, CheckServerCertificate(ctx, &repo.APIServerInfo{BaseURL: server.URL, TrustedServerCertificateFingerprint: strings.Repeat("0", 64)}), ErrServerCertificateMismatch)
+
+	var colons []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colons = append(colons, fingerprint[i:i+2])
+	}
+	assert.Error(t, CheckServerCertificate(ctx, &repo.APIServerInfo{BaseURL: server.URL, TrustedServerCertificateFingerprint: strings.Join(colons, ":")}), "CheckServerCertificate() with a fingerprint with colons")
+}
+
+func TestCheckServerConnection(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	sum := sha256.Sum256(server.Certificate().Raw)
+	apiServer := &repo.APIServerInfo{BaseURL: server.URL, TrustedServerCertificateFingerprint: hex.EncodeToString(sum[:])}
+
+	userConfigDir := t.TempDir()
+	op := &Options{
+		Config: &Config{GassetId: "0000000000", Kopia: &repo.LocalConfig{APIServer: apiServer}},
+		OsUserConfigDir: func() (string, error) {
+			return userConfigDir, nil
+		},
+	}
+	assert.Error(t, op.CheckServerConnection(ctx), "CheckServerConnection() before init")
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		t.FailNow()
+	}
+	writeKopiaConfig := func(connected *repo.APIServerInfo) {
+		configBytes, err := json.Marshal(&repo.LocalConfig{APIServer: connected})
+		if err != nil {
+			t.FailNow()
+		}
+		if os.MkdirAll(filepath.Dir(kopiaUserConfigPath), 0o700) != nil || os.WriteFile(kopiaUserConfigPath, configBytes, 0o600) != nil {
+			t.FailNow()
+		}
+	}
+
+	writeKopiaConfig(apiServer)
+	assert.NoError(t, op.CheckServerConnection(ctx))
+
+	writeKopiaConfig(&repo.APIServerInfo{BaseURL: server.URL, TrustedServerCertificateFingerprint: strings.Repeat("0", 64)})
+	assert.Error(t, op.CheckServerConnection(ctx), "CheckServerConnection() with the previous certificate in the kopia config")
+
+	op.Config.Kopia = &repo.LocalConfig{}
+	assert.NoError(t, op.CheckServerConnection(ctx), "CheckServerConnection() without an API server")
+}
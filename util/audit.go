@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"sort"
+	"time"
+)
+
+// AuditManifestType labels the audit records stored as manifests in the kopia repository.
+// The records are never modified or deleted by gasset, so the manifests form an append-only log.
+const AuditManifestType = "gasset-audit"
+
+type AuditRecord struct {
+	ID        manifest.ID   `json:"-"`
+	Command   string        `json:"command"`
+	Args      []string      `json:"args,omitempty"`
+	User      string        `json:"user"`
+	Host      string        `json:"host"`
+	Time      time.Time     `json:"time"`
+	Manifests []manifest.ID `json:"manifests,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// AuditFilter selects the audit records to list, empty fields match everything
+type AuditFilter struct {
+	User    string
+	Command string
+	Since   time.Time
+}
+
+// NewAuditRecord returns a record of a command run by the current user or machine identity
+func (op *Options) NewAuditRecord(command string, args []string) *AuditRecord {
+	clientOptions := op.ClientOptions()
+	return &AuditRecord{
+		Command: command,
+		Args:    args,
+		User:    clientOptions.Username,
+		Host:    clientOptions.Hostname,
+		Time:    time.Now().UTC(),
+	}
+}
+
+// SetError records the error the operation failed with, if any
+func (r *AuditRecord) SetError(err error) {
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+func (r *AuditRecord) labels() map[string]string {
+	return map[string]string{
+		manifest.TypeLabelKey: AuditManifestType,
+		"user":                r.User,
+		"host":                r.Host,
+		"command":             r.Command,
+	}
+}
+
+// WriteAuditRecord stores the record in the repository as part of the write session of the operation
+func WriteAuditRecord(ctx context.Context, writer repo.RepositoryWriter, record *AuditRecord) error {
+	id, err := writer.PutManifest(ctx, record.labels(), record)
+	if err != nil {
+		return err
+	}
+	record.ID = id
+	return nil
+}
+
+// Labels returns the manifest labels matching the filter
+func (f AuditFilter) Labels() map[string]string {
+	labels := map[string]string{manifest.TypeLabelKey: AuditManifestType}
+	if f.User != "" {
+		labels["user"] = f.User
+	}
+	if f.Command != "" {
+		labels["command"] = f.Command
+	}
+	return labels
+}
+
+// ListAuditRecords returns the audit records matching the filter, oldest first
+func ListAuditRecords(ctx context.Context, rep repo.Repository, filter AuditFilter) ([]*AuditRecord, error) {
+	entries, err := rep.FindManifests(ctx, filter.Labels())
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*AuditRecord, 0, len(entries))
+	for _, entry := range entries {
+		// The manifest is written at the end of the operation so it can't be older than the record
+		if entry.ModTime.Before(filter.Since) {
+			continue
+		}
+
+		record := &AuditRecord{}
+		if _, err := rep.GetManifest(ctx, entry.ID, record); err != nil {
+			return nil, err
+		}
+		record.ID = entry.ID
+		if record.Time.Before(filter.Since) {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewAuditRecord(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+
+	options := testOptions.OptionsWithGassetId.Clone()
+	record := options.NewAuditRecord("purge-file", []string{"./assets/a.png"})
+	assert.Equal(t, "user", record.User)
+	assert.Equal(t, "host-pc", record.Host)
+	assert.Equal(t, []string{"./assets/a.png"}, record.Args)
+
+	if err := options.SetMachineIdentity("ci-agent"); err != nil {
+		t.FailNow()
+	}
+	record = options.NewAuditRecord("snap", nil)
+	assert.Equal(t, MachineUserName, record.User)
+	assert.Equal(t, "ci-agent", record.Host)
+	assert.Equal(t, "snap", record.labels()["command"])
+}
+
+func TestAuditFilterLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   map[string]string
+	}{
+		{
+			name:   "Match all records without a filter",
+			filter: AuditFilter{},
+			want:   map[string]string{manifest.TypeLabelKey: AuditManifestType},
+		},
+		{
+			name:   "Match the user and command",
+			filter: AuditFilter{User: "user", Command: "purge-file"},
+			want:   map[string]string{manifest.TypeLabelKey: AuditManifestType, "user": "user", "command": "purge-file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.filter.Labels(), "Labels()")
+		})
+	}
+}
+
+func TestAuditRecordSetError(t *testing.T) {
+	record := &AuditRecord{}
+	record.SetError(nil)
+	assert.Empty(t, record.Error)
+	record.SetError(errors.Join(errors.New("./assets: canceled"), errors.New("./models: canceled")))
+	assert.Equal(t, "./assets: canceled\n./models: canceled", record.Error)
+}
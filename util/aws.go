@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"regexp"
+	"strings"
+)
+
+// AWSAccelerateEndpoint is the endpoint of S3 Transfer Acceleration, which routes the transfers through the
+// closest edge location. The requests are sent to <bucket>.s3-accelerate.amazonaws.com and signed for the region.
+const AWSAccelerateEndpoint = "s3-accelerate.amazonaws.com"
+
+var awsRegionalEndpoint = regexp.MustCompile(`^s3[.-](?:dualstack\.)?([a-z0-9-]+)\.amazonaws\.com$`)
+
+// AWSOptions applies to buckets on AWS S3 only, other S3 compatible providers are configured with the endpoint
+type AWSOptions struct {
+	Region               string `json:"region"`
+	TransferAcceleration bool   `json:"transferAcceleration,omitempty"`
+}
+
+// IsAWSEndpoint returns true if the endpoint belongs to AWS S3
+func IsAWSEndpoint(endpoint string) bool {
+	return endpoint == "s3.amazonaws.com" || strings.HasSuffix(endpoint, ".amazonaws.com")
+}
+
+// ApplyAWSOptions pins the region of the S3 options and points the endpoint at the
+// regional or the accelerated endpoint of AWS S3
+func ApplyAWSOptions(options *s3.Options, aws *AWSOptions) error {
+	if aws == nil {
+		return nil
+	}
+	if aws.Region == "" {
+		return errors.New("aws region is required")
+	}
+	if options.Endpoint != "" && !IsAWSEndpoint(options.Endpoint) {
+		return fmt.Errorf("aws options don't apply to the endpoint %s which is not AWS S3", options.Endpoint)
+	}
+
+	if aws.TransferAcceleration {
+		// The bucket becomes part of the host name of the accelerated endpoint
+		if strings.Contains(options.BucketName, ".") {
+			return fmt.Errorf("transfer acceleration does not support the bucket %s with dots in its name", options.BucketName)
+		}
+		options.Endpoint = AWSAccelerateEndpoint
+	} else if matches := awsRegionalEndpoint.FindStringSubmatch(options.Endpoint); matches != nil && matches[1] != "accelerate" {
+		if matches[1] != aws.Region {
+			return fmt.Errorf("endpoint %s is not in the pinned region %s", options.Endpoint, aws.Region)
+		}
+	} else {
+		options.Endpoint = "s3." + aws.Region + ".amazonaws.com"
+	}
+
+	// An explicit region also keeps the client from looking up the bucket location on every connection
+	options.Region = aws.Region
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApplyAWSOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options s3.Options
+		aws     *AWSOptions
+		want    s3.Options
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Keep the options without aws options",
+			options: s3.Options{BucketName: "bucket", Endpoint: "endpoint.digitaloceanspaces.com"},
+			aws:     nil,
+			want:    s3.Options{BucketName: "bucket", Endpoint: "endpoint.digitaloceanspaces.com"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Use the regional endpoint",
+			options: s3.Options{BucketName: "bucket", Endpoint: "s3.amazonaws.com"},
+			aws:     &AWSOptions{Region: "eu-west-1"},
+			want:    s3.Options{BucketName: "bucket", Endpoint: "s3.eu-west-1.amazonaws.com", Region: "eu-west-1"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Keep a dualstack endpoint in the region",
+			options: s3.Options{BucketName: "bucket", Endpoint: "s3.dualstack.eu-west-1.amazonaws.com"},
+			aws:     &AWSOptions{Region: "eu-west-1"},
+			want:    s3.Options{BucketName: "bucket", Endpoint: "s3.dualstack.eu-west-1.amazonaws.com", Region: "eu-west-1"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Use the accelerated endpoint",
+			options: s3.Options{BucketName: "bucket", Endpoint: "s3.eu-west-1.amazonaws.com"},
+			aws:     &AWSOptions{Region: "eu-west-1", TransferAcceleration: true},
+			want:    s3.Options{BucketName: "bucket", Endpoint: AWSAccelerateEndpoint, Region: "eu-west-1"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Check if error is thrown for an endpoint in another region",
+			options: s3.Options{BucketName: "bucket", Endpoint: "s3.us-east-2.amazonaws.com"},
+			aws:     &AWSOptions{Region: "eu-west-1"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Check if error is thrown for other providers",
+			options: s3.Options{BucketName: "bucket", Endpoint: "endpoint.digitaloceanspaces.com"},
+			aws:     &AWSOptions{Region: "eu-west-1"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Check if error is thrown for acceleration of a bucket with dots",
+			options: s3.Options{BucketName: "assets.example.com"},
+			aws:     &AWSOptions{Region: "eu-west-1", TransferAcceleration: true},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Check if error is thrown without a region",
+			options: s3.Options{BucketName: "bucket"},
+			aws:     &AWSOptions{TransferAcceleration: true},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplyAWSOptions(&tt.options, tt.aws)
+			if !tt.wantErr(t, err, "ApplyAWSOptions()") || err != nil {
+				return
+			}
+			assert.Equalf(t, tt.want, tt.options, "ApplyAWSOptions()")
+		})
+	}
+}
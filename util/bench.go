@@ -0,0 +1,239 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo/compression"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/sha3"
+	"hash"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// parallelismTolerance is the share of the best throughput a lower parallelism may lose and still be recommended
+const parallelismTolerance = 0.1
+
+// downloadLimitShare is the share of the measured throughput recommended as download limit to keep the link usable
+const downloadLimitShare = 0.8
+
+// compressionSavingsThreshold is the share of the bytes a compressor has to save to be recommended over none
+const compressionSavingsThreshold = 0.05
+
+// compressionSpeedShare is the share of the throughput of the fastest compressor a recommended one has to reach
+const compressionSpeedShare = 0.25
+
+type BenchResult struct {
+	Parallelism int
+	Bytes       int64
+	Duration    time.Duration
+}
+
+// Throughput returns the measured throughput in bytes per second
+func (r BenchResult) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+// RecommendParallelism returns the result with the lowest parallelism whose throughput is close to the best one
+func RecommendParallelism(results []BenchResult) BenchResult {
+	var best BenchResult
+	for _, result := range results {
+		if result.Throughput() > best.Throughput() {
+			best = result
+		}
+	}
+
+	recommended := best
+	for _, result := range results {
+		if result.Throughput() >= best.Throughput()*(1-parallelismTolerance) && result.Parallelism < recommended.Parallelism {
+			recommended = result
+		}
+	}
+	return recommended
+}
+
+// RecommendDownloadLimit returns the download limit in bytes per second which leaves part of the link free
+func RecommendDownloadLimit(result BenchResult) float64 {
+	return result.Throughput() * downloadLimitShare
+}
+
+// FormatThroughput returns a human-readable throughput
+func FormatThroughput(bytesPerSecond float64) string {
+	units := []string{"B/s", "KiB/s", "MiB/s", "GiB/s"}
+	unit := 0
+	for bytesPerSecond >= 1024 && unit < len(units)-1 {
+		bytesPerSecond /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", bytesPerSecond, units[unit])
+}
+
+// NewBenchHash returns the hash kopia uses for the given hash function so that its speed can be measured.
+// mostly from github.com/kopia/kopia/repo/hashing
+func NewBenchHash(hashFunction string, secret []byte) (hash.Hash, error) {
+	switch {
+	case strings.HasPrefix(hashFunction, "BLAKE2B-256"):
+		return blake2b.New256(secret)
+	case hashFunction == "BLAKE2S-128":
+		return blake2s.New128(secret)
+	case hashFunction == "BLAKE2S-256":
+		return blake2s.New256(secret)
+	case strings.HasPrefix(hashFunction, "HMAC-SHA256"):
+		return hmac.New(sha256.New, secret), nil
+	case hashFunction == "HMAC-SHA224":
+		return hmac.New(sha256.New224, secret), nil
+	case hashFunction == "HMAC-SHA3-224":
+		return hmac.New(sha3.New224, secret), nil
+	case hashFunction == "HMAC-SHA3-256":
+		return hmac.New(sha3.New256, secret), nil
+	}
+	return nil, fmt.Errorf("hash function %s cannot be benchmarked", hashFunction)
+}
+
+// CompressionResult is the size and time of the samples compressed with a compressor
+type CompressionResult struct {
+	Compressor      compression.Name `json:"compressor"`
+	Bytes           int64            `json:"bytes"`
+	CompressedBytes int64            `json:"compressedBytes"`
+	Duration        time.Duration    `json:"duration"`
+}
+
+// Savings returns the share of the bytes saved by the compression, negative if it grew them
+func (r CompressionResult) Savings() float64 {
+	if r.Bytes == 0 {
+		return 0
+	}
+	return 1 - float64(r.CompressedBytes)/float64(r.Bytes)
+}
+
+// Throughput returns the compression speed in bytes per second of the uncompressed bytes
+func (r CompressionResult) Throughput() float64 {
+	return BenchResult{Bytes: r.Bytes, Duration: r.Duration}.Throughput()
+}
+
+// SampleFiles reads at most sampleSize bytes of up to maxFiles files of the directory,
+// spread evenly over its files sorted by path so that repeated runs read the same samples
+func SampleFiles(dirPath string, maxFiles int, sampleSize int64) ([][]byte, error) {
+	var paths []string
+	err := filepath.WalkDir(dirPath, func(path string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s has no files to sample", dirPath)
+	}
+	sort.Strings(paths)
+
+	count := min(maxFiles, len(paths))
+	samples := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		sample, err := readSample(paths[i*len(paths)/count], sampleSize)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+func readSample(path string, sampleSize int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, sampleSize))
+}
+
+// BenchCompressors compresses the samples with every compressor kopia offers except the deprecated ones, sorted by name
+func BenchCompressors(samples [][]byte) ([]CompressionResult, error) {
+	var names []compression.Name
+	for name := range compression.ByName {
+		if !compression.IsDeprecated[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+
+	results := make([]CompressionResult, 0, len(names))
+	var buf bytes.Buffer
+	for _, name := range names {
+		result := CompressionResult{Compressor: name}
+		start := time.Now()
+		for _, sample := range samples {
+			buf.Reset()
+			if err := compression.ByName[name].Compress(&buf, bytes.NewReader(sample)); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			result.Bytes += int64(len(sample))
+			result.CompressedBytes += int64(buf.Len())
+		}
+		result.Duration = time.Since(start)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// RecommendCompression returns the compressor saving the most among the ones reaching a share of the speed of the
+// fastest, or none if no compressor saves enough to be worth the time, e.g. for assets compressed already
+func RecommendCompression(results []CompressionResult) (compression.Name, error) {
+	if len(results) == 0 {
+		return "", errors.New("no compressor was measured")
+	}
+	var fastest float64
+	for _, result := range results {
+		fastest = max(fastest, result.Throughput())
+	}
+
+	var recommended *CompressionResult
+	for i, result := range results {
+		if result.Throughput() < fastest*compressionSpeedShare {
+			continue
+		}
+		if recommended == nil || result.Savings() > recommended.Savings() {
+			recommended = &results[i]
+		}
+	}
+	if recommended == nil || recommended.Savings() < compressionSavingsThreshold {
+		return "none", nil
+	}
+	return recommended.Compressor, nil
+}
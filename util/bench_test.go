@@ -0,0 +1,133 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecommendParallelism(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []BenchResult
+		want    int
+	}{
+		{
+			name: "Recommend the fastest parallelism",
+			results: []BenchResult{
+				{Parallelism: 1, Bytes: 100, Duration: 4 * time.Second},
+				{Parallelism: 2, Bytes: 100, Duration: 2 * time.Second},
+				{Parallelism: 4, Bytes: 100, Duration: time.Second},
+			},
+			want: 4,
+		},
+		{
+			name: "Recommend a lower parallelism which is almost as fast",
+			results: []BenchResult{
+				{Parallelism: 1, Bytes: 100, Duration: 4 * time.Second},
+				{Parallelism: 2, Bytes: 100, Duration: 1050 * time.Millisecond},
+				{Parallelism: 4, Bytes: 100, Duration: time.Second},
+			},
+			want: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, RecommendParallelism(tt.results).Parallelism, "RecommendParallelism(%v)", tt.results)
+		})
+	}
+}
+
+func TestFormatThroughput(t *testing.T) {
+	tests := []struct {
+		bytesPerSecond float64
+		want           string
+	}{
+		{bytesPerSecond: 512, want: "512.0 B/s"},
+		{bytesPerSecond: 3 * 1024 * 1024, want: "3.0 MiB/s"},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.want, FormatThroughput(tt.bytesPerSecond), "FormatThroughput(%v)", tt.bytesPerSecond)
+	}
+}
+
+func TestRecommendCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []CompressionResult
+		want    string
+	}{
+		{
+			name: "Recommend the best savings among the fast compressors",
+			results: []CompressionResult{
+				{Compressor: "s2-default", Bytes: 1000, CompressedBytes: 600, Duration: time.Second},
+				{Compressor: "zstd", Bytes: 1000, CompressedBytes: 500, Duration: 2 * time.Second},
+				{Compressor: "zstd-best-compression", Bytes: 1000, CompressedBytes: 400, Duration: 10 * time.Second},
+			},
+			want: "zstd",
+		},
+		{
+			name: "Recommend none for assets compressed already",
+			results: []CompressionResult{
+				{Compressor: "s2-default", Bytes: 1000, CompressedBytes: 990, Duration: time.Second},
+				{Compressor: "zstd", Bytes: 1000, CompressedBytes: 1010, Duration: time.Second},
+			},
+			want: "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RecommendCompression(tt.results)
+			assert.NoError(t, err)
+			assert.Equalf(t, tt.want, string(got), "RecommendCompression(%v)", tt.results)
+		})
+	}
+
+	_, err := RecommendCompression(nil)
+	assert.Error(t, err)
+}
+
+func TestSampleFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Repeat(name[:1], i+3)), 0644); err != nil {
+			t.FailNow()
+		}
+	}
+
+	samples, err := SampleFiles(dir, 2, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("aaa"), []byte("cccc")}, samples)
+
+	_, err = SampleFiles(t.TempDir(), 2, 4)
+	assert.Error(t, err, "SampleFiles() of an empty directory")
+
+	results, err := BenchCompressors([][]byte{bytes.Repeat([]byte("texture "), 4096)})
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, results) {
+		return
+	}
+	for _, result := range results {
+		assert.Equal(t, int64(8*4096), result.Bytes)
+		assert.Greaterf(t, result.Savings(), 0.5, "savings of %s", result.Compressor)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// The build metadata, set by the release builds with -ldflags "-X git-gasset/util.Version=..."
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+const kopiaModule = "github.com/kopia/kopia"
+
+// BuildInfo describes the binary so that tooling can detect what the installed version supports
+type BuildInfo struct {
+	Version      string          `json:"version"`
+	Commit       string          `json:"commit,omitempty"`
+	Date         string          `json:"date,omitempty"`
+	GoVersion    string          `json:"goVersion"`
+	Platform     string          `json:"platform"`
+	KopiaVersion string          `json:"kopiaVersion,omitempty"`
+	Capabilities map[string]bool `json:"capabilities"`
+}
+
+// GetBuildInfo returns the build metadata. Builds without the ldflags, e.g. go install, fall back to the
+// VCS revision recorded by the go toolchain.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Capabilities: map[string]bool{
+			// Restored content is shared with reflinks instead of copied
+			"reflink": ReflinkSupported,
+			// The configured modes of the created files are masked by the umask
+			"umask": UmaskSupported,
+		},
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, dep := range buildInfo.Deps {
+		if dep.Path == kopiaModule {
+			info.KopiaVersion = dep.Version
+			if dep.Replace != nil {
+				info.KopiaVersion = dep.Replace.Version
+			}
+		}
+	}
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		}
+	}
+	return info
+}
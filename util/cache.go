@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"github.com/kopia/kopia/repo/format"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cacheSubDirs are the cache directories kopia protects with an HMAC-SHA256 trailer
+var cacheSubDirs = []string{"contents", "metadata", "index-blobs"}
+
+type CacheVerifyResult struct {
+	Checked   int
+	Corrupted []string
+	Evicted   int
+}
+
+// CacheHMACSecret derives the secret kopia uses to protect the local cache items.
+// mostly from github.com/kopia/kopia/repo.openWithConfig
+func CacheHMACSecret(fmgr *format.Manager) ([]byte, error) {
+	masterKey := fmgr.FormatEncryptionKey()
+	if fmgr.SupportsPasswordChange() {
+		masterKey = fmgr.GetHmacSecret()
+	}
+
+	secret := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, masterKey, fmgr.UniqueID(), []byte("local-cache-integrity")), secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// VerifyCache checks the HMAC of every item in the local kopia cache and, if evict is set, removes the corrupted ones.
+func VerifyCache(cacheDirectory string, hmacSecret []byte, evict bool) (*CacheVerifyResult, error) {
+	result := &CacheVerifyResult{}
+
+	for _, subDir := range cacheSubDirs {
+		err := filepath.WalkDir(filepath.Join(cacheDirectory, subDir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".f") {
+				return nil
+			}
+
+			result.Checked++
+
+			valid, err := verifyCacheItem(path, hmacSecret)
+			if err != nil {
+				return err
+			}
+			if valid {
+				return nil
+			}
+
+			result.Corrupted = append(result.Corrupted, path)
+			if evict {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+				result.Evicted++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func verifyCacheItem(path string, hmacSecret []byte) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if len(data) < sha256.Size {
+		return false, nil
+	}
+
+	payload, signature := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+
+	h := hmac.New(sha256.New, hmacSecret)
+	h.Write(payload)
+
+	return hmac.Equal(h.Sum(nil), signature), nil
+}
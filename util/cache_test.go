@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCacheItem(t *testing.T, path string, payload []byte, secret []byte) {
+	h := hmac.New(sha256.New, secret)
+	h.Write(payload)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.FailNow()
+	}
+	if err := os.WriteFile(path, append(payload, h.Sum(nil)...), 0644); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestVerifyCache(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+
+	type args struct {
+		evict bool
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantChecked int
+		wantCorrupt int
+		wantEvicted int
+		wantExists  bool
+	}{
+		{
+			name:        "Report corrupted cache items without evicting",
+			args:        args{evict: false},
+			wantChecked: 3,
+			wantCorrupt: 1,
+			wantEvicted: 0,
+			wantExists:  true,
+		},
+		{
+			name:        "Evict corrupted cache items",
+			args:        args{evict: true},
+			wantChecked: 3,
+			wantCorrupt: 1,
+			wantEvicted: 1,
+			wantExists:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheDir := t.TempDir()
+			writeCacheItem(t, filepath.Join(cacheDir, "contents", "ab", "abcdef.f"), []byte("content"), secret)
+			writeCacheItem(t, filepath.Join(cacheDir, "metadata", "cd", "cdef01.f"), []byte("metadata"), secret)
+			corrupted := filepath.Join(cacheDir, "index-blobs", "ef", "ef0123.f")
+			writeCacheItem(t, corrupted, []byte("index"), []byte("another secret"))
+
+			got, err := VerifyCache(cacheDir, secret, tt.args.evict)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equalf(t, tt.wantChecked, got.Checked, "VerifyCache(%v)", tt.args.evict)
+			assert.Equalf(t, []string{corrupted}[:tt.wantCorrupt], got.Corrupted, "VerifyCache(%v)", tt.args.evict)
+			assert.Equalf(t, tt.wantEvicted, got.Evicted, "VerifyCache(%v)", tt.args.evict)
+			_, statErr := os.Stat(corrupted)
+			assert.Equalf(t, tt.wantExists, statErr == nil, "VerifyCache(%v)", tt.args.evict)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+	"sort"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+	// ChangeMoved is a file removed from one path and added at another with the same content
+	ChangeMoved ChangeKind = "moved"
+)
+
+// Change is a changed file with a slash separated path, From is the previous path of a moved file
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	Path string     `json:"path"`
+	From string     `json:"from,omitempty"`
+}
+
+// DiffFiles compares two sets of files given as their object ids by path.
+// A removed and an added file with the same object id are reported as a move, so that a reorganization
+// of the assets doesn't show up as deleted and re-added content, which it isn't as the storage deduplicates it.
+// The changes are sorted by path.
+func DiffFiles(before map[string]string, after map[string]string) []Change {
+	var changes []Change
+	removedByObject := map[string][]string{}
+	var added []string
+
+	for filePath, objectId := range before {
+		if _, ok := after[filePath]; !ok {
+			removedByObject[objectId] = append(removedByObject[objectId], filePath)
+		}
+	}
+	for filePath, objectId := range after {
+		previous, ok := before[filePath]
+		switch {
+		case !ok:
+			added = append(added, filePath)
+		case previous != objectId:
+			changes = append(changes, Change{Kind: ChangeModified, Path: filePath})
+		}
+	}
+
+	// Copies of the same content are paired in path order so that the result is stable
+	for _, paths := range removedByObject {
+		sort.Strings(paths)
+	}
+	sort.Strings(added)
+	for _, filePath := range added {
+		removed := removedByObject[after[filePath]]
+		if len(removed) == 0 {
+			changes = append(changes, Change{Kind: ChangeAdded, Path: filePath})
+			continue
+		}
+		changes = append(changes, Change{Kind: ChangeMoved, Path: filePath, From: removed[0]})
+		removedByObject[after[filePath]] = removed[1:]
+	}
+	for _, paths := range removedByObject {
+		for _, filePath := range paths {
+			changes = append(changes, Change{Kind: ChangeRemoved, Path: filePath})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// SnapshotFiles returns the object ids of the files in a snapshot directory by their slash separated path
+// relative to it, which DiffFiles compares
+func SnapshotFiles(ctx context.Context, dir fs.Directory) (map[string]string, error) {
+	files := map[string]string{}
+	err := WalkSnapshotFiles(ctx, dir, "", func(filePath string, entry fs.Entry) error {
+		if withObjectId, ok := entry.(object.HasObjectID); ok {
+			files[filePath] = withObjectId.ObjectID().String()
+		}
+		return nil
+	})
+	return files, err
+}
+
+// WalkSnapshotFiles calls fn for every file in a snapshot directory with its slash separated path prefixed by dirPath
+func WalkSnapshotFiles(ctx context.Context, dir fs.Directory, dirPath string, fn func(filePath string, entry fs.Entry) error) error {
+	return fs.IterateEntries(ctx, dir, func(ctx context.Context, entry fs.Entry) error {
+		entryPath := dirPath + entry.Name()
+		if childDir, ok := entry.(fs.Directory); ok {
+			return WalkSnapshotFiles(ctx, childDir, entryPath+"/", fn)
+		}
+		return fn(entryPath, entry)
+	})
+}
+
+// SizedChange is a change with the sizes of the file before and after it, which are zero where it doesn't exist
+type SizedChange struct {
+	Change
+	SizeBefore int64 `json:"sizeBefore"`
+	SizeAfter  int64 `json:"sizeAfter"`
+	SizeDelta  int64 `json:"sizeDelta"`
+}
+
+// AddSizes returns the changes with the sizes of their files, a moved file has the size of its previous path before
+func AddSizes(changes []Change, before map[string]FileState, after map[string]FileState) []SizedChange {
+	sized := make([]SizedChange, 0, len(changes))
+	for _, change := range changes {
+		beforePath := change.Path
+		if change.Kind == ChangeMoved {
+			beforePath = change.From
+		}
+		sizedChange := SizedChange{Change: change}
+		if change.Kind != ChangeAdded {
+			sizedChange.SizeBefore = before[beforePath].Size
+		}
+		if change.Kind != ChangeRemoved {
+			sizedChange.SizeAfter = after[change.Path].Size
+		}
+		sizedChange.SizeDelta = sizedChange.SizeAfter - sizedChange.SizeBefore
+		sized = append(sized, sizedChange)
+	}
+	return sized
+}
+
+// SnapshotFileStates returns the object ids and the states of the files in a snapshot directory by their slash
+// separated path prefixed by dirPath
+func SnapshotFileStates(ctx context.Context, dir fs.Directory, dirPath string) (map[string]string, map[string]FileState, error) {
+	ids := map[string]string{}
+	states := map[string]FileState{}
+	err := WalkSnapshotFiles(ctx, dir, dirPath, func(filePath string, entry fs.Entry) error {
+		file, ok := entry.(fs.File)
+		if !ok {
+			return nil
+		}
+		states[filePath] = FileState{Size: entry.Size(), ModTime: entry.ModTime()}
+		if withObjectId, ok := file.(object.HasObjectID); ok {
+			ids[filePath] = withObjectId.ObjectID().String()
+		}
+		return nil
+	})
+	return ids, states, err
+}
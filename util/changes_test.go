@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]string
+		after  map[string]string
+		want   []Change
+	}{
+		{
+			name:   "Added, removed and modified",
+			before: map[string]string{"a.png": "1", "b.png": "2"},
+			after:  map[string]string{"b.png": "3", "c.png": "4"},
+			want: []Change{
+				{Kind: ChangeRemoved, Path: "a.png"},
+				{Kind: ChangeModified, Path: "b.png"},
+				{Kind: ChangeAdded, Path: "c.png"},
+			},
+		},
+		{
+			name:   "Moved to another directory",
+			before: map[string]string{"old/a.png": "1", "old/b.png": "2"},
+			after:  map[string]string{"new/a.png": "1", "new/b.png": "2"},
+			want: []Change{
+				{Kind: ChangeMoved, Path: "new/a.png", From: "old/a.png"},
+				{Kind: ChangeMoved, Path: "new/b.png", From: "old/b.png"},
+			},
+		},
+		{
+			name:   "Copy of an unchanged file",
+			before: map[string]string{"a.png": "1"},
+			after:  map[string]string{"a.png": "1", "b.png": "1"},
+			want:   []Change{{Kind: ChangeAdded, Path: "b.png"}},
+		},
+		{
+			name:   "Identical files moved in path order",
+			before: map[string]string{"x/1.png": "1", "x/2.png": "1"},
+			after:  map[string]string{"y/2.png": "1", "y/1.png": "1", "y/3.png": "1"},
+			want: []Change{
+				{Kind: ChangeMoved, Path: "y/1.png", From: "x/1.png"},
+				{Kind: ChangeMoved, Path: "y/2.png", From: "x/2.png"},
+				{Kind: ChangeAdded, Path: "y/3.png"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DiffFiles(tt.before, tt.after))
+		})
+	}
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+
+	if _, err := SetupFakeRepository(ctx, op, t.TempDir()); !assert.NoError(t, err) {
+		return
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "textures"), 0o755); err != nil {
+		t.FailNow()
+	}
+	for _, name := range []string{"a.png", "textures/b.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("same"), 0o644); err != nil {
+			t.FailNow()
+		}
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rep.Close(ctx)
+
+	var root fs.Directory
+	err = repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		entry, err := localfs.NewEntry(dir)
+		if err != nil {
+			return err
+		}
+		sourceInfo := op.SourceInfo(rep.ClientOptions(), "./assets")
+		man, err := snapshotfs.NewUploader(w).Upload(ctx, entry, policy.BuildTree(nil, policy.DefaultPolicy), sourceInfo)
+		if err != nil {
+			return err
+		}
+		rootEntry, err := snapshotfs.SnapshotRoot(w, man)
+		if err != nil {
+			return err
+		}
+		root = rootEntry.(fs.Directory)
+		return nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	files, err := SnapshotFiles(ctx, root)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, files["a.png"], files["textures/b.png"])
+}
+
+func TestAddSizes(t *testing.T) {
+	before := map[string]FileState{"a.png": {Size: 10}, "b.png": {Size: 20}, "c.png": {Size: 30}}
+	after := map[string]FileState{"a.png": {Size: 15}, "d.png": {Size: 20}, "e.png": {Size: 5}}
+	changes := []Change{
+		{Kind: ChangeModified, Path: "a.png"},
+		{Kind: ChangeRemoved, Path: "c.png"},
+		{Kind: ChangeMoved, Path: "d.png", From: "b.png"},
+		{Kind: ChangeAdded, Path: "e.png"},
+	}
+
+	assert.Equal(t, []SizedChange{
+		{Change: changes[0], SizeBefore: 10, SizeAfter: 15, SizeDelta: 5},
+		{Change: changes[1], SizeBefore: 30, SizeDelta: -30},
+		{Change: changes[2], SizeBefore: 20, SizeAfter: 20},
+		{Change: changes[3], SizeAfter: 5, SizeDelta: 5},
+	}, AddSizes(changes, before, after))
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo/manifest"
+	"slices"
+	"time"
+)
+
+// changesetTimeLayout orders the changeset ids by the time they were created at
+const changesetTimeLayout = "20060102-150405"
+
+// Changeset groups the snapshots of the asset directories taken by one snap, e.g. an asset drop,
+// so that they can be referred to as one unit by its id or name. The snapshots are tagged with the id
+// and the changeset is recorded in the lock file next to the .gasset file.
+type Changeset struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name,omitempty"`
+	CreatedAt time.Time              `json:"createdAt"`
+	Snapshots map[string]manifest.ID `json:"snapshots"`
+}
+
+// NewChangeset returns an empty changeset with a new id
+func (op *Options) NewChangeset(name string, now time.Time) *Changeset {
+	return &Changeset{
+		ID:        now.UTC().Format(changesetTimeLayout) + "-" + GenerateRandomString(6, op.RandIntn),
+		Name:      name,
+		CreatedAt: now.UTC(),
+		Snapshots: map[string]manifest.ID{},
+	}
+}
+
+// FindChangeset returns the changeset with the given id or, if there is none, the latest one with the given name
+func (l *LockFile) FindChangeset(idOrName string) (*Changeset, bool) {
+	var found *Changeset
+	for i := range l.Changesets {
+		changeset := &l.Changesets[i]
+		if changeset.ID == idOrName {
+			return changeset, true
+		}
+		if changeset.Name == idOrName && (found == nil || changeset.CreatedAt.After(found.CreatedAt)) {
+			found = changeset
+		}
+	}
+	return found, found != nil
+}
+
+// RecordChangeset adds the changeset to the lock file next to the .gasset file, or updates it if it was recorded
+// before the snapshot was resumed. Nothing is recorded unless a snapshot was saved in it.
+func (op *Options) RecordChangeset(changeset *Changeset) error {
+	if len(changeset.Snapshots) == 0 {
+		return nil
+	}
+	lock, err := LoadLockFile(op.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	lock.Changesets = slices.DeleteFunc(lock.Changesets, func(recorded Changeset) bool {
+		return recorded.ID == changeset.ID
+	})
+	lock.Changesets = append(lock.Changesets, *changeset)
+	return SaveLockFile(op.WorkingDirectory, lock, op.Config.Permissions)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFindChangeset(t *testing.T) {
+	monday := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	lock := &LockFile{Changesets: []Changeset{
+		{ID: "20240304-100000-aaaaaa", Name: "drop", CreatedAt: monday},
+		{ID: "20240305-100000-bbbbbb", Name: "drop", CreatedAt: monday.Add(24 * time.Hour)},
+		{ID: "20240306-100000-cccccc", Name: "fixes", CreatedAt: monday.Add(48 * time.Hour)},
+	}}
+
+	changeset, ok := lock.FindChangeset("drop")
+	if assert.True(t, ok) {
+		assert.Equal(t, "20240305-100000-bbbbbb", changeset.ID, "the latest changeset of the name")
+	}
+	changeset, ok = lock.FindChangeset("20240304-100000-aaaaaa")
+	if assert.True(t, ok) {
+		assert.Equal(t, "drop", changeset.Name)
+	}
+	_, ok = lock.FindChangeset("missing")
+	assert.False(t, ok)
+}
+
+func TestRecordChangeset(t *testing.T) {
+	op := &Options{
+		Config:           &Config{},
+		WorkingDirectory: t.TempDir(),
+		RandIntn:         func(n int) int { return 0 },
+	}
+	monday := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	changeset := op.NewChangeset("drop", monday)
+	assert.Equal(t, "20240304-100000-000000", changeset.ID)
+	assert.NoError(t, op.RecordChangeset(changeset))
+	lock, err := LoadLockFile(op.WorkingDirectory)
+	if assert.NoError(t, err) {
+		assert.Empty(t, lock.Changesets, "changesets without snapshots are not recorded")
+	}
+
+	changeset.Snapshots["./assets"] = "a"
+	assert.NoError(t, op.RecordChangeset(changeset))
+	// A resumed snapshot updates the changeset
+	changeset.Snapshots["./textures"] = "b"
+	assert.NoError(t, op.RecordChangeset(changeset))
+
+	lock, err = LoadLockFile(op.WorkingDirectory)
+	if assert.NoError(t, err) && assert.Len(t, lock.Changesets, 1) {
+		assert.Equal(t, *changeset, lock.Changesets[0])
+	}
+}
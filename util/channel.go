@@ -0,0 +1,126 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// ChannelManifestType labels the releases of the channels stored as manifests in the kopia repository.
+// Every publish adds a release, so the manifests of a channel form its history and the latest one is current.
+const ChannelManifestType = "gasset-channel"
+
+// channelPinPrefix prefixes the pins keeping the published snapshots from being deleted by the retention policy
+const channelPinPrefix = "channel-"
+
+var channelNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ChannelRelease is a curated drop of the asset directories published to a named channel, e.g. stable or beta,
+// which consumers restore instead of the latest snapshots
+type ChannelRelease struct {
+	ID      manifest.ID `json:"-"`
+	Channel string      `json:"channel"`
+	// Snapshots are the published snapshots by asset directory
+	Snapshots map[string]manifest.ID `json:"snapshots"`
+	User      string                 `json:"user"`
+	Host      string                 `json:"host"`
+	Time      time.Time              `json:"time"`
+}
+
+// CheckChannelName returns an error if the name can't be used as a channel, i.e. as a manifest label and a pin
+func CheckChannelName(name string) error {
+	if !channelNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid channel %q, use lowercase letters, digits, dots, dashes and underscores", name)
+	}
+	return nil
+}
+
+// ChannelPin returns the pin of the snapshots published to the channel
+func ChannelPin(channel string) string {
+	return channelPinPrefix + channel
+}
+
+// NewChannelRelease returns an empty release of the channel published by the current user or machine identity
+func (op *Options) NewChannelRelease(channel string) *ChannelRelease {
+	clientOptions := op.ClientOptions()
+	return &ChannelRelease{
+		Channel:   channel,
+		Snapshots: map[string]manifest.ID{},
+		User:      clientOptions.Username,
+		Host:      clientOptions.Hostname,
+		Time:      time.Now().UTC(),
+	}
+}
+
+func channelLabels(channel string) map[string]string {
+	labels := map[string]string{manifest.TypeLabelKey: ChannelManifestType}
+	if channel != "" {
+		labels["channel"] = channel
+	}
+	return labels
+}
+
+// WriteChannelRelease stores the release in the repository as part of the write session of the publish
+func WriteChannelRelease(ctx context.Context, writer repo.RepositoryWriter, release *ChannelRelease) error {
+	id, err := writer.PutManifest(ctx, channelLabels(release.Channel), release)
+	if err != nil {
+		return err
+	}
+	release.ID = id
+	return nil
+}
+
+// ListChannelReleases returns the releases of the channel, or of every channel if it is empty, oldest first
+func ListChannelReleases(ctx context.Context, rep repo.Repository, channel string) ([]*ChannelRelease, error) {
+	entries, err := rep.FindManifests(ctx, channelLabels(channel))
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*ChannelRelease, 0, len(entries))
+	for _, entry := range entries {
+		release := &ChannelRelease{}
+		if _, err := rep.GetManifest(ctx, entry.ID, release); err != nil {
+			return nil, err
+		}
+		release.ID = entry.ID
+		releases = append(releases, release)
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Time.Before(releases[j].Time)
+	})
+	return releases, nil
+}
+
+// LatestChannelRelease returns the current release of the channel
+func LatestChannelRelease(ctx context.Context, rep repo.Repository, channel string) (*ChannelRelease, error) {
+	releases, err := ListChannelReleases(ctx, rep, channel)
+	if err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("nothing was published to the %s channel", channel)
+	}
+	return releases[len(releases)-1], nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckChannelName(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{name: "Accept a plain name", channel: "stable", wantErr: assert.NoError},
+		{name: "Accept dots and dashes", channel: "beta-2.1", wantErr: assert.NoError},
+		{name: "Reject an empty name", channel: "", wantErr: assert.Error},
+		{name: "Reject uppercase letters", channel: "Stable", wantErr: assert.Error},
+		{name: "Reject a leading dash", channel: "-stable", wantErr: assert.Error},
+		{name: "Reject a slash", channel: "release/stable", wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.wantErr(t, CheckChannelName(tt.channel), "CheckChannelName(%v)", tt.channel)
+		})
+	}
+}
+
+func TestNewChannelRelease(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+
+	release := testOptions.OptionsWithGassetId.NewChannelRelease("stable")
+	assert.Equal(t, "stable", release.Channel)
+	assert.Equal(t, "user", release.User)
+	assert.Equal(t, "host-pc", release.Host)
+	assert.Empty(t, release.Snapshots)
+	assert.Equal(t, "channel-stable", ChannelPin(release.Channel))
+	assert.Equal(t, map[string]string{manifest.TypeLabelKey: ChannelManifestType, "channel": "stable"}, channelLabels(release.Channel))
+	assert.Equal(t, map[string]string{manifest.TypeLabelKey: ChannelManifestType}, channelLabels(""))
+}
@@ -0,0 +1,241 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EnvAllowChaos has to be set to 1 for the hidden --chaos flag to be accepted, so that it is never enabled by accident
+const EnvAllowChaos = "GASSET_ALLOW_CHAOS"
+
+// ErrChaos is the error injected into the storage operations in chaos mode
+var ErrChaos = errors.New("chaos: injected storage error")
+
+// ChaosOptions describe the failures injected into the storage, for developing and testing retry and resume
+type ChaosOptions struct {
+	// ErrorRate is the share of the storage operations failing with ErrChaos
+	ErrorRate float64
+	// PartialWriteRate is the share of the writes storing only a part of the blob before failing
+	PartialWriteRate float64
+	// Latency is the maximum delay added to every storage operation
+	Latency time.Duration
+	Seed    int64
+}
+
+// ParseChaosOptions parses a comma separated list like error=0.1,partial=0.05,latency=200ms,seed=42
+func ParseChaosOptions(spec string) (*ChaosOptions, error) {
+	opt := &ChaosOptions{Seed: time.Now().UnixNano()}
+	for _, setting := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return nil, fmt.Errorf("chaos setting %q is not name=value", setting)
+		}
+
+		var err error
+		switch name {
+		case "error":
+			opt.ErrorRate, err = parseRate(value)
+		case "partial":
+			opt.PartialWriteRate, err = parseRate(value)
+		case "latency":
+			opt.Latency, err = time.ParseDuration(value)
+		case "seed":
+			opt.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return nil, fmt.Errorf("unknown chaos setting %s, use error, partial, latency or seed", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chaos setting %s: %w", name, err)
+		}
+	}
+	return opt, nil
+}
+
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("%s is not between 0 and 1", value)
+	}
+	return rate, nil
+}
+
+var activeChaos atomic.Pointer[chaos]
+
+// EnableChaos injects the failures into every storage opened from now on, including the ones kopia opens from its config
+func EnableChaos(op *Options, opt *ChaosOptions) {
+	registerS3Storage()
+	activeChaos.Store(&chaos{ChaosOptions: *opt, random: rand.New(rand.NewSource(opt.Seed))})
+
+	s3New := op.S3New
+	op.S3New = func(ctx context.Context, opt *s3.Options, createIfNotExist bool) (blob.Storage, error) {
+		st, err := s3New(ctx, opt, createIfNotExist)
+		if err != nil {
+			return nil, err
+		}
+		return WrapChaos(st), nil
+	}
+}
+
+// DisableChaos stops injecting failures into the storages, including the ones wrapped already
+func DisableChaos() {
+	activeChaos.Store(nil)
+}
+
+// WrapChaos wraps the storage to inject the failures while chaos is enabled
+func WrapChaos(st blob.Storage) blob.Storage {
+	if activeChaos.Load() == nil {
+		return st
+	}
+	return &chaosStorage{Storage: st}
+}
+
+type chaos struct {
+	ChaosOptions
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// next returns the delay and whether the operation fails or, for writes, writes partially
+func (c *chaos) next() (time.Duration, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var delay time.Duration
+	if c.Latency > 0 {
+		delay = time.Duration(c.random.Int63n(int64(c.Latency)))
+	}
+	return delay, c.random.Float64() < c.ErrorRate, c.random.Float64() < c.PartialWriteRate
+}
+
+// chaosStorage injects the failures into the operations reaching the storage
+type chaosStorage struct {
+	blob.Storage
+}
+
+// inject waits for the latency and returns ErrChaos for a failing operation, partial is true for a partial write
+func (s *chaosStorage) inject(ctx context.Context) (bool, error) {
+	c := activeChaos.Load()
+	if c == nil {
+		return false, nil
+	}
+
+	delay, fail, partial := c.next()
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if fail {
+		return false, ErrChaos
+	}
+	return partial, nil
+}
+
+func (s *chaosStorage) GetBlob(ctx context.Context, blobID blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	if _, err := s.inject(ctx); err != nil {
+		return err
+	}
+	return s.Storage.GetBlob(ctx, blobID, offset, length, output)
+}
+
+func (s *chaosStorage) GetMetadata(ctx context.Context, blobID blob.ID) (blob.Metadata, error) {
+	if _, err := s.inject(ctx); err != nil {
+		return blob.Metadata{}, err
+	}
+	return s.Storage.GetMetadata(ctx, blobID)
+}
+
+func (s *chaosStorage) ListBlobs(ctx context.Context, blobIDPrefix blob.ID, cb func(bm blob.Metadata) error) error {
+	if _, err := s.inject(ctx); err != nil {
+		return err
+	}
+	return s.Storage.ListBlobs(ctx, blobIDPrefix, cb)
+}
+
+// PutBlob of a partial write stores the first half of the blob and fails like an interrupted upload
+func (s *chaosStorage) PutBlob(ctx context.Context, blobID blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	partial, err := s.inject(ctx)
+	if err != nil {
+		return err
+	}
+	if !partial {
+		return s.Storage.PutBlob(ctx, blobID, data, opts)
+	}
+
+	var buf bytes.Buffer
+	if _, err := data.WriteTo(&buf); err != nil {
+		return err
+	}
+	if err := s.Storage.PutBlob(ctx, blobID, chaosBytes(buf.Bytes()[:buf.Len()/2]), opts); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: partial write of %s", ErrChaos, blobID)
+}
+
+func (s *chaosStorage) DeleteBlob(ctx context.Context, blobID blob.ID) error {
+	if _, err := s.inject(ctx); err != nil {
+		return err
+	}
+	return s.Storage.DeleteBlob(ctx, blobID)
+}
+
+func (s *chaosStorage) DisplayName() string {
+	return "Chaos: " + s.Storage.DisplayName()
+}
+
+// chaosBytes is the blob.Bytes of the part of a blob written by a partial write
+type chaosBytes []byte
+
+func (b chaosBytes) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (b chaosBytes) Length() int {
+	return len(b)
+}
+
+func (b chaosBytes) Reader() io.ReadSeekCloser {
+	return readSeekNopCloser{bytes.NewReader(b)}
+}
+
+type readSeekNopCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekNopCloser) Close() error {
+	return nil
+}
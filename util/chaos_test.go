@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseChaosOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    *ChaosOptions
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Parse all the settings",
+			spec:    "error=0.1, partial=0.05,latency=200ms,seed=42",
+			want:    &ChaosOptions{ErrorRate: 0.1, PartialWriteRate: 0.05, Latency: 200 * time.Millisecond, Seed: 42},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Reject a rate above 1",
+			spec:    "error=1.5",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject an unknown setting",
+			spec:    "timeout=1s",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject a setting without a value",
+			spec:    "error",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChaosOptions(tt.spec)
+			if !tt.wantErr(t, err, "ParseChaosOptions(%v)", tt.spec) || err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestChaosStorage(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStorage(t.TempDir())
+	op := &Options{}
+	defer DisableChaos()
+
+	EnableChaos(op, &ChaosOptions{ErrorRate: 1, Seed: 1})
+	chaosSt := WrapChaos(st)
+	assert.ErrorIs(t, chaosSt.PutBlob(ctx, "a", chaosBytes("blob"), blob.PutOptions{}), ErrChaos)
+	assert.ErrorIs(t, chaosSt.ListBlobs(ctx, "", func(blob.Metadata) error { return nil }), ErrChaos)
+	assert.Equal(t, 0, st.BlobCount())
+
+	EnableChaos(op, &ChaosOptions{PartialWriteRate: 1, Seed: 1})
+	assert.ErrorIs(t, chaosSt.PutBlob(ctx, "a", chaosBytes("blob"), blob.PutOptions{}), ErrChaos)
+	metadata, err := st.GetMetadata(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), metadata.Length)
+
+	// Storages wrapped while chaos was enabled pass the operations through once it is disabled
+	DisableChaos()
+	assert.NoError(t, chaosSt.PutBlob(ctx, "a", chaosBytes("blob"), blob.PutOptions{}))
+	data := &outputBuffer{}
+	assert.NoError(t, chaosSt.GetBlob(ctx, "a", 0, -1, data))
+	assert.Equal(t, "blob", data.String())
+	assert.Same(t, st, WrapChaos(st))
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/snapshot"
+	"strconv"
+	"time"
+)
+
+// ClockSkewThreshold is the difference between the local clock and the one of the storage from which gasset warns
+const ClockSkewThreshold = 5 * time.Minute
+
+// TimeLayout is the layout of the times shown to the user. It includes the zone so that teams spread
+// across time zones read the same time.
+const TimeLayout = "2006-01-02 15:04:05 MST"
+
+// clockProbeBlobPrefix is the prefix of the blob written to read the clock of the storage, kopia ignores blobs with
+// other prefixes
+const clockProbeBlobPrefix = "gasset-clock-"
+
+// SnapshotAfter orders snapshots by their start time, then by their end time and only if both are equal by their id,
+// so that the previous snapshot is chosen the same way every time
+func SnapshotAfter(a *snapshot.Manifest, b *snapshot.Manifest) bool {
+	if a.StartTime != b.StartTime {
+		return a.StartTime > b.StartTime
+	}
+	if a.EndTime != b.EndTime {
+		return a.EndTime > b.EndTime
+	}
+	return a.ID > b.ID
+}
+
+// FormatTime formats a time for display in UTC or, with --local-time, in the local time zone
+func FormatTime(t time.Time, local bool) string {
+	if local {
+		return t.Local().Format(TimeLayout)
+	}
+	return t.UTC().Format(TimeLayout)
+}
+
+// IsFromFuture tells whether a snapshot started after now by more than ClockSkewThreshold,
+// which means that the clock of the machine that took it or of this one is wrong
+func IsFromFuture(man *snapshot.Manifest, now time.Time) bool {
+	return man.StartTime.ToTime().Sub(now) > ClockSkewThreshold
+}
+
+// StorageClockSkew returns how far the local clock is ahead of the clock of the storage, negative if it is behind.
+// The storage timestamps the blobs with its own clock, so a single small blob is written through the writer and its
+// timestamp compared to the local clock around the write, then the blob is deleted again.
+func StorageClockSkew(ctx context.Context, writer repo.RepositoryWriter) (time.Duration, error) {
+	directWriter, ok := writer.(repo.DirectRepositoryWriter)
+	if !ok {
+		return 0, errors.New("the storage time is not available through a kopia API server")
+	}
+	st := directWriter.BlobStorage()
+
+	before := time.Now()
+	probeID := strconv.FormatInt(before.UnixNano(), 36)
+	probeBlob := blob.ID(clockProbeBlobPrefix + probeID)
+	var stamped time.Time
+	if err := st.PutBlob(ctx, probeBlob, probeBytes(probeID), blob.PutOptions{GetModTime: &stamped}); err != nil {
+		return 0, err
+	}
+	after := time.Now()
+	defer st.DeleteBlob(ctx, probeBlob)
+
+	// Not every storage returns the timestamp of a written blob
+	if stamped.IsZero() {
+		metadata, err := st.GetMetadata(ctx, probeBlob)
+		if err != nil {
+			return 0, err
+		}
+		stamped = metadata.Timestamp
+	}
+	return before.Add(after.Sub(before) / 2).Sub(stamped), nil
+}
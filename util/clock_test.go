@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAfter(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	at := func(id manifest.ID, offset time.Duration, duration time.Duration) *snapshot.Manifest {
+		return &snapshot.Manifest{
+			ID:        id,
+			StartTime: fs.UTCTimestamp(start.Add(offset).UnixNano()),
+			EndTime:   fs.UTCTimestamp(start.Add(offset + duration).UnixNano()),
+		}
+	}
+
+	tests := []struct {
+		name string
+		a    *snapshot.Manifest
+		b    *snapshot.Manifest
+		want bool
+	}{
+		{name: "Later start time", a: at("a", time.Minute, 0), b: at("b", 0, 0), want: true},
+		{name: "Earlier start time", a: at("b", 0, 0), b: at("a", time.Minute, 0), want: false},
+		{name: "Later start time within a second", a: at("a", 200*time.Millisecond, 0), b: at("b", 0, 0), want: true},
+		{name: "Same start time with a later end time", a: at("a", 0, time.Second), b: at("b", 0, 0), want: true},
+		{name: "Same start time with an earlier end time", a: at("b", 0, 0), b: at("a", 0, time.Second), want: false},
+		{name: "Same times ordered by id", a: at("b", 0, 0), b: at("a", 0, 0), want: true},
+		{name: "Same times with a smaller id", a: at("a", 0, 0), b: at("b", 0, 0), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SnapshotAfter(tt.a, tt.b))
+		})
+	}
+}
+
+func TestIsFromFuture(t *testing.T) {
+	now := time.Now()
+	assert.False(t, IsFromFuture(&snapshot.Manifest{StartTime: fs.UTCTimestamp(now.Add(time.Minute).UnixNano())}, now))
+	assert.True(t, IsFromFuture(&snapshot.Manifest{StartTime: fs.UTCTimestamp(now.Add(time.Hour).UnixNano())}, now))
+}
+
+func TestStorageClockSkew(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+
+	st, err := SetupFakeRepository(ctx, op, t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rep.Close(ctx)
+
+	// The storage stamps the blobs an hour before the local clock
+	st.mu.Lock()
+	st.clockOffset = -time.Hour
+	st.mu.Unlock()
+	blobCount := st.BlobCount()
+
+	err = repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		skew, err := StorageClockSkew(ctx, w)
+		if assert.NoError(t, err) {
+			assert.InDelta(t, time.Hour, skew, float64(time.Minute))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, blobCount, st.BlobCount(), "the probe blob is deleted again")
+}
+
+func TestFormatTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("CEST", 2*60*60)
+	defer func() { time.Local = local }()
+
+	started := time.Date(2024, 6, 1, 22, 30, 0, 0, time.FixedZone("PDT", -7*60*60))
+	assert.Equal(t, "2024-06-02 05:30:00 UTC", FormatTime(started, false))
+	assert.Equal(t, "2024-06-02 07:30:00 CEST", FormatTime(started, true))
+}
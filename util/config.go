@@ -19,32 +19,145 @@ package util
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/snapshot/policy"
+	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 )
 
 type Config struct {
-	Kopia    *repo.LocalConfig `json:"kopia,omitempty"`
-	GassetId string            `json:"gassetId,omitempty"`
-	Dirs     []string          `json:"dirs"`
+	Kopia                  *repo.LocalConfig   `json:"kopia,omitempty"`
+	KopiaConfig            string              `json:"kopiaConfig,omitempty"`
+	Storage                string              `json:"storage,omitempty"`
+	GassetId               string              `json:"gassetId,omitempty"`
+	Namespace              string              `json:"namespace,omitempty"`
+	Dirs                   []string            `json:"dirs"`
+	AllowNestedDirs        bool                `json:"allowNestedDirs,omitempty"`
+	RestoreHooks           []RestoreHook       `json:"restoreHooks,omitempty"`
+	AWS                    *AWSOptions         `json:"aws,omitempty"`
+	GitTracked             string              `json:"gitTracked,omitempty"`
+	DeferRetention         bool                `json:"deferRetention,omitempty"`
+	SkipIdenticalSnapshots *bool               `json:"skipIdenticalSnapshots,omitempty"`
+	IfChanged              bool                `json:"ifChanged,omitempty"`
+	Quota                  *QuotaOptions       `json:"quota,omitempty"`
+	Ignore                 []string            `json:"ignore,omitempty"`
+	Compression            string              `json:"compression,omitempty"`
+	Profiles               map[string][]string `json:"profiles,omitempty"`
+	WorkingHashes          *WorkingHashOptions `json:"workingHashes,omitempty"`
+	Derived                *DerivedOptions     `json:"derived,omitempty"`
+	LockFilePerDir         bool                `json:"lockFilePerDir,omitempty"`
+	Permissions            *PermissionOptions  `json:"permissions,omitempty"`
+	TrackRestores          bool                `json:"trackRestores,omitempty"`
+	Archival               bool                `json:"archival,omitempty"`
+	Renames                []DirRename         `json:"renames,omitempty"`
+	Metrics                []MetricAnalyzer    `json:"metrics,omitempty"`
+	Throttling             *ThrottlingOptions  `json:"throttling,omitempty"`
+	Thumbnails             *ThumbnailOptions   `json:"thumbnails,omitempty"`
+	Peers                  *PeerOptions        `json:"peers,omitempty"`
+	// AssumeRole and Encryption are read from and written to the s3 config of the kopia block
+	AssumeRole *AssumeRoleOptions `json:"-"`
+	Encryption *EncryptionOptions `json:"-"`
 }
 
+// ErrArchival is returned when deleting snapshots of an archival repository without overriding it
+var ErrArchival = errors.New("the repository is archival and keeps every snapshot, pass --override-archival to delete snapshots anyway")
+
+// CheckDeletion fails for an archival repository, where every asset version must be kept, unless it is overridden
+func (c *Config) CheckDeletion(override bool) error {
+	if !c.Archival {
+		return nil
+	}
+	if !override {
+		return ErrArchival
+	}
+	log.Printf("Warning: deleting snapshots of the archival repository %s", c.GassetId)
+	return nil
+}
+
+// CheckDirs fails if an asset directory is listed twice or contains another one, whose files would then be
+// uploaded with both snapshots and restored twice. The nesting is allowed with allowNestedDirs, e.g. when the
+// outer directory ignores the inner one.
+func (c *Config) CheckDirs() error {
+	for i, dir := range c.Dirs {
+		for _, other := range c.Dirs[i+1:] {
+			if sameDir(dir, other) {
+				return fmt.Errorf("the asset directory %s is listed twice in the .gasset file", other)
+			}
+			if c.AllowNestedDirs {
+				continue
+			}
+			outer, inner := dir, other
+			if !containsDir(outer, inner) {
+				outer, inner = other, dir
+				if !containsDir(outer, inner) {
+					continue
+				}
+			}
+			return fmt.Errorf("the asset directory %s contains the asset directory %s, whose files would be snapshotted twice, set allowNestedDirs in the .gasset file if it is intended", outer, inner)
+		}
+	}
+	return nil
+}
+
+// containsDir tells whether the asset directory inner is inside the asset directory outer
+func containsDir(outer string, inner string) bool {
+	rel, err := filepath.Rel(filepath.Clean(outer), filepath.Clean(inner))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// SnapshotPolicy adds the ignore rules and the compression of the config to the policy override base.
+// It returns nil if there is nothing to override.
+func (c *Config) SnapshotPolicy(base *policy.Policy) *policy.Policy {
+	if len(c.Ignore) == 0 && c.Compression == "" && !c.LockFilePerDir {
+		return base
+	}
+
+	override := &policy.Policy{}
+	if base != nil {
+		copied := *base
+		override = &copied
+	}
+	override.FilesPolicy.IgnoreRules = append(slices.Clip(override.FilesPolicy.IgnoreRules), c.Ignore...)
+	if c.LockFilePerDir {
+		// The lock file of the directory is committed to git and describes the snapshots, it is not part of them
+		override.FilesPolicy.IgnoreRules = append(override.FilesPolicy.IgnoreRules, "/"+LockFileName)
+	}
+	if c.Compression != "" {
+		override.CompressionPolicy.CompressorName = compression.Name(c.Compression)
+	}
+	return override
+}
+
+// CompressionNames returns the compressions which can be configured, "none" disabling it
+func CompressionNames() []string {
+	names := []string{"none"}
+	for name := range compression.ByName {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetConfig reads the .gasset file of the git repository at path, in JSON or YAML
 func GetConfig(path string) (*Config, error) {
-	configBytes, err := os.ReadFile(filepath.Join(path, ".gasset"))
+	configPath, err := FindConfigFile(path)
 	if err != nil {
 		return nil, err
 	}
-
-	config := Config{}
-
-	err = json.Unmarshal(configBytes, &config)
+	configBytes, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	return decodeConfig(configBytes, DetectConfigFormat(configPath, configBytes))
 }
 
 func UpdateGassetId(path string, gassetId string) error {
@@ -54,16 +167,59 @@ func UpdateGassetId(path string, gassetId string) error {
 	}
 
 	config.GassetId = gassetId
-	return UpdateConfig(filepath.Join(path, ".gasset"), config)
+	return SaveConfig(path, config)
+}
+
+func UpdateNamespace(path string, namespace string) error {
+	config, err := GetConfig(path)
+	if err != nil {
+		return err
+	}
+
+	config.Namespace = namespace
+	return SaveConfig(path, config)
+}
+
+// RemoveDir removes an asset directory from the .gasset file
+func RemoveDir(path string, dir string) error {
+	config, err := GetConfig(path)
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(config.Dirs, func(configured string) bool {
+		return filepath.Clean(configured) == filepath.Clean(dir)
+	})
+	if index < 0 {
+		return fmt.Errorf("%s is not an asset directory of the .gasset file", dir)
+	}
+
+	config.Dirs = slices.Delete(config.Dirs, index, index+1)
+	return SaveConfig(path, config)
 }
 
+// SaveConfig writes the config into the .gasset file of the git repository at path, whichever name it has
+func SaveConfig(path string, config *Config) error {
+	configPath, err := FindConfigFile(path)
+	if err != nil {
+		return err
+	}
+	return UpdateConfig(configPath, config)
+}
+
+// UpdateConfig writes the config into the file at path in its format, keeping the order of its keys
 func UpdateConfig(path string, config *Config) error {
-	configBytes, err := json.MarshalIndent(config, "", "  ")
+	original, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	configBytes, err := encodeConfig(config, original, DetectConfigFormat(path, original))
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, configBytes, 644)
+	return config.Permissions.WriteFile(path, configBytes)
 }
 
 func WriteTempKopiaConfig(path string, config *Config) error {
@@ -72,16 +228,38 @@ func WriteTempKopiaConfig(path string, config *Config) error {
 		return err
 	}
 
-	return os.WriteFile(path, kopiaConfigBytes, 0644)
+	// The temp dir may be shared with other users
+	return os.WriteFile(path, kopiaConfigBytes, 0o600)
+}
+
+// KopiaSecrets are the credentials of the storages and the password of the repository, which are never stored in the .gasset file
+type KopiaSecrets struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	B2KeyID         string
+	B2Key           string
+	Password        string
 }
 
-func LoadKopiaSecretsFromEnv(path string) (string, string, string, error) {
+// LoadKopiaSecretsFromEnv loads the .env file at path into the environment and returns the secrets set in the environment
+func LoadKopiaSecretsFromEnv(path string) (KopiaSecrets, error) {
 	err := godotenv.Load(filepath.Join(path, ".env"))
 	if err != nil {
-		return "", "", "", err
+		return KopiaSecrets{}, err
 	}
 
-	return os.Getenv("KOPIA_ACCESS_ID"), os.Getenv("KOPIA_ACCESS_SECRET"), os.Getenv("KOPIA_PASSWORD"), nil
+	return KopiaSecretsFromEnviron(), nil
+}
+
+// KopiaSecretsFromEnviron returns the secrets set in the environment
+func KopiaSecretsFromEnviron() KopiaSecrets {
+	return KopiaSecrets{
+		AccessKeyID:     os.Getenv(EnvAccessId),
+		SecretAccessKey: os.Getenv(EnvAccessSecret),
+		B2KeyID:         os.Getenv(EnvB2KeyId),
+		B2Key:           os.Getenv(EnvB2Key),
+		Password:        os.Getenv(EnvPassword),
+	}
 }
 
 func GetGitWorkingDirectory(path string) (string, error) {
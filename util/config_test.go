@@ -18,9 +18,11 @@ package util
 
 import (
 	"fmt"
+	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -96,7 +98,15 @@ func (suite *ConfigSuite) TestUpdateGassetId() {
 	}
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
-			path := HandleAbsolutePath(suite.op.TestWorkingDirectory, tt.args.path)
+			// The .gasset file is updated in a copy so that the shared mocks are left alone
+			config, err := GetConfig(HandleAbsolutePath(suite.op.TestWorkingDirectory, tt.args.path))
+			if err != nil {
+				suite.T().FailNow()
+			}
+			path := suite.T().TempDir()
+			if err := UpdateConfig(filepath.Join(path, ".gasset"), config); err != nil {
+				suite.T().FailNow()
+			}
 			tt.wantErr(suite.T(), UpdateGassetId(path, tt.args.gassetId), fmt.Sprintf("UpdateGassetId(%v, %v)", path, tt.args.gassetId))
 		})
 	}
@@ -165,38 +175,36 @@ func (suite *ConfigSuite) TestLoadKopiaSecretsFromEnv() {
 	tests := []struct {
 		name    string
 		args    args
-		want    string
-		want1   string
-		want2   string
+		want    KopiaSecrets
 		wantErr assert.ErrorAssertionFunc
 	}{
 		{
-			name:    "Attempt from .env file",
-			args:    args{path: "../mocks"},
-			want:    "accessid",
-			want1:   "secret",
-			want2:   "password",
+			name: "Attempt from .env file",
+			args: args{path: "../mocks"},
+			want: KopiaSecrets{
+				AccessKeyID:     "accessid",
+				SecretAccessKey: "secret",
+				B2KeyID:         "b2keyid",
+				B2Key:           "b2key",
+				Password:        "password",
+			},
 			wantErr: assert.NoError,
 		},
 		{
 			name:    "Attempt from a location without a .env file",
 			args:    args{path: "../mocks/deep"},
-			want:    "",
-			want1:   "",
-			want2:   "",
+			want:    KopiaSecrets{},
 			wantErr: assert.Error,
 		},
 	}
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
 			path := HandleAbsolutePath(suite.op.TestWorkingDirectory, tt.args.path)
-			got, got1, got2, err := LoadKopiaSecretsFromEnv(path)
+			got, err := LoadKopiaSecretsFromEnv(path)
 			if !tt.wantErr(suite.T(), err, fmt.Sprintf("LoadKopiaSecretsFromEnv(%v)", path)) {
 				return
 			}
 			assert.Equalf(suite.T(), tt.want, got, "LoadKopiaSecretsFromEnv(%v)", path)
-			assert.Equalf(suite.T(), tt.want1, got1, "LoadKopiaSecretsFromEnv(%v)", path)
-			assert.Equalf(suite.T(), tt.want2, got2, "LoadKopiaSecretsFromEnv(%v)", path)
 		})
 	}
 }
@@ -248,3 +256,94 @@ func (suite *ConfigSuite) TestGetGitWorkingDirectory() {
 		})
 	}
 }
+
+func (suite *ConfigSuite) TestSnapshotPolicy() {
+	gitTracked := &policy.Policy{FilesPolicy: policy.FilesPolicy{IgnoreRules: []string{"/readme.txt"}}}
+
+	assert.Nil(suite.T(), (&Config{}).SnapshotPolicy(nil))
+	assert.Same(suite.T(), gitTracked, (&Config{}).SnapshotPolicy(gitTracked))
+
+	config := &Config{Ignore: []string{"*.meta"}, Compression: "zstd"}
+	override := config.SnapshotPolicy(gitTracked)
+	assert.Equal(suite.T(), []string{"/readme.txt", "*.meta"}, override.FilesPolicy.IgnoreRules)
+	assert.Equal(suite.T(), "zstd", string(override.CompressionPolicy.CompressorName))
+	assert.Equal(suite.T(), []string{"/readme.txt"}, gitTracked.FilesPolicy.IgnoreRules)
+
+	override = (&Config{LockFilePerDir: true}).SnapshotPolicy(nil)
+	assert.Equal(suite.T(), []string{"/" + LockFileName}, override.FilesPolicy.IgnoreRules)
+}
+
+func (suite *ConfigSuite) TestCheckDeletion() {
+	config := &Config{GassetId: "0000000000"}
+	assert.NoError(suite.T(), config.CheckDeletion(false))
+
+	config.Archival = true
+	assert.ErrorIs(suite.T(), config.CheckDeletion(false), ErrArchival)
+	assert.NoError(suite.T(), config.CheckDeletion(true))
+}
+
+func (suite *ConfigSuite) TestCheckDirs() {
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Accept sibling directories sharing a prefix",
+			config:  &Config{Dirs: []string{"./assets", "./assets-raw", "textures"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Reject a directory listed twice",
+			config:  &Config{Dirs: []string{"./assets", "assets/"}, AllowNestedDirs: true},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject a directory inside another",
+			config:  &Config{Dirs: []string{"./assets/textures", "./assets"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject a directory inside the root of the repository",
+			config:  &Config{Dirs: []string{".", "./assets"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Allow the nesting explicitly",
+			config:  &Config{Dirs: []string{"./assets", "./assets/textures"}, AllowNestedDirs: true},
+			wantErr: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			tt.wantErr(suite.T(), tt.config.CheckDirs(), "CheckDirs() of %v", tt.config.Dirs)
+		})
+	}
+}
+
+func (suite *ConfigSuite) TestRenameDir() {
+	config := &Config{
+		Dirs:         []string{"./assets", "./caches"},
+		Derived:      &DerivedOptions{Dirs: []string{"./caches"}},
+		RestoreHooks: []RestoreHook{{Dir: "./caches", Command: []string{"make"}}},
+		Profiles:     map[string][]string{"lighting": {"caches/lightmaps/", "assets/*.hdr"}},
+	}
+
+	assert.Error(suite.T(), config.RenameDir("./caches", "./assets"))
+	assert.Error(suite.T(), config.RenameDir("./sounds", "./music"))
+	assert.Error(suite.T(), (&Config{Dirs: []string{"./assets", "./caches"}}).RenameDir("./caches", "./assets/caches"), "RenameDir() into another asset directory")
+
+	assert.NoError(suite.T(), config.RenameDir("caches", "./bakes"))
+	assert.NoError(suite.T(), config.RenameDir("./bakes", "./build/bakes"))
+	assert.Equal(suite.T(), []string{"./assets", "./build/bakes"}, config.Dirs)
+	assert.Equal(suite.T(), []string{"./build/bakes"}, config.Derived.Dirs)
+	assert.Equal(suite.T(), "./build/bakes", config.RestoreHooks[0].Dir)
+	assert.Equal(suite.T(), []string{"build/bakes/lightmaps/", "assets/*.hdr"}, config.Profiles["lighting"])
+	assert.Equal(suite.T(), []string{"./bakes", "./caches"}, config.PreviousDirs("build/bakes"))
+	assert.Empty(suite.T(), config.PreviousDirs("./assets"))
+
+	hashes := &LockHashes{Files: map[string]string{"build/bakes/a.bin": "1", "assets/b.png": "2"}, Sizes: map[string]int64{"build/bakes/a.bin": 10}}
+	hashes.RenameDir("./build/bakes", "./caches")
+	assert.Equal(suite.T(), map[string]string{"caches/a.bin": "1", "assets/b.png": "2"}, hashes.Files)
+	assert.Equal(suite.T(), map[string]int64{"caches/a.bin": 10}, hashes.Sizes)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ConfigBackupManifestType labels the copies of the .gasset file and the lock files stored in the kopia repository
+const ConfigBackupManifestType = "gasset-config-backup"
+
+// ConfigBackup is a copy of the .gasset file and the lock files taken by snap, which recover-config writes back
+// when the git repository is lost. The files are keyed by their slash separated path in the git repository.
+type ConfigBackup struct {
+	ID       manifest.ID       `json:"-"`
+	GassetId string            `json:"gassetId"`
+	User     string            `json:"user"`
+	Host     string            `json:"host"`
+	Time     time.Time         `json:"time"`
+	Files    map[string]string `json:"files"`
+}
+
+// ReadConfigBackup returns a backup of the .gasset file and the lock files of the working directory.
+// The lock files which don't exist are left out.
+func (op *Options) ReadConfigBackup(now time.Time) (*ConfigBackup, error) {
+	clientOptions := op.ClientOptions()
+	backup := &ConfigBackup{
+		GassetId: op.Config.GassetId,
+		User:     clientOptions.Username,
+		Host:     clientOptions.Hostname,
+		Time:     now.UTC(),
+		Files:    map[string]string{},
+	}
+
+	configPath, err := FindConfigFile(op.WorkingDirectory)
+	if err != nil {
+		return nil, err
+	}
+	configName := filepath.Base(configPath)
+	names := []string{configName, LockFileName}
+	for _, dirPath := range op.Config.Dirs {
+		names = append(names, path.Join(filepath.ToSlash(dirPath), LockFileName))
+	}
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(op.WorkingDirectory, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) && name != configName {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		backup.Files[name] = string(content)
+	}
+	return backup, nil
+}
+
+func (b *ConfigBackup) labels() map[string]string {
+	return map[string]string{
+		manifest.TypeLabelKey: ConfigBackupManifestType,
+		"gassetId":            b.GassetId,
+	}
+}
+
+// WriteConfigBackup stores the backup unless the latest one of its gasset id has the same files already.
+// It returns whether the backup was stored.
+func WriteConfigBackup(ctx context.Context, writer repo.RepositoryWriter, backup *ConfigBackup) (bool, error) {
+	backups, err := ListConfigBackups(ctx, writer, backup.GassetId)
+	if err != nil {
+		return false, err
+	}
+	if len(backups) > 0 && maps.Equal(backups[len(backups)-1].Files, backup.Files) {
+		return false, nil
+	}
+
+	id, err := writer.PutManifest(ctx, backup.labels(), backup)
+	if err != nil {
+		return false, err
+	}
+	backup.ID = id
+	return true, nil
+}
+
+// ListConfigBackups returns the backups of the gasset id, or of every gasset id if it is empty, oldest first
+func ListConfigBackups(ctx context.Context, rep repo.Repository, gassetId string) ([]*ConfigBackup, error) {
+	labels := map[string]string{manifest.TypeLabelKey: ConfigBackupManifestType}
+	if gassetId != "" {
+		labels["gassetId"] = gassetId
+	}
+	entries, err := rep.FindManifests(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]*ConfigBackup, 0, len(entries))
+	for _, entry := range entries {
+		backup := &ConfigBackup{}
+		if _, err := rep.GetManifest(ctx, entry.ID, backup); err != nil {
+			return nil, err
+		}
+		backup.ID = entry.ID
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.Before(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// RecoverConfigBackup writes the files of the backup into the root of the git repository and returns their names.
+// Existing files are only overwritten with overwrite, otherwise nothing is written.
+func RecoverConfigBackup(root string, backup *ConfigBackup, overwrite bool, permissions *PermissionOptions) ([]string, error) {
+	names := make([]string, 0, len(backup.Files))
+	for name := range backup.Files {
+		// The backup comes from the storage, it must not write outside the git repository
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("the backup holds the file %s outside the git repository", name)
+		}
+		if !overwrite {
+			if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(name))); err == nil {
+				return nil, fmt.Errorf("%s %w", name, fs.ErrExist)
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		filePath := filepath.Join(root, filepath.FromSlash(name))
+		if err := permissions.MkdirAll(filepath.Dir(filePath)); err != nil {
+			return nil, err
+		}
+		if err := permissions.WriteFile(filePath, []byte(backup.Files[name])); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadConfigBackup(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+	op := testOptions.OptionsWithGassetId.Clone()
+	op.WorkingDirectory = t.TempDir()
+	op.Config.Dirs = []string{"./assets", "./sounds"}
+
+	files := map[string]string{
+		".gasset":             `{"gassetId": "0000000000"}`,
+		"assets/.gasset.lock": `{"archives": []}`,
+	}
+	for name, content := range files {
+		filePath := filepath.Join(op.WorkingDirectory, filepath.FromSlash(name))
+		if os.MkdirAll(filepath.Dir(filePath), 0o755) != nil || os.WriteFile(filePath, []byte(content), 0o644) != nil {
+			t.FailNow()
+		}
+	}
+
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	backup, err := op.ReadConfigBackup(now)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &ConfigBackup{GassetId: "0000000000", User: "user", Host: "host-pc", Time: now, Files: files}, backup)
+
+	op.WorkingDirectory = t.TempDir()
+	_, err = op.ReadConfigBackup(now)
+	assert.ErrorIs(t, err, fs.ErrNotExist, "a backup without a .gasset file")
+}
+
+func TestRecoverConfigBackup(t *testing.T) {
+	root := t.TempDir()
+	backup := &ConfigBackup{Files: map[string]string{
+		".gasset":             `{"gassetId": "0000000000"}`,
+		"assets/.gasset.lock": `{"archives": []}`,
+	}}
+
+	names, err := RecoverConfigBackup(root, backup, false, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{".gasset", "assets/.gasset.lock"}, names)
+	content, err := os.ReadFile(filepath.Join(root, "assets", LockFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"archives": []}`, string(content))
+
+	_, err = RecoverConfigBackup(root, backup, false, nil)
+	assert.ErrorIs(t, err, fs.ErrExist, "existing files are kept without overwrite")
+	_, err = RecoverConfigBackup(root, backup, true, nil)
+	assert.NoError(t, err)
+
+	_, err = RecoverConfigBackup(root, &ConfigBackup{Files: map[string]string{"../.bashrc": ""}}, true, nil)
+	assert.Error(t, err, "a file outside the git repository")
+}
@@ -0,0 +1,282 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileNames are the names the .gasset file can have in the root of the git repository
+var ConfigFileNames = []string{".gasset", ".gasset.json", ".gasset.yaml"}
+
+type ConfigFormat string
+
+const (
+	ConfigFormatJSON ConfigFormat = "json"
+	ConfigFormatYAML ConfigFormat = "yaml"
+)
+
+// FindConfigFile returns the path of the .gasset file of the git repository at path, whichever of the
+// ConfigFileNames it has. Without any, it returns the path of a .gasset file for it to be created.
+func FindConfigFile(path string) (string, error) {
+	var found []string
+	for _, name := range ConfigFileNames {
+		if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+			found = append(found, name)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	switch len(found) {
+	case 0:
+		return filepath.Join(path, ConfigFileNames[0]), nil
+	case 1:
+		return filepath.Join(path, found[0]), nil
+	default:
+		return "", fmt.Errorf("the git repository has both %s, keep only one of them", strings.Join(found, " and "))
+	}
+}
+
+// DetectConfigFormat returns the format of the .gasset file by its extension or, for a .gasset file without
+// one, by its content as a JSON config is always an object
+func DetectConfigFormat(path string, data []byte) ConfigFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ConfigFormatJSON
+	case ".yaml", ".yml":
+		return ConfigFormatYAML
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] == '{' {
+		return ConfigFormatJSON
+	}
+	return ConfigFormatYAML
+}
+
+// yamlToJSON converts a YAML document to JSON so that it is decoded like a JSON config, e.g. the kopia block
+func yamlToJSON(data []byte) ([]byte, error) {
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
+
+func decodeConfig(data []byte, format ConfigFormat) (*Config, error) {
+	if format == ConfigFormatYAML {
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	config := Config{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	// kopia does not know the keys of the role to assume and of the encryption, which are in its s3 config
+	var err error
+	if config.AssumeRole, err = decodeAssumeRole(data); err != nil {
+		return nil, err
+	}
+	if config.Encryption, err = decodeEncryption(data); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// encodeConfig encodes the config in the format, keeping the order of the keys and the comments of the
+// original content of the file. The keys which are new follow the existing ones.
+func encodeConfig(config *Config, original []byte, format ConfigFormat) ([]byte, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(configBytes, document); err != nil {
+		return nil, err
+	}
+	// kopia does not know the keys of the role to assume and of the encryption, which are in its s3 config
+	if config.AssumeRole != nil {
+		if err := addStorageConfigKeys(document, config.AssumeRole); err != nil {
+			return nil, err
+		}
+	}
+	if config.Encryption != nil {
+		if err := addStorageConfigKeys(document, config.Encryption); err != nil {
+			return nil, err
+		}
+	}
+
+	if format == ConfigFormatYAML {
+		// The nodes parsed from JSON are in the flow style, i.e. JSON again
+		clearStyle(document)
+	}
+	originalDocument := &yaml.Node{}
+	if len(bytes.TrimSpace(original)) > 0 && yaml.Unmarshal(original, originalDocument) == nil {
+		orderLike(document, originalDocument)
+	}
+
+	if format == ConfigFormatYAML {
+		var buffer bytes.Buffer
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	}
+
+	var compact, indented bytes.Buffer
+	if err := writeJSONNode(&compact, document); err != nil {
+		return nil, err
+	}
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// orderLike reorders the keys of the mappings of node like the ones of original and takes over its comments and
+// styles. The items of sequences are matched by their value, or else by their index.
+func orderLike(node *yaml.Node, original *yaml.Node) {
+	if node.Kind != original.Kind {
+		return
+	}
+	node.HeadComment = original.HeadComment
+	node.LineComment = original.LineComment
+	node.FootComment = original.FootComment
+	if node.Kind != yaml.ScalarNode || node.ShortTag() == original.ShortTag() {
+		node.Style = original.Style
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 && len(original.Content) > 0 {
+			orderLike(node.Content[0], original.Content[0])
+		}
+	case yaml.SequenceNode:
+		matched := make([]bool, len(original.Content))
+		for i, item := range node.Content {
+			match := -1
+			for j, originalItem := range original.Content {
+				if !matched[j] && item.Kind == yaml.ScalarNode && originalItem.Kind == yaml.ScalarNode && item.Value == originalItem.Value {
+					match = j
+					break
+				}
+			}
+			if match < 0 && i < len(original.Content) && !matched[i] && item.Kind != yaml.ScalarNode {
+				match = i
+			}
+			if match >= 0 {
+				matched[match] = true
+				orderLike(item, original.Content[match])
+			}
+		}
+	case yaml.MappingNode:
+		ordered := make([]*yaml.Node, 0, len(node.Content))
+		taken := make([]bool, len(node.Content)/2)
+		for i := 0; i+1 < len(original.Content); i += 2 {
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if !taken[j/2] && node.Content[j].Value == original.Content[i].Value {
+					taken[j/2] = true
+					orderLike(node.Content[j], original.Content[i])
+					orderLike(node.Content[j+1], original.Content[i+1])
+					ordered = append(ordered, node.Content[j], node.Content[j+1])
+					break
+				}
+			}
+		}
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if !taken[j/2] {
+				ordered = append(ordered, node.Content[j], node.Content[j+1])
+			}
+		}
+		node.Content = ordered
+	}
+}
+
+// writeJSONNode writes a node parsed from JSON back as compact JSON
+func writeJSONNode(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buffer.WriteString("null")
+			return nil
+		}
+		return writeJSONNode(buffer, node.Content[0])
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buffer.Write(key)
+			buffer.WriteByte(':')
+			if err := writeJSONNode(buffer, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeJSONNode(buffer, item); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!str" {
+			buffer.WriteString(node.Value)
+			return nil
+		}
+		value, err := json.Marshal(node.Value)
+		if err != nil {
+			return err
+		}
+		buffer.Write(value)
+	default:
+		return fmt.Errorf("unexpected YAML node of kind %d in JSON", node.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindConfigFile(t *testing.T) {
+	path := t.TempDir()
+	got, err := FindConfigFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Join(path, ".gasset"), got, "FindConfigFile() without a .gasset file")
+	}
+
+	if err := os.WriteFile(filepath.Join(path, ".gasset.yaml"), []byte("dirs: []\n"), 0o644); err != nil {
+		t.FailNow()
+	}
+	got, err = FindConfigFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Join(path, ".gasset.yaml"), got)
+	}
+
+	if err := os.WriteFile(filepath.Join(path, ".gasset"), []byte("{}"), 0o644); err != nil {
+		t.FailNow()
+	}
+	_, err = FindConfigFile(path)
+	assert.Error(t, err, "FindConfigFile() with two .gasset files")
+}
+
+func TestDetectConfigFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+		want ConfigFormat
+	}{
+		{path: ".gasset.json", data: "dirs: []", want: ConfigFormatJSON},
+		{path: ".gasset.yaml", data: "{\"dirs\": []}", want: ConfigFormatYAML},
+		{path: ".gasset", data: "\n  {\"dirs\": []}", want: ConfigFormatJSON},
+		{path: ".gasset", data: "", want: ConfigFormatJSON},
+		{path: ".gasset", data: "# assets\ndirs: []\n", want: ConfigFormatYAML},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.want, DetectConfigFormat(tt.path, []byte(tt.data)), "DetectConfigFormat(%v, %v)", tt.path, tt.data)
+	}
+}
+
+func TestSaveConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want string
+	}{
+		{
+			name: "Keep the order of the keys of a JSON config",
+			file: ".gasset",
+			data: "{\n  \"dirs\": [\"./assets\"],\n  \"gassetId\": \"0000000000\"\n}",
+			want: "{\n  \"dirs\": [\n    \"./assets\",\n    \"./textures\"\n  ],\n  \"gassetId\": \"0000000000\",\n  \"namespace\": \"art\"\n}",
+		},
+		{
+			name: "Keep the comments of a YAML config",
+			file: ".gasset.yaml",
+			data: "# The assets of the game\ndirs:\n  - ./assets # models\ngassetId: \"0000000000\"\n",
+			want: "# The assets of the game\ndirs:\n  - ./assets # models\n  - ./textures\ngassetId: \"0000000000\"\nnamespace: art\n",
+		},
+		{
+			name: "Detect a YAML config without an extension",
+			file: ".gasset",
+			data: "gassetId: \"0000000000\"\ndirs: [./assets]\n",
+			want: "gassetId: \"0000000000\"\ndirs: [./assets, ./textures]\nnamespace: art\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := t.TempDir()
+			if err := os.WriteFile(filepath.Join(path, tt.file), []byte(tt.data), 0o644); err != nil {
+				t.FailNow()
+			}
+
+			config, err := GetConfig(path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, []string{"./assets"}, config.Dirs)
+			assert.Equal(t, "0000000000", config.GassetId)
+
+			config.Dirs = append(config.Dirs, "./textures")
+			config.Namespace = "art"
+			if !assert.NoError(t, SaveConfig(path, config)) {
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(path, tt.file))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := decodeConfig([]byte("dirs: [./assets]\nifChanged: true\nskipIdenticalSnapshots: false\n"), ConfigFormatYAML)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, config.IfChanged)
+	if assert.NotNil(t, config.SkipIdenticalSnapshots) {
+		assert.False(t, *config.SkipIdenticalSnapshots)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// EnvConfigHome overrides the directory holding the kopia configs, caches and local state of gasset
+const EnvConfigHome = "GASSET_CONFIG_HOME"
+
+// LocalConfigFileName is the untracked file in the root of the git repository with the settings of a single checkout
+const LocalConfigFileName = ".gasset.local"
+
+type LocalConfig struct {
+	// ConfigHome replaces the user config directory of the os, relative paths are resolved against the root of the git repository
+	ConfigHome string `json:"configHome,omitempty"`
+}
+
+// LoadLocalConfig returns the .gasset.local file of the working directory or an empty config if it has none
+func LoadLocalConfig(workingDirectory string) (*LocalConfig, error) {
+	content, err := os.ReadFile(filepath.Join(workingDirectory, LocalConfigFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &LocalConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := &LocalConfig{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", LocalConfigFileName, err)
+	}
+	return config, nil
+}
+
+// ApplyConfigHome replaces the user config directory with the one of GASSET_CONFIG_HOME or else the one of the
+// .gasset.local file so that every path derived from it, kopia configs and local state alike, moves together.
+func (op *Options) ApplyConfigHome() error {
+	home := os.Getenv(EnvConfigHome)
+	if home == "" {
+		localConfig, err := LoadLocalConfig(op.WorkingDirectory)
+		if err != nil {
+			return err
+		}
+		home = localConfig.ConfigHome
+		if home != "" && !filepath.IsAbs(home) {
+			home = filepath.Join(op.WorkingDirectory, home)
+		}
+	}
+	if home == "" {
+		return nil
+	}
+
+	home, err := filepath.Abs(home)
+	if err != nil {
+		return err
+	}
+	op.ConfigHome = home
+	op.OsUserConfigDir = func() (string, error) {
+		return home, nil
+	}
+	return nil
+}
+
+// DefaultCacheDirectory returns the cache directory of the gasset id inside the config home,
+// or an empty string to leave the default of kopia when no config home is set
+func (op *Options) DefaultCacheDirectory() string {
+	if op.ConfigHome == "" || op.Config.GassetId == "" {
+		return ""
+	}
+	return filepath.Join(op.ConfigHome, "git-gasset", "cache-"+op.Config.GassetId)
+}
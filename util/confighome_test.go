@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyConfigHome(t *testing.T) {
+	workingDirectory := t.TempDir()
+	envHome := t.TempDir()
+
+	tests := []struct {
+		name  string
+		env   string
+		local string
+		want  string
+	}{
+		{
+			name: "Keep the user config directory of the os",
+			want: "",
+		},
+		{
+			name:  "Resolve a relative config home of the .gasset.local file against the working directory",
+			local: `{"configHome": ".gasset-home"}`,
+			want:  filepath.Join(workingDirectory, ".gasset-home"),
+		},
+		{
+			name:  "Prefer the environment variable over the .gasset.local file",
+			env:   envHome,
+			local: `{"configHome": ".gasset-home"}`,
+			want:  envHome,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvConfigHome, tt.env)
+			localPath := filepath.Join(workingDirectory, LocalConfigFileName)
+			os.Remove(localPath)
+			if tt.local != "" {
+				if err := os.WriteFile(localPath, []byte(tt.local), 0600); err != nil {
+					t.FailNow()
+				}
+			}
+
+			op := &Options{
+				WorkingDirectory: workingDirectory,
+				Config:           &Config{GassetId: "abcd1234"},
+				OsUserConfigDir: func() (string, error) {
+					return "/os/config", nil
+				},
+			}
+			if !assert.NoError(t, op.ApplyConfigHome()) {
+				return
+			}
+			assert.Equal(t, tt.want, op.ConfigHome)
+
+			kopiaConfigPath, err := op.GetKopiaUserConfigPath()
+			assert.NoError(t, err)
+			if tt.want == "" {
+				assert.Equal(t, filepath.Join("/os/config", "git-gasset", "kopia-abcd1234.config"), kopiaConfigPath)
+				assert.Equal(t, "", op.DefaultCacheDirectory())
+			} else {
+				assert.Equal(t, filepath.Join(tt.want, "git-gasset", "kopia-abcd1234.config"), kopiaConfigPath)
+				assert.Equal(t, filepath.Join(tt.want, "git-gasset", "cache-abcd1234"), op.DefaultCacheDirectory())
+			}
+		})
+	}
+}
+
+func TestLoadLocalConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, LocalConfigFileName), []byte("{"), 0600); err != nil {
+		t.FailNow()
+	}
+	_, err := LoadLocalConfig(dir)
+	assert.ErrorContains(t, err, LocalConfigFileName)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// EnvAllowDestructive has to be set to 1 for --yes to skip the confirmation of destructive operations, e.g. in CI
+const EnvAllowDestructive = "GASSET_ALLOW_DESTRUCTIVE"
+
+// ConfirmDestructive asks for the gasset id to be typed before an operation deleting remote data.
+// With yes the prompt is skipped, but only if the environment opts in as well.
+func ConfirmDestructive(r io.Reader, w io.Writer, gassetId string, action string, yes bool) error {
+	if yes {
+		if os.Getenv(EnvAllowDestructive) != "1" {
+			return fmt.Errorf("--yes requires %s=1 to be set for operations which %s", EnvAllowDestructive, action)
+		}
+		return nil
+	}
+
+	fmt.Fprint(w, T("This will %s.\nType the gasset id %s to confirm: ", action, gassetId))
+
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if strings.TrimSpace(answer) != gassetId {
+		return errors.New(T("confirmation does not match the gasset id, aborting"))
+	}
+	return nil
+}
+
+// ConfirmLocal asks for a yes before an operation deleting local data which can be recreated, skipped with yes
+func ConfirmLocal(r io.Reader, w io.Writer, question string, yes bool) error {
+	if yes {
+		return nil
+	}
+
+	fmt.Fprintf(w, "%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return errors.New(T("not confirmed, aborting"))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirmDestructive(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		yes     bool
+		optIn   string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Confirm with the typed gasset id",
+			input:   "0000000000\n",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Abort with a wrong gasset id",
+			input:   "y\n",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Abort without any input",
+			input:   "",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Skip the prompt with --yes and the opt-in",
+			yes:     true,
+			optIn:   "1",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Reject --yes without the opt-in",
+			yes:     true,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvAllowDestructive, tt.optIn)
+			err := ConfirmDestructive(strings.NewReader(tt.input), io.Discard, "0000000000", "delete data", tt.yes)
+			tt.wantErr(t, err, fmt.Sprintf("ConfirmDestructive(%q, %v)", tt.input, tt.yes))
+		})
+	}
+}
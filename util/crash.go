@@ -0,0 +1,195 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EnvCrashReportURL is the URL crash dumps are submitted to, submitting them is opt-in
+const EnvCrashReportURL = "GASSET_CRASH_REPORT_URL"
+
+// scrubbedValue replaces the strings of the .gasset file in a crash dump
+const scrubbedValue = "[scrubbed]"
+
+// unscrubbedConfigKeys are the keys of the .gasset file whose string values are kept in a crash dump as they
+// name a feature, not the user, the project or a secret
+var unscrubbedConfigKeys = []string{"type", "compression", "gitTracked"}
+
+// maxCrashPanicLength is the length the message of a panic is truncated to in a crash dump
+const maxCrashPanicLength = 1024
+
+// CrashDump is the context of a panic of a command written to the crash dumps directory. It is anonymous:
+// the strings of the .gasset file are scrubbed, also from the panic and the stack together with the home
+// directory, the working directory, the user and the host, and only the names of the flags are kept.
+type CrashDump struct {
+	Build   BuildInfo `json:"build"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Flags   []string  `json:"flags,omitempty"`
+	Panic   string    `json:"panic"`
+	Stack   string    `json:"stack"`
+	Config  any       `json:"config,omitempty"`
+}
+
+// NewCrashDump returns the crash dump of the value recovered from a panic with the stack of the goroutine
+func NewCrashDump(command string, flags []string, recovered any, stack []byte, config *Config, now time.Time) *CrashDump {
+	private := privateValues(config)
+	panicText := scrubText(fmt.Sprint(recovered), private)
+	if len(panicText) > maxCrashPanicLength {
+		panicText = panicText[:maxCrashPanicLength] + "..."
+	}
+	return &CrashDump{
+		Build:   GetBuildInfo(),
+		Time:    now.UTC(),
+		Command: command,
+		Flags:   flags,
+		Panic:   panicText,
+		Stack:   scrubText(string(stack), private),
+		Config:  ScrubConfig(config),
+	}
+}
+
+// privateValues returns the strings scrubbed from the panic and the stack, the ones of the .gasset file and the
+// ones naming the user, longest first so that a value containing another one is scrubbed as a whole
+func privateValues(config *Config) []string {
+	var private []string
+	if home, err := os.UserHomeDir(); err == nil {
+		private = append(private, home)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		private = append(private, wd)
+	}
+	if current, err := user.Current(); err == nil {
+		private = append(private, current.Username)
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		private = append(private, hostname)
+	}
+	if config != nil {
+		if configBytes, err := json.Marshal(config); err == nil {
+			var document any
+			if err := json.Unmarshal(configBytes, &document); err == nil {
+				private = appendConfigStrings(private, document, "")
+			}
+		}
+	}
+
+	private = slices.DeleteFunc(private, func(value string) bool {
+		return value == "" || value == string(filepath.Separator)
+	})
+	slices.SortFunc(private, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	return slices.Compact(private)
+}
+
+func appendConfigStrings(private []string, value any, key string) []string {
+	switch typed := value.(type) {
+	case map[string]any:
+		for childKey, child := range typed {
+			private = appendConfigStrings(private, child, childKey)
+		}
+	case []any:
+		for _, child := range typed {
+			private = appendConfigStrings(private, child, key)
+		}
+	case string:
+		if !slices.Contains(unscrubbedConfigKeys, key) {
+			private = append(private, typed)
+		}
+	}
+	return private
+}
+
+func scrubText(text string, private []string) string {
+	for _, value := range private {
+		text = strings.ReplaceAll(text, value, scrubbedValue)
+	}
+	return text
+}
+
+// ScrubConfig returns the .gasset file with every string replaced, except the ones naming a feature, so that
+// a crash dump shows which features are configured without the buckets, paths or credentials
+func ScrubConfig(config *Config) any {
+	if config == nil {
+		return nil
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil
+	}
+	var document any
+	if err := json.Unmarshal(configBytes, &document); err != nil {
+		return nil
+	}
+	return scrub(document, "")
+}
+
+func scrub(value any, key string) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for childKey, child := range typed {
+			typed[childKey] = scrub(child, childKey)
+		}
+		return typed
+	case []any:
+		for i, child := range typed {
+			typed[i] = scrub(child, key)
+		}
+		return typed
+	case string:
+		if typed == "" || slices.Contains(unscrubbedConfigKeys, key) {
+			return typed
+		}
+		return scrubbedValue
+	}
+	return value
+}
+
+// GetCrashDir returns the directory the crash dumps of this user are written to
+func (op *Options) GetCrashDir() (string, error) {
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "crashes"), nil
+}
+
+// Write writes the crash dump into a file of its own in dir and returns its path
+func (d *CrashDump) Write(dir string) (string, error) {
+	dumpBytes, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// The stack and the panic may still hold paths of the user, the dumps are private
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "crash-"+d.Time.Format("20060102T150405Z")+".json")
+	return path, os.WriteFile(path, dumpBytes, 0o600)
+}
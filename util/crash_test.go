@@ -0,0 +1,124 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrubConfig(t *testing.T) {
+	op := OptionsForTest{}
+	if err := SetupTestOptions(&op); err != nil {
+		t.FailNow()
+	}
+	config := op.OptionsWithHiddenSecrets.Clone().Config
+	config.Compression = "zstd"
+
+	scrubbed, err := json.Marshal(ScrubConfig(config))
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, secret := range []string{"someaccesskey", "somesecret", "bucket-name", "./assets"} {
+		assert.NotContains(t, string(scrubbed), secret)
+	}
+	assert.Contains(t, string(scrubbed), `"gassetId":"[scrubbed]"`)
+	assert.Contains(t, string(scrubbed), `"type":"s3"`)
+	assert.Contains(t, string(scrubbed), `"compression":"zstd"`)
+	assert.Equal(t, "someaccesskey", config.Kopia.Storage.Config.(*s3.Options).AccessKeyID, "the config itself is not scrubbed")
+}
+
+func TestCrashDumpWrite(t *testing.T) {
+	dump := NewCrashDump("snap", []string{"force"}, errors.New("boom"), []byte("goroutine 1 [running]:"), nil, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	path, err := dump.Write(t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	info, err := os.Stat(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "crash-20260102T030405Z.json", info.Name())
+
+	content, err := os.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	written := CrashDump{}
+	if assert.NoError(t, json.Unmarshal(content, &written)) {
+		assert.Equal(t, "boom", written.Panic)
+		assert.Equal(t, []string{"force"}, written.Flags)
+	}
+}
+
+func TestNewCrashDump(t *testing.T) {
+	op := OptionsForTest{}
+	if err := SetupTestOptions(&op); err != nil {
+		t.FailNow()
+	}
+	config := op.OptionsWithHiddenSecrets.Clone().Config
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.FailNow()
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		name      string
+		recovered any
+		stack     string
+		wantPanic string
+		wantStack string
+	}{
+		{
+			name:      "Scrub the strings of the .gasset file from the panic",
+			recovered: errors.New("could not open bucket-name with someaccesskey"),
+			stack:     "goroutine 1 [running]:",
+			wantPanic: "could not open [scrubbed] with [scrubbed]",
+			wantStack: "goroutine 1 [running]:",
+		},
+		{
+			name:      "Scrub the home and working directories from the panic and the stack",
+			recovered: "open " + filepath.Join(home, ".config", "a.txt") + ": permission denied",
+			stack:     "main.main()\n\t" + filepath.Join(wd, "main.go") + ":12",
+			wantPanic: "open " + filepath.Join("[scrubbed]", ".config", "a.txt") + ": permission denied",
+			wantStack: "main.main()\n\t" + filepath.Join("[scrubbed]", "main.go") + ":12",
+		},
+		{
+			name:      "Truncate a long panic",
+			recovered: strings.Repeat("x", maxCrashPanicLength+1),
+			wantPanic: strings.Repeat("x", maxCrashPanicLength) + "...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dump := NewCrashDump("snap", nil, tt.recovered, []byte(tt.stack), config, time.Now())
+			assert.Equal(t, tt.wantPanic, dump.Panic)
+			assert.Equal(t, tt.wantStack, dump.Stack)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/kopia/kopia/repo/blob"
+	"io"
+	"slices"
+)
+
+// StoragePermission is an operation on the blobs of the storage which the credentials may not permit
+type StoragePermission string
+
+const (
+	PermissionList   StoragePermission = "list"
+	PermissionGet    StoragePermission = "get"
+	PermissionPut    StoragePermission = "put"
+	PermissionDelete StoragePermission = "delete"
+)
+
+// StoragePermissions are the probed permissions in the order they are printed
+var StoragePermissions = []StoragePermission{PermissionList, PermissionGet, PermissionPut, PermissionDelete}
+
+// probeBlobPrefix is the prefix of the blob written to probe the put permission, kopia ignores blobs with other prefixes
+const probeBlobPrefix = "gasset-doctor-"
+
+// OperationPermissions are the permissions the gasset operations need on the storage
+var OperationPermissions = map[string][]StoragePermission{
+	"restore": {PermissionList, PermissionGet},
+	"list":    {PermissionList, PermissionGet},
+	"snap":    {PermissionList, PermissionGet, PermissionPut},
+	"init":    {PermissionList, PermissionGet, PermissionPut},
+	// Deleting snapshots compacts the manifest blobs, which deletes the ones they replace
+	"prune":      {PermissionList, PermissionGet, PermissionPut, PermissionDelete},
+	"purge-file": {PermissionList, PermissionGet, PermissionPut, PermissionDelete},
+}
+
+// OperationNames returns the operations whose permissions can be checked, sorted
+func OperationNames() []string {
+	names := make([]string, 0, len(OperationPermissions))
+	for name := range OperationPermissions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ProbeStoragePermissions tries every operation on the storage and returns the error each one failed with,
+// nil if the credentials permit it. A blob named after probeID is written and deleted again.
+func ProbeStoragePermissions(ctx context.Context, st blob.Storage, probeID string) map[StoragePermission]error {
+	probed := map[StoragePermission]error{}
+	probeBlob := blob.ID(probeBlobPrefix + probeID)
+
+	probed[PermissionList] = st.ListBlobs(ctx, probeBlobPrefix, func(blob.Metadata) error {
+		return nil
+	})
+
+	// Every kopia repository has the format blob, a missing one still shows that reading is permitted
+	err := st.GetBlob(ctx, "kopia.repository", 0, -1, &blobBuffer{})
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		err = nil
+	}
+	probed[PermissionGet] = err
+
+	probed[PermissionPut] = st.PutBlob(ctx, probeBlob, probeBytes(probeID), blob.PutOptions{})
+
+	// Deleting a missing blob succeeds if it is permitted, so it is probed even if the put was denied
+	err = st.DeleteBlob(ctx, probeBlob)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		err = nil
+	}
+	probed[PermissionDelete] = err
+	return probed
+}
+
+// MissingPermissions returns the permissions the operation needs which the probe found denied
+func MissingPermissions(operation string, probed map[StoragePermission]error) []StoragePermission {
+	var missing []StoragePermission
+	for _, permission := range OperationPermissions[operation] {
+		if probed[permission] != nil {
+			missing = append(missing, permission)
+		}
+	}
+	return missing
+}
+
+// blobBuffer receives a blob read from a storage
+type blobBuffer struct {
+	bytes.Buffer
+}
+
+func (b *blobBuffer) Length() int {
+	return b.Len()
+}
+
+// probeBytes is the content of the blob written to probe the put permission
+type probeBytes []byte
+
+func (b probeBytes) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (b probeBytes) Length() int {
+	return len(b)
+}
+
+func (b probeBytes) Reader() io.ReadSeekCloser {
+	return readSeekNopCloser{bytes.NewReader(b)}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// deniedStorage fails the deletes like credentials without the permission
+type deniedStorage struct {
+	blob.Storage
+}
+
+func (s deniedStorage) DeleteBlob(context.Context, blob.ID) error {
+	return errors.New("access denied")
+}
+
+func TestProbeStoragePermissions(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStorage("probe")
+
+	probed := ProbeStoragePermissions(ctx, st, "0000")
+	for _, permission := range StoragePermissions {
+		assert.NoErrorf(t, probed[permission], "permission %s", permission)
+	}
+	assert.Zero(t, st.BlobCount(), "the probe blob is deleted")
+
+	probed = ProbeStoragePermissions(ctx, deniedStorage{st}, "0000")
+	assert.NoError(t, probed[PermissionPut])
+	assert.Error(t, probed[PermissionDelete])
+	assert.Empty(t, MissingPermissions("snap", probed))
+	assert.Equal(t, []StoragePermission{PermissionDelete}, MissingPermissions("prune", probed))
+}
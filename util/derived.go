@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/snapshot/policy"
+	"path/filepath"
+	"slices"
+)
+
+// DefaultDerivedKeepLatest is the number of snapshots kept of a derived directory unless configured otherwise
+const DefaultDerivedKeepLatest = 3
+
+// DerivedOptions marks asset directories holding generated assets, e.g. caches or bakes. They share the
+// repository without being part of the reproducible state, so they have their own short retention and
+// are left out of the lock file.
+type DerivedOptions struct {
+	// Dirs are asset directories of the .gasset file
+	Dirs       []string `json:"dirs"`
+	KeepLatest int      `json:"keepLatest,omitempty"`
+	KeepDaily  int      `json:"keepDaily,omitempty"`
+	InLockFile bool     `json:"inLockFile,omitempty"`
+}
+
+// IsDerived returns true if the asset directory is marked as derived
+func (c *Config) IsDerived(dir string) bool {
+	if c.Derived == nil {
+		return false
+	}
+	return slices.ContainsFunc(c.Derived.Dirs, func(derived string) bool {
+		return filepath.Clean(derived) == filepath.Clean(dir)
+	})
+}
+
+// InLockFile returns true if the hashes of an asset directory are recorded in the lock file
+func (c *Config) InLockFile(dir string) bool {
+	if c.WorkingHashes == nil || !c.WorkingHashes.InLockFile {
+		return false
+	}
+	return !c.IsDerived(dir) || c.Derived.InLockFile
+}
+
+// RetentionPolicy returns the retention of the derived directories, which replaces the inherited one
+func (o *DerivedOptions) RetentionPolicy() policy.RetentionPolicy {
+	keepLatest := o.KeepLatest
+	if keepLatest <= 0 {
+		keepLatest = DefaultDerivedKeepLatest
+	}
+	newOptionalInt := func(n int) *policy.OptionalInt {
+		optional := policy.OptionalInt(n)
+		return &optional
+	}
+	return policy.RetentionPolicy{
+		KeepLatest:  newOptionalInt(keepLatest),
+		KeepHourly:  newOptionalInt(0),
+		KeepDaily:   newOptionalInt(o.KeepDaily),
+		KeepWeekly:  newOptionalInt(0),
+		KeepMonthly: newOptionalInt(0),
+		KeepAnnual:  newOptionalInt(0),
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConfigIsDerived(t *testing.T) {
+	config := &Config{
+		Dirs:          []string{"./assets", "./bakes"},
+		WorkingHashes: &WorkingHashOptions{InLockFile: true},
+		Derived:       &DerivedOptions{Dirs: []string{"bakes/"}},
+	}
+
+	assert.False(t, config.IsDerived("./assets"))
+	assert.True(t, config.IsDerived("./bakes"))
+	assert.True(t, config.InLockFile("./assets"))
+	assert.False(t, config.InLockFile("./bakes"))
+
+	config.Derived.InLockFile = true
+	assert.True(t, config.InLockFile("./bakes"))
+
+	assert.False(t, (&Config{}).IsDerived("./bakes"))
+	assert.False(t, (&Config{}).InLockFile("./assets"))
+}
+
+func TestDerivedRetentionPolicy(t *testing.T) {
+	retention := (&DerivedOptions{KeepDaily: 7}).RetentionPolicy()
+	assert.Equal(t, policy.OptionalInt(DefaultDerivedKeepLatest), *retention.KeepLatest)
+	assert.Equal(t, policy.OptionalInt(7), *retention.KeepDaily)
+	assert.Equal(t, policy.OptionalInt(0), *retention.KeepAnnual)
+
+	retention = (&DerivedOptions{KeepLatest: 1}).RetentionPolicy()
+	assert.Equal(t, policy.OptionalInt(1), *retention.KeepLatest)
+}
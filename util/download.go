@@ -0,0 +1,347 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot/restore"
+	"io"
+	iofs "io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DownloadChunkSize is the number of bytes downloaded between two records of the progress of a file
+const DownloadChunkSize = 4 << 20
+
+// downloadSaveInterval limits how often the queue is written, a restart downloads at most this much again
+const downloadSaveInterval = time.Second
+
+// QueuedObject is a file waiting to be downloaded. Verified is the number of its bytes synced to the partial
+// file, from which an interrupted download continues.
+type QueuedObject struct {
+	ObjectID object.ID `json:"objectId"`
+	Size     int64     `json:"size"`
+	Verified int64     `json:"verified,omitempty"`
+}
+
+// DownloadQueue is the persisted state of the download of a snapshot, so that a restore interrupted by a flaky
+// connection or a restart continues with the remaining files and within a file at the last verified byte
+type DownloadQueue struct {
+	mu        sync.Mutex
+	Snapshot  string                  `json:"snapshot"`
+	Remaining map[string]QueuedObject `json:"remaining"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+}
+
+func (op *Options) GetDownloadQueuePath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "downloads-"+op.Config.GassetId+".json"), nil
+}
+
+// LoadDownloadQueue returns the queue of the download of the snapshot, an empty one if it was not started
+// or the queue is of another snapshot
+func (op *Options) LoadDownloadQueue(snapshotId string) (*DownloadQueue, error) {
+	queuePath, err := op.GetDownloadQueuePath()
+	if err != nil {
+		return nil, err
+	}
+
+	empty := &DownloadQueue{Snapshot: snapshotId}
+	queueBytes, err := os.ReadFile(queuePath)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &DownloadQueue{}
+	if err := json.Unmarshal(queueBytes, queue); err != nil {
+		return nil, err
+	}
+	if queue.Snapshot != snapshotId {
+		return empty, nil
+	}
+	return queue, nil
+}
+
+func (op *Options) SaveDownloadQueue(queue *DownloadQueue) error {
+	queuePath, err := op.GetDownloadQueuePath()
+	if err != nil {
+		return err
+	}
+
+	queue.mu.Lock()
+	queue.UpdatedAt = time.Now().UTC()
+	queueBytes, err := json.Marshal(queue)
+	queue.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(queuePath), 0o700); err != nil {
+		return err
+	}
+	// The queue is replaced atomically so that a crash while saving does not lose it
+	tempPath := queuePath + ".tmp"
+	if err := os.WriteFile(tempPath, queueBytes, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, queuePath)
+}
+
+func (op *Options) ClearDownloadQueue() error {
+	queuePath, err := op.GetDownloadQueuePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(queuePath); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Enqueue adds the files of the snapshot directory unless the download was started before, in which case
+// only the files remaining from it are downloaded
+func (q *DownloadQueue) Enqueue(ctx context.Context, dir fs.Directory) error {
+	q.mu.Lock()
+	started := q.Remaining != nil
+	q.mu.Unlock()
+	if started {
+		return nil
+	}
+
+	remaining := map[string]QueuedObject{}
+	err := WalkSnapshotFiles(ctx, dir, "", func(filePath string, entry fs.Entry) error {
+		file, ok := entry.(fs.File)
+		if !ok {
+			return nil
+		}
+		if id, ok := fileObjectID(file); ok {
+			remaining[filePath] = QueuedObject{ObjectID: id, Size: entry.Size()}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.Remaining = remaining
+	return nil
+}
+
+// Pending returns the verified bytes of a file if it is still to be downloaded. Files of other content
+// than the queued one start over.
+func (q *DownloadQueue) Pending(relativePath string, id object.ID) (int64, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.Remaining == nil {
+		return 0, true
+	}
+	queued, ok := q.Remaining[relativePath]
+	if !ok {
+		return 0, false
+	}
+	if queued.ObjectID != id {
+		return 0, true
+	}
+	return queued.Verified, true
+}
+
+func (q *DownloadQueue) progress(relativePath string, id object.ID, size int64, verified int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.Remaining == nil {
+		q.Remaining = map[string]QueuedObject{}
+	}
+	q.Remaining[relativePath] = QueuedObject{ObjectID: id, Size: size, Verified: verified}
+}
+
+func (q *DownloadQueue) done(relativePath string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.Remaining == nil {
+		q.Remaining = map[string]QueuedObject{}
+	}
+	delete(q.Remaining, relativePath)
+}
+
+// Left returns the number of files and bytes left to download
+func (q *DownloadQueue) Left() (int, int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var bytes int64
+	for _, queued := range q.Remaining {
+		bytes += queued.Size - queued.Verified
+	}
+	return len(q.Remaining), bytes
+}
+
+// QueuedOutput downloads the files into partial files next to their targets, recording the verified bytes in the
+// queue as it goes, and renames them into place once they are complete. Files which are done according to the
+// queue are skipped, so that a restarted restore continues where the interrupted one stopped.
+type QueuedOutput struct {
+	restore.Output
+
+	targetPath     string
+	overwriteFiles bool
+	queue          *DownloadQueue
+	save           func(queue *DownloadQueue) error
+
+	mu      sync.Mutex
+	savedAt time.Time
+}
+
+// NewQueuedOutput wraps an output restoring into the target of options, saving the queue with save
+func NewQueuedOutput(output restore.Output, options *restore.FilesystemOutput, queue *DownloadQueue, save func(queue *DownloadQueue) error) *QueuedOutput {
+	return &QueuedOutput{
+		Output:         output,
+		targetPath:     options.TargetPath,
+		overwriteFiles: options.OverwriteFiles,
+		queue:          queue,
+		save:           save,
+	}
+}
+
+// WriteFile implements restore.Output
+func (o *QueuedOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	id, ok := fileObjectID(f)
+	if !ok {
+		return o.Output.WriteFile(ctx, relativePath, f)
+	}
+
+	targetPath := filepath.Join(o.targetPath, filepath.FromSlash(relativePath))
+	verified, pending := o.queue.Pending(relativePath, id)
+	if !pending {
+		if stat, err := os.Stat(targetPath); err == nil && stat.Size() == f.Size() {
+			return nil
+		}
+		verified = 0
+	}
+	if _, err := os.Lstat(targetPath); err == nil && !o.overwriteFiles {
+		return fmt.Errorf("unable to create %q, it already exists", targetPath)
+	}
+
+	partialPath := filepath.Join(filepath.Dir(targetPath), ".gasset-partial-"+filepath.Base(targetPath))
+	if err := o.download(ctx, relativePath, id, f, partialPath, verified); err != nil {
+		// The partial file is kept for the next attempt
+		o.saveQueue(true)
+		return err
+	}
+
+	if err := os.Chmod(partialPath, f.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(partialPath, f.ModTime(), f.ModTime()); err != nil {
+		return err
+	}
+	if err := os.Rename(partialPath, targetPath); err != nil {
+		return err
+	}
+	o.queue.done(relativePath)
+	o.saveQueue(false)
+	return nil
+}
+
+// download continues the download of a file into the partial file from the verified bytes
+func (o *QueuedOutput) download(ctx context.Context, relativePath string, id object.ID, f fs.File, partialPath string, verified int64) error {
+	partial, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer partial.Close()
+
+	// Only the bytes which made it to the disk before the interruption are kept
+	stat, err := partial.Stat()
+	if err != nil {
+		return err
+	}
+	offset := min(verified, stat.Size())
+	if err := partial.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := partial.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader, err := f.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := io.CopyN(partial, reader, DownloadChunkSize)
+		offset += n
+		if syncErr := partial.Sync(); syncErr != nil {
+			return syncErr
+		}
+		o.queue.progress(relativePath, id, f.Size(), offset)
+		o.saveQueue(false)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// saveQueue saves the queue at most every downloadSaveInterval unless forced.
+// A queue which can't be saved only means that more is downloaded again after a restart.
+func (o *QueuedOutput) saveQueue(force bool) {
+	o.mu.Lock()
+	if !force && time.Since(o.savedAt) < downloadSaveInterval {
+		o.mu.Unlock()
+		return
+	}
+	o.savedAt = time.Now()
+	o.mu.Unlock()
+
+	if err := o.save(o.queue); err != nil {
+		log.Printf("Warning: could not save the download queue: %v", err)
+	}
+}
+
+// Close implements restore.Output
+func (o *QueuedOutput) Close(ctx context.Context) error {
+	o.saveQueue(true)
+	return o.Output.Close(ctx)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var errConnectionLost = errors.New("connection lost")
+
+// flakyFile is a file of a snapshot whose reader fails after the given number of bytes
+type flakyFile struct {
+	snapshotFile
+	failAfter int
+}
+
+func (f flakyFile) Open(ctx context.Context) (fs.Reader, error) {
+	reader, err := f.snapshotFile.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &flakyReader{Reader: reader, left: f.failAfter}, nil
+}
+
+type flakyReader struct {
+	fs.Reader
+	left int
+}
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, errConnectionLost
+	}
+	n, err := r.Reader.Read(p[:min(len(p), r.left)])
+	r.left -= n
+	return n, err
+}
+
+func TestDownloadQueue(t *testing.T) {
+	userDir := t.TempDir()
+	op := &Options{
+		Config: &Config{GassetId: "0000000000"},
+		OsUserConfigDir: func() (string, error) {
+			return userDir, nil
+		},
+	}
+
+	id, err := object.ParseID("k0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.FailNow()
+	}
+
+	queue, err := op.LoadDownloadQueue("snapshot")
+	if !assert.NoError(t, err) {
+		return
+	}
+	queue.Remaining = map[string]QueuedObject{"a.bin": {ObjectID: id, Size: 10, Verified: 4}}
+	if !assert.NoError(t, op.SaveDownloadQueue(queue)) {
+		return
+	}
+
+	loaded, err := op.LoadDownloadQueue("snapshot")
+	if assert.NoError(t, err) {
+		verified, pending := loaded.Pending("a.bin", id)
+		assert.True(t, pending)
+		assert.Equal(t, int64(4), verified)
+		_, pending = loaded.Pending("b.bin", id)
+		assert.False(t, pending, "files missing in a started queue are done")
+		files, bytes := loaded.Left()
+		assert.Equal(t, 1, files)
+		assert.Equal(t, int64(6), bytes)
+	}
+
+	other, err := op.LoadDownloadQueue("other")
+	if assert.NoError(t, err) {
+		assert.Nil(t, other.Remaining, "the queue of another snapshot is not continued")
+	}
+
+	assert.NoError(t, op.ClearDownloadQueue())
+	assert.NoError(t, op.ClearDownloadQueue())
+}
+
+func TestQueuedOutput(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	source := filepath.Join(root, "source.bin")
+	if err := os.WriteFile(source, []byte("0123456789"), 0o644); err != nil {
+		t.FailNow()
+	}
+	entry, err := localfs.NewEntry(source)
+	if !assert.NoError(t, err) {
+		return
+	}
+	id, err := object.ParseID("k0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.FailNow()
+	}
+	file := snapshotFile{File: entry.(fs.File), id: id}
+
+	fsOutput := &restore.FilesystemOutput{TargetPath: filepath.Join(root, "target")}
+	if err := os.MkdirAll(fsOutput.TargetPath, 0o755); err != nil {
+		t.FailNow()
+	}
+	var saved int
+	queue := &DownloadQueue{Snapshot: "snapshot"}
+	output := NewQueuedOutput(fsOutput, fsOutput, queue, func(*DownloadQueue) error {
+		saved++
+		return nil
+	})
+
+	err = output.WriteFile(ctx, "a.bin", flakyFile{snapshotFile: file, failAfter: 4})
+	assert.ErrorIs(t, err, errConnectionLost)
+	verified, pending := queue.Pending("a.bin", id)
+	assert.True(t, pending)
+	assert.Equal(t, int64(4), verified)
+	assert.NoFileExists(t, filepath.Join(fsOutput.TargetPath, "a.bin"))
+
+	// The download continues at the verified bytes, the reader would fail if it started over
+	assert.NoError(t, output.WriteFile(ctx, "a.bin", flakyFile{snapshotFile: file, failAfter: 7}))
+	restored, err := os.ReadFile(filepath.Join(fsOutput.TargetPath, "a.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(restored))
+	assert.NoFileExists(t, filepath.Join(fsOutput.TargetPath, ".gasset-partial-a.bin"))
+
+	_, pending = queue.Pending("a.bin", id)
+	assert.False(t, pending)
+	// A done file is not downloaded again
+	assert.NoError(t, output.WriteFile(ctx, "a.bin", flakyFile{snapshotFile: file, failAfter: 0}))
+
+	assert.NoError(t, output.Close(ctx))
+	assert.Positive(t, saved)
+}
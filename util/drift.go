@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"github.com/kopia/kopia/repo"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// driftIgnoredKeys are the fields of the kopia config which are local to the machine and are not compared
+var driftIgnoredKeys = []string{"caching", "accessKeyID", "secretAccessKey", "sessionToken"}
+
+type ConfigDifference struct {
+	Field     string
+	Committed any
+	Connected any
+}
+
+// ConfigDrift compares the kopia config in the .gasset file with the one the user is connected with
+func (op *Options) ConfigDrift() ([]ConfigDifference, error) {
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	connected, err := repo.LoadConfigFromFile(kopiaUserConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := *op.Config.Kopia
+	committed.ClientOptions = op.ClientOptions()
+
+	return CompareKopiaConfigs(&committed, connected)
+}
+
+// CompareKopiaConfigs returns the fields which differ between two kopia configs, ignoring secrets and caching
+func CompareKopiaConfigs(committed *repo.LocalConfig, connected *repo.LocalConfig) ([]ConfigDifference, error) {
+	committedFields, err := flattenConfig(committed)
+	if err != nil {
+		return nil, err
+	}
+
+	connectedFields, err := flattenConfig(connected)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field := range committedFields {
+		fields = append(fields, field)
+	}
+	for field := range connectedFields {
+		if _, ok := committedFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var differences []ConfigDifference
+	for _, field := range fields {
+		if !reflect.DeepEqual(committedFields[field], connectedFields[field]) {
+			differences = append(differences, ConfigDifference{
+				Field:     field,
+				Committed: committedFields[field],
+				Connected: connectedFields[field],
+			})
+		}
+	}
+	return differences, nil
+}
+
+func flattenConfig(config *repo.LocalConfig) (map[string]any, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]any
+	if err := json.Unmarshal(configBytes, &document); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]any{}
+	flattenInto(fields, "", document)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]any, prefix string, document map[string]any) {
+	for key, value := range document {
+		if slices.Contains(driftIgnoredKeys, key) {
+			continue
+		}
+
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+
+		if child, ok := value.(map[string]any); ok {
+			flattenInto(fields, field, child)
+			continue
+		}
+		fields[field] = value
+	}
+}
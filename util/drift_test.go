@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type DriftSuite struct {
+	suite.Suite
+	op OptionsForTest
+}
+
+func TestDriftSuite(t *testing.T) {
+	suite.Run(t, new(DriftSuite))
+}
+
+func (suite *DriftSuite) SetupSuite() {
+	err := SetupTestOptions(&suite.op)
+	if err != nil {
+		suite.T().FailNow()
+	}
+}
+
+func (suite *DriftSuite) TestCompareKopiaConfigs() {
+	tests := []struct {
+		name    string
+		connect func(connected *repo.LocalConfig)
+		want    []ConfigDifference
+	}{
+		{
+			name:    "Compare identical configs",
+			connect: func(connected *repo.LocalConfig) {},
+			want:    nil,
+		},
+		{
+			name: "Ignore the credentials and the caching",
+			connect: func(connected *repo.LocalConfig) {
+				connected.Storage.Config.(*s3.Options).AccessKeyID = "otheraccessid"
+				connected.Storage.Config.(*s3.Options).SecretAccessKey = "othersecret"
+				connected.Caching = &content.CachingOptions{CacheDirectory: "/tmp/cache"}
+			},
+			want: nil,
+		},
+		{
+			name: "Report a changed endpoint and hostname",
+			connect: func(connected *repo.LocalConfig) {
+				connected.Storage.Config.(*s3.Options).Endpoint = "old.digitaloceanspaces.com"
+				connected.Hostname = "other-pc"
+			},
+			want: []ConfigDifference{
+				{Field: "hostname", Committed: "host-pc", Connected: "other-pc"},
+				{Field: "storage.config.endpoint", Committed: "endpoint.digitaloceanspaces.com", Connected: "old.digitaloceanspaces.com"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			committed := suite.op.OptionsWithGassetId.Config.Kopia
+			connected := suite.op.OptionsWithGassetId.Clone().Config.Kopia
+			tt.connect(connected)
+
+			got, err := CompareKopiaConfigs(committed, connected)
+			if !assert.NoError(suite.T(), err) {
+				return
+			}
+			assert.Equalf(suite.T(), tt.want, got, "CompareKopiaConfigs(%v, %v)", committed, connected)
+		})
+	}
+}
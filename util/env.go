@@ -0,0 +1,120 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	EnvAccessId     = "KOPIA_ACCESS_ID"
+	EnvAccessSecret = "KOPIA_ACCESS_SECRET"
+	EnvPassword     = "KOPIA_PASSWORD"
+	EnvB2KeyId      = "B2_KEY_ID"
+	EnvB2Key        = "B2_KEY"
+)
+
+type EnvVar struct {
+	Name        string
+	Description string
+}
+
+// RequiredEnvVars returns the environment variables needed to connect to the storage configured in the .gasset file
+func RequiredEnvVars(config *Config) []EnvVar {
+	var envVars []EnvVar
+
+	// An existing kopia config has the credentials of the storage already
+	if config.KopiaConfig == "" {
+		if provider, err := GetStorageProvider(config.StorageType()); err == nil {
+			envVars = append(envVars, provider.EnvVars()...)
+		}
+	}
+
+	return append(envVars, EnvVar{Name: EnvPassword, Description: "Password of the kopia repository"})
+}
+
+// MissingEnvVars returns the variables that are neither set in the environment nor in the .env file at path
+func MissingEnvVars(path string, envVars []EnvVar) ([]EnvVar, error) {
+	dotEnv, err := godotenv.Read(filepath.Join(path, ".env"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var missing []EnvVar
+	for _, envVar := range envVars {
+		if value, ok := os.LookupEnv(envVar.Name); ok && value != "" {
+			continue
+		}
+		if dotEnv[envVar.Name] != "" {
+			continue
+		}
+		missing = append(missing, envVar)
+	}
+	return missing, nil
+}
+
+// checkNonInteractiveInputs fails on the storage or the secrets missing in the non-interactive mode, which can't prompt for them
+func checkNonInteractiveInputs(config *Config, secrets KopiaSecrets) error {
+	if config.Kopia == nil || (config.Kopia.Storage == nil && config.Kopia.APIServer == nil) {
+		return errors.New("the storage of the repository is unknown, give it with --storage or --bucket")
+	}
+
+	var missing []string
+	for _, envVar := range RequiredEnvVars(config) {
+		if secrets.lookup(envVar.Name) == "" {
+			missing = append(missing, envVar.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s not set, the non-interactive mode reads the secrets from the environment and the password from --password-stdin or the keyring", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// lookup returns the secret of the environment variable
+func (s KopiaSecrets) lookup(name string) string {
+	switch name {
+	case EnvAccessId:
+		return s.AccessKeyID
+	case EnvAccessSecret:
+		return s.SecretAccessKey
+	case EnvB2KeyId:
+		return s.B2KeyID
+	case EnvB2Key:
+		return s.B2Key
+	case EnvPassword:
+		return s.Password
+	default:
+		return ""
+	}
+}
+
+// EnvTemplate returns the content of a .env file with an empty entry for every variable
+func EnvTemplate(envVars []EnvVar) string {
+	var builder strings.Builder
+	for _, envVar := range envVars {
+		builder.WriteString("# " + envVar.Description + "\n")
+		builder.WriteString(envVar.Name + "=\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequiredEnvVars(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []string
+	}{
+		{
+			name:   "Require the S3 credentials and the password",
+			config: &Config{Kopia: &repo.LocalConfig{Storage: &blob.ConnectionInfo{Type: "s3"}}},
+			want:   []string{EnvAccessId, EnvAccessSecret, EnvPassword},
+		},
+		{
+			name:   "Require the S3 credentials of a storage URL",
+			config: &Config{Storage: "s3://bucket-name/prefix/"},
+			want:   []string{EnvAccessId, EnvAccessSecret, EnvPassword},
+		},
+		{
+			name:   "Require the B2 application key of a storage URL",
+			config: &Config{Storage: "b2://bucket-name/prefix/"},
+			want:   []string{EnvB2KeyId, EnvB2Key, EnvPassword},
+		},
+		{
+			name:   "Require only the password of a filesystem storage",
+			config: &Config{Storage: "file:///mnt/nas/assets"},
+			want:   []string{EnvPassword},
+		},
+		{
+			name:   "Require only the password without a storage",
+			config: &Config{},
+			want:   []string{EnvPassword},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, envVar := range RequiredEnvVars(tt.config) {
+				got = append(got, envVar.Name)
+			}
+			assert.Equalf(t, tt.want, got, "RequiredEnvVars(%v)", tt.config)
+		})
+	}
+}
+
+func TestMissingEnvVars(t *testing.T) {
+	envVars := []EnvVar{{Name: EnvAccessId}, {Name: EnvAccessSecret}, {Name: EnvPassword}}
+
+	tests := []struct {
+		name    string
+		dotEnv  string
+		environ map[string]string
+		want    []EnvVar
+	}{
+		{
+			name:   "Report every variable without a .env file",
+			dotEnv: "",
+			want:   envVars,
+		},
+		{
+			name:    "Accept variables from the .env file and the environment",
+			dotEnv:  "KOPIA_ACCESS_ID=id\nKOPIA_ACCESS_SECRET=\n",
+			environ: map[string]string{EnvPassword: "password"},
+			want:    []EnvVar{{Name: EnvAccessSecret}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, envVar := range envVars {
+				t.Setenv(envVar.Name, tt.environ[envVar.Name])
+			}
+
+			path := t.TempDir()
+			if tt.dotEnv != "" {
+				if err := os.WriteFile(filepath.Join(path, ".env"), []byte(tt.dotEnv), 0600); err != nil {
+					t.FailNow()
+				}
+			}
+
+			got, err := MissingEnvVars(path, envVars)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "MissingEnvVars(%v)", path)
+		})
+	}
+}
+
+func TestCheckNonInteractiveInputs(t *testing.T) {
+	s3Config := &Config{Kopia: &repo.LocalConfig{Storage: &blob.ConnectionInfo{Type: "s3"}}}
+	assert.NoError(t, checkNonInteractiveInputs(s3Config, KopiaSecrets{AccessKeyID: "id", SecretAccessKey: "secret", Password: "password"}))
+	assert.ErrorContains(t, checkNonInteractiveInputs(s3Config, KopiaSecrets{AccessKeyID: "id"}), EnvAccessSecret+", "+EnvPassword+" not set")
+
+	apiServerConfig := &Config{Kopia: &repo.LocalConfig{APIServer: &repo.APIServerInfo{BaseURL: "https://kopia:51515"}}}
+	assert.NoError(t, checkNonInteractiveInputs(apiServerConfig, KopiaSecrets{Password: "password"}), "checkNonInteractiveInputs() of an API server")
+
+	assert.ErrorContains(t, checkNonInteractiveInputs(&Config{Kopia: &repo.LocalConfig{}}, KopiaSecrets{Password: "password"}), "--bucket")
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a structured event of the --json mode, written as a JSON line for CI tooling to read
+type Event struct {
+	// Operation is what happened, e.g. "snapshot", "init", "log" or "result" for the outcome of the command
+	Operation  string         `json:"operation"`
+	Time       time.Time      `json:"time"`
+	Command    string         `json:"command,omitempty"`
+	Status     string         `json:"status,omitempty"`
+	GassetId   string         `json:"gassetId,omitempty"`
+	Dir        string         `json:"dir,omitempty"`
+	SnapshotId string         `json:"snapshotId,omitempty"`
+	Files      int64          `json:"files,omitempty"`
+	Bytes      int64          `json:"bytes,omitempty"`
+	Counts     map[string]int `json:"counts,omitempty"`
+	Failures   int            `json:"failures,omitempty"`
+	Warnings   int            `json:"warnings,omitempty"`
+	Duration   time.Duration  `json:"duration,omitempty"`
+	Level      string         `json:"level,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// EventWriter writes the events of the --json mode as JSON lines. A nil EventWriter is the text mode and writes nothing.
+type EventWriter struct {
+	// Now is replaced by the tests
+	Now func() time.Time
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{Now: time.Now, w: w}
+}
+
+// Emit writes the event, stamped with the current time unless it has one
+func (e *EventWriter) Emit(event Event) {
+	if e == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = e.Now()
+	}
+	event.Time = event.Time.UTC()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// An event that can't be written is lost like a log line would be
+	_ = json.NewEncoder(e.w).Encode(event)
+}
+
+// LogWriter returns a writer for the log output which writes every logged line as a log event.
+// The log flags should be 0 as the events carry their own time.
+func (e *EventWriter) LogWriter() io.Writer {
+	return &logEventWriter{events: e}
+}
+
+type logEventWriter struct {
+	events *EventWriter
+}
+
+func (l *logEventWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		message, level := string(line), "info"
+		if warning, ok := strings.CutPrefix(message, "Warning: "); ok {
+			message, level = warning, "warning"
+		}
+		l.events.Emit(Event{Operation: "log", Level: level, Message: message})
+	}
+	return len(p), nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestEventWriter(t *testing.T) {
+	var output bytes.Buffer
+	events := NewEventWriter(&output)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600))
+	events.Now = func() time.Time { return now }
+
+	events.Emit(Event{Operation: "snapshot", Dir: "./assets", SnapshotId: "k1", Bytes: 2048})
+	logger := log.New(events.LogWriter(), "", 0)
+	logger.Println("snap called")
+	logger.Println("Warning: nothing to snapshot")
+
+	decoder := json.NewDecoder(&output)
+	var got []Event
+	for decoder.More() {
+		var event Event
+		if !assert.NoError(t, decoder.Decode(&event)) {
+			return
+		}
+		got = append(got, event)
+	}
+	assert.Equal(t, []Event{
+		{Operation: "snapshot", Time: now.UTC(), Dir: "./assets", SnapshotId: "k1", Bytes: 2048},
+		{Operation: "log", Time: now.UTC(), Level: "info", Message: "snap called"},
+		{Operation: "log", Time: now.UTC(), Level: "warning", Message: "nothing to snapshot"},
+	}, got)
+
+	var nilEvents *EventWriter
+	nilEvents.Emit(Event{Operation: "snapshot"})
+}
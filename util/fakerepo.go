@@ -0,0 +1,233 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorageType is the kopia storage type of MemoryStorage.
+// It is registered so that repo.Open can find the storage a repository was connected to by its name.
+const MemoryStorageType = "gasset-memory"
+
+type MemoryStorageOptions struct {
+	Name string `json:"name"`
+}
+
+var memoryStorages sync.Map
+
+func init() {
+	blob.AddSupportedStorage(MemoryStorageType, MemoryStorageOptions{}, func(ctx context.Context, opt *MemoryStorageOptions, isCreate bool) (blob.Storage, error) {
+		st, ok := memoryStorages.Load(opt.Name)
+		if !ok {
+			return nil, fmt.Errorf("memory storage %s does not exist", opt.Name)
+		}
+		return wrapStorage(st.(*MemoryStorage)), nil
+	})
+}
+
+// MemoryStorage is a blob storage keeping the blobs in memory, for tests of commands without a network.
+// Closing it keeps the blobs so that the repository can be opened again.
+type MemoryStorage struct {
+	name string
+
+	mu    sync.Mutex
+	blobs map[blob.ID][]byte
+	times map[blob.ID]time.Time
+	// clockOffset is added to the time the blobs are stamped with, like the clock of a remote storage which is off
+	clockOffset time.Duration
+}
+
+// NewMemoryStorage returns an empty storage registered under the given name
+func NewMemoryStorage(name string) *MemoryStorage {
+	st := &MemoryStorage{
+		name:  name,
+		blobs: map[blob.ID][]byte{},
+		times: map[blob.ID]time.Time{},
+	}
+	memoryStorages.Store(name, st)
+	return st
+}
+
+func (s *MemoryStorage) GetCapacity(context.Context) (blob.Capacity, error) {
+	return blob.Capacity{}, blob.ErrNotAVolume
+}
+
+// GetBlob follows the range semantics of the kopia storage implementations
+func (s *MemoryStorage) GetBlob(_ context.Context, blobID blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	output.Reset()
+
+	data, ok := s.blobs[blobID]
+	if !ok {
+		return blob.ErrBlobNotFound
+	}
+	if length < 0 {
+		_, err := output.Write(data)
+		return err
+	}
+	if offset < 0 || offset > int64(len(data)) || offset+length > int64(len(data)) {
+		return blob.ErrInvalidRange
+	}
+	_, err := output.Write(data[offset : offset+length])
+	return err
+}
+
+func (s *MemoryStorage) GetMetadata(_ context.Context, blobID blob.ID) (blob.Metadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.blobs[blobID]
+	if !ok {
+		return blob.Metadata{}, blob.ErrBlobNotFound
+	}
+	return blob.Metadata{BlobID: blobID, Length: int64(len(data)), Timestamp: s.times[blobID]}, nil
+}
+
+func (s *MemoryStorage) ListBlobs(ctx context.Context, blobIDPrefix blob.ID, cb func(bm blob.Metadata) error) error {
+	s.mu.Lock()
+	var metadata []blob.Metadata
+	for blobID, data := range s.blobs {
+		if strings.HasPrefix(string(blobID), string(blobIDPrefix)) {
+			metadata = append(metadata, blob.Metadata{BlobID: blobID, Length: int64(len(data)), Timestamp: s.times[blobID]})
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(metadata, func(i, j int) bool {
+		return metadata[i].BlobID < metadata[j].BlobID
+	})
+	for _, bm := range metadata {
+		if err := cb(bm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) ConnectionInfo() blob.ConnectionInfo {
+	return blob.ConnectionInfo{Type: MemoryStorageType, Config: &MemoryStorageOptions{Name: s.name}}
+}
+
+func (s *MemoryStorage) DisplayName() string {
+	return "Memory: " + s.name
+}
+
+func (s *MemoryStorage) PutBlob(_ context.Context, blobID blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	if opts.HasRetentionOptions() {
+		return blob.ErrUnsupportedPutBlobOption
+	}
+
+	var buf bytes.Buffer
+	if _, err := data.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.blobs[blobID]; ok && opts.DoNotRecreate {
+		return blob.ErrBlobAlreadyExists
+	}
+
+	modTime := time.Now().Add(s.clockOffset)
+	if !opts.SetModTime.IsZero() {
+		modTime = opts.SetModTime
+	}
+	s.blobs[blobID] = buf.Bytes()
+	s.times[blobID] = modTime
+
+	if opts.GetModTime != nil {
+		*opts.GetModTime = modTime
+	}
+	return nil
+}
+
+func (s *MemoryStorage) DeleteBlob(_ context.Context, blobID blob.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, blobID)
+	delete(s.times, blobID)
+	return nil
+}
+
+func (s *MemoryStorage) Close(context.Context) error {
+	return nil
+}
+
+func (s *MemoryStorage) FlushCaches(context.Context) error {
+	return nil
+}
+
+func (s *MemoryStorage) ExtendBlobRetention(context.Context, blob.ID, blob.ExtendOptions) error {
+	return blob.ErrUnsupportedPutBlobOption
+}
+
+func (s *MemoryStorage) IsReadOnly() bool {
+	return false
+}
+
+// BlobCount returns the number of blobs in the storage
+func (s *MemoryStorage) BlobCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.blobs)
+}
+
+// SetupFakeRepository initializes a kopia repository in a MemoryStorage and connects the options to it,
+// with the user config of kopia in userConfigDir. The kopia functions of the options are replaced by
+// the real ones so that commands can be tested end to end without a network.
+func SetupFakeRepository(ctx context.Context, op *Options, userConfigDir string) (*MemoryStorage, error) {
+	st := NewMemoryStorage(userConfigDir)
+
+	op.Storage = st
+	op.OsUserConfigDir = func() (string, error) {
+		return userConfigDir, nil
+	}
+	op.S3New = func(ctx context.Context, opt *s3.Options, createIfNotExist bool) (blob.Storage, error) {
+		return st, nil
+	}
+	op.SetRepoController(KopiaRepoController{})
+	op.SetPolicyManager(KopiaPolicyManager{})
+
+	if err := repo.Initialize(ctx, st, &repo.NewRepositoryOptions{}, op.Password); err != nil {
+		return nil, err
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.Connect(ctx, kopiaUserConfigPath, st, op.Password, &repo.ConnectOptions{
+		ClientOptions: op.ClientOptions(),
+	}); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
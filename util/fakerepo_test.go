@@ -0,0 +1,129 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+type outputBuffer struct {
+	bytes.Buffer
+}
+
+func (b *outputBuffer) Length() int {
+	return b.Len()
+}
+
+type blobBytes []byte
+
+func (b blobBytes) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (b blobBytes) Length() int {
+	return len(b)
+}
+
+func (b blobBytes) Reader() io.ReadSeekCloser {
+	return nopCloser{bytes.NewReader(b)}
+}
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStorage(t.Name())
+
+	assert.NoError(t, st.PutBlob(ctx, "p1", blobBytes("0123456789"), blob.PutOptions{}))
+	assert.NoError(t, st.PutBlob(ctx, "q1", blobBytes("q"), blob.PutOptions{}))
+	assert.ErrorIs(t, st.PutBlob(ctx, "p1", blobBytes("x"), blob.PutOptions{DoNotRecreate: true}), blob.ErrBlobAlreadyExists)
+
+	var buf outputBuffer
+	assert.NoError(t, st.GetBlob(ctx, "p1", 2, 3, &buf))
+	assert.Equal(t, "234", buf.String())
+	assert.ErrorIs(t, st.GetBlob(ctx, "p1", 8, 5, &buf), blob.ErrInvalidRange)
+	assert.ErrorIs(t, st.GetBlob(ctx, "missing", 0, -1, &buf), blob.ErrBlobNotFound)
+
+	var ids []blob.ID
+	assert.NoError(t, st.ListBlobs(ctx, "p", func(bm blob.Metadata) error {
+		ids = append(ids, bm.BlobID)
+		return nil
+	}))
+	assert.Equal(t, []blob.ID{"p1"}, ids)
+
+	assert.NoError(t, st.DeleteBlob(ctx, "p1"))
+	assert.Equal(t, 1, st.BlobCount())
+}
+
+func TestSetupFakeRepository(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+
+	if _, err := SetupFakeRepository(ctx, op, t.TempDir()); !assert.NoError(t, err) {
+		return
+	}
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	labels := map[string]string{manifest.TypeLabelKey: "gasset-test"}
+	var id manifest.ID
+	err = repo.WriteSession(ctx, openRepository(t, ctx, op, kopiaUserConfigPath), repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		id, err = w.PutManifest(ctx, labels, map[string]string{"key": "value"})
+		return err
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The manifest is read back through a new connection to check that it was flushed to the storage
+	var payload map[string]string
+	_, err = openRepository(t, ctx, op, kopiaUserConfigPath).GetManifest(ctx, id, &payload)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"key": "value"}, payload)
+}
+
+func openRepository(t *testing.T, ctx context.Context, op *Options, kopiaUserConfigPath string) repo.Repository {
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if err != nil {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		rep.Close(ctx)
+	})
+	return rep
+}
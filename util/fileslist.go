@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FilesFromSource is the asset directory of the snapshots of file lists, which have no directory of their own
+const FilesFromSource = "."
+
+// ReadFileList reads a list of paths separated by NUL characters if there are any, like find -print0 writes them,
+// and by newlines otherwise. Empty entries are skipped.
+func ReadFileList(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	separator := "\n"
+	if bytes.IndexByte(data, 0) >= 0 {
+		separator = "\x00"
+	}
+
+	var paths []string
+	for _, entry := range strings.Split(string(data), separator) {
+		entry = strings.TrimSuffix(entry, "\r")
+		if entry != "" {
+			paths = append(paths, entry)
+		}
+	}
+	return paths, nil
+}
+
+// fileTreeNode is a directory of the virtual tree, or a local entry included as a whole
+type fileTreeNode struct {
+	entry    fs.Entry
+	children map[string]*fileTreeNode
+}
+
+// FileListTree returns a virtual directory holding only the listed files and directories at their paths relative
+// to root, so that they can be snapshotted as one source. The paths are relative to root unless they are absolute.
+func FileListTree(root string, paths []string) (fs.Directory, error) {
+	tree := &fileTreeNode{children: map[string]*fileTreeNode{}}
+
+	for _, listed := range paths {
+		absolute := listed
+		if !filepath.IsAbs(absolute) {
+			absolute = filepath.Join(root, listed)
+		}
+		rel, err := filepath.Rel(root, absolute)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s is not inside %s", listed, root)
+		}
+
+		entry, err := localfs.NewEntry(absolute)
+		if err != nil {
+			return nil, err
+		}
+
+		node := tree
+		for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+			// A directory listed as a whole includes everything listed inside it
+			if node.entry != nil {
+				break
+			}
+			child, ok := node.children[name]
+			if !ok {
+				child = &fileTreeNode{children: map[string]*fileTreeNode{}}
+				node.children[name] = child
+			}
+			node = child
+		}
+		if node.entry == nil {
+			node.entry = entry
+			node.children = nil
+		}
+	}
+
+	if len(tree.children) == 0 {
+		return nil, fmt.Errorf("the file list is empty")
+	}
+	return tree.directory(filepath.Base(root)), nil
+}
+
+func (n *fileTreeNode) directory(name string) fs.Directory {
+	names := make([]string, 0, len(n.children))
+	for childName := range n.children {
+		names = append(names, childName)
+	}
+	sort.Strings(names)
+
+	entries := make([]fs.Entry, 0, len(names))
+	for _, childName := range names {
+		child := n.children[childName]
+		if child.entry != nil {
+			entries = append(entries, child.entry)
+		} else {
+			entries = append(entries, child.directory(childName))
+		}
+	}
+	return virtualfs.NewStaticDirectory(name, entries)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileList(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{
+			name: "Read a newline separated list",
+			list: "assets/a.png\r\nassets/b c.png\n\n",
+			want: []string{"assets/a.png", "assets/b c.png"},
+		},
+		{
+			name: "Read a NUL separated list",
+			list: "assets/a\nb.png\x00assets/c.png\x00",
+			want: []string{"assets/a\nb.png", "assets/c.png"},
+		},
+		{
+			name: "Read an empty list",
+			list: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFileList(strings.NewReader(tt.list))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFileListTree(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"assets/a.png", "assets/b.png", "sounds/s.wav", "other.txt"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, file)), 0o755); err != nil {
+			t.FailNow()
+		}
+		if err := os.WriteFile(filepath.Join(root, file), []byte(file), 0o644); err != nil {
+			t.FailNow()
+		}
+	}
+
+	tree, err := FileListTree(root, []string{"sounds/s.wav", "assets/a.png", "sounds", filepath.Join(root, "assets", "a.png")})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var paths []string
+	var walk func(dir fs.Directory, prefix string)
+	walk = func(dir fs.Directory, prefix string) {
+		err := fs.IterateEntries(context.Background(), dir, func(ctx context.Context, entry fs.Entry) error {
+			if child, ok := entry.(fs.Directory); ok {
+				walk(child, prefix+entry.Name()+"/")
+				return nil
+			}
+			paths = append(paths, prefix+entry.Name())
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	walk(tree, "")
+	assert.Equal(t, []string{"assets/a.png", "sounds/s.wav"}, paths)
+
+	_, err = FileListTree(root, []string{"../outside.png"})
+	assert.Error(t, err)
+	_, err = FileListTree(root, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrFingerprintMismatch is returned when the repository in the storage is not the one first connected to
+var ErrFingerprintMismatch = errors.New("repository fingerprint does not match the recorded one")
+
+// FingerprintRecord is the fingerprint of the repository recorded on the first connect of a gasset id
+type FingerprintRecord struct {
+	Fingerprint string    `json:"fingerprint"`
+	RecordedAt  time.Time `json:"recordedAt"`
+}
+
+// RepositoryFingerprint identifies a repository by its unique id and format.
+// The unique id is generated when the repository is created so replaced bucket contents have another fingerprint.
+func RepositoryFingerprint(rep repo.DirectRepository) string {
+	fmgr := rep.FormatManager()
+	sum := sha256.New()
+	sum.Write(rep.UniqueID())
+	fmt.Fprintf(sum, "\n%s\n%s", fmgr.GetHashFunction(), fmgr.GetEncryptionAlgorithm())
+	return hex.EncodeToString(sum.Sum(nil)[:16])
+}
+
+func (op *Options) GetFingerprintPath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "fingerprint-"+op.Config.GassetId+".json"), nil
+}
+
+// VerifyFingerprint compares the fingerprint with the one recorded for the gasset id, recording it if there is none.
+// It returns whether the fingerprint was recorded now.
+func (op *Options) VerifyFingerprint(fingerprint string) (bool, error) {
+	fingerprintPath, err := op.GetFingerprintPath()
+	if err != nil {
+		return false, err
+	}
+
+	recordBytes, err := os.ReadFile(fingerprintPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		recordBytes, err := json.MarshalIndent(&FingerprintRecord{Fingerprint: fingerprint, RecordedAt: time.Now().UTC()}, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		if err := os.MkdirAll(filepath.Dir(fingerprintPath), 0o700); err != nil {
+			return false, err
+		}
+		return true, os.WriteFile(fingerprintPath, recordBytes, 0o600)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	record := &FingerprintRecord{}
+	if err := json.Unmarshal(recordBytes, record); err != nil {
+		return false, err
+	}
+	if record.Fingerprint != fingerprint {
+		return false, fmt.Errorf("%w: the storage holds %s but %s was recorded at %s, "+
+			"the bucket contents may have been replaced. If the repository was recreated on purpose, remove %s and connect again",
+			ErrFingerprintMismatch, fingerprint, record.Fingerprint, FormatTime(record.RecordedAt, false), fingerprintPath)
+	}
+	return false, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyFingerprint(t *testing.T) {
+	userDir := t.TempDir()
+	op := &Options{
+		Config: &Config{GassetId: "0000000000"},
+		OsUserConfigDir: func() (string, error) {
+			return userDir, nil
+		},
+	}
+
+	recorded, err := op.VerifyFingerprint("aaaa")
+	assert.NoError(t, err)
+	assert.True(t, recorded, "VerifyFingerprint() on the first connect")
+
+	recorded, err = op.VerifyFingerprint("aaaa")
+	assert.NoError(t, err)
+	assert.False(t, recorded, "VerifyFingerprint() with the recorded fingerprint")
+
+	_, err = op.VerifyFingerprint("bbbb")
+	assert.ErrorIs(t, err, ErrFingerprintMismatch)
+
+	op.Config.GassetId = ""
+	_, err = op.VerifyFingerprint("aaaa")
+	assert.Error(t, err)
+}
+
+func TestRepositoryFingerprint(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	ctx := context.Background()
+	fingerprint := func() string {
+		op := options.OptionsWithGassetId.Clone()
+		if _, err := SetupFakeRepository(ctx, op, t.TempDir()); err != nil {
+			t.FailNow()
+		}
+		kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+		if err != nil {
+			t.FailNow()
+		}
+		rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+		if err != nil {
+			t.FailNow()
+		}
+		defer rep.Close(ctx)
+		return RepositoryFingerprint(rep.(repo.DirectRepository))
+	}
+
+	first := fingerprint()
+	assert.Len(t, first, 32)
+	assert.NotEqual(t, first, fingerprint(), "RepositoryFingerprint() of another repository")
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CheckFilesystemStoragePath checks that the directory of a filesystem storage, e.g. on a mounted network drive,
+// can hold the repository. A missing directory is only accepted if it is going to be created.
+func CheckFilesystemStoragePath(path string, create bool) error {
+	if path == "" {
+		return errors.New("the filesystem storage has no path")
+	}
+	// The .gasset file is shared by every checkout, which can be anywhere on the machine
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("the path %s of the filesystem storage is not absolute", path)
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if create {
+			return nil
+		}
+		return fmt.Errorf("the storage directory %s does not exist, mount the drive or run init with --create to create it", path)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot access the storage directory %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("the storage path %s is not a directory", path)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckFilesystemStoragePath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		create  bool
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Accept an existing directory",
+			path:    dir,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Reject a missing directory",
+			path:    filepath.Join(dir, "missing"),
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Accept a missing directory which is going to be created",
+			path:    filepath.Join(dir, "missing"),
+			create:  true,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Reject a file",
+			path:    file,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject a relative path",
+			path:    "repository",
+			create:  true,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject an empty path",
+			path:    "",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.wantErr(t, CheckFilesystemStoragePath(tt.path, tt.create), "CheckFilesystemStoragePath(%v, %v)", tt.path, tt.create)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"github.com/kopia/kopia/snapshot/policy"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GassetIgnoreFileName is the file with gitignore patterns of the files left out of the snapshots. The one in the
+// root of the git repository applies to every asset directory, the ones in the asset directories to their subtree.
+const GassetIgnoreFileName = ".gassetignore"
+
+// ReadGassetIgnore returns the lines of the .gassetignore file in the root of the git repository, none without one
+func ReadGassetIgnore(workingDirectory string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(workingDirectory, GassetIgnoreFileName))
+	if errors.Is(err, iofs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"), nil
+}
+
+// GassetIgnoreRules translates the gitignore patterns of the root of the git repository into the ignore rules of
+// an asset directory. Patterns without a slash, or starting with **/, match at any depth and are kept as they are.
+// The other ones are anchored to the root and are kept relative to the asset directory if they are inside it.
+func GassetIgnoreRules(lines []string, dirPath string) []string {
+	dirSegments := strings.Split(path.Clean(filepath.ToSlash(dirPath)), "/")
+	if dirSegments[0] == "." {
+		dirSegments = nil
+	}
+
+	var rules []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negation, pattern := "", line
+		if rest, ok := strings.CutPrefix(pattern, "!"); ok {
+			negation, pattern = "!", rest
+		}
+		if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") || strings.HasPrefix(pattern, "**/") {
+			rules = append(rules, line)
+			continue
+		}
+
+		dirOnly := strings.HasSuffix(pattern, "/")
+		segments := strings.Split(strings.Trim(pattern, "/"), "/")
+		if len(segments) <= len(dirSegments) {
+			// The pattern matches the asset directory itself or one of its parents
+			continue
+		}
+		inside := true
+		for i, dirSegment := range dirSegments {
+			if matched, err := path.Match(segments[i], dirSegment); err != nil || !matched {
+				inside = false
+				break
+			}
+		}
+		if !inside {
+			continue
+		}
+		rule := negation + "/" + strings.Join(segments[len(dirSegments):], "/")
+		if dirOnly {
+			rule += "/"
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// GassetIgnorePolicy adds the .gassetignore files to the policy override base, the patterns of the one in the root
+// of the git repository as ignore rules of the asset directory and the ones in the asset directory as dot ignore files
+func (op *Options) GassetIgnorePolicy(dirPath string, base *policy.Policy) (*policy.Policy, error) {
+	lines, err := ReadGassetIgnore(op.WorkingDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	override := &policy.Policy{}
+	if base != nil {
+		copied := *base
+		override = &copied
+	}
+	override.FilesPolicy.IgnoreRules = append(slices.Clip(override.FilesPolicy.IgnoreRules), GassetIgnoreRules(lines, dirPath)...)
+	// The dot ignore files of an override replace the ones of the policies, so the ones of kopia are kept
+	override.FilesPolicy.DotIgnoreFiles = append(slices.Clone(policy.DefaultPolicy.FilesPolicy.DotIgnoreFiles), GassetIgnoreFileName)
+	return override, nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGassetIgnoreRules(t *testing.T) {
+	lines := []string{
+		"# build artifacts",
+		"*.tmp",
+		"!keep.tmp",
+		"",
+		"**/cache/",
+		"/assets/build/",
+		"!/assets/build/keep.bin",
+		"assets/*.psd",
+		"*/exports/",
+		"/sounds/raw/",
+		"/assets/",
+	}
+
+	tests := []struct {
+		name    string
+		dirPath string
+		want    []string
+	}{
+		{
+			name:    "Translate the patterns of the root into an asset directory",
+			dirPath: "./assets",
+			want:    []string{"*.tmp", "!keep.tmp", "**/cache/", "/build/", "!/build/keep.bin", "/*.psd", "/exports/"},
+		},
+		{
+			name:    "Leave out the anchored patterns of other directories",
+			dirPath: "sounds",
+			want:    []string{"*.tmp", "!keep.tmp", "**/cache/", "/exports/", "/raw/"},
+		},
+		{
+			name:    "Leave out the anchored patterns of parents of a nested directory",
+			dirPath: "./assets/textures",
+			want:    []string{"*.tmp", "!keep.tmp", "**/cache/"},
+		},
+		{
+			name:    "Keep every pattern for the root",
+			dirPath: ".",
+			want:    []string{"*.tmp", "!keep.tmp", "**/cache/", "/assets/build/", "!/assets/build/keep.bin", "/assets/*.psd", "/*/exports/", "/sounds/raw/", "/assets/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, GassetIgnoreRules(lines, tt.dirPath), "GassetIgnoreRules() of %s", tt.dirPath)
+		})
+	}
+}
+
+func TestGassetIgnorePolicy(t *testing.T) {
+	op := &Options{WorkingDirectory: t.TempDir()}
+	base := &policy.Policy{FilesPolicy: policy.FilesPolicy{IgnoreRules: []string{"/readme.txt"}}}
+
+	override, err := op.GassetIgnorePolicy("./assets", base)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"/readme.txt"}, override.FilesPolicy.IgnoreRules)
+	assert.Contains(t, override.FilesPolicy.DotIgnoreFiles, GassetIgnoreFileName)
+	assert.Contains(t, override.FilesPolicy.DotIgnoreFiles, ".kopiaignore", "the dot ignore files of kopia are kept")
+
+	if err := os.WriteFile(filepath.Join(op.WorkingDirectory, GassetIgnoreFileName), []byte("*.tmp\r\n/assets/build/\r\n"), 0o644); err != nil {
+		t.FailNow()
+	}
+	override, err = op.GassetIgnorePolicy("./assets", base)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"/readme.txt", "*.tmp", "/build/"}, override.FilesPolicy.IgnoreRules)
+	assert.Equal(t, []string{"/readme.txt"}, base.FilesPolicy.IgnoreRules)
+}
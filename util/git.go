@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitHead is the checked out branch and commit, the branch is empty for a detached HEAD
+type GitHead struct {
+	Branch string
+	Commit string
+}
+
+// GetGitHead reads the HEAD of the git repository at path without depending on the git executable
+func GetGitHead(path string) (GitHead, error) {
+	gitDir := filepath.Join(path, ".git")
+
+	headBytes, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return GitHead{}, err
+	}
+	head := strings.TrimSpace(string(headBytes))
+
+	ref, ok := strings.CutPrefix(head, "ref: ")
+	if !ok {
+		return GitHead{Commit: head}, nil
+	}
+
+	gitHead := GitHead{Branch: strings.TrimPrefix(ref, "refs/heads/")}
+	gitHead.Commit, err = resolveGitRef(gitDir, ref)
+	return gitHead, err
+}
+
+// resolveGitRef returns the commit of a ref or an empty one for a branch without commits
+func resolveGitRef(gitDir string, ref string) (string, error) {
+	refBytes, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref)))
+	if err == nil {
+		return strings.TrimSpace(string(refBytes)), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	packedRefs, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer packedRefs.Close()
+
+	scanner := bufio.NewScanner(packedRefs)
+	for scanner.Scan() {
+		commit, name, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == ref {
+			return commit, nil
+		}
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetGitHead(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    GitHead
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Resolve a loose branch ref",
+			files: map[string]string{
+				"HEAD":                   "ref: refs/heads/feature/sky\n",
+				"refs/heads/main":        "1111111111111111111111111111111111111111\n",
+				"refs/heads/feature/sky": "2222222222222222222222222222222222222222\n",
+			},
+			want:    GitHead{Branch: "feature/sky", Commit: "2222222222222222222222222222222222222222"},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Resolve a packed branch ref",
+			files: map[string]string{
+				"HEAD":        "ref: refs/heads/main\n",
+				"packed-refs": "# pack-refs with: peeled fully-peeled sorted\n3333333333333333333333333333333333333333 refs/heads/main\n",
+			},
+			want:    GitHead{Branch: "main", Commit: "3333333333333333333333333333333333333333"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Resolve a detached HEAD",
+			files:   map[string]string{"HEAD": "4444444444444444444444444444444444444444\n"},
+			want:    GitHead{Commit: "4444444444444444444444444444444444444444"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Resolve a branch without commits",
+			files:   map[string]string{"HEAD": "ref: refs/heads/main\n"},
+			want:    GitHead{Branch: "main"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Check if error is thrown without a HEAD",
+			files:   map[string]string{},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				filePath := filepath.Join(dir, ".git", filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+					t.FailNow()
+				}
+				if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+					t.FailNow()
+				}
+			}
+
+			got, err := GetGitHead(dir)
+			if !tt.wantErr(t, err, "GetGitHead()") || err != nil {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "GetGitHead()")
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Handling of files in the asset directories which are tracked by git as well
+const (
+	// GitTrackedExclude excludes the git tracked files from the snapshots, the default
+	GitTrackedExclude = "exclude"
+	// GitTrackedInclude snapshots the git tracked files as well
+	GitTrackedInclude = "include"
+	// GitTrackedError refuses to snapshot a directory with git tracked files
+	GitTrackedError = "error"
+)
+
+// GitTrackedMode returns the configured handling of git tracked files in the asset directories
+func (c *Config) GitTrackedMode() string {
+	if c.GitTracked == "" {
+		return GitTrackedExclude
+	}
+	return c.GitTracked
+}
+
+// GitTrackedFiles returns the slash separated paths, relative to the asset directory, of the files in it
+// which are in the git index
+func GitTrackedFiles(workingDirectory string, dir string) ([]string, error) {
+	cmd := exec.Command("git", "-C", workingDirectory, "ls-files", "-z", "--full-name", "--", filepath.ToSlash(dir))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	dirPrefix := path.Clean(filepath.ToSlash(dir)) + "/"
+	var tracked []string
+	for _, trackedPath := range strings.Split(string(output), "\x00") {
+		if relativePath, ok := strings.CutPrefix(trackedPath, dirPrefix); ok && relativePath != "" {
+			tracked = append(tracked, relativePath)
+		}
+	}
+	return tracked, nil
+}
+
+// GitIgnoreRules returns the kopia ignore rules, anchored to the snapshot root, excluding the given paths
+func GitIgnoreRules(relativePaths []string) []string {
+	rules := make([]string, 0, len(relativePaths))
+	for _, relativePath := range relativePaths {
+		rules = append(rules, "/"+escapeIgnorePattern(relativePath))
+	}
+	return rules
+}
+
+// escapeIgnorePattern escapes the characters having a meaning in gitignore patterns
+func escapeIgnorePattern(name string) string {
+	var escaped strings.Builder
+	for i, r := range name {
+		switch {
+		case strings.ContainsRune(`\*?[`, r):
+			escaped.WriteRune('\\')
+		case r == ' ' && i == len(name)-1:
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitTrackedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"assets/tracked.txt", "assets/sub/tracked.png", "assets/untracked.png", "code.go"} {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.FailNow()
+		}
+		if err := os.WriteFile(filePath, []byte(name), 0644); err != nil {
+			t.FailNow()
+		}
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "assets/tracked.txt", "assets/sub/tracked.png", "code.go"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			t.Skipf("git is not available: %v", err)
+		}
+	}
+
+	tracked, err := GitTrackedFiles(dir, "./assets")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.ElementsMatch(t, []string{"tracked.txt", "sub/tracked.png"}, tracked)
+
+	_, err = GitTrackedFiles(t.TempDir(), "./assets")
+	assert.Error(t, err, "outside of a git repository")
+}
+
+func TestGitIgnoreRules(t *testing.T) {
+	assert.Equal(t,
+		[]string{"/sub/tracked.png", `/\[draft] \*final\?.psd`, "/!important.txt", `/trailing\ `},
+		GitIgnoreRules([]string{"sub/tracked.png", "[draft] *final?.psd", "!important.txt", "trailing "}))
+}
+
+func TestGitTrackedMode(t *testing.T) {
+	assert.Equal(t, GitTrackedExclude, (&Config{}).GitTrackedMode())
+	assert.Equal(t, GitTrackedError, (&Config{GitTracked: GitTrackedError}).GitTrackedMode())
+}
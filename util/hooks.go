@@ -0,0 +1,144 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/restore"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Environment variables describing the restore to the hook commands
+const (
+	EnvHookDir          = "GASSET_HOOK_DIR"
+	EnvHookSnapshot     = "GASSET_HOOK_SNAPSHOT"
+	EnvHookChangedCount = "GASSET_HOOK_CHANGED_COUNT"
+	EnvHookChangedFiles = "GASSET_HOOK_CHANGED_FILES"
+)
+
+// RestoreHook is a command run after the assets of a directory are restored,
+// e.g. to make an engine refresh its asset database or to touch a marker file.
+type RestoreHook struct {
+	Dir     string   `json:"dir"`
+	Command []string `json:"command"`
+}
+
+// RestoreChange describes the files a restore changed in an asset directory
+type RestoreChange struct {
+	Dir      string
+	Snapshot string
+	// Changed holds the slash separated paths of the changed files relative to the working directory
+	Changed []string
+}
+
+// RunRestoreHooks runs the hooks of the directories with changed files.
+// The changed files are listed in a temp file since the list can exceed the size limit of the environment.
+// A failing hook doesn't stop the others from running.
+func (op *Options) RunRestoreHooks(ctx context.Context, changes []RestoreChange, stdout io.Writer, stderr io.Writer) error {
+	var errs []error
+	for _, change := range changes {
+		if len(change.Changed) == 0 {
+			continue
+		}
+		for _, hook := range op.Config.RestoreHooks {
+			if filepath.Clean(hook.Dir) != filepath.Clean(change.Dir) {
+				continue
+			}
+			if err := op.runRestoreHook(ctx, hook, change, stdout, stderr); err != nil {
+				errs = append(errs, fmt.Errorf("restore hook of %s: %w", hook.Dir, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (op *Options) runRestoreHook(ctx context.Context, hook RestoreHook, change RestoreChange, stdout io.Writer, stderr io.Writer) error {
+	if len(hook.Command) == 0 {
+		return errors.New("command is empty")
+	}
+
+	changedFile, err := os.CreateTemp(op.TempDir(), "gasset-changed-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(changedFile.Name())
+
+	_, err = io.WriteString(changedFile, strings.Join(change.Changed, "\n")+"\n")
+	if closeErr := changedFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Dir = op.WorkingDirectory
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(),
+		EnvHookDir+"="+change.Dir,
+		EnvHookSnapshot+"="+change.Snapshot,
+		EnvHookChangedCount+"="+strconv.Itoa(len(change.Changed)),
+		EnvHookChangedFiles+"="+changedFile.Name(),
+	)
+	return cmd.Run()
+}
+
+// ChangeOutput collects the files written by the wrapped output restoring the asset directory at dirPath,
+// the files which already existed are not changed
+type ChangeOutput struct {
+	restore.Output
+
+	dirPath string
+	mu      sync.Mutex
+	changed []string
+}
+
+func NewChangeOutput(output restore.Output, dirPath string) *ChangeOutput {
+	return &ChangeOutput{Output: output, dirPath: path.Clean(filepath.ToSlash(dirPath))}
+}
+
+// WriteFile implements restore.Output
+func (o *ChangeOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	if err := o.Output.WriteFile(ctx, relativePath, f); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	o.changed = append(o.changed, path.Join(o.dirPath, relativePath))
+	o.mu.Unlock()
+	return nil
+}
+
+// Changed returns the slash separated paths of the written files relative to the working directory, sorted
+func (o *ChangeOutput) Changed() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	changed := append([]string(nil), o.changed...)
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunRestoreHooks(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+	options := testOptions.OptionsWithGassetId.Clone()
+	options.WorkingDirectory = t.TempDir()
+	options.TempDirectory = t.TempDir()
+	options.Config.RestoreHooks = []RestoreHook{
+		{Dir: "./assets", Command: []string{"sh", "-c", `echo "$GASSET_HOOK_DIR $GASSET_HOOK_SNAPSHOT $GASSET_HOOK_CHANGED_COUNT"; cat "$GASSET_HOOK_CHANGED_FILES"; touch refreshed`}},
+		{Dir: "assets", Command: []string{"sh", "-c", "exit 3"}},
+		{Dir: "./models", Command: []string{"sh", "-c", "touch models-refreshed"}},
+	}
+
+	var stdout bytes.Buffer
+	err := options.RunRestoreHooks(context.Background(), []RestoreChange{
+		{Dir: "./assets", Snapshot: "k1", Changed: []string{"assets/a.png", "assets/b.png"}},
+		{Dir: "./models", Snapshot: "k2"},
+	}, &stdout, &stdout)
+
+	assert.ErrorContains(t, err, "restore hook of assets: exit status 3")
+	assert.Equal(t, "./assets k1 2\nassets/a.png\nassets/b.png\n", stdout.String())
+	assert.FileExists(t, filepath.Join(options.WorkingDirectory, "refreshed"))
+	assert.NoFileExists(t, filepath.Join(options.WorkingDirectory, "models-refreshed"), "hooks of unchanged directories are not run")
+
+	leftovers, err := os.ReadDir(options.TempDirectory)
+	assert.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func TestChangeOutput(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	source := filepath.Join(root, "source.bin")
+	if err := os.WriteFile(source, []byte("restored"), 0644); err != nil {
+		t.FailNow()
+	}
+	entry, err := localfs.NewEntry(source)
+	if err != nil {
+		t.FailNow()
+	}
+
+	target := &restore.FilesystemOutput{TargetPath: filepath.Join(root, "target")}
+	if err := os.MkdirAll(target.TargetPath, 0755); err != nil {
+		t.FailNow()
+	}
+	if err := target.Init(ctx); err != nil {
+		t.FailNow()
+	}
+
+	output := NewChangeOutput(target, "./assets")
+	assert.NoError(t, output.WriteFile(ctx, "b.bin", entry.(fs.File)))
+	assert.NoError(t, output.WriteFile(ctx, "a.bin", entry.(fs.File)))
+	assert.Error(t, output.WriteFile(ctx, "a.bin", entry.(fs.File)))
+	assert.Equal(t, []string{"assets/a.bin", "assets/b.bin"}, output.Changed())
+}
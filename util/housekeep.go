@@ -0,0 +1,157 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"github.com/kopia/kopia/repo"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// OrphanedItem is a local config, state file or cache of a gasset id which no registered checkout uses anymore
+type OrphanedItem struct {
+	GassetId string `json:"gassetId"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	// Unknown is set when no checkout of the gasset id was ever registered, e.g. because it was
+	// last used by a version of gasset without the registry
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// stateGassetId returns the gasset id of a file or directory named like kopia-<id>.config or cache-<id>
+func stateGassetId(name string) (string, bool) {
+	_, rest, found := strings.Cut(name, "-")
+	if !found {
+		return "", false
+	}
+	gassetId, _, _ := strings.Cut(rest, ".")
+	if gassetId == "" || strings.ContainsAny(gassetId, "-_ ") {
+		return "", false
+	}
+	return gassetId, true
+}
+
+// FindOrphanedItems lists the kopia configs, caches and local state of the gasset ids without a live checkout,
+// sorted by gasset id and path. The caches are found through the kopia configs so that the ones kopia keeps
+// in the cache directory of the os are reclaimed as well.
+func (op *Options) FindOrphanedItems() ([]OrphanedItem, error) {
+	registry, err := op.LoadCheckoutRegistry()
+	if err != nil {
+		return nil, err
+	}
+	live := registry.LiveGassetIds()
+
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	stateDir := filepath.Join(userDir, "git-gasset")
+
+	entries, err := os.ReadDir(stateDir)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []OrphanedItem
+	for _, entry := range entries {
+		gassetId, ok := stateGassetId(entry.Name())
+		if !ok || live[gassetId] {
+			continue
+		}
+		_, registered := registry.GassetIds[gassetId]
+		itemPath := filepath.Join(stateDir, entry.Name())
+
+		if strings.HasPrefix(entry.Name(), "kopia-") && strings.HasSuffix(entry.Name(), ".config") {
+			if cacheDir := kopiaCacheDirectory(itemPath); cacheDir != "" && !strings.HasPrefix(cacheDir, stateDir+string(filepath.Separator)) {
+				if size, err := pathSize(cacheDir); err == nil {
+					items = append(items, OrphanedItem{GassetId: gassetId, Path: cacheDir, Size: size, Unknown: !registered})
+				}
+			}
+		}
+
+		size, err := pathSize(itemPath)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, OrphanedItem{GassetId: gassetId, Path: itemPath, Size: size, Unknown: !registered})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].GassetId != items[j].GassetId {
+			return items[i].GassetId < items[j].GassetId
+		}
+		return items[i].Path < items[j].Path
+	})
+	return items, nil
+}
+
+// RemoveOrphanedItems deletes the items and forgets their gasset ids in the registry
+func (op *Options) RemoveOrphanedItems(items []OrphanedItem) error {
+	var errs []error
+	for _, item := range items {
+		if err := os.RemoveAll(item.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	registry, err := op.LoadCheckoutRegistry()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, item := range items {
+		delete(registry.GassetIds, item.GassetId)
+	}
+	if err := op.SaveCheckoutRegistry(registry); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// kopiaCacheDirectory returns the cache directory of a kopia config or an empty string if it has none
+func kopiaCacheDirectory(configPath string) string {
+	localConfig, err := repo.LoadConfigFromFile(configPath)
+	if err != nil || localConfig.Caching == nil {
+		return ""
+	}
+	return localConfig.Caching.CacheDirectory
+}
+
+// pathSize returns the total size of the files of a directory or the size of a file
+func pathSize(root string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(root, func(_ string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
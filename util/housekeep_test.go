@@ -0,0 +1,83 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindOrphanedItems(t *testing.T) {
+	userDir := t.TempDir()
+	stateDir := filepath.Join(userDir, "git-gasset")
+	checkout := t.TempDir()
+	if err := os.WriteFile(filepath.Join(checkout, ".gasset"), []byte(`{"gassetId": "live1234"}`), 0600); err != nil {
+		t.FailNow()
+	}
+	writeFile := func(name string, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(stateDir, name)), 0o700); err != nil {
+			t.FailNow()
+		}
+		if err := os.WriteFile(filepath.Join(stateDir, name), []byte(content), 0o600); err != nil {
+			t.FailNow()
+		}
+	}
+	writeFile("usage-live1234.json", "{}")
+	writeFile("usage-gone1234.json", "{}")
+	writeFile("cache-gone1234/blob", "12345")
+	writeFile("hashes-never1234.json", "{}")
+
+	op := &Options{
+		WorkingDirectory: checkout,
+		Config:           &Config{GassetId: "live1234"},
+		OsUserConfigDir: func() (string, error) {
+			return userDir, nil
+		},
+	}
+	assert.NoError(t, op.RegisterCheckout(time.Now()))
+	registry, err := op.LoadCheckoutRegistry()
+	if !assert.NoError(t, err) {
+		return
+	}
+	registry.Register("gone1234", filepath.Join(checkout, "deleted"), time.Now())
+	assert.NoError(t, op.SaveCheckoutRegistry(registry))
+
+	items, err := op.FindOrphanedItems()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []OrphanedItem{
+		{GassetId: "gone1234", Path: filepath.Join(stateDir, "cache-gone1234"), Size: 5},
+		{GassetId: "gone1234", Path: filepath.Join(stateDir, "usage-gone1234.json"), Size: 2},
+		{GassetId: "never1234", Path: filepath.Join(stateDir, "hashes-never1234.json"), Size: 2, Unknown: true},
+	}, items)
+
+	assert.NoError(t, op.RemoveOrphanedItems(items[:2]))
+	assert.NoFileExists(t, filepath.Join(stateDir, "usage-gone1234.json"))
+	assert.NoDirExists(t, filepath.Join(stateDir, "cache-gone1234"))
+	assert.FileExists(t, filepath.Join(stateDir, "usage-live1234.json"))
+
+	registry, err = op.LoadCheckoutRegistry()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, registry.GassetIds, "gone1234")
+	assert.Contains(t, registry.GassetIds, "live1234")
+}
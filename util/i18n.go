@@ -0,0 +1,164 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvLang selects the language of the user-facing messages, log output is never translated
+const EnvLang = "GASSET_LANG"
+
+// catalogs maps the English message formats to their translations per language.
+// Translations may reorder the arguments with explicit indexes like %[2]d.
+var catalogs = map[string]map[string]string{
+	"ja": {
+		"Local cache is disabled, nothing to verify":       "ローカルキャッシュが無効のため、検証するものはありません",
+		"Checked %d cache items, %d corrupted, %d evicted": "%d 件のキャッシュ項目を確認しました（破損 %d 件、削除 %d 件）",
+		"%s is valid": "%s は有効です",
+		"The connected kopia config matches the .gasset file":                                                "接続中の kopia 設定は .gasset ファイルと一致しています",
+		"Wrote %s, fill in the values before connecting":                                                     "%s を作成しました。接続する前に値を入力してください",
+		"All required environment variables are set":                                                         "必要な環境変数はすべて設定されています",
+		"%s is not part of any snapshot":                                                                     "%s はどのスナップショットにも含まれていません",
+		"%s is part of %d of %d snapshots, nothing was rewritten":                                            "%[1]s は %[3]d 件中 %[2]d 件のスナップショットに含まれています。何も書き換えていません",
+		"Removed %s from %d snapshots, its content is dropped from the storage by the next full maintenance": "%[2]d 件のスナップショットから %[1]s を削除しました。内容は次回のフルメンテナンスでストレージから削除されます",
+		"Nothing to resume":                                                                            "再開する操作はありません",
+		"Resuming %s started at %s, remaining: %s":                                                     "%[2]s に開始した %[1]s を再開します。残り: %[3]s",
+		"This will %s.\nType the gasset id %s to confirm: ":                                            "この操作は次を実行します: %s\n確認のため gasset id %s を入力してください: ",
+		"confirmation does not match the gasset id, aborting":                                          "入力が gasset id と一致しないため中止します",
+		"Snapshot %s of %s taken at %s":                                                                "%[3]s に作成された %[2]s のスナップショット %[1]s",
+		"%d snapshots would be deleted, nothing was deleted":                                           "%d 件のスナップショットが削除対象です。何も削除していません",
+		"Deleted %d snapshots, their content is dropped from the storage by the next full maintenance": "%d 件のスナップショットを削除しました。内容は次回のフルメンテナンスでストレージから削除されます",
+		"Archived %s as snapshot %s":                                                                   "%s をスナップショット %s としてアーカイブしました",
+		"Removed %s from the .gasset file":                                                             ".gasset ファイルから %s を削除しました",
+		"All files match their recorded hashes":                                                        "すべてのファイルが記録されたハッシュと一致しています",
+		"No setting of the bucket %s endangers the repository":                                         "バケット %s の設定にリポジトリを危険にさらすものはありません",
+		"%d snapshots would become eligible for deletion, the policy was not changed":                  "%d 件のスナップショットが削除対象になります。ポリシーは変更していません",
+		"Changed the retention, %d snapshots become eligible for deletion by the next prune":           "保持設定を変更しました。%d 件のスナップショットが次回の prune で削除対象になります",
+		"%d of %d assets are cold, %s in total":                                                        "%[2]d 件中 %[1]d 件のアセットが未使用です（合計 %[3]s）",
+		"Moved %s to %s as snapshot %s":                                                                "%s を %s に移動し、スナップショット %s を作成しました",
+		"Restored %s from snapshot %s, %d files written and %d unchanged":                              "%[1]s をスナップショット %[2]s から復元しました（書き込み %[3]d 件、変更なし %[4]d 件）",
+		"%s has no snapshot yet":                                                                       "%s のスナップショットはまだありません",
+		"%s matches snapshot %s":                                                                       "%s はスナップショット %s と一致しています",
+		"%s changed since snapshot %s":                                                                 "%s はスナップショット %s 以降に変更されています",
+		"%d files changed, %s in size":                                                                 "%d 件のファイルが変更されました（サイズ %s）",
+		"Delete %d orphaned items, %s in total?":                                                       "孤立した %d 件の項目（合計 %s）を削除しますか?",
+		"not confirmed, aborting":                                                                      "確認されなかったため中止します",
+		"%d orphaned items, %s in total":                                                               "孤立した項目 %d 件（合計 %s）",
+		"Deleted %d orphaned items, reclaimed %s":                                                      "孤立した %d 件の項目を削除し、%s を解放しました",
+		"Forgot %d checkouts":                                                                          "%d 件のチェックアウトを登録から削除しました",
+		"No snap statistics recorded yet":                                                              "スナップの統計はまだ記録されていません",
+		"Duration trend:":                                                                              "所要時間の推移:",
+		"Latest run took %s, the median is %s":                                                         "最新の実行は %s かかりました。中央値は %s です",
+		"The credentials permit every checked operation":                                               "認証情報はチェックしたすべての操作を許可しています",
+		"Published %s to the %s channel as snapshot %s":                                                "%[1]s を %[2]s チャンネルにスナップショット %[3]s として公開しました",
+		"The lock files are consistent":                                                                "ロックファイルに問題はありません",
+		"Serving the pack blobs of %s to the peers on port %d":                                         "%[1]s のパックブロブをポート %[2]d でピアに提供しています",
+		"Sampled %d files of %s":                                                                       "%[2]s の %[1]d 個のファイルをサンプリングしました",
+		"Verified %d files of %d snapshots, %d corrupted":                                              "%[2]d 個のスナップショットの %[1]d 個のファイルを検証しました。破損: %[3]d 個",
+		"Recovered %s from the backup taken by %s at %s":                                               "%[2]s が %[3]s に取ったバックアップから %[1]s を復元しました",
+		"Stored the password of the repository %s in the keyring":                                      "リポジトリ %s のパスワードをキーリングに保存しました",
+		"Removed the password of the repository %s from the keyring":                                   "リポジトリ %s のパスワードをキーリングから削除しました",
+		"Password: ": "パスワード: ",
+		"%s: %d files hashed (%s), %d cached (%s), %s uploaded": "%s: %d ファイルをハッシュ化 (%s)、%d キャッシュ済み (%s)、%s アップロード済み",
+		", %s left": "、残り %s",
+		"Created the tutorial project %s with the asset directory %s": "アセットディレクトリ %[2]s を持つチュートリアルプロジェクト %[1]s を作成しました",
+		"Created the repository in %s like init --create does":        "init --create と同じように %s にリポジトリを作成しました",
+		"Snapshotted %s like snap does":                               "snap と同じように %s のスナップショットを取りました",
+		"Deleted %s as if it was lost":                                "%s を失ったものとして削除しました",
+		"Restored %s like restore does":                               "restore と同じように %s を復元しました",
+		"The tutorial project is kept in %s, delete it when done":     "チュートリアルプロジェクトは %s に残してあります。終わったら削除してください",
+		"No profiles are configured in the .gasset file":              ".gasset ファイルにプロファイルが設定されていません",
+	},
+	"ko": {
+		"Local cache is disabled, nothing to verify":       "로컬 캐시가 비활성화되어 있어 검증할 항목이 없습니다",
+		"Checked %d cache items, %d corrupted, %d evicted": "캐시 항목 %d개를 확인했습니다 (손상 %d개, 제거 %d개)",
+		"%s is valid": "%s 은(는) 유효합니다",
+		"The connected kopia config matches the .gasset file":                                                "연결된 kopia 설정이 .gasset 파일과 일치합니다",
+		"Wrote %s, fill in the values before connecting":                                                     "%s 을(를) 작성했습니다. 연결하기 전에 값을 입력하세요",
+		"All required environment variables are set":                                                         "필요한 환경 변수가 모두 설정되어 있습니다",
+		"%s is not part of any snapshot":                                                                     "%s 은(는) 어떤 스냅샷에도 포함되어 있지 않습니다",
+		"%s is part of %d of %d snapshots, nothing was rewritten":                                            "%[1]s 은(는) 스냅샷 %[3]d개 중 %[2]d개에 포함되어 있습니다. 아무것도 다시 쓰지 않았습니다",
+		"Removed %s from %d snapshots, its content is dropped from the storage by the next full maintenance": "스냅샷 %[2]d개에서 %[1]s 을(를) 제거했습니다. 내용은 다음 전체 유지 관리 때 스토리지에서 삭제됩니다",
+		"Nothing to resume":                                                                            "재개할 작업이 없습니다",
+		"Resuming %s started at %s, remaining: %s":                                                     "%[2]s에 시작된 %[1]s 을(를) 재개합니다. 남은 항목: %[3]s",
+		"This will %s.\nType the gasset id %s to confirm: ":                                            "이 작업은 다음을 수행합니다: %s\n확인하려면 gasset id %s 을(를) 입력하세요: ",
+		"confirmation does not match the gasset id, aborting":                                          "입력이 gasset id와 일치하지 않아 중단합니다",
+		"Snapshot %s of %s taken at %s":                                                                "%[3]s에 생성된 %[2]s 의 스냅샷 %[1]s",
+		"%d snapshots would be deleted, nothing was deleted":                                           "스냅샷 %d개가 삭제 대상입니다. 아무것도 삭제하지 않았습니다",
+		"Deleted %d snapshots, their content is dropped from the storage by the next full maintenance": "스냅샷 %d개를 삭제했습니다. 내용은 다음 전체 유지 관리 때 스토리지에서 삭제됩니다",
+		"Archived %s as snapshot %s":                                                                   "%s 을(를) 스냅샷 %s 로 보관했습니다",
+		"Removed %s from the .gasset file":                                                             ".gasset 파일에서 %s 을(를) 제거했습니다",
+		"All files match their recorded hashes":                                                        "모든 파일이 기록된 해시와 일치합니다",
+		"No setting of the bucket %s endangers the repository":                                         "버킷 %s 의 설정 중 저장소를 위험하게 하는 항목은 없습니다",
+		"%d snapshots would become eligible for deletion, the policy was not changed":                  "스냅샷 %d개가 삭제 대상이 됩니다. 정책은 변경하지 않았습니다",
+		"Changed the retention, %d snapshots become eligible for deletion by the next prune":           "보존 설정을 변경했습니다. 스냅샷 %d개가 다음 prune 때 삭제 대상이 됩니다",
+		"%d of %d assets are cold, %s in total":                                                        "에셋 %[2]d개 중 %[1]d개가 사용되지 않습니다 (합계 %[3]s)",
+		"Moved %s to %s as snapshot %s":                                                                "%s 을(를) %s (으)로 이동하고 스냅샷 %s 을(를) 만들었습니다",
+		"Restored %s from snapshot %s, %d files written and %d unchanged":                              "스냅샷 %[2]s 에서 %[1]s 을(를) 복원했습니다 (작성 %[3]d개, 변경 없음 %[4]d개)",
+		"%s has no snapshot yet":                                                                       "%s 의 스냅샷이 아직 없습니다",
+		"%s matches snapshot %s":                                                                       "%s 이(가) 스냅샷 %s 와(과) 일치합니다",
+		"%s changed since snapshot %s":                                                                 "%s 이(가) 스냅샷 %s 이후 변경되었습니다",
+		"%d files changed, %s in size":                                                                 "파일 %d개가 변경되었습니다 (크기 %s)",
+		"Delete %d orphaned items, %s in total?":                                                       "분리된 항목 %d개 (합계 %s)를 삭제하시겠습니까?",
+		"not confirmed, aborting":                                                                      "확인되지 않아 중단합니다",
+		"%d orphaned items, %s in total":                                                               "분리된 항목 %d개 (합계 %s)",
+		"Deleted %d orphaned items, reclaimed %s":                                                      "분리된 항목 %d개를 삭제하고 %s 을(를) 확보했습니다",
+		"Forgot %d checkouts":                                                                          "체크아웃 %d개를 등록에서 제거했습니다",
+		"No snap statistics recorded yet":                                                              "아직 기록된 스냅 통계가 없습니다",
+		"Duration trend:":                                                                              "소요 시간 추이:",
+		"Latest run took %s, the median is %s":                                                         "최근 실행은 %s 걸렸습니다. 중앙값은 %s 입니다",
+		"The credentials permit every checked operation":                                               "자격 증명이 확인한 모든 작업을 허용합니다",
+		"Published %s to the %s channel as snapshot %s":                                                "%[1]s 을(를) 스냅샷 %[3]s (으)로 %[2]s 채널에 게시했습니다",
+		"The lock files are consistent":                                                                "잠금 파일에 문제가 없습니다",
+		"Serving the pack blobs of %s to the peers on port %d":                                         "%[1]s 의 팩 블롭을 포트 %[2]d 에서 피어에 제공하고 있습니다",
+		"Sampled %d files of %s":                                                                       "%[2]s 의 파일 %[1]d개를 샘플링했습니다",
+		"Verified %d files of %d snapshots, %d corrupted":                                              "스냅샷 %[2]d개의 파일 %[1]d개를 검증했습니다. 손상: %[3]d개",
+		"Recovered %s from the backup taken by %s at %s":                                               "%[3]s 에 %[2]s 가 만든 백업에서 %[1]s 를 복구했습니다",
+		"Stored the password of the repository %s in the keyring":                                      "저장소 %s 의 비밀번호를 키링에 저장했습니다",
+		"Removed the password of the repository %s from the keyring":                                   "저장소 %s 의 비밀번호를 키링에서 삭제했습니다",
+		"Password: ": "비밀번호: ",
+		"%s: %d files hashed (%s), %d cached (%s), %s uploaded": "%s: %d 개 파일 해시 (%s), %d 개 캐시됨 (%s), %s 업로드됨",
+		", %s left": ", %s 남음",
+		"Created the tutorial project %s with the asset directory %s": "에셋 디렉터리 %[2]s 가 있는 튜토리얼 프로젝트 %[1]s 를 만들었습니다",
+		"Created the repository in %s like init --create does":        "init --create 처럼 %s 에 리포지토리를 만들었습니다",
+		"Snapshotted %s like snap does":                               "snap 처럼 %s 의 스냅샷을 만들었습니다",
+		"Deleted %s as if it was lost":                                "%s 를 잃어버린 것처럼 삭제했습니다",
+		"Restored %s like restore does":                               "restore 처럼 %s 를 복원했습니다",
+		"The tutorial project is kept in %s, delete it when done":     "튜토리얼 프로젝트는 %s 에 남아 있습니다. 끝나면 삭제하세요",
+		"No profiles are configured in the .gasset file":              ".gasset 파일에 설정된 프로필이 없습니다",
+	},
+}
+
+// Language returns the language selected with GASSET_LANG, e.g. "ja" for ja_JP.UTF-8
+func Language() string {
+	lang := strings.ToLower(os.Getenv(EnvLang))
+	if i := strings.IndexAny(lang, "_-."); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// T formats a user-facing message in the selected language, falling back to English
+func T(format string, args ...any) string {
+	if translated, ok := catalogs[Language()][format]; ok {
+		format = translated
+	}
+	return fmt.Sprintf(format, args...)
+}
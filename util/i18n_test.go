@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		name string
+		lang string
+		want string
+	}{
+		{
+			name: "Fall back to English without a language",
+			lang: "",
+			want: "./assets is part of 1 of 3 snapshots, nothing was rewritten",
+		},
+		{
+			name: "Fall back to English for an unknown language",
+			lang: "fr_FR.UTF-8",
+			want: "./assets is part of 1 of 3 snapshots, nothing was rewritten",
+		},
+		{
+			name: "Translate to Japanese with reordered arguments",
+			lang: "ja_JP.UTF-8",
+			want: "./assets は 3 件中 1 件のスナップショットに含まれています。何も書き換えていません",
+		},
+		{
+			name: "Translate to Korean",
+			lang: "ko",
+			want: "./assets 은(는) 스냅샷 3개 중 1개에 포함되어 있습니다. 아무것도 다시 쓰지 않았습니다",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvLang, tt.lang)
+			got := T("%s is part of %d of %d snapshots, nothing was rewritten", "./assets", 1, 3)
+			assert.Equalf(t, tt.want, got, "T() with %s=%s", EnvLang, tt.lang)
+		})
+	}
+}
+
+func TestCatalogsKeepVerbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%(?:\[\d+])?([a-z])`)
+	verbsOf := func(format string) []string {
+		var result []string
+		for _, match := range verbs.FindAllStringSubmatch(format, -1) {
+			result = append(result, match[1])
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	for lang, catalog := range catalogs {
+		for format, translated := range catalog {
+			assert.Equalf(t, verbsOf(format), verbsOf(translated), "%s translation of %q", lang, format)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/zalando/go-keyring"
+)
+
+// The function fields of the options are injected as a group through these interfaces, so that tests can
+// replace them with the generated mocks of the gassettest package instead of writing stubs of their own.
+
+// StorageFactory creates the storages of the .gasset file and reads the settings of their buckets
+type StorageFactory interface {
+	NewS3(ctx context.Context, opt *s3.Options, createIfNotExist bool) (blob.Storage, error)
+	NewB2(ctx context.Context, opt *b2.Options, createIfNotExist bool) (blob.Storage, error)
+	NewFilesystem(ctx context.Context, opt *filesystem.Options, createIfNotExist bool) (blob.Storage, error)
+	BucketPolicy(ctx context.Context, opt *s3.Options) (string, error)
+	BucketSettings(ctx context.Context, opt *s3.Options) (*BucketSettings, error)
+	CheckEncryption(ctx context.Context, opt *s3.Options, encryption *EncryptionOptions) error
+}
+
+// RepoController creates, connects to and opens the kopia repository
+type RepoController interface {
+	Connect(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions) error
+	ConnectAPIServer(ctx context.Context, configFile string, si *repo.APIServerInfo, password string, options *repo.ConnectOptions) error
+	Initialize(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string) error
+	Open(ctx context.Context, configFile string, password string, options *repo.Options) (repo.Repository, error)
+	WriteSession(ctx context.Context, r repo.Repository, opt repo.WriteSessionOptions, cb func(ctx context.Context, w repo.RepositoryWriter) error) error
+}
+
+// PolicyManager writes the kopia policies of the snapshot sources
+type PolicyManager interface {
+	SetPolicy(ctx context.Context, r repo.RepositoryWriter, si snapshot.SourceInfo, pol *policy.Policy) error
+}
+
+// Keyring stores the secrets of the service by user in the keyring of the os
+type Keyring interface {
+	Get(service string, user string) (string, error)
+	Set(service string, user string, password string) error
+	Delete(service string, user string) error
+}
+
+// SetStorageFactory replaces the storage functions of the options with the ones of the factory
+func (op *Options) SetStorageFactory(factory StorageFactory) {
+	op.S3New = factory.NewS3
+	op.B2New = factory.NewB2
+	op.FilesystemNew = factory.NewFilesystem
+	op.S3BucketPolicy = factory.BucketPolicy
+	op.S3BucketSettings = factory.BucketSettings
+	op.S3CheckEncryption = factory.CheckEncryption
+}
+
+// SetRepoController replaces the kopia repository functions of the options with the ones of the controller
+func (op *Options) SetRepoController(controller RepoController) {
+	op.RepoConnect = controller.Connect
+	op.RepoConnectAPIServer = controller.ConnectAPIServer
+	op.RepoInitialize = controller.Initialize
+	op.RepoOpen = controller.Open
+	op.RepoWriteSession = controller.WriteSession
+}
+
+// SetPolicyManager replaces the kopia policy functions of the options with the ones of the manager
+func (op *Options) SetPolicyManager(manager PolicyManager) {
+	op.PolicySetPolicy = manager.SetPolicy
+}
+
+// SetKeyring replaces the keyring functions of the options with the ones of the keyring
+func (op *Options) SetKeyring(keyring Keyring) {
+	op.KeyringGet = keyring.Get
+	op.KeyringSet = keyring.Set
+	op.KeyringDelete = keyring.Delete
+}
+
+// KopiaStorageFactory is the StorageFactory of the kopia library and the S3 API
+type KopiaStorageFactory struct{}
+
+func (KopiaStorageFactory) NewS3(ctx context.Context, opt *s3.Options, createIfNotExist bool) (blob.Storage, error) {
+	return NewS3Storage(ctx, opt, createIfNotExist)
+}
+
+func (KopiaStorageFactory) NewB2(ctx context.Context, opt *b2.Options, createIfNotExist bool) (blob.Storage, error) {
+	return b2.New(ctx, opt, createIfNotExist)
+}
+
+func (KopiaStorageFactory) NewFilesystem(ctx context.Context, opt *filesystem.Options, createIfNotExist bool) (blob.Storage, error) {
+	return filesystem.New(ctx, opt, createIfNotExist)
+}
+
+func (KopiaStorageFactory) BucketPolicy(ctx context.Context, opt *s3.Options) (string, error) {
+	return GetS3BucketPolicy(ctx, opt)
+}
+
+func (KopiaStorageFactory) BucketSettings(ctx context.Context, opt *s3.Options) (*BucketSettings, error) {
+	return GetS3BucketSettings(ctx, opt)
+}
+
+func (KopiaStorageFactory) CheckEncryption(ctx context.Context, opt *s3.Options, encryption *EncryptionOptions) error {
+	return CheckS3Encryption(ctx, opt, encryption)
+}
+
+// KopiaRepoController is the RepoController of the kopia library
+type KopiaRepoController struct{}
+
+func (KopiaRepoController) Connect(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions) error {
+	return repo.Connect(ctx, configFile, st, password, options)
+}
+
+func (KopiaRepoController) ConnectAPIServer(ctx context.Context, configFile string, si *repo.APIServerInfo, password string, options *repo.ConnectOptions) error {
+	return repo.ConnectAPIServer(ctx, configFile, si, password, options)
+}
+
+func (KopiaRepoController) Initialize(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string) error {
+	return repo.Initialize(ctx, st, opt, password)
+}
+
+func (KopiaRepoController) Open(ctx context.Context, configFile string, password string, options *repo.Options) (repo.Repository, error) {
+	return repo.Open(ctx, configFile, password, options)
+}
+
+func (KopiaRepoController) WriteSession(ctx context.Context, r repo.Repository, opt repo.WriteSessionOptions, cb func(ctx context.Context, w repo.RepositoryWriter) error) error {
+	return repo.WriteSession(ctx, r, opt, cb)
+}
+
+// KopiaPolicyManager is the PolicyManager of the kopia library
+type KopiaPolicyManager struct{}
+
+func (KopiaPolicyManager) SetPolicy(ctx context.Context, r repo.RepositoryWriter, si snapshot.SourceInfo, pol *policy.Policy) error {
+	return policy.SetPolicy(ctx, r, si, pol)
+}
+
+// OSKeyring is the Keyring of the os, i.e. the keychain of macOS, the credential manager of windows and the
+// secret service of linux
+type OSKeyring struct{}
+
+func (OSKeyring) Get(service string, user string) (string, error) {
+	return keyring.Get(service, user)
+}
+
+func (OSKeyring) Set(service string, user string, password string) error {
+	return keyring.Set(service, user, password)
+}
+
+func (OSKeyring) Delete(service string, user string) error {
+	return keyring.Delete(service, user)
+}
@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// InsecureTransport returns the problems of the kopia config of the .gasset file which expose the assets in transit
+func InsecureTransport(config *Config) []string {
+	if config.Kopia == nil {
+		return nil
+	}
+
+	var problems []string
+	if apiServer := config.Kopia.APIServer; apiServer != nil && strings.HasPrefix(strings.ToLower(apiServer.BaseURL), "http://") {
+		problems = append(problems, fmt.Sprintf("the kopia API server %s is reached without TLS", apiServer.BaseURL))
+	}
+	if config.Kopia.Storage != nil {
+		if opt, ok := config.Kopia.Storage.Config.(*s3.Options); ok {
+			if opt.DoNotUseTLS {
+				problems = append(problems, fmt.Sprintf("the s3 endpoint %s is reached without TLS", opt.Endpoint))
+			} else if opt.DoNotVerifyTLS {
+				problems = append(problems, fmt.Sprintf("the TLS certificate of the s3 endpoint %s is not verified", opt.Endpoint))
+			}
+		}
+	}
+	return problems
+}
+
+// newS3Client creates a client for the provider API, set up like the kopia s3 storage does it, with the
+// temporary credentials of the role while one is assumed
+func newS3Client(opt *s3.Options) (*minio.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opt.DoNotVerifyTLS {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	creds := credentials.NewStaticV4(opt.AccessKeyID, opt.SecretAccessKey, opt.SessionToken)
+	if assumeRole := activeAssumeRole.Load(); assumeRole != nil {
+		creds = credentials.New(&assumeRoleProvider{opt: opt, assumeRole: assumeRole})
+	}
+
+	return minio.New(opt.Endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    !opt.DoNotUseTLS,
+		Region:    opt.Region,
+		Transport: transport,
+	})
+}
+
+// GetS3BucketPolicy returns the policy of the bucket, which is empty if it has none
+func GetS3BucketPolicy(ctx context.Context, opt *s3.Options) (string, error) {
+	client, err := newS3Client(opt)
+	if err != nil {
+		return "", err
+	}
+
+	bucketPolicy, err := client.GetBucketPolicy(ctx, opt.BucketName)
+	if minio.ToErrorResponse(err).Code == "NoSuchBucketPolicy" {
+		return "", nil
+	}
+	return bucketPolicy, err
+}
+
+// stringOrList is a policy element which is either a string or a list of strings
+type stringOrList []string
+
+func (l *stringOrList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = []string{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+type bucketPolicyStatement struct {
+	Effect    string          `json:"Effect"`
+	Principal json.RawMessage `json:"Principal"`
+	Action    stringOrList    `json:"Action"`
+	Resource  stringOrList    `json:"Resource"`
+	Condition json.RawMessage `json:"Condition"`
+}
+
+// isPublicPrincipal returns true for "*" and {"AWS": "*"}, which grant the statement to anonymous requests
+func (s bucketPolicyStatement) isPublicPrincipal() bool {
+	var principal string
+	if err := json.Unmarshal(s.Principal, &principal); err == nil {
+		return principal == "*"
+	}
+	var principals struct {
+		AWS stringOrList `json:"AWS"`
+	}
+	if err := json.Unmarshal(s.Principal, &principals); err != nil {
+		return false
+	}
+	for _, aws := range principals.AWS {
+		if aws == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPublicReadPolicy returns true if the bucket policy lets anonymous requests read the objects under the prefix.
+// Statements with conditions are not evaluated and considered to restrict the access.
+func IsPublicReadPolicy(bucketPolicy string, bucket string, prefix string) (bool, error) {
+	if bucketPolicy == "" {
+		return false, nil
+	}
+
+	var document struct {
+		Statement []bucketPolicyStatement `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(bucketPolicy), &document); err != nil {
+		return false, err
+	}
+
+	object := "arn:aws:s3:::" + bucket + "/" + prefix + "kopia.repository"
+	for _, statement := range document.Statement {
+		if statement.Effect != "Allow" || !statement.isPublicPrincipal() || len(statement.Condition) > 0 {
+			continue
+		}
+		if matchesAnyPolicyPattern(statement.Action, "s3:GetObject") && matchesAnyPolicyPattern(statement.Resource, object) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchesAnyPolicyPattern matches the value against the patterns of a policy, where * and ? are wildcards
+func matchesAnyPolicyPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		expression := regexp.QuoteMeta(pattern)
+		expression = strings.ReplaceAll(expression, `\*`, ".*")
+		expression = strings.ReplaceAll(expression, `\?`, ".")
+		if regexp.MustCompile("(?i)^" + expression + "$").MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInsecureTransport(t *testing.T) {
+	s3Config := func(opt *s3.Options) *Config {
+		return &Config{Kopia: &repo.LocalConfig{Storage: &blob.ConnectionInfo{Type: "s3", Config: opt}}}
+	}
+
+	assert.Empty(t, InsecureTransport(s3Config(&s3.Options{Endpoint: "s3.amazonaws.com"})))
+	assert.Equal(t, []string{"the s3 endpoint minio:9000 is reached without TLS"},
+		InsecureTransport(s3Config(&s3.Options{Endpoint: "minio:9000", DoNotUseTLS: true, DoNotVerifyTLS: true})))
+	assert.Equal(t, []string{"the TLS certificate of the s3 endpoint minio:9000 is not verified"},
+		InsecureTransport(s3Config(&s3.Options{Endpoint: "minio:9000", DoNotVerifyTLS: true})))
+	assert.Equal(t, []string{"the kopia API server http://kopia:51515 is reached without TLS"},
+		InsecureTransport(&Config{Kopia: &repo.LocalConfig{APIServer: &repo.APIServerInfo{BaseURL: "http://kopia:51515"}}}))
+}
+
+func TestIsPublicReadPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   bool
+	}{
+		{
+			name: "No policy",
+		},
+		{
+			name:   "Public read of all objects",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket-name/*"}]}`,
+			want:   true,
+		},
+		{
+			name:   "Public read with wildcard actions",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:Get*"],"Resource":["arn:aws:s3:::bucket-name/prefix/*"]}]}`,
+			want:   true,
+		},
+		{
+			name:   "Public read of another prefix",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket-name/public/*"}]}`,
+		},
+		{
+			name:   "Read of a specific account",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"s3:*","Resource":"arn:aws:s3:::bucket-name/*"}]}`,
+		},
+		{
+			name:   "Public read restricted by a condition",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket-name/*","Condition":{"IpAddress":{"aws:SourceIp":"10.0.0.0/8"}}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsPublicReadPolicy(tt.policy, "bucket-name", "prefix/")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+)
+
+// KeyringService is the service of the keyring the passwords of the repositories are stored under, by gasset id
+const KeyringService = "git-gasset"
+
+// KeyringPassword returns the password of the repository stored in the keyring, or an empty string if it has none.
+// A machine without a keyring, e.g. a CI runner without a secret service, reads the password from the .env file.
+func (op *Options) KeyringPassword() string {
+	if op.KeyringGet == nil || op.Config.GassetId == "" {
+		return ""
+	}
+	password, err := op.KeyringGet(KeyringService, op.Config.GassetId)
+	if err != nil {
+		return ""
+	}
+	return password
+}
+
+// StorePassword stores the password of the repository in the keyring, replacing the one stored already
+func (op *Options) StorePassword(password string) error {
+	if op.Config.GassetId == "" {
+		return errors.New("the .gasset file has no gasset id, run init first")
+	}
+	if password == "" {
+		return errors.New("the password is empty")
+	}
+	if err := op.KeyringSet(KeyringService, op.Config.GassetId, password); err != nil {
+		return fmt.Errorf("could not store the password in the keyring: %w", err)
+	}
+	return nil
+}
+
+// ForgetPassword removes the password of the repository from the keyring
+func (op *Options) ForgetPassword() error {
+	if op.Config.GassetId == "" {
+		return errors.New("the .gasset file has no gasset id, run init first")
+	}
+	err := op.KeyringDelete(KeyringService, op.Config.GassetId)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("the keyring has no password of the repository %s", op.Config.GassetId)
+	}
+	if err != nil {
+		return fmt.Errorf("could not remove the password from the keyring: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zalando/go-keyring"
+	"testing"
+)
+
+func TestKeyringPassword(t *testing.T) {
+	stored := map[string]string{}
+	op := &Options{
+		Config: &Config{GassetId: "0000000000"},
+		KeyringGet: func(service string, user string) (string, error) {
+			password, ok := stored[service+"/"+user]
+			if !ok {
+				return "", keyring.ErrNotFound
+			}
+			return password, nil
+		},
+		KeyringSet: func(service string, user string, password string) error {
+			stored[service+"/"+user] = password
+			return nil
+		},
+		KeyringDelete: func(service string, user string) error {
+			if _, ok := stored[service+"/"+user]; !ok {
+				return keyring.ErrNotFound
+			}
+			delete(stored, service+"/"+user)
+			return nil
+		},
+	}
+
+	assert.Empty(t, op.KeyringPassword(), "KeyringPassword() before storing")
+	assert.Error(t, op.StorePassword(""), "StorePassword() of an empty password")
+	assert.NoError(t, op.StorePassword("password"))
+	assert.Equal(t, map[string]string{KeyringService + "/0000000000": "password"}, stored)
+	assert.Equal(t, "password", op.KeyringPassword())
+
+	assert.NoError(t, op.ForgetPassword())
+	assert.Empty(t, op.KeyringPassword(), "KeyringPassword() after forgetting")
+	assert.Error(t, op.ForgetPassword(), "ForgetPassword() twice")
+
+	// A machine without a keyring falls back to the .env file
+	op.KeyringGet = func(service string, user string) (string, error) {
+		return "", errors.New("the secret service is not running")
+	}
+	assert.Empty(t, op.KeyringPassword())
+
+	op.Config.GassetId = ""
+	assert.Error(t, op.StorePassword("password"), "StorePassword() without a gasset id")
+}
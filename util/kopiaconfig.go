@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// EnvKopiaConfigPath is the variable kopia reads the path of its config from
+const EnvKopiaConfigPath = "KOPIA_CONFIG_PATH"
+
+// UsesExternalKopiaConfig tells whether gasset uses an existing kopia config instead of managing its own
+func (op *Options) UsesExternalKopiaConfig() bool {
+	return op.KopiaConfigPath != ""
+}
+
+// resolveKopiaConfigPath uses the kopia config of the .gasset file unless one is given already,
+// relative paths are relative to the root of the git repository
+func (op *Options) resolveKopiaConfigPath() {
+	if op.KopiaConfigPath != "" || op.Config.KopiaConfig == "" {
+		return
+	}
+	op.KopiaConfigPath = op.Config.KopiaConfig
+	if !filepath.IsAbs(op.KopiaConfigPath) {
+		op.KopiaConfigPath = filepath.Join(op.WorkingDirectory, op.KopiaConfigPath)
+	}
+}
+
+// CheckExternalKopiaConfig checks that the existing kopia config is connected to the repository of the .gasset file,
+// so that the snapshots are never written to another repository the kopia config happens to be connected to
+func (op *Options) CheckExternalKopiaConfig() error {
+	external, err := repo.LoadConfigFromFile(op.KopiaConfigPath)
+	if err != nil {
+		return fmt.Errorf("could not load the kopia config %s: %w", op.KopiaConfigPath, err)
+	}
+
+	expected := op.Config.Kopia
+	if expected.APIServer != nil {
+		if external.APIServer == nil || external.APIServer.BaseURL != expected.APIServer.BaseURL {
+			return fmt.Errorf("the kopia config %s is not connected to the server %s of the .gasset file", op.KopiaConfigPath, expected.APIServer.BaseURL)
+		}
+		return nil
+	}
+
+	if expected.Storage == nil || external.Storage == nil || external.Storage.Type != expected.Storage.Type {
+		return fmt.Errorf("the kopia config %s is not connected to the storage of the .gasset file", op.KopiaConfigPath)
+	}
+	switch expectedConfig := expected.Storage.Config.(type) {
+	case *s3.Options:
+		externalS3, ok := external.Storage.Config.(*s3.Options)
+		if !ok || externalS3.BucketName != expectedConfig.BucketName || externalS3.Prefix != expectedConfig.Prefix || externalS3.Endpoint != expectedConfig.Endpoint {
+			return fmt.Errorf("the kopia config %s is not connected to the bucket %s%s at %s of the .gasset file", op.KopiaConfigPath, expectedConfig.BucketName, expectedConfig.Prefix, expectedConfig.Endpoint)
+		}
+	case *b2.Options:
+		externalB2, ok := external.Storage.Config.(*b2.Options)
+		if !ok || externalB2.BucketName != expectedConfig.BucketName || externalB2.Prefix != expectedConfig.Prefix {
+			return fmt.Errorf("the kopia config %s is not connected to the b2 bucket %s%s of the .gasset file", op.KopiaConfigPath, expectedConfig.BucketName, expectedConfig.Prefix)
+		}
+	case *filesystem.Options:
+		externalFilesystem, ok := external.Storage.Config.(*filesystem.Options)
+		if !ok || filepath.Clean(externalFilesystem.Path) != filepath.Clean(expectedConfig.Path) {
+			return fmt.Errorf("the kopia config %s is not connected to the directory %s of the .gasset file", op.KopiaConfigPath, expectedConfig.Path)
+		}
+	}
+	return nil
+}
+
+// ReadPersistedPassword returns the password kopia persisted next to its config, or an empty string if it did not
+func ReadPersistedPassword(configPath string) (string, error) {
+	encoded, err := os.ReadFile(configPath + ".kopia-password")
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	password, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return "", fmt.Errorf("invalid persisted password of %s: %w", configPath, err)
+	}
+	return string(password), nil
+}
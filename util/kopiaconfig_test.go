@@ -0,0 +1,112 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/base64"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptions_CheckExternalKopiaConfig(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(config *repo.LocalConfig)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Connected to the bucket of the .gasset file",
+			modify:  func(config *repo.LocalConfig) {},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Connected to another bucket",
+			modify: func(config *repo.LocalConfig) {
+				config.Storage.Config.(*s3.Options).BucketName = "other-bucket"
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "Connected to a server instead",
+			modify: func(config *repo.LocalConfig) {
+				config.Storage = nil
+				config.APIServer = &repo.APIServerInfo{BaseURL: "https://kopia.example.com"}
+			},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			external := options.OptionsWithGassetId.Clone()
+			tt.modify(external.Config.Kopia)
+
+			op := options.OptionsWithGassetId.Clone()
+			op.KopiaConfigPath = filepath.Join(t.TempDir(), "repository.config")
+			if err := WriteTempKopiaConfig(op.KopiaConfigPath, external.Config); err != nil {
+				t.FailNow()
+			}
+
+			tt.wantErr(t, op.CheckExternalKopiaConfig())
+
+			kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+			assert.NoError(t, err)
+			assert.Equal(t, op.KopiaConfigPath, kopiaUserConfigPath)
+		})
+	}
+}
+
+func TestReadPersistedPassword(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "repository.config")
+
+	password, err := ReadPersistedPassword(configPath)
+	assert.NoError(t, err)
+	assert.Empty(t, password)
+
+	if err := os.WriteFile(configPath+".kopia-password", []byte(base64.StdEncoding.EncodeToString([]byte("secret"))), 0o600); err != nil {
+		t.FailNow()
+	}
+	password, err = ReadPersistedPassword(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", password)
+}
+
+func TestOptions_resolveKopiaConfigPath(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+
+	op := options.OptionsWithGassetId.Clone()
+	op.Config.KopiaConfig = "kopia/repository.config"
+	op.resolveKopiaConfigPath()
+	assert.Equal(t, filepath.Join(op.WorkingDirectory, "kopia", "repository.config"), op.KopiaConfigPath)
+
+	op = options.OptionsWithGassetId.Clone()
+	op.Config.KopiaConfig = "kopia/repository.config"
+	op.KopiaConfigPath = "/flag/repository.config"
+	op.resolveKopiaConfigPath()
+	assert.Equal(t, "/flag/repository.config", op.KopiaConfigPath)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// KopiaDebug passes the internal logs of kopia into the log of gasset and collects the repositories
+// opened while it is enabled, whose internal metrics are logged when the command ends
+type KopiaDebug struct {
+	logger logging.LoggerFactory
+
+	mu           sync.Mutex
+	repositories []repo.Repository
+}
+
+// EnableKopiaDebug logs the internal logs of kopia, including every storage access, to w.
+// The logs of the storage are the ones of the repositories opened from now on.
+func EnableKopiaDebug(op *Options, w io.Writer) *KopiaDebug {
+	debug := &KopiaDebug{logger: logging.ToWriter(w)}
+
+	connect := op.RepoConnect
+	op.RepoConnect = func(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions) error {
+		return connect(debug.Context(ctx), configFile, st, password, options)
+	}
+	initialize := op.RepoInitialize
+	op.RepoInitialize = func(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string) error {
+		return initialize(debug.Context(ctx), st, opt, password)
+	}
+	open := op.RepoOpen
+	op.RepoOpen = func(ctx context.Context, configFile string, password string, options *repo.Options) (repo.Repository, error) {
+		traced := repo.Options{}
+		if options != nil {
+			traced = *options
+		}
+		traced.TraceStorage = true
+		rep, err := open(debug.Context(ctx), configFile, password, &traced)
+		if err == nil {
+			debug.mu.Lock()
+			debug.repositories = append(debug.repositories, rep)
+			debug.mu.Unlock()
+		}
+		return rep, err
+	}
+	writeSession := op.RepoWriteSession
+	op.RepoWriteSession = func(ctx context.Context, r repo.Repository, opt repo.WriteSessionOptions, cb func(ctx context.Context, w repo.RepositoryWriter) error) error {
+		return writeSession(debug.Context(ctx), r, opt, cb)
+	}
+	return debug
+}
+
+// Context returns the context passing the logs of kopia to the log of gasset. A nil KopiaDebug returns ctx.
+func (d *KopiaDebug) Context(ctx context.Context) context.Context {
+	if d == nil {
+		return ctx
+	}
+	return logging.WithLogger(ctx, d.logger)
+}
+
+// LogMetrics logs the internal metrics of the repositories opened while it was enabled, e.g. the
+// durations of the content writes and the cache hits. A nil KopiaDebug logs nothing.
+func (d *KopiaDebug) LogMetrics() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ctx := d.Context(context.Background())
+	for _, rep := range d.repositories {
+		// The metrics registry of kopia is internal, it is only reachable through the methods of the repository
+		metrics := reflect.ValueOf(rep).MethodByName("Metrics")
+		if !metrics.IsValid() {
+			continue
+		}
+		registry := metrics.Call(nil)[0]
+		if logMetrics := registry.MethodByName("Log"); logMetrics.IsValid() {
+			logMetrics.Call([]reflect.Value{reflect.ValueOf(ctx)})
+		}
+	}
+}
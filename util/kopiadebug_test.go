@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKopiaDebug(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+
+	if _, err := SetupFakeRepository(ctx, op, t.TempDir()); !assert.NoError(t, err) {
+		return
+	}
+	var buf bytes.Buffer
+	debug := EnableKopiaDebug(op, &buf)
+
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rep.Close(ctx)
+
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		_, err := w.PutManifest(ctx, map[string]string{"type": "test"}, map[string]string{})
+		return err
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, buf.String(), "PutBlob", "the storage accesses are logged")
+
+	buf.Reset()
+	debug.LogMetrics()
+	assert.NotEmpty(t, buf.String(), "the metrics are logged")
+
+	// Without --kopia-debug nothing is logged
+	var disabled *KopiaDebug
+	assert.Equal(t, ctx, disabled.Context(ctx))
+	disabled.LogMetrics()
+}
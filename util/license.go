@@ -0,0 +1,148 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// LicenseFileName is the sidecar manifest holding the license metadata of a directory and its files.
+// It lives next to the assets so that it is snapshotted together with them.
+const LicenseFileName = ".gasset-license.json"
+
+// UnknownLicense groups the entries of the report without a license
+const UnknownLicense = "UNKNOWN"
+
+type LicenseInfo struct {
+	License string `json:"license,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Author  string `json:"author,omitempty"`
+}
+
+// LicenseManifest applies to the directory it is in and all the subdirectories without their own manifest.
+// Files lists the files of the directory with their own license.
+type LicenseManifest struct {
+	LicenseInfo
+	Files map[string]LicenseInfo `json:"files,omitempty"`
+}
+
+// LicenseEntry is the license of a directory or file, with a slash separated path relative to the snapshot root
+type LicenseEntry struct {
+	Path string
+	LicenseInfo
+}
+
+func ReadLicenseManifest(r io.Reader) (*LicenseManifest, error) {
+	manifest := &LicenseManifest{}
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// LoadLicenseManifest returns the license manifest of a local directory or an empty one if it has none
+func LoadLicenseManifest(dir string) (*LicenseManifest, error) {
+	file, err := os.Open(filepath.Join(dir, LicenseFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &LicenseManifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadLicenseManifest(file)
+}
+
+func SaveLicenseManifest(dir string, manifest *LicenseManifest, permissions *PermissionOptions) error {
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return permissions.WriteFile(filepath.Join(dir, LicenseFileName), manifestBytes)
+}
+
+// SetLicense records the license of a local directory or file in the sidecar manifest of its directory
+func SetLicense(assetPath string, info LicenseInfo, permissions *PermissionOptions) error {
+	stat, err := os.Stat(assetPath)
+	if err != nil {
+		return err
+	}
+
+	dir := assetPath
+	if !stat.IsDir() {
+		dir = filepath.Dir(assetPath)
+	}
+
+	manifest, err := LoadLicenseManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	if stat.IsDir() {
+		manifest.LicenseInfo = info
+	} else {
+		if manifest.Files == nil {
+			manifest.Files = map[string]LicenseInfo{}
+		}
+		manifest.Files[filepath.Base(assetPath)] = info
+	}
+
+	return SaveLicenseManifest(dir, manifest, permissions)
+}
+
+// LicenseEntries returns the entries of a manifest found in the directory at the slash separated dirPath
+func (m *LicenseManifest) LicenseEntries(dirPath string) []LicenseEntry {
+	var entries []LicenseEntry
+	if m.LicenseInfo != (LicenseInfo{}) {
+		entries = append(entries, LicenseEntry{Path: dirPath, LicenseInfo: m.LicenseInfo})
+	}
+	for name, info := range m.Files {
+		entries = append(entries, LicenseEntry{Path: path.Join(dirPath, name), LicenseInfo: info})
+	}
+	return entries
+}
+
+// GroupLicenses groups the entries by license, sorted by license and path
+func GroupLicenses(entries []LicenseEntry) ([]string, map[string][]LicenseEntry) {
+	groups := map[string][]LicenseEntry{}
+	for _, entry := range entries {
+		license := entry.License
+		if license == "" {
+			license = UnknownLicense
+		}
+		groups[license] = append(groups[license], entry)
+	}
+
+	licenses := make([]string, 0, len(groups))
+	for license, group := range groups {
+		licenses = append(licenses, license)
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Path < group[j].Path
+		})
+	}
+	sort.Strings(licenses)
+
+	return licenses, groups
+}
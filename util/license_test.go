@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetLicense(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "wall.png")
+	if err := os.WriteFile(file, []byte("png"), 0644); err != nil {
+		t.FailNow()
+	}
+
+	directoryLicense := LicenseInfo{License: "CC-BY-4.0", Source: "https://example.com/pack"}
+	fileLicense := LicenseInfo{License: "Proprietary", Author: "Vendor"}
+
+	assert.NoError(t, SetLicense(dir, directoryLicense, nil))
+	assert.NoError(t, SetLicense(file, fileLicense, nil))
+
+	manifest, err := LoadLicenseManifest(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &LicenseManifest{
+		LicenseInfo: directoryLicense,
+		Files:       map[string]LicenseInfo{"wall.png": fileLicense},
+	}, manifest)
+	assert.ElementsMatch(t, []LicenseEntry{
+		{Path: "textures", LicenseInfo: directoryLicense},
+		{Path: "textures/wall.png", LicenseInfo: fileLicense},
+	}, manifest.LicenseEntries("textures"))
+}
+
+func TestGroupLicenses(t *testing.T) {
+	entries := []LicenseEntry{
+		{Path: "textures/b.png", LicenseInfo: LicenseInfo{License: "MIT"}},
+		{Path: "sounds", LicenseInfo: LicenseInfo{Source: "https://example.com"}},
+		{Path: "textures/a.png", LicenseInfo: LicenseInfo{License: "MIT"}},
+	}
+
+	licenses, groups := GroupLicenses(entries)
+	assert.Equal(t, []string{"MIT", UnknownLicense}, licenses)
+	assert.Equal(t, []LicenseEntry{entries[2], entries[0]}, groups["MIT"])
+	assert.Equal(t, []LicenseEntry{entries[1]}, groups[UnknownLicense])
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"slices"
+	"strings"
+)
+
+// BucketSettings are the settings of a bucket which decide if it can keep a kopia repository safely
+type BucketSettings struct {
+	// Versioning is Enabled, Suspended or empty if it was never enabled
+	Versioning string
+	// Lifecycle is nil if the bucket has no lifecycle rules
+	Lifecycle *lifecycle.Configuration
+}
+
+// GetS3BucketSettings reads the versioning and the lifecycle rules of the bucket through the provider API
+func GetS3BucketSettings(ctx context.Context, opt *s3.Options) (*BucketSettings, error) {
+	client, err := newS3Client(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	versioning, err := client.GetBucketVersioning(ctx, opt.BucketName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the versioning: %w", err)
+	}
+
+	rules, err := client.GetBucketLifecycle(ctx, opt.BucketName)
+	if minio.ToErrorResponse(err).Code == "NoSuchLifecycleConfiguration" {
+		rules, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the lifecycle rules: %w", err)
+	}
+
+	return &BucketSettings{Versioning: versioning.Status, Lifecycle: rules}, nil
+}
+
+type AdviceLevel string
+
+const (
+	// AdviceDanger is a setting which corrupts the repository or makes it unreadable
+	AdviceDanger AdviceLevel = "danger"
+	// AdviceWarning is a setting which costs more than it needs to
+	AdviceWarning AdviceLevel = "warning"
+	AdviceInfo    AdviceLevel = "info"
+)
+
+type BucketAdvice struct {
+	Level   AdviceLevel
+	Message string
+}
+
+// archiveStorageClasses can't be read without restoring the objects first, which kopia doesn't do
+var archiveStorageClasses = []string{"GLACIER", "DEEP_ARCHIVE"}
+
+// appliesToPrefix returns true if an enabled rule applies to some of the objects under the prefix.
+// kopia doesn't tag its blobs so rules filtering by tags never apply.
+func appliesToPrefix(rule lifecycle.Rule, prefix string) bool {
+	if !strings.EqualFold(rule.Status, "Enabled") || !rule.RuleFilter.Tag.IsEmpty() || len(rule.RuleFilter.And.Tags) > 0 {
+		return false
+	}
+	rulePrefix := rule.Prefix
+	if rule.RuleFilter.Prefix != "" {
+		rulePrefix = rule.RuleFilter.Prefix
+	} else if rule.RuleFilter.And.Prefix != "" {
+		rulePrefix = rule.RuleFilter.And.Prefix
+	}
+	return strings.HasPrefix(prefix, rulePrefix) || strings.HasPrefix(rulePrefix, prefix)
+}
+
+// AdviseBucket returns the problems of the bucket settings for a kopia repository under the prefix, the most severe first
+func AdviseBucket(settings *BucketSettings, prefix string) []BucketAdvice {
+	var dangers, warnings, infos []BucketAdvice
+	expiresNoncurrent := false
+	abortsUploads := false
+
+	if settings.Lifecycle != nil {
+		for _, rule := range settings.Lifecycle.Rules {
+			if !appliesToPrefix(rule, prefix) {
+				continue
+			}
+
+			switch {
+			case !rule.Expiration.IsDaysNull():
+				dangers = append(dangers, BucketAdvice{AdviceDanger, fmt.Sprintf("rule %q expires the blobs after %d days, which deletes blobs the snapshots still need and corrupts the repository", rule.ID, rule.Expiration.Days)})
+			case !rule.Expiration.IsDateNull():
+				dangers = append(dangers, BucketAdvice{AdviceDanger, fmt.Sprintf("rule %q expires the blobs on %s, which deletes blobs the snapshots still need and corrupts the repository", rule.ID, rule.Expiration.Date.Format("2006-01-02"))})
+			case rule.Expiration.DeleteAll.IsEnabled():
+				dangers = append(dangers, BucketAdvice{AdviceDanger, fmt.Sprintf("rule %q expires all versions of the blobs, which corrupts the repository", rule.ID)})
+			}
+
+			storageClass := strings.ToUpper(rule.Transition.StorageClass)
+			if !rule.Transition.IsNull() && slices.Contains(archiveStorageClasses, storageClass) {
+				dangers = append(dangers, BucketAdvice{AdviceDanger, fmt.Sprintf("rule %q moves the blobs to %s, which kopia can't read without restoring them first", rule.ID, storageClass)})
+			}
+
+			if !rule.NoncurrentVersionExpiration.IsDaysNull() {
+				expiresNoncurrent = true
+			}
+			if !rule.AbortIncompleteMultipartUpload.IsDaysNull() {
+				abortsUploads = true
+			}
+		}
+	}
+
+	switch {
+	case strings.EqualFold(settings.Versioning, "Enabled") && !expiresNoncurrent:
+		warnings = append(warnings, BucketAdvice{AdviceWarning, "versioning keeps the blobs deleted by maintenance as noncurrent versions which are billed forever, add a rule expiring noncurrent versions"})
+	case !strings.EqualFold(settings.Versioning, "Enabled"):
+		infos = append(infos, BucketAdvice{AdviceInfo, "versioning is disabled, with versioning and a rule expiring noncurrent versions blobs deleted by mistake can be recovered"})
+	}
+	if !abortsUploads {
+		infos = append(infos, BucketAdvice{AdviceInfo, "no rule aborts incomplete multipart uploads, the parts of interrupted uploads are billed until they are"})
+	}
+
+	return append(append(dangers, warnings...), infos...)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdviseBucket(t *testing.T) {
+	abortUploads := lifecycle.Rule{ID: "abort", Status: "Enabled", AbortIncompleteMultipartUpload: lifecycle.AbortIncompleteMultipartUpload{DaysAfterInitiation: 7}}
+	expireNoncurrent := lifecycle.Rule{ID: "noncurrent", Status: "Enabled", NoncurrentVersionExpiration: lifecycle.NoncurrentVersionExpiration{NoncurrentDays: 30}}
+
+	tests := []struct {
+		name     string
+		settings *BucketSettings
+		want     []BucketAdvice
+	}{
+		{
+			name: "Versioning with noncurrent expiration and aborted uploads",
+			settings: &BucketSettings{
+				Versioning: "Enabled",
+				Lifecycle:  &lifecycle.Configuration{Rules: []lifecycle.Rule{abortUploads, expireNoncurrent}},
+			},
+			want: nil,
+		},
+		{
+			name:     "No versioning and no rules",
+			settings: &BucketSettings{},
+			want: []BucketAdvice{
+				{AdviceInfo, "versioning is disabled, with versioning and a rule expiring noncurrent versions blobs deleted by mistake can be recovered"},
+				{AdviceInfo, "no rule aborts incomplete multipart uploads, the parts of interrupted uploads are billed until they are"},
+			},
+		},
+		{
+			name:     "Versioning without noncurrent expiration",
+			settings: &BucketSettings{Versioning: "Enabled", Lifecycle: &lifecycle.Configuration{Rules: []lifecycle.Rule{abortUploads}}},
+			want: []BucketAdvice{
+				{AdviceWarning, "versioning keeps the blobs deleted by maintenance as noncurrent versions which are billed forever, add a rule expiring noncurrent versions"},
+			},
+		},
+		{
+			name: "Expiration and archive transition of the repository prefix",
+			settings: &BucketSettings{Versioning: "Enabled", Lifecycle: &lifecycle.Configuration{Rules: []lifecycle.Rule{
+				abortUploads,
+				expireNoncurrent,
+				{ID: "cleanup", Status: "Enabled", Expiration: lifecycle.Expiration{Days: 90}},
+				{ID: "archive", Status: "Enabled", RuleFilter: lifecycle.Filter{Prefix: "gasset/"}, Transition: lifecycle.Transition{Days: 30, StorageClass: "GLACIER"}},
+			}}},
+			want: []BucketAdvice{
+				{AdviceDanger, `rule "cleanup" expires the blobs after 90 days, which deletes blobs the snapshots still need and corrupts the repository`},
+				{AdviceDanger, `rule "archive" moves the blobs to GLACIER, which kopia can't read without restoring them first`},
+			},
+		},
+		{
+			name: "Rules of other prefixes, tags or disabled do not apply",
+			settings: &BucketSettings{Versioning: "Enabled", Lifecycle: &lifecycle.Configuration{Rules: []lifecycle.Rule{
+				abortUploads,
+				expireNoncurrent,
+				{ID: "logs", Status: "Enabled", RuleFilter: lifecycle.Filter{Prefix: "logs/"}, Expiration: lifecycle.Expiration{Days: 7}},
+				{ID: "tagged", Status: "Enabled", RuleFilter: lifecycle.Filter{Tag: lifecycle.Tag{Key: "temp", Value: "true"}}, Expiration: lifecycle.Expiration{Days: 7}},
+				{ID: "disabled", Status: "Disabled", Expiration: lifecycle.Expiration{Days: 7}},
+			}}},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AdviseBucket(tt.settings, "gasset/project/"))
+		})
+	}
+}
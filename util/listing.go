@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kopia/kopia/repo/manifest"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListingMaxAge is how long the cached listing is used for completion before the repository is listed again
+const ListingMaxAge = 5 * time.Minute
+
+// SnapshotListing caches the paths in the latest snapshot of every asset directory so that
+// shell completion can offer files that only exist in the snapshots without opening the repository.
+type SnapshotListing struct {
+	UpdatedAt time.Time             `json:"updatedAt"`
+	Dirs      map[string]DirListing `json:"dirs"`
+}
+
+// DirListing holds the slash separated paths relative to the working directory, directories end with a slash
+type DirListing struct {
+	Snapshot manifest.ID `json:"snapshot"`
+	Paths    []string    `json:"paths"`
+}
+
+func (op *Options) GetListingCachePath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "listing-"+op.Config.GassetId+".json"), nil
+}
+
+// LoadListingCache returns the cached listing or an empty one if there is none
+func (op *Options) LoadListingCache() (*SnapshotListing, error) {
+	listingPath, err := op.GetListingCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	listingBytes, err := os.ReadFile(listingPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &SnapshotListing{Dirs: map[string]DirListing{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &SnapshotListing{}
+	if err := json.Unmarshal(listingBytes, listing); err != nil {
+		return nil, err
+	}
+	if listing.Dirs == nil {
+		listing.Dirs = map[string]DirListing{}
+	}
+	return listing, nil
+}
+
+func (op *Options) SaveListingCache(listing *SnapshotListing) error {
+	listingPath, err := op.GetListingCachePath()
+	if err != nil {
+		return err
+	}
+
+	listingBytes, err := json.Marshal(listing)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(listingPath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(listingPath, listingBytes, 0o600)
+}
+
+// IsFresh returns true if the listing was updated within ListingMaxAge
+func (l *SnapshotListing) IsFresh(now time.Time) bool {
+	return now.Sub(l.UpdatedAt) < ListingMaxAge
+}
+
+// Complete returns the paths matching the prefix, collapsed to the next path segment like shells do for local files
+func (l *SnapshotListing) Complete(toComplete string) []string {
+	toComplete = strings.TrimPrefix(filepath.ToSlash(toComplete), "./")
+
+	seen := map[string]bool{}
+	var candidates []string
+	for _, dirListing := range l.Dirs {
+		for _, listedPath := range dirListing.Paths {
+			if !strings.HasPrefix(listedPath, toComplete) {
+				continue
+			}
+			candidate := listedPath
+			if i := strings.Index(listedPath[len(toComplete):], "/"); i >= 0 {
+				candidate = listedPath[:len(toComplete)+i+1]
+			}
+			if !seen[candidate] {
+				seen[candidate] = true
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSnapshotListingComplete(t *testing.T) {
+	listing := &SnapshotListing{
+		Dirs: map[string]DirListing{
+			"./assets": {Paths: []string{"assets/", "assets/textures/", "assets/textures/wall.png", "assets/textures/floor.png", "assets/theme.ogg"}},
+			"./models": {Paths: []string{"models/", "models/tree.fbx"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		toComplete string
+		want       []string
+	}{
+		{
+			name:       "Complete the asset directories",
+			toComplete: "",
+			want:       []string{"assets/", "models/"},
+		},
+		{
+			name:       "Complete the next segment only",
+			toComplete: "assets/t",
+			want:       []string{"assets/textures/", "assets/theme.ogg"},
+		},
+		{
+			name:       "Complete a relative path",
+			toComplete: "./assets/textures/f",
+			want:       []string{"assets/textures/floor.png"},
+		},
+		{
+			name:       "Complete nothing for unknown paths",
+			toComplete: "sounds/",
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, listing.Complete(tt.toComplete), "Complete(%v)", tt.toComplete)
+		})
+	}
+}
+
+func TestSnapshotListingCache(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+	options := testOptions.OptionsWithGassetId.Clone()
+	userDir := t.TempDir()
+	options.OsUserConfigDir = func() (string, error) {
+		return userDir, nil
+	}
+
+	listing, err := options.LoadListingCache()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, listing.IsFresh(time.Now()))
+
+	listing.UpdatedAt = time.Now().Truncate(time.Second)
+	listing.Dirs["./assets"] = DirListing{Snapshot: "k1", Paths: []string{"assets/", "assets/wall.png"}}
+	if !assert.NoError(t, options.SaveListingCache(listing)) {
+		return
+	}
+
+	loaded, err := options.LoadListingCache()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, loaded.IsFresh(time.Now()))
+	assert.Equal(t, listing.Dirs, loaded.Dirs)
+}
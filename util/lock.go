@@ -0,0 +1,178 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kopia/kopia/repo/manifest"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockFileName is the file next to the .gasset file recording the snapshots the git repository refers to.
+// It is committed to git together with the .gasset file. With lockFilePerDir the hashes of every asset
+// directory are recorded in a lock file of the same name in the directory instead.
+const LockFileName = ".gasset.lock"
+
+type LockFile struct {
+	Archives      []ArchiveEntry `json:"archives,omitempty"`
+	Changesets    []Changeset    `json:"changesets,omitempty"`
+	WorkingHashes *LockHashes    `json:"workingHashes,omitempty"`
+}
+
+// LockHashes are the hashes of the working files when they were last snapshotted, by their slash separated
+// path relative to the root of the git repository. Sizes are missing in lock files recorded before they were added.
+type LockHashes struct {
+	Algorithm string            `json:"algorithm"`
+	Files     map[string]string `json:"files"`
+	Sizes     map[string]int64  `json:"sizes,omitempty"`
+}
+
+// RecordDir replaces the hashes of the files in an asset directory, dropping the recorded ones of another algorithm
+func (l *LockFile) RecordDir(algorithm string, dirPath string, hashes map[string]WorkingHash) {
+	if l.WorkingHashes == nil || l.WorkingHashes.Algorithm != algorithm {
+		l.WorkingHashes = &LockHashes{Algorithm: algorithm, Files: map[string]string{}}
+	}
+	l.WorkingHashes.dropDir(dirPath)
+	if l.WorkingHashes.Sizes == nil {
+		l.WorkingHashes.Sizes = map[string]int64{}
+	}
+	for filePath, fileHash := range hashes {
+		l.WorkingHashes.Files[filePath] = fileHash.Hash
+		l.WorkingHashes.Sizes[filePath] = fileHash.Size
+	}
+}
+
+// dropDir removes the files in an asset directory
+func (h *LockHashes) dropDir(dirPath string) {
+	for filePath := range h.Files {
+		if inAssetDir(filePath, dirPath) {
+			delete(h.Files, filePath)
+			delete(h.Sizes, filePath)
+		}
+	}
+}
+
+// ArchiveEntry is the final snapshot of an asset directory which was removed from the .gasset file
+type ArchiveEntry struct {
+	Dir        string      `json:"dir"`
+	Source     string      `json:"source"`
+	Snapshot   manifest.ID `json:"snapshot"`
+	ArchivedAt time.Time   `json:"archivedAt"`
+}
+
+// LoadLockFile returns the lock file in the directory or an empty one if it has none
+func LoadLockFile(path string) (*LockFile, error) {
+	lockBytes, err := os.ReadFile(filepath.Join(path, LockFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &LockFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ReadLockFile(lockBytes)
+}
+
+func ReadLockFile(lockBytes []byte) (*LockFile, error) {
+	lock := &LockFile{}
+	if err := json.Unmarshal(lockBytes, lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// LoadLockFiles returns the lock file next to the .gasset file merged with the ones of the asset directories,
+// so that the lock files are read the same whether they are split per directory or not
+func LoadLockFiles(root string, dirs []string) (*LockFile, error) {
+	return MergeLockFiles(dirs, func(dirPath string) (*LockFile, error) {
+		return LoadLockFile(filepath.Join(root, dirPath))
+	})
+}
+
+// MergeLockFiles merges the lock file next to the .gasset file, loaded from the directory ".", with the ones of the asset directories
+func MergeLockFiles(dirs []string, load func(dirPath string) (*LockFile, error)) (*LockFile, error) {
+	lock, err := load(".")
+	if err != nil {
+		return nil, err
+	}
+	for _, dirPath := range dirs {
+		dirLock, err := load(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		// The lock file of the directory replaces the hashes recorded before the lock files were split
+		if dirLock.WorkingHashes != nil && lock.WorkingHashes != nil && dirLock.WorkingHashes.Algorithm == lock.WorkingHashes.Algorithm {
+			lock.WorkingHashes.dropDir(dirPath)
+		}
+		lock.Merge(dirLock)
+	}
+	return lock, nil
+}
+
+// Merge adds the entries of another lock file. Hashes of another algorithm than the ones already merged are left out.
+func (l *LockFile) Merge(other *LockFile) {
+	l.Archives = append(l.Archives, other.Archives...)
+	l.Changesets = append(l.Changesets, other.Changesets...)
+	if other.WorkingHashes == nil {
+		return
+	}
+	if l.WorkingHashes == nil {
+		l.WorkingHashes = &LockHashes{Algorithm: other.WorkingHashes.Algorithm, Files: map[string]string{}}
+	}
+	if l.WorkingHashes.Algorithm != other.WorkingHashes.Algorithm {
+		return
+	}
+	for filePath, fileHash := range other.WorkingHashes.Files {
+		l.WorkingHashes.Files[filePath] = fileHash
+	}
+	for filePath, size := range other.WorkingHashes.Sizes {
+		if l.WorkingHashes.Sizes == nil {
+			l.WorkingHashes.Sizes = map[string]int64{}
+		}
+		l.WorkingHashes.Sizes[filePath] = size
+	}
+}
+
+// LockFileDir returns the directory of the lock file recording the hashes of an asset directory
+func (c *Config) LockFileDir(root string, dirPath string) string {
+	if c.LockFilePerDir {
+		return filepath.Join(root, dirPath)
+	}
+	return root
+}
+
+func SaveLockFile(path string, lock *LockFile, permissions *PermissionOptions) error {
+	lockBytes, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return permissions.WriteFile(filepath.Join(path, LockFileName), append(lockBytes, '\n'))
+}
+
+// DirHashes returns the hashes of the files in an asset directory by their path, which DiffFiles compares
+func (h *LockHashes) DirHashes(dirPath string) map[string]string {
+	hashes := map[string]string{}
+	for filePath, fileHash := range h.Files {
+		if inAssetDir(filePath, dirPath) {
+			hashes[filePath] = fileHash
+		}
+	}
+	return hashes
+}
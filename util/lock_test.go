@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := LoadLockFile(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &LockFile{}, lock)
+
+	lock.Archives = append(lock.Archives, ArchiveEntry{
+		Dir:        "./old",
+		Source:     "user@host-pc:/gasset/old",
+		Snapshot:   "abc",
+		ArchivedAt: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+	})
+	if !assert.NoError(t, SaveLockFile(dir, lock, nil)) {
+		return
+	}
+
+	loaded, err := LoadLockFile(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, lock, loaded)
+}
+
+func TestLoadLockFiles(t *testing.T) {
+	root := t.TempDir()
+	config := &Config{LockFilePerDir: true}
+	textures := config.LockFileDir(root, "./textures")
+	assert.Equal(t, filepath.Join(root, "textures"), textures)
+
+	// Hashes recorded in the lock file of the root before the lock files were split
+	rootLock := &LockFile{
+		Archives:      []ArchiveEntry{{Dir: "./old", Snapshot: "abc"}},
+		WorkingHashes: &LockHashes{Algorithm: HashXXH64, Files: map[string]string{"textures/removed.png": "1", "sounds/a.wav": "2"}},
+	}
+	texturesLock := &LockFile{
+		WorkingHashes: &LockHashes{Algorithm: HashXXH64, Files: map[string]string{"textures/wall.png": "3"}},
+	}
+	modelsLock := &LockFile{
+		WorkingHashes: &LockHashes{Algorithm: HashBLAKE3, Files: map[string]string{"models/a.fbx": "4"}},
+	}
+	for dir, lock := range map[string]*LockFile{root: rootLock, textures: texturesLock, filepath.Join(root, "models"): modelsLock} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.FailNow()
+		}
+		if err := SaveLockFile(dir, lock, nil); err != nil {
+			t.FailNow()
+		}
+	}
+
+	lock, err := LoadLockFiles(root, []string{"./textures", "./sounds", "./models"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, rootLock.Archives, lock.Archives)
+	assert.Equal(t, &LockHashes{
+		Algorithm: HashXXH64,
+		Files:     map[string]string{"textures/wall.png": "3", "sounds/a.wav": "2"},
+	}, lock.WorkingHashes)
+}
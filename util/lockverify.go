@@ -0,0 +1,251 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kopia/kopia/repo/manifest"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// LockIssue is a problem found in a lock file, e.g. after a git merge. Fixed issues were resolved
+// in the lock file while the others have to be resolved by hand.
+type LockIssue struct {
+	Problem string
+	Fixed   bool
+}
+
+// LockSnapshotRef is a snapshot a lock file refers to
+type LockSnapshotRef struct {
+	// Entry describes the entry of the lock file referring to the snapshot
+	Entry    string
+	Snapshot manifest.ID
+	// Source is the source the snapshot must have been taken of, empty if any
+	Source string
+}
+
+var (
+	conflictStart  = []byte("<<<<<<<")
+	conflictBase   = []byte("|||||||")
+	conflictMiddle = []byte("=======")
+	conflictEnd    = []byte(">>>>>>>")
+)
+
+// ReadMergedLockFile reads a lock file which may still hold the conflict markers of a git merge.
+// Both sides of the conflicts are read and merged, the issues report what could not be merged.
+func ReadMergedLockFile(lockBytes []byte) (*LockFile, []LockIssue, error) {
+	ours, theirs, conflicted, err := splitConflictSides(lockBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !conflicted {
+		lock, err := ReadLockFile(lockBytes)
+		return lock, nil, err
+	}
+
+	oursLock, err := ReadLockFile(ours)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read our side of the merge conflict: %w", err)
+	}
+	theirsLock, err := ReadLockFile(theirs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read their side of the merge conflict: %w", err)
+	}
+
+	issues := []LockIssue{{Problem: "the merge conflict was resolved by merging both sides", Fixed: true}}
+	if oursLock.WorkingHashes != nil && theirsLock.WorkingHashes != nil {
+		if oursLock.WorkingHashes.Algorithm != theirsLock.WorkingHashes.Algorithm {
+			issues = append(issues, LockIssue{Problem: fmt.Sprintf("the sides of the merge record %s and %s hashes, the %s ones were kept, run snap to record them again",
+				oursLock.WorkingHashes.Algorithm, theirsLock.WorkingHashes.Algorithm, oursLock.WorkingHashes.Algorithm)})
+		} else {
+			for _, filePath := range sortedKeys(oursLock.WorkingHashes.Files) {
+				theirHash, ok := theirsLock.WorkingHashes.Files[filePath]
+				if ok && theirHash != oursLock.WorkingHashes.Files[filePath] {
+					issues = append(issues, LockIssue{Problem: fmt.Sprintf("the sides of the merge record different hashes of %s, ours was kept, run snap to record it again", filePath)})
+				}
+			}
+		}
+	}
+
+	// Our hashes are merged last so that they win over theirs
+	lock := &LockFile{}
+	lock.Merge(&LockFile{WorkingHashes: theirsLock.WorkingHashes})
+	lock.Merge(&LockFile{WorkingHashes: oursLock.WorkingHashes})
+	// The entries both sides kept from before the merge are only taken once
+	lock.Archives = oursLock.Archives
+	for _, entry := range theirsLock.Archives {
+		if !slices.ContainsFunc(lock.Archives, func(kept ArchiveEntry) bool { return reflect.DeepEqual(kept, entry) }) {
+			lock.Archives = append(lock.Archives, entry)
+		}
+	}
+	lock.Changesets = oursLock.Changesets
+	for _, changeset := range theirsLock.Changesets {
+		if !slices.ContainsFunc(lock.Changesets, func(kept Changeset) bool { return reflect.DeepEqual(kept, changeset) }) {
+			lock.Changesets = append(lock.Changesets, changeset)
+		}
+	}
+	sort.SliceStable(lock.Archives, func(i, j int) bool {
+		return lock.Archives[i].ArchivedAt.Before(lock.Archives[j].ArchivedAt)
+	})
+	sort.SliceStable(lock.Changesets, func(i, j int) bool {
+		return lock.Changesets[i].CreatedAt.Before(lock.Changesets[j].CreatedAt)
+	})
+	return lock, issues, nil
+}
+
+// splitConflictSides returns the lock file as it is on our and their side of the git merge conflicts, if it has any.
+// The base of a diff3 conflict is left out.
+func splitConflictSides(lockBytes []byte) ([]byte, []byte, bool, error) {
+	const (
+		common = iota
+		oursSide
+		baseSide
+		theirsSide
+	)
+	var ours, theirs bytes.Buffer
+	state, conflicted := common, false
+	for _, line := range bytes.SplitAfter(lockBytes, []byte("\n")) {
+		switch {
+		case bytes.HasPrefix(line, conflictStart) && state == common:
+			state, conflicted = oursSide, true
+		case bytes.HasPrefix(line, conflictBase) && state == oursSide:
+			state = baseSide
+		case bytes.HasPrefix(line, conflictMiddle) && (state == oursSide || state == baseSide):
+			state = theirsSide
+		case bytes.HasPrefix(line, conflictEnd) && state == theirsSide:
+			state = common
+		case state == common:
+			ours.Write(line)
+			theirs.Write(line)
+		case state == oursSide:
+			ours.Write(line)
+		case state == theirsSide:
+			theirs.Write(line)
+		}
+	}
+	if state != common {
+		return nil, nil, false, fmt.Errorf("the lock file has an unterminated merge conflict")
+	}
+	return ours.Bytes(), theirs.Bytes(), conflicted, nil
+}
+
+// Dedupe removes the archive entries recorded twice and merges the changesets recorded twice with the same id,
+// e.g. by both branches of a merge
+func (l *LockFile) Dedupe() []LockIssue {
+	var issues []LockIssue
+
+	var archives []ArchiveEntry
+	for _, entry := range l.Archives {
+		if slices.ContainsFunc(archives, func(kept ArchiveEntry) bool {
+			return kept.Dir == entry.Dir && kept.Snapshot == entry.Snapshot
+		}) {
+			issues = append(issues, LockIssue{Problem: fmt.Sprintf("the archive of %s as snapshot %s was recorded twice", entry.Dir, entry.Snapshot), Fixed: true})
+			continue
+		}
+		archives = append(archives, entry)
+	}
+	l.Archives = archives
+
+	var changesets []Changeset
+	for _, changeset := range l.Changesets {
+		index := slices.IndexFunc(changesets, func(kept Changeset) bool {
+			return kept.ID == changeset.ID
+		})
+		if index < 0 {
+			changesets = append(changesets, changeset)
+			continue
+		}
+		issues = append(issues, changesets[index].merge(changeset)...)
+	}
+	l.Changesets = changesets
+
+	return issues
+}
+
+// merge adds the snapshots of a changeset recorded twice, keeping the ones of the changeset on conflicts
+func (c *Changeset) merge(other Changeset) []LockIssue {
+	issues := []LockIssue{{Problem: fmt.Sprintf("the changeset %s was recorded twice", c.ID), Fixed: true}}
+	if other.Name != c.Name {
+		issues = append(issues, LockIssue{Problem: fmt.Sprintf("the changeset %s is named both %q and %q", c.ID, c.Name, other.Name)})
+	}
+	if other.CreatedAt.Before(c.CreatedAt) {
+		c.CreatedAt = other.CreatedAt
+	}
+	snapshots := make(map[string]manifest.ID, len(c.Snapshots)+len(other.Snapshots))
+	for dirPath, id := range c.Snapshots {
+		snapshots[dirPath] = id
+	}
+	for _, dirPath := range sortedKeys(other.Snapshots) {
+		id, ok := snapshots[dirPath]
+		if !ok {
+			snapshots[dirPath] = other.Snapshots[dirPath]
+		} else if id != other.Snapshots[dirPath] {
+			issues = append(issues, LockIssue{Problem: fmt.Sprintf("the changeset %s records both snapshot %s and %s of %s", c.ID, id, other.Snapshots[dirPath], dirPath)})
+		}
+	}
+	c.Snapshots = snapshots
+	return issues
+}
+
+// DropStrayHashes removes the hashes of the files outside the given asset directories,
+// which no snap records, and the sizes of the files without a hash
+func (l *LockFile) DropStrayHashes(dirs []string) []LockIssue {
+	if l.WorkingHashes == nil {
+		return nil
+	}
+	var issues []LockIssue
+	for _, filePath := range sortedKeys(l.WorkingHashes.Files) {
+		if !slices.ContainsFunc(dirs, func(dirPath string) bool { return inAssetDir(filePath, dirPath) }) {
+			delete(l.WorkingHashes.Files, filePath)
+			delete(l.WorkingHashes.Sizes, filePath)
+			issues = append(issues, LockIssue{Problem: fmt.Sprintf("the hash of %s is not of a file in an asset directory", filePath), Fixed: true})
+		}
+	}
+	for _, filePath := range sortedKeys(l.WorkingHashes.Sizes) {
+		if _, ok := l.WorkingHashes.Files[filePath]; !ok {
+			delete(l.WorkingHashes.Sizes, filePath)
+			issues = append(issues, LockIssue{Problem: fmt.Sprintf("the size of %s has no hash", filePath), Fixed: true})
+		}
+	}
+	return issues
+}
+
+// SnapshotRefs returns the snapshots the archive entries and the changesets refer to
+func (l *LockFile) SnapshotRefs() []LockSnapshotRef {
+	var refs []LockSnapshotRef
+	for _, entry := range l.Archives {
+		refs = append(refs, LockSnapshotRef{Entry: "the archive of " + entry.Dir, Snapshot: entry.Snapshot, Source: entry.Source})
+	}
+	for _, changeset := range l.Changesets {
+		for _, dirPath := range sortedKeys(changeset.Snapshots) {
+			refs = append(refs, LockSnapshotRef{Entry: fmt.Sprintf("the changeset %s of %s", changeset.ID, dirPath), Snapshot: changeset.Snapshots[dirPath]})
+		}
+	}
+	return refs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReadMergedLockFile(t *testing.T) {
+	merged := `{
+  "changesets": [
+    {
+      "id": "20240601-100000-aaaaaa",
+      "createdAt": "2024-06-01T10:00:00Z",
+<<<<<<< HEAD
+      "snapshots": {"./assets": "k1"}
+    },
+    {
+      "id": "20240603-100000-cccccc",
+      "createdAt": "2024-06-03T10:00:00Z",
+      "snapshots": {"./assets": "k3"}
+    }
+  ],
+  "workingHashes": {"algorithm": "sha256", "files": {"assets/a.png": "ours", "assets/b.png": "b"}}
+||||||| base
+      "snapshots": {"./assets": "k1"}
+    }
+  ]
+=======
+      "snapshots": {"./assets": "k1"}
+    },
+    {
+      "id": "20240602-100000-bbbbbb",
+      "createdAt": "2024-06-02T10:00:00Z",
+      "snapshots": {"./sounds": "k2"}
+    }
+  ],
+  "workingHashes": {"algorithm": "sha256", "files": {"assets/a.png": "theirs", "sounds/c.wav": "c"}}
+>>>>>>> feature
+}
+`
+	lock, issues, err := ReadMergedLockFile([]byte(merged))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var ids []string
+	for _, changeset := range lock.Changesets {
+		ids = append(ids, changeset.ID)
+	}
+	assert.Equal(t, []string{"20240601-100000-aaaaaa", "20240602-100000-bbbbbb", "20240603-100000-cccccc"}, ids)
+	assert.Equal(t, map[string]string{"assets/a.png": "ours", "assets/b.png": "b", "sounds/c.wav": "c"}, lock.WorkingHashes.Files)
+	assert.Equal(t, []LockIssue{
+		{Problem: "the merge conflict was resolved by merging both sides", Fixed: true},
+		{Problem: "the sides of the merge record different hashes of assets/a.png, ours was kept, run snap to record it again"},
+	}, issues)
+	assert.Empty(t, lock.Dedupe(), "the changeset both sides kept is only taken once")
+
+	_, _, err = ReadMergedLockFile([]byte("{\n<<<<<<< HEAD\n}\n"))
+	assert.Error(t, err, "ReadMergedLockFile() with an unterminated conflict")
+}
+
+func TestLockFileDedupe(t *testing.T) {
+	archivedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	lock := &LockFile{
+		Archives: []ArchiveEntry{
+			{Dir: "./old", Snapshot: "k1", ArchivedAt: archivedAt},
+			{Dir: "./old", Snapshot: "k1", ArchivedAt: archivedAt},
+			{Dir: "./old", Snapshot: "k2", ArchivedAt: archivedAt.Add(time.Hour)},
+		},
+		Changesets: []Changeset{
+			{ID: "c", Name: "drop", CreatedAt: archivedAt.Add(time.Hour), Snapshots: map[string]manifest.ID{"./assets": "k3"}},
+			{ID: "c", Name: "drop", CreatedAt: archivedAt, Snapshots: map[string]manifest.ID{"./assets": "k4", "./sounds": "k5"}},
+		},
+	}
+
+	issues := lock.Dedupe()
+	assert.Equal(t, []LockIssue{
+		{Problem: "the archive of ./old as snapshot k1 was recorded twice", Fixed: true},
+		{Problem: "the changeset c was recorded twice", Fixed: true},
+		{Problem: "the changeset c records both snapshot k3 and k4 of ./assets"},
+	}, issues)
+	assert.Len(t, lock.Archives, 2)
+	assert.Equal(t, []Changeset{
+		{ID: "c", Name: "drop", CreatedAt: archivedAt, Snapshots: map[string]manifest.ID{"./assets": "k3", "./sounds": "k5"}},
+	}, lock.Changesets)
+	assert.Empty(t, lock.Dedupe())
+}
+
+func TestDropStrayHashes(t *testing.T) {
+	lock := &LockFile{WorkingHashes: &LockHashes{
+		Algorithm: "sha256",
+		Files:     map[string]string{"assets/a.png": "a", "old/b.png": "b"},
+		Sizes:     map[string]int64{"assets/a.png": 1, "old/b.png": 2, "assets/c.png": 3},
+	}}
+
+	issues := lock.DropStrayHashes([]string{"./assets"})
+	assert.Equal(t, []LockIssue{
+		{Problem: "the hash of old/b.png is not of a file in an asset directory", Fixed: true},
+		{Problem: "the size of assets/c.png has no hash", Fixed: true},
+	}, issues)
+	assert.Equal(t, map[string]string{"assets/a.png": "a"}, lock.WorkingHashes.Files)
+	assert.Equal(t, map[string]int64{"assets/a.png": 1}, lock.WorkingHashes.Sizes)
+
+	assert.Equal(t, []LockSnapshotRef{{Entry: "the changeset c of ./assets", Snapshot: "k1"}},
+		(&LockFile{Changesets: []Changeset{{ID: "c", Snapshots: map[string]manifest.ID{"./assets": "k1"}}}}).SnapshotRefs())
+}
@@ -0,0 +1,170 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"log"
+	"sort"
+	"time"
+)
+
+// MaintenanceLockManifestType labels the advisory locks taken in the kopia repository by the commands
+// deleting or rewriting snapshots, so that two users never run them concurrently
+const MaintenanceLockManifestType = "gasset-maintenance-lock"
+
+// DefaultMaintenanceLockTTL is how long a lock is held when its command dies without releasing it
+const DefaultMaintenanceLockTTL = time.Hour
+
+var ErrMaintenanceLocked = errors.New("the repository is locked for maintenance")
+
+type MaintenanceLock struct {
+	ID         manifest.ID `json:"-"`
+	Command    string      `json:"command"`
+	User       string      `json:"user"`
+	Host       string      `json:"host"`
+	AcquiredAt time.Time   `json:"acquiredAt"`
+	ExpiresAt  time.Time   `json:"expiresAt"`
+}
+
+type MaintenanceLockOptions struct {
+	// TTL is how long the lock is held at most, DefaultMaintenanceLockTTL if zero
+	TTL time.Duration
+	// Steal removes the locks of other users which did not expire yet
+	Steal bool
+}
+
+func (l *MaintenanceLock) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+func (l *MaintenanceLock) String() string {
+	return fmt.Sprintf("%s by %s@%s since %s", l.Command, l.User, l.Host, l.AcquiredAt.Format(time.RFC3339))
+}
+
+// ListMaintenanceLocks returns the maintenance locks of the repository including the expired ones, oldest first
+func ListMaintenanceLocks(ctx context.Context, rep repo.Repository) ([]*MaintenanceLock, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{manifest.TypeLabelKey: MaintenanceLockManifestType})
+	if err != nil {
+		return nil, err
+	}
+
+	locks := make([]*MaintenanceLock, 0, len(entries))
+	for _, entry := range entries {
+		lock := &MaintenanceLock{}
+		if _, err := rep.GetManifest(ctx, entry.ID, lock); err != nil {
+			return nil, err
+		}
+		lock.ID = entry.ID
+		locks = append(locks, lock)
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		if !locks[i].AcquiredAt.Equal(locks[j].AcquiredAt) {
+			return locks[i].AcquiredAt.Before(locks[j].AcquiredAt)
+		}
+		return locks[i].ID < locks[j].ID
+	})
+	return locks, nil
+}
+
+// AcquireMaintenanceLock takes the maintenance lock of the repository for a command. Expired locks are
+// removed while the ones still held by others fail with ErrMaintenanceLocked unless they are stolen.
+func (op *Options) AcquireMaintenanceLock(ctx context.Context, rep repo.Repository, command string, lockOptions MaintenanceLockOptions, now time.Time) (*MaintenanceLock, error) {
+	ttl := lockOptions.TTL
+	if ttl <= 0 {
+		ttl = DefaultMaintenanceLockTTL
+	}
+
+	clientOptions := op.ClientOptions()
+	lock := &MaintenanceLock{
+		Command:    command,
+		User:       clientOptions.Username,
+		Host:       clientOptions.Hostname,
+		AcquiredAt: now.UTC(),
+		ExpiresAt:  now.Add(ttl).UTC(),
+	}
+
+	locks, err := ListMaintenanceLocks(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+	var stale []*MaintenanceLock
+	for _, existing := range locks {
+		if !existing.Expired(now) && !lockOptions.Steal {
+			return nil, fmt.Errorf("%w: %s, expiring at %s", ErrMaintenanceLocked, existing, existing.ExpiresAt.Format(time.RFC3339))
+		}
+		if !existing.Expired(now) {
+			log.Printf("Warning: stealing the maintenance lock of %s", existing)
+		}
+		stale = append(stale, existing)
+	}
+
+	err = op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Acquire maintenance lock"),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		for _, existing := range stale {
+			if err := writer.DeleteManifest(ctx, existing.ID); err != nil {
+				return err
+			}
+		}
+		lock.ID, err = writer.PutManifest(ctx, map[string]string{
+			manifest.TypeLabelKey: MaintenanceLockManifestType,
+			"user":                lock.User,
+			"host":                lock.Host,
+		}, lock)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Two users may have written their locks at the same time, the oldest one wins
+	if err := rep.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	locks, err = ListMaintenanceLocks(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range locks {
+		if existing.Expired(now) {
+			continue
+		}
+		if existing.ID == lock.ID {
+			break
+		}
+		if err := op.ReleaseMaintenanceLock(ctx, rep, lock); err != nil {
+			log.Printf("Warning: could not release the maintenance lock: %v", err)
+		}
+		return nil, fmt.Errorf("%w: %s, expiring at %s", ErrMaintenanceLocked, existing, existing.ExpiresAt.Format(time.RFC3339))
+	}
+	return lock, nil
+}
+
+// ReleaseMaintenanceLock removes a lock taken with AcquireMaintenanceLock
+func (op *Options) ReleaseMaintenanceLock(ctx context.Context, rep repo.Repository, lock *MaintenanceLock) error {
+	return op.RepoWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: op.SessionPurpose("Release maintenance lock"),
+	}, func(ctx context.Context, writer repo.RepositoryWriter) error {
+		return writer.DeleteManifest(ctx, lock.ID)
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAcquireMaintenanceLock(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+	if _, err := SetupFakeRepository(ctx, op, t.TempDir()); err != nil {
+		t.FailNow()
+	}
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if err != nil {
+		t.FailNow()
+	}
+	rep := openRepository(t, ctx, op, kopiaUserConfigPath)
+
+	other := op.Clone()
+	if err := other.SetMachineIdentity("ci-agent"); err != nil {
+		t.FailNow()
+	}
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	lock, err := op.AcquireMaintenanceLock(ctx, rep, "prune", MaintenanceLockOptions{}, now)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, now.Add(DefaultMaintenanceLockTTL), lock.ExpiresAt)
+
+	_, err = other.AcquireMaintenanceLock(ctx, rep, "purge-file", MaintenanceLockOptions{}, now.Add(time.Minute))
+	assert.ErrorIs(t, err, ErrMaintenanceLocked)
+	assert.ErrorContains(t, err, "prune by user@host-pc")
+
+	// The lock of a command which died expires
+	expired, err := other.AcquireMaintenanceLock(ctx, rep, "prune", MaintenanceLockOptions{TTL: time.Minute}, now.Add(2*time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stolen, err := op.AcquireMaintenanceLock(ctx, rep, "prune", MaintenanceLockOptions{Steal: true}, now.Add(2*time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+	locks, err := ListMaintenanceLocks(ctx, rep)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, locks, 1) {
+		assert.Equal(t, stolen.ID, locks[0].ID)
+		assert.NotEqual(t, expired.ID, locks[0].ID)
+	}
+
+	assert.NoError(t, op.ReleaseMaintenanceLock(ctx, rep, stolen))
+	locks, err = ListMaintenanceLocks(ctx, rep)
+	assert.NoError(t, err)
+	assert.Empty(t, locks)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/snapshot"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TagMetricPrefix prefixes the tags of the snapshot manifests holding the metrics of the analyzers
+const TagMetricPrefix = "tag:metric-"
+
+// EnvMetricsDir is the asset directory an analyzer is run for, relative to the working directory
+const EnvMetricsDir = "GASSET_METRICS_DIR"
+
+// The metrics of every snapshot, taken from the statistics of the manifest
+const (
+	MetricFiles = "files"
+	MetricDirs  = "dirs"
+	MetricBytes = "bytes"
+)
+
+var metricNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// MetricAnalyzer is a command computing metrics of an asset directory when it is snapshotted, e.g. the total
+// triangles of the meshes or the texture resolution. It is run in the working directory with the asset
+// directory as its last argument and prints a JSON object of metric names and numbers, which are recorded
+// in the snapshot as <name>.<metric>.
+type MetricAnalyzer struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+}
+
+// AnalyzeDir runs the analyzers of the .gasset file for an asset directory. A failing analyzer doesn't stop
+// the others, their metrics are returned together with the errors.
+func (op *Options) AnalyzeDir(ctx context.Context, dirPath string) (map[string]float64, error) {
+	metrics := map[string]float64{}
+	var errs []error
+	for _, analyzer := range op.Config.Metrics {
+		analyzed, err := op.runAnalyzer(ctx, analyzer, dirPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("analyzer %s: %w", analyzer.Name, err))
+			continue
+		}
+		for name, value := range analyzed {
+			metrics[analyzer.Name+"."+name] = value
+		}
+	}
+	return metrics, errors.Join(errs...)
+}
+
+func (op *Options) runAnalyzer(ctx context.Context, analyzer MetricAnalyzer, dirPath string) (map[string]float64, error) {
+	if !metricNamePattern.MatchString(analyzer.Name) {
+		return nil, fmt.Errorf("invalid name, use lowercase letters, digits and underscores")
+	}
+	if len(analyzer.Command) == 0 {
+		return nil, errors.New("command is empty")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, analyzer.Command[0], append(analyzer.Command[1:], dirPath)...)
+	cmd.Dir = op.WorkingDirectory
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), EnvMetricsDir+"="+dirPath)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	metrics := map[string]float64{}
+	if err := json.Unmarshal(stdout.Bytes(), &metrics); err != nil {
+		return nil, fmt.Errorf("the output is not a JSON object of numbers: %w", err)
+	}
+	for name := range metrics {
+		if !metricNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid metric name %q, use lowercase letters, digits and underscores", name)
+		}
+	}
+	return metrics, nil
+}
+
+// MetricTags returns the tags recording the metrics in a snapshot manifest
+func MetricTags(metrics map[string]float64) map[string]string {
+	tags := make(map[string]string, len(metrics))
+	for name, value := range metrics {
+		tags[TagMetricPrefix+name] = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return tags
+}
+
+// SnapshotMetrics returns the metrics recorded in a snapshot together with the ones of its statistics
+func SnapshotMetrics(man *snapshot.Manifest) map[string]float64 {
+	metrics := map[string]float64{
+		MetricFiles: float64(man.Stats.TotalFileCount),
+		MetricDirs:  float64(man.Stats.TotalDirectoryCount),
+		MetricBytes: float64(man.Stats.TotalFileSize),
+	}
+	for key, value := range man.Tags {
+		name, ok := strings.CutPrefix(key, TagMetricPrefix)
+		if !ok {
+			continue
+		}
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			metrics[name] = parsed
+		}
+	}
+	return metrics
+}
+
+// MetricNames returns the names of the metrics of the snapshots, the ones of the statistics first
+func MetricNames(manifests []*snapshot.Manifest) []string {
+	seen := map[string]bool{}
+	var analyzed []string
+	for _, man := range manifests {
+		for key := range man.Tags {
+			if name, ok := strings.CutPrefix(key, TagMetricPrefix); ok && !seen[name] {
+				seen[name] = true
+				analyzed = append(analyzed, name)
+			}
+		}
+	}
+	sort.Strings(analyzed)
+	return append([]string{MetricFiles, MetricDirs, MetricBytes}, analyzed...)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/stretchr/testify/assert"
+	"os/exec"
+	"testing"
+)
+
+func TestAnalyzeDir(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	op := &Options{WorkingDirectory: t.TempDir(), Config: &Config{Metrics: []MetricAnalyzer{
+		{Name: "meshes", Command: []string{"sh", "-c", `printf '{"triangles": %d}' ${#1}`, "sh"}},
+		{Name: "textures", Command: []string{"sh", "-c", `echo "$` + EnvMetricsDir + `" >&2; exit 1`}},
+		{Name: "sounds", Command: []string{"sh", "-c", `echo not json`}},
+	}}}
+
+	metrics, err := op.AnalyzeDir(context.Background(), "./assets")
+	assert.Equal(t, map[string]float64{"meshes.triangles": 8}, metrics)
+	assert.ErrorContains(t, err, "analyzer textures: exit status 1: ./assets")
+	assert.ErrorContains(t, err, "analyzer sounds: the output is not a JSON object of numbers")
+}
+
+func TestSnapshotMetrics(t *testing.T) {
+	man := &snapshot.Manifest{
+		Stats: snapshot.Stats{TotalFileCount: 3, TotalDirectoryCount: 2, TotalFileSize: 1024},
+		Tags:  MetricTags(map[string]float64{"meshes.triangles": 1200, "textures.megapixels": 4.5}),
+	}
+	man.Tags[TagDerived] = "true"
+
+	assert.Equal(t, map[string]float64{
+		MetricFiles:           3,
+		MetricDirs:            2,
+		MetricBytes:           1024,
+		"meshes.triangles":    1200,
+		"textures.megapixels": 4.5,
+	}, SnapshotMetrics(man))
+	assert.Equal(t, []string{MetricFiles, MetricDirs, MetricBytes, "meshes.triangles", "textures.megapixels"}, MetricNames([]*snapshot.Manifest{man}))
+}
@@ -19,32 +19,66 @@ package util
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
 	"github.com/kopia/kopia/repo/blob/s3"
 	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"io/fs"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
+// MachineUserName is the username recorded for the snapshots taken with a machine identity
+const MachineUserName = "gasset-machine"
+
 type Options struct {
 	WorkingDirectory string
 	Config           *Config
 	Password         string
 	Storage          blob.Storage
-	GassetIdLength   int
-	OsGetwd          func() (string, error)
-	OsTempDir        func() string
-	OsUserConfigDir  func() (string, error)
-	RandIntn         func(n int) int
-	S3New            func(ctx context.Context, opt *s3.Options, createIfNotExist bool) (blob.Storage, error)
-	RepoConnect      func(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions) error
-	RepoInitialize   func(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string) error
-	RepoOpen         func(ctx context.Context, configFile string, password string, options *repo.Options) (rep repo.Repository, err error)
-	RepoWriteSession func(ctx context.Context, r repo.Repository, opt repo.WriteSessionOptions, cb func(ctx context.Context, w repo.RepositoryWriter) error) error
-	PolicySetPolicy  func(ctx context.Context, r repo.RepositoryWriter, si snapshot.SourceInfo, pol *policy.Policy) error
+	MachineIdentity  string
+	TempDirectory    string
+	KopiaConfigPath  string
+	// ConfigHome is set when GASSET_CONFIG_HOME or the .gasset.local file overrides the user config directory
+	ConfigHome string
+	// StorageURL overrides the storage of the .gasset file
+	StorageURL string
+	// GivenPassword is the password given on the command line, it is preferred to the keyring and the .env file
+	GivenPassword string
+	// NonInteractive fails on a missing input instead of prompting for it, the secrets may all be in the environment then
+	NonInteractive bool
+	// Sandbox segregates the sources of the snapshots from the ones of the asset directories
+	Sandbox              string
+	Command              string
+	LocalTime            bool
+	Reconnect            Backoff
+	GassetIdLength       int
+	OsGetwd              func() (string, error)
+	OsTempDir            func() string
+	OsUserConfigDir      func() (string, error)
+	RandIntn             func(n int) int
+	KeyringGet           func(service string, user string) (string, error)
+	KeyringSet           func(service string, user string, password string) error
+	KeyringDelete        func(service string, user string) error
+	S3New                func(ctx context.Context, opt *s3.Options, createIfNotExist bool) (blob.Storage, error)
+	B2New                func(ctx context.Context, opt *b2.Options, createIfNotExist bool) (blob.Storage, error)
+	FilesystemNew        func(ctx context.Context, opt *filesystem.Options, createIfNotExist bool) (blob.Storage, error)
+	S3BucketPolicy       func(ctx context.Context, opt *s3.Options) (string, error)
+	S3BucketSettings     func(ctx context.Context, opt *s3.Options) (*BucketSettings, error)
+	S3CheckEncryption    func(ctx context.Context, opt *s3.Options, encryption *EncryptionOptions) error
+	RepoConnect          func(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions) error
+	RepoConnectAPIServer func(ctx context.Context, configFile string, si *repo.APIServerInfo, password string, options *repo.ConnectOptions) error
+	RepoInitialize       func(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string) error
+	RepoOpen             func(ctx context.Context, configFile string, password string, options *repo.Options) (rep repo.Repository, err error)
+	RepoWriteSession     func(ctx context.Context, r repo.Repository, opt repo.WriteSessionOptions, cb func(ctx context.Context, w repo.RepositoryWriter) error) error
+	PolicySetPolicy      func(ctx context.Context, r repo.RepositoryWriter, si snapshot.SourceInfo, pol *policy.Policy) error
 }
 
 func (op *Options) InitWorkingDirectory() error {
@@ -58,7 +92,7 @@ func (op *Options) InitWorkingDirectory() error {
 		return err
 	}
 	op.WorkingDirectory = path
-	return nil
+	return op.ApplyConfigHome()
 }
 
 // ReloadKopiaConfig  saves the "kopia" section of the .gasset file and reloads it using kopia APIs.
@@ -68,10 +102,25 @@ func (op *Options) ReloadKopiaConfig() error {
 	if err != nil {
 		return err
 	}
+	return op.LoadKopiaConfig(config)
+}
+
+// LoadKopiaConfig uses the config, e.g. one not read from the .gasset file, with the kopia config and the
+// secrets resolved like ReloadKopiaConfig does
+func (op *Options) LoadKopiaConfig(config *Config) error {
 	op.Config = config
+	if err := config.Permissions.Validate(); err != nil {
+		return fmt.Errorf("permissions: %w", err)
+	}
+	if err := config.CheckDirs(); err != nil {
+		return err
+	}
+	if err := config.ResolveStorageURL(context.Background(), op.StorageURL); err != nil {
+		return err
+	}
 
-	tempPath := filepath.Join(op.OsTempDir(), "kopia.config")
-	if err = WriteTempKopiaConfig(tempPath, config); err != nil {
+	tempPath := filepath.Join(op.TempDir(), "kopia.config")
+	if err := WriteTempKopiaConfig(tempPath, config); err != nil {
 		return err
 	}
 	kopiaConfig, err := repo.LoadConfigFromFile(tempPath)
@@ -79,20 +128,79 @@ func (op *Options) ReloadKopiaConfig() error {
 		return err
 	}
 	op.Config.Kopia = kopiaConfig
+	op.resolveKopiaConfigPath()
 
-	accessKey, secretKey, password, err := LoadKopiaSecretsFromEnv(op.WorkingDirectory)
+	password := op.GivenPassword
+	if password == "" {
+		password = op.KeyringPassword()
+	}
+	secrets, err := LoadKopiaSecretsFromEnv(op.WorkingDirectory)
 	if err != nil {
-		return err
+		// An existing kopia config has the credentials of the storage, the password can be given or in the keyring
+		// and the non-interactive mode has the secrets in the environment, so the .env file is optional then
+		optional := op.UsesExternalKopiaConfig() || password != "" || op.NonInteractive
+		if !optional || !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		secrets = KopiaSecretsFromEnviron()
+	}
+	// The given password and the one in the keyring are preferred to the one of the .env file
+	if password != "" {
+		secrets.Password = password
+	}
+	if op.NonInteractive && !op.UsesExternalKopiaConfig() {
+		if err := checkNonInteractiveInputs(config, secrets); err != nil {
+			return err
+		}
+	}
+	// A kopia API server has no storage, only the password of the user on the server
+	if kopiaConfig.Storage != nil {
+		if provider, err := GetStorageProvider(kopiaConfig.Storage.Type); err == nil {
+			if err := provider.ApplySecrets(kopiaConfig.Storage.Config, secrets, config); err != nil {
+				return err
+			}
+		}
 	}
-	if typedConfig, ok := kopiaConfig.Storage.Config.(*s3.Options); ok {
-		typedConfig.AccessKeyID = accessKey
-		typedConfig.SecretAccessKey = secretKey
+	op.Password = secrets.Password
+	if config.AssumeRole != nil {
+		EnableAssumeRole(config.AssumeRole)
+	}
+	if config.Encryption != nil {
+		EnableEncryption(config.Encryption)
+	}
+
+	if op.UsesExternalKopiaConfig() {
+		if op.Password == "" {
+			if op.Password, err = ReadPersistedPassword(op.KopiaConfigPath); err != nil {
+				return err
+			}
+		}
+		return op.CheckExternalKopiaConfig()
 	}
-	op.Password = password
 	return nil
 }
 
+// TempDir returns the configured temp directory or the one of the os
+func (op *Options) TempDir() string {
+	if op.TempDirectory != "" {
+		return op.TempDirectory
+	}
+	return op.OsTempDir()
+}
+
+// StagingRoot returns the directory restored files are staged in before they are renamed into place.
+// It defaults to a directory inside .git which is on the same filesystem as the assets and ignored by git.
+func (op *Options) StagingRoot() string {
+	if op.TempDirectory != "" {
+		return op.TempDirectory
+	}
+	return filepath.Join(op.WorkingDirectory, ".git", "gasset-staging")
+}
+
 func (op *Options) GetKopiaUserConfigPath() (string, error) {
+	if op.UsesExternalKopiaConfig() {
+		return op.KopiaConfigPath, nil
+	}
 	if op.Config.GassetId == "" {
 		return "", errors.New("gasset id is empty")
 	}
@@ -103,6 +211,74 @@ func (op *Options) GetKopiaUserConfigPath() (string, error) {
 	return filepath.Join(userDir, "git-gasset", "kopia-"+op.Config.GassetId+".config"), nil
 }
 
+// SetMachineIdentity overrides the hostname and username used by kopia with a deterministic identity
+// so that snapshots from CI agents are kept apart from the ones taken by people.
+func (op *Options) SetMachineIdentity(name string) error {
+	if strings.ContainsAny(name, "@: /\\") {
+		return fmt.Errorf("invalid machine identity %q", name)
+	}
+	op.MachineIdentity = name
+	return nil
+}
+
+// ClientOptions returns the kopia client options with the machine identity applied
+func (op *Options) ClientOptions() repo.ClientOptions {
+	clientOptions := op.Config.Kopia.ClientOptions
+	if op.MachineIdentity != "" {
+		clientOptions.Hostname = op.MachineIdentity
+		clientOptions.Username = MachineUserName
+	}
+	return clientOptions
+}
+
+// SourcePath returns the path of the snapshot source of an asset directory.
+// Repositories sharing a kopia repository set a namespace so that their sources never collide,
+// otherwise the absolute path of the directory is used. The sources of a sandbox have its name appended.
+func (op *Options) SourcePath(dirPath string) string {
+	sourcePath := filepath.Join(op.WorkingDirectory, dirPath)
+	if op.Config.Namespace != "" {
+		sourcePath = path.Join("/", op.Config.Namespace, filepath.ToSlash(filepath.Clean(dirPath)))
+	}
+	if op.Sandbox != "" {
+		sourcePath += sandboxSourceSuffix + op.Sandbox
+	}
+	return sourcePath
+}
+
+// AssetDir returns the configured asset directory containing assetPath and the slash separated path relative to it
+func (op *Options) AssetDir(assetPath string) (string, string, error) {
+	if !filepath.IsAbs(assetPath) {
+		assetPath = filepath.Join(op.WorkingDirectory, assetPath)
+	}
+
+	for _, dir := range op.Config.Dirs {
+		rel, err := filepath.Rel(filepath.Join(op.WorkingDirectory, dir), assetPath)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return dir, filepath.ToSlash(rel), nil
+	}
+	return "", "", fmt.Errorf("%s is not inside any of the asset directories", assetPath)
+}
+
+// SourceInfo returns the snapshot source for an asset directory, owned by the machine identity if one is set
+func (op *Options) SourceInfo(clientOptions repo.ClientOptions, dirPath string) snapshot.SourceInfo {
+	if op.MachineIdentity != "" {
+		clientOptions.Hostname = op.MachineIdentity
+		clientOptions.Username = MachineUserName
+	}
+	return snapshot.SourceInfo{
+		Host:     clientOptions.Hostname,
+		UserName: clientOptions.Username,
+		Path:     op.SourcePath(dirPath),
+	}
+}
+
+// IsMachineSource returns true if the source was snapshotted with a machine identity
+func IsMachineSource(sourceInfo snapshot.SourceInfo) bool {
+	return sourceInfo.UserName == MachineUserName
+}
+
 func (op *Options) Clone() *Options {
 	copyKopia := func(l *repo.LocalConfig) *repo.LocalConfig {
 		var apiServer *repo.APIServerInfo
@@ -110,8 +286,6 @@ func (op *Options) Clone() *Options {
 		var caching *content.CachingOptions
 		var clientOptions repo.ClientOptions
 
-		castConfig := l.Storage.Config.(*s3.Options)
-
 		if l.APIServer != nil {
 			apiServer = &repo.APIServerInfo{
 				BaseURL:                             l.APIServer.BaseURL,
@@ -121,30 +295,9 @@ func (op *Options) Clone() *Options {
 		}
 
 		if l.Storage != nil {
-			storage = &blob.ConnectionInfo{
-				Type: l.Storage.Type,
-				Config: &s3.Options{
-					BucketName:      castConfig.BucketName,
-					Prefix:          castConfig.Prefix,
-					Endpoint:        castConfig.Endpoint,
-					DoNotUseTLS:     castConfig.DoNotUseTLS,
-					DoNotVerifyTLS:  castConfig.DoNotVerifyTLS,
-					RootCA:          castConfig.RootCA,
-					AccessKeyID:     castConfig.AccessKeyID,
-					SecretAccessKey: castConfig.SecretAccessKey,
-					SessionToken:    castConfig.SessionToken,
-					Region:          castConfig.Region,
-					Limits: throttling.Limits{
-						ReadsPerSecond:         castConfig.Limits.ReadsPerSecond,
-						WritesPerSecond:        castConfig.Limits.WritesPerSecond,
-						ListsPerSecond:         castConfig.Limits.ListsPerSecond,
-						UploadBytesPerSecond:   castConfig.Limits.UploadBytesPerSecond,
-						DownloadBytesPerSecond: castConfig.Limits.DownloadBytesPerSecond,
-						ConcurrentReads:        castConfig.Limits.ConcurrentReads,
-						ConcurrentWrites:       castConfig.Limits.ConcurrentWrites,
-					},
-					PointInTime: castConfig.PointInTime,
-				},
+			storage = &blob.ConnectionInfo{Type: l.Storage.Type, Config: l.Storage.Config}
+			if provider, err := GetStorageProvider(l.Storage.Type); err == nil {
+				storage.Config = provider.CloneConfig(l.Storage.Config)
 			}
 		}
 
@@ -196,24 +349,151 @@ func (op *Options) Clone() *Options {
 			ClientOptions: clientOptions,
 		}
 	}
+	var aws *AWSOptions
+	if op.Config.AWS != nil {
+		awsCopy := *op.Config.AWS
+		aws = &awsCopy
+	}
+	var skipIdenticalSnapshots *bool
+	if op.Config.SkipIdenticalSnapshots != nil {
+		skip := *op.Config.SkipIdenticalSnapshots
+		skipIdenticalSnapshots = &skip
+	}
+	var quota *QuotaOptions
+	if op.Config.Quota != nil {
+		quotaCopy := *op.Config.Quota
+		quota = &quotaCopy
+	}
+	var profiles map[string][]string
+	if op.Config.Profiles != nil {
+		profiles = make(map[string][]string, len(op.Config.Profiles))
+		for name, patterns := range op.Config.Profiles {
+			profiles[name] = append([]string(nil), patterns...)
+		}
+	}
+	var workingHashes *WorkingHashOptions
+	if op.Config.WorkingHashes != nil {
+		workingHashesCopy := *op.Config.WorkingHashes
+		workingHashes = &workingHashesCopy
+	}
+	var derived *DerivedOptions
+	if op.Config.Derived != nil {
+		derivedCopy := *op.Config.Derived
+		derivedCopy.Dirs = append([]string(nil), op.Config.Derived.Dirs...)
+		derived = &derivedCopy
+	}
+	var permissions *PermissionOptions
+	if op.Config.Permissions != nil {
+		permissionsCopy := *op.Config.Permissions
+		permissions = &permissionsCopy
+	}
+	var metrics []MetricAnalyzer
+	for _, analyzer := range op.Config.Metrics {
+		metrics = append(metrics, MetricAnalyzer{Name: analyzer.Name, Command: append([]string(nil), analyzer.Command...)})
+	}
+	var throttlingOptions *ThrottlingOptions
+	if op.Config.Throttling != nil {
+		throttlingOptions = &ThrottlingOptions{}
+		if op.Config.Throttling.Snap != nil {
+			snap := *op.Config.Throttling.Snap
+			throttlingOptions.Snap = &snap
+		}
+		if op.Config.Throttling.Restore != nil {
+			restore := *op.Config.Throttling.Restore
+			throttlingOptions.Restore = &restore
+		}
+	}
+	var thumbnails *ThumbnailOptions
+	if op.Config.Thumbnails != nil {
+		thumbnailsCopy := *op.Config.Thumbnails
+		thumbnails = &thumbnailsCopy
+	}
+	var peers *PeerOptions
+	if op.Config.Peers != nil {
+		peersCopy := *op.Config.Peers
+		peersCopy.Peers = append([]string(nil), op.Config.Peers.Peers...)
+		peers = &peersCopy
+	}
+	var assumeRole *AssumeRoleOptions
+	if op.Config.AssumeRole != nil {
+		assumeRoleCopy := *op.Config.AssumeRole
+		assumeRole = &assumeRoleCopy
+	}
+	var encryption *EncryptionOptions
+	if op.Config.Encryption != nil {
+		encryptionCopy := *op.Config.Encryption
+		encryption = &encryptionCopy
+	}
+	var restoreHooks []RestoreHook
+	for _, hook := range op.Config.RestoreHooks {
+		restoreHooks = append(restoreHooks, RestoreHook{Dir: hook.Dir, Command: append([]string(nil), hook.Command...)})
+	}
 	return &Options{
 		WorkingDirectory: op.WorkingDirectory,
 		Config: &Config{
-			Kopia:    copyKopia(op.Config.Kopia),
-			GassetId: op.Config.GassetId,
+			Kopia:                  copyKopia(op.Config.Kopia),
+			KopiaConfig:            op.Config.KopiaConfig,
+			Storage:                op.Config.Storage,
+			GassetId:               op.Config.GassetId,
+			Namespace:              op.Config.Namespace,
+			Dirs:                   append([]string(nil), op.Config.Dirs...),
+			AllowNestedDirs:        op.Config.AllowNestedDirs,
+			RestoreHooks:           restoreHooks,
+			AWS:                    aws,
+			GitTracked:             op.Config.GitTracked,
+			DeferRetention:         op.Config.DeferRetention,
+			SkipIdenticalSnapshots: skipIdenticalSnapshots,
+			IfChanged:              op.Config.IfChanged,
+			Quota:                  quota,
+			Ignore:                 append([]string(nil), op.Config.Ignore...),
+			Compression:            op.Config.Compression,
+			Profiles:               profiles,
+			WorkingHashes:          workingHashes,
+			Derived:                derived,
+			LockFilePerDir:         op.Config.LockFilePerDir,
+			Permissions:            permissions,
+			TrackRestores:          op.Config.TrackRestores,
+			Archival:               op.Config.Archival,
+			Renames:                append([]DirRename(nil), op.Config.Renames...),
+			Metrics:                metrics,
+			Throttling:             throttlingOptions,
+			Thumbnails:             thumbnails,
+			Peers:                  peers,
+			AssumeRole:             assumeRole,
+			Encryption:             encryption,
 		},
-		Password:         op.Password,
-		Storage:          op.Storage,
-		GassetIdLength:   op.GassetIdLength,
-		OsGetwd:          op.OsGetwd,
-		OsTempDir:        op.OsTempDir,
-		OsUserConfigDir:  op.OsUserConfigDir,
-		RandIntn:         op.RandIntn,
-		S3New:            op.S3New,
-		RepoConnect:      op.RepoConnect,
-		RepoInitialize:   op.RepoInitialize,
-		RepoOpen:         op.RepoOpen,
-		RepoWriteSession: op.RepoWriteSession,
-		PolicySetPolicy:  op.PolicySetPolicy,
+		Password:             op.Password,
+		Storage:              op.Storage,
+		MachineIdentity:      op.MachineIdentity,
+		TempDirectory:        op.TempDirectory,
+		KopiaConfigPath:      op.KopiaConfigPath,
+		ConfigHome:           op.ConfigHome,
+		StorageURL:           op.StorageURL,
+		GivenPassword:        op.GivenPassword,
+		NonInteractive:       op.NonInteractive,
+		Sandbox:              op.Sandbox,
+		Command:              op.Command,
+		LocalTime:            op.LocalTime,
+		Reconnect:            op.Reconnect,
+		GassetIdLength:       op.GassetIdLength,
+		OsGetwd:              op.OsGetwd,
+		OsTempDir:            op.OsTempDir,
+		OsUserConfigDir:      op.OsUserConfigDir,
+		RandIntn:             op.RandIntn,
+		KeyringGet:           op.KeyringGet,
+		KeyringSet:           op.KeyringSet,
+		KeyringDelete:        op.KeyringDelete,
+		S3New:                op.S3New,
+		B2New:                op.B2New,
+		FilesystemNew:        op.FilesystemNew,
+		S3BucketPolicy:       op.S3BucketPolicy,
+		S3BucketSettings:     op.S3BucketSettings,
+		S3CheckEncryption:    op.S3CheckEncryption,
+		RepoConnect:          op.RepoConnect,
+		RepoConnectAPIServer: op.RepoConnectAPIServer,
+		RepoInitialize:       op.RepoInitialize,
+		RepoOpen:             op.RepoOpen,
+		RepoWriteSession:     op.RepoWriteSession,
+		PolicySetPolicy:      op.PolicySetPolicy,
 	}
 }
@@ -18,6 +18,12 @@ package util
 
 import (
 	"fmt"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/sharded"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/kopia/kopia/snapshot"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"testing"
@@ -65,9 +71,26 @@ func (suite *OptionsSuite) TestInitWorkingDirectory() {
 }
 
 func (suite *OptionsSuite) TestReloadKopiaConfig() {
+	withKeyring := suite.op.OptionsWithGassetId.Clone()
+	withKeyring.KeyringGet = func(service string, user string) (string, error) {
+		return "keyring-" + user, nil
+	}
+	wantKeyring := suite.op.OptionsWithGassetId.Clone()
+	wantKeyring.Password = "keyring-" + wantKeyring.Config.GassetId
+	withGivenPassword := withKeyring.Clone()
+	withGivenPassword.GivenPassword = "given"
+	withGivenPassword.NonInteractive = true
+	wantGivenPassword := suite.op.OptionsWithGassetId.Clone()
+	wantGivenPassword.Password = "given"
+	withKeyringNonInteractive := withKeyring.Clone()
+	withKeyringNonInteractive.NonInteractive = true
+	withoutKeyringNonInteractive := suite.op.OptionsWithGassetId.Clone()
+	withoutKeyringNonInteractive.NonInteractive = true
+
 	tests := []struct {
 		name    string
 		fields  Options
+		env     map[string]string
 		want    Options
 		wantErr assert.ErrorAssertionFunc
 	}{
@@ -77,11 +100,42 @@ func (suite *OptionsSuite) TestReloadKopiaConfig() {
 			want:    *suite.op.OptionsWithGassetId,
 			wantErr: assert.NoError,
 		},
+		{
+			name:    "Prefer the password of the keyring to the one of the .env file",
+			fields:  *withKeyring,
+			want:    *wantKeyring,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Prefer the given password to the one of the keyring",
+			fields:  *withGivenPassword,
+			want:    *wantGivenPassword,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Accept the password of the keyring in the non-interactive mode",
+			fields:  *withKeyringNonInteractive,
+			env:     map[string]string{EnvPassword: ""},
+			want:    *wantKeyring,
+			wantErr: assert.NoError,
+		},
+		{
+			name:   "Fail without any password in the non-interactive mode",
+			fields: *withoutKeyringNonInteractive,
+			env:    map[string]string{EnvPassword: ""},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "the password from --password-stdin or the keyring", i...)
+			},
+		},
 	}
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
+			// An empty variable hides the one of the .env file, which doesn't override the environment
+			for name, value := range tt.env {
+				suite.T().Setenv(name, value)
+			}
 			err := tt.fields.ReloadKopiaConfig()
-			if !tt.wantErr(suite.T(), err, fmt.Sprintf("reloadKopiaConfig()")) {
+			if !tt.wantErr(suite.T(), err, fmt.Sprintf("reloadKopiaConfig()")) || err != nil {
 				return
 			}
 			assert.Equalf(suite.T(), tt.want.Config, tt.fields.Config, fmt.Sprintf("initWorkingDirectory()"))
@@ -120,3 +174,154 @@ func (suite *OptionsSuite) TestGetKopiaUserConfigPath() {
 		})
 	}
 }
+
+func (suite *OptionsSuite) TestSourceInfo() {
+	assetsPath := HandleAbsolutePath(suite.op.TestWorkingDirectory, "../mocks/assets")
+
+	tests := []struct {
+		name            string
+		machineIdentity string
+		namespace       string
+		sandbox         string
+		want            snapshot.SourceInfo
+		wantErr         assert.ErrorAssertionFunc
+	}{
+		{
+			name:            "Use the kopia client options without a machine identity",
+			machineIdentity: "",
+			want:            snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: assetsPath},
+			wantErr:         assert.NoError,
+		},
+		{
+			name:            "Use the machine identity",
+			machineIdentity: "ci-agent-1",
+			want:            snapshot.SourceInfo{Host: "ci-agent-1", UserName: MachineUserName, Path: assetsPath},
+			wantErr:         assert.NoError,
+		},
+		{
+			name:            "Use the namespace of a shared repository",
+			machineIdentity: "",
+			namespace:       "shared-library",
+			want:            snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: "/shared-library/assets"},
+			wantErr:         assert.NoError,
+		},
+		{
+			name:            "Keep the sources of a sandbox apart",
+			machineIdentity: "",
+			sandbox:         "pipeline-test",
+			want:            snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: assetsPath + "#sandbox-pipeline-test"},
+			wantErr:         assert.NoError,
+		},
+		{
+			name:            "Reject a machine identity which is not a valid hostname",
+			machineIdentity: "user@ci-agent",
+			wantErr:         assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			op := suite.op.OptionsWithGassetId.Clone()
+			op.Config.Namespace = tt.namespace
+			op.Sandbox = tt.sandbox
+			err := op.SetMachineIdentity(tt.machineIdentity)
+			if !tt.wantErr(suite.T(), err, fmt.Sprintf("SetMachineIdentity(%v)", tt.machineIdentity)) || err != nil {
+				return
+			}
+			got := op.SourceInfo(op.Config.Kopia.ClientOptions, "./assets")
+			assert.Equalf(suite.T(), tt.want, got, "SourceInfo(%v)", "./assets")
+			assert.Equalf(suite.T(), tt.machineIdentity != "", IsMachineSource(got), "IsMachineSource(%v)", got)
+		})
+	}
+}
+
+func (suite *OptionsSuite) TestAssetDir() {
+	tests := []struct {
+		name      string
+		assetPath string
+		wantDir   string
+		wantRel   string
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "Find the asset directory of a relative path",
+			assetPath: "assets/textures/wall.png",
+			wantDir:   "./assets",
+			wantRel:   "textures/wall.png",
+			wantErr:   assert.NoError,
+		},
+		{
+			name:      "Reject a path outside of the asset directories",
+			assetPath: "src/main.go",
+			wantErr:   assert.Error,
+		},
+		{
+			name:      "Reject the asset directory itself",
+			assetPath: "assets",
+			wantErr:   assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			gotDir, gotRel, err := suite.op.OptionsWithGassetId.AssetDir(tt.assetPath)
+			if !tt.wantErr(suite.T(), err, fmt.Sprintf("AssetDir(%v)", tt.assetPath)) || err != nil {
+				return
+			}
+			assert.Equalf(suite.T(), tt.wantDir, gotDir, "AssetDir(%v)", tt.assetPath)
+			assert.Equalf(suite.T(), tt.wantRel, gotRel, "AssetDir(%v)", tt.assetPath)
+		})
+	}
+}
+
+func (suite *OptionsSuite) TestClone() {
+	op := suite.op.OptionsWithGassetId.Clone()
+	op.Config.WorkingHashes = &WorkingHashOptions{Algorithm: HashBLAKE3, InLockFile: true}
+	op.Config.Derived = &DerivedOptions{Dirs: []string{"./bakes"}, KeepLatest: 1}
+	op.Config.Permissions = &PermissionOptions{FileMode: "0664", DirMode: "2775"}
+	op.Config.Metrics = []MetricAnalyzer{{Name: "meshes", Command: []string{"./tools/count-triangles"}}}
+	op.Config.Throttling = &ThrottlingOptions{Snap: &throttling.Limits{UploadBytesPerSecond: 1 << 20}}
+	op.Config.Thumbnails = &ThumbnailOptions{Size: 128}
+	op.Config.IfChanged = true
+	op.Config.Peers = &PeerOptions{Peers: []string{"192.168.1.20"}, Discover: true}
+	op.Config.AssumeRole = &AssumeRoleOptions{RoleARN: "arn:aws:iam::123456789012:role/assets", SessionDuration: "2h"}
+	op.Config.Encryption = &EncryptionOptions{SSE: SSEKMS, KMSKeyID: "alias/assets"}
+
+	cloned := op.Clone()
+	assert.Equal(suite.T(), op.Config.WorkingHashes, cloned.Config.WorkingHashes)
+	assert.Equal(suite.T(), op.Config.Derived, cloned.Config.Derived)
+	assert.True(suite.T(), cloned.Config.IfChanged)
+
+	cloned.Config.Derived.Dirs[0] = "./caches"
+	assert.Equal(suite.T(), []string{"./bakes"}, op.Config.Derived.Dirs)
+
+	cloned.Config.Permissions.FileMode = "0600"
+	assert.Equal(suite.T(), "0664", op.Config.Permissions.FileMode)
+
+	cloned.Config.Metrics[0].Command[0] = "./tools/count-vertices"
+	assert.Equal(suite.T(), []string{"./tools/count-triangles"}, op.Config.Metrics[0].Command)
+
+	cloned.Config.Throttling.Snap.UploadBytesPerSecond = 0
+	assert.Equal(suite.T(), float64(1<<20), op.Config.Throttling.Snap.UploadBytesPerSecond)
+
+	cloned.Config.Thumbnails.Size = 64
+	assert.Equal(suite.T(), 128, op.Config.Thumbnails.Size)
+
+	cloned.Config.Peers.Peers[0] = "192.168.1.21"
+	assert.Equal(suite.T(), []string{"192.168.1.20"}, op.Config.Peers.Peers)
+
+	cloned.Config.AssumeRole.SessionDuration = "1h"
+	assert.Equal(suite.T(), "2h", op.Config.AssumeRole.SessionDuration)
+	cloned.Config.Encryption.KMSKeyID = "alias/other"
+	assert.Equal(suite.T(), "alias/assets", op.Config.Encryption.KMSKeyID)
+
+	op.Config.Kopia.Storage = &blob.ConnectionInfo{Type: "b2", Config: &b2.Options{BucketName: "assets", KeyID: "keyid", Key: "key"}}
+	cloned = op.Clone()
+	assert.Equal(suite.T(), op.Config.Kopia.Storage, cloned.Config.Kopia.Storage)
+	cloned.Config.Kopia.Storage.Config.(*b2.Options).Key = "other"
+	assert.Equal(suite.T(), "key", op.Config.Kopia.Storage.Config.(*b2.Options).Key)
+
+	op.Config.Kopia.Storage = &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{Path: "/mnt/assets", Options: sharded.Options{DirectoryShards: []int{1, 3}}}}
+	cloned = op.Clone()
+	assert.Equal(suite.T(), op.Config.Kopia.Storage, cloned.Config.Kopia.Storage)
+	cloned.Config.Kopia.Storage.Config.(*filesystem.Options).DirectoryShards[0] = 2
+	assert.Equal(suite.T(), []int{1, 3}, op.Config.Kopia.Storage.Config.(*filesystem.Options).DirectoryShards)
+}
@@ -0,0 +1,517 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"io"
+	iofs "io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultPeerPort is the TCP port the peers serve the pack blobs on and the UDP port they are discovered on
+	DefaultPeerPort = 7746
+	// DefaultPeerCacheBytes limits the size of the pack blobs kept for the peers
+	DefaultPeerCacheBytes = 20 << 30
+	// peerDiscoveryWait is how long the answers to a discovery are collected
+	peerDiscoveryWait = 500 * time.Millisecond
+	// peerDiscoveryQuery is followed by the gasset id in a discovery and peerDiscoveryAnswer by the gasset id and the port
+	peerDiscoveryQuery  = "gasset-peer?"
+	peerDiscoveryAnswer = "gasset-peer!"
+)
+
+// PeerOptions turn on fetching the pack blobs of restores from teammates on the same network before the storage.
+// The pack blobs are immutable and encrypted, so any peer having one can serve it.
+type PeerOptions struct {
+	// Peers are the addresses of the peers, e.g. 192.168.1.20 or nas.local:7746
+	Peers []string `json:"peers,omitempty"`
+	// Discover finds the peers serving the repository on the network with a UDP broadcast
+	Discover bool `json:"discover,omitempty"`
+	// Port is the port the peer command serves on and the discovery is sent to
+	Port int `json:"port,omitempty"`
+	// MaxCacheBytes limits the size of the pack blobs kept for the peers, the least recently used are removed
+	MaxCacheBytes int64 `json:"maxCacheBytes,omitempty"`
+}
+
+// ServePort returns the port the peer command serves on and the discovery is sent to
+func (p *PeerOptions) ServePort() int {
+	if p == nil || p.Port <= 0 {
+		return DefaultPeerPort
+	}
+	return p.Port
+}
+
+func (p *PeerOptions) maxCacheBytes() int64 {
+	if p == nil || p.MaxCacheBytes <= 0 {
+		return DefaultPeerCacheBytes
+	}
+	return p.MaxCacheBytes
+}
+
+// PeerAddresses returns the configured peers with the default port where they have none
+func (p *PeerOptions) PeerAddresses() []string {
+	addresses := make([]string, 0, len(p.Peers))
+	for _, peer := range p.Peers {
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			peer = net.JoinHostPort(peer, strconv.Itoa(p.ServePort()))
+		}
+		addresses = append(addresses, peer)
+	}
+	return addresses
+}
+
+// isPeerBlob tells whether a blob is a pack blob, which never changes once written, with an id safe as a file name
+func isPeerBlob(id blob.ID) bool {
+	if len(id) < 2 || (id[0] != 'p' && id[0] != 'q') {
+		return false
+	}
+	return !strings.ContainsFunc(string(id), func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_')
+	})
+}
+
+// PeerCache keeps the pack blobs fetched by the restores on the disk to serve them to the peers
+type PeerCache struct {
+	dir      string
+	maxBytes int64
+
+	mu sync.Mutex
+}
+
+// OpenPeerCache returns the peer cache of the repository
+func (op *Options) OpenPeerCache() (*PeerCache, error) {
+	if op.Config.GassetId == "" {
+		return nil, errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(userDir, "git-gasset", "peers-"+op.Config.GassetId)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &PeerCache{dir: dir, maxBytes: op.Config.Peers.maxCacheBytes()}, nil
+}
+
+// Open opens a cached blob and marks it as used, so that it is the last to be evicted
+func (c *PeerCache) Open(id blob.ID) (*os.File, error) {
+	if !isPeerBlob(id) {
+		return nil, iofs.ErrNotExist
+	}
+	blobPath := filepath.Join(c.dir, string(id))
+	now := time.Now()
+	if err := os.Chtimes(blobPath, now, now); err != nil {
+		return nil, err
+	}
+	return os.Open(blobPath)
+}
+
+// Put caches a blob and evicts the least recently used ones above the size limit
+func (c *PeerCache) Put(id blob.ID, data []byte) error {
+	if !isPeerBlob(id) {
+		return fmt.Errorf("%s is not a pack blob", id)
+	}
+	temp, err := os.CreateTemp(c.dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), filepath.Join(c.dir, string(id)))
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return c.evict()
+}
+
+func (c *PeerCache) evict() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var infos []iofs.FileInfo
+	var total int64
+	for _, entry := range entries {
+		if !isPeerBlob(blob.ID(entry.Name())) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+		total += info.Size()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+			return err
+		}
+		total -= info.Size()
+	}
+	return nil
+}
+
+// peerFetcher fetches the pack blobs from the peer cache and the peers before the storage
+type peerFetcher struct {
+	cache    *PeerCache
+	gassetId string
+	peers    []string
+	client   *http.Client
+
+	fromCache atomic.Int64
+	fromPeers atomic.Int64
+}
+
+var (
+	activePeers       atomic.Pointer[peerFetcher]
+	registerPeersOnce sync.Once
+)
+
+// EnablePeers fetches the pack blobs of every storage opened from now on from the peer cache and the peers of
+// the config before the storage. The blobs fetched from the storage are added to the peer cache.
+func EnablePeers(ctx context.Context, op *Options) error {
+	cache, err := op.OpenPeerCache()
+	if err != nil {
+		return err
+	}
+
+	peers := op.Config.Peers.PeerAddresses()
+	if op.Config.Peers.Discover {
+		discovered, err := op.DiscoverPeers(ctx)
+		if err != nil {
+			log.Printf("Warning: could not discover the peers: %v", err)
+		}
+		for _, peer := range discovered {
+			if !slices.Contains(peers, peer) {
+				peers = append(peers, peer)
+			}
+		}
+	}
+
+	registerPeersOnce.Do(func() {
+		registerS3Storage()
+		blob.AddSupportedStorage("b2", b2.Options{}, func(ctx context.Context, opt *b2.Options, isCreate bool) (blob.Storage, error) {
+			st, err := b2.New(ctx, opt, isCreate)
+			if err != nil {
+				return nil, err
+			}
+			return wrapStorage(st), nil
+		})
+		blob.AddSupportedStorage("filesystem", filesystem.Options{}, func(ctx context.Context, opt *filesystem.Options, isCreate bool) (blob.Storage, error) {
+			st, err := filesystem.New(ctx, opt, isCreate)
+			if err != nil {
+				return nil, err
+			}
+			return wrapStorage(st), nil
+		})
+	})
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// An unreachable peer falls back to the next one quickly
+	transport.DialContext = (&net.Dialer{Timeout: time.Second}).DialContext
+	transport.ResponseHeaderTimeout = 3 * time.Second
+	activePeers.Store(&peerFetcher{
+		cache:    cache,
+		gassetId: op.Config.GassetId,
+		peers:    peers,
+		client:   &http.Client{Transport: transport},
+	})
+	return nil
+}
+
+// DisablePeers stops fetching from the peers, also for the storages wrapped already
+func DisablePeers() {
+	activePeers.Store(nil)
+}
+
+// PeerFetches returns the number of pack blobs read from the peer cache and fetched from the peers since the peers were enabled
+func PeerFetches() (int64, int64) {
+	fetcher := activePeers.Load()
+	if fetcher == nil {
+		return 0, 0
+	}
+	return fetcher.fromCache.Load(), fetcher.fromPeers.Load()
+}
+
+// wrapStorage wraps a storage kopia opens from its config with the peers and the chaos, whichever are enabled
+func wrapStorage(st blob.Storage) blob.Storage {
+	return WrapPeers(WrapChaos(st))
+}
+
+// WrapPeers wraps the storage to fetch the pack blobs from the peers while they are enabled
+func WrapPeers(st blob.Storage) blob.Storage {
+	if activePeers.Load() == nil {
+		return st
+	}
+	return &peerStorage{Storage: st}
+}
+
+// peerStorage reads the pack blobs from the peer cache, then from the peers and only then from the storage
+type peerStorage struct {
+	blob.Storage
+}
+
+func (s *peerStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	fetcher := activePeers.Load()
+	// Reading nothing checks whether the blob exists, which only the storage knows
+	if fetcher == nil || length == 0 || !isPeerBlob(id) {
+		return s.Storage.GetBlob(ctx, id, offset, length, output)
+	}
+
+	if err := fetcher.readCache(id, offset, length, output); err == nil {
+		fetcher.fromCache.Add(1)
+		return nil
+	}
+	for _, peer := range fetcher.peers {
+		output.Reset()
+		if err := fetcher.fetchPeer(ctx, peer, id, offset, length, output); err == nil {
+			fetcher.fromPeers.Add(1)
+			return nil
+		}
+	}
+	output.Reset()
+
+	// The whole blob is fetched so that the next restore and the peers find it in the cache
+	var buf blobBuffer
+	if err := s.Storage.GetBlob(ctx, id, 0, -1, &buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if err := fetcher.cache.Put(id, data); err != nil {
+		log.Printf("Warning: could not cache %s for the peers: %v", id, err)
+	}
+	return writeBlobRange(data, offset, length, output)
+}
+
+func (s *peerStorage) DisplayName() string {
+	return "Peers: " + s.Storage.DisplayName()
+}
+
+func (f *peerFetcher) readCache(id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	file, err := f.cache.Open(id)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	return writeBlobRange(data, offset, length, output)
+}
+
+func (f *peerFetcher) fetchPeer(ctx context.Context, peer string, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+peer+peerBlobPath(f.gassetId)+string(id), nil)
+	if err != nil {
+		return err
+	}
+	if length > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	partial := request.Header.Get("Range") != ""
+	if !(response.StatusCode == http.StatusOK && !partial || response.StatusCode == http.StatusPartialContent && partial) {
+		return fmt.Errorf("peer %s answered %s", peer, response.Status)
+	}
+
+	n, err := io.Copy(output, response.Body)
+	if err != nil {
+		return err
+	}
+	if length > 0 && n != length {
+		return fmt.Errorf("peer %s sent %d of %d bytes", peer, n, length)
+	}
+	return nil
+}
+
+// writeBlobRange writes the range of the blob like the storages do, the rest of the blob if length is negative
+func writeBlobRange(data []byte, offset, length int64, output blob.OutputBuffer) error {
+	if offset < 0 || offset > int64(len(data)) || length > 0 && offset+length > int64(len(data)) {
+		return blob.ErrInvalidRange
+	}
+	end := int64(len(data))
+	if length >= 0 {
+		end = offset + length
+	}
+	_, err := output.Write(data[offset:end])
+	return err
+}
+
+func peerBlobPath(gassetId string) string {
+	return "/gasset/" + gassetId + "/blobs/"
+}
+
+// newPeerHandler serves the pack blobs of the peer cache, supporting range requests
+func newPeerHandler(cache *PeerCache, gassetId string) http.Handler {
+	prefix := peerBlobPath(gassetId)
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		file, err := cache.Open(blob.ID(strings.TrimPrefix(r.URL.Path, prefix)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", info.ModTime(), file)
+	})
+	return mux
+}
+
+// ServePeers serves the peer cache to the peers and answers their discoveries on the port until ctx is done
+func ServePeers(ctx context.Context, cache *PeerCache, gassetId string, port int) error {
+	conn, err := net.ListenPacket("udp4", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go answerPeerDiscovery(conn, gassetId, port)
+
+	server := &http.Server{Addr: ":" + strconv.Itoa(port), Handler: newPeerHandler(cache, gassetId), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// answerPeerDiscovery answers the discoveries of the repository with the port of the peer server until conn is closed
+func answerPeerDiscovery(conn net.PacketConn, gassetId string, port int) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if string(buf[:n]) != peerDiscoveryQuery+gassetId {
+			continue
+		}
+		answer := fmt.Sprintf("%s%s %d", peerDiscoveryAnswer, gassetId, port)
+		if _, err := conn.WriteTo([]byte(answer), addr); err != nil {
+			log.Printf("Warning: could not answer the discovery of %s: %v", addr, err)
+		}
+	}
+}
+
+// DiscoverPeers broadcasts a discovery of the repository on the port of the config and returns the addresses of
+// the peer servers answering it
+func (op *Options) DiscoverPeers(ctx context.Context) ([]string, error) {
+	broadcast := &net.UDPAddr{IP: net.IPv4bcast, Port: op.Config.Peers.ServePort()}
+	return discoverPeers(ctx, op.Config.GassetId, broadcast, peerDiscoveryWait)
+}
+
+// discoverPeers sends a discovery of the repository to the address, usually the broadcast address of the network,
+// and returns the addresses of the peer servers answering within the wait
+func discoverPeers(ctx context.Context, gassetId string, addr *net.UDPAddr, wait time.Duration) ([]string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteTo([]byte(peerDiscoveryQuery+gassetId), addr); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(wait)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var peers []string
+	buf := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return peers, nil
+		}
+		if err != nil {
+			return peers, err
+		}
+		rest, ok := strings.CutPrefix(string(buf[:n]), peerDiscoveryAnswer+gassetId+" ")
+		if !ok {
+			continue
+		}
+		port, err := strconv.Atoi(rest)
+		if err != nil {
+			continue
+		}
+		peer := net.JoinHostPort(from.IP.String(), strconv.Itoa(port))
+		if !slices.Contains(peers, peer) {
+			peers = append(peers, peer)
+		}
+	}
+}
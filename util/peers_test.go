@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPeerAddresses(t *testing.T) {
+	options := &PeerOptions{Peers: []string{"192.168.1.20", "nas.local:8000", "::1"}}
+	assert.Equal(t, []string{"192.168.1.20:7746", "nas.local:8000", "[::1]:7746"}, options.PeerAddresses())
+
+	options.Port = 9000
+	assert.Equal(t, "192.168.1.20:9000", options.PeerAddresses()[0])
+}
+
+func TestIsPeerBlob(t *testing.T) {
+	assert.True(t, isPeerBlob("p0123abc"))
+	assert.True(t, isPeerBlob("q0123abc-s1"))
+	assert.False(t, isPeerBlob("xn0_123"), "index blob")
+	assert.False(t, isPeerBlob("kopia.repository"))
+	assert.False(t, isPeerBlob("p../secret"))
+	assert.False(t, isPeerBlob("p"))
+}
+
+func TestPeerCacheEvict(t *testing.T) {
+	cache := &PeerCache{dir: t.TempDir(), maxBytes: 10}
+
+	old := time.Now().Add(-time.Hour)
+	assert.NoError(t, cache.Put("p1", []byte("01234")))
+	assert.NoError(t, os.Chtimes(filepath.Join(cache.dir, "p1"), old, old))
+	assert.NoError(t, cache.Put("p2", []byte("01234")))
+	assert.NoError(t, os.Chtimes(filepath.Join(cache.dir, "p2"), old.Add(time.Minute), old.Add(time.Minute)))
+
+	// Opening p1 makes p2 the least recently used one
+	file, err := cache.Open("p1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	file.Close()
+
+	assert.NoError(t, cache.Put("p3", []byte("01")))
+	assert.FileExists(t, filepath.Join(cache.dir, "p1"))
+	assert.NoFileExists(t, filepath.Join(cache.dir, "p2"))
+	assert.FileExists(t, filepath.Join(cache.dir, "p3"))
+
+	assert.Error(t, cache.Put("xn0", []byte("index")), "Put() of an index blob")
+}
+
+func TestPeerStorage(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStorage(t.Name())
+	assert.NoError(t, st.PutBlob(ctx, "pstorage", blobBytes("0123456789"), blob.PutOptions{}))
+	assert.NoError(t, st.PutBlob(ctx, "xindex", blobBytes("index"), blob.PutOptions{}))
+
+	peerCache := &PeerCache{dir: t.TempDir(), maxBytes: DefaultPeerCacheBytes}
+	assert.NoError(t, peerCache.Put("ppeer", []byte("abcdefghij")))
+	server := httptest.NewServer(newPeerHandler(peerCache, "id"))
+	defer server.Close()
+
+	cache := &PeerCache{dir: t.TempDir(), maxBytes: DefaultPeerCacheBytes}
+	activePeers.Store(&peerFetcher{
+		cache:    cache,
+		gassetId: "id",
+		peers:    []string{"127.0.0.1:1", strings.TrimPrefix(server.URL, "http://")},
+		client:   http.DefaultClient,
+	})
+	defer DisablePeers()
+	wrapped := WrapPeers(st)
+
+	var output blobBuffer
+	assert.NoError(t, wrapped.GetBlob(ctx, "ppeer", 2, 3, &output))
+	assert.Equal(t, "cde", output.String())
+
+	output.Reset()
+	assert.NoError(t, wrapped.GetBlob(ctx, "pstorage", 4, -1, &output))
+	assert.Equal(t, "456789", output.String())
+	assert.FileExists(t, filepath.Join(cache.dir, "pstorage"), "the blob fetched from the storage is cached")
+
+	output.Reset()
+	assert.NoError(t, wrapped.GetBlob(ctx, "pstorage", 0, 2, &output))
+	assert.Equal(t, "01", output.String())
+	fromCache, fromPeers := PeerFetches()
+	assert.Equal(t, int64(1), fromCache)
+	assert.Equal(t, int64(1), fromPeers)
+
+	output.Reset()
+	assert.NoError(t, wrapped.GetBlob(ctx, "xindex", 0, -1, &output))
+	assert.NoFileExists(t, filepath.Join(cache.dir, "xindex"))
+
+	output.Reset()
+	assert.ErrorIs(t, wrapped.GetBlob(ctx, "pstorage", 8, 5, &output), blob.ErrInvalidRange)
+	assert.ErrorIs(t, wrapped.GetBlob(ctx, "pmissing", 0, -1, &output), blob.ErrBlobNotFound)
+}
+
+func TestDiscoverPeers(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.FailNow()
+	}
+	defer conn.Close()
+	go answerPeerDiscovery(conn, "id", 7746)
+
+	peers, err := discoverPeers(context.Background(), "id", conn.LocalAddr().(*net.UDPAddr), 200*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:7746"}, peers)
+
+	peers, err = discoverPeers(context.Background(), "other", conn.LocalAddr().(*net.UDPAddr), 200*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Empty(t, peers)
+}
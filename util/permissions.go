@@ -0,0 +1,193 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/restore"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// The modes of the files and directories created in the git repository unless the .gasset file configures them
+const (
+	DefaultFileMode iofs.FileMode = 0o644
+	DefaultDirMode  iofs.FileMode = 0o755
+)
+
+// PermissionOptions are the modes of the files and directories written in the git repository, i.e. the
+// restored assets, the .gasset and lock files and the asset directories. Files of the user, like the
+// kopia config, stay private regardless. Teams sharing a checkout set them, e.g. to 0664 and 2775, so
+// that everyone in the group can update the assets.
+type PermissionOptions struct {
+	// FileMode is an octal mode, e.g. 0664
+	FileMode string `json:"fileMode,omitempty"`
+	// DirMode is an octal mode with the setuid, setgid and sticky bits, e.g. 2775
+	DirMode string `json:"dirMode,omitempty"`
+	// IgnoreUmask applies the modes as they are instead of masking them with the umask of the process
+	IgnoreUmask bool `json:"ignoreUmask,omitempty"`
+}
+
+// ParseMode parses an octal mode like 0664 or 2775
+func ParseMode(mode string) (iofs.FileMode, error) {
+	bits, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || bits > 0o7777 {
+		return 0, fmt.Errorf("invalid mode %q, expected an octal mode like 0644", mode)
+	}
+
+	parsed := iofs.FileMode(bits & 0o777)
+	for special, flag := range map[uint64]iofs.FileMode{0o4000: iofs.ModeSetuid, 0o2000: iofs.ModeSetgid, 0o1000: iofs.ModeSticky} {
+		if bits&special != 0 {
+			parsed |= flag
+		}
+	}
+	return parsed, nil
+}
+
+// Validate fails on modes which cannot be parsed
+func (p *PermissionOptions) Validate() error {
+	_, _, err := p.modes()
+	return err
+}
+
+// modes returns the configured modes, the default ones for the modes which are not configured
+func (p *PermissionOptions) modes() (iofs.FileMode, iofs.FileMode, error) {
+	fileMode, dirMode := DefaultFileMode, DefaultDirMode
+	if p == nil {
+		return fileMode, dirMode, nil
+	}
+	var err error
+	if p.FileMode != "" {
+		if fileMode, err = ParseMode(p.FileMode); err != nil {
+			return 0, 0, fmt.Errorf("fileMode: %w", err)
+		}
+	}
+	if p.DirMode != "" {
+		if dirMode, err = ParseMode(p.DirMode); err != nil {
+			return 0, 0, fmt.Errorf("dirMode: %w", err)
+		}
+	}
+	return fileMode, dirMode, nil
+}
+
+// masked returns the mode a file or directory gets, which is masked by the umask unless it is ignored
+func (p *PermissionOptions) masked(mode iofs.FileMode) iofs.FileMode {
+	if p != nil && p.IgnoreUmask {
+		return mode
+	}
+	return mode &^ Umask()
+}
+
+// ApplyFile sets the configured mode of a file. Without a configured mode the file is left alone,
+// e.g. a restored file keeps the mode of its snapshot.
+func (p *PermissionOptions) ApplyFile(name string) error {
+	if p == nil || p.FileMode == "" {
+		return nil
+	}
+	return p.chmodFile(name)
+}
+
+// ApplyDir sets the configured mode of a directory. Without a configured mode the directory is left alone.
+func (p *PermissionOptions) ApplyDir(name string) error {
+	if p == nil || p.DirMode == "" {
+		return nil
+	}
+	return p.chmodDir(name)
+}
+
+func (p *PermissionOptions) chmodFile(name string) error {
+	fileMode, _, err := p.modes()
+	if err != nil {
+		return err
+	}
+	return os.Chmod(name, p.masked(fileMode))
+}
+
+func (p *PermissionOptions) chmodDir(name string) error {
+	_, dirMode, err := p.modes()
+	if err != nil {
+		return err
+	}
+	return os.Chmod(name, p.masked(dirMode))
+}
+
+// WriteFile writes a file of the git repository with the configured file mode
+func (p *PermissionOptions) WriteFile(name string, data []byte) error {
+	fileMode, _, err := p.modes()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, data, fileMode.Perm()); err != nil {
+		return err
+	}
+	// The mode of an existing file is not changed by writing it and the special bits are never set by creating it
+	if p == nil || (p.FileMode == "" && !p.IgnoreUmask) {
+		return nil
+	}
+	return p.chmodFile(name)
+}
+
+// MkdirAll creates a directory of the git repository and its parents with the configured directory mode
+func (p *PermissionOptions) MkdirAll(name string) error {
+	_, dirMode, err := p.modes()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(name, dirMode.Perm()); err != nil {
+		return err
+	}
+	if p == nil || (p.DirMode == "" && !p.IgnoreUmask) {
+		return nil
+	}
+	return p.chmodDir(name)
+}
+
+// PermissionOutput applies the configured modes to the files and directories restored by the wrapped output
+type PermissionOutput struct {
+	restore.Output
+
+	targetPath  string
+	permissions *PermissionOptions
+}
+
+// NewPermissionOutput wraps an output restoring into targetPath, the output is returned as is without configured modes
+func NewPermissionOutput(output restore.Output, targetPath string, permissions *PermissionOptions) restore.Output {
+	if permissions == nil || (permissions.FileMode == "" && permissions.DirMode == "") {
+		return output
+	}
+	return &PermissionOutput{Output: output, targetPath: targetPath, permissions: permissions}
+}
+
+// WriteFile implements restore.Output
+func (o *PermissionOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	if err := o.Output.WriteFile(ctx, relativePath, f); err != nil {
+		return err
+	}
+	return o.permissions.ApplyFile(filepath.Join(o.targetPath, filepath.FromSlash(relativePath)))
+}
+
+// FinishDirectory implements restore.Output, the wrapped output sets the attributes of the snapshot first
+func (o *PermissionOutput) FinishDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
+	if err := o.Output.FinishDirectory(ctx, relativePath, e); err != nil {
+		return err
+	}
+	return o.permissions.ApplyDir(filepath.Join(o.targetPath, filepath.FromSlash(relativePath)))
+}
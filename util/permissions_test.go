@@ -0,0 +1,109 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		want    fs.FileMode
+		wantErr bool
+	}{
+		{name: "File mode", mode: "0664", want: 0o664},
+		{name: "File mode without leading zero", mode: "640", want: 0o640},
+		{name: "Directory mode with setgid", mode: "2775", want: fs.ModeSetgid | 0o775},
+		{name: "Directory mode with setuid and sticky", mode: "5755", want: fs.ModeSetuid | fs.ModeSticky | 0o755},
+		{name: "Not octal", mode: "0869", wantErr: true},
+		{name: "Too many bits", mode: "17777", wantErr: true},
+		{name: "Empty", mode: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMode(tt.mode)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPermissionOptionsValidate(t *testing.T) {
+	var unset *PermissionOptions
+	assert.NoError(t, unset.Validate())
+	assert.NoError(t, (&PermissionOptions{FileMode: "0664", DirMode: "2775"}).Validate())
+	assert.ErrorContains(t, (&PermissionOptions{DirMode: "rwxr-xr-x"}).Validate(), "dirMode")
+}
+
+func TestPermissionOptionsWriteFile(t *testing.T) {
+	dir := t.TempDir()
+
+	permissions := &PermissionOptions{FileMode: "0660", DirMode: "0770", IgnoreUmask: true}
+	file := filepath.Join(dir, "file")
+	// The mode of an existing file is changed as well
+	if err := os.WriteFile(file, []byte("old"), 0o600); err != nil {
+		t.FailNow()
+	}
+	assert.NoError(t, permissions.WriteFile(file, []byte("new")))
+	assertMode(t, file, 0o660)
+
+	subDir := filepath.Join(dir, "a", "b")
+	assert.NoError(t, permissions.MkdirAll(subDir))
+	assertMode(t, subDir, 0o770)
+
+	var unset *PermissionOptions
+	defaultFile := filepath.Join(dir, "default")
+	assert.NoError(t, unset.WriteFile(defaultFile, []byte("default")))
+	assertMode(t, defaultFile, DefaultFileMode&^Umask())
+}
+
+func TestPermissionOptionsApply(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("file"), 0o700); err != nil {
+		t.FailNow()
+	}
+	if err := os.Chmod(file, 0o700); err != nil {
+		t.FailNow()
+	}
+
+	// Without a configured mode the file keeps its mode, even when the umask is ignored
+	assert.NoError(t, (&PermissionOptions{IgnoreUmask: true}).ApplyFile(file))
+	assertMode(t, file, 0o700)
+
+	assert.NoError(t, (&PermissionOptions{FileMode: "0640", IgnoreUmask: true}).ApplyFile(file))
+	assertMode(t, file, 0o640)
+}
+
+func assertMode(t *testing.T, name string, want fs.FileMode) {
+	t.Helper()
+	stat, err := os.Stat(name)
+	if assert.NoError(t, err) {
+		assert.Equalf(t, want, stat.Mode().Perm(), "mode of %s", name)
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// policyLoadParallelism is the number of policies loaded from the repository at once
+const policyLoadParallelism = 8
+
+// PolicyCache holds all the policies of the repository so that the policy trees of the sources of a snap are built
+// without looking up the policy hierarchy of every source in the repository
+type PolicyCache struct {
+	policies map[manifest.ID]*CachedPolicy
+}
+
+// CachedPolicy is a policy with the labels of its manifest, which are not part of the policy itself
+type CachedPolicy struct {
+	Labels map[string]string `json:"labels"`
+	Policy *policy.Policy    `json:"policy"`
+}
+
+func (op *Options) GetPolicyCachePath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "policies-"+op.Config.GassetId+".json"), nil
+}
+
+// LoadPolicyCache lists the policies of the repository and loads the ones missing from the cache of the previous runs.
+// Changing a policy replaces its manifest so the cache is invalidated by the manifest ids alone.
+func (op *Options) LoadPolicyCache(ctx context.Context, rep repo.Repository) (*PolicyCache, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{manifest.TypeLabelKey: policy.ManifestType})
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := op.readPolicyCache()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &PolicyCache{policies: make(map[manifest.ID]*CachedPolicy, len(entries))}
+	var missing []*manifest.EntryMetadata
+	for _, entry := range entries {
+		if cachedPolicy, ok := cached[entry.ID]; ok && cachedPolicy.Policy != nil {
+			cache.policies[entry.ID] = cachedPolicy
+			continue
+		}
+		missing = append(missing, entry)
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, policyLoadParallelism)
+	for _, entry := range missing {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(entry *manifest.EntryMetadata) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			pol := &policy.Policy{}
+			_, err := rep.GetManifest(ctx, entry.ID, pol)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			cache.policies[entry.ID] = &CachedPolicy{Labels: entry.Labels, Policy: pol}
+		}(entry)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 || len(cached) != len(cache.policies) {
+		if err := op.writePolicyCache(cache.policies); err != nil {
+			return nil, err
+		}
+	}
+	return cache, nil
+}
+
+func (op *Options) readPolicyCache() (map[manifest.ID]*CachedPolicy, error) {
+	cachePath, err := op.GetPolicyCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	cacheBytes, err := os.ReadFile(cachePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var policies map[manifest.ID]*CachedPolicy
+	if err := json.Unmarshal(cacheBytes, &policies); err != nil {
+		// A corrupted cache is rebuilt from the repository
+		return nil, nil
+	}
+	return policies, nil
+}
+
+func (op *Options) writePolicyCache(policies map[manifest.ID]*CachedPolicy) error {
+	cachePath, err := op.GetPolicyCachePath()
+	if err != nil {
+		return err
+	}
+
+	cacheBytes, err := json.Marshal(policies)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, cacheBytes, 0o600)
+}
+
+// Tree returns the policy tree of a source like policy.TreeForSourceWithOverride does.
+// As in kopia, the override replaces the policy of the source itself and is merged with the policies of its parents.
+func (c *PolicyCache) Tree(si snapshot.SourceInfo, optionalPolicyOverride *policy.Policy) *policy.Tree {
+	var own, ancestors, userHost, host, global, children []*CachedPolicy
+	for _, cached := range c.policies {
+		target := policySource(cached.Labels)
+		switch {
+		case target.Host == "":
+			global = append(global, cached)
+		case target.Host != si.Host:
+		case target.UserName == "":
+			host = append(host, cached)
+		case target.UserName != si.UserName:
+		case target.Path == "":
+			userHost = append(userHost, cached)
+		case target.Path == si.Path:
+			own = append(own, cached)
+		case nestedRelativePath(target.Path, si.Path) != "":
+			ancestors = append(ancestors, cached)
+		case nestedRelativePath(si.Path, target.Path) != "":
+			children = append(children, cached)
+		}
+	}
+
+	// The most specific policies come first
+	sort.SliceStable(ancestors, func(i, j int) bool {
+		return len(ancestors[i].Labels[snapshot.PathLabel]) > len(ancestors[j].Labels[snapshot.PathLabel])
+	})
+
+	var hierarchy []*policy.Policy
+	if optionalPolicyOverride != nil {
+		optionalPolicyOverride.Labels = policy.LabelsForSource(si)
+		hierarchy = append(hierarchy, optionalPolicyOverride)
+	} else {
+		hierarchy = appendPolicies(hierarchy, own)
+	}
+	hierarchy = appendPolicies(hierarchy, ancestors)
+	hierarchy = appendPolicies(hierarchy, userHost)
+	hierarchy = appendPolicies(hierarchy, host)
+	hierarchy = appendPolicies(hierarchy, global)
+
+	effective, _ := policy.MergePolicies(hierarchy, si)
+	policies := map[string]*policy.Policy{".": effective}
+	for _, child := range children {
+		policies["./"+nestedRelativePath(si.Path, child.Labels[snapshot.PathLabel])] = labeled(child)
+	}
+
+	return policy.BuildTree(policies, policy.DefaultPolicy)
+}
+
+func policySource(labels map[string]string) snapshot.SourceInfo {
+	return snapshot.SourceInfo{
+		Host:     labels[snapshot.HostnameLabel],
+		UserName: labels[snapshot.UsernameLabel],
+		Path:     labels[snapshot.PathLabel],
+	}
+}
+
+// labeled returns the policy with the labels of its manifest as kopia loads it
+func labeled(cached *CachedPolicy) *policy.Policy {
+	pol := *cached.Policy
+	pol.Labels = cached.Labels
+	return &pol
+}
+
+func appendPolicies(hierarchy []*policy.Policy, cached []*CachedPolicy) []*policy.Policy {
+	for _, c := range cached {
+		hierarchy = append(hierarchy, labeled(c))
+	}
+	return hierarchy
+}
+
+// nestedRelativePath returns the slash separated path of p relative to base if it is inside it, an empty string otherwise.
+// mostly from github.com/kopia/kopia/snapshot/policy.nestedRelativePathNormalizedToSlashes
+func nestedRelativePath(base string, p string) string {
+	base = strings.TrimSuffix(strings.ReplaceAll(base, "\\", "/"), "/")
+	p = strings.ReplaceAll(p, "\\", "/")
+
+	if !strings.HasPrefix(p, base+"/") {
+		return ""
+	}
+	return strings.TrimPrefix(p, base+"/")
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestPolicyCache_Tree(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+
+	if _, err := SetupFakeRepository(ctx, op, t.TempDir()); !assert.NoError(t, err) {
+		return
+	}
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rep.Close(ctx)
+
+	source := snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: "/project/assets"}
+	keep := func(n int) *policy.OptionalInt {
+		value := policy.OptionalInt(n)
+		return &value
+	}
+	policies := map[snapshot.SourceInfo]*policy.Policy{
+		policy.GlobalPolicySourceInfo:                         {RetentionPolicy: policy.RetentionPolicy{KeepLatest: keep(1), KeepDaily: keep(1)}},
+		{Host: "host-pc", UserName: "user"}:                   {RetentionPolicy: policy.RetentionPolicy{KeepDaily: keep(2)}},
+		{Host: "host-pc", UserName: "user", Path: "/project"}: {FilesPolicy: policy.FilesPolicy{IgnoreRules: []string{"*.tmp"}}},
+		source: {RetentionPolicy: policy.RetentionPolicy{KeepLatest: keep(3)}},
+		{Host: "host-pc", UserName: "user", Path: "/project/assets/textures"}: {FilesPolicy: policy.FilesPolicy{IgnoreRules: []string{"*.psd"}}},
+		{Host: "host-pc", UserName: "other", Path: "/project/assets"}:         {RetentionPolicy: policy.RetentionPolicy{KeepLatest: keep(4)}},
+	}
+	err = repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		for si, pol := range policies {
+			if err := policy.SetPolicy(ctx, w, si, pol); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cache, err := op.LoadPolicyCache(ctx, rep)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cachePath, err := op.GetPolicyCachePath()
+	assert.NoError(t, err)
+	assert.FileExists(t, cachePath)
+
+	override := &policy.Policy{FilesPolicy: policy.FilesPolicy{IgnoreRules: []string{"/readme.txt"}}}
+	for _, optionalPolicyOverride := range []*policy.Policy{nil, override} {
+		want, err := policy.TreeForSourceWithOverride(ctx, rep, source, optionalPolicyOverride)
+		if !assert.NoError(t, err) {
+			return
+		}
+		got := cache.Tree(source, optionalPolicyOverride)
+
+		assert.Equal(t, want.EffectivePolicy().RetentionPolicy, got.EffectivePolicy().RetentionPolicy)
+		assert.ElementsMatch(t, want.EffectivePolicy().FilesPolicy.IgnoreRules, got.EffectivePolicy().FilesPolicy.IgnoreRules)
+		assert.ElementsMatch(t, want.Child("textures").DefinedPolicy().FilesPolicy.IgnoreRules, got.Child("textures").DefinedPolicy().FilesPolicy.IgnoreRules)
+	}
+
+	// Policies missing from the cache file are loaded from the repository again
+	if err := os.WriteFile(cachePath, []byte("{}"), 0o600); err != nil {
+		t.FailNow()
+	}
+	cache, err = op.LoadPolicyCache(ctx, rep)
+	assert.NoError(t, err)
+	assert.Len(t, cache.policies, len(policies))
+}
+
+func TestNestedRelativePath(t *testing.T) {
+	assert.Equal(t, "textures/wall", nestedRelativePath("/project/assets", "/project/assets/textures/wall"))
+	assert.Equal(t, "project", nestedRelativePath("/", "/project"))
+	assert.Equal(t, "assets", nestedRelativePath(`C:\project`, `C:\project\assets`))
+	assert.Equal(t, "", nestedRelativePath("/project/assets", "/project/assets"))
+	assert.Equal(t, "", nestedRelativePath("/project/assets", "/project/assets2"))
+}
@@ -0,0 +1,149 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// WindowsPathBudget is the length a path within the git repository can have so that it can still be restored on
+// Windows, leaving 60 of the 260 characters of MAX_PATH to the location of the checkout
+const WindowsPathBudget = 200
+
+// windowsReservedNames are the device names Windows reserves with any extension, in any case
+var windowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// windowsInvalidCharacters can't be part of a file name on Windows, besides the control characters
+const windowsInvalidCharacters = `<>:"|?*\`
+
+// PathProblem is why a snapshotted path can't be restored on every platform
+type PathProblem struct {
+	Path    string
+	Problem string
+}
+
+func (p PathProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Problem)
+}
+
+// PortablePathProblems returns the problems of the paths, relative to the root of the git repository with slashes,
+// which break restoring them on Windows, or on Windows and macOS for paths only differing in case.
+// A problem of a directory is reported once for the directory, not for every path in it.
+func PortablePathProblems(paths []string) []PathProblem {
+	var problems []PathProblem
+	seen := map[string]bool{}
+	byFoldedPath := map[string]string{}
+
+	sorted := slices.Clone(paths)
+	slices.Sort(sorted)
+	for _, p := range sorted {
+		names := strings.Split(p, "/")
+		for i, name := range names {
+			prefix := strings.Join(names[:i+1], "/")
+			if seen[prefix] {
+				continue
+			}
+			seen[prefix] = true
+
+			if problem := windowsNameProblem(name); problem != "" {
+				problems = append(problems, PathProblem{Path: prefix, Problem: problem})
+			}
+			length := utf8.RuneCountInString(prefix)
+			if length > WindowsPathBudget && length-utf8.RuneCountInString(name)-1 <= WindowsPathBudget {
+				problems = append(problems, PathProblem{Path: prefix, Problem: fmt.Sprintf("%d characters long, which leaves too little of the 260 characters of Windows paths to the checkout", length)})
+			}
+
+			folded := strings.ToLower(prefix)
+			if other, ok := byFoldedPath[folded]; ok {
+				problems = append(problems, PathProblem{Path: prefix, Problem: fmt.Sprintf("only differs in case from %s, one overwrites the other on Windows and macOS", other)})
+			} else {
+				byFoldedPath[folded] = prefix
+			}
+		}
+	}
+	return problems
+}
+
+// windowsNameProblem returns why Windows can't create a file or directory with the name, or an empty string
+func windowsNameProblem(name string) string {
+	if name == "" || name == "." || name == ".." {
+		return ""
+	}
+
+	stem, _, _ := strings.Cut(name, ".")
+	if slices.Contains(windowsReservedNames, strings.ToUpper(strings.TrimRight(stem, " "))) {
+		return fmt.Sprintf("%s is a reserved name on Windows", name)
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return fmt.Sprintf("%q ends with a dot or space, which Windows drops", name)
+	}
+	for _, r := range name {
+		if r < 32 || strings.ContainsRune(windowsInvalidCharacters, r) {
+			return fmt.Sprintf("%q contains %q, which Windows does not allow", name, r)
+		}
+	}
+	return ""
+}
+
+// PathCollector records the paths of the files and directories of an upload while passing the progress on
+type PathCollector struct {
+	snapshotfs.UploadProgress
+	dir   string
+	mu    sync.Mutex
+	paths []string
+}
+
+// NewPathCollector collects the paths of the upload of the directory, relative to the root of the git repository
+func NewPathCollector(progress snapshotfs.UploadProgress, dir string) *PathCollector {
+	return &PathCollector{UploadProgress: progress, dir: path.Clean(dir)}
+}
+
+func (c *PathCollector) add(relativePath string) {
+	if relativePath == "" || relativePath == "." {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths = append(c.paths, path.Join(c.dir, relativePath))
+}
+
+func (c *PathCollector) FinishedFile(fname string, err error) {
+	c.add(fname)
+	c.UploadProgress.FinishedFile(fname, err)
+}
+
+func (c *PathCollector) StartedDirectory(dirname string) {
+	c.add(dirname)
+	c.UploadProgress.StartedDirectory(dirname)
+}
+
+// Paths returns the paths collected so far
+func (c *PathCollector) Paths() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.paths)
+}
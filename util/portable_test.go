@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPortablePathProblems(t *testing.T) {
+	longName := strings.Repeat("a", WindowsPathBudget)
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{
+			name:  "Accept portable paths",
+			paths: []string{"assets/textures/wall.png", "assets/.hidden", "assets/console/readme"},
+			want:  nil,
+		},
+		{
+			name:  "Report reserved names with any extension and case",
+			paths: []string{"assets/nul", "assets/Com1.txt", "assets/aux .png"},
+			want:  []string{"assets/Com1.txt", "assets/aux .png", "assets/nul"},
+		},
+		{
+			name:  "Report trailing dots and spaces and invalid characters",
+			paths: []string{"assets/final.", "assets/final ", "assets/what?.png"},
+			want:  []string{"assets/final ", "assets/final.", "assets/what?.png"},
+		},
+		{
+			name:  "Report a directory once for all its paths",
+			paths: []string{"assets/prn/a.png", "assets/prn/b.png"},
+			want:  []string{"assets/prn"},
+		},
+		{
+			name:  "Report a path too long for windows once",
+			paths: []string{"assets/" + longName + "/a.png", "assets/" + longName + "/b.png"},
+			want:  []string{"assets/" + longName},
+		},
+		{
+			name:  "Report paths only differing in case",
+			paths: []string{"assets/Wall.png", "assets/wall.png"},
+			want:  []string{"assets/wall.png"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, problem := range PortablePathProblems(tt.paths) {
+				got = append(got, problem.Path)
+			}
+			assert.Equalf(t, tt.want, got, "PortablePathProblems(%v)", tt.paths)
+		})
+	}
+}
+
+func TestPathCollector(t *testing.T) {
+	collector := NewPathCollector(&snapshotfs.NullUploadProgress{}, "./assets")
+	collector.StartedDirectory(".")
+	collector.StartedDirectory("textures")
+	collector.FinishedFile("textures/wall.png", nil)
+	assert.Equal(t, []string{"assets/textures", "assets/textures/wall.png"}, collector.Paths())
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// ProfileSize is the size of the files restored with a profile
+type ProfileSize struct {
+	Name     string   `json:"name"`
+	Patterns []string `json:"patterns"`
+	Files    int64    `json:"files"`
+	Bytes    int64    `json:"bytes"`
+}
+
+// ProfileNames returns the names of the profiles of the config sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileMatches tells whether a profile selects a file, given by its slash separated path relative to the working directory.
+// A pattern selects the files matching it and everything inside the directories matching it,
+// a pattern ending with a slash only selects directories.
+func ProfileMatches(patterns []string, filePath string) bool {
+	filePath = path.Clean(filePath)
+	for _, pattern := range patterns {
+		candidate := filePath
+		if strings.HasSuffix(pattern, "/") {
+			candidate = path.Dir(filePath)
+		}
+		pattern = path.Clean(pattern)
+		for ; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+			if matched, err := path.Match(pattern, candidate); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AddFile adds a file to the profile if the profile selects it
+func (p *ProfileSize) AddFile(filePath string, size int64) {
+	if ProfileMatches(p.Patterns, filePath) {
+		p.Files++
+		p.Bytes += size
+	}
+}
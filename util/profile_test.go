@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProfileMatches(t *testing.T) {
+	patterns := []string{"./assets/textures", "./assets/models/*.fbx"}
+	tests := []struct {
+		filePath string
+		want     bool
+	}{
+		{filePath: "assets/textures/wall.png", want: true},
+		{filePath: "assets/textures/stone/floor.png", want: true},
+		{filePath: "assets/models/tree.fbx", want: true},
+		{filePath: "assets/models/tree.blend", want: false},
+		{filePath: "assets/textures2/wall.png", want: false},
+		{filePath: "assets/sounds/step.wav", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filePath, func(t *testing.T) {
+			assert.Equal(t, tt.want, ProfileMatches(patterns, tt.filePath))
+		})
+	}
+}
+
+func TestProfileSize_AddFile(t *testing.T) {
+	size := &ProfileSize{Name: "art", Patterns: []string{"./assets/*/"}}
+	size.AddFile("assets/textures/wall.png", 100)
+	size.AddFile("assets/readme.txt", 10)
+	size.AddFile("assets/models/tree.fbx", 50)
+
+	assert.Equal(t, int64(2), size.Files)
+	assert.Equal(t, int64(150), size.Bytes)
+}
+
+func TestConfig_ProfileNames(t *testing.T) {
+	config := &Config{Profiles: map[string][]string{"full": {"."}, "art": {"./assets"}}}
+	assert.Equal(t, []string{"art", "full"}, config.ProfileNames())
+}
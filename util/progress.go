@@ -0,0 +1,188 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is the time between two draws of the progress bar
+const progressInterval = 500 * time.Millisecond
+
+// UploadProgressBar draws the progress of the upload of an asset directory on a line of the terminal while passing
+// the progress on. It is redrawn until the upload finishes and then left with the totals of the directory.
+type UploadProgressBar struct {
+	snapshotfs.UploadProgress
+
+	w   io.Writer
+	now func() time.Time
+
+	mu        sync.Mutex
+	dir       string
+	started   time.Time
+	stop      chan struct{}
+	done      chan struct{}
+	lineWidth int
+
+	hashedFiles    atomic.Int64
+	hashedBytes    atomic.Int64
+	cachedFiles    atomic.Int64
+	cachedBytes    atomic.Int64
+	uploadedBytes  atomic.Int64
+	estimatedBytes atomic.Int64
+}
+
+// NewUploadProgressBar draws the progress bars of the uploads on w, usually a terminal
+func NewUploadProgressBar(w io.Writer, now func() time.Time) *UploadProgressBar {
+	return &UploadProgressBar{UploadProgress: &snapshotfs.NullUploadProgress{}, w: w, now: now}
+}
+
+// Wrap starts the progress of the upload of the directory, which is passed on to progress
+func (b *UploadProgressBar) Wrap(dir string, progress snapshotfs.UploadProgress) *UploadProgressBar {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.UploadProgress = progress
+	b.dir = dir
+	b.started = b.now()
+	b.lineWidth = 0
+	for _, counter := range []*atomic.Int64{&b.hashedFiles, &b.hashedBytes, &b.cachedFiles, &b.cachedBytes, &b.uploadedBytes, &b.estimatedBytes} {
+		counter.Store(0)
+	}
+	return b
+}
+
+// UploadStarted implements snapshotfs.UploadProgress
+func (b *UploadProgressBar) UploadStarted() {
+	b.mu.Lock()
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	stop, done := b.stop, b.done
+	b.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.draw(false)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	b.UploadProgress.UploadStarted()
+}
+
+// UploadFinished implements snapshotfs.UploadProgress
+func (b *UploadProgressBar) UploadFinished() {
+	b.mu.Lock()
+	stop, done := b.stop, b.done
+	b.stop, b.done = nil, nil
+	b.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	b.draw(true)
+	b.UploadProgress.UploadFinished()
+}
+
+// CachedFile implements snapshotfs.UploadProgress
+func (b *UploadProgressBar) CachedFile(fname string, numBytes int64) {
+	b.cachedFiles.Add(1)
+	b.cachedBytes.Add(numBytes)
+	b.UploadProgress.CachedFile(fname, numBytes)
+}
+
+// FinishedHashingFile implements snapshotfs.UploadProgress
+func (b *UploadProgressBar) FinishedHashingFile(fname string, numBytes int64) {
+	b.hashedFiles.Add(1)
+	b.UploadProgress.FinishedHashingFile(fname, numBytes)
+}
+
+// HashedBytes implements snapshotfs.UploadProgress
+func (b *UploadProgressBar) HashedBytes(numBytes int64) {
+	b.hashedBytes.Add(numBytes)
+	b.UploadProgress.HashedBytes(numBytes)
+}
+
+// UploadedBytes implements snapshotfs.UploadProgress, the uploader only reports them through the OnUpload
+// callback of the write session
+func (b *UploadProgressBar) UploadedBytes(numBytes int64) {
+	b.uploadedBytes.Add(numBytes)
+	b.UploadProgress.UploadedBytes(numBytes)
+}
+
+// EstimatedDataSize implements snapshotfs.UploadProgress
+func (b *UploadProgressBar) EstimatedDataSize(fileCount int, totalBytes int64) {
+	b.estimatedBytes.Store(totalBytes)
+	b.UploadProgress.EstimatedDataSize(fileCount, totalBytes)
+}
+
+// Line returns the progress of the upload of the directory, with the share of the estimated size and the
+// remaining time once the size is estimated and some bytes were hashed
+func (b *UploadProgressBar) Line() string {
+	b.mu.Lock()
+	dir, elapsed := b.dir, b.now().Sub(b.started)
+	b.mu.Unlock()
+
+	hashedBytes, cachedBytes := b.hashedBytes.Load(), b.cachedBytes.Load()
+	line := T("%s: %d files hashed (%s), %d cached (%s), %s uploaded", dir,
+		b.hashedFiles.Load(), FormatBytes(hashedBytes), b.cachedFiles.Load(), FormatBytes(cachedBytes), FormatBytes(b.uploadedBytes.Load()))
+
+	estimated := b.estimatedBytes.Load()
+	processed := hashedBytes + cachedBytes
+	if estimated <= 0 || processed > estimated {
+		return line
+	}
+	line += fmt.Sprintf(", %d%%", processed*100/estimated)
+	// The cached files take no time, so the remaining bytes are hashed at the rate of the hashed ones
+	if hashedBytes > 0 && elapsed > 0 {
+		remaining := time.Duration(float64(estimated-processed) / float64(hashedBytes) * float64(elapsed))
+		line += T(", %s left", remaining.Round(time.Second))
+	}
+	return line
+}
+
+// draw writes the line over the previous one, final leaves it with a newline
+func (b *UploadProgressBar) draw(final bool) {
+	line := b.Line()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	padding := ""
+	if width := len([]rune(line)); width < b.lineWidth {
+		padding = strings.Repeat(" ", b.lineWidth-width)
+	} else {
+		b.lineWidth = width
+	}
+	end := ""
+	if final {
+		end = "\n"
+		b.lineWidth = 0
+	}
+	fmt.Fprint(b.w, "\r"+line+padding+end)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadProgressBar(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	w := &bytes.Buffer{}
+	bar := NewUploadProgressBar(w, func() time.Time {
+		return now
+	})
+
+	paths := NewPathCollector(&snapshotfs.NullUploadProgress{}, "assets")
+	var progress snapshotfs.UploadProgress = bar.Wrap("./assets", paths)
+	progress.UploadStarted()
+	progress.CachedFile("cached.png", 3<<20)
+	progress.HashingFile("hashed.png")
+	progress.HashedBytes(1 << 20)
+	progress.FinishedHashingFile("hashed.png", 1<<20)
+	progress.FinishedFile("hashed.png", nil)
+	progress.UploadedBytes(512 << 10)
+	assert.Equal(t, "./assets: 1 files hashed (1.0 MiB), 1 cached (3.0 MiB), 512.0 KiB uploaded", bar.Line())
+
+	// The remaining 4 MiB are hashed at the rate of the hashed MiB
+	progress.EstimatedDataSize(3, 8<<20)
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, "./assets: 1 files hashed (1.0 MiB), 1 cached (3.0 MiB), 512.0 KiB uploaded, 50%, 40s left", bar.Line())
+
+	progress.UploadFinished()
+	assert.True(t, strings.HasPrefix(w.String(), "\r./assets: 1 files hashed"))
+	assert.True(t, strings.HasSuffix(w.String(), "40s left\n"))
+	// The progress is passed on
+	assert.Equal(t, []string{"assets/hashed.png"}, paths.Paths())
+
+	// The next directory starts from zero
+	bar.Wrap("./textures", &snapshotfs.NullUploadProgress{})
+	assert.Equal(t, "./textures: 0 files hashed (0.0 B), 0 cached (0.0 B), 0.0 B uploaded", bar.Line())
+}
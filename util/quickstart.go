@@ -0,0 +1,136 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// QuickstartDir is the asset directory of the quickstart project
+const QuickstartDir = "./assets"
+
+// quickstartAssets are the sample assets of the quickstart project by their path in the asset directory
+var quickstartAssets = map[string]string{
+	"models/tree.obj":     "# A tree with a single triangle\nv 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n",
+	"textures/grass.ppm":  "P3\n2 1\n255\n34 139 34 50 205 50\n",
+	"sounds/readme.txt":   "Sounds, textures and models are too big for git, gasset keeps them in a kopia repository.\n",
+	"levels/level-01.txt": "#####\n#S G#\n#####\n",
+}
+
+// QuickstartProject is the throwaway git repository of the quickstart with its own filesystem storage and config directory
+type QuickstartProject struct {
+	// Path is the root of the git repository
+	Path string
+	// StoragePath is the directory of the filesystem storage of the repository
+	StoragePath string
+	// ConfigPath replaces the user config directory, it keeps the kopia config of the quickstart
+	ConfigPath string
+}
+
+// CreateQuickstartProject creates a git repository with sample assets in root, which has to be empty if it exists.
+// The .gasset file stores the repository next to it and the .env file has the password. The kopia config
+// goes to the ConfigPath of the project instead of the user config directory so that removing root removes everything.
+func CreateQuickstartProject(root string, password string) (*QuickstartProject, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("%s is not empty, the quickstart needs a new directory", root)
+	}
+
+	project := &QuickstartProject{
+		Path:        filepath.Join(root, "project"),
+		StoragePath: filepath.Join(root, "repository"),
+		ConfigPath:  filepath.Join(root, "config"),
+	}
+	if err := os.MkdirAll(project.Path, 0o755); err != nil {
+		return nil, err
+	}
+	if _, err := gitOutput(project.Path, "init", "--quiet"); err != nil {
+		return nil, err
+	}
+
+	for _, assetPath := range project.AssetPaths() {
+		if err := os.MkdirAll(filepath.Dir(project.AssetPath(assetPath)), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(project.AssetPath(assetPath), []byte(quickstartAssets[assetPath]), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	config := &Config{
+		Dirs: []string{QuickstartDir},
+		Kopia: &repo.LocalConfig{
+			Storage: &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{Path: project.StoragePath}},
+		},
+	}
+	if err := SaveConfig(project.Path, config); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(project.Path, ".env"), []byte(EnvPassword+"="+password+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	if _, err := AppendGitIgnore(project.Path, []string{"/" + path.Clean(QuickstartDir) + "/", ".env"}, nil); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// AssetPaths returns the paths of the sample assets in the asset directory, sorted
+func (p *QuickstartProject) AssetPaths() []string {
+	var paths []string
+	for assetPath := range quickstartAssets {
+		paths = append(paths, assetPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// AssetPath returns the path on the disk of the sample asset
+func (p *QuickstartProject) AssetPath(assetPath string) string {
+	return filepath.Join(p.Path, QuickstartDir, filepath.FromSlash(assetPath))
+}
+
+// CheckAssets returns an error if a sample asset is missing or differs from the one the project was created with
+func (p *QuickstartProject) CheckAssets() error {
+	for _, assetPath := range p.AssetPaths() {
+		content, err := os.ReadFile(p.AssetPath(assetPath))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(content, []byte(quickstartAssets[assetPath])) {
+			return fmt.Errorf("%s differs from the sample asset", assetPath)
+		}
+	}
+	return nil
+}
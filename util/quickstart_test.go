@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateQuickstartProject(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "quickstart")
+
+	project, err := CreateQuickstartProject(root, "password")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, project.CheckAssets())
+
+	config, err := GetConfig(project.Path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{QuickstartDir}, config.Dirs)
+	if assert.NotNil(t, config.Kopia) && assert.NotNil(t, config.Kopia.Storage) {
+		assert.Equal(t, project.StoragePath, config.Kopia.Storage.Config.(*filesystem.Options).Path)
+	}
+	assert.Equal(t, filepath.Join(root, "config"), project.ConfigPath, "the kopia config is kept in the quickstart directory")
+	gitIgnore, err := os.ReadFile(filepath.Join(project.Path, ".gitignore"))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(gitIgnore), "/assets/")
+	}
+
+	lost := project.AssetPaths()[0]
+	assert.NoError(t, os.WriteFile(project.AssetPath(lost), []byte("changed"), 0o644))
+	assert.Error(t, project.CheckAssets(), "CheckAssets() with a changed asset")
+	assert.NoError(t, os.Remove(project.AssetPath(lost)))
+	assert.Error(t, project.CheckAssets(), "CheckAssets() with a deleted asset")
+
+	_, err = CreateQuickstartProject(root, "password")
+	assert.Error(t, err, "CreateQuickstartProject() in a directory which is not empty")
+}
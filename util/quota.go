@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// DefaultQuotaWarnPercent is the share of a quota from which snap warns
+const DefaultQuotaWarnPercent = 80
+
+const bytesPerGiB = 1 << 30
+
+// QuotaOptions are soft limits of the storage. They are never enforced, snap only warns when the repository gets close to them.
+type QuotaOptions struct {
+	LimitBytes       int64   `json:"limitBytes,omitempty"`
+	PricePerGiBMonth float64 `json:"pricePerGiBMonth,omitempty"`
+	MonthlyBudget    float64 `json:"monthlyBudget,omitempty"`
+	WarnPercent      int     `json:"warnPercent,omitempty"`
+}
+
+func (q *QuotaOptions) warnPercent() int {
+	if q.WarnPercent <= 0 {
+		return DefaultQuotaWarnPercent
+	}
+	return q.WarnPercent
+}
+
+// Warnings returns a warning for each quota the usage in bytes reached the warning threshold of
+func (q *QuotaOptions) Warnings(usage int64) []string {
+	var warnings []string
+	percent := q.warnPercent()
+
+	if q.LimitBytes > 0 && usage*100 >= q.LimitBytes*int64(percent) {
+		warnings = append(warnings, fmt.Sprintf("The repository uses %s of the %s storage limit (%d%%)",
+			FormatBytes(usage), FormatBytes(q.LimitBytes), usage*100/q.LimitBytes))
+	}
+
+	if q.PricePerGiBMonth > 0 && q.MonthlyBudget > 0 {
+		cost := float64(usage) / bytesPerGiB * q.PricePerGiBMonth
+		if cost*100 >= q.MonthlyBudget*float64(percent) {
+			warnings = append(warnings, fmt.Sprintf("The repository costs an estimated %.2f of the %.2f monthly storage budget (%.0f%%)",
+				cost, q.MonthlyBudget, cost*100/q.MonthlyBudget))
+		}
+	}
+
+	return warnings
+}
+
+// StorageUsage returns the bytes stored by the repository, which is what S3 compatible providers bill the storage by.
+// It lists the blobs of the repository as there is no usage API common to the providers.
+func StorageUsage(ctx context.Context, rep repo.Repository) (int64, error) {
+	directRep, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return 0, errors.New("the storage usage is not available through a kopia API server")
+	}
+
+	blobs, err := blob.ListAllBlobs(ctx, directRep.BlobReader(), "")
+	if err != nil {
+		return 0, err
+	}
+	return blob.TotalLength(blobs), nil
+}
+
+// FormatBytes returns a human-readable size
+func FormatBytes(bytes int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
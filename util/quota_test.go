@@ -0,0 +1,100 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQuotaOptions_Warnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota QuotaOptions
+		usage int64
+		want  []string
+	}{
+		{
+			name:  "Below the default threshold",
+			quota: QuotaOptions{LimitBytes: 100 * bytesPerGiB},
+			usage: 79 * bytesPerGiB,
+		},
+		{
+			name:  "Reached the default threshold",
+			quota: QuotaOptions{LimitBytes: 100 * bytesPerGiB},
+			usage: 80 * bytesPerGiB,
+			want:  []string{"The repository uses 80.0 GiB of the 100.0 GiB storage limit (80%)"},
+		},
+		{
+			name:  "Reached the configured threshold of the budget",
+			quota: QuotaOptions{PricePerGiBMonth: 0.02, MonthlyBudget: 1, WarnPercent: 50},
+			usage: 30 * bytesPerGiB,
+			want:  []string{"The repository costs an estimated 0.60 of the 1.00 monthly storage budget (60%)"},
+		},
+		{
+			name:  "Budget without a price",
+			quota: QuotaOptions{MonthlyBudget: 1},
+			usage: 1000 * bytesPerGiB,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.quota.Warnings(tt.usage))
+		})
+	}
+}
+
+func TestStorageUsage(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+
+	st, err := SetupFakeRepository(ctx, op, t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rep.Close(ctx)
+
+	var want int64
+	for _, data := range st.blobs {
+		want += int64(len(data))
+	}
+
+	usage, err := StorageUsage(ctx, rep)
+	assert.NoError(t, err)
+	assert.Equal(t, want, usage)
+	assert.Positive(t, usage)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512.0 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0 TiB", FormatBytes(2<<40))
+}
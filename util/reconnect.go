@@ -0,0 +1,104 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"log"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Backoff is the exponential backoff between the attempts to reconnect to the kopia API server
+type Backoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Attempts int
+}
+
+// DefaultReconnectBackoff rides out a restart of the kopia API server of a few minutes
+var DefaultReconnectBackoff = Backoff{Initial: time.Second, Max: time.Minute, Attempts: 10}
+
+// Delay returns the wait before the given zero based attempt.
+// The delay doubles with each attempt up to Max and is jittered between half and all of it
+// so that the clients of a restarted server do not reconnect all at once.
+func (b Backoff) Delay(attempt int, randIntn func(n int) int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(randIntn(int(half)+1))
+}
+
+// IsServerUnavailable tells whether an error is caused by the kopia API server being down or restarting,
+// as opposed to errors which would fail again after reconnecting
+func IsServerUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if status.Code(err) == codes.Unavailable {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// ConnectsToServer tells whether the repository is accessed through a kopia API server
+func (op *Options) ConnectsToServer() bool {
+	return op.Config != nil && op.Config.Kopia != nil && op.Config.Kopia.APIServer != nil
+}
+
+// RetryServer runs fn and, while it fails because the kopia API server is unavailable, runs it again after a backoff.
+// fn is responsible for opening the repository so that each attempt uses a new connection.
+// Without an API server fn runs once as there is nothing to reconnect to.
+func (op *Options) RetryServer(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := fn(ctx)
+	if !op.ConnectsToServer() {
+		return err
+	}
+
+	for attempt := 0; IsServerUnavailable(err) && attempt < op.Reconnect.Attempts; attempt++ {
+		delay := op.Reconnect.Delay(attempt, op.RandIntn)
+		log.Printf("Kopia API server is unavailable, reconnecting in %s (attempt %d of %d): %v", delay.Round(time.Millisecond), attempt+1, op.Reconnect.Attempts, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+
+		err = fn(ctx)
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	backoff := Backoff{Initial: time.Second, Max: 5 * time.Second}
+	noJitter := func(n int) int { return n - 1 }
+
+	assert.Equal(t, time.Second, backoff.Delay(0, noJitter))
+	assert.Equal(t, 2*time.Second, backoff.Delay(1, noJitter))
+	assert.Equal(t, 4*time.Second, backoff.Delay(2, noJitter))
+	assert.Equal(t, 5*time.Second, backoff.Delay(3, noJitter))
+	assert.Equal(t, 5*time.Second, backoff.Delay(100, noJitter))
+	assert.Equal(t, 2500*time.Millisecond, backoff.Delay(100, func(int) int { return 0 }))
+}
+
+func TestIsServerUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "No error", err: nil, want: false},
+		{name: "Unavailable gRPC status", err: fmt.Errorf("session: %w", status.Error(codes.Unavailable, "closing")), want: true},
+		{name: "Other gRPC status", err: status.Error(codes.PermissionDenied, "denied"), want: false},
+		{name: "Refused connection", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "Joined with other errors", err: errors.Join(errors.New("./assets"), syscall.ECONNRESET), want: true},
+		{name: "Canceled", err: errors.Join(context.Canceled, syscall.ECONNRESET), want: false},
+		{name: "Other error", err: errors.New("invalid password"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsServerUnavailable(tt.err))
+		})
+	}
+}
+
+func TestOptions_RetryServer(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	unavailable := status.Error(codes.Unavailable, "server restarting")
+
+	t.Run("Runs once without an API server", func(t *testing.T) {
+		op := options.OptionsWithGassetId.Clone()
+		op.Reconnect = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 3}
+
+		calls := 0
+		err := op.RetryServer(context.Background(), func(context.Context) error {
+			calls++
+			return unavailable
+		})
+		assert.ErrorIs(t, err, unavailable)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Reconnects until the server is back", func(t *testing.T) {
+		op := options.OptionsWithGassetId.Clone()
+		op.Config.Kopia.APIServer = &repo.APIServerInfo{BaseURL: "https://kopia.example.com"}
+		op.Reconnect = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 3}
+
+		calls := 0
+		err := op.RetryServer(context.Background(), func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return unavailable
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Gives up after the attempts", func(t *testing.T) {
+		op := options.OptionsWithGassetId.Clone()
+		op.Config.Kopia.APIServer = &repo.APIServerInfo{BaseURL: "https://kopia.example.com"}
+		op.Reconnect = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 2}
+
+		calls := 0
+		err := op.RetryServer(context.Background(), func(context.Context) error {
+			calls++
+			return unavailable
+		})
+		assert.ErrorIs(t, err, unavailable)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Does not retry other errors", func(t *testing.T) {
+		op := options.OptionsWithGassetId.Clone()
+		op.Config.Kopia.APIServer = &repo.APIServerInfo{BaseURL: "https://kopia.example.com"}
+		op.Reconnect = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 2}
+
+		calls := 0
+		invalid := errors.New("invalid password")
+		err := op.RetryServer(context.Background(), func(context.Context) error {
+			calls++
+			return invalid
+		})
+		assert.ErrorIs(t, err, invalid)
+		assert.Equal(t, 1, calls)
+	})
+}
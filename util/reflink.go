@@ -0,0 +1,233 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCloneNotSupported is returned by CloneFile when the filesystem can't share the content of files
+var ErrCloneNotSupported = errors.New("the filesystem does not support reflinks")
+
+// RestoredFile is a local file with the content of an object as it was restored
+type RestoredFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// RestoredContent remembers where the content of the objects was restored to, so that later restores,
+// e.g. after switching branches, can link to the local files instead of downloading and copying the bytes again
+type RestoredContent struct {
+	mu sync.Mutex
+	// Files are keyed by the string of the object id, which has no text form to key a JSON object with
+	Files map[string]RestoredFile `json:"files"`
+}
+
+func (op *Options) GetRestoredContentPath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "restored-"+op.Config.GassetId+".json"), nil
+}
+
+// LoadRestoredContent returns the restored content or an empty one if nothing was restored yet
+func (op *Options) LoadRestoredContent() (*RestoredContent, error) {
+	contentPath, err := op.GetRestoredContentPath()
+	if err != nil {
+		return nil, err
+	}
+
+	contentBytes, err := os.ReadFile(contentPath)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return &RestoredContent{Files: map[string]RestoredFile{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content := &RestoredContent{}
+	if err := json.Unmarshal(contentBytes, content); err != nil {
+		return nil, err
+	}
+	if content.Files == nil {
+		content.Files = map[string]RestoredFile{}
+	}
+	return content, nil
+}
+
+func (op *Options) SaveRestoredContent(content *RestoredContent) error {
+	contentPath, err := op.GetRestoredContentPath()
+	if err != nil {
+		return err
+	}
+
+	content.mu.Lock()
+	contentBytes, err := json.Marshal(content)
+	content.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(contentPath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(contentPath, contentBytes, 0o600)
+}
+
+// Find returns the local file with the content of the object if it was not changed since it was restored
+func (c *RestoredContent) Find(id object.ID) (string, bool) {
+	c.mu.Lock()
+	restored, ok := c.Files[id.String()]
+	c.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	stat, err := os.Stat(restored.Path)
+	if err != nil || !stat.Mode().IsRegular() || stat.Size() != restored.Size || !stat.ModTime().Equal(restored.ModTime) {
+		return "", false
+	}
+	return restored.Path, true
+}
+
+// Record remembers that the local file has the content of the object
+func (c *RestoredContent) Record(id object.ID, localPath string) {
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Files[id.String()] = RestoredFile{Path: localPath, Size: stat.Size(), ModTime: stat.ModTime()}
+}
+
+// LinkOutput restores the files whose content is already on the local disk by reflinks, or by hard links if
+// hardLink is set, falling back to the wrapped output when the content can't be shared
+type LinkOutput struct {
+	restore.Output
+
+	targetPath     string
+	overwriteFiles bool
+	content        *RestoredContent
+	hardLink       bool
+}
+
+// NewLinkOutput wraps an output restoring into the target of options, which must be on the same filesystem
+// as the restored content for the links to work
+func NewLinkOutput(output restore.Output, options *restore.FilesystemOutput, content *RestoredContent, hardLink bool) *LinkOutput {
+	return &LinkOutput{
+		Output:         output,
+		targetPath:     options.TargetPath,
+		overwriteFiles: options.OverwriteFiles,
+		content:        content,
+		hardLink:       hardLink,
+	}
+}
+
+// WriteFile implements restore.Output
+func (o *LinkOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	targetPath := filepath.Join(o.targetPath, filepath.FromSlash(relativePath))
+	id, hasId := fileObjectID(f)
+
+	if hasId {
+		if localPath, ok := o.content.Find(id); ok && localPath != targetPath {
+			if err := o.linkFile(localPath, targetPath, f); err == nil {
+				o.content.Record(id, targetPath)
+				return nil
+			}
+		}
+	}
+
+	if err := o.Output.WriteFile(ctx, relativePath, f); err != nil {
+		return err
+	}
+	if hasId {
+		o.content.Record(id, targetPath)
+	}
+	return nil
+}
+
+// FileExists implements restore.Output
+func (o *LinkOutput) FileExists(ctx context.Context, relativePath string, f fs.File) bool {
+	exists := o.Output.FileExists(ctx, relativePath, f)
+	if id, ok := fileObjectID(f); exists && ok {
+		o.content.Record(id, filepath.Join(o.targetPath, filepath.FromSlash(relativePath)))
+	}
+	return exists
+}
+
+// linkFile links the local file next to the target and renames it into place, so that the target is never half-written
+func (o *LinkOutput) linkFile(localPath string, targetPath string, f fs.File) error {
+	if _, err := os.Lstat(targetPath); err == nil && !o.overwriteFiles {
+		return fmt.Errorf("unable to create %q, it already exists", targetPath)
+	}
+
+	linkPath := filepath.Join(filepath.Dir(targetPath), ".gasset-link-"+filepath.Base(targetPath))
+	if o.hardLink {
+		// The attributes are shared with the linked file so they are left alone
+		if err := os.Link(localPath, linkPath); err != nil {
+			return err
+		}
+	} else {
+		if err := CloneFile(localPath, linkPath); err != nil {
+			return err
+		}
+		if err := os.Chmod(linkPath, f.Mode().Perm()); err != nil {
+			os.Remove(linkPath)
+			return err
+		}
+		if err := os.Chtimes(linkPath, f.ModTime(), f.ModTime()); err != nil {
+			os.Remove(linkPath)
+			return err
+		}
+	}
+
+	if err := os.Rename(linkPath, targetPath); err != nil {
+		os.Remove(linkPath)
+		return err
+	}
+	return nil
+}
+
+// fileObjectID returns the object id of a file of a snapshot
+func fileObjectID(f fs.File) (object.ID, bool) {
+	entry, ok := f.(snapshot.HasDirEntry)
+	if !ok {
+		return object.EmptyID, false
+	}
+	return entry.DirEntry().ObjectID, true
+}
+
+var _ restore.Output = (*LinkOutput)(nil)
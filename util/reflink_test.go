@@ -0,0 +1,139 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// snapshotFile is a local file standing in for a file of a snapshot with the given object id
+type snapshotFile struct {
+	fs.File
+	id object.ID
+}
+
+func (f snapshotFile) DirEntry() *snapshot.DirEntry {
+	return &snapshot.DirEntry{ObjectID: f.id}
+}
+
+func TestRestoredContent(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.bin")
+	if err := os.WriteFile(file, []byte("a"), 0o644); err != nil {
+		t.FailNow()
+	}
+	id, err := object.ParseID("k0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.FailNow()
+	}
+
+	content := &RestoredContent{Files: map[string]RestoredFile{}}
+	_, ok := content.Find(id)
+	assert.False(t, ok)
+
+	content.Record(id, file)
+	found, ok := content.Find(id)
+	assert.True(t, ok)
+	assert.Equal(t, file, found)
+
+	// The content is saved as JSON between the restores
+	contentBytes, err := json.Marshal(content)
+	if !assert.NoError(t, err) {
+		return
+	}
+	saved := &RestoredContent{}
+	if !assert.NoError(t, json.Unmarshal(contentBytes, saved)) {
+		return
+	}
+	found, ok = saved.Find(id)
+	assert.True(t, ok)
+	assert.Equal(t, file, found)
+
+	// A file changed after it was restored can't be linked to
+	if err := os.Chtimes(file, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.FailNow()
+	}
+	_, ok = content.Find(id)
+	assert.False(t, ok)
+}
+
+func TestLinkOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		hardLink bool
+	}{
+		{name: "Clone the restored content or copy it without reflinks"},
+		{name: "Hard link the restored content", hardLink: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			root := t.TempDir()
+			source := filepath.Join(root, "source.bin")
+			if err := os.WriteFile(source, []byte("restored"), 0o644); err != nil {
+				t.FailNow()
+			}
+			entry, err := localfs.NewEntry(source)
+			if !assert.NoError(t, err) {
+				return
+			}
+			id, err := object.ParseID("k0123456789abcdef0123456789abcdef")
+			if err != nil {
+				t.FailNow()
+			}
+			file := snapshotFile{File: entry.(fs.File), id: id}
+
+			fsOutput := &restore.FilesystemOutput{TargetPath: filepath.Join(root, "target"), OverwriteFiles: true}
+			if err := os.MkdirAll(fsOutput.TargetPath, 0o755); err != nil {
+				t.FailNow()
+			}
+			if err := fsOutput.Init(ctx); err != nil {
+				t.FailNow()
+			}
+			content := &RestoredContent{Files: map[string]RestoredFile{}}
+			output := NewLinkOutput(fsOutput, fsOutput, content, tt.hardLink)
+
+			// The first restore copies the content, the second one links to it
+			assert.NoError(t, output.WriteFile(ctx, "a.bin", file))
+			assert.NoError(t, output.WriteFile(ctx, "b.bin", file))
+
+			for _, name := range []string{"a.bin", "b.bin"} {
+				restored, err := os.ReadFile(filepath.Join(fsOutput.TargetPath, name))
+				assert.NoError(t, err)
+				assert.Equal(t, "restored", string(restored))
+			}
+
+			a, err := os.Stat(filepath.Join(fsOutput.TargetPath, "a.bin"))
+			assert.NoError(t, err)
+			b, err := os.Stat(filepath.Join(fsOutput.TargetPath, "b.bin"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.hardLink, os.SameFile(a, b))
+			assert.Equal(t, filepath.Join(fsOutput.TargetPath, "b.bin"), content.Files[file.id.String()].Path)
+		})
+	}
+}
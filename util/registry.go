@@ -0,0 +1,206 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// CheckoutRegistry maps the gasset ids to the local checkouts using them so that the local state
+// of a gasset id can be told apart from the one left behind by checkouts which were deleted
+type CheckoutRegistry struct {
+	GassetIds map[string][]RegisteredCheckout `json:"gassetIds"`
+}
+
+type CheckoutStatus string
+
+const (
+	CheckoutActive CheckoutStatus = "active"
+	// CheckoutMissing is a checkout which was deleted or moved, or no longer has a .gasset file
+	CheckoutMissing    CheckoutStatus = "missing"
+	CheckoutSwitched   CheckoutStatus = "switched"
+	CheckoutUnreadable CheckoutStatus = "unreadable"
+)
+
+// RegisteredCheckout is the root of a git repository which ran a command with a gasset id
+type RegisteredCheckout struct {
+	Path     string    `json:"path"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+func (op *Options) GetCheckoutRegistryPath() (string, error) {
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "checkouts.json"), nil
+}
+
+// LoadCheckoutRegistry returns the registered checkouts or an empty registry if none were registered
+func (op *Options) LoadCheckoutRegistry() (*CheckoutRegistry, error) {
+	registryPath, err := op.GetCheckoutRegistryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	registryBytes, err := os.ReadFile(registryPath)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return &CheckoutRegistry{GassetIds: map[string][]RegisteredCheckout{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &CheckoutRegistry{}
+	if err := json.Unmarshal(registryBytes, registry); err != nil {
+		return nil, err
+	}
+	if registry.GassetIds == nil {
+		registry.GassetIds = map[string][]RegisteredCheckout{}
+	}
+	return registry, nil
+}
+
+func (op *Options) SaveCheckoutRegistry(registry *CheckoutRegistry) error {
+	registryPath, err := op.GetCheckoutRegistryPath()
+	if err != nil {
+		return err
+	}
+
+	registryBytes, err := json.Marshal(registry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(registryPath), 0o700); err != nil {
+		return err
+	}
+	// The registry is shared by all the checkouts so it is replaced atomically, through a temp file
+	// of its own per command so that commands running at the same time do not write into each other's
+	tempFile, err := os.CreateTemp(filepath.Dir(registryPath), "checkouts.json.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(registryBytes)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), registryPath)
+}
+
+// RegisterCheckout records that the working directory used its gasset id at the given time
+func (op *Options) RegisterCheckout(now time.Time) error {
+	if op.Config == nil || op.Config.GassetId == "" {
+		return nil
+	}
+
+	registry, err := op.LoadCheckoutRegistry()
+	if err != nil {
+		return err
+	}
+	registry.Register(op.Config.GassetId, op.WorkingDirectory, now)
+	return op.SaveCheckoutRegistry(registry)
+}
+
+// Register adds the checkout to the gasset id or updates the time it was last used.
+// A checkout switched to another gasset id is moved, leaving the old gasset id registered without it.
+func (r *CheckoutRegistry) Register(gassetId string, checkoutPath string, now time.Time) {
+	for otherId, checkouts := range r.GassetIds {
+		if otherId != gassetId {
+			r.GassetIds[otherId] = slices.DeleteFunc(checkouts, func(checkout RegisteredCheckout) bool {
+				return checkout.Path == checkoutPath
+			})
+		}
+	}
+
+	checkouts := r.GassetIds[gassetId]
+	for i := range checkouts {
+		if checkouts[i].Path == checkoutPath {
+			checkouts[i].LastUsed = now.UTC()
+			return
+		}
+	}
+	r.GassetIds[gassetId] = append(checkouts, RegisteredCheckout{Path: checkoutPath, LastUsed: now.UTC()})
+}
+
+// LiveGassetIds returns the gasset ids which are still used by at least one of their registered checkouts,
+// i.e. the checkout exists and its .gasset file has not been switched to another gasset id
+func (r *CheckoutRegistry) LiveGassetIds() map[string]bool {
+	live := map[string]bool{}
+	for gassetId, checkouts := range r.GassetIds {
+		for _, checkout := range checkouts {
+			// A .gasset file which cannot be read is kept on the safe side, its local state is not orphaned
+			if status := checkout.Status(gassetId); status == CheckoutActive || status == CheckoutUnreadable {
+				live[gassetId] = true
+				break
+			}
+		}
+	}
+	return live
+}
+
+// ForgetStale drops the checkouts which are missing or switched to another gasset id and returns them by gasset id.
+// The gasset ids stay registered so that housekeeping still knows their local state is orphaned.
+func (r *CheckoutRegistry) ForgetStale() map[string][]RegisteredCheckout {
+	forgotten := map[string][]RegisteredCheckout{}
+	for gassetId, checkouts := range r.GassetIds {
+		r.GassetIds[gassetId] = slices.DeleteFunc(checkouts, func(checkout RegisteredCheckout) bool {
+			if status := checkout.Status(gassetId); status != CheckoutMissing && status != CheckoutSwitched {
+				return false
+			}
+			forgotten[gassetId] = append(forgotten[gassetId], checkout)
+			return true
+		})
+	}
+	return forgotten
+}
+
+// SortedGassetIds returns the registered gasset ids sorted
+func (r *CheckoutRegistry) SortedGassetIds() []string {
+	gassetIds := make([]string, 0, len(r.GassetIds))
+	for gassetId := range r.GassetIds {
+		gassetIds = append(gassetIds, gassetId)
+	}
+	sort.Strings(gassetIds)
+	return gassetIds
+}
+
+// Status tells whether the checkout still uses the gasset id it is registered with
+func (c RegisteredCheckout) Status(gassetId string) CheckoutStatus {
+	config, err := GetConfig(c.Path)
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		return CheckoutMissing
+	case err != nil:
+		return CheckoutUnreadable
+	case config.GassetId != gassetId:
+		return CheckoutSwitched
+	default:
+		return CheckoutActive
+	}
+}
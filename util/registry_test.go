@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegisterCheckout(t *testing.T) {
+	userDir := t.TempDir()
+	op := &Options{
+		WorkingDirectory: "/work/game",
+		Config:           &Config{GassetId: "abcd1234"},
+		OsUserConfigDir: func() (string, error) {
+			return userDir, nil
+		},
+	}
+	first := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	assert.NoError(t, op.RegisterCheckout(first))
+	assert.NoError(t, op.RegisterCheckout(second))
+	op.WorkingDirectory = "/work/game-copy"
+	assert.NoError(t, op.RegisterCheckout(second))
+
+	registry, err := op.LoadCheckoutRegistry()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []RegisteredCheckout{
+		{Path: "/work/game", LastUsed: second},
+		{Path: "/work/game-copy", LastUsed: second},
+	}, registry.GassetIds["abcd1234"])
+}
+
+func TestLiveGassetIds(t *testing.T) {
+	checkout := t.TempDir()
+	if err := os.WriteFile(filepath.Join(checkout, ".gasset"), []byte(`{"gassetId": "live1234"}`), 0600); err != nil {
+		t.FailNow()
+	}
+
+	registry := &CheckoutRegistry{GassetIds: map[string][]RegisteredCheckout{
+		"live1234":     {{Path: filepath.Join(checkout, "deleted")}, {Path: checkout}},
+		"switched1234": {{Path: checkout}},
+		"deleted1234":  {{Path: filepath.Join(checkout, "deleted")}},
+	}}
+	assert.Equal(t, map[string]bool{"live1234": true}, registry.LiveGassetIds())
+}
+
+func TestRegisterSwitchedCheckout(t *testing.T) {
+	registry := &CheckoutRegistry{GassetIds: map[string][]RegisteredCheckout{}}
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	registry.Register("old12345", "/work/game", now)
+	registry.Register("new12345", "/work/game", now)
+
+	assert.Equal(t, map[string][]RegisteredCheckout{
+		"old12345": {},
+		"new12345": {{Path: "/work/game", LastUsed: now}},
+	}, registry.GassetIds)
+}
+
+func TestForgetStale(t *testing.T) {
+	checkout := t.TempDir()
+	broken := t.TempDir()
+	if err := os.WriteFile(filepath.Join(checkout, ".gasset"), []byte(`{"gassetId": "live1234"}`), 0600); err != nil {
+		t.FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(broken, ".gasset"), []byte(`{`), 0600); err != nil {
+		t.FailNow()
+	}
+	missing := RegisteredCheckout{Path: filepath.Join(checkout, "deleted")}
+
+	registry := &CheckoutRegistry{GassetIds: map[string][]RegisteredCheckout{
+		"live1234":     {missing, {Path: checkout}},
+		"switched1234": {{Path: checkout}},
+		"broken1234":   {{Path: broken}},
+	}}
+	assert.Equal(t, map[string][]RegisteredCheckout{
+		"live1234":     {missing},
+		"switched1234": {{Path: checkout}},
+	}, registry.ForgetStale())
+	assert.Equal(t, map[string][]RegisteredCheckout{
+		"live1234":     {{Path: checkout}},
+		"switched1234": {},
+		"broken1234":   {{Path: broken}},
+	}, registry.GassetIds)
+	assert.Equal(t, map[string]bool{"live1234": true, "broken1234": true}, registry.LiveGassetIds())
+}
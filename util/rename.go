@@ -0,0 +1,139 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DirRename records that an asset directory was moved, so that the snapshots taken at its previous path
+// stay part of its history
+type DirRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// sameDir tells whether two asset directories of the .gasset file are the same
+func sameDir(a string, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// PreviousDirs returns the paths an asset directory had before it was renamed, the most recent first
+func (c *Config) PreviousDirs(dir string) []string {
+	var previous []string
+	for i := len(c.Renames) - 1; i >= 0; i-- {
+		rename := c.Renames[i]
+		// A directory renamed back to an earlier path would otherwise be followed forever
+		if sameDir(rename.To, dir) && !slices.ContainsFunc(previous, func(p string) bool { return sameDir(p, rename.From) }) {
+			previous = append(previous, rename.From)
+			dir = rename.From
+		}
+	}
+	return previous
+}
+
+// RenameDir replaces an asset directory by its new path everywhere in the config and records the rename
+func (c *Config) RenameDir(oldDir string, newDir string) error {
+	index := slices.IndexFunc(c.Dirs, func(configured string) bool {
+		return sameDir(configured, oldDir)
+	})
+	if index < 0 {
+		return fmt.Errorf("%s is not an asset directory of the .gasset file", oldDir)
+	}
+	if slices.ContainsFunc(c.Dirs, func(configured string) bool { return sameDir(configured, newDir) }) {
+		return fmt.Errorf("%s is an asset directory of the .gasset file already", newDir)
+	}
+	oldDir = c.Dirs[index]
+
+	c.Dirs[index] = newDir
+	if c.Derived != nil {
+		for i, derived := range c.Derived.Dirs {
+			if sameDir(derived, oldDir) {
+				c.Derived.Dirs[i] = newDir
+			}
+		}
+	}
+	for i, hook := range c.RestoreHooks {
+		if sameDir(hook.Dir, oldDir) {
+			c.RestoreHooks[i].Dir = newDir
+		}
+	}
+	for name, patterns := range c.Profiles {
+		for i, pattern := range patterns {
+			c.Profiles[name][i] = renamePath(pattern, oldDir, newDir)
+		}
+	}
+	c.Renames = append(c.Renames, DirRename{From: oldDir, To: newDir})
+	return c.CheckDirs()
+}
+
+// renamePath moves a slash separated path from inside the old directory into the new one, keeping a trailing slash
+func renamePath(filePath string, oldDir string, newDir string) string {
+	oldPrefix := path.Clean(filepath.ToSlash(oldDir))
+	cleaned := path.Clean(filePath)
+	if cleaned != oldPrefix && !strings.HasPrefix(cleaned, oldPrefix+"/") {
+		return filePath
+	}
+	renamed := path.Join(path.Clean(filepath.ToSlash(newDir)), strings.TrimPrefix(cleaned, oldPrefix))
+	if strings.HasSuffix(filePath, "/") {
+		renamed += "/"
+	}
+	return renamed
+}
+
+// RenameDirInConfig renames an asset directory in the .gasset file in the directory at path
+func RenameDirInConfig(path string, oldDir string, newDir string) error {
+	config, err := GetConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := config.RenameDir(oldDir, newDir); err != nil {
+		return err
+	}
+	return SaveConfig(path, config)
+}
+
+// RenameDir moves the hashes of the files in the old asset directory to the new one
+func (h *LockHashes) RenameDir(oldDir string, newDir string) {
+	files := make(map[string]string, len(h.Files))
+	for filePath, fileHash := range h.Files {
+		files[renamePath(filePath, oldDir, newDir)] = fileHash
+	}
+	h.Files = files
+
+	if h.Sizes == nil {
+		return
+	}
+	sizes := make(map[string]int64, len(h.Sizes))
+	for filePath, size := range h.Sizes {
+		sizes[renamePath(filePath, oldDir, newDir)] = size
+	}
+	h.Sizes = sizes
+}
+
+// RenameDir moves the hashes of the files in the old asset directory to the new one
+func (w *WorkingHashes) RenameDir(oldDir string, newDir string) {
+	files := make(map[string]WorkingHash, len(w.Files))
+	for filePath, fileHash := range w.Files {
+		files[renamePath(filePath, oldDir, newDir)] = fileHash
+	}
+	w.Files = files
+}
@@ -0,0 +1,240 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the report for every processed file
+const (
+	ReportActionHashed   = "hashed"
+	ReportActionCached   = "cached"
+	ReportActionExcluded = "excluded"
+	ReportActionRestored = "restored"
+	ReportActionSkipped  = "skipped"
+	ReportActionError    = "error"
+)
+
+// ReportEntry is a line of the JSONL report, the path is slash separated and relative to the working directory
+type ReportEntry struct {
+	Time       time.Time `json:"time"`
+	Operation  string    `json:"operation"`
+	Path       string    `json:"path"`
+	Action     string    `json:"action"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs int64     `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Report writes the entries of the processed files as JSON lines, it is safe for concurrent use
+type Report struct {
+	mu     sync.Mutex
+	writer io.WriteCloser
+	enc    *json.Encoder
+	err    error
+}
+
+func NewReport(writer io.WriteCloser) *Report {
+	return &Report{writer: writer, enc: json.NewEncoder(writer)}
+}
+
+// CreateReport creates or truncates the report file
+func CreateReport(reportPath string) (*Report, error) {
+	file, err := os.Create(reportPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewReport(file), nil
+}
+
+// Write adds an entry to the report. Errors don't fail the operation being reported and are returned by Close.
+func (r *Report) Write(entry ReportEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	r.err = r.enc.Encode(entry)
+}
+
+func (r *Report) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.writer.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// UploadReport is the progress of an upload recording every file of an asset directory in the report
+type UploadReport struct {
+	snapshotfs.NullUploadProgress
+
+	report *Report
+	dir    string
+
+	mu      sync.Mutex
+	started map[string]time.Time
+	bytes   map[string]int64
+	actions map[string]string
+}
+
+func NewUploadReport(report *Report, dir string) *UploadReport {
+	return &UploadReport{
+		report:  report,
+		dir:     path.Clean(dir),
+		started: map[string]time.Time{},
+		bytes:   map[string]int64{},
+		actions: map[string]string{},
+	}
+}
+
+func (p *UploadReport) write(relativePath string, action string, bytes int64, duration time.Duration, err error) {
+	entry := ReportEntry{
+		Operation:  "snap",
+		Path:       path.Join(p.dir, relativePath),
+		Action:     action,
+		Bytes:      bytes,
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	p.report.Write(entry)
+}
+
+// HashingFile implements snapshotfs.UploadProgress
+func (p *UploadReport) HashingFile(fname string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started[fname] = time.Now()
+}
+
+// FinishedHashingFile implements snapshotfs.UploadProgress
+func (p *UploadReport) FinishedHashingFile(fname string, numBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes[fname] = numBytes
+	p.actions[fname] = ReportActionHashed
+}
+
+// CachedFile implements snapshotfs.UploadProgress
+func (p *UploadReport) CachedFile(fname string, numBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes[fname] = numBytes
+	p.actions[fname] = ReportActionCached
+}
+
+// ExcludedFile implements snapshotfs.UploadProgress
+func (p *UploadReport) ExcludedFile(fname string, numBytes int64) {
+	p.write(fname, ReportActionExcluded, numBytes, 0, nil)
+}
+
+// ExcludedDir implements snapshotfs.UploadProgress
+func (p *UploadReport) ExcludedDir(dirname string) {
+	p.write(dirname, ReportActionExcluded, 0, 0, nil)
+}
+
+// Error implements snapshotfs.UploadProgress
+func (p *UploadReport) Error(fname string, err error, _ bool) {
+	p.write(fname, ReportActionError, 0, 0, err)
+}
+
+// FinishedFile implements snapshotfs.UploadProgress
+func (p *UploadReport) FinishedFile(fname string, err error) {
+	p.mu.Lock()
+	action, ok := p.actions[fname]
+	if !ok {
+		action = ReportActionHashed
+	}
+	if err != nil {
+		action = ReportActionError
+	}
+	var duration time.Duration
+	if started, ok := p.started[fname]; ok {
+		duration = time.Since(started)
+	}
+	bytes := p.bytes[fname]
+	delete(p.started, fname)
+	delete(p.bytes, fname)
+	delete(p.actions, fname)
+	p.mu.Unlock()
+
+	p.write(fname, action, bytes, duration, err)
+}
+
+// ReportOutput records every file restored to the wrapped output in the report
+type ReportOutput struct {
+	restore.Output
+
+	report *Report
+	dir    string
+}
+
+func NewReportOutput(output restore.Output, report *Report, dir string) *ReportOutput {
+	return &ReportOutput{Output: output, report: report, dir: path.Clean(dir)}
+}
+
+// WriteFile implements restore.Output
+func (o *ReportOutput) WriteFile(ctx context.Context, relativePath string, e fs.File) error {
+	started := time.Now()
+	err := o.Output.WriteFile(ctx, relativePath, e)
+
+	entry := ReportEntry{
+		Operation:  "restore",
+		Path:       path.Join(o.dir, relativePath),
+		Action:     ReportActionRestored,
+		Bytes:      e.Size(),
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		entry.Action = ReportActionError
+		entry.Error = err.Error()
+	}
+	o.report.Write(entry)
+	return err
+}
+
+// FileExists implements restore.Output
+func (o *ReportOutput) FileExists(ctx context.Context, relativePath string, e fs.File) bool {
+	exists := o.Output.FileExists(ctx, relativePath, e)
+	if exists {
+		o.report.Write(ReportEntry{
+			Operation: "restore",
+			Path:      path.Join(o.dir, relativePath),
+			Action:    ReportActionSkipped,
+			Bytes:     e.Size(),
+		})
+	}
+	return exists
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readReport(t *testing.T, reportPath string) []ReportEntry {
+	file, err := os.Open(reportPath)
+	if err != nil {
+		t.FailNow()
+	}
+	defer file.Close()
+
+	var entries []ReportEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := ReportEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.FailNow()
+		}
+		entry.Time = entry.Time.UTC()
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestUploadReport(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.jsonl")
+	report, err := CreateReport(reportPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	progress := NewUploadReport(report, "./assets")
+	progress.HashingFile("textures/wall.png")
+	progress.FinishedHashingFile("textures/wall.png", 42)
+	progress.FinishedFile("textures/wall.png", nil)
+	progress.CachedFile("theme.ogg", 7)
+	progress.FinishedFile("theme.ogg", nil)
+	progress.ExcludedDir("tmp")
+	progress.FinishedFile("locked.psd", errors.New("permission denied"))
+
+	if !assert.NoError(t, report.Close()) {
+		return
+	}
+
+	entries := readReport(t, reportPath)
+	if !assert.Len(t, entries, 4) {
+		return
+	}
+	assert.Equal(t, []string{"assets/textures/wall.png", "assets/theme.ogg", "assets/tmp", "assets/locked.psd"},
+		[]string{entries[0].Path, entries[1].Path, entries[2].Path, entries[3].Path})
+	assert.Equal(t, []string{ReportActionHashed, ReportActionCached, ReportActionExcluded, ReportActionError},
+		[]string{entries[0].Action, entries[1].Action, entries[2].Action, entries[3].Action})
+	assert.Equal(t, int64(42), entries[0].Bytes)
+	assert.Equal(t, int64(7), entries[1].Bytes)
+	assert.Equal(t, "permission denied", entries[3].Error)
+	assert.Equal(t, "snap", entries[0].Operation)
+}
+
+type existingOutput struct {
+	restore.Output
+}
+
+func (o existingOutput) FileExists(context.Context, string, fs.File) bool {
+	return true
+}
+
+func TestReportOutput(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "source.bin")
+	if err := os.WriteFile(source, []byte("restored"), 0644); err != nil {
+		t.FailNow()
+	}
+	entry, err := localfs.NewEntry(source)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	reportPath := filepath.Join(root, "report.jsonl")
+	report, err := CreateReport(reportPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The restore creates the directories before writing their files
+	fsOutput := &restore.FilesystemOutput{TargetPath: root}
+	if err := fsOutput.Init(context.Background()); err != nil {
+		t.FailNow()
+	}
+	output := NewReportOutput(fsOutput, report, "assets")
+	assert.NoError(t, output.WriteFile(context.Background(), "a.bin", entry.(fs.File)))
+	assert.True(t, NewReportOutput(existingOutput{fsOutput}, report, "assets").FileExists(context.Background(), "b.bin", entry.(fs.File)))
+
+	if !assert.NoError(t, report.Close()) {
+		return
+	}
+
+	entries := readReport(t, reportPath)
+	if !assert.Len(t, entries, 2) {
+		return
+	}
+	assert.Equal(t, ReportEntry{Time: entries[0].Time, Operation: "restore", Path: "assets/a.bin", Action: ReportActionRestored, Bytes: 8, DurationMs: entries[0].DurationMs}, entries[0])
+	assert.Equal(t, ReportEntry{Time: entries[1].Time, Operation: "restore", Path: "assets/b.bin", Action: ReportActionSkipped, Bytes: 8}, entries[1])
+}
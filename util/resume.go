@@ -0,0 +1,98 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ResumeState records what is left of an interrupted operation so that resume can pick it up
+type ResumeState struct {
+	Operation       string    `json:"operation"`
+	RemainingDirs   []string  `json:"remainingDirs"`
+	Checkpoints     []string  `json:"checkpoints,omitempty"`
+	MachineIdentity string    `json:"machineIdentity,omitempty"`
+	StartedAt       time.Time `json:"startedAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func (op *Options) GetResumeStatePath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "resume-"+op.stateID()+".json"), nil
+}
+
+func (op *Options) SaveResumeState(state *ResumeState) error {
+	statePath, err := op.GetResumeStatePath()
+	if err != nil {
+		return err
+	}
+
+	stateBytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, stateBytes, 0o600)
+}
+
+// LoadResumeState returns the state of the last interrupted operation or nil if there is none
+func (op *Options) LoadResumeState() (*ResumeState, error) {
+	statePath, err := op.GetResumeStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	stateBytes, err := os.ReadFile(statePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ResumeState{}
+	if err := json.Unmarshal(stateBytes, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (op *Options) ClearResumeState() error {
+	statePath, err := op.GetResumeStatePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
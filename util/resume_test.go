@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type ResumeSuite struct {
+	suite.Suite
+	op OptionsForTest
+}
+
+func TestResumeSuite(t *testing.T) {
+	suite.Run(t, new(ResumeSuite))
+}
+
+func (suite *ResumeSuite) SetupSuite() {
+	err := SetupTestOptions(&suite.op)
+	if err != nil {
+		suite.T().FailNow()
+	}
+}
+
+func (suite *ResumeSuite) TestResumeState() {
+	op := suite.op.OptionsWithGassetId.Clone()
+	userDir := suite.T().TempDir()
+	op.OsUserConfigDir = func() (string, error) {
+		return userDir, nil
+	}
+
+	state, err := op.LoadResumeState()
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), state, "LoadResumeState() without an interrupted operation")
+
+	want := &ResumeState{
+		Operation:     "snap",
+		RemainingDirs: []string{"./assets"},
+		Checkpoints:   []string{"checkpoint"},
+		StartedAt:     time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC),
+		UpdatedAt:     time.Date(2024, 1, 17, 10, 5, 0, 0, time.UTC),
+	}
+	assert.NoError(suite.T(), op.SaveResumeState(want))
+
+	state, err = op.LoadResumeState()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), want, state, "LoadResumeState() after SaveResumeState()")
+
+	assert.NoError(suite.T(), op.ClearResumeState())
+	state, err = op.LoadResumeState()
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), state, "LoadResumeState() after ClearResumeState()")
+}
+
+func (suite *ResumeSuite) TestResumeStateWithoutGassetId() {
+	_, err := suite.op.OptionsWithNoGassetId.LoadResumeState()
+	assert.Error(suite.T(), err)
+}
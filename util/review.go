@@ -0,0 +1,168 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ReviewChange is a changed asset file with its sizes when the lock files record them
+type ReviewChange struct {
+	Change
+	Size         int64 `json:"size,omitempty"`
+	PreviousSize int64 `json:"previousSize,omitempty"`
+}
+
+// AssetReview summarizes the asset changes between the lock files of two git refs for a pull request
+type AssetReview struct {
+	Base    string         `json:"base"`
+	Head    string         `json:"head"`
+	Changes []ReviewChange `json:"changes"`
+	// Counts are the number of changes by kind
+	Counts map[ChangeKind]int `json:"counts"`
+	// DeltaBytes is the growth of the assets, negative if they shrank, counting only the files with known sizes
+	DeltaBytes int64 `json:"deltaBytes"`
+}
+
+// GitLockFiles returns the lock files at a git ref merged like LoadLockFiles.
+// A lock file missing at the ref is empty, e.g. in a commit before the lock files were split.
+func GitLockFiles(workingDirectory string, ref string, dirs []string) (*LockFile, error) {
+	if _, err := gitOutput(workingDirectory, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("%s is not a commit: %w", ref, err)
+	}
+	return MergeLockFiles(dirs, func(dirPath string) (*LockFile, error) {
+		object := ref + ":./" + path.Join(filepath.ToSlash(dirPath), LockFileName)
+		if _, err := gitOutput(workingDirectory, "cat-file", "-e", object); err != nil {
+			return &LockFile{}, nil
+		}
+		lockBytes, err := gitOutput(workingDirectory, "cat-file", "blob", object)
+		if err != nil {
+			return nil, err
+		}
+		return ReadLockFile(lockBytes)
+	})
+}
+
+func gitOutput(workingDirectory string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", workingDirectory}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// ReviewLockFiles compares the hashes of the working files recorded in two lock files
+func ReviewLockFiles(base string, before *LockFile, head string, after *LockFile) (*AssetReview, error) {
+	if before.WorkingHashes == nil && after.WorkingHashes == nil {
+		return nil, errors.New("the lock files record no hashes, set workingHashes.inLockFile in the .gasset file")
+	}
+	beforeHashes, afterHashes := &LockHashes{}, &LockHashes{}
+	if before.WorkingHashes != nil {
+		beforeHashes = before.WorkingHashes
+	}
+	if after.WorkingHashes != nil {
+		afterHashes = after.WorkingHashes
+	}
+	if beforeHashes.Algorithm != "" && afterHashes.Algorithm != "" && beforeHashes.Algorithm != afterHashes.Algorithm {
+		return nil, fmt.Errorf("the lock files of %s and %s record %s and %s hashes, which cannot be compared",
+			base, head, beforeHashes.Algorithm, afterHashes.Algorithm)
+	}
+
+	review := &AssetReview{Base: base, Head: head, Changes: []ReviewChange{}, Counts: map[ChangeKind]int{}}
+	for _, change := range DiffFiles(beforeHashes.Files, afterHashes.Files) {
+		reviewChange := ReviewChange{Change: change}
+		switch change.Kind {
+		case ChangeAdded:
+			reviewChange.Size = afterHashes.Sizes[change.Path]
+			review.DeltaBytes += reviewChange.Size
+		case ChangeRemoved:
+			reviewChange.PreviousSize = beforeHashes.Sizes[change.Path]
+			review.DeltaBytes -= reviewChange.PreviousSize
+		case ChangeModified:
+			size, known := afterHashes.Sizes[change.Path]
+			previousSize, previousKnown := beforeHashes.Sizes[change.Path]
+			reviewChange.Size, reviewChange.PreviousSize = size, previousSize
+			if known && previousKnown {
+				review.DeltaBytes += size - previousSize
+			}
+		case ChangeMoved:
+			reviewChange.Size = afterHashes.Sizes[change.Path]
+		}
+		review.Counts[change.Kind]++
+		review.Changes = append(review.Changes, reviewChange)
+	}
+	return review, nil
+}
+
+// WriteMarkdown writes the review as a markdown table to be posted to a pull request
+func (r *AssetReview) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Asset changes between %s and %s\n\n", r.Base, r.Head)
+	if len(r.Changes) == 0 {
+		b.WriteString("No asset changed.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("| Change | Path | Size |\n|---|---|---|\n")
+	for _, change := range r.Changes {
+		filePath := "`" + change.Path + "`"
+		if change.Kind == ChangeMoved {
+			filePath += " from `" + change.From + "`"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", change.Kind, filePath, change.sizeText())
+	}
+
+	counts := make([]string, 0, 4)
+	for _, kind := range []ChangeKind{ChangeAdded, ChangeRemoved, ChangeModified, ChangeMoved} {
+		if r.Counts[kind] > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", r.Counts[kind], kind))
+		}
+	}
+	delta := "+" + FormatBytes(r.DeltaBytes)
+	if r.DeltaBytes < 0 {
+		delta = "-" + FormatBytes(-r.DeltaBytes)
+	}
+	fmt.Fprintf(&b, "\n%s, %s\n", strings.Join(counts, ", "), delta)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sizeText returns the size column of a change, empty if the lock files do not record it
+func (c ReviewChange) sizeText() string {
+	switch {
+	case c.Kind == ChangeModified && c.Size > 0 && c.PreviousSize > 0:
+		return FormatBytes(c.PreviousSize) + " → " + FormatBytes(c.Size)
+	case c.Size > 0:
+		return FormatBytes(c.Size)
+	case c.PreviousSize > 0:
+		return FormatBytes(c.PreviousSize)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os/exec"
+	"testing"
+)
+
+func TestReviewLockFiles(t *testing.T) {
+	before := &LockFile{WorkingHashes: &LockHashes{
+		Algorithm: HashXXH64,
+		Files:     map[string]string{"assets/old.png": "1", "assets/wall.png": "2", "assets/gone.wav": "3"},
+		Sizes:     map[string]int64{"assets/old.png": 100, "assets/wall.png": 200, "assets/gone.wav": 300},
+	}}
+	after := &LockFile{WorkingHashes: &LockHashes{
+		Algorithm: HashXXH64,
+		Files:     map[string]string{"assets/new.png": "1", "assets/wall.png": "4", "assets/door.png": "5"},
+		Sizes:     map[string]int64{"assets/new.png": 100, "assets/wall.png": 250, "assets/door.png": 1024},
+	}}
+
+	review, err := ReviewLockFiles("main", before, "feature", after)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []ReviewChange{
+		{Change: Change{Kind: ChangeAdded, Path: "assets/door.png"}, Size: 1024},
+		{Change: Change{Kind: ChangeRemoved, Path: "assets/gone.wav"}, PreviousSize: 300},
+		{Change: Change{Kind: ChangeMoved, Path: "assets/new.png", From: "assets/old.png"}, Size: 100},
+		{Change: Change{Kind: ChangeModified, Path: "assets/wall.png"}, Size: 250, PreviousSize: 200},
+	}, review.Changes)
+	assert.Equal(t, int64(1024-300+50), review.DeltaBytes)
+
+	var markdown bytes.Buffer
+	assert.NoError(t, review.WriteMarkdown(&markdown))
+	assert.Contains(t, markdown.String(), "| moved | `assets/new.png` from `assets/old.png` | 100.0 B |\n")
+	assert.Contains(t, markdown.String(), "| modified | `assets/wall.png` | 200.0 B → 250.0 B |\n")
+	assert.Contains(t, markdown.String(), "\n1 added, 1 removed, 1 modified, 1 moved, +774.0 B\n")
+
+	after.WorkingHashes.Algorithm = HashBLAKE3
+	_, err = ReviewLockFiles("main", before, "feature", after)
+	assert.Error(t, err, "ReviewLockFiles() of different algorithms")
+
+	_, err = ReviewLockFiles("main", &LockFile{}, "feature", &LockFile{})
+	assert.Error(t, err, "ReviewLockFiles() without hashes")
+}
+
+func TestGitLockFiles(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			t.Skipf("git is not available: %v", err)
+		}
+	}
+	git("init", "-q")
+
+	lock := &LockFile{}
+	lock.RecordDir(HashXXH64, "./assets", map[string]WorkingHash{"assets/wall.png": {Size: 3, Hash: "1"}})
+	if err := SaveLockFile(dir, lock, nil); err != nil {
+		t.FailNow()
+	}
+	git("add", LockFileName)
+	git("commit", "-q", "-m", "lock")
+
+	loaded, err := GitLockFiles(dir, "HEAD", []string{"./assets"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, lock, loaded)
+
+	_, err = GitLockFiles(dir, "missing", []string{"./assets"})
+	assert.Error(t, err, "GitLockFiles() of a missing ref")
+}
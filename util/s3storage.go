@@ -0,0 +1,119 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"gopkg.in/yaml.v3"
+	"sync"
+)
+
+var registerS3Once sync.Once
+
+// registerS3Storage makes kopia open its s3 storages with NewS3Storage and wrapped by the enabled wrappers, so
+// that the storages it opens from its config have the features of gasset too
+func registerS3Storage() {
+	registerS3Once.Do(func() {
+		blob.AddSupportedStorage("s3", s3.Options{}, func(ctx context.Context, opt *s3.Options, isCreate bool) (blob.Storage, error) {
+			st, err := NewS3Storage(ctx, opt, isCreate)
+			if err != nil {
+				return nil, err
+			}
+			return wrapStorage(st), nil
+		})
+	})
+}
+
+// NewS3Storage opens the s3 storage with the temporary credentials of the role while one is assumed, and
+// encrypting the blobs it writes while an encryption is enabled
+func NewS3Storage(ctx context.Context, opt *s3.Options, isCreate bool) (blob.Storage, error) {
+	var st blob.Storage
+	var err error
+	if assumeRole := activeAssumeRole.Load(); assumeRole != nil {
+		st, err = newAssumeRoleStorage(ctx, opt, assumeRole, isCreate)
+	} else {
+		st, err = s3.New(ctx, opt, isCreate)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if encryption := activeEncryption.Load(); encryption != nil {
+		return newEncryptedStorage(st, opt, encryption)
+	}
+	return st, nil
+}
+
+// decodeStorageConfigKeys decodes the config of the storage of the kopia block of the JSON config into keys,
+// which are the ones kopia does not know, and returns the type of the storage
+func decodeStorageConfigKeys(data []byte, keys any) (string, error) {
+	var raw struct {
+		Kopia *struct {
+			Storage *struct {
+				Type   string          `json:"type"`
+				Config json.RawMessage `json:"config"`
+			} `json:"storage"`
+		} `json:"kopia"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return "", err
+	}
+	if raw.Kopia == nil || raw.Kopia.Storage == nil || len(raw.Kopia.Storage.Config) == 0 {
+		return "", nil
+	}
+	return raw.Kopia.Storage.Type, json.Unmarshal(raw.Kopia.Storage.Config, keys)
+}
+
+// addStorageConfigKeys adds the keys to the config of the storage of the kopia block of the document parsed from JSON
+func addStorageConfigKeys(document *yaml.Node, keys any) error {
+	if len(document.Content) == 0 {
+		return nil
+	}
+	node := document.Content[0]
+	for _, key := range []string{"kopia", "storage", "config"} {
+		if node = mappingValue(node, key); node == nil {
+			return nil
+		}
+	}
+
+	keysBytes, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	keysDocument := &yaml.Node{}
+	if err := yaml.Unmarshal(keysBytes, keysDocument); err != nil {
+		return err
+	}
+	node.Content = append(node.Content, keysDocument.Content[0].Content...)
+	return nil
+}
+
+// mappingValue returns the value of the key of the mapping node, or nil if it has none
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
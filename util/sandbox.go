@@ -0,0 +1,46 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// sandboxSourceSuffix is appended to the path of the source of an asset directory, followed by the name of the
+// sandbox, for the source of its snapshots in the sandbox. The suffix keeps them out of the sources of the asset
+// directories, so they are neither listed nor restored without the sandbox.
+const sandboxSourceSuffix = "#sandbox-"
+
+var sandboxNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// CheckSandboxName returns an error if the name can't be used as a sandbox, i.e. as a part of the source paths
+func CheckSandboxName(name string) error {
+	if !sandboxNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid sandbox %q, use lowercase letters, digits, dots, dashes and underscores", name)
+	}
+	return nil
+}
+
+// stateID returns the id the local state of the snapshots is kept by, the gasset id or the one of the sandbox
+// so that experiments in a sandbox never change what the next snap outside of it compares against
+func (op *Options) stateID() string {
+	if op.Sandbox != "" {
+		return op.Config.GassetId + sandboxSourceSuffix + op.Sandbox
+	}
+	return op.Config.GassetId
+}
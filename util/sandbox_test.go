@@ -0,0 +1,55 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckSandboxName(t *testing.T) {
+	assert.NoError(t, CheckSandboxName("pipeline-test"))
+	assert.NoError(t, CheckSandboxName("jane.v2_1"))
+	assert.Error(t, CheckSandboxName(""))
+	assert.Error(t, CheckSandboxName("Pipeline"))
+	assert.Error(t, CheckSandboxName("a/b"))
+	assert.Error(t, CheckSandboxName("-test"))
+}
+
+func TestSandboxState(t *testing.T) {
+	userConfigDir := t.TempDir()
+	op := &Options{
+		Config: &Config{GassetId: "0000000000"},
+		OsUserConfigDir: func() (string, error) {
+			return userConfigDir, nil
+		},
+	}
+	snapStatePath, err := op.GetSnapStatePath()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	op.Sandbox = "pipeline-test"
+	for _, getPath := range []func() (string, error){op.GetSnapStatePath, op.GetResumeStatePath, op.GetWorkingHashesPath} {
+		sandboxPath, err := getPath()
+		if assert.NoError(t, err) {
+			assert.Contains(t, sandboxPath, "0000000000#sandbox-pipeline-test")
+		}
+	}
+	sandboxPath, _ := op.GetSnapStatePath()
+	assert.NotEqual(t, snapStatePath, sandboxPath)
+}
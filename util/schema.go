@@ -0,0 +1,529 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema needed to describe the .gasset file
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	// OneOf lists alternatives of which the value has to match one, e.g. the storages by their type
+	OneOf []*Schema `json:"oneOf,omitempty"`
+}
+
+type LintError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e LintError) Error() string {
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+func closedObject(description string, properties map[string]*Schema, required ...string) *Schema {
+	additionalProperties := false
+	return &Schema{
+		Description:          description,
+		Type:                 "object",
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: &additionalProperties,
+	}
+}
+
+func typed(schemaType string, description string) *Schema {
+	return &Schema{Type: schemaType, Description: description}
+}
+
+// ConfigSchema returns the JSON Schema of the .gasset file including the subset of the kopia config gasset supports
+func ConfigSchema() *Schema {
+	throttlingProperties := func(properties map[string]*Schema) map[string]*Schema {
+		properties["readsPerSecond"] = typed("number", "Maximum number of reads per second")
+		properties["writesPerSecond"] = typed("number", "Maximum number of writes per second")
+		properties["listsPerSecond"] = typed("number", "Maximum number of lists per second")
+		properties["maxUploadSpeedBytesPerSecond"] = typed("number", "Maximum upload speed in bytes per second")
+		properties["maxDownloadSpeedBytesPerSecond"] = typed("number", "Maximum download speed in bytes per second")
+		properties["concurrentReads"] = typed("integer", "Maximum number of concurrent reads")
+		properties["concurrentWrites"] = typed("integer", "Maximum number of concurrent writes")
+		return properties
+	}
+	throttlingLimits := func(description string) *Schema {
+		return closedObject(description, throttlingProperties(map[string]*Schema{}))
+	}
+	throttling := throttlingLimits("Throttling limits of the storage")
+
+	// The options of the kopia storages embed its throttling limits, which are therefore valid inline in their config
+	s3Config := closedObject("S3 storage options, the credentials are read from the .env file", throttlingProperties(map[string]*Schema{
+		"bucket":          typed("string", "Name of the bucket"),
+		"prefix":          typed("string", "Prefix of the blobs in the bucket"),
+		"endpoint":        typed("string", "S3 endpoint"),
+		"doNotUseTLS":     typed("boolean", "Uses plain HTTP to connect to the endpoint"),
+		"doNotVerifyTLS":  typed("boolean", "Skips the verification of the TLS certificate"),
+		"rootCA":          typed("string", "Base64 encoded root certificate of the endpoint"),
+		"accessKeyID":     typed("string", "Overwritten by KOPIA_ACCESS_ID"),
+		"secretAccessKey": typed("string", "Overwritten by KOPIA_ACCESS_SECRET"),
+		"sessionToken":    typed("string", "Session token of the credentials"),
+		"region":          typed("string", "Region of the bucket"),
+		"pointInTime":     typed("string", "Point in time to view the bucket at"),
+		"roleArn":         typed("string", "ARN of the role assumed with the credentials of the .env file, whose temporary credentials access the bucket"),
+		"externalId":      typed("string", "External id required by the trust policy of the role"),
+		"sessionDuration": typed("string", "Duration of the temporary credentials of the role between 15m and 12h, e.g. 1h, they are renewed before they expire"),
+		"stsEndpoint":     typed("string", "STS endpoint the role is assumed at, defaults to the one of the region on AWS"),
+		"sse":             {Type: "string", Description: "Server side encryption of the blobs, aws:kms with the KMS key of kmsKeyId or AES256", Enum: []string{SSEKMS, SSES3}},
+		"kmsKeyId":        typed("string", "ID or ARN of the KMS key encrypting the blobs with sse aws:kms, defaults to the key of S3 managed by AWS"),
+	}), "bucket", "endpoint")
+
+	b2Config := closedObject("Backblaze B2 storage options, the application key is read from the .env file", throttlingProperties(map[string]*Schema{
+		"bucket": typed("string", "Name of the bucket"),
+		"prefix": typed("string", "Prefix of the files in the bucket"),
+		"keyID":  typed("string", "Overwritten by "+EnvB2KeyId),
+		"key":    typed("string", "Overwritten by "+EnvB2Key),
+	}), "bucket")
+
+	filesystemConfig := closedObject("Filesystem storage options, e.g. of a mounted network drive", throttlingProperties(map[string]*Schema{
+		"path":      typed("string", "Absolute path of the directory of the repository"),
+		"fileMode":  typed("integer", "Permissions of the files, e.g. 384 for 0600"),
+		"dirMode":   typed("integer", "Permissions of the directories, e.g. 448 for 0700"),
+		"uid":       typed("integer", "Owner of the files"),
+		"gid":       typed("integer", "Group of the files"),
+		"dirShards": {Type: "array", Description: "Lengths of the prefixes of the blob names used as subdirectories", Items: typed("integer", "")},
+	}), "path")
+
+	storage := &Schema{Type: "object", Description: "Storage of the kopia repository", OneOf: []*Schema{
+		closedObject("S3 compatible storage", map[string]*Schema{
+			"type":   {Type: "string", Description: "Type of the storage", Enum: []string{"s3"}},
+			"config": s3Config,
+		}, "type", "config"),
+		closedObject("Backblaze B2 storage", map[string]*Schema{
+			"type":   {Type: "string", Description: "Type of the storage", Enum: []string{"b2"}},
+			"config": b2Config,
+		}, "type", "config"),
+		closedObject("Filesystem storage", map[string]*Schema{
+			"type":   {Type: "string", Description: "Type of the storage", Enum: []string{"filesystem"}},
+			"config": filesystemConfig,
+		}, "type", "config"),
+	}}
+
+	apiServer := closedObject("Kopia repository server to connect to", map[string]*Schema{
+		"url":                   typed("string", "URL of the server"),
+		"serverCertFingerprint": typed("string", "SHA256 fingerprint of the server certificate in hex without colons, which the server has to present"),
+		"disableGRPC":           typed("boolean", "Uses the legacy REST API instead of gRPC"),
+	}, "url")
+
+	caching := closedObject("Local cache of the kopia repository", map[string]*Schema{
+		"cacheDirectory":              typed("string", "Directory of the cache"),
+		"maxCacheSize":                typed("integer", "Size of the content cache in bytes"),
+		"contentCacheSizeLimitBytes":  typed("integer", "Hard limit of the content cache in bytes"),
+		"maxMetadataCacheSize":        typed("integer", "Size of the metadata cache in bytes"),
+		"metadataCacheSizeLimitBytes": typed("integer", "Hard limit of the metadata cache in bytes"),
+		"maxListCacheDuration":        typed("integer", "Duration in seconds to cache blob lists"),
+		"minMetadataSweepAge":         typed("integer", "Minimum age in seconds of swept metadata"),
+		"minContentSweepAge":          typed("integer", "Minimum age in seconds of swept contents"),
+		"minIndexSweepAge":            typed("integer", "Minimum age in seconds of swept indexes"),
+	})
+
+	kopia := closedObject("Kopia config shared by everyone working on the repository", map[string]*Schema{
+		"apiServer":               apiServer,
+		"storage":                 storage,
+		"caching":                 caching,
+		"hostname":                typed("string", "Hostname recorded in the snapshots"),
+		"username":                typed("string", "Username recorded in the snapshots"),
+		"readonly":                typed("boolean", "Connects to the repository in read only mode"),
+		"permissiveCacheLoading":  typed("boolean", "Ignores errors while loading the cache"),
+		"description":             typed("string", "Description of the repository"),
+		"enableActions":           typed("boolean", "Enables the kopia snapshot actions"),
+		"formatBlobCacheDuration": typed("integer", "Duration in nanoseconds to cache the format blob"),
+		"throttlingLimits":        throttling,
+	})
+
+	config := closedObject("Configuration of git-gasset", map[string]*Schema{
+		"kopia":           kopia,
+		"storage":         typed("string", "Storage as a URL instead of the storage of the kopia block, e.g. s3://bucket/prefix/?endpoint=nyc3.digitaloceanspaces.com&region=nyc3, with the doNotUseTLS and doNotVerifyTLS parameters as well, b2://bucket/prefix/ or file:///mnt/assets"),
+		"kopiaConfig":     typed("string", "Existing kopia config to use instead of the one managed by gasset, relative to the root of the git repository"),
+		"gassetId":        typed("string", "Id of the gasset repository, generated by init --create"),
+		"namespace":       typed("string", "Namespace of the snapshots when the kopia repository is shared with other projects"),
+		"dirs":            {Type: "array", Description: "Asset directories to snapshot, none of them may contain another unless allowNestedDirs is set", Items: typed("string", "")},
+		"allowNestedDirs": typed("boolean", "Allows an asset directory inside another, whose files are then snapshotted with both unless the outer one ignores them"),
+		"aws": closedObject("AWS S3 options, not applicable to other S3 compatible providers", map[string]*Schema{
+			"region":               typed("string", "Region of the bucket, the endpoint is derived from it"),
+			"transferAcceleration": typed("boolean", "Uses S3 Transfer Acceleration, which has to be enabled on the bucket"),
+		}, "region"),
+		"gitTracked":             {Type: "string", Description: "Handling of files in the asset directories which are tracked by git as well, defaults to exclude", Enum: []string{GitTrackedExclude, GitTrackedInclude, GitTrackedError}},
+		"deferRetention":         typed("boolean", "Leaves applying the retention policy to scheduled prune runs so that snap finishes faster"),
+		"skipIdenticalSnapshots": typed("boolean", "Skips saving snapshots identical to the previous ones, defaults to the ignoreIdenticalSnapshots retention policy"),
+		"ifChanged":              typed("boolean", "Makes snap exit without connecting to the repository if no file changed since the last snap on this machine, like --if-changed"),
+		"ignore":                 {Type: "array", Description: "Ignore rules in the .gitignore syntax applied to every asset directory", Items: typed("string", "")},
+		"compression":            {Type: "string", Description: "Compression of the snapshots, none for assets compressed already", Enum: CompressionNames()},
+		"profiles":               typed("object", "Asset directories and globs to restore by profile name, e.g. {\"art\": [\"./assets/textures\", \"./assets/models/*.fbx\"]}"),
+		"quota": closedObject("Soft limits of the storage, snap warns when the repository gets close to them", map[string]*Schema{
+			"limitBytes":       typed("integer", "Storage limit in bytes"),
+			"pricePerGiBMonth": typed("number", "Storage price per GiB and month charged by the provider"),
+			"monthlyBudget":    typed("number", "Monthly storage budget, in the currency of the price"),
+			"warnPercent":      typed("integer", "Share of the limits in percent from which snap warns, defaults to 80"),
+		}),
+		"workingHashes": closedObject("Records a fast hash of the working files on snap, which check verifies without reading the repository", map[string]*Schema{
+			"algorithm":  {Type: "string", Description: "Hash algorithm, defaults to xxhash", Enum: []string{HashXXH64, HashBLAKE3}},
+			"inLockFile": typed("boolean", "Records the hashes in the .gasset.lock file as well, so that clones can verify their files"),
+		}),
+		"derived": closedObject("Asset directories holding generated assets, e.g. caches or bakes, with their own short retention and left out of the lock file", map[string]*Schema{
+			"dirs":       {Type: "array", Description: "Asset directories of dirs which are derived", Items: typed("string", "")},
+			"keepLatest": typed("integer", "Number of latest snapshots kept of every derived directory, defaults to 3"),
+			"keepDaily":  typed("integer", "Number of daily snapshots kept of every derived directory"),
+			"inLockFile": typed("boolean", "Records the hashes of the derived directories in the lock file as well"),
+		}, "dirs"),
+		"lockFilePerDir": typed("boolean", "Records the hashes of every asset directory in a .gasset.lock file in the directory instead of the one next to the .gasset file, so that teams working on different directories do not conflict"),
+		"permissions": closedObject("Modes of the files and directories created in the git repository", map[string]*Schema{
+			"fileMode":    typed("string", "Octal mode of the created files, e.g. 0664"),
+			"dirMode":     typed("string", "Octal mode of the created directories including the setuid, setgid and sticky bits, e.g. 2775"),
+			"ignoreUmask": typed("boolean", "Applies the modes as they are instead of masking them with the umask"),
+		}),
+		"renames": {Type: "array", Description: "Asset directories moved with mv, whose snapshots at the previous path stay part of their history", Items: closedObject("Move of an asset directory", map[string]*Schema{
+			"from": typed("string", "Previous path of the asset directory"),
+			"to":   typed("string", "New path of the asset directory"),
+		}, "from", "to")},
+		"metrics": {Type: "array", Description: "Analyzers computing metrics of the asset directories recorded in every snapshot", Items: closedObject("Command printing a JSON object of metric names and numbers for the asset directory passed as its last argument", map[string]*Schema{
+			"name":    typed("string", "Name of the analyzer prefixing its metrics, lowercase letters, digits and underscores"),
+			"command": {Type: "array", Description: "Command and its arguments, run in the root of the git repository", Items: typed("string", "")},
+		}, "name", "command")},
+		"throttling": closedObject("Throttling limits per command replacing all the throttlingLimits of the kopia config while it runs, an empty object lifts every limit", map[string]*Schema{
+			"snap":    throttlingLimits("Throttling limits of snap"),
+			"restore": throttlingLimits("Throttling limits of restore"),
+		}),
+		"thumbnails": closedObject("Generates thumbnails of the PNG, JPEG and GIF images on snap and stores them in the repository, so that they can be previewed without reading the images", map[string]*Schema{
+			"size":        typed("integer", "Length of the longest edge of a thumbnail in pixels, 256 by default"),
+			"maxFileSize": typed("integer", "Images larger than this many bytes are skipped, 64 MiB by default"),
+			"workers":     typed("integer", "Number of images decoded at the same time, 1 by default"),
+		}),
+		"peers": closedObject("Fetches the pack blobs of restores from teammates on the same network running the peer command before the storage", map[string]*Schema{
+			"peers":         {Type: "array", Description: "Addresses of the peers, e.g. 192.168.1.20 or nas.local:7746", Items: typed("string", "")},
+			"discover":      typed("boolean", "Finds the peers serving the repository on the network with a UDP broadcast"),
+			"port":          typed("integer", "Port the peer command serves on and the discovery is sent to, 7746 by default"),
+			"maxCacheBytes": typed("integer", "Size limit of the pack blobs kept for the peers, the least recently used are removed, 20 GiB by default"),
+		}),
+		"archival":      typed("boolean", "Keeps every snapshot, snap does not apply the retention policy and prune and purge-file refuse to run without --override-archival"),
+		"trackRestores": typed("boolean", "Counts locally how often every asset is restored, which report cold-assets aggregates"),
+		"restoreHooks": {Type: "array", Description: "Commands run after assets are restored", Items: closedObject("Command run after the assets of a directory are restored", map[string]*Schema{
+			"dir":     typed("string", "Asset directory the hook is run for"),
+			"command": {Type: "array", Description: "Command and its arguments, run in the root of the git repository", Items: typed("string", "")},
+		}, "dir", "command")},
+	}, "dirs")
+	config.Schema = "https://json-schema.org/draft/2020-12/schema"
+	config.Title = ".gasset"
+
+	return config
+}
+
+// LintConfig validates the content of a .gasset file in the format against the schema and returns the problems found
+func LintConfig(data []byte, format ConfigFormat) ([]LintError, error) {
+	var lintErrors []LintError
+	var err error
+	if format == ConfigFormatYAML {
+		lintErrors, err = lintYAMLConfig(data)
+	} else {
+		lintErrors, err = lintJSONConfig(data)
+	}
+	if err != nil || len(lintErrors) > 0 {
+		return lintErrors, err
+	}
+
+	// The schema does not know every rule of the config, which LoadConfig checks by decoding it
+	if _, err := decodeConfig(data, format); err != nil {
+		return []LintError{{Path: "/", Line: 1, Column: 1, Message: err.Error()}}, nil
+	}
+	return nil, nil
+}
+
+// lintJSONConfig validates a JSON .gasset file with the offsets of its fields
+func lintJSONConfig(data []byte) ([]LintError, error) {
+	var document any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&document); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := lineColumn(data, syntaxErr.Offset-1)
+			return []LintError{{Path: "/", Line: line, Column: column, Message: syntaxErr.Error()}}, nil
+		}
+		return nil, err
+	}
+
+	positions := map[string]int64{}
+	dec = json.NewDecoder(bytes.NewReader(data))
+	if err := recordPositions(dec, data, "", positions); err != nil {
+		return nil, err
+	}
+
+	return lintDocument(document, func(path string) (int, int) {
+		return lineColumn(data, positions[path])
+	}), nil
+}
+
+// lintYAMLConfig validates a YAML .gasset file like a JSON one, with the positions of the YAML nodes
+func lintYAMLConfig(data []byte) ([]LintError, error) {
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		line := 1
+		fmt.Sscanf(err.Error(), "yaml: line %d:", &line)
+		return []LintError{{Path: "/", Line: line, Column: 1, Message: err.Error()}}, nil
+	}
+
+	jsonBytes, err := yamlToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var document any
+	dec := json.NewDecoder(bytes.NewReader(jsonBytes))
+	dec.UseNumber()
+	if err := dec.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	positions := map[string]*yaml.Node{}
+	if len(root.Content) > 0 {
+		recordNodePositions(root.Content[0], "", positions)
+	}
+	return lintDocument(document, func(path string) (int, int) {
+		if node, ok := positions[path]; ok {
+			return node.Line, node.Column
+		}
+		return 1, 1
+	}), nil
+}
+
+// recordNodePositions records the node of every field by its path, the key for the fields of a mapping
+func recordNodePositions(node *yaml.Node, path string, positions map[string]*yaml.Node) {
+	if _, ok := positions[path]; !ok {
+		positions[path] = node
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := path + "/" + node.Content[i].Value
+			positions[childPath] = node.Content[i]
+			recordNodePositions(node.Content[i+1], childPath, positions)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			recordNodePositions(item, path+"/"+strconv.Itoa(i), positions)
+		}
+	}
+}
+
+func lintDocument(document any, position func(path string) (int, int)) []LintError {
+	var lintErrors []LintError
+	report := func(path string, message string) {
+		line, column := position(path)
+		if path == "" {
+			path = "/"
+		}
+		lintErrors = append(lintErrors, LintError{Path: path, Line: line, Column: column, Message: message})
+	}
+	validate(ConfigSchema(), document, "", report)
+
+	sort.SliceStable(lintErrors, func(i, j int) bool {
+		if lintErrors[i].Line != lintErrors[j].Line {
+			return lintErrors[i].Line < lintErrors[j].Line
+		}
+		return lintErrors[i].Column < lintErrors[j].Column
+	})
+	return lintErrors
+}
+
+func validate(schema *Schema, value any, path string, report func(path string, message string)) {
+	if !matchesType(schema.Type, value) {
+		report(path, fmt.Sprintf("expected %s", schema.Type))
+		return
+	}
+
+	if len(schema.OneOf) > 0 {
+		validateOneOf(schema.OneOf, value, path, report)
+		return
+	}
+
+	if len(schema.Enum) > 0 {
+		if str, _ := value.(string); !slices.Contains(schema.Enum, str) {
+			report(path, fmt.Sprintf("must be one of %s", strings.Join(schema.Enum, ", ")))
+		}
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]any:
+		for _, key := range schema.Required {
+			if _, ok := typedValue[key]; !ok {
+				report(path, fmt.Sprintf("missing required field %q", key))
+			}
+		}
+		for key, child := range typedValue {
+			childPath := path + "/" + key
+			childSchema, ok := schema.Properties[key]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					report(childPath, "unknown field")
+				}
+				continue
+			}
+			validate(childSchema, child, childPath, report)
+		}
+	case []any:
+		if schema.Items == nil {
+			return
+		}
+		for i, child := range typedValue {
+			validate(schema.Items, child, path+"/"+strconv.Itoa(i), report)
+		}
+	}
+}
+
+// validateOneOf validates the value against the first alternative whose enum properties, e.g. the type of a storage,
+// match it and otherwise reports the values these properties allow
+func validateOneOf(alternatives []*Schema, value any, path string, report func(path string, message string)) {
+	object, _ := value.(map[string]any)
+	allowed := map[string][]string{}
+	for _, alternative := range alternatives {
+		matches := true
+		for key, property := range alternative.Properties {
+			if len(property.Enum) == 0 {
+				continue
+			}
+			allowed[key] = append(allowed[key], property.Enum...)
+			if str, _ := object[key].(string); !slices.Contains(property.Enum, str) {
+				matches = false
+			}
+		}
+		if matches {
+			validate(alternative, value, path, report)
+			return
+		}
+	}
+
+	keys := make([]string, 0, len(allowed))
+	for key := range allowed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := object[key]; !ok {
+			report(path, fmt.Sprintf("missing required field %q", key))
+			continue
+		}
+		report(path+"/"+key, fmt.Sprintf("must be one of %s", strings.Join(allowed[key], ", ")))
+	}
+}
+
+func matchesType(schemaType string, value any) bool {
+	switch schemaType {
+	case "":
+		return true
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := number.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return false
+}
+
+// recordPositions walks the JSON tokens and records the offset of every field by its path
+func recordPositions(dec *json.Decoder, data []byte, path string, positions map[string]int64) error {
+	if _, ok := positions[path]; !ok {
+		positions[path] = skipSeparators(data, dec.InputOffset())
+	}
+
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		for dec.More() {
+			offset := skipSeparators(data, dec.InputOffset())
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			childPath := path + "/" + key.(string)
+			positions[childPath] = offset
+			if err := recordPositions(dec, data, childPath, positions); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := recordPositions(dec, data, path+"/"+strconv.Itoa(i), positions); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,:", rune(data[offset])) {
+		offset++
+	}
+	return offset
+}
+
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return line, column
+}
@@ -0,0 +1,157 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestLintConfig(t *testing.T) {
+	mockConfig, err := os.ReadFile("../mocks/.gasset")
+	if err != nil {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want []LintError
+	}{
+		{
+			name: "Lint the mock config",
+			data: string(mockConfig),
+			want: nil,
+		},
+		{
+			name: "Lint a config with an unknown field and a wrong type",
+			data: "{\n  \"gassetId\": 1,\n  \"dirs\": [\"./assets\"],\n  \"dir\": \"./assets\"\n}",
+			want: []LintError{
+				{Path: "/gassetId", Line: 2, Column: 3, Message: "expected string"},
+				{Path: "/dir", Line: 4, Column: 3, Message: "unknown field"},
+			},
+		},
+		{
+			name: "Lint a config with a missing field and an unsupported storage",
+			data: "{\n  \"kopia\": {\n    \"storage\": {\"type\": \"ftp\", \"config\": {\"bucket\": \"b\", \"endpoint\": \"e\"}}\n  }\n}",
+			want: []LintError{
+				{Path: "/", Line: 1, Column: 1, Message: "missing required field \"dirs\""},
+				{Path: "/kopia/storage/type", Line: 3, Column: 17, Message: "must be one of s3, b2, filesystem"},
+			},
+		},
+		{
+			name: "Lint a config with the options of another storage",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"b2\", \"config\": {\"bucket\": \"b\", \"endpoint\": \"e\"}}\n  }\n}",
+			want: []LintError{
+				{Path: "/kopia/storage/config/endpoint", Line: 4, Column: 57, Message: "unknown field"},
+			},
+		},
+		{
+			name: "Lint a config with the defaults of snap",
+			data: "{\n  \"dirs\": [],\n  \"ifChanged\": true,\n  \"skipIdenticalSnapshots\": false\n}",
+			want: nil,
+		},
+		{
+			name: "Lint a config with a b2 storage",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"b2\", \"config\": {\"bucket\": \"b\", \"prefix\": \"p/\"}}\n  }\n}",
+			want: nil,
+		},
+		{
+			name: "Lint a config with a filesystem storage",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"filesystem\", \"config\": {\"path\": \"/mnt/nas/assets\", \"dirShards\": [1, 3]}}\n  }\n}",
+			want: nil,
+		},
+		{
+			name: "Lint a filesystem storage without a path",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"filesystem\", \"config\": {}}\n  }\n}",
+			want: []LintError{
+				{Path: "/kopia/storage/config", Line: 4, Column: 39, Message: "missing required field \"path\""},
+			},
+		},
+		{
+			name: "Lint an s3 storage assuming a role",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"s3\", \"config\": {\"bucket\": \"b\", \"endpoint\": \"e\", \"roleArn\": \"arn:aws:iam::123456789012:role/assets\", \"sessionDuration\": \"2h\"}}\n  }\n}",
+			want: nil,
+		},
+		{
+			name: "Lint an s3 storage encrypting the blobs with a KMS key",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"s3\", \"config\": {\"bucket\": \"b\", \"endpoint\": \"e\", \"sse\": \"aws:kms\", \"kmsKeyId\": \"alias/assets\"}}\n  }\n}",
+			want: nil,
+		},
+		{
+			name: "Lint an s3 storage with an unknown sse",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"s3\", \"config\": {\"bucket\": \"b\", \"endpoint\": \"e\", \"sse\": \"kms\"}}\n  }\n}",
+			want: []LintError{
+				{Path: "/kopia/storage/config/sse", Line: 4, Column: 74, Message: "must be one of aws:kms, AES256"},
+			},
+		},
+		{
+			name: "Lint an s3 storage with the throttling limits inline",
+			data: "{\n  \"dirs\": [],\n  \"kopia\": {\n    \"storage\": {\"type\": \"s3\", \"config\": {\"bucket\": \"b\", \"endpoint\": \"e\", \"maxDownloadSpeedBytesPerSecond\": 1048576, \"concurrentReads\": 4}}\n  }\n}",
+			want: nil,
+		},
+		{
+			name: "Lint a YAML config with a filesystem storage with the throttling limits inline",
+			data: "dirs: []\nkopia:\n  storage:\n    type: filesystem\n    config:\n      path: /mnt/nas/assets\n      maxUploadSpeedBytesPerSecond: 1048576\n",
+			want: nil,
+		},
+		{
+			name: "Lint a YAML config which LoadConfig fails to decode",
+			data: "dirs: []\nkopia:\n  storage:\n    type: s3\n    config:\n      bucket: b\n      endpoint: e\n      sessionDuration: 1h\n",
+			want: []LintError{
+				{Path: "/", Line: 1, Column: 1, Message: "the s3 storage has externalId, sessionDuration or stsEndpoint without roleArn"},
+			},
+		},
+		{
+			name: "Lint a config allowing nested dirs",
+			data: "{\n  \"dirs\": [\"./assets\", \"./assets/textures\"],\n  \"allowNestedDirs\": true\n}",
+			want: nil,
+		},
+		{
+			name: "Lint a YAML config with an unknown field and a wrong type",
+			data: "gassetId: 1\ndirs:\n  - ./assets\ndir: ./assets\n",
+			want: []LintError{
+				{Path: "/gassetId", Line: 1, Column: 1, Message: "expected string"},
+				{Path: "/dir", Line: 4, Column: 1, Message: "unknown field"},
+			},
+		},
+		{
+			name: "Lint a YAML config with a syntax error",
+			data: "dirs:\n  - ./assets\n - ./textures\n",
+			want: []LintError{
+				{Path: "/", Line: 2, Column: 1, Message: "yaml: line 2: did not find expected key"},
+			},
+		},
+		{
+			name: "Lint a config with a syntax error",
+			data: "{\n  \"dirs\": [\"./assets\",]\n}",
+			want: []LintError{
+				{Path: "/", Line: 2, Column: 23, Message: "invalid character ']' looking for beginning of value"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LintConfig([]byte(tt.data), DetectConfigFormat(".gasset", []byte(tt.data)))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "LintConfig(%v)", tt.data)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// selftestFileSizes are the sizes of the files of a self test source by their path, small enough to be uploaded
+// in seconds, with subdirectories and an empty file to restore
+var selftestFileSizes = map[string]int{
+	"small.bin":         1 << 10,
+	"empty.bin":         0,
+	"nested/large.bin":  3<<20 + 17,
+	"nested/deep/a.bin": 64 << 10,
+}
+
+// SelftestSource is a throwaway directory of random files which self test snapshots and restores
+type SelftestSource struct {
+	// Path is the directory of the files
+	Path string
+	// hashes are the SHA-256 hashes of the files by their slash separated path
+	hashes map[string][sha256.Size]byte
+}
+
+// NewSelftestSource writes the random files of a self test source into the directory at path
+func NewSelftestSource(path string) (*SelftestSource, error) {
+	source := &SelftestSource{Path: path, hashes: map[string][sha256.Size]byte{}}
+	for filePath, size := range selftestFileSizes {
+		content := make([]byte, size)
+		if _, err := rand.Read(content); err != nil {
+			return nil, err
+		}
+		target := filepath.Join(path, filepath.FromSlash(filePath))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return nil, err
+		}
+		source.hashes[filePath] = sha256.Sum256(content)
+	}
+	return source, nil
+}
+
+// Bytes returns the total size of the files of the source
+func (s *SelftestSource) Bytes() int64 {
+	var total int64
+	for _, size := range selftestFileSizes {
+		total += int64(size)
+	}
+	return total
+}
+
+// Verify checks that the directory at path has exactly the files of the source with the same bytes
+func (s *SelftestSource) Verify(path string) error {
+	var errs []error
+	found := map[string]bool{}
+	err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		found[rel] = true
+
+		expected, ok := s.hashes[rel]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s was not in the source", rel))
+			return nil
+		}
+		hash, err := hashSelftestFile(filePath)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hash[:], expected[:]) {
+			errs = append(errs, fmt.Errorf("%s differs from the source", rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for filePath := range s.hashes {
+		if !found[filePath] {
+			missing = append(missing, filePath)
+		}
+	}
+	sort.Strings(missing)
+	for _, filePath := range missing {
+		errs = append(errs, fmt.Errorf("%s is missing", filePath))
+	}
+	return errors.Join(errs...)
+}
+
+func hashSelftestFile(filePath string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	f, err := os.Open(filePath)
+	if err != nil {
+		return hash, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return hash, err
+	}
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
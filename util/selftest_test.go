@@ -0,0 +1,41 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelftestSource(t *testing.T) {
+	path := t.TempDir()
+	source, err := NewSelftestSource(path)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.NoError(t, source.Verify(path))
+
+	if err := os.WriteFile(filepath.Join(path, "small.bin"), []byte("changed"), 0o644); err != nil {
+		t.FailNow()
+	}
+	if err := os.Remove(filepath.Join(path, "nested", "deep", "a.bin")); err != nil {
+		t.FailNow()
+	}
+	assert.EqualError(t, source.Verify(path), "small.bin differs from the source\nnested/deep/a.bin is missing")
+}
@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"strings"
+)
+
+// shortCommitLength is the length of the commit hashes recorded in the write sessions
+const shortCommitLength = 12
+
+// Tags recorded in the snapshot manifests
+const (
+	TagCommand   = "tag:gasset-command"
+	TagGitBranch = "tag:git-branch"
+	TagGitCommit = "tag:git-commit"
+	TagArchived  = "tag:archived"
+	TagDerived   = "tag:derived"
+	TagChangeset = "tag:changeset"
+	TagMovedFrom = "tag:moved-from"
+)
+
+// SessionPurpose returns the purpose of a kopia write session including the command, gasset id,
+// git branch and commit and the user identity, so that the kopia logs of shared repositories are attributable.
+func (op *Options) SessionPurpose(purpose string) string {
+	var details []string
+	if op.Command != "" {
+		details = append(details, "command="+op.Command)
+	}
+	if op.Config.GassetId != "" {
+		details = append(details, "gasset="+op.Config.GassetId)
+	}
+	if head, err := GetGitHead(op.WorkingDirectory); err == nil {
+		if head.Branch != "" {
+			details = append(details, "branch="+head.Branch)
+		}
+		if head.Commit != "" {
+			details = append(details, "commit="+head.Commit[:min(len(head.Commit), shortCommitLength)])
+		}
+	}
+	clientOptions := op.ClientOptions()
+	details = append(details, "user="+clientOptions.Username+"@"+clientOptions.Hostname)
+
+	return purpose + " (" + strings.Join(details, " ") + ")"
+}
+
+// SnapshotTags returns the kopia tags recording the command and the git HEAD a snapshot was taken at
+func (op *Options) SnapshotTags() map[string]string {
+	tags := map[string]string{}
+	if op.Command != "" {
+		tags[TagCommand] = op.Command
+	}
+	if head, err := GetGitHead(op.WorkingDirectory); err == nil {
+		if head.Branch != "" {
+			tags[TagGitBranch] = head.Branch
+		}
+		if head.Commit != "" {
+			tags[TagGitCommit] = head.Commit
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
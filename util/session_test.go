@@ -0,0 +1,53 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionContext(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+	options := testOptions.OptionsWithGassetId.Clone()
+	options.Command = "snap"
+
+	// Without a git HEAD only the command, gasset id and user are recorded
+	options.WorkingDirectory = t.TempDir()
+	assert.Equal(t, "Create snapshot (command=snap gasset=0000000000 user=user@host-pc)", options.SessionPurpose("Create snapshot"))
+	assert.Equal(t, map[string]string{"tag:gasset-command": "snap"}, options.SnapshotTags())
+
+	if err := os.MkdirAll(filepath.Join(options.WorkingDirectory, ".git"), 0755); err != nil {
+		t.FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(options.WorkingDirectory, ".git", "HEAD"), []byte("0123456789abcdef0123456789abcdef01234567\n"), 0644); err != nil {
+		t.FailNow()
+	}
+	if err := options.SetMachineIdentity("ci-agent"); err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, "Create snapshot (command=snap gasset=0000000000 commit=0123456789ab user=gasset-machine@ci-agent)", options.SessionPurpose("Create snapshot"))
+	assert.Equal(t, map[string]string{
+		"tag:gasset-command": "snap",
+		"tag:git-commit":     "0123456789abcdef0123456789abcdef01234567",
+	}, options.SnapshotTags())
+}
@@ -0,0 +1,177 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotDetails are the details of a snapshot shown by snapshot show
+type SnapshotDetails struct {
+	ID               string            `json:"id"`
+	Path             string            `json:"path"`
+	User             string            `json:"user"`
+	Host             string            `json:"host"`
+	Description      string            `json:"description,omitempty"`
+	StartTime        time.Time         `json:"startTime"`
+	EndTime          time.Time         `json:"endTime"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	Pins             []string          `json:"pins,omitempty"`
+	GitBranch        string            `json:"gitBranch,omitempty"`
+	GitCommit        string            `json:"gitCommit,omitempty"`
+	Files            int64             `json:"files"`
+	Dirs             int64             `json:"dirs"`
+	Bytes            int64             `json:"bytes"`
+	Errors           int               `json:"errors"`
+	IgnoredErrors    int               `json:"ignoredErrors"`
+	IncompleteReason string            `json:"incompleteReason,omitempty"`
+	RootObjectID     string            `json:"rootObjectId,omitempty"`
+}
+
+// NewSnapshotDetails collects the details of a snapshot manifest.
+// The stats are taken from the summary of the root directory, which includes the
+// entries reused from previous snapshots, and from the upload stats otherwise.
+func NewSnapshotDetails(man *snapshot.Manifest) SnapshotDetails {
+	details := SnapshotDetails{
+		ID:               string(man.ID),
+		Path:             man.Source.Path,
+		User:             man.Source.UserName,
+		Host:             man.Source.Host,
+		Description:      man.Description,
+		StartTime:        man.StartTime.ToTime().UTC(),
+		EndTime:          man.EndTime.ToTime().UTC(),
+		Tags:             man.Tags,
+		Pins:             man.Pins,
+		GitBranch:        man.Tags[TagGitBranch],
+		GitCommit:        man.Tags[TagGitCommit],
+		Files:            int64(man.Stats.TotalFileCount),
+		Dirs:             int64(man.Stats.TotalDirectoryCount),
+		Bytes:            man.Stats.TotalFileSize,
+		Errors:           int(man.Stats.ErrorCount),
+		IgnoredErrors:    int(man.Stats.IgnoredErrorCount),
+		IncompleteReason: man.IncompleteReason,
+	}
+
+	if man.RootEntry != nil {
+		details.RootObjectID = man.RootEntry.ObjectID.String()
+		if summary := man.RootEntry.DirSummary; summary != nil {
+			details.Files = summary.TotalFileCount
+			details.Dirs = summary.TotalDirCount
+			details.Bytes = summary.TotalFileSize
+			details.Errors = summary.FatalErrorCount
+			details.IgnoredErrors = summary.IgnoredErrorCount
+		}
+	}
+	return details
+}
+
+// SnapshotFilter selects the snapshots to list, empty fields match everything.
+// PathPrefixes match whole segments of the source path, so /work/assets matches /work/assets/textures but not /work/assets2.
+type SnapshotFilter struct {
+	Host         string
+	User         string
+	PathPrefixes []string
+	Since        time.Time
+	Until        time.Time
+	// Changeset is the id of the changeset the snapshots were taken in
+	Changeset string
+	// Machine also matches the snapshots taken with a machine identity, which are left out of the human view otherwise
+	Machine bool
+}
+
+// Labels returns the manifest labels matching the filter
+func (f SnapshotFilter) Labels() map[string]string {
+	labels := map[string]string{manifest.TypeLabelKey: snapshot.ManifestType}
+	if f.Host != "" {
+		labels[snapshot.HostnameLabel] = f.Host
+	}
+	if f.User != "" {
+		labels[snapshot.UsernameLabel] = f.User
+	}
+	if f.Changeset != "" {
+		labels[TagChangeset] = f.Changeset
+	}
+	return labels
+}
+
+// MatchesPath returns true if the source path is under any of the path prefixes
+func (f SnapshotFilter) MatchesPath(sourcePath string) bool {
+	if len(f.PathPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range f.PathPrefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if sourcePath == prefix || strings.HasPrefix(sourcePath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesSource returns true unless the source was snapshotted with a machine identity which the filter leaves out.
+// A host or user filter asks for the snapshots of a machine identity explicitly.
+func (f SnapshotFilter) MatchesSource(sourceInfo snapshot.SourceInfo) bool {
+	return f.Machine || f.Host != "" || f.User != "" || !IsMachineSource(sourceInfo)
+}
+
+// FindSnapshots returns the snapshots matching the filter, oldest first.
+// The manifests are filtered by their metadata first so that only the matching snapshots are loaded.
+func FindSnapshots(ctx context.Context, rep repo.Repository, filter SnapshotFilter) ([]*snapshot.Manifest, error) {
+	entries, err := rep.FindManifests(ctx, filter.Labels())
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []manifest.ID
+	for _, entry := range entries {
+		// The manifest is written at the end of the snapshot so it can't be older than its start
+		sourceInfo := snapshot.SourceInfo{
+			Host:     entry.Labels[snapshot.HostnameLabel],
+			UserName: entry.Labels[snapshot.UsernameLabel],
+			Path:     entry.Labels[snapshot.PathLabel],
+		}
+		if entry.ModTime.Before(filter.Since) || !filter.MatchesPath(sourceInfo.Path) || !filter.MatchesSource(sourceInfo) {
+			continue
+		}
+		ids = append(ids, entry.ID)
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*snapshot.Manifest
+	for _, man := range manifests {
+		startTime := man.StartTime.ToTime()
+		if startTime.Before(filter.Since) || (!filter.Until.IsZero() && startTime.After(filter.Until)) {
+			continue
+		}
+		result = append(result, man)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return SnapshotAfter(result[j], result[i])
+	})
+	return result, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewSnapshotDetails(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	rootId, err := object.ParseID("k0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.FailNow()
+	}
+	man := &snapshot.Manifest{
+		ID:          "abc",
+		Source:      snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: "gasset/0000000000/assets"},
+		Description: "nightly",
+		StartTime:   fs.UTCTimestampFromTime(start),
+		EndTime:     fs.UTCTimestampFromTime(start.Add(time.Minute)),
+		Stats:       snapshot.Stats{TotalFileCount: 1, TotalDirectoryCount: 1, TotalFileSize: 10},
+		Tags:        map[string]string{TagGitBranch: "main", TagGitCommit: "0123456789abcdef"},
+		Pins:        []string{"release"},
+		RootEntry: &snapshot.DirEntry{
+			ObjectID:   rootId,
+			DirSummary: &fs.DirectorySummary{TotalFileCount: 5, TotalDirCount: 2, TotalFileSize: 100, FatalErrorCount: 1},
+		},
+	}
+
+	assert.Equal(t, SnapshotDetails{
+		ID:           "abc",
+		Path:         "gasset/0000000000/assets",
+		User:         "user",
+		Host:         "host-pc",
+		Description:  "nightly",
+		StartTime:    start,
+		EndTime:      start.Add(time.Minute),
+		Tags:         man.Tags,
+		Pins:         []string{"release"},
+		GitBranch:    "main",
+		GitCommit:    "0123456789abcdef",
+		Files:        5,
+		Dirs:         2,
+		Bytes:        100,
+		Errors:       1,
+		RootObjectID: "k0123456789abcdef0123456789abcdef",
+	}, NewSnapshotDetails(man))
+}
+
+func TestSnapshotFilter(t *testing.T) {
+	filter := SnapshotFilter{Host: "ci-agent", PathPrefixes: []string{"/gasset/assets/"}}
+	assert.Equal(t, map[string]string{
+		manifest.TypeLabelKey:  snapshot.ManifestType,
+		snapshot.HostnameLabel: "ci-agent",
+	}, filter.Labels())
+	assert.True(t, filter.MatchesPath("/gasset/assets"))
+	assert.True(t, filter.MatchesPath("/gasset/assets/textures"))
+	assert.False(t, filter.MatchesPath("/gasset/assets2"))
+	assert.True(t, SnapshotFilter{}.MatchesPath("/other"))
+
+	machine := snapshot.SourceInfo{Host: "ci-agent", UserName: MachineUserName, Path: "/gasset/assets"}
+	assert.False(t, SnapshotFilter{}.MatchesSource(machine))
+	assert.True(t, SnapshotFilter{Machine: true}.MatchesSource(machine))
+	assert.True(t, filter.MatchesSource(machine))
+	assert.True(t, SnapshotFilter{}.MatchesSource(snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: "/gasset/assets"}))
+}
+
+func TestFindSnapshots(t *testing.T) {
+	options := &OptionsForTest{}
+	if err := SetupTestOptions(options); err != nil {
+		t.FailNow()
+	}
+	op := options.OptionsWithGassetId.Clone()
+	ctx := context.Background()
+
+	if _, err := SetupFakeRepository(ctx, op, t.TempDir()); !assert.NoError(t, err) {
+		return
+	}
+	kopiaUserConfigPath, err := op.GetKopiaUserConfigPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+	rep, err := op.RepoOpen(ctx, kopiaUserConfigPath, op.Password, &repo.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rep.Close(ctx)
+
+	now := time.Now()
+	snapshots := []*snapshot.Manifest{
+		{Source: snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: "/gasset/assets"}, StartTime: fs.UTCTimestampFromTime(now.Add(-time.Hour))},
+		{Source: snapshot.SourceInfo{Host: "ci-agent", UserName: MachineUserName, Path: "/gasset/assets"}, StartTime: fs.UTCTimestampFromTime(now.Add(-48 * time.Hour))},
+		{Source: snapshot.SourceInfo{Host: "host-pc", UserName: "user", Path: "/gasset/sounds"}, StartTime: fs.UTCTimestampFromTime(now.Add(-2 * time.Hour))},
+	}
+	err = repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		for _, man := range snapshots {
+			if _, err := snapshot.SaveSnapshot(ctx, w, man); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids := func(filter SnapshotFilter) []manifest.ID {
+		manifests, err := FindSnapshots(ctx, rep, filter)
+		assert.NoError(t, err)
+		var result []manifest.ID
+		for _, man := range manifests {
+			result = append(result, man.ID)
+		}
+		return result
+	}
+
+	assert.Equal(t, []manifest.ID{snapshots[2].ID, snapshots[0].ID}, ids(SnapshotFilter{}), "the machine snapshots are left out")
+	assert.Equal(t, []manifest.ID{snapshots[1].ID, snapshots[2].ID, snapshots[0].ID}, ids(SnapshotFilter{Machine: true}))
+	assert.Equal(t, []manifest.ID{snapshots[1].ID}, ids(SnapshotFilter{Host: "ci-agent"}))
+	assert.Equal(t, []manifest.ID{snapshots[1].ID, snapshots[0].ID}, ids(SnapshotFilter{PathPrefixes: []string{"/gasset/assets"}, Machine: true}))
+	assert.Equal(t, []manifest.ID{snapshots[2].ID, snapshots[0].ID}, ids(SnapshotFilter{User: "user", Since: now.Add(-3 * time.Hour)}))
+	assert.Equal(t, []manifest.ID{snapshots[1].ID}, ids(SnapshotFilter{Until: now.Add(-24 * time.Hour), Machine: true}))
+}
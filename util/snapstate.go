@@ -0,0 +1,147 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/kopia/kopia/fs/localfs"
+	iofs "io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// SnapState is the size and modification time of every file in the asset directories when all of them were
+// last snapshotted on this machine, together with a hash of the .gasset file and the storage flags. While the
+// files are in this state, snap can exit without connecting to the repository.
+type SnapState struct {
+	ConfigHash string               `json:"configHash"`
+	Files      map[string]FileState `json:"files"`
+}
+
+func (op *Options) GetSnapStatePath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "snapstate-"+op.stateID()+".json"), nil
+}
+
+// ScanSnapState returns the current state of the files in the asset directories without reading their content.
+// The lock files of the directories are left out as snap writes them after the snapshot.
+func (op *Options) ScanSnapState(ctx context.Context) (*SnapState, error) {
+	configPath, err := FindConfigFile(op.WorkingDirectory)
+	if err != nil {
+		return nil, err
+	}
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	// The root .gassetignore file changes what is snapshotted without changing the files
+	ignoreBytes, err := os.ReadFile(filepath.Join(op.WorkingDirectory, GassetIgnoreFileName))
+	if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return nil, err
+	}
+	configHash := sha256.New()
+	configHash.Write(configBytes)
+	configHash.Write([]byte{0})
+	configHash.Write(ignoreBytes)
+	for _, flag := range []string{op.StorageURL, op.KopiaConfigPath, op.MachineIdentity} {
+		configHash.Write([]byte{0})
+		configHash.Write([]byte(flag))
+	}
+
+	state := &SnapState{ConfigHash: hex.EncodeToString(configHash.Sum(nil)), Files: map[string]FileState{}}
+	for _, dirPath := range op.Config.Dirs {
+		dir, err := localfs.Directory(filepath.Join(op.WorkingDirectory, dirPath))
+		if err != nil {
+			return nil, err
+		}
+		dirPrefix := path.Clean(filepath.ToSlash(dirPath)) + "/"
+		states, err := DirFileStates(ctx, dir, dirPrefix)
+		if err != nil {
+			return nil, err
+		}
+		if op.Config.LockFilePerDir {
+			delete(states, dirPrefix+LockFileName)
+		}
+		maps.Copy(state.Files, states)
+	}
+	return state, nil
+}
+
+// Matches tells whether the files and the config are in the same state as in the other one
+func (s *SnapState) Matches(other *SnapState) bool {
+	if s == nil || other == nil || s.ConfigHash != other.ConfigHash || len(s.Files) != len(other.Files) {
+		return false
+	}
+	for filePath, state := range s.Files {
+		otherState, ok := other.Files[filePath]
+		if !ok || state.Size != otherState.Size || !state.ModTime.Equal(otherState.ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadSnapState returns the state recorded by the last snap of all the asset directories or nil if there is none
+func (op *Options) LoadSnapState() (*SnapState, error) {
+	statePath, err := op.GetSnapStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	stateBytes, err := os.ReadFile(statePath)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &SnapState{}
+	if err := json.Unmarshal(stateBytes, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (op *Options) SaveSnapState(state *SnapState) error {
+	statePath, err := op.GetSnapStatePath()
+	if err != nil {
+		return err
+	}
+
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, stateBytes, 0o600)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapState(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+	op := testOptions.OptionsWithGassetId.Clone()
+	op.WorkingDirectory = t.TempDir()
+	userConfigDir := t.TempDir()
+	op.OsUserConfigDir = func() (string, error) {
+		return userConfigDir, nil
+	}
+	op.Config.Dirs = []string{"./assets"}
+	op.Config.LockFilePerDir = true
+	ctx := context.Background()
+
+	files := map[string]string{
+		".gasset":             `{"gassetId": "0000000000", "dirs": ["./assets"]}`,
+		"assets/a.txt":        "a",
+		"assets/sub/b.txt":    "b",
+		"assets/.gasset.lock": `{}`,
+	}
+	for name, content := range files {
+		filePath := filepath.Join(op.WorkingDirectory, filepath.FromSlash(name))
+		if os.MkdirAll(filepath.Dir(filePath), 0o755) != nil || os.WriteFile(filePath, []byte(content), 0o644) != nil {
+			t.FailNow()
+		}
+	}
+
+	recorded, err := op.LoadSnapState()
+	assert.NoError(t, err)
+	assert.Nil(t, recorded, "no snap recorded a state yet")
+
+	state, err := op.ScanSnapState(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, state.Files, 2, "the lock file of the directory is left out")
+	assert.Contains(t, state.Files, "assets/sub/b.txt")
+	assert.False(t, state.Matches(recorded))
+
+	if !assert.NoError(t, op.SaveSnapState(state)) {
+		return
+	}
+	recorded, err = op.LoadSnapState()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The lock file written after the snapshot is no change
+	assert.NoError(t, os.WriteFile(filepath.Join(op.WorkingDirectory, "assets", LockFileName), []byte(`{"archives": []}`), 0o644))
+	state, err = op.ScanSnapState(ctx)
+	if assert.NoError(t, err) {
+		assert.True(t, state.Matches(recorded))
+	}
+
+	touched := filepath.Join(op.WorkingDirectory, "assets", "sub", "b.txt")
+	assert.NoError(t, os.Chtimes(touched, time.Now(), time.Now().Add(time.Hour)))
+	state, err = op.ScanSnapState(ctx)
+	if assert.NoError(t, err) {
+		assert.False(t, state.Matches(recorded), "Matches() with a touched file")
+	}
+
+	// Another storage snapshots to another repository
+	if !assert.NoError(t, op.SaveSnapState(state)) {
+		return
+	}
+	op.StorageURL = "file:///mnt/assets"
+	recorded = state
+	state, err = op.ScanSnapState(ctx)
+	if assert.NoError(t, err) {
+		assert.False(t, state.Matches(recorded), "Matches() with another storage")
+	}
+}
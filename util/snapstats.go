@@ -0,0 +1,157 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapStatsManifestType labels the statistics of the snap runs stored as manifests in the kopia repository,
+// one per run so that the history shows how the snapshots of the repository evolve
+const SnapStatsManifestType = "gasset-snap-stats"
+
+type SnapStats struct {
+	ID         manifest.ID   `json:"-"`
+	User       string        `json:"user"`
+	Host       string        `json:"host"`
+	StartTime  time.Time     `json:"startTime"`
+	Duration   time.Duration `json:"duration"`
+	Dirs       int           `json:"dirs"`
+	Snapshots  int           `json:"snapshots"`
+	FailedDirs int           `json:"failedDirs,omitempty"`
+	FileErrors int           `json:"fileErrors,omitempty"`
+	Files      int64         `json:"files"`
+	// Bytes is the size of the files of the saved snapshots, UploadedBytes the part of it which had to be
+	// written to the storage after deduplication and compression
+	Bytes         int64 `json:"bytes"`
+	UploadedBytes int64 `json:"uploadedBytes"`
+}
+
+// NewSnapStats returns the statistics of a snap run of the current user or machine identity started at the given time
+func (op *Options) NewSnapStats(start time.Time) *SnapStats {
+	clientOptions := op.ClientOptions()
+	return &SnapStats{
+		User:      clientOptions.Username,
+		Host:      clientOptions.Hostname,
+		StartTime: start.UTC(),
+	}
+}
+
+// AddSnapshot adds the files of a saved snapshot to the statistics
+func (s *SnapStats) AddSnapshot(man *snapshot.Manifest) {
+	s.Snapshots++
+	s.Files += int64(man.Stats.TotalFileCount)
+	s.Bytes += man.Stats.TotalFileSize
+	s.FileErrors += int(man.Stats.ErrorCount)
+}
+
+// DedupRatio returns the share of the bytes which did not have to be uploaded, 0 without bytes
+func (s *SnapStats) DedupRatio() float64 {
+	if s.Bytes <= 0 {
+		return 0
+	}
+	ratio := 1 - float64(s.UploadedBytes)/float64(s.Bytes)
+	if ratio < 0 {
+		return 0
+	}
+	return ratio
+}
+
+// WriteSnapStats stores the statistics in the repository as part of the write session of the snap run
+func WriteSnapStats(ctx context.Context, writer repo.RepositoryWriter, stats *SnapStats) error {
+	id, err := writer.PutManifest(ctx, map[string]string{
+		manifest.TypeLabelKey: SnapStatsManifestType,
+		"user":                stats.User,
+		"host":                stats.Host,
+	}, stats)
+	if err != nil {
+		return err
+	}
+	stats.ID = id
+	return nil
+}
+
+// ListSnapStats returns the statistics of the latest snap runs, oldest first, all of them if limit is not positive
+func ListSnapStats(ctx context.Context, rep repo.Repository, limit int) ([]*SnapStats, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{manifest.TypeLabelKey: SnapStatsManifestType})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime.Before(entries[j].ModTime)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	history := make([]*SnapStats, 0, len(entries))
+	for _, entry := range entries {
+		stats := &SnapStats{}
+		if _, err := rep.GetManifest(ctx, entry.ID, stats); err != nil {
+			return nil, err
+		}
+		stats.ID = entry.ID
+		history = append(history, stats)
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].StartTime.Before(history[j].StartTime)
+	})
+	return history, nil
+}
+
+// Sparkline renders the values as a line of bars scaled between the smallest and the largest one
+func Sparkline(values []float64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, value := range values {
+		low = min(low, value)
+		high = max(high, value)
+	}
+
+	var builder strings.Builder
+	for _, value := range values {
+		index := 0
+		if high > low {
+			index = int((value - low) / (high - low) * float64(len(bars)-1))
+		}
+		builder.WriteRune(bars[index])
+	}
+	return builder.String()
+}
+
+// Median returns the median of the values, 0 without values
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSnapStatsDedupRatio(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats SnapStats
+		want  float64
+	}{
+		{
+			name:  "Nothing saved",
+			stats: SnapStats{},
+			want:  0,
+		},
+		{
+			name:  "A quarter of the bytes uploaded",
+			stats: SnapStats{Bytes: 400, UploadedBytes: 100},
+			want:  0.75,
+		},
+		{
+			name:  "More uploaded than saved",
+			stats: SnapStats{Bytes: 100, UploadedBytes: 150},
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.stats.DedupRatio(), "DedupRatio()")
+		})
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "", Sparkline(nil))
+	assert.Equal(t, "▁▁▁", Sparkline([]float64{5, 5, 5}))
+	assert.Equal(t, "▁▄█", Sparkline([]float64{10, 15, 20}))
+}
+
+func TestMedian(t *testing.T) {
+	assert.Equal(t, 0.0, Median(nil))
+	assert.Equal(t, 3.0, Median([]float64{5, 1, 3}))
+	assert.Equal(t, 2.5, Median([]float64{4, 1, 3, 2}))
+}
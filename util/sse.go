@@ -0,0 +1,186 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// SSEKMS encrypts the blobs with a key of AWS KMS, the one of kmsKeyId or else the key of S3 managed by AWS
+	SSEKMS = "aws:kms"
+	// SSES3 encrypts the blobs with the keys of S3
+	SSES3 = "AES256"
+)
+
+// encryptionCheckBlob is the object written to check that the blobs can be encrypted, it is removed right away
+const encryptionCheckBlob = "gasset-encryption-check"
+
+// EncryptionOptions are the keys of the s3 config of the kopia block, next to the ones of kopia, to encrypt the
+// blobs written to the bucket on the server side. The blobs are encrypted by kopia already, this satisfies the
+// policies requiring the objects of the bucket to be encrypted with a key of the organisation.
+type EncryptionOptions struct {
+	SSE      string `json:"sse"`
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
+}
+
+func (e *EncryptionOptions) serverSide() (encrypt.ServerSide, error) {
+	switch e.SSE {
+	case SSEKMS:
+		return encrypt.NewSSEKMS(e.KMSKeyID, nil)
+	case SSES3:
+		return encrypt.NewSSE(), nil
+	default:
+		return nil, fmt.Errorf("unknown sse %s, expected %s or %s", e.SSE, SSEKMS, SSES3)
+	}
+}
+
+// String names the key, e.g. in the errors
+func (e *EncryptionOptions) String() string {
+	if e.KMSKeyID != "" {
+		return fmt.Sprintf("the KMS key %s", e.KMSKeyID)
+	}
+	return e.SSE
+}
+
+// CheckS3Encryption writes and removes an object encrypted like the blobs will be, so that a KMS key which can't be
+// used, e.g. without the permission to generate data keys with it, fails before the repository is created
+func CheckS3Encryption(ctx context.Context, opt *s3.Options, encryption *EncryptionOptions) error {
+	serverSide, err := encryption.serverSide()
+	if err != nil {
+		return err
+	}
+	client, err := newS3Client(opt)
+	if err != nil {
+		return err
+	}
+
+	name := opt.Prefix + encryptionCheckBlob
+	content := []byte("gasset")
+	if _, err := client.PutObject(ctx, opt.BucketName, name, bytes.NewReader(content), int64(len(content)), minio.PutObjectOptions{
+		ServerSideEncryption: serverSide,
+	}); err != nil {
+		return fmt.Errorf("the blobs of the bucket %s can't be encrypted with %s: %w", opt.BucketName, encryption, err)
+	}
+	if err := client.RemoveObject(ctx, opt.BucketName, name, minio.RemoveObjectOptions{}); err != nil {
+		log.Printf("Warning: could not remove %s from the bucket %s: %v", name, opt.BucketName, err)
+	}
+	return nil
+}
+
+var activeEncryption atomic.Pointer[EncryptionOptions]
+
+// EnableEncryption encrypts the blobs written to every s3 storage opened from now on, including the ones kopia opens from its config
+func EnableEncryption(encryption *EncryptionOptions) {
+	registerS3Storage()
+	activeEncryption.Store(encryption)
+}
+
+// DisableEncryption leaves the encryption of the blobs written to the s3 storages opened from now on to the bucket
+func DisableEncryption() {
+	activeEncryption.Store(nil)
+}
+
+func newEncryptedStorage(st blob.Storage, opt *s3.Options, encryption *EncryptionOptions) (blob.Storage, error) {
+	serverSide, err := encryption.serverSide()
+	if err != nil {
+		return nil, err
+	}
+	client, err := newS3Client(opt)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedStorage{Storage: st, client: client, bucket: opt.BucketName, prefix: opt.Prefix, serverSide: serverSide}, nil
+}
+
+// encryptedStorage writes the blobs with the headers of the server side encryption, which the s3 storage of kopia
+// does not send. The other operations are the ones of the s3 storage.
+type encryptedStorage struct {
+	blob.Storage
+	client     *minio.Client
+	bucket     string
+	prefix     string
+	serverSide encrypt.ServerSide
+}
+
+// PutBlob uploads the blob like the s3 storage of kopia does, with the server side encryption
+func (s *encryptedStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	switch {
+	case opts.DoNotRecreate:
+		return fmt.Errorf("do-not-recreate: %w", blob.ErrUnsupportedPutBlobOption)
+	case !opts.SetModTime.IsZero():
+		return blob.ErrSetTimeUnsupported
+	}
+
+	putOptions := minio.PutObjectOptions{
+		ContentType:          "application/x-kopia",
+		DisableMultipart:     true,
+		SendContentMd5:       true,
+		ServerSideEncryption: s.serverSide,
+	}
+	if opts.RetentionPeriod != 0 {
+		putOptions.Mode = minio.RetentionMode(opts.RetentionMode)
+		if !putOptions.Mode.IsValid() {
+			return fmt.Errorf("invalid retention mode: %q", opts.RetentionMode)
+		}
+		putOptions.RetainUntilDate = time.Now().Add(opts.RetentionPeriod).UTC()
+	}
+	if _, err := s.client.PutObject(ctx, s.bucket, s.prefix+string(id), data.Reader(), int64(data.Length()), putOptions); err != nil {
+		return err
+	}
+
+	if opts.GetModTime != nil {
+		metadata, err := s.Storage.GetMetadata(ctx, id)
+		if err != nil {
+			return err
+		}
+		*opts.GetModTime = metadata.Timestamp
+	}
+	return nil
+}
+
+// decodeEncryption returns the encryption options of the s3 config of the kopia block of the JSON config
+func decodeEncryption(data []byte) (*EncryptionOptions, error) {
+	encryption := &EncryptionOptions{}
+	storageType, err := decodeStorageConfigKeys(data, encryption)
+	if err != nil || *encryption == (EncryptionOptions{}) {
+		return nil, err
+	}
+	if storageType != "s3" {
+		return nil, fmt.Errorf("sse only applies to the s3 storage, not to the %s storage", storageType)
+	}
+	if encryption.SSE == "" {
+		return nil, fmt.Errorf("the s3 storage has kmsKeyId without sse, set sse to %s", SSEKMS)
+	}
+	if encryption.KMSKeyID != "" && encryption.SSE != SSEKMS {
+		return nil, errors.New("kmsKeyId only applies to the sse " + SSEKMS)
+	}
+	if _, err := encryption.serverSide(); err != nil {
+		return nil, err
+	}
+	return encryption, nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newSSEServer accepts every put and records the server side encryption headers of the puts by object
+func newSSEServer(t *testing.T, headers map[string]http.Header, mu *sync.Mutex) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			mu.Lock()
+			headers[r.URL.Path] = r.Header.Clone()
+			mu.Unlock()
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	headers := map[string]http.Header{}
+	server := newSSEServer(t, headers, &mu)
+	opt := &s3.Options{
+		BucketName:      "bucket",
+		Prefix:          "assets/",
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		DoNotUseTLS:     true,
+		Region:          "us-east-1",
+		AccessKeyID:     "accessid",
+		SecretAccessKey: "secret",
+	}
+
+	st, err := newEncryptedStorage(StubStorage{}, opt, &EncryptionOptions{SSE: SSEKMS, KMSKeyID: "alias/assets"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, st.PutBlob(ctx, "p0123", chaosBytes("content"), blob.PutOptions{}))
+	put := headers["/bucket/assets/p0123"]
+	if assert.NotNil(t, put) {
+		assert.Equal(t, "aws:kms", put.Get("X-Amz-Server-Side-Encryption"))
+		assert.Equal(t, "alias/assets", put.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	}
+	assert.ErrorIs(t, st.PutBlob(ctx, "p0123", chaosBytes("content"), blob.PutOptions{DoNotRecreate: true}), blob.ErrUnsupportedPutBlobOption)
+
+	st, err = newEncryptedStorage(StubStorage{}, opt, &EncryptionOptions{SSE: SSES3})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, st.PutBlob(ctx, "q0123", chaosBytes("content"), blob.PutOptions{}))
+	put = headers["/bucket/assets/q0123"]
+	if assert.NotNil(t, put) {
+		assert.Equal(t, "AES256", put.Get("X-Amz-Server-Side-Encryption"))
+		assert.Empty(t, put.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	}
+
+	assert.NoError(t, CheckS3Encryption(ctx, opt, &EncryptionOptions{SSE: SSEKMS, KMSKeyID: "alias/assets"}))
+	assert.Contains(t, headers, "/bucket/assets/"+encryptionCheckBlob)
+}
+
+func TestEncryptionConfig(t *testing.T) {
+	original := []byte(`{
+  "kopia": {
+    "storage": {
+      "type": "s3",
+      "config": {
+        "bucket": "bucket",
+        "endpoint": "s3.amazonaws.com",
+        "sse": "aws:kms",
+        "kmsKeyId": "alias/assets"
+      }
+    }
+  },
+  "dirs": ["./assets"]
+}`)
+	config, err := decodeConfig(original, ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &EncryptionOptions{SSE: SSEKMS, KMSKeyID: "alias/assets"}, config.Encryption)
+	assert.Nil(t, config.AssumeRole)
+
+	encoded, err := encodeConfig(config, original, ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+	decoded, err := decodeConfig(encoded, ConfigFormatJSON)
+	if assert.NoError(t, err) {
+		assert.Equal(t, config.Encryption, decoded.Encryption)
+	}
+
+	_, err = decodeConfig([]byte(`{"kopia": {"storage": {"type": "b2", "config": {"bucket": "b", "sse": "AES256"}}}, "dirs": []}`), ConfigFormatJSON)
+	assert.Error(t, err, "decodeConfig() of the sse of a b2 storage")
+	_, err = decodeConfig([]byte(`{"kopia": {"storage": {"type": "s3", "config": {"bucket": "b", "kmsKeyId": "alias/assets"}}}, "dirs": []}`), ConfigFormatJSON)
+	assert.Error(t, err, "decodeConfig() of a KMS key without sse")
+	_, err = decodeConfig([]byte(`{"kopia": {"storage": {"type": "s3", "config": {"bucket": "b", "sse": "AES256", "kmsKeyId": "alias/assets"}}}, "dirs": []}`), ConfigFormatJSON)
+	assert.Error(t, err, "decodeConfig() of a KMS key with the keys of S3")
+	_, err = decodeConfig([]byte(`{"kopia": {"storage": {"type": "s3", "config": {"bucket": "b", "sse": "kms"}}}, "dirs": []}`), ConfigFormatJSON)
+	assert.Error(t, err, "decodeConfig() of an unknown sse")
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/restore"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// StagedOutput restores every file into a staging directory first and renames it into place once it is complete,
+// so that engines watching the asset directories never open half-written files
+type StagedOutput struct {
+	*restore.FilesystemOutput
+	staging    *restore.FilesystemOutput
+	stagingDir string
+	counter    atomic.Int64
+}
+
+// NewStagedOutput creates a staging directory under stagingRoot which must be on the same filesystem as the target
+func NewStagedOutput(ctx context.Context, output *restore.FilesystemOutput, stagingRoot string) (*StagedOutput, error) {
+	if err := os.MkdirAll(stagingRoot, 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(output.TargetPath, DefaultDirMode); err != nil {
+		return nil, err
+	}
+
+	stagingDir, err := os.MkdirTemp(stagingRoot, "gasset-restore-")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSameFilesystem(stagingDir, output.TargetPath); err != nil {
+		os.RemoveAll(stagingDir)
+		return nil, err
+	}
+
+	staging := *output
+	staging.TargetPath = stagingDir
+	staging.OverwriteFiles = true
+	staging.WriteFilesAtomically = false
+
+	for _, o := range []*restore.FilesystemOutput{output, &staging} {
+		if err := o.Init(ctx); err != nil {
+			os.RemoveAll(stagingDir)
+			return nil, err
+		}
+	}
+
+	return &StagedOutput{
+		FilesystemOutput: output,
+		staging:          &staging,
+		stagingDir:       stagingDir,
+	}, nil
+}
+
+// checkSameFilesystem renames a probe file from the staging directory into the target since renames across
+// filesystems fail and would not be atomic anyway
+func checkSameFilesystem(stagingDir string, targetDir string) error {
+	probe, err := os.CreateTemp(stagingDir, "probe-")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+
+	target := filepath.Join(targetDir, ".gasset-"+filepath.Base(probe.Name()))
+	if err := os.Rename(probe.Name(), target); err != nil {
+		os.Remove(probe.Name())
+		return fmt.Errorf("staging directory %s must be on the same filesystem as %s: %w", stagingDir, targetDir, err)
+	}
+	return os.Remove(target)
+}
+
+// WriteFile implements restore.Output interface.
+func (o *StagedOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	targetPath := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+	if _, err := os.Lstat(targetPath); err == nil && !o.OverwriteFiles {
+		return fmt.Errorf("unable to create %q, it already exists", targetPath)
+	}
+
+	stagedName := fmt.Sprintf("%d-%s", o.counter.Add(1), path.Base(relativePath))
+	if err := o.staging.WriteFile(ctx, stagedName, f); err != nil {
+		return err
+	}
+
+	if err := os.Rename(filepath.Join(o.stagingDir, stagedName), targetPath); err != nil {
+		return err
+	}
+	return restore.SafeRemoveAll(targetPath)
+}
+
+// Close implements restore.Output interface.
+func (o *StagedOutput) Close(ctx context.Context) error {
+	if err := o.FilesystemOutput.Close(ctx); err != nil {
+		return err
+	}
+	return os.RemoveAll(o.stagingDir)
+}
+
+var _ restore.Output = (*StagedOutput)(nil)
@@ -0,0 +1,102 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStagedOutput(t *testing.T) {
+	tests := []struct {
+		name           string
+		existing       bool
+		overwriteFiles bool
+		wantErr        assert.ErrorAssertionFunc
+		wantContent    string
+	}{
+		{
+			name:        "Restore a new file through the staging directory",
+			wantErr:     assert.NoError,
+			wantContent: "restored",
+		},
+		{
+			name:           "Overwrite an existing file",
+			existing:       true,
+			overwriteFiles: true,
+			wantErr:        assert.NoError,
+			wantContent:    "restored",
+		},
+		{
+			name:        "Keep an existing file without overwrite",
+			existing:    true,
+			wantErr:     assert.Error,
+			wantContent: "existing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			root := t.TempDir()
+			source := filepath.Join(root, "source.bin")
+			target := filepath.Join(root, "target")
+			stagingRoot := filepath.Join(root, "staging")
+
+			if err := os.WriteFile(source, []byte("restored"), 0644); err != nil {
+				t.FailNow()
+			}
+			if tt.existing {
+				if err := os.MkdirAll(target, 0755); err != nil {
+					t.FailNow()
+				}
+				if err := os.WriteFile(filepath.Join(target, "asset.bin"), []byte("existing"), 0644); err != nil {
+					t.FailNow()
+				}
+			}
+
+			entry, err := localfs.NewEntry(source)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			output, err := NewStagedOutput(ctx, &restore.FilesystemOutput{
+				TargetPath:     target,
+				OverwriteFiles: tt.overwriteFiles,
+			}, stagingRoot)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			tt.wantErr(t, output.WriteFile(ctx, "asset.bin", entry.(fs.File)), "WriteFile(%v)", "asset.bin")
+			assert.NoError(t, output.Close(ctx))
+
+			content, err := os.ReadFile(filepath.Join(target, "asset.bin"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantContent, string(content))
+
+			staged, err := os.ReadDir(stagingRoot)
+			assert.NoError(t, err)
+			assert.Empty(t, staged)
+		})
+	}
+}
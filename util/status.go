@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"sort"
+	"time"
+)
+
+// FileState is the size and modification time of a file, which tell if it changed without reading it
+type FileState struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// DirFileStates returns the states of the files in a local or snapshot directory by their slash separated path
+// prefixed by dirPath
+func DirFileStates(ctx context.Context, dir fs.Directory, dirPath string) (map[string]FileState, error) {
+	states := map[string]FileState{}
+	err := WalkSnapshotFiles(ctx, dir, dirPath, func(filePath string, entry fs.Entry) error {
+		if _, ok := entry.(fs.File); ok {
+			states[filePath] = FileState{Size: entry.Size(), ModTime: entry.ModTime()}
+		}
+		return nil
+	})
+	return states, err
+}
+
+// CompareWorkingFiles compares the working files of an asset directory with the files of its snapshot, both given
+// by their path relative to the working directory. Files with the size and modification time of the snapshot are
+// unchanged, just like snap considers them. A file which was only touched since is unchanged as well if its hash
+// recorded when it had the state of the snapshot still matches, hashFile hashes the working file for that.
+// The changes are sorted by path.
+func CompareWorkingFiles(snapshotFiles map[string]FileState, workingFiles map[string]FileState, recorded map[string]WorkingHash, hashFile func(filePath string) (string, error)) ([]Change, error) {
+	var changes []Change
+	for filePath, working := range workingFiles {
+		snapshotted, ok := snapshotFiles[filePath]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Path: filePath})
+			continue
+		}
+		if working.Size != snapshotted.Size {
+			changes = append(changes, Change{Kind: ChangeModified, Path: filePath})
+			continue
+		}
+		if working.ModTime.Equal(snapshotted.ModTime) {
+			continue
+		}
+
+		known, ok := recorded[filePath]
+		if ok && known.Size == snapshotted.Size && known.ModTime.Equal(snapshotted.ModTime) {
+			fileHash, err := hashFile(filePath)
+			if err != nil {
+				return nil, err
+			}
+			if fileHash == known.Hash {
+				continue
+			}
+		}
+		changes = append(changes, Change{Kind: ChangeModified, Path: filePath})
+	}
+	for filePath := range snapshotFiles {
+		if _, ok := workingFiles[filePath]; !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Path: filePath})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCompareWorkingFiles(t *testing.T) {
+	snapshotted := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	touched := snapshotted.Add(time.Hour)
+	snapshotFiles := map[string]FileState{
+		"assets/same.png":    {Size: 3, ModTime: snapshotted},
+		"assets/grown.png":   {Size: 3, ModTime: snapshotted},
+		"assets/touched.png": {Size: 3, ModTime: snapshotted},
+		"assets/edited.png":  {Size: 3, ModTime: snapshotted},
+		"assets/unknown.png": {Size: 3, ModTime: snapshotted},
+		"assets/gone.png":    {Size: 3, ModTime: snapshotted},
+	}
+	workingFiles := map[string]FileState{
+		"assets/same.png":    {Size: 3, ModTime: snapshotted},
+		"assets/grown.png":   {Size: 4, ModTime: touched},
+		"assets/touched.png": {Size: 3, ModTime: touched},
+		"assets/edited.png":  {Size: 3, ModTime: touched},
+		"assets/unknown.png": {Size: 3, ModTime: touched},
+		"assets/new.png":     {Size: 1, ModTime: touched},
+	}
+	recorded := map[string]WorkingHash{
+		"assets/touched.png": {Size: 3, ModTime: snapshotted, Hash: "abc"},
+		"assets/edited.png":  {Size: 3, ModTime: snapshotted, Hash: "abc"},
+	}
+	workingHashes := map[string]string{"assets/touched.png": "abc", "assets/edited.png": "def"}
+
+	var hashed []string
+	changes, err := CompareWorkingFiles(snapshotFiles, workingFiles, recorded, func(filePath string) (string, error) {
+		hashed = append(hashed, filePath)
+		return workingHashes[filePath], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Kind: ChangeModified, Path: "assets/edited.png"},
+		{Kind: ChangeRemoved, Path: "assets/gone.png"},
+		{Kind: ChangeModified, Path: "assets/grown.png"},
+		{Kind: ChangeAdded, Path: "assets/new.png"},
+		{Kind: ChangeModified, Path: "assets/unknown.png"},
+	}, changes)
+	assert.ElementsMatch(t, []string{"assets/touched.png", "assets/edited.png"}, hashed)
+
+	_, err = CompareWorkingFiles(snapshotFiles, workingFiles, recorded, func(string) (string, error) {
+		return "", errors.New("permission denied")
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,308 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"log"
+	"slices"
+)
+
+// StorageProvider is a backend of the storage of the kopia block, registered by the type of its connection info.
+// The config passed to its methods is the config of the connection info, e.g. *s3.Options for the s3 provider.
+type StorageProvider interface {
+	// Create opens the storage of a new repository, creating what the backend can create itself
+	Create(ctx context.Context, op *Options, config any) (blob.Storage, error)
+	// Connect opens the storage of an existing repository
+	Connect(ctx context.Context, op *Options, config any) (blob.Storage, error)
+	// ApplySecrets sets the credentials read from the environment on the config
+	ApplySecrets(config any, secrets KopiaSecrets, c *Config) error
+	// ValidateCredentials checks that the credentials of the config are complete before the storage is opened
+	ValidateCredentials(config any) error
+	// CloneConfig returns a deep copy of the config
+	CloneConfig(config any) any
+	// EnvVars returns the environment variables holding the credentials of the storage
+	EnvVars() []EnvVar
+}
+
+// PublicReadChecker is implemented by the providers of a storage which can be made readable by anyone
+type PublicReadChecker interface {
+	// PublicRead returns a problem if anyone can read the repository of the storage
+	PublicRead(ctx context.Context, op *Options, config any) []string
+}
+
+var storageProviders = map[string]StorageProvider{
+	"s3":         s3Provider{},
+	"b2":         b2Provider{},
+	"filesystem": filesystemProvider{},
+}
+
+// RegisterStorageProvider adds the provider of the storage type, replacing the one registered already
+func RegisterStorageProvider(storageType string, provider StorageProvider) {
+	storageProviders[storageType] = provider
+}
+
+// GetStorageProvider returns the provider of the storage type
+func GetStorageProvider(storageType string) (StorageProvider, error) {
+	provider, ok := storageProviders[storageType]
+	if !ok {
+		return nil, fmt.Errorf("the %s storage is not supported", storageType)
+	}
+	return provider, nil
+}
+
+// configOf returns the config of the storage as the options of its provider
+func configOf[T any](storageType string, config any) (*T, error) {
+	typed, ok := config.(*T)
+	if !ok {
+		return nil, fmt.Errorf("the config of the %s storage is invalid", storageType)
+	}
+	return typed, nil
+}
+
+type s3Provider struct{}
+
+// Create opens the bucket of a new repository, the bucket must exist already. The blobs must be encryptable with
+// the key of the .gasset file, if any, before the repository writes its first blob.
+func (p s3Provider) Create(ctx context.Context, op *Options, config any) (blob.Storage, error) {
+	if encryption := op.Config.Encryption; encryption != nil {
+		opt, err := configOf[s3.Options]("s3", config)
+		if err != nil {
+			return nil, err
+		}
+		if err := op.S3CheckEncryption(ctx, opt, encryption); err != nil {
+			return nil, err
+		}
+	}
+	return p.Connect(ctx, op, config)
+}
+
+func (s3Provider) Connect(ctx context.Context, op *Options, config any) (blob.Storage, error) {
+	opt, err := configOf[s3.Options]("s3", config)
+	if err != nil {
+		return nil, err
+	}
+	return op.S3New(ctx, opt, false)
+}
+
+func (s3Provider) ApplySecrets(config any, secrets KopiaSecrets, c *Config) error {
+	opt, err := configOf[s3.Options]("s3", config)
+	if err != nil {
+		return err
+	}
+	opt.AccessKeyID = secrets.AccessKeyID
+	opt.SecretAccessKey = secrets.SecretAccessKey
+	return ApplyAWSOptions(opt, c.AWS)
+}
+
+// ValidateCredentials accepts a bucket without credentials, kopia then uses the ones of the machine, e.g. its IAM role
+func (s3Provider) ValidateCredentials(config any) error {
+	opt, err := configOf[s3.Options]("s3", config)
+	if err != nil {
+		return err
+	}
+	if (opt.AccessKeyID == "") != (opt.SecretAccessKey == "") {
+		return fmt.Errorf("the credentials of the s3 bucket %s are incomplete, set both %s and %s", opt.BucketName, EnvAccessId, EnvAccessSecret)
+	}
+	return nil
+}
+
+func (s3Provider) CloneConfig(config any) any {
+	opt, ok := config.(*s3.Options)
+	if !ok {
+		return config
+	}
+	return &s3.Options{
+		BucketName:      opt.BucketName,
+		Prefix:          opt.Prefix,
+		Endpoint:        opt.Endpoint,
+		DoNotUseTLS:     opt.DoNotUseTLS,
+		DoNotVerifyTLS:  opt.DoNotVerifyTLS,
+		RootCA:          opt.RootCA,
+		AccessKeyID:     opt.AccessKeyID,
+		SecretAccessKey: opt.SecretAccessKey,
+		SessionToken:    opt.SessionToken,
+		Region:          opt.Region,
+		Limits: throttling.Limits{
+			ReadsPerSecond:         opt.Limits.ReadsPerSecond,
+			WritesPerSecond:        opt.Limits.WritesPerSecond,
+			ListsPerSecond:         opt.Limits.ListsPerSecond,
+			UploadBytesPerSecond:   opt.Limits.UploadBytesPerSecond,
+			DownloadBytesPerSecond: opt.Limits.DownloadBytesPerSecond,
+			ConcurrentReads:        opt.Limits.ConcurrentReads,
+			ConcurrentWrites:       opt.Limits.ConcurrentWrites,
+		},
+		PointInTime: opt.PointInTime,
+	}
+}
+
+func (s3Provider) EnvVars() []EnvVar {
+	return []EnvVar{
+		{Name: EnvAccessId, Description: "Access key id of the S3 bucket"},
+		{Name: EnvAccessSecret, Description: "Secret access key of the S3 bucket"},
+	}
+}
+
+// PublicRead checks the policy of the bucket. Not being able to read the policy, e.g. without the permission, is not a problem.
+func (s3Provider) PublicRead(ctx context.Context, op *Options, config any) []string {
+	opt, ok := config.(*s3.Options)
+	if !ok {
+		return nil
+	}
+	bucketPolicy, err := op.S3BucketPolicy(ctx, opt)
+	if err != nil {
+		log.Printf("Could not check if the bucket %s is public: %v", opt.BucketName, err)
+		return nil
+	}
+
+	public, err := IsPublicReadPolicy(bucketPolicy, opt.BucketName, opt.Prefix)
+	if err != nil {
+		log.Printf("Could not check if the bucket %s is public: %v", opt.BucketName, err)
+		return nil
+	}
+	if public {
+		return []string{fmt.Sprintf("the policy of the bucket %s lets anyone read the repository", opt.BucketName)}
+	}
+	return nil
+}
+
+type b2Provider struct{}
+
+// Create opens the bucket of a new repository, the bucket must exist already
+func (p b2Provider) Create(ctx context.Context, op *Options, config any) (blob.Storage, error) {
+	return p.Connect(ctx, op, config)
+}
+
+func (b2Provider) Connect(ctx context.Context, op *Options, config any) (blob.Storage, error) {
+	opt, err := configOf[b2.Options]("b2", config)
+	if err != nil {
+		return nil, err
+	}
+	return op.B2New(ctx, opt, false)
+}
+
+func (b2Provider) ApplySecrets(config any, secrets KopiaSecrets, _ *Config) error {
+	opt, err := configOf[b2.Options]("b2", config)
+	if err != nil {
+		return err
+	}
+	opt.KeyID = secrets.B2KeyID
+	opt.Key = secrets.B2Key
+	return nil
+}
+
+// ValidateCredentials requires the application key, b2 has no anonymous access
+func (b2Provider) ValidateCredentials(config any) error {
+	opt, err := configOf[b2.Options]("b2", config)
+	if err != nil {
+		return err
+	}
+	if opt.KeyID == "" || opt.Key == "" {
+		return fmt.Errorf("the application key of the b2 bucket %s is missing, set %s and %s", opt.BucketName, EnvB2KeyId, EnvB2Key)
+	}
+	return nil
+}
+
+func (b2Provider) CloneConfig(config any) any {
+	opt, ok := config.(*b2.Options)
+	if !ok {
+		return config
+	}
+	return &b2.Options{
+		BucketName: opt.BucketName,
+		Prefix:     opt.Prefix,
+		KeyID:      opt.KeyID,
+		Key:        opt.Key,
+		Limits:     opt.Limits,
+	}
+}
+
+func (b2Provider) EnvVars() []EnvVar {
+	return []EnvVar{
+		{Name: EnvB2KeyId, Description: "Application key id of the B2 bucket"},
+		{Name: EnvB2Key, Description: "Application key of the B2 bucket"},
+	}
+}
+
+type filesystemProvider struct{}
+
+// Create creates the directory of a new repository, e.g. on a mounted network drive
+func (filesystemProvider) Create(ctx context.Context, op *Options, config any) (blob.Storage, error) {
+	opt, err := configOf[filesystem.Options]("filesystem", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckFilesystemStoragePath(opt.Path, true); err != nil {
+		return nil, err
+	}
+	return op.FilesystemNew(ctx, opt, true)
+}
+
+func (filesystemProvider) Connect(ctx context.Context, op *Options, config any) (blob.Storage, error) {
+	opt, err := configOf[filesystem.Options]("filesystem", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckFilesystemStoragePath(opt.Path, false); err != nil {
+		return nil, err
+	}
+	return op.FilesystemNew(ctx, opt, false)
+}
+
+// ApplySecrets does nothing, the directory is accessed with the permissions of the user
+func (filesystemProvider) ApplySecrets(any, KopiaSecrets, *Config) error {
+	return nil
+}
+
+func (filesystemProvider) ValidateCredentials(config any) error {
+	_, err := configOf[filesystem.Options]("filesystem", config)
+	return err
+}
+
+func (filesystemProvider) CloneConfig(config any) any {
+	opt, ok := config.(*filesystem.Options)
+	if !ok {
+		return config
+	}
+	copied := &filesystem.Options{
+		Path:          opt.Path,
+		FileMode:      opt.FileMode,
+		DirectoryMode: opt.DirectoryMode,
+		Options:       opt.Options,
+		Limits:        opt.Limits,
+	}
+	copied.DirectoryShards = slices.Clone(opt.DirectoryShards)
+	if opt.FileUID != nil {
+		uid := *opt.FileUID
+		copied.FileUID = &uid
+	}
+	if opt.FileGID != nil {
+		gid := *opt.FileGID
+		copied.FileGID = &gid
+	}
+	return copied
+}
+
+func (filesystemProvider) EnvVars() []EnvVar {
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type memoryProvider struct {
+	filesystemProvider
+	opened []bool
+}
+
+func (p *memoryProvider) Create(context.Context, *Options, any) (blob.Storage, error) {
+	p.opened = append(p.opened, true)
+	return nil, nil
+}
+
+func (p *memoryProvider) Connect(context.Context, *Options, any) (blob.Storage, error) {
+	p.opened = append(p.opened, false)
+	return nil, nil
+}
+
+func TestGetStorageProvider(t *testing.T) {
+	for _, storageType := range []string{"s3", "b2", "filesystem"} {
+		_, err := GetStorageProvider(storageType)
+		assert.NoErrorf(t, err, "GetStorageProvider(%v)", storageType)
+	}
+	_, err := GetStorageProvider("memory")
+	assert.Error(t, err, "GetStorageProvider() of an unregistered storage")
+
+	provider := &memoryProvider{}
+	RegisterStorageProvider("memory", provider)
+	defer delete(storageProviders, "memory")
+	got, err := GetStorageProvider("memory")
+	if assert.NoError(t, err) {
+		_, _ = got.Create(context.Background(), &Options{}, nil)
+		assert.Equal(t, []bool{true}, provider.opened)
+	}
+}
+
+func TestStorageProvider_ValidateCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		storageType string
+		config      any
+		wantErr     assert.ErrorAssertionFunc
+	}{
+		{
+			name:        "Accept an s3 bucket without credentials",
+			storageType: "s3",
+			config:      &s3.Options{BucketName: "bucket"},
+			wantErr:     assert.NoError,
+		},
+		{
+			name:        "Reject an s3 bucket with only the access key id",
+			storageType: "s3",
+			config:      &s3.Options{BucketName: "bucket", AccessKeyID: "id"},
+			wantErr:     assert.Error,
+		},
+		{
+			name:        "Reject a b2 bucket without the application key",
+			storageType: "b2",
+			config:      &b2.Options{BucketName: "bucket", KeyID: "id"},
+			wantErr:     assert.Error,
+		},
+		{
+			name:        "Accept a b2 bucket with the application key",
+			storageType: "b2",
+			config:      &b2.Options{BucketName: "bucket", KeyID: "id", Key: "key"},
+			wantErr:     assert.NoError,
+		},
+		{
+			name:        "Reject the config of another storage",
+			storageType: "filesystem",
+			config:      &s3.Options{BucketName: "bucket"},
+			wantErr:     assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := GetStorageProvider(tt.storageType)
+			if !assert.NoError(t, err) {
+				return
+			}
+			tt.wantErr(t, provider.ValidateCredentials(tt.config), "ValidateCredentials(%v)", tt.config)
+		})
+	}
+}
+
+func TestStorageProvider_CloneConfig(t *testing.T) {
+	uid := 1000
+	opt := &filesystem.Options{Path: "/mnt/nas/assets", FileUID: &uid}
+	opt.DirectoryShards = []int{1, 3}
+	cloned := filesystemProvider{}.CloneConfig(opt).(*filesystem.Options)
+	assert.Equal(t, opt, cloned)
+	cloned.DirectoryShards[0] = 2
+	*cloned.FileUID = 0
+	assert.Equal(t, []int{1, 3}, opt.DirectoryShards)
+	assert.Equal(t, 1000, *opt.FileUID)
+
+	s3Opt := &s3.Options{BucketName: "bucket"}
+	assert.NotSame(t, s3Opt, s3Provider{}.CloneConfig(s3Opt))
+	assert.Equal(t, s3Opt, s3Provider{}.CloneConfig(s3Opt))
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultS3Endpoint is the endpoint of a storage URL without one
+const DefaultS3Endpoint = "s3.amazonaws.com"
+
+// s3URLParams are the query parameters of an s3 storage URL, named like the keys of the kopia block
+var s3URLParams = []string{"endpoint", "region", "doNotUseTLS", "doNotVerifyTLS"}
+
+// ParseStorageURL parses a storage given as a URL like s3://bucket/prefix/?endpoint=nyc3.digitaloceanspaces.com
+// into the connection info of the kopia block. The credentials are never part of the URL, they are read from
+// the environment like the ones of the kopia block.
+func ParseStorageURL(spec string) (*blob.ConnectionInfo, error) {
+	storageURL, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage URL: %w", err)
+	}
+	if storageURL.User != nil {
+		return nil, fmt.Errorf("the storage URL cannot hold credentials, set %s and %s instead", EnvAccessId, EnvAccessSecret)
+	}
+
+	switch storageURL.Scheme {
+	case "s3":
+		return parseS3URL(storageURL)
+	case "b2":
+		return parseB2URL(storageURL)
+	case "file":
+		return parseFileURL(storageURL)
+	case "":
+		return nil, fmt.Errorf("storage URL %s has no scheme, e.g. s3://bucket/prefix/", spec)
+	default:
+		return nil, fmt.Errorf("the %s storage is not supported, only s3, b2 and file are", storageURL.Scheme)
+	}
+}
+
+// S3StorageURL returns the storage URL of the s3 bucket, e.g. s3://bucket/prefix/?endpoint=nyc3.digitaloceanspaces.com
+func S3StorageURL(bucket string, prefix string, endpoint string) string {
+	storageURL := &url.URL{Scheme: "s3", Host: bucket, Path: "/" + strings.TrimPrefix(prefix, "/")}
+	if endpoint != "" {
+		storageURL.RawQuery = url.Values{"endpoint": {endpoint}}.Encode()
+	}
+	return storageURL.String()
+}
+
+func parseFileURL(storageURL *url.URL) (*blob.ConnectionInfo, error) {
+	if storageURL.Host != "" && storageURL.Host != "localhost" {
+		return nil, errors.New("the file storage URL has a host, mount the drive and use its path, e.g. file:///mnt/assets")
+	}
+	if storageURL.RawQuery != "" {
+		return nil, errors.New("the file storage URL has no parameters")
+	}
+	path := storageURL.Path
+	if path == "" {
+		return nil, errors.New("the file storage URL has no path, e.g. file:///mnt/assets")
+	}
+	// The drive of a windows path follows the slash of the URL, e.g. file:///Z:/assets
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{
+		Path: filepath.FromSlash(path),
+	}}, nil
+}
+
+func parseB2URL(storageURL *url.URL) (*blob.ConnectionInfo, error) {
+	if storageURL.Host == "" {
+		return nil, errors.New("the b2 storage URL has no bucket, e.g. b2://bucket/prefix/")
+	}
+	if storageURL.RawQuery != "" {
+		return nil, errors.New("the b2 storage URL has no parameters")
+	}
+	return &blob.ConnectionInfo{Type: "b2", Config: &b2.Options{
+		BucketName: storageURL.Host,
+		Prefix:     strings.TrimPrefix(storageURL.Path, "/"),
+	}}, nil
+}
+
+func parseS3URL(storageURL *url.URL) (*blob.ConnectionInfo, error) {
+	if storageURL.Host == "" {
+		return nil, errors.New("the s3 storage URL has no bucket, e.g. s3://bucket/prefix/")
+	}
+
+	query := storageURL.Query()
+	for param := range query {
+		if !slices.Contains(s3URLParams, param) {
+			return nil, fmt.Errorf("unknown parameter %s of the s3 storage URL, expected one of %s", param, strings.Join(s3URLParams, ", "))
+		}
+	}
+
+	options := &s3.Options{
+		BucketName: storageURL.Host,
+		Prefix:     strings.TrimPrefix(storageURL.Path, "/"),
+		Endpoint:   query.Get("endpoint"),
+		Region:     query.Get("region"),
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = DefaultS3Endpoint
+	}
+	var err error
+	if options.DoNotUseTLS, err = parseURLBool(query, "doNotUseTLS"); err != nil {
+		return nil, err
+	}
+	if options.DoNotVerifyTLS, err = parseURLBool(query, "doNotVerifyTLS"); err != nil {
+		return nil, err
+	}
+	return &blob.ConnectionInfo{Type: "s3", Config: options}, nil
+}
+
+func parseURLBool(query url.Values, param string) (bool, error) {
+	if !query.Has(param) {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(query.Get(param))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s of the storage URL: %w", param, err)
+	}
+	return value, nil
+}
+
+// StorageType returns the type of the storage of the kopia block or the storage URL
+func (c *Config) StorageType() string {
+	if c.Kopia != nil && c.Kopia.Storage != nil {
+		return c.Kopia.Storage.Type
+	}
+	if storageURL, err := url.Parse(c.Storage); err == nil {
+		if storageURL.Scheme == "file" {
+			return "filesystem"
+		}
+		return storageURL.Scheme
+	}
+	return ""
+}
+
+// ResolveStorageURL replaces the storage of the kopia block with the one of the storage URL, the one of the
+// .gasset file unless another one is given. Without a kopia block, the client options default to the ones of the machine.
+func (c *Config) ResolveStorageURL(ctx context.Context, spec string) error {
+	if spec == "" {
+		spec = c.Storage
+	} else if c.Kopia != nil && c.Kopia.Storage != nil {
+		// A storage given on the command line overrides the one of the .gasset file
+		c.Kopia.Storage = nil
+	}
+	if spec == "" {
+		return nil
+	}
+	if c.Kopia != nil && c.Kopia.Storage != nil {
+		return errors.New("the .gasset file configures the storage both as a URL and in the kopia block, remove one of them")
+	}
+
+	connectionInfo, err := ParseStorageURL(spec)
+	if err != nil {
+		return err
+	}
+	if c.Kopia == nil {
+		c.Kopia = &repo.LocalConfig{ClientOptions: repo.ClientOptions{}.ApplyDefaults(ctx, "git-gasset")}
+	}
+	c.Kopia.Storage = connectionInfo
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/b2"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseStorageURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    *blob.ConnectionInfo
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Parse an s3 URL with an endpoint",
+			spec: "s3://bucket-name/prefix/?endpoint=nyc3.digitaloceanspaces.com&region=nyc3",
+			want: &blob.ConnectionInfo{Type: "s3", Config: &s3.Options{
+				BucketName: "bucket-name",
+				Prefix:     "prefix/",
+				Endpoint:   "nyc3.digitaloceanspaces.com",
+				Region:     "nyc3",
+			}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Default to the AWS endpoint",
+			spec: "s3://bucket-name?doNotUseTLS=true&doNotVerifyTLS=1",
+			want: &blob.ConnectionInfo{Type: "s3", Config: &s3.Options{
+				BucketName:     "bucket-name",
+				Endpoint:       DefaultS3Endpoint,
+				DoNotUseTLS:    true,
+				DoNotVerifyTLS: true,
+			}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Parse a b2 URL",
+			spec: "b2://bucket-name/prefix/",
+			want: &blob.ConnectionInfo{Type: "b2", Config: &b2.Options{
+				BucketName: "bucket-name",
+				Prefix:     "prefix/",
+			}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Reject parameters of a b2 URL",
+			spec:    "b2://bucket-name/?endpoint=example.com",
+			wantErr: assert.Error,
+		},
+		{
+			name: "Parse a file URL",
+			spec: "file:///mnt/nas/assets",
+			want: &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{
+				Path: filepath.FromSlash("/mnt/nas/assets"),
+			}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Parse a file URL of a windows drive",
+			spec: "file:///Z:/assets",
+			want: &blob.ConnectionInfo{Type: "filesystem", Config: &filesystem.Options{
+				Path: filepath.FromSlash("Z:/assets"),
+			}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Reject a file URL with a host",
+			spec:    "file://nas/assets",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject credentials in the URL",
+			spec:    "s3://id:secret@bucket-name/prefix/",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject an unknown parameter",
+			spec:    "s3://bucket-name/?accessKeyID=id",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject an unsupported storage",
+			spec:    "gcs://bucket-name/prefix/",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Reject a URL without a bucket",
+			spec:    "s3:///prefix/",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStorageURL(tt.spec)
+			if !tt.wantErr(t, err, "ParseStorageURL(%v)", tt.spec) || err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	assert.Equal(t, "s3://bucket-name/", S3StorageURL("bucket-name", "", ""))
+	assert.Equal(t, "s3://bucket-name/prefix/?endpoint=nyc3.digitaloceanspaces.com", S3StorageURL("bucket-name", "/prefix/", "nyc3.digitaloceanspaces.com"))
+
+	got, err := ParseStorageURL(S3StorageURL("bucket-name", "my assets/", "localhost:9000"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &s3.Options{BucketName: "bucket-name", Prefix: "my assets/", Endpoint: "localhost:9000"}, got.Config)
+	}
+}
+
+func TestResolveStorageURL(t *testing.T) {
+	ctx := context.Background()
+
+	config := &Config{Storage: "s3://bucket-name/prefix/"}
+	assert.NoError(t, config.ResolveStorageURL(ctx, ""))
+	assert.Equal(t, "s3", config.StorageType())
+	assert.Equal(t, "bucket-name", config.Kopia.Storage.Config.(*s3.Options).BucketName)
+	assert.NotEmpty(t, config.Kopia.ClientOptions.Hostname, "client options without a kopia block")
+
+	kopiaBlock := func() *repo.LocalConfig {
+		return &repo.LocalConfig{
+			Storage:       &blob.ConnectionInfo{Type: "s3", Config: &s3.Options{BucketName: "kopia-bucket"}},
+			ClientOptions: repo.ClientOptions{Hostname: "host-pc"},
+		}
+	}
+
+	config = &Config{Kopia: kopiaBlock(), Storage: "s3://bucket-name/"}
+	assert.Error(t, config.ResolveStorageURL(ctx, ""), "ResolveStorageURL() with both a URL and a kopia block")
+
+	// The storage given on the command line overrides the kopia block but keeps its client options
+	config = &Config{Kopia: kopiaBlock()}
+	assert.NoError(t, config.ResolveStorageURL(ctx, "s3://other-bucket/"))
+	assert.Equal(t, "other-bucket", config.Kopia.Storage.Config.(*s3.Options).BucketName)
+	assert.Equal(t, "host-pc", config.Kopia.ClientOptions.Hostname)
+
+	config = &Config{Kopia: kopiaBlock()}
+	assert.NoError(t, config.ResolveStorageURL(ctx, ""))
+	assert.Equal(t, "kopia-bucket", config.Kopia.Storage.Config.(*s3.Options).BucketName)
+}
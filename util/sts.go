@@ -0,0 +1,344 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultSessionDuration is the duration of the temporary credentials of a role without sessionDuration
+	DefaultSessionDuration = time.Hour
+	// assumeRoleRefreshWindow is how long before they expire the temporary credentials are replaced
+	assumeRoleRefreshWindow = 5 * time.Minute
+	assumeRoleSessionName   = "git-gasset"
+)
+
+// AssumeRoleOptions are the keys of the s3 config of the kopia block, next to the ones of kopia, to access the
+// bucket with the temporary credentials of a role. The credentials of the .env file are only used to assume it.
+type AssumeRoleOptions struct {
+	RoleARN         string `json:"roleArn"`
+	ExternalID      string `json:"externalId,omitempty"`
+	SessionDuration string `json:"sessionDuration,omitempty"`
+	STSEndpoint     string `json:"stsEndpoint,omitempty"`
+}
+
+// Duration returns the session duration, which AWS allows between 15 minutes and 12 hours
+func (a *AssumeRoleOptions) Duration() (time.Duration, error) {
+	if a.SessionDuration == "" {
+		return DefaultSessionDuration, nil
+	}
+	duration, err := time.ParseDuration(a.SessionDuration)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sessionDuration: %w", err)
+	}
+	if duration < 15*time.Minute || duration > 12*time.Hour {
+		return 0, fmt.Errorf("sessionDuration %s is not between 15m and 12h", a.SessionDuration)
+	}
+	return duration, nil
+}
+
+// Endpoint returns the STS endpoint, which defaults to the one of the region of the bucket on AWS
+func (a *AssumeRoleOptions) Endpoint(region string) string {
+	if a.STSEndpoint != "" {
+		return a.STSEndpoint
+	}
+	if region == "" {
+		return "https://sts.amazonaws.com"
+	}
+	return "https://sts." + region + ".amazonaws.com"
+}
+
+// TemporaryCredentials are the credentials of an assumed role
+type TemporaryCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// AssumeRole calls STS to assume the role with the credentials of the s3 options
+func AssumeRole(ctx context.Context, opt *s3.Options, assumeRole *AssumeRoleOptions) (*TemporaryCredentials, error) {
+	if opt.AccessKeyID == "" || opt.SecretAccessKey == "" {
+		return nil, fmt.Errorf("assuming the role %s needs the credentials of %s and %s", assumeRole.RoleARN, EnvAccessId, EnvAccessSecret)
+	}
+	duration, err := assumeRole.Duration()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("Action", "AssumeRole")
+	form.Set("Version", credentials.STSVersion)
+	form.Set("RoleArn", assumeRole.RoleARN)
+	form.Set("RoleSessionName", assumeRoleSessionName)
+	form.Set("DurationSeconds", strconv.Itoa(int(duration.Seconds())))
+	if assumeRole.ExternalID != "" {
+		form.Set("ExternalId", assumeRole.ExternalID)
+	}
+	body := form.Encode()
+	hash := sha256.Sum256([]byte(body))
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(assumeRole.Endpoint(opt.Region), "/")+"/", strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(hash[:]))
+	location := opt.Region
+	if location == "" {
+		location = "us-east-1"
+	}
+	request = signer.SignV4STS(*request, opt.AccessKeyID, opt.SecretAccessKey, location)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("could not assume the role %s: %w", assumeRole.RoleARN, err)
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		var errorResponse credentials.ErrorResponse
+		if xml.Unmarshal(responseBody, &errorResponse) == nil && errorResponse.STSError.Code != "" {
+			return nil, fmt.Errorf("could not assume the role %s: %s: %s", assumeRole.RoleARN, errorResponse.STSError.Code, errorResponse.STSError.Message)
+		}
+		return nil, fmt.Errorf("could not assume the role %s: %s", assumeRole.RoleARN, response.Status)
+	}
+
+	var assumed credentials.AssumeRoleResponse
+	if err := xml.Unmarshal(responseBody, &assumed); err != nil {
+		return nil, fmt.Errorf("invalid response of STS: %w", err)
+	}
+	result := assumed.Result.Credentials
+	if result.AccessKey == "" || result.SessionToken == "" {
+		return nil, errors.New("the response of STS has no credentials")
+	}
+	return &TemporaryCredentials{
+		AccessKeyID:     result.AccessKey,
+		SecretAccessKey: result.SecretKey,
+		SessionToken:    result.SessionToken,
+		Expiration:      result.Expiration,
+	}, nil
+}
+
+var activeAssumeRole atomic.Pointer[AssumeRoleOptions]
+
+// EnableAssumeRole makes every s3 storage opened from now on assume the role, including the ones kopia opens from its config
+func EnableAssumeRole(assumeRole *AssumeRoleOptions) {
+	registerS3Storage()
+	activeAssumeRole.Store(assumeRole)
+}
+
+// DisableAssumeRole makes the s3 storages opened from now on use the credentials of their options
+func DisableAssumeRole() {
+	activeAssumeRole.Store(nil)
+}
+
+// newAssumeRoleStorage opens the s3 storage with the temporary credentials of the role
+func newAssumeRoleStorage(ctx context.Context, opt *s3.Options, assumeRole *AssumeRoleOptions, isCreate bool) (blob.Storage, error) {
+	st := &assumeRoleStorage{base: *opt, assumeRole: assumeRole, isCreate: isCreate}
+	if _, err := st.current(ctx); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// assumeRoleStorage reopens the s3 storage with new temporary credentials before the ones it has expire, so that
+// long uploads outlive the session. Its connection info has the credentials of the .env file and not the
+// temporary ones, which kopia would otherwise persist in its config.
+type assumeRoleStorage struct {
+	base       s3.Options
+	assumeRole *AssumeRoleOptions
+	isCreate   bool
+
+	mu      sync.Mutex
+	storage blob.Storage
+	expires time.Time
+}
+
+func (s *assumeRoleStorage) current(ctx context.Context) (blob.Storage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.storage != nil && time.Until(s.expires) > assumeRoleRefreshWindow {
+		return s.storage, nil
+	}
+
+	temporary, err := AssumeRole(ctx, &s.base, s.assumeRole)
+	if err != nil {
+		return nil, err
+	}
+	opt := s.base
+	opt.AccessKeyID = temporary.AccessKeyID
+	opt.SecretAccessKey = temporary.SecretAccessKey
+	opt.SessionToken = temporary.SessionToken
+	st, err := s3.New(ctx, &opt, s.isCreate)
+	if err != nil {
+		return nil, err
+	}
+	// The previous storage is left to the requests still using it, an s3 storage holds nothing to release
+	s.storage = st
+	s.expires = temporary.Expiration
+	return st, nil
+}
+
+func (s *assumeRoleStorage) GetCapacity(ctx context.Context) (blob.Capacity, error) {
+	st, err := s.current(ctx)
+	if err != nil {
+		return blob.Capacity{}, err
+	}
+	return st.GetCapacity(ctx)
+}
+
+func (s *assumeRoleStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	st, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	return st.GetBlob(ctx, id, offset, length, output)
+}
+
+func (s *assumeRoleStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	st, err := s.current(ctx)
+	if err != nil {
+		return blob.Metadata{}, err
+	}
+	return st.GetMetadata(ctx, id)
+}
+
+func (s *assumeRoleStorage) ListBlobs(ctx context.Context, prefix blob.ID, cb func(bm blob.Metadata) error) error {
+	st, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	return st.ListBlobs(ctx, prefix, cb)
+}
+
+func (s *assumeRoleStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	st, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	return st.PutBlob(ctx, id, data, opts)
+}
+
+func (s *assumeRoleStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	st, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	return st.DeleteBlob(ctx, id)
+}
+
+func (s *assumeRoleStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, opts blob.ExtendOptions) error {
+	st, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	return st.ExtendBlobRetention(ctx, id, opts)
+}
+
+func (s *assumeRoleStorage) FlushCaches(ctx context.Context) error {
+	st, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	return st.FlushCaches(ctx)
+}
+
+func (s *assumeRoleStorage) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.storage.Close(ctx)
+}
+
+func (s *assumeRoleStorage) ConnectionInfo() blob.ConnectionInfo {
+	opt := s.base
+	return blob.ConnectionInfo{Type: "s3", Config: &opt}
+}
+
+func (s *assumeRoleStorage) DisplayName() string {
+	return fmt.Sprintf("S3: %v %v as %v", s.base.Endpoint, s.base.BucketName, s.assumeRole.RoleARN)
+}
+
+func (s *assumeRoleStorage) IsReadOnly() bool {
+	return false
+}
+
+// assumeRoleProvider provides the temporary credentials of the role to a minio client, assuming it again
+// before they expire
+type assumeRoleProvider struct {
+	opt        *s3.Options
+	assumeRole *AssumeRoleOptions
+	expires    time.Time
+}
+
+func (p *assumeRoleProvider) Retrieve() (credentials.Value, error) {
+	temporary, err := AssumeRole(context.Background(), p.opt, p.assumeRole)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	p.expires = temporary.Expiration
+	return credentials.Value{
+		AccessKeyID:     temporary.AccessKeyID,
+		SecretAccessKey: temporary.SecretAccessKey,
+		SessionToken:    temporary.SessionToken,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+func (p *assumeRoleProvider) IsExpired() bool {
+	return time.Until(p.expires) <= assumeRoleRefreshWindow
+}
+
+// decodeAssumeRole returns the assume role options of the s3 config of the kopia block of the JSON config
+func decodeAssumeRole(data []byte) (*AssumeRoleOptions, error) {
+	assumeRole := &AssumeRoleOptions{}
+	storageType, err := decodeStorageConfigKeys(data, assumeRole)
+	if err != nil || *assumeRole == (AssumeRoleOptions{}) {
+		return nil, err
+	}
+	if storageType != "s3" {
+		return nil, fmt.Errorf("roleArn only applies to the s3 storage, not to the %s storage", storageType)
+	}
+	if assumeRole.RoleARN == "" {
+		return nil, errors.New("the s3 storage has externalId, sessionDuration or stsEndpoint without roleArn")
+	}
+	if _, err := assumeRole.Duration(); err != nil {
+		return nil, err
+	}
+	return assumeRole, nil
+}
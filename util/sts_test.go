@@ -0,0 +1,159 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSTSServer serves AssumeRole with credentials expiring after the duration and answers every s3 request with
+// not found. It counts the roles assumed.
+func newSTSServer(t *testing.T, expiresIn time.Duration, assumed *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("Action") != "AssumeRole" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			return
+		}
+		if r.PostForm.Get("RoleArn") != "arn:aws:iam::123456789012:role/assets" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=accessid/") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><Error><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`)
+			return
+		}
+		n := assumed.Add(1)
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials>`+
+			`<AccessKeyId>ASIA%d</AccessKeyId><SecretAccessKey>temporary</SecretAccessKey><SessionToken>token-%s-%s</SessionToken>`+
+			`<Expiration>%s</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`,
+			n, r.PostForm.Get("ExternalId"), r.PostForm.Get("DurationSeconds"), time.Now().Add(expiresIn).UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAssumeRole(t *testing.T) {
+	ctx := context.Background()
+	var assumed atomic.Int32
+	server := newSTSServer(t, time.Hour, &assumed)
+	opt := &s3.Options{AccessKeyID: "accessid", SecretAccessKey: "secret", Region: "eu-west-1"}
+
+	temporary, err := AssumeRole(ctx, opt, &AssumeRoleOptions{RoleARN: "arn:aws:iam::123456789012:role/assets", ExternalID: "gasset", SessionDuration: "2h", STSEndpoint: server.URL})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ASIA1", temporary.AccessKeyID)
+		assert.Equal(t, "token-gasset-7200", temporary.SessionToken)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), temporary.Expiration, time.Minute)
+	}
+
+	_, err = AssumeRole(ctx, opt, &AssumeRoleOptions{RoleARN: "arn:aws:iam::123456789012:role/other", STSEndpoint: server.URL})
+	assert.ErrorContains(t, err, "AccessDenied")
+
+	_, err = AssumeRole(ctx, &s3.Options{}, &AssumeRoleOptions{RoleARN: "arn:aws:iam::123456789012:role/assets", STSEndpoint: server.URL})
+	assert.Error(t, err, "AssumeRole() without credentials")
+
+	_, err = AssumeRole(ctx, opt, &AssumeRoleOptions{RoleARN: "arn:aws:iam::123456789012:role/assets", SessionDuration: "13h", STSEndpoint: server.URL})
+	assert.Error(t, err, "AssumeRole() for longer than AWS allows")
+
+	assert.Equal(t, "https://sts.eu-west-1.amazonaws.com", (&AssumeRoleOptions{}).Endpoint("eu-west-1"))
+}
+
+func TestAssumeRoleStorage(t *testing.T) {
+	ctx := context.Background()
+	var assumed atomic.Int32
+	server := newSTSServer(t, time.Minute, &assumed)
+	opt := &s3.Options{
+		BucketName:      "bucket",
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		DoNotUseTLS:     true,
+		Region:          "us-east-1",
+		AccessKeyID:     "accessid",
+		SecretAccessKey: "secret",
+	}
+
+	EnableAssumeRole(&AssumeRoleOptions{RoleARN: "arn:aws:iam::123456789012:role/assets", STSEndpoint: server.URL})
+	defer DisableAssumeRole()
+
+	st, err := NewS3Storage(ctx, opt, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int32(1), assumed.Load())
+
+	// The credentials expire within the refresh window so the role is assumed again before the request
+	_, err = st.GetMetadata(ctx, "missing")
+	assert.ErrorIs(t, err, blob.ErrBlobNotFound)
+	assert.Equal(t, int32(2), assumed.Load())
+
+	// Kopia persists the connection info, which has the credentials of the .env file
+	connectionInfo := st.ConnectionInfo()
+	assert.Equal(t, "accessid", connectionInfo.Config.(*s3.Options).AccessKeyID)
+	assert.Empty(t, connectionInfo.Config.(*s3.Options).SessionToken)
+
+	DisableAssumeRole()
+	st, err = NewS3Storage(ctx, opt, false)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, st.DisplayName(), "role")
+	}
+	assert.Equal(t, int32(2), assumed.Load())
+}
+
+func TestAssumeRoleConfig(t *testing.T) {
+	original := []byte(`{
+  "kopia": {
+    "storage": {
+      "type": "s3",
+      "config": {
+        "bucket": "bucket",
+        "endpoint": "s3.amazonaws.com",
+        "roleArn": "arn:aws:iam::123456789012:role/assets",
+        "sessionDuration": "2h"
+      }
+    }
+  },
+  "dirs": ["./assets"]
+}`)
+	config, err := decodeConfig(original, ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &AssumeRoleOptions{RoleARN: "arn:aws:iam::123456789012:role/assets", SessionDuration: "2h"}, config.AssumeRole)
+
+	// The keys kopia does not know are written back to its s3 config
+	config.GassetId = "0000000000"
+	encoded, err := encodeConfig(config, original, ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+	decoded, err := decodeConfig(encoded, ConfigFormatJSON)
+	if assert.NoError(t, err) {
+		assert.Equal(t, config.AssumeRole, decoded.AssumeRole)
+		assert.Equal(t, "0000000000", decoded.GassetId)
+	}
+
+	_, err = decodeConfig([]byte(`{"kopia": {"storage": {"type": "b2", "config": {"bucket": "b", "roleArn": "arn"}}}, "dirs": []}`), ConfigFormatJSON)
+	assert.Error(t, err, "decodeConfig() of a role of a b2 storage")
+	_, err = decodeConfig([]byte(`{"kopia": {"storage": {"type": "s3", "config": {"bucket": "b", "externalId": "id"}}}, "dirs": []}`), ConfigFormatJSON)
+	assert.Error(t, err, "decodeConfig() of an external id without a role")
+}
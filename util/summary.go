@@ -0,0 +1,179 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Summary collects the outcome of a command for the single line logged when it ends, so that
+// the logs of CI runs can be interpreted without reading every line. A nil summary ignores everything.
+type Summary struct {
+	command string
+	started time.Time
+
+	mu       sync.Mutex
+	counts   []summaryCount
+	bytes    int64
+	warnings int
+	failures int
+}
+
+type summaryCount struct {
+	name  string
+	count int
+}
+
+func NewSummary(command string, started time.Time) *Summary {
+	return &Summary{command: command, started: started}
+}
+
+// Add counts items of the outcome, e.g. the saved snapshots, in the order they were first added
+func (s *Summary) Add(name string, count int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.counts {
+		if s.counts[i].name == name {
+			s.counts[i].count += count
+			return
+		}
+	}
+	s.counts = append(s.counts, summaryCount{name: name, count: count})
+}
+
+func (s *Summary) AddBytes(bytes int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes += bytes
+}
+
+// AddFailures counts the items that failed while the command went on with the others
+func (s *Summary) AddFailures(count int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures += count
+}
+
+func (s *Summary) HasFailures() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failures > 0
+}
+
+// Line returns the summary of the command which ended with err at now
+func (s *Summary) Line(err error, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status(err)
+	var details []string
+	for _, c := range s.counts {
+		if c.count > 0 {
+			details = append(details, fmt.Sprintf("%d %s", c.count, c.name))
+		}
+	}
+	if s.failures > 0 {
+		details = append(details, fmt.Sprintf("%d failed", s.failures))
+	}
+	if s.bytes > 0 {
+		details = append(details, FormatBytes(s.bytes))
+	}
+	details = append(details, now.Sub(s.started).Round(time.Millisecond).String())
+	if s.warnings == 1 {
+		details = append(details, "1 warning")
+	} else if s.warnings > 1 {
+		details = append(details, fmt.Sprintf("%d warnings", s.warnings))
+	}
+
+	return fmt.Sprintf("%s %s: %s", s.command, status, strings.Join(details, ", "))
+}
+
+// Event returns the summary of the command which ended with err at now as the result event of the --json mode
+func (s *Summary) Event(err error, now time.Time) Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := Event{
+		Operation: "result",
+		Time:      now,
+		Command:   s.command,
+		Status:    s.status(err),
+		Bytes:     s.bytes,
+		Failures:  s.failures,
+		Warnings:  s.warnings,
+		Duration:  now.Sub(s.started),
+	}
+	for _, c := range s.counts {
+		if c.count > 0 {
+			if event.Counts == nil {
+				event.Counts = map[string]int{}
+			}
+			event.Counts[c.name] = c.count
+		}
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// status returns the status of the command which ended with err, s.mu must be held
+func (s *Summary) status(err error) string {
+	if s.failures > 0 {
+		return "partially failed"
+	} else if err != nil {
+		return "failed"
+	}
+	return "succeeded"
+}
+
+// LogWriter returns a writer for the log output which counts the logged warnings
+func (s *Summary) LogWriter(w io.Writer) io.Writer {
+	return &warningCounter{w: w, summary: s}
+}
+
+type warningCounter struct {
+	w       io.Writer
+	summary *Summary
+}
+
+func (c *warningCounter) Write(p []byte) (int, error) {
+	if warnings := bytes.Count(p, []byte("Warning: ")); warnings > 0 {
+		c.summary.mu.Lock()
+		c.summary.warnings += warnings
+		c.summary.mu.Unlock()
+	}
+	return c.w.Write(p)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSummaryLine(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := started.Add(1500 * time.Millisecond)
+
+	tests := []struct {
+		name   string
+		record func(s *Summary)
+		err    error
+		want   string
+	}{
+		{
+			name:   "Succeeded without counts",
+			record: func(s *Summary) {},
+			want:   "snap succeeded: 1.5s",
+		},
+		{
+			name: "Succeeded with counts in the order they were added",
+			record: func(s *Summary) {
+				s.Add("snapshots", 1)
+				s.Add("unchanged", 2)
+				s.Add("snapshots", 1)
+				s.Add("skipped", 0)
+				s.AddBytes(2048)
+			},
+			want: "snap succeeded: 2 snapshots, 2 unchanged, 2.0 KiB, 1.5s",
+		},
+		{
+			name: "Partially failed",
+			record: func(s *Summary) {
+				s.Add("snapshots", 1)
+				s.AddFailures(1)
+			},
+			err:  errors.New("assets: access denied"),
+			want: "snap partially failed: 1 snapshots, 1 failed, 1.5s",
+		},
+		{
+			name:   "Failed",
+			record: func(s *Summary) {},
+			err:    errors.New("access denied"),
+			want:   "snap failed: 1.5s",
+		},
+		{
+			name: "Count the logged warnings",
+			record: func(s *Summary) {
+				logger := log.New(s.LogWriter(io.Discard), "", 0)
+				logger.Println("Warning: the clock of the storage is ahead")
+				logger.Println("snap called")
+				logger.Println("Warning: nothing to snapshot")
+			},
+			want: "snap succeeded: 1.5s, 2 warnings",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSummary("snap", started)
+			tt.record(s)
+			assert.Equal(t, tt.want, s.Line(tt.err, now))
+		})
+	}
+}
+
+func TestSummaryHasFailures(t *testing.T) {
+	s := NewSummary("snap", time.Now())
+	s.Add("snapshots", 1)
+	assert.False(t, s.HasFailures())
+	s.AddFailures(1)
+	assert.True(t, s.HasFailures())
+}
+
+func TestSummaryNil(t *testing.T) {
+	var s *Summary
+	s.Add("snapshots", 1)
+	s.AddBytes(1)
+	s.AddFailures(1)
+	assert.False(t, s.HasFailures())
+}
+
+func TestSummaryEvent(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := started.Add(1500 * time.Millisecond)
+
+	summary := NewSummary("snap", started)
+	summary.Add("snapshots", 1)
+	summary.Add("unchanged", 0)
+	summary.AddBytes(2048)
+	summary.AddFailures(1)
+	assert.Equal(t, Event{
+		Operation: "result",
+		Time:      now,
+		Command:   "snap",
+		Status:    "partially failed",
+		Bytes:     2048,
+		Counts:    map[string]int{"snapshots": 1},
+		Failures:  1,
+		Duration:  1500 * time.Millisecond,
+		Error:     "assets: access denied",
+	}, summary.Event(errors.New("assets: access denied"), now))
+}
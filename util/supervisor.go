@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthPath is the path of the health endpoint of the long-running modes
+const HealthPath = "/healthz"
+
+// DefaultRestartBackoff restarts a crashed worker quickly at first and every few minutes while it keeps crashing
+var DefaultRestartBackoff = Backoff{Initial: time.Second, Max: 5 * time.Minute}
+
+// WorkerHealth is the state of a supervised worker as reported by the health endpoint
+type WorkerHealth struct {
+	Name      string    `json:"name"`
+	Running   bool      `json:"running"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"lastError,omitempty"`
+	LastCrash time.Time `json:"lastCrash,omitempty"`
+}
+
+// Supervisor runs the workers of a long-running mode, like the watchers of the asset directories, and restarts
+// them with a backoff when they fail or panic so that gasset keeps running as a background service
+type Supervisor struct {
+	Backoff  Backoff
+	RandIntn func(n int) int
+
+	mu      sync.Mutex
+	workers map[string]*WorkerHealth
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		Backoff:  DefaultRestartBackoff,
+		RandIntn: rand.Intn,
+		workers:  map[string]*WorkerHealth{},
+	}
+}
+
+// Run runs the worker until it returns without an error or ctx is done, restarting it after every crash.
+// The backoff starts over once the worker ran for longer than the maximum delay.
+func (s *Supervisor) Run(ctx context.Context, name string, worker func(ctx context.Context) error) error {
+	attempt := 0
+	for {
+		s.update(name, func(h *WorkerHealth) { h.Running = true })
+		started := time.Now()
+		err := runRecovered(ctx, worker)
+		if err == nil || ctx.Err() != nil {
+			s.update(name, func(h *WorkerHealth) { h.Running = false })
+			return err
+		}
+
+		if time.Since(started) > s.Backoff.Max {
+			attempt = 0
+		}
+		delay := s.Backoff.Delay(attempt, s.RandIntn)
+		attempt++
+		s.update(name, func(h *WorkerHealth) {
+			h.Running = false
+			h.Restarts++
+			h.LastError = err.Error()
+			h.LastCrash = time.Now()
+		})
+		log.Printf("Warning: %s crashed, restarting in %s: %v", name, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// runRecovered turns a panic of the worker into an error so that it is restarted like any other crash
+func runRecovered(ctx context.Context, worker func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Warning: panic: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return worker(ctx)
+}
+
+func (s *Supervisor) update(name string, fn func(h *WorkerHealth)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workers == nil {
+		s.workers = map[string]*WorkerHealth{}
+	}
+	health, ok := s.workers[name]
+	if !ok {
+		health = &WorkerHealth{Name: name}
+		s.workers[name] = health
+	}
+	fn(health)
+}
+
+// Health returns the state of the workers sorted by name
+func (s *Supervisor) Health() []WorkerHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	health := make([]WorkerHealth, 0, len(s.workers))
+	for _, worker := range s.workers {
+		health = append(health, *worker)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].Name < health[j].Name
+	})
+	return health
+}
+
+// ServeHTTP implements the health endpoint. It answers 503 while any of the workers is waiting to be restarted.
+func (s *Supervisor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	health := s.Health()
+	status := http.StatusOK
+	for _, worker := range health {
+		if !worker.Running {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]any{"workers": health}); err != nil {
+		log.Printf("Warning: writing the health response failed: %v", err)
+	}
+}
+
+// ServeHealth serves the health endpoint of the supervisor and the other endpoints of the mode, e.g. its metrics,
+// on addr until ctx is done
+func ServeHealth(ctx context.Context, addr string, s *Supervisor, endpoints map[string]http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(HealthPath, s)
+	for path, handler := range endpoints {
+		mux.Handle(path, handler)
+	}
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
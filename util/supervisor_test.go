@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSupervisorRun(t *testing.T) {
+	supervisor := NewSupervisor()
+	supervisor.Backoff = Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond}
+
+	runs := 0
+	err := supervisor.Run(context.Background(), "watch ./assets", func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1:
+			return errors.New("watcher failed")
+		case 2:
+			panic("watcher panicked")
+		default:
+			return nil
+		}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, runs)
+
+	health := supervisor.Health()
+	if assert.Len(t, health, 1) {
+		assert.Equal(t, "watch ./assets", health[0].Name)
+		assert.False(t, health[0].Running)
+		assert.Equal(t, 2, health[0].Restarts)
+		assert.Equal(t, "panic: watcher panicked", health[0].LastError)
+	}
+}
+
+func TestSupervisorRunCanceled(t *testing.T) {
+	supervisor := NewSupervisor()
+	supervisor.Backoff = Backoff{Initial: time.Hour, Max: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- supervisor.Run(ctx, "watch", func(ctx context.Context) error {
+			return errors.New("watcher failed")
+		})
+	}()
+
+	assert.Eventually(t, func() bool {
+		health := supervisor.Health()
+		return len(health) == 1 && health[0].Restarts == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestSupervisorServeHTTP(t *testing.T) {
+	supervisor := NewSupervisor()
+	supervisor.update("a", func(h *WorkerHealth) { h.Running = true })
+
+	recorder := httptest.NewRecorder()
+	supervisor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	supervisor.update("b", func(h *WorkerHealth) { h.Restarts = 1 })
+	recorder = httptest.NewRecorder()
+	supervisor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var body struct {
+		Workers []WorkerHealth `json:"workers"`
+	}
+	if assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&body)) {
+		assert.Equal(t, []WorkerHealth{{Name: "a", Running: true}, {Name: "b", Restarts: 1}}, body.Workers)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ProjectTemplate is the recommended setup of a kind of project, scaffolded by init --template
+type ProjectTemplate struct {
+	Dirs        []string
+	Ignore      []string
+	Compression string
+	// GitIgnore are the entries keeping the assets out of git
+	GitIgnore []string
+}
+
+// commonIgnore are the files created by the operating systems which are never assets
+var commonIgnore = []string{".DS_Store", "Thumbs.db", "desktop.ini"}
+
+var ProjectTemplates = map[string]ProjectTemplate{
+	// The .meta files of Unity have to be in git as the scenes reference the assets by the guids in them
+	"game-unity": {
+		Dirs:        []string{"./Assets/Art", "./Assets/Audio"},
+		Ignore:      append([]string{"*.meta"}, commonIgnore...),
+		Compression: "zstd",
+		GitIgnore:   []string{"/Assets/Art/**", "!/Assets/Art/**/", "!/Assets/Art/**/*.meta", "/Assets/Audio/**", "!/Assets/Audio/**/", "!/Assets/Audio/**/*.meta"},
+	},
+	// The Developers folders of Unreal are personal sandboxes, not shared assets
+	"game-unreal": {
+		Dirs:        []string{"./Content", "./RawContent"},
+		Ignore:      append([]string{"/Developers/"}, commonIgnore...),
+		Compression: "zstd-fastest",
+		GitIgnore:   []string{"/Content/", "/RawContent/"},
+	},
+	// Footage and renders are compressed by their codecs already
+	"film": {
+		Dirs:        []string{"./footage", "./audio", "./renders"},
+		Ignore:      append([]string{"*.tmp", "*.lock"}, commonIgnore...),
+		Compression: "none",
+		GitIgnore:   []string{"/footage/", "/audio/", "/renders/"},
+	},
+}
+
+// ProjectTemplateNames returns the names of the templates sorted
+func ProjectTemplateNames() []string {
+	names := make([]string, 0, len(ProjectTemplates))
+	for name := range ProjectTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProjectTemplate returns the template with the given name
+func GetProjectTemplate(name string) (ProjectTemplate, error) {
+	template, ok := ProjectTemplates[name]
+	if !ok {
+		return ProjectTemplate{}, fmt.Errorf("unknown template %q, expected one of %s", name, strings.Join(ProjectTemplateNames(), ", "))
+	}
+	return template, nil
+}
+
+// ApplyTemplate adds the dirs and ignore rules of the template missing from the config.
+// The compression is only set if the config has none so that an existing choice is kept.
+func (c *Config) ApplyTemplate(template ProjectTemplate) {
+	for _, dir := range template.Dirs {
+		if !slices.Contains(c.Dirs, dir) {
+			c.Dirs = append(c.Dirs, dir)
+		}
+	}
+	for _, rule := range template.Ignore {
+		if !slices.Contains(c.Ignore, rule) {
+			c.Ignore = append(c.Ignore, rule)
+		}
+	}
+	if c.Compression == "" {
+		c.Compression = template.Compression
+	}
+}
+
+func ApplyProjectTemplate(path string, template ProjectTemplate) error {
+	config, err := GetConfig(path)
+	if err != nil {
+		return err
+	}
+
+	config.ApplyTemplate(template)
+	return SaveConfig(path, config)
+}
+
+// AppendGitIgnore adds the entries missing from the .gitignore file in path, creating it if needed.
+// It returns the entries which were added.
+func AppendGitIgnore(path string, entries []string, permissions *PermissionOptions) ([]string, error) {
+	gitIgnorePath := filepath.Join(path, ".gitignore")
+
+	content, err := os.ReadFile(gitIgnorePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var existing []string
+	for _, line := range strings.Split(string(content), "\n") {
+		existing = append(existing, strings.TrimSpace(line))
+	}
+
+	var added []string
+	for _, entry := range entries {
+		if !slices.Contains(existing, entry) && !slices.Contains(added, entry) {
+			added = append(added, entry)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	var builder strings.Builder
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		builder.WriteString("\n")
+	}
+	builder.WriteString("# Assets managed by git-gasset\n")
+	for _, entry := range added {
+		builder.WriteString(entry + "\n")
+	}
+
+	file, err := os.OpenFile(gitIgnorePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, DefaultFileMode)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(builder.String()); err != nil {
+		return nil, err
+	}
+	return added, permissions.ApplyFile(gitIgnorePath)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectTemplates(t *testing.T) {
+	for name, template := range ProjectTemplates {
+		assert.Containsf(t, CompressionNames(), template.Compression, "compression of %s", name)
+		assert.NotEmptyf(t, template.Dirs, "dirs of %s", name)
+	}
+
+	_, err := GetProjectTemplate("game-godot")
+	assert.EqualError(t, err, `unknown template "game-godot", expected one of film, game-unity, game-unreal`)
+}
+
+func TestConfig_ApplyTemplate(t *testing.T) {
+	config := &Config{Dirs: []string{"./Content", "./Docs"}, Compression: "s2-default"}
+	config.ApplyTemplate(ProjectTemplates["game-unreal"])
+
+	assert.Equal(t, []string{"./Content", "./Docs", "./RawContent"}, config.Dirs)
+	assert.Equal(t, ProjectTemplates["game-unreal"].Ignore, config.Ignore)
+	assert.Equal(t, "s2-default", config.Compression)
+}
+
+func TestAppendGitIgnore(t *testing.T) {
+	dir := t.TempDir()
+	gitIgnorePath := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(gitIgnorePath, []byte("/build/\n/footage/"), 0o644); err != nil {
+		t.FailNow()
+	}
+
+	added, err := AppendGitIgnore(dir, []string{"/footage/", "/renders/"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/renders/"}, added)
+
+	added, err = AppendGitIgnore(dir, []string{"/footage/", "/renders/"}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, added)
+
+	content, err := os.ReadFile(gitIgnorePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "/build/\n/footage/\n# Assets managed by git-gasset\n/renders/\n", string(content))
+}
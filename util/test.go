@@ -23,6 +23,7 @@ import (
 	"github.com/kopia/kopia/repo/blob/s3"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/zalando/go-keyring"
 	"os"
 	"path/filepath"
 	"strings"
@@ -81,6 +82,7 @@ func SetupTestOptions(options *OptionsForTest) error {
 				},
 			},
 			GassetId: "0000000000",
+			Dirs:     []string{"./assets"},
 		},
 		Password:       "password",
 		Storage:        StubStorage{},
@@ -100,9 +102,21 @@ func SetupTestOptions(options *OptionsForTest) error {
 		S3New: func(ctx context.Context, opt *s3.Options, create bool) (blob.Storage, error) {
 			return StubStorage{}, nil
 		},
+		S3BucketPolicy: func(ctx context.Context, opt *s3.Options) (string, error) {
+			return "", nil
+		},
+		S3BucketSettings: func(ctx context.Context, opt *s3.Options) (*BucketSettings, error) {
+			return &BucketSettings{}, nil
+		},
+		S3CheckEncryption: func(ctx context.Context, opt *s3.Options, encryption *EncryptionOptions) error {
+			return nil
+		},
 		RepoConnect: func(ctx context.Context, configFile string, st blob.Storage, password string, options *repo.ConnectOptions) error {
 			return nil
 		},
+		RepoConnectAPIServer: func(ctx context.Context, configFile string, si *repo.APIServerInfo, password string, options *repo.ConnectOptions) error {
+			return nil
+		},
 		RepoInitialize: func(ctx context.Context, st blob.Storage, opt *repo.NewRepositoryOptions, password string) error {
 			return nil
 		},
@@ -115,6 +129,15 @@ func SetupTestOptions(options *OptionsForTest) error {
 		PolicySetPolicy: func(ctx context.Context, r repo.RepositoryWriter, si snapshot.SourceInfo, pol *policy.Policy) error {
 			return nil
 		},
+		KeyringGet: func(service string, user string) (string, error) {
+			return "", keyring.ErrNotFound
+		},
+		KeyringSet: func(service string, user string, password string) error {
+			return nil
+		},
+		KeyringDelete: func(service string, user string) error {
+			return nil
+		},
 	}
 
 	options.OptionsWithNoGassetId = options.OptionsWithGassetId.Clone()
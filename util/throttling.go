@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"os"
+	"path/filepath"
+)
+
+// ThrottlingOptions are the throttling limits of the storage per command. A section replaces all the
+// throttlingLimits of the kopia config while the command runs, so an empty one lifts every limit.
+type ThrottlingOptions struct {
+	Snap    *throttling.Limits `json:"snap,omitempty"`
+	Restore *throttling.Limits `json:"restore,omitempty"`
+}
+
+// SnapLimits returns the limits of snap or nil to keep the ones of the kopia config
+func (t *ThrottlingOptions) SnapLimits() *throttling.Limits {
+	if t == nil {
+		return nil
+	}
+	return t.Snap
+}
+
+// RestoreLimits returns the limits of restore or nil to keep the ones of the kopia config
+func (t *ThrottlingOptions) RestoreLimits() *throttling.Limits {
+	if t == nil {
+		return nil
+	}
+	return t.Restore
+}
+
+// KopiaThrottlingLimits returns the limits kopia applies for a config, the throttlingLimits of the config or else the
+// ones stored with the storage
+func KopiaThrottlingLimits(localConfig *repo.LocalConfig) throttling.Limits {
+	if localConfig.Throttling != nil {
+		return *localConfig.Throttling
+	}
+	var limits throttling.Limits
+	if localConfig.Storage == nil {
+		return limits
+	}
+	if storageBytes, err := json.Marshal(localConfig.Storage.Config); err == nil {
+		_ = json.Unmarshal(storageBytes, &limits)
+	}
+	return limits
+}
+
+// OverrideThrottling makes the repositories opened from now on use other throttling limits than the ones of the
+// kopia config. The limits start from section, or the ones of the kopia config if it is nil, and are then adjusted.
+// The kopia config is left untouched, the repository is opened from a copy with the limits which is removed again.
+func (op *Options) OverrideThrottling(section *throttling.Limits, adjust func(limits *throttling.Limits)) {
+	repoOpen := op.RepoOpen
+	op.RepoOpen = func(ctx context.Context, configFile string, password string, options *repo.Options) (repo.Repository, error) {
+		localConfig, err := repo.LoadConfigFromFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		limits := KopiaThrottlingLimits(localConfig)
+		if section != nil {
+			limits = *section
+		}
+		if adjust != nil {
+			adjust(&limits)
+		}
+		localConfig.Throttling = &limits
+
+		configBytes, err := json.Marshal(localConfig)
+		if err != nil {
+			return nil, err
+		}
+		// The copy holds the credentials of the storage, so it stays next to the config with the same permissions
+		tempFile, err := os.CreateTemp(filepath.Dir(configFile), filepath.Base(configFile)+".*.throttled")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tempFile.Name())
+		_, err = tempFile.Write(configBytes)
+		if closeErr := tempFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return repoOpen(ctx, tempFile.Name(), password, options)
+	}
+}
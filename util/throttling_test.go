@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverrideThrottling(t *testing.T) {
+	storageLimits := throttling.Limits{UploadBytesPerSecond: 100, ConcurrentReads: 2}
+	configLimits := &throttling.Limits{UploadBytesPerSecond: 200, ConcurrentWrites: 4}
+
+	tests := []struct {
+		name    string
+		config  *throttling.Limits
+		section *throttling.Limits
+		adjust  func(limits *throttling.Limits)
+		want    throttling.Limits
+	}{
+		{
+			name: "Keep the limits stored with the storage",
+			want: storageLimits,
+		},
+		{
+			name:   "Adjust the limits of the kopia config",
+			config: configLimits,
+			adjust: func(limits *throttling.Limits) { limits.ConcurrentReads = 8 },
+			want:   throttling.Limits{UploadBytesPerSecond: 200, ConcurrentWrites: 4, ConcurrentReads: 8},
+		},
+		{
+			name:    "Replace all the limits with the section",
+			config:  configLimits,
+			section: &throttling.Limits{},
+			want:    throttling.Limits{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "kopia.config")
+			localConfig := &repo.LocalConfig{
+				Storage: &blob.ConnectionInfo{Type: "s3", Config: &s3.Options{BucketName: "assets", Limits: storageLimits}},
+				ClientOptions: repo.ClientOptions{
+					Hostname:   "host",
+					Throttling: tt.config,
+				},
+			}
+			configBytes, err := json.Marshal(localConfig)
+			if err != nil {
+				t.FailNow()
+			}
+			if err := os.WriteFile(configFile, configBytes, 0o600); err != nil {
+				t.FailNow()
+			}
+
+			var opened string
+			var got throttling.Limits
+			op := &Options{
+				RepoOpen: func(ctx context.Context, configFile string, password string, options *repo.Options) (repo.Repository, error) {
+					opened = configFile
+					openedConfig, err := repo.LoadConfigFromFile(configFile)
+					if err != nil {
+						return nil, err
+					}
+					got = KopiaThrottlingLimits(openedConfig)
+					assert.Equal(t, "host", openedConfig.Hostname)
+					return nil, nil
+				},
+			}
+			op.OverrideThrottling(tt.section, tt.adjust)
+
+			_, err = op.RepoOpen(context.Background(), configFile, "", &repo.Options{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NotEqual(t, configFile, opened)
+			assert.NoFileExists(t, opened)
+
+			unchanged, err := os.ReadFile(configFile)
+			assert.NoError(t, err)
+			assert.Equal(t, configBytes, unchanged)
+		})
+	}
+}
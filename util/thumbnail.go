@@ -0,0 +1,304 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kopia/kopia/snapshot"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// TagThumbnailsOf tags the snapshot of the thumbnails of an asset directory with the id of the snapshot they preview
+const TagThumbnailsOf = "tag:thumbnails-of"
+
+// ThumbnailExtension is appended to the path of an image for the path of its thumbnail
+const ThumbnailExtension = ".thumb.png"
+
+// thumbnailSourceSuffix is appended to the path of the source of an asset directory for the source of its thumbnails
+const thumbnailSourceSuffix = "#thumbnails"
+
+const (
+	DefaultThumbnailSize        = 256
+	DefaultThumbnailMaxFileSize = 64 << 20 // 64 MiB
+	// maxThumbnailPixels keeps an image whose small file decodes into a huge bitmap from exhausting the memory
+	maxThumbnailPixels = 100 << 20
+)
+
+// thumbnailExtensions are the image formats thumbnails are generated for
+var thumbnailExtensions = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// ThumbnailOptions turns on the thumbnails of the images of the asset directories, generated on snap
+type ThumbnailOptions struct {
+	// Size is the length of the longest edge of a thumbnail in pixels
+	Size int `json:"size,omitempty"`
+	// MaxFileSize skips the images which are larger, in bytes
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+	// Workers is the number of images decoded at the same time
+	Workers int `json:"workers,omitempty"`
+}
+
+func (t *ThumbnailOptions) size() int {
+	if t == nil || t.Size <= 0 {
+		return DefaultThumbnailSize
+	}
+	return t.Size
+}
+
+func (t *ThumbnailOptions) maxFileSize() int64 {
+	if t == nil || t.MaxFileSize <= 0 {
+		return DefaultThumbnailMaxFileSize
+	}
+	return t.MaxFileSize
+}
+
+func (t *ThumbnailOptions) workers() int {
+	if t == nil {
+		return 1
+	}
+	return max(t.Workers, 1)
+}
+
+// ThumbnailUpdate counts the thumbnails changed by UpdateThumbnails
+type ThumbnailUpdate struct {
+	Generated int
+	Removed   int
+	Skipped   int
+}
+
+// ThumbnailSource returns the source of the thumbnails of the source of an asset directory
+func ThumbnailSource(info snapshot.SourceInfo) snapshot.SourceInfo {
+	info.Path += thumbnailSourceSuffix
+	return info
+}
+
+// IsThumbnailImage tells by its extension whether thumbnails are generated for a file
+func IsThumbnailImage(name string) bool {
+	return slices.Contains(thumbnailExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// GetThumbnailDirectory returns the local directory of the thumbnails of an asset directory, which is snapshotted
+// so that the thumbnails of the unchanged images are neither generated nor uploaded again
+func (op *Options) GetThumbnailDirectory(dirPath string) (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "thumbnails-"+op.Config.GassetId, filepath.Clean(dirPath)), nil
+}
+
+// UpdateThumbnails generates the thumbnails of the images among the snapshotted paths of the asset directory,
+// relative to the root of the git repository with slashes, which are missing or older than the image, and removes
+// the ones of the images which are not snapshotted anymore. The images failing to decode are skipped and returned
+// as the error after the others are done.
+func (op *Options) UpdateThumbnails(ctx context.Context, dirPath string, paths []string) (ThumbnailUpdate, error) {
+	update := ThumbnailUpdate{}
+	thumbnailDir, err := op.GetThumbnailDirectory(dirPath)
+	if err != nil {
+		return update, err
+	}
+	if err := os.MkdirAll(thumbnailDir, 0o700); err != nil {
+		return update, err
+	}
+
+	dir := path.Clean(filepath.ToSlash(dirPath))
+	wanted := map[string]string{}
+	for _, snapshotted := range paths {
+		relativePath := strings.TrimPrefix(snapshotted, dir+"/")
+		if dir == "." {
+			relativePath = snapshotted
+		} else if relativePath == snapshotted {
+			continue
+		}
+		if IsThumbnailImage(relativePath) {
+			wanted[filepath.Join(thumbnailDir, filepath.FromSlash(relativePath))+ThumbnailExtension] = filepath.Join(op.WorkingDirectory, dir, filepath.FromSlash(relativePath))
+		}
+	}
+
+	err = filepath.WalkDir(thumbnailDir, func(thumbnailPath string, entry iofs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if _, ok := wanted[thumbnailPath]; ok {
+			return nil
+		}
+		update.Removed++
+		return os.Remove(thumbnailPath)
+	})
+	if err != nil {
+		return update, err
+	}
+
+	settings := op.Config.Thumbnails
+	var mu sync.Mutex
+	var errs []error
+	jobs := make(chan [2]string)
+	var wg sync.WaitGroup
+	for i := 0; i < settings.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				generated, err := updateThumbnail(job[0], job[1], settings)
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs = append(errs, fmt.Errorf("%s: %w", job[0], err))
+				case generated:
+					update.Generated++
+				default:
+					update.Skipped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	thumbnailPaths := make([]string, 0, len(wanted))
+	for thumbnailPath := range wanted {
+		thumbnailPaths = append(thumbnailPaths, thumbnailPath)
+	}
+	slices.Sort(thumbnailPaths)
+	for _, thumbnailPath := range thumbnailPaths {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- [2]string{wanted[thumbnailPath], thumbnailPath}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return update, ctx.Err()
+	}
+	return update, errors.Join(errs...)
+}
+
+// updateThumbnail generates the thumbnail of an image unless it has the modification time of the image already.
+// It returns whether it was generated, images larger than the limits are skipped.
+func updateThumbnail(imagePath string, thumbnailPath string, settings *ThumbnailOptions) (bool, error) {
+	imageInfo, err := os.Stat(imagePath)
+	if err != nil {
+		return false, err
+	}
+	if thumbnailInfo, err := os.Stat(thumbnailPath); err == nil && thumbnailInfo.ModTime().Equal(imageInfo.ModTime()) {
+		return false, nil
+	}
+	if imageInfo.Size() > settings.maxFileSize() {
+		return false, nil
+	}
+
+	imageFile, err := os.Open(imagePath)
+	if err != nil {
+		return false, err
+	}
+	defer imageFile.Close()
+
+	if err := os.MkdirAll(filepath.Dir(thumbnailPath), 0o700); err != nil {
+		return false, err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(thumbnailPath), filepath.Base(thumbnailPath)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if err := GenerateThumbnail(imageFile, tempFile, settings.size()); err != nil {
+		tempFile.Close()
+		return false, err
+	}
+	if err := tempFile.Close(); err != nil {
+		return false, err
+	}
+	// The thumbnail gets the modification time of the image to tell whether it is up to date
+	if err := os.Chtimes(tempFile.Name(), imageInfo.ModTime(), imageInfo.ModTime()); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tempFile.Name(), thumbnailPath)
+}
+
+// GenerateThumbnail decodes a PNG, JPEG or GIF image and writes it as a PNG scaled down to fit into size x size pixels
+func GenerateThumbnail(r io.Reader, w io.Writer, size int) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return fmt.Errorf("the image of %dx%d pixels is too large for a thumbnail", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, scaleDown(img, size))
+}
+
+// scaleDown averages the pixels of the image into an image whose longest edge is at most size pixels
+func scaleDown(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return src
+	}
+
+	scale := float64(max(width, height)) / float64(size)
+	dstWidth := max(1, int(float64(width)/scale))
+	dstHeight := max(1, int(float64(height)/scale))
+	dst := image.NewRGBA64(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := bounds.Min.Y + (y+1)*height/dstHeight
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := bounds.Min.X + (x+1)*width/dstWidth
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
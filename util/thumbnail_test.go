@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestImage(t *testing.T, imagePath string, width int, height int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	buffer := &bytes.Buffer{}
+	if err := png.Encode(buffer, img); err != nil {
+		t.FailNow()
+	}
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0o755); err != nil {
+		t.FailNow()
+	}
+	if err := os.WriteFile(imagePath, buffer.Bytes(), 0o644); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		want   image.Point
+	}{
+		{name: "Scale a wide image down", width: 600, height: 300, want: image.Pt(256, 128)},
+		{name: "Scale a tall image down", width: 100, height: 1000, want: image.Pt(25, 256)},
+		{name: "Keep a small image", width: 64, height: 32, want: image.Pt(64, 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imagePath := filepath.Join(t.TempDir(), "image.png")
+			writeTestImage(t, imagePath, tt.width, tt.height)
+			imageFile, err := os.Open(imagePath)
+			if err != nil {
+				t.FailNow()
+			}
+			defer imageFile.Close()
+
+			thumbnail := &bytes.Buffer{}
+			if !assert.NoError(t, GenerateThumbnail(imageFile, thumbnail, DefaultThumbnailSize)) {
+				return
+			}
+			config, err := png.DecodeConfig(thumbnail)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, image.Pt(config.Width, config.Height))
+		})
+	}
+
+	assert.Error(t, GenerateThumbnail(bytes.NewReader([]byte("not an image")), &bytes.Buffer{}, DefaultThumbnailSize))
+}
+
+func TestIsThumbnailImage(t *testing.T) {
+	assert.True(t, IsThumbnailImage("textures/wall.PNG"))
+	assert.True(t, IsThumbnailImage("photo.jpeg"))
+	assert.False(t, IsThumbnailImage("model.fbx"))
+}
+
+func TestUpdateThumbnails(t *testing.T) {
+	testOptions := OptionsForTest{}
+	if err := SetupTestOptions(&testOptions); err != nil {
+		t.FailNow()
+	}
+	options := testOptions.OptionsWithGassetId.Clone()
+	options.WorkingDirectory = t.TempDir()
+	userDir := t.TempDir()
+	options.OsUserConfigDir = func() (string, error) {
+		return userDir, nil
+	}
+	options.Config.Thumbnails = &ThumbnailOptions{Workers: 2}
+
+	writeTestImage(t, filepath.Join(options.WorkingDirectory, "assets", "textures", "wall.png"), 512, 512)
+	writeTestImage(t, filepath.Join(options.WorkingDirectory, "assets", "floor.png"), 16, 16)
+	if err := os.WriteFile(filepath.Join(options.WorkingDirectory, "assets", "broken.png"), []byte("broken"), 0o644); err != nil {
+		t.FailNow()
+	}
+	paths := []string{"assets/textures", "assets/textures/wall.png", "assets/floor.png", "assets/broken.png", "assets/notes.txt"}
+
+	ctx := context.Background()
+	update, err := options.UpdateThumbnails(ctx, "./assets", paths)
+	assert.ErrorContains(t, err, "broken.png")
+	assert.Equal(t, ThumbnailUpdate{Generated: 2}, update)
+
+	thumbnailDir, err := options.GetThumbnailDirectory("./assets")
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(thumbnailDir, "textures", "wall.png"+ThumbnailExtension))
+
+	update, err = options.UpdateThumbnails(ctx, "./assets", paths[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, ThumbnailUpdate{Skipped: 1, Removed: 1}, update)
+	assert.NoFileExists(t, filepath.Join(thumbnailDir, "floor.png"+ThumbnailExtension))
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import "io/fs"
+
+// UmaskSupported tells whether the modes of the created files are masked by the umask of the process
+const UmaskSupported = false
+
+// Umask returns no umask as there is none on this platform
+func Umask() fs.FileMode {
+	return 0
+}
@@ -0,0 +1,180 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/restore"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PathUsage is how often an asset was restored on this machine
+type PathUsage struct {
+	Hits         int       `json:"hits"`
+	LastRestored time.Time `json:"lastRestored"`
+}
+
+// RestoreUsage is the local state of the restores of the assets by their slash separated path relative to
+// the working directory. It is only recorded with trackRestores in the .gasset file.
+type RestoreUsage struct {
+	mu    sync.Mutex
+	Paths map[string]PathUsage `json:"paths"`
+}
+
+// ColdAsset is a file of a snapshot which was not restored since the reported time
+type ColdAsset struct {
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	LastRestored time.Time `json:"lastRestored,omitempty"`
+}
+
+func (op *Options) GetRestoreUsagePath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "usage-"+op.Config.GassetId+".json"), nil
+}
+
+// LoadRestoreUsage returns the recorded restores or an empty usage if none were recorded
+func (op *Options) LoadRestoreUsage() (*RestoreUsage, error) {
+	usagePath, err := op.GetRestoreUsagePath()
+	if err != nil {
+		return nil, err
+	}
+
+	usageBytes, err := os.ReadFile(usagePath)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return &RestoreUsage{Paths: map[string]PathUsage{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &RestoreUsage{}
+	if err := json.Unmarshal(usageBytes, usage); err != nil {
+		return nil, err
+	}
+	if usage.Paths == nil {
+		usage.Paths = map[string]PathUsage{}
+	}
+	return usage, nil
+}
+
+func (op *Options) SaveRestoreUsage(usage *RestoreUsage) error {
+	usagePath, err := op.GetRestoreUsagePath()
+	if err != nil {
+		return err
+	}
+
+	usage.mu.Lock()
+	usageBytes, err := json.Marshal(usage)
+	usage.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(usagePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(usagePath, usageBytes, 0o600)
+}
+
+// Record counts a restore of the asset at the slash separated path
+func (u *RestoreUsage) Record(assetPath string, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usage := u.Paths[assetPath]
+	usage.Hits++
+	if at.After(usage.LastRestored) {
+		usage.LastRestored = at
+	}
+	u.Paths[assetPath] = usage
+}
+
+// ColdAssets returns the files, by their slash separated path and size, which were not restored since the given
+// time or never if it is zero, sorted by size with the largest first as archiving them saves the most
+func (u *RestoreUsage) ColdAssets(files map[string]int64, since time.Time) []ColdAsset {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var cold []ColdAsset
+	for filePath, size := range files {
+		usage, ok := u.Paths[filePath]
+		if ok && (since.IsZero() || !usage.LastRestored.Before(since)) {
+			continue
+		}
+		cold = append(cold, ColdAsset{Path: filePath, Size: size, LastRestored: usage.LastRestored})
+	}
+	sort.Slice(cold, func(i, j int) bool {
+		if cold[i].Size != cold[j].Size {
+			return cold[i].Size > cold[j].Size
+		}
+		return cold[i].Path < cold[j].Path
+	})
+	return cold
+}
+
+// UsageOutput counts the files restored by the wrapped output, including the ones which already existed,
+// by their path in the asset directory at dirPath
+type UsageOutput struct {
+	restore.Output
+
+	dirPath string
+	usage   *RestoreUsage
+	now     func() time.Time
+}
+
+// NewUsageOutput wraps an output restoring the asset directory at dirPath, relative to the working directory
+func NewUsageOutput(output restore.Output, dirPath string, usage *RestoreUsage, now func() time.Time) *UsageOutput {
+	return &UsageOutput{
+		Output:  output,
+		dirPath: path.Clean(filepath.ToSlash(dirPath)),
+		usage:   usage,
+		now:     now,
+	}
+}
+
+// WriteFile implements restore.Output
+func (o *UsageOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	if err := o.Output.WriteFile(ctx, relativePath, f); err != nil {
+		return err
+	}
+	o.usage.Record(path.Join(o.dirPath, relativePath), o.now())
+	return nil
+}
+
+// FileExists implements restore.Output
+func (o *UsageOutput) FileExists(ctx context.Context, relativePath string, f fs.File) bool {
+	exists := o.Output.FileExists(ctx, relativePath, f)
+	if exists {
+		o.usage.Record(path.Join(o.dirPath, relativePath), o.now())
+	}
+	return exists
+}
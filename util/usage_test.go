@@ -0,0 +1,94 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRestoreUsage(t *testing.T) {
+	userDir := t.TempDir()
+	op := &Options{
+		Config: &Config{GassetId: "0000000000"},
+		OsUserConfigDir: func() (string, error) {
+			return userDir, nil
+		},
+	}
+
+	usage, err := op.LoadRestoreUsage()
+	if !assert.NoError(t, err) {
+		return
+	}
+	monday := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	usage.Record("assets/hero.png", monday)
+	usage.Record("assets/hero.png", monday.Add(-time.Hour))
+	usage.Record("assets/old.png", monday.Add(-30*24*time.Hour))
+	if !assert.NoError(t, op.SaveRestoreUsage(usage)) {
+		return
+	}
+
+	usage, err = op.LoadRestoreUsage()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, PathUsage{Hits: 2, LastRestored: monday}, usage.Paths["assets/hero.png"])
+
+	files := map[string]int64{"assets/hero.png": 100, "assets/old.png": 10, "assets/never.png": 10, "assets/huge.psd": 1000}
+	assert.Equal(t, []ColdAsset{
+		{Path: "assets/huge.psd", Size: 1000},
+		{Path: "assets/never.png", Size: 10},
+	}, usage.ColdAssets(files, time.Time{}))
+	assert.Equal(t, []ColdAsset{
+		{Path: "assets/huge.psd", Size: 1000},
+		{Path: "assets/never.png", Size: 10},
+		{Path: "assets/old.png", Size: 10, LastRestored: monday.Add(-30 * 24 * time.Hour)},
+	}, usage.ColdAssets(files, monday.Add(-7*24*time.Hour)))
+}
+
+// partlyRestoredOutput writes nothing and reports the files named existing as already restored
+type partlyRestoredOutput struct {
+	restore.Output
+}
+
+func (partlyRestoredOutput) WriteFile(context.Context, string, fs.File) error {
+	return nil
+}
+
+func (partlyRestoredOutput) FileExists(_ context.Context, relativePath string, _ fs.File) bool {
+	return relativePath == "existing"
+}
+
+func TestUsageOutput(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	usage := &RestoreUsage{Paths: map[string]PathUsage{}}
+	output := NewUsageOutput(partlyRestoredOutput{}, "./assets", usage, func() time.Time { return now })
+
+	assert.NoError(t, output.WriteFile(ctx, "textures/wall.png", nil))
+	assert.True(t, output.FileExists(ctx, "existing", nil))
+	assert.False(t, output.FileExists(ctx, "missing", nil))
+
+	assert.Equal(t, map[string]PathUsage{
+		"assets/textures/wall.png": {Hits: 1, LastRestored: now},
+		"assets/existing":          {Hits: 1, LastRestored: now},
+	}, usage.Paths)
+}
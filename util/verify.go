@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsPath is the path of the Prometheus metrics of the long-running modes
+const MetricsPath = "/metrics"
+
+// maxAlertedFiles limits the corrupted files listed in a webhook alert
+const maxAlertedFiles = 20
+
+// VerifyResult is the outcome of reading a sample of the files of the snapshots to verify their content
+type VerifyResult struct {
+	Snapshots int           `json:"snapshots"`
+	Files     int           `json:"files"`
+	Bytes     int64         `json:"bytes"`
+	Corrupted []string      `json:"corrupted,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// VerifyStatus tracks the verification rounds of the daemon mode and serves them as Prometheus metrics
+type VerifyStatus struct {
+	// Now is replaced by the tests
+	Now func() time.Time
+
+	mu          sync.Mutex
+	started     time.Time
+	lastSuccess time.Time
+	last        *VerifyResult
+	rounds      int
+	failures    int
+	files       int
+	corrupted   int
+}
+
+func NewVerifyStatus(now func() time.Time) *VerifyStatus {
+	return &VerifyStatus{Now: now, started: now()}
+}
+
+// Record adds a finished round. A round which found corrupted files doesn't count as a successful verification.
+func (s *VerifyStatus) Record(result *VerifyResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rounds++
+	s.last = result
+	s.files += result.Files
+	s.corrupted += len(result.Corrupted)
+	if len(result.Corrupted) == 0 {
+		s.lastSuccess = s.Now()
+	}
+}
+
+// RecordFailure counts a round which could not verify anything, e.g. because the storage was unreachable
+func (s *VerifyStatus) RecordFailure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+}
+
+// Lag returns the time since the last successful verification, or since the daemon started without one
+func (s *VerifyStatus) Lag() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since := s.lastSuccess
+	if since.IsZero() {
+		since = s.started
+	}
+	return s.Now().Sub(since)
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format
+func (s *VerifyStatus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	lag := s.Lag()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b strings.Builder
+	metric := func(name string, kind string, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+	metric("gasset_verify_lag_seconds", "gauge", "Time since the last verification without corrupted files.", lag.Seconds())
+	if !s.lastSuccess.IsZero() {
+		metric("gasset_verify_last_success_timestamp_seconds", "gauge", "Time of the last verification without corrupted files.", float64(s.lastSuccess.Unix()))
+	}
+	metric("gasset_verify_rounds_total", "counter", "Verification rounds finished.", float64(s.rounds))
+	metric("gasset_verify_failures_total", "counter", "Verification rounds which could not run.", float64(s.failures))
+	metric("gasset_verify_files_total", "counter", "Files read to verify them.", float64(s.files))
+	metric("gasset_verify_corrupted_files_total", "counter", "Corrupted files found.", float64(s.corrupted))
+	if s.last != nil {
+		metric("gasset_verify_last_duration_seconds", "gauge", "Duration of the last verification round.", s.last.Duration.Seconds())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, b.String())
+}
+
+// VerifyAlert is the payload of the webhook alert sent when corrupted files are found. Text makes it
+// readable by the incoming webhooks of chat services.
+type VerifyAlert struct {
+	Text      string    `json:"text"`
+	GassetId  string    `json:"gassetId"`
+	Host      string    `json:"host"`
+	Time      time.Time `json:"time"`
+	Files     int       `json:"files"`
+	Corrupted []string  `json:"corrupted"`
+}
+
+// NewVerifyAlert returns the alert of a verification which found corrupted files
+func (op *Options) NewVerifyAlert(result *VerifyResult, now time.Time) *VerifyAlert {
+	corrupted := result.Corrupted
+	if len(corrupted) > maxAlertedFiles {
+		corrupted = corrupted[:maxAlertedFiles]
+	}
+	host := op.ClientOptions().Hostname
+	return &VerifyAlert{
+		Text: fmt.Sprintf("gasset verify found %d corrupted files in the repository %s on %s, e.g. %s",
+			len(result.Corrupted), op.Config.GassetId, host, result.Corrupted[0]),
+		GassetId:  op.Config.GassetId,
+		Host:      host,
+		Time:      now.UTC(),
+		Files:     result.Files,
+		Corrupted: corrupted,
+	}
+}
+
+// SendWebhook posts the payload as JSON to the webhook URL
+func SendWebhook(ctx context.Context, client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("the webhook answered %s", response.Status)
+	}
+	return nil
+}
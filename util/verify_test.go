@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kopia/kopia/repo"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifyStatus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	status := NewVerifyStatus(func() time.Time { return now })
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, time.Hour, status.Lag(), "the lag since the start without a verification")
+
+	status.Record(&VerifyResult{Files: 10, Duration: time.Minute})
+	now = now.Add(2 * time.Hour)
+	status.Record(&VerifyResult{Files: 5, Corrupted: []string{"assets/a.txt (snapshot k1): hash mismatch"}})
+	status.RecordFailure()
+	assert.Equal(t, 2*time.Hour, status.Lag(), "a verification with corrupted files doesn't reset the lag")
+
+	recorder := httptest.NewRecorder()
+	status.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "gasset_verify_lag_seconds 7200\n")
+	assert.Contains(t, body, "gasset_verify_rounds_total 2\n")
+	assert.Contains(t, body, "gasset_verify_failures_total 1\n")
+	assert.Contains(t, body, "gasset_verify_files_total 15\n")
+	assert.Contains(t, body, "gasset_verify_corrupted_files_total 1\n")
+}
+
+func TestSendWebhook(t *testing.T) {
+	var got VerifyAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	op := &Options{Config: &Config{
+		GassetId: "0000000000",
+		Kopia:    &repo.LocalConfig{ClientOptions: repo.ClientOptions{Hostname: "nas"}},
+	}}
+	result := &VerifyResult{Files: 3, Corrupted: []string{"assets/a.txt (snapshot k1): hash mismatch"}}
+	alert := op.NewVerifyAlert(result, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if !assert.NoError(t, SendWebhook(context.Background(), server.Client(), server.URL, alert)) {
+		return
+	}
+	assert.Equal(t, "nas", got.Host)
+	assert.Equal(t, result.Corrupted, got.Corrupted)
+	assert.Contains(t, got.Text, "1 corrupted files")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failing.Close()
+	assert.Error(t, SendWebhook(context.Background(), failing.Client(), failing.URL, alert))
+}
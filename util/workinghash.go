@@ -0,0 +1,230 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+	"hash"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	HashXXH64  = "xxhash"
+	HashBLAKE3 = "blake3"
+)
+
+// WorkingHashOptions enables recording a fast hash of the working files on snap, which check verifies
+// without reading the repository
+type WorkingHashOptions struct {
+	// Algorithm is xxhash or blake3, defaults to xxhash
+	Algorithm string `json:"algorithm,omitempty"`
+	// InLockFile records the hashes in the .gasset.lock file as well, so that clones can verify their files
+	InLockFile bool `json:"inLockFile,omitempty"`
+}
+
+func (o *WorkingHashOptions) HashAlgorithm() string {
+	if o == nil || o.Algorithm == "" {
+		return HashXXH64
+	}
+	return o.Algorithm
+}
+
+// WorkingHash is the hash of a working file together with the size and modification time it was hashed at
+type WorkingHash struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"`
+}
+
+// WorkingHashes is the local state of the hashes of the files in the asset directories by their slash
+// separated path relative to the working directory
+type WorkingHashes struct {
+	Algorithm string                 `json:"algorithm"`
+	Files     map[string]WorkingHash `json:"files"`
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case HashXXH64:
+		return xxhash.New(), nil
+	case HashBLAKE3:
+		return blake3.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm %s, use %s or %s", algorithm, HashXXH64, HashBLAKE3)
+	}
+}
+
+// HashFile returns the hex encoded hash of the content of a file
+func HashFile(algorithm string, filePath string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashWorkingFiles hashes the regular files of an asset directory. Files with the same size and modification
+// time as in previous are not read again, like git does with its index.
+func HashWorkingFiles(root string, dirPath string, algorithm string, previous *WorkingHashes) (map[string]WorkingHash, error) {
+	if _, err := newHash(algorithm); err != nil {
+		return nil, err
+	}
+	if previous != nil && previous.Algorithm != algorithm {
+		previous = nil
+	}
+
+	hashes := map[string]WorkingHash{}
+	err := filepath.WalkDir(filepath.Join(root, dirPath), func(filePath string, entry iofs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		// The lock file of the directory is not an asset
+		if entry.Name() == LockFileName && filepath.Dir(filePath) == filepath.Join(root, dirPath) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if previous != nil {
+			if known, ok := previous.Files[relPath]; ok && known.Size == info.Size() && known.ModTime.Equal(info.ModTime()) {
+				hashes[relPath] = known
+				return nil
+			}
+		}
+
+		fileHash, err := HashFile(algorithm, filePath)
+		if err != nil {
+			return err
+		}
+		hashes[relPath] = WorkingHash{Size: info.Size(), ModTime: info.ModTime(), Hash: fileHash}
+		return nil
+	})
+	return hashes, err
+}
+
+// inAssetDir reports if the slash separated path of a file relative to the working directory is in an asset directory
+func inAssetDir(filePath string, dirPath string) bool {
+	return strings.HasPrefix(filePath, path.Clean(filepath.ToSlash(dirPath))+"/")
+}
+
+// RecordDir replaces the hashes of the files in an asset directory
+func (w *WorkingHashes) RecordDir(dirPath string, hashes map[string]WorkingHash) {
+	for filePath := range w.Files {
+		if inAssetDir(filePath, dirPath) {
+			delete(w.Files, filePath)
+		}
+	}
+	for filePath, fileHash := range hashes {
+		w.Files[filePath] = fileHash
+	}
+}
+
+// DirHashes returns the hashes of the files in an asset directory by their path, which DiffFiles compares
+func (w *WorkingHashes) DirHashes(dirPath string) map[string]string {
+	hashes := map[string]string{}
+	for filePath, fileHash := range w.Files {
+		if inAssetDir(filePath, dirPath) {
+			hashes[filePath] = fileHash.Hash
+		}
+	}
+	return hashes
+}
+
+func (op *Options) GetWorkingHashesPath() (string, error) {
+	if op.Config.GassetId == "" {
+		return "", errors.New("gasset id is empty")
+	}
+	userDir, err := op.OsUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "git-gasset", "hashes-"+op.stateID()+".json"), nil
+}
+
+// LoadWorkingHashes returns the recorded hashes or empty ones with the configured algorithm if none were recorded
+func (op *Options) LoadWorkingHashes() (*WorkingHashes, error) {
+	hashesPath, err := op.GetWorkingHashesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	empty := &WorkingHashes{Algorithm: op.Config.WorkingHashes.HashAlgorithm(), Files: map[string]WorkingHash{}}
+	hashesBytes, err := os.ReadFile(hashesPath)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := &WorkingHashes{}
+	if err := json.Unmarshal(hashesBytes, hashes); err != nil {
+		return nil, err
+	}
+	if hashes.Algorithm != empty.Algorithm {
+		return empty, nil
+	}
+	if hashes.Files == nil {
+		hashes.Files = map[string]WorkingHash{}
+	}
+	return hashes, nil
+}
+
+func (op *Options) SaveWorkingHashes(hashes *WorkingHashes) error {
+	hashesPath, err := op.GetWorkingHashesPath()
+	if err != nil {
+		return err
+	}
+
+	hashesBytes, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hashesPath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(hashesPath, hashesBytes, 0o600)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2024 Sayak Mukhopadhyay
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "wall.png")
+	if err := os.WriteFile(file, []byte("png"), 0644); err != nil {
+		t.FailNow()
+	}
+
+	xxh, err := HashFile(HashXXH64, file)
+	assert.NoError(t, err)
+	assert.Len(t, xxh, 16)
+
+	b3, err := HashFile(HashBLAKE3, file)
+	assert.NoError(t, err)
+	assert.Len(t, b3, 64)
+
+	_, err = HashFile("md5", file)
+	assert.Error(t, err)
+}
+
+func TestHashWorkingFiles(t *testing.T) {
+	root := t.TempDir()
+	textures := filepath.Join(root, "assets", "textures")
+	if err := os.MkdirAll(textures, 0755); err != nil {
+		t.FailNow()
+	}
+	wall := filepath.Join(textures, "wall.png")
+	if err := os.WriteFile(wall, []byte("png"), 0644); err != nil {
+		t.FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(root, "assets", "sound.ogg"), []byte("ogg"), 0644); err != nil {
+		t.FailNow()
+	}
+	// The lock file of the directory is left out
+	if err := os.WriteFile(filepath.Join(root, "assets", LockFileName), []byte("{}"), 0644); err != nil {
+		t.FailNow()
+	}
+
+	hashes, err := HashWorkingFiles(root, "./assets", HashXXH64, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, hashes, 2)
+	assert.Contains(t, hashes, "assets/textures/wall.png")
+	assert.Contains(t, hashes, "assets/sound.ogg")
+
+	// Files with the size and modification time recorded before are not read again
+	stale := hashes["assets/textures/wall.png"]
+	stale.Hash = "recorded"
+	previous := &WorkingHashes{Algorithm: HashXXH64, Files: map[string]WorkingHash{"assets/textures/wall.png": stale}}
+	reused, err := HashWorkingFiles(root, "./assets", HashXXH64, previous)
+	assert.NoError(t, err)
+	assert.Equal(t, "recorded", reused["assets/textures/wall.png"].Hash)
+
+	// but they are once they change
+	if err := os.Chtimes(wall, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.FailNow()
+	}
+	rehashed, err := HashWorkingFiles(root, "./assets", HashXXH64, previous)
+	assert.NoError(t, err)
+	assert.Equal(t, hashes["assets/textures/wall.png"].Hash, rehashed["assets/textures/wall.png"].Hash)
+}
+
+func TestWorkingHashesRecordDir(t *testing.T) {
+	hashes := &WorkingHashes{Algorithm: HashXXH64, Files: map[string]WorkingHash{
+		"assets/old.png":     {Hash: "1"},
+		"assets-raw/raw.psd": {Hash: "2"},
+	}}
+	hashes.RecordDir("./assets/", map[string]WorkingHash{"assets/new.png": {Hash: "3"}})
+
+	assert.Equal(t, map[string]string{"assets/new.png": "3"}, hashes.DirHashes("assets"))
+	assert.Equal(t, map[string]string{"assets-raw/raw.psd": "2"}, hashes.DirHashes("./assets-raw"))
+
+	lock := &LockFile{WorkingHashes: &LockHashes{Algorithm: HashBLAKE3, Files: map[string]string{"assets-raw/raw.psd": "2"}}}
+	lock.RecordDir(HashXXH64, "./assets", map[string]WorkingHash{"assets/new.png": {Size: 5, Hash: "3"}})
+	assert.Equal(t, &LockHashes{
+		Algorithm: HashXXH64,
+		Files:     map[string]string{"assets/new.png": "3"},
+		Sizes:     map[string]int64{"assets/new.png": 5},
+	}, lock.WorkingHashes)
+}
+
+func TestLoadWorkingHashes(t *testing.T) {
+	userDir := t.TempDir()
+	op := &Options{
+		Config:          &Config{GassetId: "abc", WorkingHashes: &WorkingHashOptions{}},
+		OsUserConfigDir: func() (string, error) { return userDir, nil },
+	}
+
+	hashes, err := op.LoadWorkingHashes()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &WorkingHashes{Algorithm: HashXXH64, Files: map[string]WorkingHash{}}, hashes)
+
+	hashes.Files["assets/wall.png"] = WorkingHash{Size: 3, ModTime: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), Hash: "1"}
+	if !assert.NoError(t, op.SaveWorkingHashes(hashes)) {
+		return
+	}
+	loaded, err := op.LoadWorkingHashes()
+	assert.NoError(t, err)
+	assert.Equal(t, hashes, loaded)
+
+	// Hashes of another algorithm are dropped
+	op.Config.WorkingHashes.Algorithm = HashBLAKE3
+	loaded, err = op.LoadWorkingHashes()
+	assert.NoError(t, err)
+	assert.Empty(t, loaded.Files)
+}